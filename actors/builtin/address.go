@@ -0,0 +1,75 @@
+package builtin
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
+)
+
+// Protocol of delegated addresses, which identify actors by addresses assigned by other actors.
+// The address library and the network don't yet support it, but such an address is resolved like any other
+// once they do, so actors accepting it need no change.
+const DelegatedProtocol = addr.Protocol(4)
+
+// Whether actors resolve addresses of a protocol.
+func IsSupportedAddressProtocol(p addr.Protocol) bool {
+	switch p {
+	case addr.ID, addr.SECP256K1, addr.Actor, addr.BLS, DelegatedProtocol:
+		return true
+	}
+	return false
+}
+
+// Resolves an address to the ID address of an existing actor, returning the actor's code.
+func ResolveActorAddress(rt runtime.Runtime, raw addr.Address) (addr.Address, cid.Cid, error) {
+	if !IsSupportedAddressProtocol(raw.Protocol()) {
+		return addr.Undef, cid.Undef, exitcode.ErrIllegalArgument.Wrapf("unsupported protocol %d of address %v", raw.Protocol(), raw)
+	}
+	resolved, ok := rt.ResolveAddress(raw)
+	if !ok {
+		return addr.Undef, cid.Undef, exitcode.ErrIllegalArgument.Wrapf("unable to resolve address %v", raw)
+	}
+	code, ok := rt.GetActorCodeCID(resolved)
+	if !ok {
+		return addr.Undef, cid.Undef, exitcode.ErrIllegalArgument.Wrapf("no code for address %v", resolved)
+	}
+	return resolved, code, nil
+}
+
+// Resolves an address to the ID address of a principal actor, one that may sign messages.
+func ResolvePrincipalAddress(rt runtime.Runtime, raw addr.Address) (addr.Address, error) {
+	resolved, code, err := ResolveActorAddress(rt, raw)
+	if err != nil {
+		return addr.Undef, err
+	}
+	if !IsPrincipal(code) {
+		return addr.Undef, exitcode.ErrIllegalArgument.Wrapf("actor %v type must be a principal, was %v", resolved, code)
+	}
+	return resolved, nil
+}
+
+// Resolves an address to the ID address of an account actor with a BLS key.
+// The key of an account addressed other than by its BLS address is fetched from the account.
+func ResolveBLSAccountAddress(rt runtime.Runtime, raw addr.Address) (addr.Address, error) {
+	resolved, code, err := ResolveActorAddress(rt, raw)
+	if err != nil {
+		return addr.Undef, err
+	}
+	if code != AccountActorCodeID {
+		return addr.Undef, exitcode.ErrIllegalArgument.Wrapf("actor %v type must be an account, was %v", resolved, code)
+	}
+	if raw.Protocol() != addr.BLS {
+		var pubkey addr.Address
+		code := rt.Send(resolved, MethodsAccount.PubkeyAddress, nil, big.Zero(), &pubkey)
+		if !code.IsSuccess() {
+			return addr.Undef, code.Wrapf("failed to fetch account pubkey from %v", resolved)
+		}
+		if pubkey.Protocol() != addr.BLS {
+			return addr.Undef, exitcode.ErrIllegalArgument.Wrapf("account %v must have BLS pubkey, was %v", resolved, pubkey.Protocol())
+		}
+	}
+	return resolved, nil
+}
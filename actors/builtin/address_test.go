@@ -0,0 +1,153 @@
+package builtin_test
+
+import (
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
+	"github.com/filecoin-project/specs-actors/v8/support/mock"
+	tutil "github.com/filecoin-project/specs-actors/v8/support/testing"
+)
+
+func TestIsSupportedAddressProtocol(t *testing.T) {
+	for _, p := range []addr.Protocol{addr.ID, addr.SECP256K1, addr.Actor, addr.BLS, builtin.DelegatedProtocol} {
+		assert.True(t, builtin.IsSupportedAddressProtocol(p), "protocol %d", p)
+	}
+	for _, p := range []addr.Protocol{addr.Unknown, builtin.DelegatedProtocol + 1} {
+		assert.False(t, builtin.IsSupportedAddressProtocol(p), "protocol %d", p)
+	}
+}
+
+func TestResolveAddress(t *testing.T) {
+	receiver := tutil.NewIDAddr(t, 100)
+	accountID := tutil.NewIDAddr(t, 101)
+	multisigID := tutil.NewIDAddr(t, 102)
+	minerID := tutil.NewIDAddr(t, 103)
+	blsAddr := tutil.NewBLSAddr(t, 1)
+	secpAddr := tutil.NewSECP256K1Addr(t, "secp")
+	actorAddr := tutil.NewActorAddr(t, "multisig")
+
+	setup := func(t *testing.T) *mock.Runtime {
+		rt := mock.NewBuilder(receiver).
+			WithActorType(accountID, builtin.AccountActorCodeID).
+			WithActorType(multisigID, builtin.MultisigActorCodeID).
+			WithActorType(minerID, builtin.StorageMinerActorCodeID).
+			Build(t)
+		rt.AddIDAddress(blsAddr, accountID)
+		rt.AddIDAddress(secpAddr, accountID)
+		rt.AddIDAddress(actorAddr, multisigID)
+		return rt
+	}
+	// Calls f within a method invocation, as the runtime requires.
+	call := func(rt *mock.Runtime, f func(rt runtime.Runtime)) {
+		rt.Call(func(rt runtime.Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
+			f(rt)
+			return nil
+		}, nil)
+	}
+
+	t.Run("resolves an actor by address of each protocol", func(t *testing.T) {
+		rt := setup(t)
+		for _, tc := range []struct {
+			raw      addr.Address
+			expected addr.Address
+			code     cid.Cid
+		}{
+			{accountID, accountID, builtin.AccountActorCodeID},
+			{blsAddr, accountID, builtin.AccountActorCodeID},
+			{secpAddr, accountID, builtin.AccountActorCodeID},
+			{actorAddr, multisigID, builtin.MultisigActorCodeID},
+			{minerID, minerID, builtin.StorageMinerActorCodeID},
+		} {
+			call(rt, func(rt runtime.Runtime) {
+				resolved, code, err := builtin.ResolveActorAddress(rt, tc.raw)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, resolved)
+				assert.Equal(t, tc.code, code)
+			})
+		}
+	})
+
+	t.Run("rejects an address that is unsupported, unresolved or of no actor", func(t *testing.T) {
+		rt := setup(t)
+		for _, raw := range []addr.Address{
+			addr.Undef,
+			tutil.NewBLSAddr(t, 2),
+			tutil.NewIDAddr(t, 999),
+		} {
+			call(rt, func(rt runtime.Runtime) {
+				_, _, err := builtin.ResolveActorAddress(rt, raw)
+				assert.Equal(t, exitcode.ErrIllegalArgument, exitcode.Unwrap(err, exitcode.Ok), "address %v", raw)
+			})
+		}
+	})
+
+	t.Run("resolves principals", func(t *testing.T) {
+		rt := setup(t)
+		call(rt, func(rt runtime.Runtime) {
+			resolved, err := builtin.ResolvePrincipalAddress(rt, secpAddr)
+			require.NoError(t, err)
+			assert.Equal(t, accountID, resolved)
+			resolved, err = builtin.ResolvePrincipalAddress(rt, actorAddr)
+			require.NoError(t, err)
+			assert.Equal(t, multisigID, resolved)
+
+			_, err = builtin.ResolvePrincipalAddress(rt, minerID)
+			assert.Equal(t, exitcode.ErrIllegalArgument, exitcode.Unwrap(err, exitcode.Ok))
+		})
+	})
+
+	t.Run("resolves a BLS account by its BLS address without fetching its key", func(t *testing.T) {
+		rt := setup(t)
+		call(rt, func(rt runtime.Runtime) {
+			resolved, err := builtin.ResolveBLSAccountAddress(rt, blsAddr)
+			require.NoError(t, err)
+			assert.Equal(t, accountID, resolved)
+		})
+		rt.Verify()
+	})
+
+	t.Run("fetches the key of an account addressed otherwise", func(t *testing.T) {
+		rt := setup(t)
+		rt.ExpectSend(accountID, builtin.MethodsAccount.PubkeyAddress, nil, big.Zero(), &blsAddr, exitcode.Ok)
+		call(rt, func(rt runtime.Runtime) {
+			resolved, err := builtin.ResolveBLSAccountAddress(rt, accountID)
+			require.NoError(t, err)
+			assert.Equal(t, accountID, resolved)
+		})
+		rt.Verify()
+
+		rt.ExpectSend(accountID, builtin.MethodsAccount.PubkeyAddress, nil, big.Zero(), &secpAddr, exitcode.Ok)
+		call(rt, func(rt runtime.Runtime) {
+			_, err := builtin.ResolveBLSAccountAddress(rt, secpAddr)
+			assert.Equal(t, exitcode.ErrIllegalArgument, exitcode.Unwrap(err, exitcode.Ok))
+		})
+		rt.Verify()
+	})
+
+	t.Run("fails if the account's key cannot be fetched", func(t *testing.T) {
+		rt := setup(t)
+		rt.ExpectSend(accountID, builtin.MethodsAccount.PubkeyAddress, nil, big.Zero(), &blsAddr, exitcode.ErrForbidden)
+		call(rt, func(rt runtime.Runtime) {
+			_, err := builtin.ResolveBLSAccountAddress(rt, accountID)
+			assert.Equal(t, exitcode.ErrForbidden, exitcode.Unwrap(err, exitcode.Ok))
+		})
+		rt.Verify()
+	})
+
+	t.Run("rejects an actor that is not an account", func(t *testing.T) {
+		rt := setup(t)
+		call(rt, func(rt runtime.Runtime) {
+			_, err := builtin.ResolveBLSAccountAddress(rt, actorAddr)
+			assert.Equal(t, exitcode.ErrIllegalArgument, exitcode.Unwrap(err, exitcode.Ok))
+		})
+	})
+}
@@ -0,0 +1,101 @@
+package market
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"golang.org/x/xerrors"
+)
+
+// A provider's standing offer to accept any deal meeting its terms.
+// Deals matching a provider's unexpired ask may be published by the client
+// without a message from the provider's worker or control addresses.
+type ProviderAsk struct {
+	// Minimum acceptable storage price per epoch.
+	MinPricePerEpoch abi.TokenAmount
+	// Inclusive bounds on the deal piece size.
+	MinPieceSize abi.PaddedPieceSize
+	MaxPieceSize abi.PaddedPieceSize
+	// Minimum number of epochs between a deal's publication and its start, giving the provider time to seal.
+	MinStartDelay abi.ChainEpoch
+	// Inclusive bounds on the deal duration.
+	MinDuration abi.ChainEpoch
+	MaxDuration abi.ChainEpoch
+	// Maximum provider collateral the provider will lock for a single deal.
+	MaxProviderCollateral abi.TokenAmount
+	// Maximum provider collateral the provider will commit to all deals published against the ask over its lifetime.
+	MaxTotalProviderCollateral abi.TokenAmount
+	// Total provider collateral of the deals published against the ask so far. It only grows: collateral is
+	// not returned to the ask as those deals settle, so the total maximum bounds all collateral ever committed.
+	// Maintained by the market actor, so must be zero when the ask is posted.
+	ProviderCollateralCommitted abi.TokenAmount
+	// Whether only verified deals are accepted.
+	VerifiedOnly bool
+	// Epoch at which the ask stops matching deals.
+	Expiry abi.ChainEpoch
+}
+
+// Checks that the ask's terms are internally consistent and unexpired at the current epoch.
+func (a *ProviderAsk) Validate(currEpoch abi.ChainEpoch) error {
+	if a.MinPricePerEpoch.LessThan(big.Zero()) {
+		return xerrors.Errorf("negative minimum price %v", a.MinPricePerEpoch)
+	}
+	if a.MaxProviderCollateral.LessThan(big.Zero()) {
+		return xerrors.Errorf("negative maximum provider collateral %v", a.MaxProviderCollateral)
+	}
+	if a.MaxTotalProviderCollateral.LessThan(a.MaxProviderCollateral) {
+		return xerrors.Errorf("maximum total provider collateral %v less than maximum per deal %v",
+			a.MaxTotalProviderCollateral, a.MaxProviderCollateral)
+	}
+	if !a.ProviderCollateralCommitted.IsZero() {
+		return xerrors.Errorf("ask posted with provider collateral %v already committed", a.ProviderCollateralCommitted)
+	}
+	if a.MinPieceSize > a.MaxPieceSize {
+		return xerrors.Errorf("minimum piece size %d exceeds maximum %d", a.MinPieceSize, a.MaxPieceSize)
+	}
+	if a.MinStartDelay < 0 {
+		return xerrors.Errorf("negative minimum start delay %d", a.MinStartDelay)
+	}
+	if a.MinDuration < 0 || a.MinDuration > a.MaxDuration {
+		return xerrors.Errorf("duration range [%d, %d] invalid", a.MinDuration, a.MaxDuration)
+	}
+	if a.Expiry <= currEpoch {
+		return xerrors.Errorf("ask expiry %d must be after current epoch %d", a.Expiry, currEpoch)
+	}
+	return nil
+}
+
+// Returns an error describing the first of the ask's terms that a proposal fails to meet.
+func (a *ProviderAsk) Matches(proposal *DealProposal, currEpoch abi.ChainEpoch) error {
+	if currEpoch >= a.Expiry {
+		return xerrors.Errorf("ask expired at %d", a.Expiry)
+	}
+	if proposal.StoragePricePerEpoch.LessThan(a.MinPricePerEpoch) {
+		return xerrors.Errorf("price %v below ask minimum %v", proposal.StoragePricePerEpoch, a.MinPricePerEpoch)
+	}
+	if proposal.PieceSize < a.MinPieceSize || proposal.PieceSize > a.MaxPieceSize {
+		return xerrors.Errorf("piece size %d outside ask range [%d, %d]", proposal.PieceSize, a.MinPieceSize, a.MaxPieceSize)
+	}
+	if startDelay := proposal.StartEpoch - currEpoch; startDelay < a.MinStartDelay {
+		return xerrors.Errorf("start epoch %d is %d epochs away, below ask minimum delay %d", proposal.StartEpoch, startDelay, a.MinStartDelay)
+	}
+	if duration := proposal.Duration(); duration < a.MinDuration || duration > a.MaxDuration {
+		return xerrors.Errorf("duration %d outside ask range [%d, %d]", duration, a.MinDuration, a.MaxDuration)
+	}
+	if proposal.ProviderCollateral.GreaterThan(a.MaxProviderCollateral) {
+		return xerrors.Errorf("provider collateral %v exceeds ask maximum %v", proposal.ProviderCollateral, a.MaxProviderCollateral)
+	}
+	if a.VerifiedOnly && !proposal.VerifiedDeal {
+		return xerrors.Errorf("ask accepts only verified deals")
+	}
+	return nil
+}
+
+// Returns an error if committing a further amount of provider collateral against the ask would exceed its total maximum.
+func (a *ProviderAsk) CheckCollateralAvailable(amount abi.TokenAmount) error {
+	committed := big.Add(a.ProviderCollateralCommitted, amount)
+	if committed.GreaterThan(a.MaxTotalProviderCollateral) {
+		return xerrors.Errorf("provider collateral %v committed against ask would exceed total maximum %v",
+			committed, a.MaxTotalProviderCollateral)
+	}
+	return nil
+}
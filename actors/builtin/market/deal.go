@@ -1,6 +1,20 @@
 package market
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
 	market0 "github.com/filecoin-project/specs-actors/actors/builtin/market"
 )
 
@@ -12,6 +26,135 @@ import (
 //}
 var PieceCIDPrefix = market0.PieceCIDPrefix
 
+// Prefix of the CID of a serialized deal proposal, as computed by DealProposal.Cid.
+var DealProposalCIDPrefix = cid.Prefix{
+	Version:  1,
+	Codec:    cid.DagCBOR,
+	MhType:   mh.BLAKE2B_MIN + 31,
+	MhLength: 32,
+}
+
+// A label chosen by a deal's client, which is either a UTF-8 string or raw bytes.
+// A string label is serialized as a CBOR text string and a bytes label as a CBOR byte string, so that
+// a deal proposal's serialization, and hence its CID, is preserved whichever the client chose.
+// The zero value is the empty string label.
+type DealLabel struct {
+	bs        []byte
+	notString bool
+}
+
+var EmptyDealLabel = DealLabel{}
+
+// Makes a string label, which must be valid UTF-8.
+// A label's length is checked against DealMaxLabelSize only when its deal is published.
+func NewLabelFromString(s string) (DealLabel, error) {
+	if !utf8.ValidString(s) {
+		return EmptyDealLabel, xerrors.Errorf("provided string is invalid utf8")
+	}
+	return DealLabel{bs: []byte(s)}, nil
+}
+
+// Makes a bytes label.
+func NewLabelFromBytes(b []byte) DealLabel {
+	return DealLabel{bs: b, notString: true}
+}
+
+func (l DealLabel) IsString() bool {
+	return !l.notString
+}
+
+func (l DealLabel) IsBytes() bool {
+	return l.notString
+}
+
+func (l DealLabel) ToString() (string, error) {
+	if !l.IsString() {
+		return "", xerrors.Errorf("label is not string")
+	}
+	return string(l.bs), nil
+}
+
+func (l DealLabel) ToBytes() ([]byte, error) {
+	if !l.IsBytes() {
+		return nil, xerrors.Errorf("label is not bytes")
+	}
+	return l.bs, nil
+}
+
+// Length of the label in bytes, whether a string or bytes.
+func (l DealLabel) Length() int {
+	return len(l.bs)
+}
+
+// Whether two labels have the same content and are both strings or both bytes.
+func (l DealLabel) Equals(o DealLabel) bool {
+	return bytes.Equal(l.bs, o.bs) && l.notString == o.notString
+}
+
+func (l *DealLabel) MarshalCBOR(w io.Writer) error {
+	scratch := make([]byte, 9)
+
+	// A nil label is marshalled as the empty string label.
+	if l == nil {
+		return cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajTextString, 0)
+	}
+	// The same limit applies to string and bytes labels, in both directions.
+	if len(l.bs) > cbg.ByteArrayMaxLen {
+		return xerrors.Errorf("label is too long to marshal (%d), max allowed (%d)", len(l.bs), cbg.ByteArrayMaxLen)
+	}
+
+	majorType := byte(cbg.MajByteString)
+	if l.IsString() {
+		majorType = cbg.MajTextString
+	}
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, majorType, uint64(len(l.bs))); err != nil {
+		return err
+	}
+	_, err := w.Write(l.bs)
+	return err
+}
+
+func (l *DealLabel) UnmarshalCBOR(r io.Reader) error {
+	if l == nil {
+		return xerrors.Errorf("cannot unmarshal into nil label")
+	}
+	*l = EmptyDealLabel
+
+	scratch := make([]byte, 8)
+	maj, length, err := cbg.CborReadHeaderBuf(r, scratch)
+	if err != nil {
+		return err
+	}
+	switch maj {
+	case cbg.MajTextString:
+		if length > cbg.ByteArrayMaxLen {
+			return xerrors.Errorf("label string was too long (%d), max allowed (%d)", length, cbg.ByteArrayMaxLen)
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		if !utf8.Valid(buf) {
+			return xerrors.Errorf("label string not valid utf8")
+		}
+		l.bs = buf
+	case cbg.MajByteString:
+		if length > cbg.ByteArrayMaxLen {
+			return xerrors.Errorf("label bytes was too long (%d), max allowed (%d)", length, cbg.ByteArrayMaxLen)
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		l.bs = buf
+		l.notString = true
+	default:
+		return xerrors.Errorf("unexpected major type %d for label, expected text string (%d) or byte string (%d)",
+			maj, cbg.MajTextString, cbg.MajByteString)
+	}
+	return nil
+}
+
 // Note: Deal Collateral is only released and returned to clients and miners
 // when the storage deal stops counting towards power. In the current iteration,
 // it will be released when the sector containing the storage deals expires,
@@ -20,33 +163,390 @@ var PieceCIDPrefix = market0.PieceCIDPrefix
 // minimal deals that last for a long time.
 // Note: ClientCollateralPerEpoch may not be needed and removed pending future confirmation.
 // There will be a Minimum value for both client and provider deal collateral.
-//type DealProposal struct {
-//	PieceCID     cid.Cid `checked:"true"` // Checked in validateDeal, CommP
-//	PieceSize    abi.PaddedPieceSize
-//	VerifiedDeal bool
-//	Client       addr.Address
-//	Provider     addr.Address
-//
-//	// Label is an arbitrary client chosen label to apply to the deal
-//	// TODO: Limit the size of this: https://github.com/filecoin-project/specs-actors/issues/897
-//	Label string
-//
-//	// Nominal start epoch. Deal payment is linear between StartEpoch and EndEpoch,
-//	// with total amount StoragePricePerEpoch * (EndEpoch - StartEpoch).
-//	// Storage deal must appear in a sealed (proven) sector no later than StartEpoch,
-//	// otherwise it is invalid.
-//	StartEpoch           abi.ChainEpoch
-//	EndEpoch             abi.ChainEpoch
-//	StoragePricePerEpoch abi.TokenAmount
-//
-//	ProviderCollateral abi.TokenAmount
-//	ClientCollateral   abi.TokenAmount
-//}
-type DealProposal = market0.DealProposal
+type DealProposal struct {
+	PieceCID     cid.Cid `checked:"true"` // Checked in validateDeal, CommP
+	PieceSize    abi.PaddedPieceSize
+	VerifiedDeal bool
+	Client       addr.Address
+	Provider     addr.Address
+
+	// Label is an arbitrary client chosen label to apply to the deal
+	Label DealLabel
+
+	// Nominal start epoch. Deal payment is linear between StartEpoch and EndEpoch,
+	// with total amount StoragePricePerEpoch * (EndEpoch - StartEpoch).
+	// Storage deal must appear in a sealed (proven) sector no later than StartEpoch,
+	// otherwise it is invalid.
+	StartEpoch           abi.ChainEpoch
+	EndEpoch             abi.ChainEpoch
+	StoragePricePerEpoch abi.TokenAmount
+
+	ProviderCollateral abi.TokenAmount
+	ClientCollateral   abi.TokenAmount
+
+	// Terms of the provider's commitment to serve retrievals of the piece, nil if it makes none.
+	RetrievalSLA *RetrievalSLA
+}
+
+// Terms of a provider's commitment to serve retrievals of a deal's piece.
+// The client may attest to each retrieval the provider fails to serve. An attested violation the provider does
+// not dispute is penalized from the provider's collateral for the deal, and the penalty burnt.
+type RetrievalSLA struct {
+	// Share of retrieval requests the provider commits to serve, out of RetrievalAvailabilityDenominator.
+	Availability uint64
+	// Provider collateral burnt for each violation penalized.
+	ViolationPenalty abi.TokenAmount
+}
 
 // ClientDealProposal is a DealProposal signed by a client
-// type ClientDealProposal struct {
-// 	Proposal        DealProposal
-// 	ClientSignature crypto.Signature
-// }
-type ClientDealProposal = market0.ClientDealProposal
+type ClientDealProposal struct {
+	Proposal        DealProposal
+	ClientSignature crypto.Signature
+}
+
+func (p *DealProposal) Duration() abi.ChainEpoch {
+	return p.EndEpoch - p.StartEpoch
+}
+
+func (p *DealProposal) TotalStorageFee() abi.TokenAmount {
+	return big.Mul(p.StoragePricePerEpoch, big.NewInt(int64(p.Duration())))
+}
+
+func (p *DealProposal) ClientBalanceRequirement() abi.TokenAmount {
+	return big.Add(p.ClientCollateral, p.TotalStorageFee())
+}
+
+func (p *DealProposal) ProviderBalanceRequirement() abi.TokenAmount {
+	return p.ProviderCollateral
+}
+
+// The share of retrieval requests the provider commits to serve, out of RetrievalAvailabilityDenominator,
+// which is zero for a deal without retrieval terms.
+func (p *DealProposal) RetrievalAvailability() uint64 {
+	if p.RetrievalSLA == nil {
+		return 0
+	}
+	return p.RetrievalSLA.Availability
+}
+
+// The penalty burnt for each retrieval violation penalized, which is zero for a deal without
+// retrieval terms.
+func (p *DealProposal) RetrievalViolationPenalty() abi.TokenAmount {
+	if p.RetrievalSLA == nil {
+		return big.Zero()
+	}
+	return p.RetrievalSLA.ViolationPenalty
+}
+
+func (p *DealProposal) Cid() (cid.Cid, error) {
+	buf := new(bytes.Buffer)
+	if err := p.MarshalCBOR(buf); err != nil {
+		return cid.Undef, err
+	}
+	return abi.CidBuilder.Sum(buf.Bytes())
+}
+
+// Whether a deal is a verified deal with no price and no collateral from either party.
+// Such a deal locks no funds, so it is published and settled without touching the escrow or locked tables.
+func IsDataOnboardingDeal(proposal *DealProposal) bool {
+	return proposal.VerifiedDeal &&
+		proposal.StoragePricePerEpoch.IsZero() &&
+		proposal.ClientCollateral.IsZero() &&
+		proposal.ProviderCollateral.IsZero()
+}
+
+// Key under which a client's deals are indexed by label.
+// The key is a digest of the client's ID address and the first DealLabelIndexPrefixSize bytes of the label,
+// so deals whose labels share a long common prefix share a key.
+type LabelIndexKey [32]byte
+
+func (k LabelIndexKey) Key() string {
+	return string(k[:])
+}
+
+// Computes the label index key for a label chosen by a client, who must be identified by ID address.
+// String and bytes labels with the same content share a key.
+func ComputeLabelIndexKey(client addr.Address, label DealLabel, hash func([]byte) [32]byte) LabelIndexKey {
+	prefix := label.bs
+	if len(prefix) > DealLabelIndexPrefixSize {
+		prefix = prefix[:DealLabelIndexPrefixSize]
+	}
+	data := append(client.Bytes(), prefix...)
+	return hash(data)
+}
+
+// Number of fields in the serialization of a proposal without retrieval terms.
+const dealProposalBaseFields = 11
+
+// Proposals are serialized as a CBOR array of their fields, with the retrieval terms appended only if present,
+// so that a proposal without them serializes, and has the CID, as it did before they were introduced.
+// These methods are maintained by hand, rather than generated, to omit the absent terms.
+
+func (t *DealProposal) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	scratch := make([]byte, 9)
+
+	fields := uint64(dealProposalBaseFields)
+	if t.RetrievalSLA != nil {
+		fields++
+	}
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, fields); err != nil {
+		return err
+	}
+
+	// t.PieceCID (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.PieceCID); err != nil {
+		return xerrors.Errorf("failed to write cid field t.PieceCID: %w", err)
+	}
+
+	// t.PieceSize (abi.PaddedPieceSize) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.PieceSize)); err != nil {
+		return err
+	}
+
+	// t.VerifiedDeal (bool) (bool)
+	if err := cbg.WriteBool(w, t.VerifiedDeal); err != nil {
+		return err
+	}
+
+	// t.Client (address.Address) (struct)
+	if err := t.Client.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Provider (address.Address) (struct)
+	if err := t.Provider.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Label (market.DealLabel) (struct)
+	if err := t.Label.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.StartEpoch (abi.ChainEpoch) (int64)
+	if t.StartEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.StartEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.StartEpoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.EndEpoch (abi.ChainEpoch) (int64)
+	if t.EndEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.EndEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.EndEpoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.StoragePricePerEpoch (big.Int) (struct)
+	if err := t.StoragePricePerEpoch.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.ProviderCollateral (big.Int) (struct)
+	if err := t.ProviderCollateral.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.ClientCollateral (big.Int) (struct)
+	if err := t.ClientCollateral.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.RetrievalSLA (market.RetrievalSLA) (struct), omitted if absent
+	if t.RetrievalSLA != nil {
+		if err := t.RetrievalSLA.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *DealProposal) UnmarshalCBOR(r io.Reader) error {
+	*t = DealProposal{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != dealProposalBaseFields && extra != dealProposalBaseFields+1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+	hasRetrievalSLA := extra > dealProposalBaseFields
+
+	// t.PieceCID (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.PieceCID: %w", err)
+		}
+
+		t.PieceCID = c
+
+	}
+	// t.PieceSize (abi.PaddedPieceSize) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.PieceSize = abi.PaddedPieceSize(extra)
+
+	}
+	// t.VerifiedDeal (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.VerifiedDeal = false
+	case 21:
+		t.VerifiedDeal = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	// t.Client (address.Address) (struct)
+
+	{
+
+		if err := t.Client.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Client: %w", err)
+		}
+
+	}
+	// t.Provider (address.Address) (struct)
+
+	{
+
+		if err := t.Provider.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Provider: %w", err)
+		}
+
+	}
+	// t.Label (market.DealLabel) (struct)
+
+	{
+
+		if err := t.Label.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Label: %w", err)
+		}
+
+	}
+	// t.StartEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.StartEpoch = abi.ChainEpoch(extraI)
+	}
+	// t.EndEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.EndEpoch = abi.ChainEpoch(extraI)
+	}
+	// t.StoragePricePerEpoch (big.Int) (struct)
+
+	{
+
+		if err := t.StoragePricePerEpoch.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.StoragePricePerEpoch: %w", err)
+		}
+
+	}
+	// t.ProviderCollateral (big.Int) (struct)
+
+	{
+
+		if err := t.ProviderCollateral.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.ProviderCollateral: %w", err)
+		}
+
+	}
+	// t.ClientCollateral (big.Int) (struct)
+
+	{
+
+		if err := t.ClientCollateral.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.ClientCollateral: %w", err)
+		}
+
+	}
+	// t.RetrievalSLA (market.RetrievalSLA) (struct), present only if not nil
+	if hasRetrievalSLA {
+		t.RetrievalSLA = new(RetrievalSLA)
+		if err := t.RetrievalSLA.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RetrievalSLA: %w", err)
+		}
+	}
+	return nil
+}
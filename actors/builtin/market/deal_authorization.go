@@ -0,0 +1,19 @@
+package market
+
+import (
+	cid "github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+)
+
+// Method invoked on a deal client that is not a signing party, such as a DataDAO-style actor, to
+// authorize a deal proposal being published, in place of a signature on the proposal.
+// Any actor that is not built in and implements this method, with AuthorizeDealParams, may be the client
+// of a deal. The client authorizes the deal by returning successfully, and rejects it with any other exit code.
+// Clients are asked to authorize a batch's deals before the market validates the batch against its state.
+const MethodAuthorizeDeal = builtin.MethodsExternalStart
+
+type AuthorizeDealParams struct {
+	// CID of the proposal, with the client and provider addresses resolved to ID addresses.
+	ProposalCid cid.Cid
+}
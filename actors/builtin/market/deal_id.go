@@ -0,0 +1,41 @@
+package market
+
+import (
+	"encoding/binary"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+)
+
+// Deal IDs derived from proposal CIDs have this bit set, and those allocated from the sequential
+// counter do not, so the two schemes never allocate the same ID.
+const DerivedDealIDBase = abi.DealID(1 << 62)
+
+// Mask of the bits of a derived deal ID taken from the proposal CID.
+const derivedDealIDMask = uint64(DerivedDealIDBase - 1)
+
+// Returns the deal ID derived from a proposal CID, from the leading bytes of its multihash digest.
+// A deal is published with this ID unless another deal already holds it, in which case the deal
+// takes the next free derived ID after it.
+func DerivedDealID(proposalCid cid.Cid) (abi.DealID, error) {
+	decoded, err := mh.Decode(proposalCid.Hash())
+	if err != nil {
+		return 0, xerrors.Errorf("failed to decode multihash of %s: %w", proposalCid, err)
+	}
+	if len(decoded.Digest) < 8 {
+		return 0, xerrors.Errorf("digest of %s too short to derive a deal ID", proposalCid)
+	}
+	return DerivedDealIDBase | abi.DealID(binary.BigEndian.Uint64(decoded.Digest)&derivedDealIDMask), nil
+}
+
+// Whether a deal ID was derived from a proposal CID rather than allocated from the counter.
+func IsDerivedDealID(id abi.DealID) bool {
+	return id&DerivedDealIDBase != 0
+}
+
+// Returns the derived deal ID following the given one, wrapping within the derived range.
+func nextDerivedDealID(id abi.DealID) abi.DealID {
+	return DerivedDealIDBase | ((id + 1) & abi.DealID(derivedDealIDMask))
+}
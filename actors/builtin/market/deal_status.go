@@ -0,0 +1,95 @@
+package market
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// The stage of a deal's lifecycle at an epoch, computed from its proposal and state.
+//
+// A published deal is pending until it is activated in a sector, which must happen by its start epoch.
+// An active deal is paid for until its end epoch, unless its sector is terminated first and the deal slashed.
+// Cron settles deals that have expired, been slashed or timed out, and removes them from state.
+type DealStatus uint64
+
+const (
+	// Published and awaiting activation by its start epoch.
+	DealStatusPending DealStatus = iota
+	// Activated in a sector and not yet at its end epoch.
+	DealStatusActive
+	// At or past its end epoch without being slashed, awaiting final settlement by cron.
+	// This includes a deal whose sector was terminated at or after its end.
+	DealStatusExpired
+	// Terminated before its end epoch, awaiting settlement by cron.
+	DealStatusSlashed
+	// Settled by cron and removed from state.
+	DealStatusSettled
+	// Not activated by its start epoch, awaiting clean up by cron.
+	DealStatusTimedOut
+)
+
+func (s DealStatus) String() string {
+	switch s {
+	case DealStatusPending:
+		return "pending"
+	case DealStatusActive:
+		return "active"
+	case DealStatusExpired:
+		return "expired"
+	case DealStatusSlashed:
+		return "slashed"
+	case DealStatusSettled:
+		return "settled"
+	case DealStatusTimedOut:
+		return "timed-out"
+	default:
+		return "unknown"
+	}
+}
+
+// Returns the lifecycle status of a deal at the current epoch, and whether the deal is known.
+// A deal removed from state is known to have been settled only if its ID was allocated from the counter,
+// since a removed deal with a derived ID can't be told apart from an ID never allocated.
+func (st *State) DealStatus(store adt.Store, dealID abi.DealID, currEpoch abi.ChainEpoch) (DealStatus, bool, error) {
+	proposals, err := AsDealProposalArray(store, st.Proposals)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to load deal proposals: %w", err)
+	}
+	proposal, found, err := proposals.Get(dealID)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to get deal proposal %d: %w", dealID, err)
+	}
+	if !found {
+		if !IsDerivedDealID(dealID) && dealID < st.NextID {
+			return DealStatusSettled, true, nil
+		}
+		return 0, false, nil
+	}
+
+	states, err := AsDealStateArray(store, st.States)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+	state, found, err := states.Get(dealID)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to get deal state %d: %w", dealID, err)
+	}
+	if !found {
+		if currEpoch > proposal.StartEpoch {
+			return DealStatusTimedOut, true, nil
+		}
+		return DealStatusPending, true, nil
+	}
+
+	// A deal whose sector was terminated only once the deal had ended
+	// records its end epoch as the slash epoch and is settled as expired.
+	if state.SlashEpoch != epochUndefined && state.SlashEpoch < proposal.EndEpoch {
+		return DealStatusSlashed, true, nil
+	}
+	if currEpoch >= proposal.EndEpoch {
+		return DealStatusExpired, true, nil
+	}
+	return DealStatusActive, true, nil
+}
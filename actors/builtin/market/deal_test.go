@@ -0,0 +1,198 @@
+package market_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
+	tutil "github.com/filecoin-project/specs-actors/v8/support/testing"
+)
+
+func TestDealLabel(t *testing.T) {
+	roundTrip := func(t *testing.T, label market.DealLabel) market.DealLabel {
+		buf := new(bytes.Buffer)
+		require.NoError(t, label.MarshalCBOR(buf))
+		var out market.DealLabel
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		return out
+	}
+
+	t.Run("string label round trips as text string", func(t *testing.T) {
+		label, err := market.NewLabelFromString("order-1")
+		require.NoError(t, err)
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, label.MarshalCBOR(buf))
+		assert.Equal(t, byte(cbg.MajTextString<<5), buf.Bytes()[0]&0xe0)
+
+		out := roundTrip(t, label)
+		assert.True(t, out.IsString())
+		assert.True(t, label.Equals(out))
+		s, err := out.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "order-1", s)
+		_, err = out.ToBytes()
+		assert.Error(t, err)
+	})
+
+	t.Run("bytes label round trips as byte string", func(t *testing.T) {
+		raw := []byte{0xff, 0xfe, 0x00, 0x01}
+		label := market.NewLabelFromBytes(raw)
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, label.MarshalCBOR(buf))
+		assert.Equal(t, byte(cbg.MajByteString<<5), buf.Bytes()[0]&0xe0)
+
+		out := roundTrip(t, label)
+		assert.True(t, out.IsBytes())
+		assert.True(t, label.Equals(out))
+		b, err := out.ToBytes()
+		require.NoError(t, err)
+		assert.Equal(t, raw, b)
+		_, err = out.ToString()
+		assert.Error(t, err)
+	})
+
+	t.Run("empty label is an empty string", func(t *testing.T) {
+		out := roundTrip(t, market.EmptyDealLabel)
+		assert.True(t, out.IsString())
+		assert.Equal(t, 0, out.Length())
+		assert.True(t, market.EmptyDealLabel.Equals(out))
+	})
+
+	t.Run("rejects invalid utf8 string", func(t *testing.T) {
+		_, err := market.NewLabelFromString(string([]byte{0xff, 0xfe}))
+		assert.Error(t, err)
+
+		// A text string of invalid UTF-8 doesn't unmarshal.
+		buf := new(bytes.Buffer)
+		require.NoError(t, cbg.WriteMajorTypeHeader(buf, cbg.MajTextString, 2))
+		buf.Write([]byte{0xff, 0xfe})
+		var out market.DealLabel
+		assert.Error(t, out.UnmarshalCBOR(buf))
+	})
+
+	t.Run("rejects other major types", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, cbg.WriteMajorTypeHeader(buf, cbg.MajUnsignedInt, 2))
+		var out market.DealLabel
+		assert.Error(t, out.UnmarshalCBOR(buf))
+	})
+
+	t.Run("labels at the maximum length round trip", func(t *testing.T) {
+		stringLabel, err := market.NewLabelFromString(strings.Repeat("a", cbg.ByteArrayMaxLen))
+		require.NoError(t, err)
+		out := roundTrip(t, stringLabel)
+		assert.True(t, stringLabel.Equals(out))
+
+		bytesLabel := market.NewLabelFromBytes(bytes.Repeat([]byte{0xff}, cbg.ByteArrayMaxLen))
+		out = roundTrip(t, bytesLabel)
+		assert.True(t, bytesLabel.Equals(out))
+	})
+
+	t.Run("rejects labels over the maximum length", func(t *testing.T) {
+		stringLabel, err := market.NewLabelFromString(strings.Repeat("a", cbg.ByteArrayMaxLen+1))
+		require.NoError(t, err)
+		assert.Error(t, stringLabel.MarshalCBOR(new(bytes.Buffer)))
+		bytesLabel := market.NewLabelFromBytes(bytes.Repeat([]byte{0xff}, cbg.ByteArrayMaxLen+1))
+		assert.Error(t, bytesLabel.MarshalCBOR(new(bytes.Buffer)))
+
+		for _, maj := range []byte{cbg.MajTextString, cbg.MajByteString} {
+			buf := new(bytes.Buffer)
+			require.NoError(t, cbg.WriteMajorTypeHeader(buf, maj, cbg.ByteArrayMaxLen+1))
+			var out market.DealLabel
+			assert.Error(t, out.UnmarshalCBOR(buf))
+		}
+	})
+
+	t.Run("string and bytes labels with same content differ", func(t *testing.T) {
+		stringLabel, err := market.NewLabelFromString("label")
+		require.NoError(t, err)
+		bytesLabel := market.NewLabelFromBytes([]byte("label"))
+		assert.False(t, stringLabel.Equals(bytesLabel))
+		assert.Equal(t, stringLabel.Length(), bytesLabel.Length())
+
+		proposal := market.DealProposal{
+			PieceCID:             tutil.MakeCID("1", &market.PieceCIDPrefix),
+			PieceSize:            2048,
+			Client:               tutil.NewIDAddr(t, 101),
+			Provider:             tutil.NewIDAddr(t, 102),
+			Label:                stringLabel,
+			StartEpoch:           10,
+			EndEpoch:             20,
+			StoragePricePerEpoch: big.Zero(),
+			ProviderCollateral:   big.Zero(),
+			ClientCollateral:     big.Zero(),
+		}
+		stringCid, err := proposal.Cid()
+		require.NoError(t, err)
+		proposal.Label = bytesLabel
+		bytesCid, err := proposal.Cid()
+		require.NoError(t, err)
+		assert.NotEqual(t, stringCid, bytesCid)
+	})
+}
+
+func TestDealProposalRetrievalSLA(t *testing.T) {
+	proposal := market.DealProposal{
+		PieceCID:             tutil.MakeCID("1", &market.PieceCIDPrefix),
+		PieceSize:            2048,
+		Client:               tutil.NewIDAddr(t, 101),
+		Provider:             tutil.NewIDAddr(t, 102),
+		Label:                market.EmptyDealLabel,
+		StartEpoch:           10,
+		EndEpoch:             20,
+		StoragePricePerEpoch: big.Zero(),
+		ProviderCollateral:   big.NewInt(10),
+		ClientCollateral:     big.Zero(),
+	}
+	roundTrip := func(t *testing.T, proposal market.DealProposal) (market.DealProposal, uint64) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, proposal.MarshalCBOR(buf))
+		_, fields, err := cbg.CborReadHeader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		var out market.DealProposal
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		return out, fields
+	}
+
+	t.Run("proposal without terms omits them", func(t *testing.T) {
+		out, fields := roundTrip(t, proposal)
+		assert.EqualValues(t, 11, fields)
+		assert.Nil(t, out.RetrievalSLA)
+		assert.Zero(t, out.RetrievalAvailability())
+		assert.Equal(t, big.Zero(), out.RetrievalViolationPenalty())
+	})
+
+	t.Run("proposal with terms round trips", func(t *testing.T) {
+		withSLA := proposal
+		withSLA.RetrievalSLA = &market.RetrievalSLA{Availability: 9_900, ViolationPenalty: big.NewInt(4)}
+		out, fields := roundTrip(t, withSLA)
+		assert.EqualValues(t, 12, fields)
+		assert.Equal(t, withSLA.RetrievalSLA, out.RetrievalSLA)
+		assert.EqualValues(t, 9_900, out.RetrievalAvailability())
+		assert.Equal(t, big.NewInt(4), out.RetrievalViolationPenalty())
+
+		plainCid, err := proposal.Cid()
+		require.NoError(t, err)
+		slaCid, err := withSLA.Cid()
+		require.NoError(t, err)
+		assert.NotEqual(t, plainCid, slaCid)
+	})
+
+	t.Run("rejects null terms", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, proposal.MarshalCBOR(buf))
+		encoded := buf.Bytes()
+		encoded[0]++ // One more field in the array header.
+		encoded = append(encoded, cbg.CborNull...)
+		var out market.DealProposal
+		assert.Error(t, out.UnmarshalCBOR(bytes.NewReader(encoded)))
+	})
+}
@@ -0,0 +1,57 @@
+package market
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+)
+
+// Method invoked on a client's escrow funder to request funds for the client's escrow.
+// Any actor that is not built in and implements this method, with RequestEscrowFundsParams, may act as a funder.
+// The funder is expected to respond by calling FundClientEscrow with the funds.
+// Funds are requested for a batch of deals being published before the market validates the batch against its state.
+const MethodRequestEscrowFunds = builtin.MethodsExternalStart + 1
+
+// An actor authorized by a client to top up the client's escrow when it is insufficient to
+// cover a deal being published. The funder gains no authority to publish deals.
+type EscrowFunder struct {
+	// ID address of the funding actor.
+	Funder addr.Address
+	// Maximum total amount that may be requested from the funder in any one epoch.
+	MaxPerEpoch abi.TokenAmount
+	// The epoch in which funds were last requested, and the total requested in that epoch.
+	LastRequestEpoch abi.ChainEpoch
+	RequestedInEpoch abi.TokenAmount
+}
+
+// Returns the amount that may still be requested from the funder in the current epoch.
+func (f *EscrowFunder) Allowance(currEpoch abi.ChainEpoch) abi.TokenAmount {
+	if f.LastRequestEpoch != currEpoch {
+		return f.MaxPerEpoch
+	}
+	return big.Max(big.Sub(f.MaxPerEpoch, f.RequestedInEpoch), big.Zero())
+}
+
+// Records an amount requested from the funder in the current epoch.
+func (f *EscrowFunder) recordRequest(currEpoch abi.ChainEpoch, amount abi.TokenAmount) {
+	if f.LastRequestEpoch != currEpoch {
+		f.LastRequestEpoch = currEpoch
+		f.RequestedInEpoch = big.Zero()
+	}
+	f.RequestedInEpoch = big.Add(f.RequestedInEpoch, amount)
+}
+
+// Restores to the allowance an amount recorded as requested in the current epoch but not funded.
+func (f *EscrowFunder) restoreRequest(currEpoch abi.ChainEpoch, amount abi.TokenAmount) {
+	if f.LastRequestEpoch != currEpoch {
+		return
+	}
+	f.RequestedInEpoch = big.Max(big.Sub(f.RequestedInEpoch, amount), big.Zero())
+}
+
+type RequestEscrowFundsParams struct {
+	Client addr.Address
+	Amount abi.TokenAmount
+}
@@ -1,6 +1,8 @@
 package market
 
 import (
+	"bytes"
+	"encoding/binary"
 	"sort"
 
 	addr "github.com/filecoin-project/go-address"
@@ -8,6 +10,7 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/cbor"
+	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	rtt "github.com/filecoin-project/go-state-types/rt"
 	market0 "github.com/filecoin-project/specs-actors/actors/builtin/market"
@@ -41,6 +44,34 @@ func (a Actor) Exports() []interface{} {
 		7:                         a.OnMinerSectorsTerminate,
 		8:                         a.ComputeDataCommitment,
 		9:                         a.CronTick,
+		10:                        a.PostProviderAsk,
+		11:                        a.WithdrawProviderAsk,
+		12:                        a.RevokeDealProposal,
+		13:                        a.IndexDealLabels,
+		14:                        a.LookupDealsByLabel,
+		15:                        a.VerifyPieceInclusion,
+		16:                        a.AuthorizeEscrowFunder,
+		17:                        a.FundClientEscrow,
+		18:                        a.RepairLockedTotals,
+		19:                        a.PublishStorageDealsAggregated,
+		20:                        a.SettleDealPayments,
+		21:                        a.SampleDealsForAudit,
+		22:                        a.TerminateBreachedDeal,
+		23:                        a.GetBalance,
+		24:                        a.GetDealStatus,
+		25:                        a.PublishStorageDealsFromClient,
+		26:                        a.AcceptDealProposals,
+		27:                        a.AmendDealProposal,
+		28:                        a.GetDealActivation,
+		29:                        a.TransferDealClient,
+		30:                        a.AddProviderCollateral,
+		31:                        a.ReportRetrievalViolation,
+		32:                        a.BatchActivateDeals,
+		33:                        a.GetDealUpdateEpoch,
+		34:                        a.WithdrawDealProposals,
+		35:                        a.OnMinerDealsReplaced,
+		36:                        a.DisputeRetrievalViolation,
+		37:                        a.ContestDealSlash,
 	}
 }
 
@@ -140,10 +171,134 @@ func (a Actor) AddBalance(rt Runtime, providerOrClientAddress *addr.Address) *ab
 	return nil
 }
 
-// type PublishStorageDealsParams struct {
-// 	Deals []ClientDealProposal
-// }
-type PublishStorageDealsParams = market0.PublishStorageDealsParams
+type GetBalanceReturn struct {
+	Balance abi.TokenAmount // Total escrow balance, including locked funds.
+	Locked  abi.TokenAmount // Funds locked for deal payments and collateral.
+}
+
+// Returns the escrow and locked balances of a client or provider.
+// An address with no escrow entry has zero balances.
+func (a Actor) GetBalance(rt Runtime, providerOrClientAddress *addr.Address) *GetBalanceReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	nominal, ok := rt.ResolveAddress(*providerOrClientAddress)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve address %v", *providerOrClientAddress)
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withEscrowTable(ReadOnlyPermission).
+		withLockedTable(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	balance, err := msm.escrowTable.Get(nominal)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get escrow balance")
+	locked, err := msm.lockedTable.Get(nominal)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get locked balance")
+
+	return &GetBalanceReturn{Balance: balance, Locked: locked}
+}
+
+type GetDealStatusParams struct {
+	DealID abi.DealID
+}
+
+type GetDealStatusReturn struct {
+	Status DealStatus
+}
+
+// Returns the lifecycle status of a deal at the current epoch.
+func (a Actor) GetDealStatus(rt Runtime, params *GetDealStatusParams) *GetDealStatusReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	status, found, err := st.DealStatus(adt.AsStore(rt), params.DealID, rt.CurrEpoch())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get status of deal %d", params.DealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", params.DealID)
+	}
+	return &GetDealStatusReturn{Status: status}
+}
+
+type GetDealActivationParams struct {
+	DealID abi.DealID
+}
+
+type GetDealActivationReturn struct {
+	// The provider, which activated the deal in one of its sectors.
+	Provider addr.Address
+	// The epoch at which the deal was activated, or -1 if it is not yet activated.
+	ActivationEpoch abi.ChainEpoch
+	// The sector in which the deal was activated, or DealSectorNumberUnknown if it is not yet activated
+	// or was activated before the market recorded deals' sectors.
+	SectorNumber abi.SectorNumber
+}
+
+// Returns where and when a deal was activated, so that a client can find the sector holding its deal.
+// A deal is found until it is settled and removed from state.
+func (a Actor) GetDealActivation(rt Runtime, params *GetDealActivationParams) *GetDealActivationReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(ReadOnlyPermission).
+		withDealStates(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	proposal, found, err := msm.dealProposals.Get(params.DealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", params.DealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", params.DealID)
+	}
+	state, _, err := msm.dealStates.Get(params.DealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", params.DealID)
+
+	return &GetDealActivationReturn{
+		Provider:        proposal.Provider,
+		ActivationEpoch: state.SectorStartEpoch,
+		SectorNumber:    state.SectorNumber,
+	}
+}
+
+type GetDealUpdateEpochParams struct {
+	DealID abi.DealID
+}
+
+type GetDealUpdateEpochReturn struct {
+	// The epoch at which the deal is queued for its next update, or -1 if it is not queued.
+	Epoch abi.ChainEpoch
+}
+
+// Returns the epoch at which cron next processes a deal, timing out the deal if it is not yet activated
+// or otherwise settling its payments since its last update, or its expiry or slashing.
+// If cron falls behind, the deal may be processed at a later epoch.
+func (a Actor) GetDealUpdateEpoch(rt Runtime, params *GetDealUpdateEpochParams) *GetDealUpdateEpochReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(ReadOnlyPermission).
+		withDealStates(ReadOnlyPermission).withDealsByEpoch(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	proposal, found, err := msm.dealProposals.Get(params.DealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", params.DealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", params.DealID)
+	}
+	state, _, err := msm.dealStates.Get(params.DealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", params.DealID)
+
+	epoch, err := msm.dealUpdateEpoch(params.DealID, proposal, state)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to find update epoch of deal %d", params.DealID)
+	return &GetDealUpdateEpochReturn{Epoch: epoch}
+}
+
+type PublishStorageDealsParams struct {
+	Deals []ClientDealProposal
+}
 
 //type PublishStorageDealsReturn struct {
 //	IDs        []abi.DealID
@@ -152,16 +307,249 @@ type PublishStorageDealsParams = market0.PublishStorageDealsParams
 type PublishStorageDealsReturn = market6.PublishStorageDealsReturn
 
 // Publish a new set of storage deals (not yet included in a sector).
+// A deal with a client that is a signing party must carry the client's signature of the proposal.
+// A deal with any other client actor is instead authorized by the client with MethodAuthorizeDeal.
 func (a Actor) PublishStorageDeals(rt Runtime, params *PublishStorageDealsParams) *PublishStorageDealsReturn {
 	// Deal message must have a From field identical to the provider of all the deals.
 	// This allows us to retain and verify only the client's signature in each deal proposal itself.
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	return publishStorageDeals(rt, params.Deals, false)
+}
+
+type PublishStorageDealsAggregatedParams struct {
+	Deals []DealProposal
+	// Aggregate of each client's BLS signature over the CID of its deal proposal.
+	AggregateSignature crypto.Signature
+}
+
+// Publishes a set of storage deals whose clients all sign with BLS keys, with the clients'
+// signatures supplied as a single aggregate, verified once for the whole batch.
+// An invalid aggregate rejects the batch. Otherwise, deals are dropped or published as for PublishStorageDeals.
+func (a Actor) PublishStorageDealsAggregated(rt Runtime, params *PublishStorageDealsAggregatedParams) *PublishStorageDealsReturn {
 	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
 	if len(params.Deals) == 0 {
 		rt.Abortf(exitcode.ErrIllegalArgument, "empty deals parameter")
 	}
+	if params.AggregateSignature.Type != crypto.SigTypeBLS {
+		rt.Abortf(exitcode.ErrIllegalArgument, "aggregate signature must be BLS, was type %d", params.AggregateSignature.Type)
+	}
+
+	deals := make([]ClientDealProposal, len(params.Deals))
+	signers := make([]addr.Address, len(params.Deals))
+	plaintexts := make([][]byte, len(params.Deals))
+	for di, proposal := range params.Deals {
+		if isDealClientActor(rt, proposal.Client) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "client %v of deal %d is not a signing party", proposal.Client, di)
+		}
+		pcid, err := proposal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to take cid of proposal %d", di)
+		deals[di] = ClientDealProposal{Proposal: proposal}
+		signers[di] = proposal.Client
+		plaintexts[di] = pcid.Bytes()
+	}
+	err := rt.VerifyAggregateSignature(params.AggregateSignature, signers, plaintexts)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid aggregate signature")
+
+	return publishStorageDeals(rt, deals, true)
+}
+
+type PublishStorageDealsFromClientParams struct {
+	Proposals []DealProposal
+}
+
+type PublishStorageDealsFromClientReturn struct {
+	// CIDs of the proposals as recorded, with client and provider addresses resolved to ID addresses.
+	ProposalCids []cid.Cid
+}
+
+// Records deal proposals on chain for their providers to accept with AcceptDealProposals.
+// The client's message authorizes its proposals in place of a signature over each, so the caller
+// is taken to be the client of every proposal. No funds are locked until a proposal is accepted.
+func (a Actor) PublishStorageDealsFromClient(rt Runtime, params *PublishStorageDealsFromClientParams) *PublishStorageDealsFromClientReturn {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	client := rt.Caller()
+	if len(params.Proposals) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no proposals to publish")
+	}
+
+	proposals := make([]DealProposal, len(params.Proposals))
+	proposalCids := make([]cid.Cid, len(params.Proposals))
+	for i, proposal := range params.Proposals {
+		resolvedClient, ok := rt.ResolveAddress(proposal.Client)
+		if !ok || resolvedClient != client {
+			rt.Abortf(exitcode.ErrForbidden, "caller %v is not the client %v of proposal %d", client, proposal.Client, i)
+		}
+		provider, ok := rt.ResolveAddress(proposal.Provider)
+		if !ok {
+			rt.Abortf(exitcode.ErrNotFound, "failed to resolve provider address %v of proposal %d", proposal.Provider, i)
+		}
+		if rt.CurrEpoch() > proposal.StartEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "proposal %d start epoch %d has already elapsed", i, proposal.StartEpoch)
+		}
+
+		proposal.Client = client
+		proposal.Provider = provider
+		pcid, err := proposal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to take cid of proposal %d", i)
+		proposals[i] = proposal
+		proposalCids[i] = pcid
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withPendingProposals(ReadOnlyPermission).
+			withClientProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for i, pcid := range proposalCids {
+			published, err := msm.pendingDeals.Has(abi.CidKey(pcid))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check for published deal proposal")
+			if published {
+				rt.Abortf(exitcode.ErrIllegalArgument, "proposal %s has already been published", pcid)
+			}
+			added, err := msm.clientProposals.PutIfAbsent(abi.CidKey(pcid), &proposals[i])
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record proposal %s", pcid)
+			if !added {
+				rt.Abortf(exitcode.ErrIllegalArgument, "proposal %s is already awaiting acceptance", pcid)
+			}
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return &PublishStorageDealsFromClientReturn{ProposalCids: proposalCids}
+}
+
+type AcceptDealProposalsParams struct {
+	Proposals []DealProposalRef
+}
+
+type DealProposalRef struct {
+	// CID of a proposal as returned by PublishStorageDealsFromClient.
+	ProposalCid cid.Cid `checked:"true"` // Prefix checked in AcceptDealProposals
+}
+
+// Publishes deals from proposals that their clients recorded with PublishStorageDealsFromClient.
+// The deals are validated and published as for PublishStorageDeals, with the clients' funds locked now,
+// but carry no client signatures. Proposals accepted are no longer awaiting acceptance; the others remain,
+// except those whose start epoch has elapsed, which can never be accepted and are removed.
+func (a Actor) AcceptDealProposals(rt Runtime, params *AcceptDealProposalsParams) *PublishStorageDealsReturn {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	if len(params.Proposals) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no proposals to accept")
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withClientProposals(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	deals := make([]ClientDealProposal, 0, len(params.Proposals))
+	// Index in params of each of deals.
+	dealIdxs := make([]int, 0, len(params.Proposals))
+	var elapsed []cid.Cid
+	for i, ref := range params.Proposals {
+		pcid := ref.ProposalCid
+		if pcid.Prefix() != DealProposalCIDPrefix {
+			rt.Abortf(exitcode.ErrIllegalArgument, "proposal CID %s has wrong prefix", pcid)
+		}
+		var proposal DealProposal
+		found, err := msm.clientProposals.Get(abi.CidKey(pcid), &proposal)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get client proposal %s", pcid)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no client proposal %s awaiting acceptance", pcid)
+		}
+		if rt.CurrEpoch() > proposal.StartEpoch {
+			rt.Log(rtt.INFO, "proposal %s start epoch %d has elapsed", pcid, proposal.StartEpoch)
+			elapsed = append(elapsed, pcid)
+			continue
+		}
+		deals = append(deals, ClientDealProposal{Proposal: proposal})
+		dealIdxs = append(dealIdxs, i)
+	}
+
+	ret := &PublishStorageDealsReturn{IDs: []abi.DealID{}, ValidDeals: bitfield.New()}
+	if len(deals) > 0 {
+		published := publishStorageDeals(rt, deals, true)
+		ret.IDs = published.IDs
+		err = published.ValidDeals.ForEach(func(i uint64) error {
+			ret.ValidDeals.Set(uint64(dealIdxs[i]))
+			return nil
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to map accepted deals to proposals")
+	}
+
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withClientProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		err = ret.ValidDeals.ForEach(func(i uint64) error {
+			_, err := msm.clientProposals.TryDelete(abi.CidKey(params.Proposals[i].ProposalCid))
+			return err
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove accepted client proposals")
+		for _, pcid := range elapsed {
+			_, err := msm.clientProposals.TryDelete(abi.CidKey(pcid))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove elapsed client proposal %s", pcid)
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return ret
+}
+
+type WithdrawDealProposalsParams struct {
+	Proposals []DealProposalRef
+}
+
+// Withdraws proposals that the caller recorded with PublishStorageDealsFromClient and that have not been accepted.
+// Only a proposal's client may withdraw it, so the caller is taken to be the client.
+func (a Actor) WithdrawDealProposals(rt Runtime, params *WithdrawDealProposalsParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	client := rt.Caller()
+	if len(params.Proposals) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no proposals to withdraw")
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withClientProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for _, ref := range params.Proposals {
+			pcid := ref.ProposalCid
+			if pcid.Prefix() != DealProposalCIDPrefix {
+				rt.Abortf(exitcode.ErrIllegalArgument, "proposal CID %s has wrong prefix", pcid)
+			}
+			var proposal DealProposal
+			found, err := msm.clientProposals.Get(abi.CidKey(pcid), &proposal)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get client proposal %s", pcid)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "no client proposal %s awaiting acceptance", pcid)
+			}
+			if proposal.Client != client {
+				rt.Abortf(exitcode.ErrForbidden, "caller %v is not the client of proposal %s", client, pcid)
+			}
+			err = msm.clientProposals.Delete(abi.CidKey(pcid))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove client proposal %s", pcid)
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+// Publishes the valid deals among those given, dropping the others.
+// Clients' signatures are checked individually unless the clients have otherwise authorized the whole batch.
+func publishStorageDeals(rt Runtime, deals []ClientDealProposal, signaturesVerified bool) *PublishStorageDealsReturn {
+	if len(deals) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "empty deals parameter")
+	}
 
 	// All deals should have the same provider so get worker once
-	providerRaw := params.Deals[0].Proposal.Provider
+	providerRaw := deals[0].Proposal.Provider
 	provider, ok := rt.ResolveAddress(providerRaw)
 	if !ok {
 		rt.Abortf(exitcode.ErrNotFound, "failed to resolve provider address %v", providerRaw)
@@ -182,31 +570,34 @@ func (a Actor) PublishStorageDeals(rt Runtime, params *PublishStorageDealsParams
 		}
 		callerOk = caller == controller
 	}
+	// Without the provider's authority, deals may be published only by their client
+	// and only if they match the provider's standing ask.
+	var standingAsk *ProviderAsk
 	if !callerOk {
-		rt.Abortf(exitcode.ErrForbidden, "caller %v is not worker or control address of provider %v", caller, provider)
+		var askSt State
+		rt.StateReadonly(&askSt)
+		msm, err := askSt.mutator(adt.AsStore(rt)).withProviderAsks(ReadOnlyPermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+		ask, found, err := msm.getActiveProviderAsk(provider, rt.CurrEpoch())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load provider ask")
+		if !found {
+			rt.Abortf(exitcode.ErrForbidden, "caller %v is not worker or control address of provider %v", caller, provider)
+		}
+		standingAsk = ask
 	}
-	resolvedAddrs := make(map[addr.Address]addr.Address, len(params.Deals))
+	resolvedAddrs := make(map[addr.Address]addr.Address, len(deals))
 	baselinePower := requestCurrentBaselinePower(rt)
 	networkRawPower, networkQAPower := requestCurrentNetworkPower(rt)
 
-	// Drop invalid deals
-	var st State
-	proposalCidLookup := make(map[cid.Cid]struct{})
-	validProposalCids := make([]cid.Cid, 0)
-	validDeals := make([]ClientDealProposal, 0, len(params.Deals))
-	totalClientLockup := make(map[addr.Address]abi.TokenAmount)
-	totalProviderLockup := abi.NewTokenAmount(0)
-
-	validInputBf := bitfield.New()
-	rt.StateReadonly(&st)
-	msm, err := st.mutator(adt.AsStore(rt)).withPendingProposals(ReadOnlyPermission).
-		withEscrowTable(ReadOnlyPermission).withLockedTable(ReadOnlyPermission).build()
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
-	for di, deal := range params.Deals {
+	// Deals are first checked independently of market state, and client actors are asked to authorize their deals.
+	clients := make([]addr.Address, len(deals))
+	clientIsActor := make([]bool, len(deals))
+	for di, deal := range deals {
 		/*
 			drop malformed deals
 		*/
-		if err := validateDeal(rt, deal, networkRawPower, networkQAPower, baselinePower); err != nil {
+		clientIsActor[di] = isDealClientActor(rt, deal.Proposal.Client)
+		if err := validateDeal(rt, deal, !clientIsActor[di] && !signaturesVerified, networkRawPower, networkQAPower, baselinePower); err != nil {
 			rt.Log(rtt.INFO, "invalid deal %d: %s", di, err)
 			continue
 		}
@@ -219,75 +610,231 @@ func (a Actor) PublishStorageDeals(rt Runtime, params *PublishStorageDealsParams
 			rt.Log(rtt.INFO, "invalid deal %d: failed to resolve proposal.Client address %v for deal ", di, deal.Proposal.Client)
 			continue
 		}
+		if standingAsk != nil {
+			if client != caller {
+				rt.Log(rtt.INFO, "invalid deal %d: caller %v is neither the provider nor the client %v", di, caller, client)
+				continue
+			}
+			if err := standingAsk.Matches(&deal.Proposal, rt.CurrEpoch()); err != nil {
+				rt.Log(rtt.INFO, "invalid deal %d: does not match provider ask: %s", di, err)
+				continue
+			}
+		}
 
 		/*
-			drop deals with insufficient lock up to cover costs
+			drop deals not authorized by a client actor
+		*/
+		if clientIsActor[di] {
+			normalized := deal.Proposal
+			normalized.Provider = provider
+			normalized.Client = client
+			pcid, err := normalized.Cid()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to take cid of proposal %d", di)
+			if !requestDealAuthorization(rt, client, pcid) {
+				rt.Log(rtt.INFO, "invalid deal %d: proposal %s not authorized by client %v", di, pcid, client)
+				continue
+			}
+		}
+		clients[di] = client
+	}
+
+	// Deals are then checked against market state, so that escrow funders are asked to cover only the deals
+	// that may be published.
+	var st State
+	pcids := make([]cid.Cid, len(deals))
+	republishedIDs := make(map[int]abi.DealID)
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withPendingProposals(ReadOnlyPermission).
+		withRevokedProposals(ReadOnlyPermission).withSupersededProposals(ReadOnlyPermission).
+		withDealProposals(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+	proposalCidLookup := make(map[cid.Cid]struct{})
+	// Provider collateral of the deals in this batch published against the provider's standing ask.
+	askCollateral := abi.NewTokenAmount(0)
+	for di, deal := range deals {
+		client := clients[di]
+		if client == addr.Undef {
+			continue
+		}
+		if standingAsk != nil {
+			if err := standingAsk.CheckCollateralAvailable(big.Add(askCollateral, deal.Proposal.ProviderCollateral)); err != nil {
+				rt.Log(rtt.INFO, "invalid deal %d: %s", di, err)
+				continue
+			}
+		}
+
+		// Normalise provider and client addresses in the proposal stored on chain.
+		// Must happen after signature verification and before taking cid.
+		normalized := deal.Proposal
+		normalized.Provider = provider
+		normalized.Client = client
+		pcid, err := normalized.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to take cid of proposal %d", di)
+
+		/*
+			accept republished deals
+		*/
+		// With IDs derived from proposal CIDs, republishing a pending proposal is idempotent:
+		// the deal keeps the ID it was published with and locks no further funds.
+		if DealIDsFromProposalCID {
+			pending, err := msm.pendingDeals.Has(abi.CidKey(pcid))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check for existence of deal proposal")
+			if pending {
+				id, found, err := msm.findDerivedDealID(pcid)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to find deal for proposal %s", pcid)
+				if found {
+					rt.Log(rtt.INFO, "deal %d: proposal %s already published as deal %d", di, pcid, id)
+					republishedIDs[di] = id
+					continue
+				}
+			}
+		}
+
+		/*
+			drop duplicate, superseded and revoked deals
 		*/
-		if _, ok := totalClientLockup[client]; !ok {
-			totalClientLockup[client] = abi.NewTokenAmount(0)
-		}
-		totalClientLockup[client] = big.Sum(totalClientLockup[client], deal.Proposal.ClientBalanceRequirement())
-		clientBalanceOk, err := msm.balanceCovered(client, totalClientLockup[client])
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check client balance coverage")
-		if !clientBalanceOk {
-			rt.Log(rtt.INFO, "invalid deal: %d: insufficient client funds to cover proposal cost", di)
+		// check proposalCids for duplication within message batch
+		if _, duplicateInMessage := proposalCidLookup[pcid]; duplicateInMessage {
+			rt.Log(rtt.INFO, "invalid deal %d: cannot publish duplicate deal proposal %s", di, pcid)
+			continue
+		}
+		publishable, reason, err := msm.checkProposalPublishable(client, pcid, rt.CurrEpoch())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check deal proposal %s", pcid)
+		if !publishable {
+			rt.Log(rtt.INFO, "invalid deal %d: %s", di, reason)
+			continue
+		}
+
+		if standingAsk != nil {
+			askCollateral = big.Add(askCollateral, deal.Proposal.ProviderCollateral)
+		}
+		proposalCidLookup[pcid] = struct{}{}
+		pcids[di] = pcid
+	}
+
+	// Clients are funded in the order they first appear in the batch.
+	var fundedClients []addr.Address
+	clientRequirements := make(map[addr.Address]abi.TokenAmount)
+	for di, deal := range deals {
+		client := clients[di]
+		if !pcids[di].Defined() || IsDataOnboardingDeal(&deal.Proposal) {
 			continue
 		}
-		totalProviderLockup = big.Sum(totalProviderLockup, deal.Proposal.ProviderCollateral)
-		providerBalanceOk, err := msm.balanceCovered(provider, totalProviderLockup)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check provider balance coverage")
-		if !providerBalanceOk {
-			rt.Log(rtt.INFO, "invalid deal: %d: insufficient provider funds to cover proposal cost", di)
+		if _, ok := clientRequirements[client]; !ok {
+			fundedClients = append(fundedClients, client)
+			clientRequirements[client] = big.Zero()
+		}
+		clientRequirements[client] = big.Add(clientRequirements[client], deal.Proposal.ClientBalanceRequirement())
+	}
+	for _, client := range fundedClients {
+		requestEscrowFunds(rt, client, clientRequirements[client])
+	}
+
+	// Drop invalid deals
+	validProposalCids := make([]cid.Cid, 0)
+	validDeals := make([]ClientDealProposal, 0, len(deals))
+	validInputIdxs := make([]int, 0, len(deals))
+	// Allocations made for verified deals, keyed by the deal's index in validDeals.
+	allocationIDs := make(map[int]verifreg.AllocationID)
+	totalClientLockup := make(map[addr.Address]abi.TokenAmount)
+	totalProviderLockup := abi.NewTokenAmount(0)
+	askCollateral = abi.NewTokenAmount(0)
+
+	validInputBf := bitfield.New()
+	for di := range republishedIDs {
+		validInputBf.Set(uint64(di))
+	}
+	// State is loaded again, since the requests for escrow funds called out of the market.
+	rt.StateReadonly(&st)
+	msm, err = st.mutator(adt.AsStore(rt)).withPendingProposals(ReadOnlyPermission).
+		withEscrowTable(ReadOnlyPermission).withLockedTable(ReadOnlyPermission).
+		withRevokedProposals(ReadOnlyPermission).withSupersededProposals(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+	for di, deal := range deals {
+		client := clients[di]
+		pcid := pcids[di]
+		if !pcid.Defined() {
 			continue
 		}
+		if standingAsk != nil {
+			if err := standingAsk.CheckCollateralAvailable(big.Add(askCollateral, deal.Proposal.ProviderCollateral)); err != nil {
+				rt.Log(rtt.INFO, "invalid deal %d: %s", di, err)
+				continue
+			}
+		}
 
 		/*
-			drop duplicate deals
+			drop deals with insufficient lock up to cover costs
 		*/
-		// Normalise provider and client addresses in the proposal stored on chain.
-		// Must happen after signature verification and before taking cid.
+		// A data onboarding deal locks no funds, so is not subject to balance checks.
+		if !IsDataOnboardingDeal(&deal.Proposal) {
+			if _, ok := totalClientLockup[client]; !ok {
+				totalClientLockup[client] = abi.NewTokenAmount(0)
+			}
+			totalClientLockup[client] = big.Sum(totalClientLockup[client], deal.Proposal.ClientBalanceRequirement())
+			clientBalanceOk, err := msm.balanceCovered(client, totalClientLockup[client])
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check client balance coverage")
+			if !clientBalanceOk {
+				rt.Log(rtt.INFO, "invalid deal: %d: insufficient client funds to cover proposal cost", di)
+				continue
+			}
+			totalProviderLockup = big.Sum(totalProviderLockup, deal.Proposal.ProviderCollateral)
+			providerBalanceOk, err := msm.balanceCovered(provider, totalProviderLockup)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check provider balance coverage")
+			if !providerBalanceOk {
+				rt.Log(rtt.INFO, "invalid deal: %d: insufficient provider funds to cover proposal cost", di)
+				continue
+			}
+		}
+
 		deal.Proposal.Provider = provider
 		resolvedAddrs[deal.Proposal.Client] = client
 		deal.Proposal.Client = client
 
-		pcid, err := deal.Proposal.Cid()
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to take cid of proposal %d", di)
-
-		// check proposalCids for duplication within message batch
-		// check state PendingProposals for duplication across messages
-		duplicateInState, err := msm.pendingDeals.Has(abi.CidKey(pcid))
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check for existence of deal proposal")
-		_, duplicateInMessage := proposalCidLookup[pcid]
-		if duplicateInState || duplicateInMessage {
-			rt.Log(rtt.INFO, "invalid deal %d: cannot publish duplicate deal proposal %s", di)
+		// The proposal is checked against state again, which the escrow funders may have changed.
+		publishable, reason, err := msm.checkProposalPublishable(client, pcid, rt.CurrEpoch())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check deal proposal %s", pcid)
+		if !publishable {
+			rt.Log(rtt.INFO, "invalid deal %d: %s", di, reason)
 			continue
 		}
 
 		/*
-			check VerifiedClient allowed cap and deduct PieceSize from cap
+			allocate PieceSize of the VerifiedClient's DataCap to the deal, to be claimed when it is activated
 			drop deals with a DealSize that cannot be fully covered by VerifiedClient's available DataCap
 		*/
 		if deal.Proposal.VerifiedDeal {
+			// The return is decoded only if the allocation succeeds.
+			var out builtin.CBORBytes
 			code := rt.Send(
 				builtin.VerifiedRegistryActorAddr,
-				builtin.MethodsVerifiedRegistry.UseBytes,
-				&verifreg.UseBytesParams{
-					Address:  client,
-					DealSize: big.NewIntUnsigned(uint64(deal.Proposal.PieceSize)),
+				builtin.MethodsVerifiedRegistry.CreateAllocation,
+				&verifreg.CreateAllocationParams{
+					Client:     client,
+					Provider:   provider,
+					Size:       big.NewIntUnsigned(uint64(deal.Proposal.PieceSize)),
+					Expiration: deal.Proposal.StartEpoch,
 				},
 				abi.NewTokenAmount(0),
-				&builtin.Discard{},
+				&out,
 			)
 			if code.IsError() {
 				rt.Log(rtt.INFO, "invalid deal %d: failed to acquire datacap exitcode: %d", di, code)
 				continue
 			}
+			var ret verifreg.CreateAllocationReturn
+			err = ret.UnmarshalCBOR(bytes.NewReader(out))
+			builtin.RequireNoErr(rt, err, exitcode.ErrSerialization, "failed to decode allocation for deal %d", di)
+			allocationIDs[len(validDeals)] = ret.AllocationID
 		}
 
 		// update valid deal state
-		proposalCidLookup[pcid] = struct{}{}
+		if standingAsk != nil {
+			askCollateral = big.Add(askCollateral, deal.Proposal.ProviderCollateral)
+		}
 		validProposalCids = append(validProposalCids, pcid)
 		validDeals = append(validDeals, deal)
+		validInputIdxs = append(validInputIdxs, di)
 		validInputBf.Set(uint64(di))
 	}
 
@@ -295,26 +842,37 @@ func (a Actor) PublishStorageDeals(rt Runtime, params *PublishStorageDealsParams
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count valid deals in bitfield")
 	builtin.RequirePredicate(rt, len(validDeals) == len(validProposalCids), exitcode.ErrIllegalState,
 		"%d valid deals but %d valid proposal cids", len(validDeals), len(validProposalCids))
-	builtin.RequirePredicate(rt, uint64(len(validDeals)) == validDealCount, exitcode.ErrIllegalState,
-		"%d valid deals but validDealCount=%d", len(validDeals), validDealCount)
+	builtin.RequirePredicate(rt, uint64(len(validDeals)+len(republishedIDs)) == validDealCount, exitcode.ErrIllegalState,
+		"%d valid deals and %d republished but validDealCount=%d", len(validDeals), len(republishedIDs), validDealCount)
 	builtin.RequireParam(rt, validDealCount > 0, "All deal proposals invalid")
 
-	var newDealIds []abi.DealID
+	dealIDs := make(map[int]abi.DealID, validDealCount)
+	for di, id := range republishedIDs {
+		dealIDs[di] = id
+	}
 	rt.StateTransaction(&st, func() {
 		msm, err := st.mutator(adt.AsStore(rt)).withPendingProposals(WritePermission).
 			withDealProposals(WritePermission).withDealsByEpoch(WritePermission).withEscrowTable(WritePermission).
-			withLockedTable(WritePermission).build()
+			withLockedTable(WritePermission).withDealAllocations(WritePermission).withProviderAsks(WritePermission).build()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
 
 		// All storage dealProposals will be added in an atomic transaction; this operation will be unrolled if any of them fails.
 		// This should only fail on programmer error because all expected invalid conditions should be filtered in the first set of checks.
 		for vdi, validDeal := range validDeals {
-			err := msm.lockClientAndProviderBalances(&validDeal.Proposal)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to lock balance")
-
-			id := msm.generateStorageDealID()
+			if !IsDataOnboardingDeal(&validDeal.Proposal) {
+				err := msm.lockClientAndProviderBalances(&validDeal.Proposal)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to lock balance")
+			}
 
 			pcid := validProposalCids[vdi]
+			var id abi.DealID
+			if DealIDsFromProposalCID {
+				id, err = msm.generateDerivedDealID(pcid)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to derive deal ID for proposal %s", pcid)
+			} else {
+				id = msm.generateStorageDealID()
+			}
+
 			err = msm.pendingDeals.Put(abi.CidKey(pcid))
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set pending deal")
 
@@ -325,22 +883,475 @@ func (a Actor) PublishStorageDeals(rt Runtime, params *PublishStorageDealsParams
 			// schedule too many deals for the same tick.
 			processEpoch := GenRandNextEpoch(validDeal.Proposal.StartEpoch, id)
 
-			err = msm.dealsByEpoch.Put(processEpoch, id)
+			err = msm.dealsByEpoch.Put(processEpoch, validDeal.Proposal.Provider, id)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal ops by epoch")
 
-			newDealIds = append(newDealIds, id)
+			if allocationID, ok := allocationIDs[vdi]; ok {
+				err = msm.putDealAllocation(id, allocationID)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record allocation for deal %d", id)
+			}
+
+			dealIDs[validInputIdxs[vdi]] = id
+		}
+
+		if standingAsk != nil && !askCollateral.IsZero() {
+			ask, found, err := msm.getActiveProviderAsk(provider, rt.CurrEpoch())
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load provider ask")
+			builtin.RequirePredicate(rt, found, exitcode.ErrIllegalState, "provider %v ask removed while publishing", provider)
+			err = ask.CheckCollateralAvailable(askCollateral)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "provider %v ask changed while publishing", provider)
+			ask.ProviderCollateralCommitted = big.Add(ask.ProviderCollateralCommitted, askCollateral)
+			err = msm.providerAsks.Put(abi.AddrKey(provider), ask)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update provider ask")
 		}
 		err = msm.commitState()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
 	})
 
+	// IDs are returned in the order of the valid deals in the input.
+	var newDealIds []abi.DealID
+	for di := range deals {
+		if id, ok := dealIDs[di]; ok {
+			newDealIds = append(newDealIds, id)
+		}
+	}
+
 	return &PublishStorageDealsReturn{
 		IDs:        newDealIds,
 		ValidDeals: validInputBf,
 	}
 }
 
-// Changed in v3:
+type PostProviderAskParams struct {
+	Provider addr.Address
+	Ask      ProviderAsk
+}
+
+// Records a standing ask for a provider, replacing any previous ask.
+// Deals matching the ask may subsequently be published by their clients.
+// Only the provider's worker or control addresses may post an ask.
+func (a Actor) PostProviderAsk(rt Runtime, params *PostProviderAskParams) *abi.EmptyValue {
+	provider := validateProviderControlCaller(rt, params.Provider)
+
+	err := params.Ask.Validate(rt.CurrEpoch())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid provider ask")
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withProviderAsks(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		err = msm.providerAsks.Put(abi.AddrKey(provider), &params.Ask)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set provider ask")
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type WithdrawProviderAskParams struct {
+	Provider addr.Address
+}
+
+// Removes a provider's standing ask.
+// Only the provider's worker or control addresses may withdraw an ask.
+func (a Actor) WithdrawProviderAsk(rt Runtime, params *WithdrawProviderAskParams) *abi.EmptyValue {
+	provider := validateProviderControlCaller(rt, params.Provider)
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withProviderAsks(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		found, err := msm.providerAsks.TryDelete(abi.AddrKey(provider))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete provider ask")
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no ask for provider %v", provider)
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type RevokeDealProposalParams struct {
+	// CID of the proposal as it would be recorded on chain, with client and provider
+	// addresses resolved to ID addresses.
+	ProposalCid cid.Cid `checked:"true"` // Prefix checked in RevokeDealProposal
+	// Epoch at which the revocation lapses.
+	Expiry abi.ChainEpoch
+}
+
+// Revokes a signed deal proposal that has not yet been published, so that it cannot be published
+// before the revocation's expiry. A revocation may be renewed by revoking the same proposal again.
+// Only the proposal's client may revoke it, so the caller is taken to be the client.
+func (a Actor) RevokeDealProposal(rt Runtime, params *RevokeDealProposalParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	client := rt.Caller()
+
+	if params.ProposalCid.Prefix() != DealProposalCIDPrefix {
+		rt.Abortf(exitcode.ErrIllegalArgument, "proposal CID %s has wrong prefix", params.ProposalCid)
+	}
+	currEpoch := rt.CurrEpoch()
+	if params.Expiry <= currEpoch {
+		rt.Abortf(exitcode.ErrIllegalArgument, "revocation expiry %d must be after current epoch %d", params.Expiry, currEpoch)
+	}
+	if params.Expiry > currEpoch+MaxProposalRevocationDuration {
+		rt.Abortf(exitcode.ErrIllegalArgument, "revocation expiry %d exceeds maximum %d", params.Expiry, currEpoch+MaxProposalRevocationDuration)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withPendingProposals(ReadOnlyPermission).
+			withRevokedProposals(WritePermission).withClientProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		published, err := msm.pendingDeals.Has(abi.CidKey(params.ProposalCid))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check for published deal proposal")
+		if published {
+			rt.Abortf(exitcode.ErrIllegalArgument, "proposal %s has already been published", params.ProposalCid)
+		}
+
+		err = msm.revokeProposal(client, params.ProposalCid, params.Expiry, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to revoke proposal %s", params.ProposalCid)
+
+		// A revoked proposal recorded by its client can no longer be accepted.
+		err = msm.removeClientProposal(client, params.ProposalCid)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove client proposal %s", params.ProposalCid)
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type IndexDealLabelsParams struct {
+	DealIDs []abi.DealID
+}
+
+// Indexes deals by their labels so that they may be found with LookupDealsByLabel.
+// Deals are indexed under their client's address, and only a deal's client may index it, so the caller
+// is taken to be the client.
+func (a Actor) IndexDealLabels(rt Runtime, params *IndexDealLabelsParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	client := rt.Caller()
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(ReadOnlyPermission).
+			withLabelIndex(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for _, dealID := range params.DealIDs {
+			proposal, found, err := msm.dealProposals.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "no such deal %d", dealID)
+			}
+			if proposal.Client != client {
+				rt.Abortf(exitcode.ErrForbidden, "caller %v is not the client of deal %d", client, dealID)
+			}
+			if proposal.Label.Length() == 0 {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %d has no label to index", dealID)
+			}
+
+			key := ComputeLabelIndexKey(client, proposal.Label, rt.HashBlake2b)
+			err = msm.indexDealLabel(key, dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to index label of deal %d", dealID)
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type LookupDealsByLabelParams struct {
+	Client addr.Address
+	Label  DealLabel
+}
+
+type LookupDealsByLabelReturn struct {
+	DealIDs []abi.DealID
+}
+
+// Returns the deals that a client has indexed with exactly the given label and which have not been cleaned up
+// or transferred to another client.
+func (a Actor) LookupDealsByLabel(rt Runtime, params *LookupDealsByLabelParams) *LookupDealsByLabelReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	if params.Label.Length() == 0 || params.Label.Length() > DealMaxLabelSize {
+		rt.Abortf(exitcode.ErrIllegalArgument, "label length %d must be between 1 and %d", params.Label.Length(), DealMaxLabelSize)
+	}
+	client, ok := rt.ResolveAddress(params.Client)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve client address %v", params.Client)
+	}
+	key := ComputeLabelIndexKey(client, params.Label, rt.HashBlake2b)
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(ReadOnlyPermission).
+		withLabelIndex(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	deals, err := msm.loadLabelIndexEntry(key)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load label index entry")
+
+	dealIDs := []abi.DealID{}
+	err = deals.ForEach(func(id uint64) error {
+		proposal, found, err := msm.dealProposals.Get(abi.DealID(id))
+		if err != nil {
+			return err
+		}
+		if found && proposal.Client == client && proposal.Label.Equals(params.Label) {
+			dealIDs = append(dealIDs, abi.DealID(id))
+		}
+		return nil
+	})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate label index entry")
+
+	return &LookupDealsByLabelReturn{DealIDs: dealIDs}
+}
+
+type SampleDealsForAuditParams struct {
+	Epoch  abi.ChainEpoch // Epoch of the beacon randomness from which samples are drawn.
+	Offset uint64         // Index of the first sample to return.
+	Count  uint64         // Number of samples to return.
+}
+
+type SampleDealsForAuditReturn struct {
+	DealIDs []abi.DealID
+}
+
+// Samples active deals for retrieval audits, drawing each deal with probability proportional to its piece size.
+// Each sample picks a deal uniformly from the index of active deals, which includes deals whose IDs are derived
+// from their proposal CIDs, from the beacon randomness at the given epoch and the sample's index. The deal is
+// accepted with probability its piece size over the largest piece size indexed, so that the deals accepted are
+// weighted by piece size. Samples rejected, or picking a deal slashed or expired but not yet cleaned up, yield
+// nothing, so fewer deals than the count may be returned. The same deal may be drawn more than once, and a
+// sequence of samples may be fetched in pages of any size. Each sample costs at most three lookups, so the cost
+// is bounded by the count.
+func (a Actor) SampleDealsForAudit(rt Runtime, params *SampleDealsForAuditParams) *SampleDealsForAuditReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	currEpoch := rt.CurrEpoch()
+	if params.Epoch < 0 || params.Epoch > currEpoch {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sample epoch %d must be between 0 and current epoch %d", params.Epoch, currEpoch)
+	}
+	if params.Count == 0 || params.Count > DealAuditSamplesMax {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sample count %d must be between 1 and %d", params.Count, DealAuditSamplesMax)
+	}
+	if params.Offset+params.Count < params.Offset {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sample offset %d out of range", params.Offset)
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(ReadOnlyPermission).
+		withDealStates(ReadOnlyPermission).withActiveDeals(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	samples := []abi.DealID{}
+	activeCount := msm.activeDeals.Length()
+	if activeCount == 0 {
+		return &SampleDealsForAuditReturn{DealIDs: samples}
+	}
+
+	randomness := rt.GetRandomnessFromBeacon(builtin.DomainSeparationTag_MarketDealAuditSeed, params.Epoch, nil)
+	dealCount := big.NewIntUnsigned(activeCount)
+	maxPieceSize := big.NewIntUnsigned(uint64(msm.activeDealsMaxPieceSize))
+	for i := params.Offset; i < params.Offset+params.Count; i++ {
+		// The remainder of the seed picks the deal, and the quotient whether it is accepted.
+		seed := auditSampleSeed(rt, randomness, i)
+		index := big.Mod(seed, dealCount).Uint64()
+		acceptance := big.Mod(big.Div(seed, dealCount), maxPieceSize)
+
+		var dealID cbg.CborInt
+		found, err := msm.activeDeals.Get(index, &dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get active deal %d", index)
+		builtin.RequireState(rt, found, "no active deal at index %d of %d", index, activeCount)
+		proposal, err := getDealProposal(msm.dealProposals, abi.DealID(dealID))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+		if acceptance.GreaterThanEqual(big.NewIntUnsigned(uint64(proposal.PieceSize))) || proposal.EndEpoch <= currEpoch {
+			continue
+		}
+		state, found, err := msm.dealStates.Get(abi.DealID(dealID))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", dealID)
+		if !found || state.SlashEpoch != epochUndefined {
+			continue
+		}
+		samples = append(samples, abi.DealID(dealID))
+	}
+	return &SampleDealsForAuditReturn{DealIDs: samples}
+}
+
+// Derives the seed of an audit sample from the sampling randomness and the sample's index.
+func auditSampleSeed(rt Runtime, randomness abi.Randomness, index uint64) big.Int {
+	buf := make([]byte, len(randomness)+8)
+	copy(buf, randomness)
+	binary.BigEndian.PutUint64(buf[len(randomness):], index)
+	digest := rt.HashBlake2b(buf)
+	return big.PositiveFromUnsignedBytes(digest[:])
+}
+
+type VerifyPieceInclusionParams struct {
+	DealID       abi.DealID
+	SubPieceCID  cid.Cid `checked:"true"` // Checked in VerifyPieceInclusionProof
+	SubPieceSize abi.PaddedPieceSize
+	Proof        PieceInclusionProof
+}
+
+// Verifies that a sub-piece is included in the piece of a published deal, so that the aggregator of
+// the deal's piece can demonstrate inclusion of a client's data to other actors.
+// Aborts if the deal is not found or the proof is invalid.
+func (a Actor) VerifyPieceInclusion(rt Runtime, params *VerifyPieceInclusionParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	proposal, found, err := msm.dealProposals.Get(params.DealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", params.DealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", params.DealID)
+	}
+
+	err = VerifyPieceInclusionProof(proposal.PieceCID, proposal.PieceSize, params.SubPieceCID, params.SubPieceSize, &params.Proof)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid inclusion proof for deal %d", params.DealID)
+	return nil
+}
+
+type AuthorizeEscrowFunderParams struct {
+	Funder addr.Address
+	// Maximum amount that may be requested from the funder in any one epoch.
+	// Zero removes any existing authorization.
+	MaxPerEpoch abi.TokenAmount
+}
+
+// Authorizes an actor that is not built in to top up the caller's escrow when it is insufficient to cover deals
+// being published with the caller as client. Funds are requested with MethodRequestEscrowFunds,
+// for no more than the shortfall and bounded in total per epoch. Replaces any prior authorization.
+func (a Actor) AuthorizeEscrowFunder(rt Runtime, params *AuthorizeEscrowFunderParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	client := rt.Caller()
+
+	if params.MaxPerEpoch.LessThan(big.Zero()) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "negative maximum per epoch %v", params.MaxPerEpoch)
+	}
+	var funder addr.Address
+	if !params.MaxPerEpoch.IsZero() {
+		var ok bool
+		if funder, ok = rt.ResolveAddress(params.Funder); !ok {
+			rt.Abortf(exitcode.ErrNotFound, "failed to resolve funder address %v", params.Funder)
+		}
+		// Built-in actors don't implement MethodRequestEscrowFunds, which must never invoke one of their methods.
+		code, ok := rt.GetActorCodeCID(funder)
+		if !ok || builtin.IsBuiltinActor(code) {
+			rt.Abortf(exitcode.ErrForbidden, "funder %v is not an actor that may fund escrow", funder)
+		}
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withEscrowFunders(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		if params.MaxPerEpoch.IsZero() {
+			_, err = msm.escrowFunders.TryDelete(abi.AddrKey(client))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove escrow funder for %v", client)
+		} else {
+			err = msm.escrowFunders.Put(abi.AddrKey(client), &EscrowFunder{
+				Funder:           funder,
+				MaxPerEpoch:      params.MaxPerEpoch,
+				LastRequestEpoch: epochUndefined,
+				RequestedInEpoch: big.Zero(),
+			})
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set escrow funder for %v", client)
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+// Adds the value sent to a client's escrow. May be called only by the client's authorized escrow funder,
+// typically in response to a request for funds.
+func (a Actor) FundClientEscrow(rt Runtime, clientAddress *addr.Address) *abi.EmptyValue {
+	msgValue := rt.ValueReceived()
+	builtin.RequireParam(rt, msgValue.GreaterThan(big.Zero()), "balance to add must be greater than zero")
+	rt.ValidateImmediateCallerAcceptAny()
+
+	client, ok := rt.ResolveAddress(*clientAddress)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve client address %v", *clientAddress)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withEscrowTable(WritePermission).
+			withEscrowFunders(ReadOnlyPermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		funder, found, err := msm.getEscrowFunder(client)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load escrow funder")
+		if !found || funder.Funder != rt.Caller() {
+			rt.Abortf(exitcode.ErrForbidden, "caller %v is not the escrow funder for client %v", rt.Caller(), client)
+		}
+
+		err = msm.escrowTable.Add(client, msgValue)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add balance to escrow table")
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type RepairLockedTotalsReturn struct {
+	// Totals as recorded in state before the repair.
+	Recorded LockedTotals
+	// Totals as recomputed from the deals, and now recorded.
+	Computed LockedTotals
+}
+
+// Recomputes the totals of locked client collateral, provider collateral and client storage fees
+// from the deals in state, replacing the recorded totals if they have drifted.
+// Balances in the locked table are not changed.
+// May only be invoked by the system actor, e.g. as part of a network upgrade.
+func (a Actor) RepairLockedTotals(rt Runtime, _ *abi.EmptyValue) *RepairLockedTotalsReturn {
+	rt.ValidateImmediateCallerIs(builtin.SystemActorAddr)
+
+	var ret RepairLockedTotalsReturn
+	var st State
+	rt.StateTransaction(&st, func() {
+		computed, err := st.ComputeLockedTotals(adt.AsStore(rt))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute locked totals")
+
+		ret.Recorded = LockedTotals{
+			ClientCollateral:   st.TotalClientLockedCollateral,
+			ProviderCollateral: st.TotalProviderLockedCollateral,
+			ClientStorageFee:   st.TotalClientStorageFee,
+		}
+		ret.Computed = *computed
+		if !ret.Recorded.ClientCollateral.Equals(computed.ClientCollateral) ||
+			!ret.Recorded.ProviderCollateral.Equals(computed.ProviderCollateral) ||
+			!ret.Recorded.ClientStorageFee.Equals(computed.ClientStorageFee) {
+			rt.Log(rtt.WARN, "repairing locked totals: recorded %+v, computed %+v", ret.Recorded, *computed)
+		}
+
+		st.TotalClientLockedCollateral = computed.ClientCollateral
+		st.TotalProviderLockedCollateral = computed.ProviderCollateral
+		st.TotalClientStorageFee = computed.ClientStorageFee
+	})
+	return &ret
+}
+
+// Changed in v3:
 // - Array of sectors rather than just one
 // - Removed SectorStart (which is unknown at call time)
 //type VerifyDealsForActivationParams struct {
@@ -406,11 +1417,12 @@ func (a Actor) VerifyDealsForActivation(rt Runtime, params *VerifyDealsForActiva
 	}
 }
 
-//type ActivateDealsParams struct {
-//	DealIDs      []abi.DealID
-//	SectorExpiry abi.ChainEpoch
-//}
-type ActivateDealsParams = market0.ActivateDealsParams
+type ActivateDealsParams struct {
+	DealIDs      []abi.DealID
+	SectorExpiry abi.ChainEpoch
+	// The sector in which the deals are activated, recorded in their states.
+	SectorNumber abi.SectorNumber
+}
 
 // Verify that a given set of storage deals is valid for a sector currently being ProveCommitted,
 // update the market's internal state accordingly.
@@ -419,249 +1431,1269 @@ func (a Actor) ActivateDeals(rt Runtime, params *ActivateDealsParams) *abi.Empty
 	minerAddr := rt.Caller()
 	currEpoch := rt.CurrEpoch()
 
-	var st State
-	store := adt.AsStore(rt)
+	var st State
+	var verifiedActivations []verifreg.ActivatedBytes
+	var allocationIDs []verifreg.AllocationID
+
+	// Update deal dealStates.
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
+			withPendingProposals(ReadOnlyPermission).withDealProposals(ReadOnlyPermission).
+			withDealAllocations(WritePermission).withActiveDeals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		proposals, err := msm.checkSectorDealsActivatable(minerAddr, params.DealIDs, params.SectorExpiry, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to validate dealProposals for activation")
+		verifiedActivations, allocationIDs, err = msm.activateSectorDeals(params.DealIDs, proposals, params.SectorNumber, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to activate deals")
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+
+	// The deals' allocations are claimed for the sector, which fails activation if they cannot be.
+	if len(allocationIDs) > 0 {
+		code := rt.Send(
+			builtin.VerifiedRegistryActorAddr,
+			builtin.MethodsVerifiedRegistry.ClaimAllocations,
+			&verifreg.ClaimAllocationsParams{
+				Provider:      minerAddr,
+				Sector:        params.SectorNumber,
+				AllocationIDs: allocationIDs,
+			},
+			abi.NewTokenAmount(0),
+			&builtin.Discard{},
+		)
+		builtin.RequireSuccess(rt, code, "failed to claim allocations for verified deals")
+	}
+
+	// Usage statistics are informational, so failing to record them does not prevent activation.
+	if len(verifiedActivations) > 0 {
+		code := rt.Send(
+			builtin.VerifiedRegistryActorAddr,
+			builtin.MethodsVerifiedRegistry.RecordActivatedBytes,
+			&verifreg.RecordActivatedBytesParams{Activations: verifiedActivations},
+			abi.NewTokenAmount(0),
+			&builtin.Discard{},
+		)
+		if !code.IsSuccess() {
+			rt.Log(rtt.ERROR, "failed to send RecordActivatedBytes call to the VerifReg actor for %d verified deals, got code %v",
+				len(verifiedActivations), code)
+		}
+	}
+
+	return nil
+}
+
+// The deals to be activated in a sector.
+// This is SectorDeals with the number of the sector, which is recorded in the deals' states.
+type SectorDealActivation struct {
+	SectorNumber abi.SectorNumber
+	SectorExpiry abi.ChainEpoch
+	DealIDs      []abi.DealID
+}
+
+type BatchActivateDealsParams struct {
+	Sectors []SectorDealActivation
+}
+
+type BatchActivateDealsReturn struct {
+	// Indices in the batch of sectors whose deals were activated.
+	Activated bitfield.BitField
+}
+
+// Activates the deals of many sectors being ProveCommitted, as ActivateDeals does for each.
+// A sector's deals are all activated, or none are if any cannot be, without affecting the other sectors.
+// The allocations of the sectors' verified deals are claimed before any deal is activated, and a sector
+// whose allocations cannot be claimed is not activated.
+func (a Actor) BatchActivateDeals(rt Runtime, params *BatchActivateDealsParams) *BatchActivateDealsReturn {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+	currEpoch := rt.CurrEpoch()
+
+	failed := make([]bool, len(params.Sectors))
+	fail := func(i int, err error) {
+		failed[i] = true
+		rt.Log(rtt.INFO, "failed to activate deals of sector %d: %s", params.Sectors[i].SectorNumber, err)
+	}
+
+	// Check each sector, and find the allocations to be claimed for it.
+	var stReadOnly State
+	rt.StateReadonly(&stReadOnly)
+	msmReadOnly, err := stReadOnly.mutator(adt.AsStore(rt)).withDealStates(ReadOnlyPermission).
+		withPendingProposals(ReadOnlyPermission).withDealProposals(ReadOnlyPermission).
+		withDealAllocations(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+	claimed := make([]bool, len(params.Sectors))
+	// Deals of the sectors that passed the checks, which no later sector in the batch may name.
+	batchDeals := make(map[abi.DealID]struct{})
+	for i, sector := range params.Sectors {
+		if dealID, dup := findBatchDuplicate(batchDeals, sector.DealIDs); dup {
+			fail(i, xerrors.Errorf("deal %d is named by an earlier sector in the batch", dealID))
+			continue
+		}
+		proposals, err := msmReadOnly.checkSectorDealsActivatable(minerAddr, sector.DealIDs, sector.SectorExpiry, currEpoch)
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		var allocationIDs []verifreg.AllocationID
+		for j, dealID := range sector.DealIDs {
+			if !proposals[j].VerifiedDeal {
+				continue
+			}
+			allocationID, found, err := stReadOnly.GetDealAllocation(adt.AsStore(rt), dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get allocation for deal %d", dealID)
+			if found {
+				allocationIDs = append(allocationIDs, allocationID)
+			}
+		}
+		if len(allocationIDs) > 0 {
+			code := rt.Send(
+				builtin.VerifiedRegistryActorAddr,
+				builtin.MethodsVerifiedRegistry.ClaimAllocations,
+				&verifreg.ClaimAllocationsParams{
+					Provider:      minerAddr,
+					Sector:        sector.SectorNumber,
+					AllocationIDs: allocationIDs,
+				},
+				abi.NewTokenAmount(0),
+				&builtin.Discard{},
+			)
+			if !code.IsSuccess() {
+				fail(i, code.Wrapf("failed to claim allocations for verified deals"))
+				continue
+			}
+			claimed[i] = true
+		}
+		for _, dealID := range sector.DealIDs {
+			batchDeals[dealID] = struct{}{}
+		}
+	}
+
+	var verifiedActivations []verifreg.ActivatedBytes
+	activated := bitfield.New()
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
+			withPendingProposals(ReadOnlyPermission).withDealProposals(ReadOnlyPermission).
+			withDealAllocations(WritePermission).withActiveDeals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for i, sector := range params.Sectors {
+			if failed[i] {
+				continue
+			}
+			// Checked again against the state being written. No deal is named by two sectors that passed the
+			// first check, so a sector whose allocations were claimed can't fail now.
+			proposals, err := msm.checkSectorDealsActivatable(minerAddr, sector.DealIDs, sector.SectorExpiry, currEpoch)
+			if err != nil {
+				builtin.RequireState(rt, !claimed[i], "sector %d failed activation after claiming allocations: %s", sector.SectorNumber, err)
+				fail(i, err)
+				continue
+			}
+			sectorActivations, _, err := msm.activateSectorDeals(sector.DealIDs, proposals, sector.SectorNumber, currEpoch)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to activate deals of sector %d", sector.SectorNumber)
+			verifiedActivations = append(verifiedActivations, sectorActivations...)
+			activated.Set(uint64(i))
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+
+	// Usage statistics are informational, so failing to record them does not prevent activation.
+	if len(verifiedActivations) > 0 {
+		code := rt.Send(
+			builtin.VerifiedRegistryActorAddr,
+			builtin.MethodsVerifiedRegistry.RecordActivatedBytes,
+			&verifreg.RecordActivatedBytesParams{Activations: verifiedActivations},
+			abi.NewTokenAmount(0),
+			&builtin.Discard{},
+		)
+		if !code.IsSuccess() {
+			rt.Log(rtt.ERROR, "failed to send RecordActivatedBytes call to the VerifReg actor for %d verified deals, got code %v",
+				len(verifiedActivations), code)
+		}
+	}
+
+	return &BatchActivateDealsReturn{Activated: activated}
+}
+
+// Returns the first of a sector's deals already named by an earlier sector of a batch, if any.
+func findBatchDuplicate(batchDeals map[abi.DealID]struct{}, dealIDs []abi.DealID) (abi.DealID, bool) {
+	for _, dealID := range dealIDs {
+		if _, found := batchDeals[dealID]; found {
+			return dealID, true
+		}
+	}
+	return 0, false
+}
+
+//type SectorDataSpec struct {
+//	DealIDs    []abi.DealID
+//	SectorType abi.RegisteredSealProof
+//}
+type SectorDataSpec = market5.SectorDataSpec
+
+//type ComputeDataCommitmentParams struct {
+//	Inputs []*SectorDataSpec
+//}
+type ComputeDataCommitmentParams = market5.ComputeDataCommitmentParams
+
+//type ComputeDataCommitmentReturn struct {
+//	CommDs []cbg.CborCid
+//}
+type ComputeDataCommitmentReturn = market5.ComputeDataCommitmentReturn
+
+func (a Actor) ComputeDataCommitment(rt Runtime, params *ComputeDataCommitmentParams) *ComputeDataCommitmentReturn {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+
+	var st State
+	rt.StateReadonly(&st)
+	proposals, err := AsDealProposalArray(adt.AsStore(rt), st.Proposals)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal dealProposals")
+	commDs := make([]cbg.CborCid, len(params.Inputs))
+	for i, commInput := range params.Inputs {
+		pieces := make([]abi.PieceInfo, 0)
+		for _, dealID := range commInput.DealIDs {
+			deal, err := getDealProposal(proposals, dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get dealId %d", dealID)
+
+			pieces = append(pieces, abi.PieceInfo{
+				PieceCID: deal.PieceCID,
+				Size:     deal.PieceSize,
+			})
+		}
+		commD, err := rt.ComputeUnsealedSectorCID(commInput.SectorType, pieces)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to compute unsealed sectorCID: %s", err)
+		commDs[i] = (cbg.CborCid)(commD)
+	}
+	return &ComputeDataCommitmentReturn{
+		CommDs: commDs,
+	}
+}
+
+//type OnMinerSectorsTerminateParams struct {
+//	Epoch   abi.ChainEpoch
+//	DealIDs []abi.DealID
+//}
+type OnMinerSectorsTerminateParams = market0.OnMinerSectorsTerminateParams
+
+type OnMinerSectorsTerminateReturn struct {
+	// Deals with no proposal, which expired and were settled before their sector was terminated.
+	NotFound []abi.DealID
+}
+
+// Terminate a set of deals in response to their containing sector being terminated.
+// Slash provider collateral, refund client collateral, and refund partial unpaid escrow
+// amount to client.
+// The states of the deals are written together once all are checked.
+func (a Actor) OnMinerSectorsTerminate(rt Runtime, params *OnMinerSectorsTerminateParams) *OnMinerSectorsTerminateReturn {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+	if len(params.DealIDs) > MaxDealsTerminatedPerCall {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many deals to terminate %d, max %d", len(params.DealIDs), MaxDealsTerminatedPerCall)
+	}
+
+	notFound := []abi.DealID{}
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
+			withDealProposals(ReadOnlyPermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal state")
+
+		slashed := make(map[abi.DealID]*DealState, len(params.DealIDs))
+		for _, dealID := range params.DealIDs {
+			deal, found, err := msm.dealProposals.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %v", dealID)
+			// The deal may have expired and been deleted before the sector is terminated.
+			// Report the dealID to the caller and continue execution for other deals
+			if !found {
+				rt.Log(rtt.INFO, "couldn't find deal %d", dealID)
+				notFound = append(notFound, dealID)
+				continue
+			}
+			builtin.RequireState(rt, deal.Provider == minerAddr, "caller %v is not the provider %v of deal %v",
+				minerAddr, deal.Provider, dealID)
+			if _, ok := slashed[dealID]; ok {
+				continue
+			}
+
+			state, found, err := msm.dealStates.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %v", dealID)
+			if !found {
+				// A deal with a proposal but no state is not activated, but then it should not be
+				// part of a sector that is terminating.
+				rt.Abortf(exitcode.ErrIllegalArgument, "no state for deal %v", dealID)
+			}
+
+			// if a deal is already slashed, we don't need to do anything here.
+			if state.SlashEpoch != epochUndefined {
+				rt.Log(rtt.INFO, "deal %d already slashed", dealID)
+				continue
+			}
+
+			// mark the deal for slashing here, at the epoch its sector was terminated or, if the deal had
+			// already ended by then, at its end epoch. A deal marked at its end epoch is not slashed.
+			// actual releasing of locked funds for the client and slashing of provider collateral happens in CronTick.
+			state.SlashEpoch = params.Epoch
+			if deal.EndEpoch < params.Epoch {
+				state.SlashEpoch = deal.EndEpoch
+			}
+			slashed[dealID] = state
+		}
+
+		err = msm.dealStates.SetMany(slashed)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal states")
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return &OnMinerSectorsTerminateReturn{NotFound: notFound}
+}
+
+type OnMinerDealsReplacedParams struct {
+	DealIDs []abi.DealID
+}
+
+// Terminates deals whose data a miner has replaced in their sectors with a replica update, as though their
+// sectors were terminated at the current epoch. The deals' provider collateral is slashed, and the client's
+// collateral and remaining storage fee unlocked, when cron next processes them.
+// Deals already ended, or slashed, are left for cron to settle.
+func (a Actor) OnMinerDealsReplaced(rt Runtime, params *OnMinerDealsReplacedParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+	if len(params.DealIDs) > MaxDealsTerminatedPerCall {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many deals to terminate %d, max %d", len(params.DealIDs), MaxDealsTerminatedPerCall)
+	}
+
+	currEpoch := rt.CurrEpoch()
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
+			withDealProposals(ReadOnlyPermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		slashed := make(map[abi.DealID]*DealState, len(params.DealIDs))
+		for _, dealID := range params.DealIDs {
+			deal, found, err := msm.dealProposals.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %v", dealID)
+			if !found {
+				rt.Log(rtt.INFO, "couldn't find deal %d", dealID)
+				continue
+			}
+			if deal.Provider != minerAddr {
+				rt.Abortf(exitcode.ErrForbidden, "caller %v is not the provider %v of deal %v", minerAddr, deal.Provider, dealID)
+			}
+			if _, ok := slashed[dealID]; ok {
+				continue
+			}
+
+			state, found, err := msm.dealStates.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %v", dealID)
+			if !found {
+				rt.Abortf(exitcode.ErrIllegalArgument, "no state for deal %v", dealID)
+			}
+			if state.SlashEpoch != epochUndefined {
+				rt.Log(rtt.INFO, "deal %d already slashed", dealID)
+				continue
+			}
+			if currEpoch >= deal.EndEpoch {
+				rt.Log(rtt.INFO, "deal %d already ended", dealID)
+				continue
+			}
+
+			state.SlashEpoch = currEpoch
+			slashed[dealID] = state
+		}
+
+		err = msm.dealStates.SetMany(slashed)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal states")
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type ContestDealSlashParams struct {
+	Provider addr.Address
+	DealIDs  []abi.DealID
+}
+
+// Reverses the slash recorded for deals whose sectors were terminated only once the deals had ended,
+// the termination having raced the deals' expiry.
+// Only a slash recorded at or after a deal's end epoch may be reversed; one recorded before it stands.
+// The provider may contest a slash within DealSlashContestWindow epochs of its recorded epoch, and until
+// cron settles the deal, which then settles it as expired with no termination recorded.
+func (a Actor) ContestDealSlash(rt Runtime, params *ContestDealSlashParams) *abi.EmptyValue {
+	provider := validateProviderControlCaller(rt, params.Provider)
+	if len(params.DealIDs) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no deals to contest")
+	}
+
+	currEpoch := rt.CurrEpoch()
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
+			withDealProposals(ReadOnlyPermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal state")
+
+		for _, dealID := range params.DealIDs {
+			// A deal settled by cron has no proposal left to contest.
+			deal, err := getDealProposal(msm.dealProposals, dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrNotFound, "failed to get deal proposal %v", dealID)
+			if deal.Provider != provider {
+				rt.Abortf(exitcode.ErrForbidden, "provider %v is not the provider %v of deal %v", provider, deal.Provider, dealID)
+			}
+
+			state, found, err := msm.dealStates.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %v", dealID)
+			if !found || state.SlashEpoch == epochUndefined {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %v has no recorded slash", dealID)
+			}
+			if state.SlashEpoch < deal.EndEpoch {
+				rt.Abortf(exitcode.ErrForbidden, "deal %v was slashed at %d, before its end at %d",
+					dealID, state.SlashEpoch, deal.EndEpoch)
+			}
+			if currEpoch > state.SlashEpoch+DealSlashContestWindow {
+				rt.Abortf(exitcode.ErrForbidden, "window to contest slash of deal %v closed at %d",
+					dealID, state.SlashEpoch+DealSlashContestWindow)
+			}
+
+			rt.Log(rtt.INFO, "provider %v contested slash of deal %d at %d after its end at %d",
+				provider, dealID, state.SlashEpoch, deal.EndEpoch)
+			state.SlashEpoch = epochUndefined
+			err = msm.dealStates.Set(dealID, state)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state %v", dealID)
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type SettleDealPaymentsParams struct {
+	DealIDs []abi.DealID
+}
+
+// Pays providers the storage fees their active deals have earned up to the current epoch, ahead of the
+// deals' next scheduled cron updates. Settlement transfers only payments already earned, so any party
+// may trigger it. Deals that have ended or been slashed are left to cron to settle.
+func (a Actor) SettleDealPayments(rt Runtime, params *SettleDealPaymentsParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerAcceptAny()
+	if len(params.DealIDs) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no deals to settle")
+	}
+
+	currEpoch := rt.CurrEpoch()
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
+			withLockedTable(WritePermission).withEscrowTable(WritePermission).
+			withDealProposals(ReadOnlyPermission).withPendingProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for _, dealID := range params.DealIDs {
+			deal, err := getDealProposal(msm.dealProposals, dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrNotFound, "failed to get deal proposal %v", dealID)
+
+			state, found, err := msm.dealStates.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %v", dealID)
+			if !found {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %v is not active", dealID)
+			}
+			if state.SlashEpoch != epochUndefined {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %v was slashed at %d", dealID, state.SlashEpoch)
+			}
+			if currEpoch < deal.StartEpoch {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %v does not start until %d", dealID, deal.StartEpoch)
+			}
+			if currEpoch >= deal.EndEpoch {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %v ended at %d", dealID, deal.EndEpoch)
+			}
+
+			msm.settleDealPayment(rt, dealID, deal, state, currEpoch)
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+// Pays a started, unslashed deal's provider for the epochs elapsed up to an epoch before the deal's end.
+func (m *marketStateMutation) settleDealPayment(rt Runtime, dealID abi.DealID, deal *DealProposal, state *DealState, epoch abi.ChainEpoch) {
+	// The deal's first update, whether by cron or settlement, retires its pending proposal.
+	if state.LastUpdatedEpoch == epochUndefined {
+		dcid, err := deal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %v", dealID)
+		err = m.pendingDeals.Delete(abi.CidKey(dcid))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete pending proposal %v", dcid)
+	}
+
+	// The deal remains scheduled for its next cron update, which pays only for the epochs after this one.
+	slashAmount, _, removeDeal := m.updatePendingDealState(rt, state, deal, epoch)
+	builtin.RequireState(rt, slashAmount.IsZero() && !removeDeal, "settled deal %d should continue unslashed", dealID)
+
+	state.LastUpdatedEpoch = epoch
+	err := m.dealStates.Set(dealID, state)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state %v", dealID)
+}
+
+// New terms for a deal, agreed by its client and provider.
+type DealAmendment struct {
+	DealID abi.DealID
+	// CID of the deal's proposal as it stands, binding the amendment to the terms it replaces.
+	ProposalCid          cid.Cid `checked:"true"` // Prefix checked in AmendDealProposal
+	StoragePricePerEpoch abi.TokenAmount
+	EndEpoch             abi.ChainEpoch
+}
+
+type AmendDealProposalParams struct {
+	Amendment DealAmendment
+	// Signature over the amendment by the party not sending the message: the client when sent by the provider's
+	// worker or a control address, or the provider's worker when sent by the client.
+	Signature crypto.Signature
+}
+
+type AmendDealProposalReturn struct {
+	// CID of the amended proposal, to be named by any further amendment.
+	ProposalCid cid.Cid
+}
+
+// Changes the storage price and end epoch of a deal that is yet to end, by agreement of its client and provider.
+// A deal already activated may only have its end brought forward, since its sector's expiration was checked
+// against the original end. A verified deal's end may not change at all, since the sector's verified deal weight,
+// computed from the deal's duration when it was pre-committed, would no longer match it. The provider is first
+// paid for the epochs elapsed at the old price, and the client's locked storage fee is then adjusted to cover the
+// remaining epochs at the new price. Collateral is unchanged.
+// The deal keeps its ID, but is no longer found by the CID of its original proposal.
+func (a Actor) AmendDealProposal(rt Runtime, params *AmendDealProposalParams) *AmendDealProposalReturn {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	amendment := params.Amendment
+	dealID := amendment.DealID
+	currEpoch := rt.CurrEpoch()
+	builtin.RequireParam(rt, amendment.ProposalCid.Prefix() == DealProposalCIDPrefix, "proposal CID had wrong prefix")
+
+	var stReadOnly State
+	rt.StateReadonly(&stReadOnly)
+	proposals, err := AsDealProposalArray(adt.AsStore(rt), stReadOnly.Proposals)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal proposals")
+	proposal, found, err := proposals.Get(dealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", dealID)
+	}
+
+	// The signer is whichever party did not send the message.
+	_, worker, controllers := builtin.RequestMinerControlAddrs(rt, proposal.Provider)
+	signer := proposal.Client
+	if rt.Caller() == proposal.Client {
+		signer = worker
+	} else if !isControllerOrWorker(rt.Caller(), worker, controllers) {
+		rt.Abortf(exitcode.ErrForbidden, "caller %v is neither client nor provider of deal %d", rt.Caller(), dealID)
+	}
+	signed, err := SigningBytes(SigningDomainDealAmendment, &amendment)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to marshal amendment")
+	err = rt.VerifySignature(params.Signature, signer, signed)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid signature by %v over amendment of deal %d", signer, dealID)
+
+	var amendedCid cid.Cid
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(WritePermission).
+			withDealStates(WritePermission).withPendingProposals(WritePermission).
+			withEscrowTable(WritePermission).withLockedTable(WritePermission).
+			withSupersededProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		deal, err := getDealProposal(msm.dealProposals, dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+		dealCid, err := deal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %d", dealID)
+		if !dealCid.Equals(amendment.ProposalCid) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "amendment is of proposal %s, not the current proposal %s of deal %d", amendment.ProposalCid, dealCid, dealID)
+		}
+		if IsDataOnboardingDeal(deal) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d is a data onboarding deal", dealID)
+		}
+
+		state, active, err := msm.dealStates.Get(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", dealID)
+		if active {
+			if state.SlashEpoch != epochUndefined {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %d was slashed at %d", dealID, state.SlashEpoch)
+			}
+			if currEpoch >= deal.EndEpoch {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %d ended at %d", dealID, deal.EndEpoch)
+			}
+			if amendment.EndEpoch > deal.EndEpoch {
+				rt.Abortf(exitcode.ErrIllegalArgument, "cannot extend active deal %d beyond its end %d", dealID, deal.EndEpoch)
+			}
+		} else if currEpoch > deal.StartEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d was not activated by its start %d", dealID, deal.StartEpoch)
+		}
+		if deal.VerifiedDeal && amendment.EndEpoch != deal.EndEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "cannot change end of verified deal %d, whose duration weights its sector", dealID)
+		}
+
+		amended := *deal
+		amended.StoragePricePerEpoch = amendment.StoragePricePerEpoch
+		amended.EndEpoch = amendment.EndEpoch
+		if amended.EndEpoch <= currEpoch || amended.EndEpoch <= amended.StartEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "amended end epoch %d has elapsed or precedes start %d", amended.EndEpoch, amended.StartEpoch)
+		}
+		minDuration, maxDuration := DealDurationBounds(amended.PieceSize)
+		if amended.Duration() < minDuration || amended.Duration() > maxDuration {
+			rt.Abortf(exitcode.ErrIllegalArgument, "amended deal duration %d out of bounds", amended.Duration())
+		}
+		minPrice, maxPrice := DealPricePerEpochBounds(amended.PieceSize, amended.Duration())
+		if amended.StoragePricePerEpoch.LessThan(minPrice) || amended.StoragePricePerEpoch.GreaterThan(maxPrice) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "amended storage price %v out of bounds", amended.StoragePricePerEpoch)
+		}
+
+		// Epochs elapsed are paid for at the old price, and the remainder locked at the new.
+		paidUntil := deal.StartEpoch
+		if active && currEpoch > deal.StartEpoch {
+			msm.settleDealPayment(rt, dealID, deal, state, currEpoch)
+			paidUntil = currEpoch
+		}
+		feeBefore, err := dealGetPaymentRemaining(deal, paidUntil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute remaining payment for deal %d", dealID)
+		feeAfter, err := dealGetPaymentRemaining(&amended, paidUntil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute amended payment for deal %d", dealID)
+		if feeDelta := big.Sub(feeAfter, feeBefore); feeDelta.GreaterThan(big.Zero()) {
+			err = msm.maybeLockBalance(deal.Client, feeDelta)
+			builtin.RequireNoErr(rt, err, exitcode.ErrInsufficientFunds, "failed to lock client storage fee for deal %d", dealID)
+			msm.totalClientStorageFee = big.Add(msm.totalClientStorageFee, feeDelta)
+		} else if feeDelta.LessThan(big.Zero()) {
+			err = msm.unlockBalance(deal.Client, feeDelta.Neg(), ClientStorageFee)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock client storage fee for deal %d", dealID)
+		}
+
+		amendedCid, err = msm.replaceDealProposal(dealID, deal, dealCid, &amended, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to amend proposal %d", dealID)
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return &AmendDealProposalReturn{ProposalCid: amendedCid}
+}
+
+// A transfer of the client side of a deal to a new client.
+type DealClientTransfer struct {
+	DealID abi.DealID
+	// CID of the deal's proposal as it stands, binding the transfer to the terms taken on.
+	ProposalCid cid.Cid `checked:"true"` // Prefix checked in TransferDealClient
+	NewClient   addr.Address
+}
+
+type TransferDealClientParams struct {
+	Transfer DealClientTransfer
+	// Signature over the transfer by the new client, agreeing to take on the deal's remaining obligations.
+	Signature crypto.Signature
+}
+
+type TransferDealClientReturn struct {
+	// CID of the proposal naming the new client.
+	ProposalCid cid.Cid
+}
+
+// Reassigns the client side of an active deal from its client, the caller, to a new client.
+// The provider is first paid for the epochs elapsed, and the remaining storage fee and the client collateral are
+// then locked in the new client's escrow and released from the old client's. Data cap spent on a verified deal
+// can't move with it, so verified deals can't be transferred.
+// The deal keeps its ID, but is no longer found by the CID of its original proposal, nor by the old client's labels.
+func (a Actor) TransferDealClient(rt Runtime, params *TransferDealClientParams) *TransferDealClientReturn {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	transfer := params.Transfer
+	dealID := transfer.DealID
+	currEpoch := rt.CurrEpoch()
+	builtin.RequireParam(rt, transfer.ProposalCid.Prefix() == DealProposalCIDPrefix, "proposal CID had wrong prefix")
+
+	newClient, ok := rt.ResolveAddress(transfer.NewClient)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve new client address %v", transfer.NewClient)
+	}
+	signed, err := SigningBytes(SigningDomainDealClientTransfer, &transfer)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to marshal transfer")
+	err = rt.VerifySignature(params.Signature, newClient, signed)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid signature by %v over transfer of deal %d", newClient, dealID)
+
+	var transferredCid cid.Cid
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(WritePermission).
+			withDealStates(WritePermission).withPendingProposals(WritePermission).
+			withEscrowTable(WritePermission).withLockedTable(WritePermission).
+			withSupersededProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		deal, found, err := msm.dealProposals.Get(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no such deal %d", dealID)
+		}
+		if rt.Caller() != deal.Client {
+			rt.Abortf(exitcode.ErrForbidden, "caller %v is not the client of deal %d", rt.Caller(), dealID)
+		}
+		if newClient == deal.Client {
+			rt.Abortf(exitcode.ErrIllegalArgument, "new client %v is already the client of deal %d", newClient, dealID)
+		}
+		dealCid, err := deal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %d", dealID)
+		if !dealCid.Equals(transfer.ProposalCid) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "transfer is of proposal %s, not the current proposal %s of deal %d", transfer.ProposalCid, dealCid, dealID)
+		}
+		if deal.VerifiedDeal {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d is verified", dealID)
+		}
+		if IsDataOnboardingDeal(deal) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d is a data onboarding deal", dealID)
+		}
+
+		state, active, err := msm.dealStates.Get(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", dealID)
+		if !active {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d is not active", dealID)
+		}
+		if state.SlashEpoch != epochUndefined {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d was slashed at %d", dealID, state.SlashEpoch)
+		}
+		if currEpoch >= deal.EndEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d ended at %d", dealID, deal.EndEpoch)
+		}
+
+		// Epochs elapsed are paid for by the old client, and the remainder by the new.
+		paidUntil := deal.StartEpoch
+		if currEpoch > deal.StartEpoch {
+			msm.settleDealPayment(rt, dealID, deal, state, currEpoch)
+			paidUntil = currEpoch
+		}
+		remainingFee, err := dealGetPaymentRemaining(deal, paidUntil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute remaining payment for deal %d", dealID)
+
+		err = msm.maybeLockBalance(newClient, big.Add(remainingFee, deal.ClientCollateral))
+		builtin.RequireNoErr(rt, err, exitcode.ErrInsufficientFunds, "failed to lock new client funds for deal %d", dealID)
+		err = msm.unlockBalance(deal.Client, remainingFee, ClientStorageFee)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock client storage fee for deal %d", dealID)
+		err = msm.unlockBalance(deal.Client, deal.ClientCollateral, ClientCollateral)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock client collateral for deal %d", dealID)
+		// The amounts remain locked, now by the new client.
+		msm.totalClientStorageFee = big.Add(msm.totalClientStorageFee, remainingFee)
+		msm.totalClientLockedCollateral = big.Add(msm.totalClientLockedCollateral, deal.ClientCollateral)
+
+		transferred := *deal
+		transferred.Client = newClient
+		transferredCid, err = msm.replaceDealProposal(dealID, deal, dealCid, &transferred, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to transfer proposal %d", dealID)
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return &TransferDealClientReturn{ProposalCid: transferredCid}
+}
+
+type AddProviderCollateralParams struct {
+	DealID abi.DealID
+	// CID of the deal's proposal as it stands, binding the addition to the terms it amends.
+	ProposalCid cid.Cid `checked:"true"` // Prefix checked in AddProviderCollateral
+	Amount      abi.TokenAmount
+}
+
+type AddProviderCollateralReturn struct {
+	// CID of the proposal with the increased collateral.
+	ProposalCid cid.Cid
+}
+
+// Increases the provider collateral of a deal that is yet to end, locking the amount from the provider's escrow.
+// A provider may do this to meet a minimum collateral raised since the deal was published, before it is activated.
+// The deal keeps its ID, but is no longer found by the CID of its original proposal.
+func (a Actor) AddProviderCollateral(rt Runtime, params *AddProviderCollateralParams) *AddProviderCollateralReturn {
+	dealID := params.DealID
+	currEpoch := rt.CurrEpoch()
+	builtin.RequireParam(rt, params.ProposalCid.Prefix() == DealProposalCIDPrefix, "proposal CID had wrong prefix")
+	builtin.RequireParam(rt, params.Amount.GreaterThan(big.Zero()), "collateral to add %v must be positive", params.Amount)
+
+	var stReadOnly State
+	rt.StateReadonly(&stReadOnly)
+	proposals, err := AsDealProposalArray(adt.AsStore(rt), stReadOnly.Proposals)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal proposals")
+	proposal, found, err := proposals.Get(dealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", dealID)
+	}
+	_, worker, controllers := builtin.RequestMinerControlAddrs(rt, proposal.Provider)
+	rt.ValidateImmediateCallerIs(append(controllers, worker)...)
+
+	var addedCid cid.Cid
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(WritePermission).
+			withDealStates(ReadOnlyPermission).withPendingProposals(WritePermission).
+			withEscrowTable(WritePermission).withLockedTable(WritePermission).
+			withSupersededProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		deal, err := getDealProposal(msm.dealProposals, dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+		dealCid, err := deal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %d", dealID)
+		if !dealCid.Equals(params.ProposalCid) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "addition is to proposal %s, not the current proposal %s of deal %d", params.ProposalCid, dealCid, dealID)
+		}
+		if IsDataOnboardingDeal(deal) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d is a data onboarding deal", dealID)
+		}
+
+		state, active, err := msm.dealStates.Get(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", dealID)
+		if active {
+			if state.SlashEpoch != epochUndefined {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %d was slashed at %d", dealID, state.SlashEpoch)
+			}
+			if currEpoch >= deal.EndEpoch {
+				rt.Abortf(exitcode.ErrIllegalArgument, "deal %d ended at %d", dealID, deal.EndEpoch)
+			}
+		} else if currEpoch > deal.StartEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d was not activated by its start %d", dealID, deal.StartEpoch)
+		}
+
+		added := *deal
+		added.ProviderCollateral = big.Add(deal.ProviderCollateral, params.Amount)
+		// Only the upper bound applies, the collateral having been at least the minimum at publication.
+		_, maxCollateral := DealProviderCollateralBounds(added.PieceSize, added.VerifiedDeal, big.Zero(), big.Zero(), big.Zero(), rt.TotalFilCircSupply())
+		if added.ProviderCollateral.GreaterThan(maxCollateral) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "provider collateral %v exceeds maximum %v", added.ProviderCollateral, maxCollateral)
+		}
+
+		err = msm.maybeLockBalance(deal.Provider, params.Amount)
+		builtin.RequireNoErr(rt, err, exitcode.ErrInsufficientFunds, "failed to lock provider collateral for deal %d", dealID)
+		msm.totalProviderLockedCollateral = big.Add(msm.totalProviderLockedCollateral, params.Amount)
+
+		addedCid, err = msm.replaceDealProposal(dealID, deal, dealCid, &added, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update proposal %d", dealID)
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return &AddProviderCollateralReturn{ProposalCid: addedCid}
+}
+
+// A client's attestation that the provider of a deal failed to serve a retrieval of its piece.
+type RetrievalViolation struct {
+	DealID abi.DealID
+	// CID of the deal's proposal as it stands.
+	ProposalCid cid.Cid `checked:"true"` // Prefix checked in ReportRetrievalViolation
+	// Epoch at which the retrieval failed.
+	Epoch abi.ChainEpoch
+}
+
+type ReportRetrievalViolationParams struct {
+	Violation RetrievalViolation
+	// Signature over the violation by the deal's client, in SigningDomainRetrievalViolation.
+	Signature crypto.Signature
+}
+
+type ReportRetrievalViolationReturn struct {
+	// The last epoch at which the provider may dispute the violation, after which it is penalized.
+	DisputeDeadline abi.ChainEpoch
+}
+
+// Reports a retrieval the provider of an active deal with retrieval terms failed to serve, as attested by the
+// deal's client. Any party may submit the report.
+// The provider may dispute the violation within RetrievalDisputeWindow by presenting the client's receipt for
+// the retrieval. An undisputed violation is penalized when next the deal is reported or updated by cron: the
+// violation penalty, up to the collateral remaining, is slashed from the provider's collateral for the deal and
+// burnt. The deal keeps its ID, but is no longer found by the CID of its original proposal.
+// Only one violation of a deal may await penalty at a time, reported violations must be of successively later
+// retrievals, and the number reported in each RetrievalViolationPeriod is bounded by the availability to which
+// the provider committed.
+func (a Actor) ReportRetrievalViolation(rt Runtime, params *ReportRetrievalViolationParams) *ReportRetrievalViolationReturn {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	violation := params.Violation
+	dealID := violation.DealID
+	currEpoch := rt.CurrEpoch()
+	builtin.RequireParam(rt, violation.ProposalCid.Prefix() == DealProposalCIDPrefix, "proposal CID had wrong prefix")
+
+	var stReadOnly State
+	rt.StateReadonly(&stReadOnly)
+	proposals, err := AsDealProposalArray(adt.AsStore(rt), stReadOnly.Proposals)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal proposals")
+	proposal, found, err := proposals.Get(dealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", dealID)
+	}
+	signed, err := SigningBytes(SigningDomainRetrievalViolation, &violation)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to marshal violation")
+	err = rt.VerifySignature(params.Signature, proposal.Client, signed)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid signature by %v over violation of deal %d", proposal.Client, dealID)
 
-	// Update deal dealStates.
+	penalty := big.Zero()
+	var st State
 	rt.StateTransaction(&st, func() {
-		_, _, _, err := ValidateDealsForActivation(&st, store, params.DealIDs, minerAddr, params.SectorExpiry, currEpoch)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to validate dealProposals for activation")
-
-		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
-			withPendingProposals(ReadOnlyPermission).withDealProposals(ReadOnlyPermission).build()
+		msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(WritePermission).
+			withDealStates(ReadOnlyPermission).withPendingProposals(WritePermission).
+			withEscrowTable(WritePermission).withLockedTable(WritePermission).
+			withRetrievalViolations(WritePermission).withSupersededProposals(WritePermission).build()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
 
-		for _, dealID := range params.DealIDs {
-			// This construction could be replaced with a single "update deal state" state method, possibly batched
-			// over all deal ids at once.
-			_, found, err := msm.dealStates.Get(dealID)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get state for dealId %d", dealID)
-			if found {
-				rt.Abortf(exitcode.ErrIllegalArgument, "deal %d already included in another sector", dealID)
-			}
-
-			proposal, err := getDealProposal(msm.dealProposals, dealID)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get dealId %d", dealID)
-
-			propc, err := proposal.Cid()
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate proposal CID")
+		deal, err := getDealProposal(msm.dealProposals, dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+		dealCid, err := deal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %d", dealID)
+		if !dealCid.Equals(violation.ProposalCid) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "violation is of proposal %s, not the current proposal %s of deal %d", violation.ProposalCid, dealCid, dealID)
+		}
+		if deal.RetrievalSLA == nil {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d has no retrieval terms", dealID)
+		}
 
-			has, err := msm.pendingDeals.Has(abi.CidKey(propc))
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get pending proposal %v", propc)
+		state, active, err := msm.dealStates.Get(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", dealID)
+		if !active {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d is not active", dealID)
+		}
+		if state.SlashEpoch != epochUndefined {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d was slashed at %d", dealID, state.SlashEpoch)
+		}
+		if violation.Epoch < state.SectorStartEpoch || violation.Epoch >= deal.EndEpoch || violation.Epoch > currEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "violation epoch %d is outside the active term of deal %d", violation.Epoch, dealID)
+		}
 
-			if !has {
-				rt.Abortf(exitcode.ErrIllegalState, "tried to activate deal that was not in the pending set (%s)", propc)
-			}
+		penalty, deal, err = msm.penalizeRetrievalViolation(dealID, deal, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to penalize retrieval violation of deal %d", dealID)
+		if deal.ProviderCollateral.IsZero() {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d has no provider collateral remaining", dealID)
+		}
 
-			err = msm.dealStates.Set(dealID, &DealState{
-				SectorStartEpoch: currEpoch,
-				LastUpdatedEpoch: epochUndefined,
-				SlashEpoch:       epochUndefined,
-			})
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state %d", dealID)
+		violations := RetrievalViolations{
+			PeriodStart:        currEpoch,
+			PeriodReports:      0,
+			LastEpoch:          epochUndefined,
+			PendingReportEpoch: epochUndefined,
+		}
+		_, err = msm.retrievalViolations.Get(abi.UIntKey(uint64(dealID)), &violations)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get retrieval violations of deal %d", dealID)
+		if violations.PendingReportEpoch != epochUndefined {
+			rt.Abortf(exitcode.ErrForbidden, "violation of deal %d reported at %d awaits dispute until %d",
+				dealID, violations.PendingReportEpoch, violations.PendingReportEpoch+RetrievalDisputeWindow)
+		}
+		if violation.Epoch <= violations.LastEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "violation epoch %d must be after that of the last violation reported %d",
+				violation.Epoch, violations.LastEpoch)
+		}
+		if currEpoch >= violations.PeriodStart+RetrievalViolationPeriod {
+			violations.PeriodStart = currEpoch
+			violations.PeriodReports = 0
 		}
+		if maxReports := RetrievalViolationsPerPeriodMax(deal.RetrievalAvailability()); violations.PeriodReports >= maxReports {
+			rt.Abortf(exitcode.ErrForbidden, "deal %d already has %d violations reported in the period from %d, the most its availability allows",
+				dealID, violations.PeriodReports, violations.PeriodStart)
+		}
+		violations.PeriodReports++
+		violations.LastEpoch = violation.Epoch
+		violations.PendingReportEpoch = currEpoch
+		err = msm.retrievalViolations.Put(abi.UIntKey(uint64(dealID)), &violations)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put retrieval violations of deal %d", dealID)
 
 		err = msm.commitState()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
 	})
 
-	return nil
+	if !penalty.IsZero() {
+		code := rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, penalty, &builtin.Discard{})
+		builtin.RequireSuccess(rt, code, "failed to burn retrieval violation penalty")
+	}
+	return &ReportRetrievalViolationReturn{DisputeDeadline: currEpoch + RetrievalDisputeWindow}
 }
 
-//type SectorDataSpec struct {
-//	DealIDs    []abi.DealID
-//	SectorType abi.RegisteredSealProof
-//}
-type SectorDataSpec = market5.SectorDataSpec
+// A client's receipt for a retrieval of a deal's piece that the provider served.
+type RetrievalReceipt struct {
+	DealID abi.DealID
+	// Epoch at which the retrieval was served.
+	Epoch abi.ChainEpoch
+}
 
-//type ComputeDataCommitmentParams struct {
-//	Inputs []*SectorDataSpec
-//}
-type ComputeDataCommitmentParams = market5.ComputeDataCommitmentParams
+type DisputeRetrievalViolationParams struct {
+	Receipt RetrievalReceipt
+	// Signature over the receipt by the deal's client, in SigningDomainRetrievalReceipt.
+	Signature crypto.Signature
+}
 
-//type ComputeDataCommitmentReturn struct {
-//	CommDs []cbg.CborCid
-//}
-type ComputeDataCommitmentReturn = market5.ComputeDataCommitmentReturn
+// Disputes the violation of a deal awaiting penalty with the client's receipt for the retrieval reported failed,
+// dismissing the violation. Only the deal's provider may dispute, within RetrievalDisputeWindow of the report.
+func (a Actor) DisputeRetrievalViolation(rt Runtime, params *DisputeRetrievalViolationParams) *abi.EmptyValue {
+	receipt := params.Receipt
+	dealID := receipt.DealID
+	currEpoch := rt.CurrEpoch()
 
-func (a Actor) ComputeDataCommitment(rt Runtime, params *ComputeDataCommitmentParams) *ComputeDataCommitmentReturn {
-	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	var stReadOnly State
+	rt.StateReadonly(&stReadOnly)
+	proposals, err := AsDealProposalArray(adt.AsStore(rt), stReadOnly.Proposals)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal proposals")
+	proposal, found, err := proposals.Get(dealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such deal %d", dealID)
+	}
+	_, worker, controllers := builtin.RequestMinerControlAddrs(rt, proposal.Provider)
+	rt.ValidateImmediateCallerIs(append(controllers, worker)...)
+
+	signed, err := SigningBytes(SigningDomainRetrievalReceipt, &receipt)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to marshal receipt")
+	err = rt.VerifySignature(params.Signature, proposal.Client, signed)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid signature by %v over receipt for deal %d", proposal.Client, dealID)
 
 	var st State
-	rt.StateReadonly(&st)
-	proposals, err := AsDealProposalArray(adt.AsStore(rt), st.Proposals)
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal dealProposals")
-	commDs := make([]cbg.CborCid, len(params.Inputs))
-	for i, commInput := range params.Inputs {
-		pieces := make([]abi.PieceInfo, 0)
-		for _, dealID := range commInput.DealIDs {
-			deal, err := getDealProposal(proposals, dealID)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get dealId %d", dealID)
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withRetrievalViolations(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
 
-			pieces = append(pieces, abi.PieceInfo{
-				PieceCID: deal.PieceCID,
-				Size:     deal.PieceSize,
-			})
+		var violations RetrievalViolations
+		found, err := msm.retrievalViolations.Get(abi.UIntKey(uint64(dealID)), &violations)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get retrieval violations of deal %d", dealID)
+		if !found || violations.PendingReportEpoch == epochUndefined {
+			rt.Abortf(exitcode.ErrNotFound, "no violation of deal %d awaits dispute", dealID)
 		}
-		commD, err := rt.ComputeUnsealedSectorCID(commInput.SectorType, pieces)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to compute unsealed sectorCID: %s", err)
-		commDs[i] = (cbg.CborCid)(commD)
+		if currEpoch > violations.PendingReportEpoch+RetrievalDisputeWindow {
+			rt.Abortf(exitcode.ErrForbidden, "dispute window for violation of deal %d closed at %d",
+				dealID, violations.PendingReportEpoch+RetrievalDisputeWindow)
+		}
+		if receipt.Epoch != violations.LastEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "receipt is for retrieval at %d, not the violation at %d", receipt.Epoch, violations.LastEpoch)
+		}
+
+		violations.PendingReportEpoch = epochUndefined
+		err = msm.retrievalViolations.Put(abi.UIntKey(uint64(dealID)), &violations)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put retrieval violations of deal %d", dealID)
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+func isControllerOrWorker(a addr.Address, worker addr.Address, controllers []addr.Address) bool {
+	if a == worker {
+		return true
 	}
-	return &ComputeDataCommitmentReturn{
-		CommDs: commDs,
+	for _, c := range controllers {
+		if a == c {
+			return true
+		}
 	}
+	return false
 }
 
-//type OnMinerSectorsTerminateParams struct {
-//	Epoch   abi.ChainEpoch
-//	DealIDs []abi.DealID
-//}
-type OnMinerSectorsTerminateParams = market0.OnMinerSectorsTerminateParams
+type TerminateBreachedDealParams struct {
+	DealID       abi.DealID
+	SectorNumber abi.SectorNumber // The provider's sector holding the deal.
+}
 
-// Terminate a set of deals in response to their containing sector being terminated.
-// Slash provider collateral, refund client collateral, and refund partial unpaid escrow
-// amount to client.
-func (a Actor) OnMinerSectorsTerminate(rt Runtime, params *OnMinerSectorsTerminateParams) *abi.EmptyValue {
-	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
-	minerAddr := rt.Caller()
+// Has the sector holding a deal terminated for the provider's breach of the deal, at the request of the deal's client.
+// The provider's miner actor checks that the sector has been faulty for long enough to be in breach, and the deal is
+// then slashed as for any other termination. Its provider collateral is paid to the client, rather than burnt.
+func (a Actor) TerminateBreachedDeal(rt Runtime, params *TerminateBreachedDealParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	client := rt.Caller()
+	dealID := params.DealID
+	currEpoch := rt.CurrEpoch()
 
+	var provider addr.Address
 	var st State
 	rt.StateTransaction(&st, func() {
-		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
-			withDealProposals(ReadOnlyPermission).build()
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deal state")
-
-		for _, dealID := range params.DealIDs {
-			deal, found, err := msm.dealProposals.Get(dealID)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %v", dealID)
-			// The deal may have expired and been deleted before the sector is terminated.
-			// Log the dealID for the dealProposal and continue execution for other deals
-			if !found {
-				rt.Log(rtt.INFO, "couldn't find deal %d", dealID)
-				continue
-			}
-			builtin.RequireState(rt, deal.Provider == minerAddr, "caller %v is not the provider %v of deal %v",
-				minerAddr, deal.Provider, dealID)
-
-			// do not slash expired deals
-			if deal.EndEpoch <= params.Epoch {
-				rt.Log(rtt.INFO, "deal %d expired, not slashing", dealID)
-				continue
-			}
-
-			state, found, err := msm.dealStates.Get(dealID)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %v", dealID)
-			if !found {
-				// A deal with a proposal but no state is not activated, but then it should not be
-				// part of a sector that is terminating.
-				rt.Abortf(exitcode.ErrIllegalArgument, "no state for deal %v", dealID)
-			}
-
-			// if a deal is already slashed, we don't need to do anything here.
-			if state.SlashEpoch != epochUndefined {
-				rt.Log(rtt.INFO, "deal %d already slashed", dealID)
-				continue
-			}
-
-			// mark the deal for slashing here.
-			// actual releasing of locked funds for the client and slashing of provider collateral happens in CronTick.
-			state.SlashEpoch = params.Epoch
+		msm, err := st.mutator(adt.AsStore(rt)).withDealProposals(ReadOnlyPermission).
+			withDealStates(ReadOnlyPermission).withBreachedDeals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
 
-			err = msm.dealStates.Set(dealID, state)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state %v", dealID)
+		proposal, found, err := msm.dealProposals.Get(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %d", dealID)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no such deal %d", dealID)
+		}
+		if proposal.Client != client {
+			rt.Abortf(exitcode.ErrForbidden, "caller %v is not the client of deal %d", client, dealID)
+		}
+		state, found, err := msm.dealStates.Get(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %d", dealID)
+		if !found {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d is not active", dealID)
+		}
+		if state.SlashEpoch != epochUndefined {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d was slashed at %d", dealID, state.SlashEpoch)
+		}
+		if proposal.EndEpoch <= currEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal %d ended at %d", dealID, proposal.EndEpoch)
 		}
 
+		err = msm.breachedDeals.Put(abi.UIntKey(uint64(dealID)))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record breach of deal %d", dealID)
 		err = msm.commitState()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+		provider = proposal.Provider
 	})
+
+	code := rt.Send(
+		provider,
+		builtin.MethodsMiner.TerminateBreachedSector,
+		&builtin.TerminateBreachedSectorParams{
+			SectorNumber: params.SectorNumber,
+			DealID:       dealID,
+		},
+		big.Zero(),
+		&builtin.Discard{},
+	)
+	builtin.RequireSuccess(rt, code, "failed to terminate sector %d of provider %v", params.SectorNumber, provider)
 	return nil
 }
 
 func (a Actor) CronTick(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 	rt.ValidateImmediateCallerIs(builtin.CronActorAddr)
-	amountSlashed := big.Zero()
+	burns := cronBurns{TimeoutPenalties: big.Zero(), TerminationSlashes: big.Zero(), RetrievalPenalties: big.Zero()}
 
 	var timedOutVerifiedDeals []*DealProposal
 
 	var st State
 	rt.StateTransaction(&st, func() {
-		updatesNeeded := make(map[abi.ChainEpoch][]abi.DealID)
+		updatesNeeded := make(map[abi.ChainEpoch]*providerDealOps)
 
 		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
 			withLockedTable(WritePermission).withEscrowTable(WritePermission).withDealsByEpoch(WritePermission).
-			withDealProposals(WritePermission).withPendingProposals(WritePermission).build()
+			withDealProposals(WritePermission).withPendingProposals(WritePermission).
+			withDealAllocations(WritePermission).withBreachedDeals(WritePermission).
+			withRetrievalViolations(WritePermission).withSupersededProposals(WritePermission).
+			withActiveDeals(WritePermission).build()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
 
-		for i := st.LastCron + 1; i <= rt.CurrEpoch(); i++ {
-			err = msm.dealsByEpoch.ForEach(i, func(dealID abi.DealID) error {
-				deal, err := getDealProposal(msm.dealProposals, dealID)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get dealId %d", dealID)
-
-				dcid, err := deal.Cid()
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %v", dealID)
+		processDealUpdate := func(dealID abi.DealID) {
+			deal, err := getDealProposal(msm.dealProposals, dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get dealId %d", dealID)
 
-				state, found, err := msm.dealStates.Get(dealID)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state")
+			dcid, err := deal.Cid()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %v", dealID)
 
-				// deal has been published but not activated yet -> terminate it as it has timed out
-				if !found {
-					// Not yet appeared in proven sector; check for timeout.
-					builtin.RequireState(rt, rt.CurrEpoch() >= deal.StartEpoch, "deal %d processed before start epoch %d",
-						dealID, deal.StartEpoch)
+			state, found, err := msm.dealStates.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state")
 
-					slashed := msm.processDealInitTimedOut(rt, deal)
-					if !slashed.IsZero() {
-						amountSlashed = big.Add(amountSlashed, slashed)
-					}
-					if deal.VerifiedDeal {
+			// deal has been published but not activated yet -> terminate it as it has timed out
+			if !found {
+				// Not yet appeared in proven sector; check for timeout.
+				builtin.RequireState(rt, rt.CurrEpoch() >= deal.StartEpoch, "deal %d processed before start epoch %d",
+					dealID, deal.StartEpoch)
+
+				slashed := msm.processDealInitTimedOut(rt, deal)
+				burns.TimeoutPenalties = big.Add(burns.TimeoutPenalties, slashed)
+				if deal.VerifiedDeal {
+					// The client recovers the DataCap of a deal's expired allocation by removing it from the
+					// verified registry, where it finds the allocation's ID with GetClientAllocations once the
+					// deal's record of it is removed here. DataCap used by a deal with no allocation is restored here.
+					_, allocated, err := msm.popDealAllocation(dealID)
+					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove allocation for deal %d", dealID)
+					if !allocated {
 						timedOutVerifiedDeals = append(timedOutVerifiedDeals, deal)
 					}
-
-					// Delete the proposal (but not state, which doesn't exist).
-					err = msm.dealProposals.Delete(dealID)
-					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal proposal %d", dealID)
-
-					err = msm.pendingDeals.Delete(abi.CidKey(dcid))
-					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete pending proposal %d (%v)", dealID, dcid)
-					return nil
 				}
 
-				// if this is the first cron tick for the deal, it should be in the pending state.
-				if state.LastUpdatedEpoch == epochUndefined {
-					pdErr := msm.pendingDeals.Delete(abi.CidKey(dcid))
-					builtin.RequireNoErr(rt, pdErr, exitcode.ErrIllegalState, "failed to delete pending proposal %v", dcid)
-				}
+				// Delete the proposal (but not state, which doesn't exist).
+				err = msm.dealProposals.Delete(dealID)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal proposal %d", dealID)
 
-				slashAmount, nextEpoch, removeDeal := msm.updatePendingDealState(rt, state, deal, rt.CurrEpoch())
-				builtin.RequireState(rt, slashAmount.GreaterThanEqual(big.Zero()), "computed negative slash amount %v for deal %d", slashAmount, dealID)
+				err = msm.pendingDeals.Delete(abi.CidKey(dcid))
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete pending proposal %d (%v)", dealID, dcid)
+				return
+			}
 
-				if removeDeal {
-					builtin.RequireState(rt, nextEpoch == epochUndefined, "removed deal %d should have no scheduled epoch (got %d)", dealID, nextEpoch)
-					amountSlashed = big.Add(amountSlashed, slashAmount)
+			// if this is the first cron tick for the deal, it should be in the pending state.
+			if state.LastUpdatedEpoch == epochUndefined {
+				pdErr := msm.pendingDeals.Delete(abi.CidKey(dcid))
+				builtin.RequireNoErr(rt, pdErr, exitcode.ErrIllegalState, "failed to delete pending proposal %v", dcid)
+			}
 
-					// Delete proposal and state simultaneously.
-					err = msm.dealStates.Delete(dealID)
-					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal state %d", dealID)
-					err = msm.dealProposals.Delete(dealID)
-					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal proposal %d", dealID)
+			// A retrieval violation left undisputed is penalized before the deal is settled.
+			penalty, deal, err := msm.penalizeRetrievalViolation(dealID, deal, rt.CurrEpoch())
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to penalize retrieval violation of deal %d", dealID)
+			burns.RetrievalPenalties = big.Add(burns.RetrievalPenalties, penalty)
+
+			slashAmount, nextEpoch, removeDeal := msm.updatePendingDealState(rt, state, deal, rt.CurrEpoch())
+			builtin.RequireState(rt, slashAmount.GreaterThanEqual(big.Zero()), "computed negative slash amount %v for deal %d", slashAmount, dealID)
+
+			if removeDeal {
+				builtin.RequireState(rt, nextEpoch == epochUndefined, "removed deal %d should have no scheduled epoch (got %d)", dealID, nextEpoch)
+				breached, err := msm.breachedDeals.TryDelete(abi.UIntKey(uint64(dealID)))
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check breach of deal %d", dealID)
+				if breached {
+					// Compensate the client of a breached deal with the slashed collateral.
+					err = msm.escrowTable.Add(deal.Client, slashAmount)
+					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to pay slashed collateral of deal %d to client", dealID)
 				} else {
-					builtin.RequireState(rt, nextEpoch > rt.CurrEpoch(), "continuing deal %d next epoch %d should be in future", dealID, nextEpoch)
-					builtin.RequireState(rt, slashAmount.IsZero(), "continuing deal %d should not be slashed", dealID)
+					burns.TerminationSlashes = big.Add(burns.TerminationSlashes, slashAmount)
+				}
 
-					// Update deal's LastUpdatedEpoch in DealStates
-					state.LastUpdatedEpoch = rt.CurrEpoch()
-					err = msm.dealStates.Set(dealID, state)
-					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state")
+				// Delete proposal and state simultaneously.
+				_, err = msm.retrievalViolations.TryDelete(abi.UIntKey(uint64(dealID)))
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete retrieval violations of deal %d", dealID)
+				err = msm.dealStates.Delete(dealID)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal state %d", dealID)
+				err = msm.removeActiveDeal(dealID)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove active deal %d", dealID)
+				err = msm.dealProposals.Delete(dealID)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal proposal %d", dealID)
+			} else {
+				builtin.RequireState(rt, nextEpoch > rt.CurrEpoch(), "continuing deal %d next epoch %d should be in future", dealID, nextEpoch)
+				builtin.RequireState(rt, slashAmount.IsZero(), "continuing deal %d should not be slashed", dealID)
+
+				// Update deal's LastUpdatedEpoch in DealStates
+				state.LastUpdatedEpoch = rt.CurrEpoch()
+				err = msm.dealStates.Set(dealID, state)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state")
+
+				scheduleDealOp(updatesNeeded, nextEpoch, deal.Provider, dealID)
+			}
+		}
 
-					updatesNeeded[nextEpoch] = append(updatesNeeded[nextEpoch], dealID)
+		// Epochs are processed in order until the limit on deal updates is reached, and the deals of each epoch
+		// provider by provider. LastCron records the last epoch processed in full, and the deals of an epoch cut short
+		// by the limit are removed from it as they're processed, so that the next tick continues where this one stopped.
+		remaining := MaxDealUpdatesPerCronTick
+		for i := st.LastCron + 1; i <= rt.CurrEpoch() && remaining > 0; i++ {
+			ops := newProviderDealOps()
+			count := 0
+			err = msm.dealsByEpoch.ForEach(i, func(provider addr.Address, dealID abi.DealID) error {
+				if count == remaining {
+					return errCronLimitReached
 				}
-
+				ops.add(provider, dealID)
+				count++
 				return nil
 			})
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate deal ops")
+			if err != nil && err != errCronLimitReached {
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate deal ops")
+			}
+			complete := err == nil
+
+			for _, provider := range ops.providers {
+				for _, dealID := range ops.deals[provider] {
+					processDealUpdate(dealID)
+				}
+			}
+			remaining -= count
 
+			if !complete {
+				for _, provider := range ops.providers {
+					err = msm.dealsByEpoch.RemoveMany(i, provider, ops.deals[provider])
+					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal ops for epoch %v provider %v", i, provider)
+				}
+				break
+			}
 			err = msm.dealsByEpoch.RemoveAll(i)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal ops for epoch %v", i)
+			st.LastCron = i
 		}
 
 		// Iterate changes in sorted order to ensure that loads/stores
@@ -675,42 +2707,108 @@ func (a Actor) CronTick(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 		sort.Slice(changedEpochs, func(i, j int) bool { return changedEpochs[i] < changedEpochs[j] })
 
 		for _, epoch := range changedEpochs {
-			err = msm.dealsByEpoch.PutMany(epoch, updatesNeeded[epoch])
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to reinsert deal IDs for epoch %v", epoch)
+			ops := updatesNeeded[epoch]
+			for _, provider := range ops.providers {
+				err = msm.dealsByEpoch.PutMany(epoch, provider, ops.deals[provider])
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to reinsert deal IDs for epoch %v provider %v", epoch, provider)
+			}
 		}
 
-		st.LastCron = rt.CurrEpoch()
-
 		err = msm.commitState()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
 	})
 
-	for _, d := range timedOutVerifiedDeals {
-		code := rt.Send(
-			builtin.VerifiedRegistryActorAddr,
-			builtin.MethodsVerifiedRegistry.RestoreBytes,
-			&verifreg.RestoreBytesParams{
-				Address:  d.Client,
-				DealSize: big.NewIntUnsigned(uint64(d.PieceSize)),
-			},
-			abi.NewTokenAmount(0),
-			&builtin.Discard{},
-		)
-
-		if !code.IsSuccess() {
-			rt.Log(rtt.ERROR, "failed to send RestoreBytes call to the VerifReg actor for timed-out verified deal, client: %s, dealSize: %v, "+
-				"provider: %v, got code %v", d.Client, d.PieceSize, d.Provider, code)
-		}
-	}
+	restoreTimedOutVerifiedDeals(rt, timedOutVerifiedDeals)
 
-	if !amountSlashed.IsZero() {
-		e := rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, amountSlashed, &builtin.Discard{})
+	if total := burns.Total(); !total.IsZero() {
+		rt.Log(rtt.DEBUG, "burning %v: %v for timed out deals, %v for terminated deals, %v for retrieval violations", total,
+			burns.TimeoutPenalties, burns.TerminationSlashes, burns.RetrievalPenalties)
+		e := rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, total, &builtin.Discard{})
 		builtin.RequireSuccess(rt, e, "expected send to burnt funds actor to succeed")
 	}
 
 	return nil
 }
 
+// Halts collection of an epoch's deal updates once a cron tick's limit is reached.
+var errCronLimitReached = xerrors.New("cron tick deal update limit reached")
+
+// Deal ops for an epoch, grouped by provider.
+// Providers are kept in the order they are first added, so that iteration over them is deterministic.
+type providerDealOps struct {
+	providers []addr.Address
+	deals     map[addr.Address][]abi.DealID
+}
+
+func newProviderDealOps() *providerDealOps {
+	return &providerDealOps{deals: make(map[addr.Address][]abi.DealID)}
+}
+
+func (o *providerDealOps) add(provider addr.Address, dealID abi.DealID) {
+	if _, ok := o.deals[provider]; !ok {
+		o.providers = append(o.providers, provider)
+	}
+	o.deals[provider] = append(o.deals[provider], dealID)
+}
+
+// Records a deal op to be scheduled for an epoch.
+func scheduleDealOp(ops map[abi.ChainEpoch]*providerDealOps, epoch abi.ChainEpoch, provider addr.Address, dealID abi.DealID) {
+	if _, ok := ops[epoch]; !ok {
+		ops[epoch] = newProviderDealOps()
+	}
+	ops[epoch].add(provider, dealID)
+}
+
+// Amounts forfeited by deals processed in a cron tick, burnt together at its end.
+type cronBurns struct {
+	// Provider collateral forfeited by deals that were not activated by their start epoch.
+	TimeoutPenalties abi.TokenAmount
+	// Collateral slashed from deals whose sectors were terminated.
+	TerminationSlashes abi.TokenAmount
+	// Provider collateral slashed for undisputed retrieval violations.
+	RetrievalPenalties abi.TokenAmount
+}
+
+func (b *cronBurns) Total() abi.TokenAmount {
+	return big.Sum(b.TimeoutPenalties, b.TerminationSlashes, b.RetrievalPenalties)
+}
+
+// Restores the data cap of the clients of timed-out verified deals with a single call to the
+// verified registry. A failure to restore is logged, but doesn't abort the tick.
+func restoreTimedOutVerifiedDeals(rt Runtime, deals []*DealProposal) {
+	if len(deals) == 0 {
+		return
+	}
+	params := verifreg.RestoreBytesBatchParams{Restorations: make([]verifreg.RestoreBytesParams, len(deals))}
+	for i, d := range deals {
+		params.Restorations[i] = verifreg.RestoreBytesParams{
+			Address:  d.Client,
+			DealSize: big.NewIntUnsigned(uint64(d.PieceSize)),
+		}
+	}
+
+	var ret verifreg.RestoreBytesBatchReturn
+	code := rt.Send(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RestoreBytesBatch, &params,
+		abi.NewTokenAmount(0), &ret)
+	if !code.IsSuccess() {
+		rt.Log(rtt.ERROR, "failed to send RestoreBytesBatch call to the VerifReg actor for %d timed-out verified deals, got code %v",
+			len(deals), code)
+		return
+	}
+	for _, f := range ret.Failures {
+		if f.Index >= uint64(len(deals)) {
+			rt.Log(rtt.ERROR, "VerifReg actor reported failure for restoration %d of %d", f.Index, len(deals))
+			continue
+		}
+		d := deals[f.Index]
+		rt.Log(rtt.ERROR, "failed to restore data cap for timed-out verified deal, client: %s, dealSize: %v, "+
+			"provider: %v, got code %v", d.Client, d.PieceSize, d.Provider, f.Code)
+	}
+}
+
+// Returns the epoch at which a deal is first queued for processing by cron: the first epoch at or after its start
+// epoch whose offset into a DealUpdatesInterval-epoch period is the deal ID modulo the period. Spreading deals
+// across the period prevents an attacker from scheduling too many deals for the same tick.
 func GenRandNextEpoch(startEpoch abi.ChainEpoch, dealID abi.DealID) abi.ChainEpoch {
 	offset := abi.ChainEpoch(uint64(dealID) % uint64(DealUpdatesInterval))
 	q := builtin.NewQuantSpec(DealUpdatesInterval, 0)
@@ -793,15 +2891,19 @@ func validateDealCanActivate(proposal *DealProposal, minerAddr addr.Address, sec
 	return nil
 }
 
-func validateDeal(rt Runtime, deal ClientDealProposal, networkRawPower, networkQAPower, baselinePower abi.StoragePower) error {
-	if err := dealProposalIsInternallyValid(rt, deal); err != nil {
-		return xerrors.Errorf("Invalid deal proposal %w", err)
+// The client's signature is checked only if verifySignature is set. A client actor authorizes its deals
+// when published instead, and an aggregate signature is checked for a whole batch of deals.
+func validateDeal(rt Runtime, deal ClientDealProposal, verifySignature bool, networkRawPower, networkQAPower, baselinePower abi.StoragePower) error {
+	if verifySignature {
+		if err := dealProposalIsInternallyValid(rt, deal); err != nil {
+			return xerrors.Errorf("Invalid deal proposal %w", err)
+		}
 	}
 
 	proposal := deal.Proposal
 
-	if len(proposal.Label) > DealMaxLabelSize {
-		return xerrors.Errorf("deal label can be at most %d bytes, is %d", DealMaxLabelSize, len(proposal.Label))
+	if proposal.Label.Length() > DealMaxLabelSize {
+		return xerrors.Errorf("deal label can be at most %d bytes, is %d", DealMaxLabelSize, proposal.Label.Length())
 	}
 
 	if err := proposal.PieceSize.Validate(); err != nil {
@@ -836,6 +2938,9 @@ func validateDeal(rt Runtime, deal ClientDealProposal, networkRawPower, networkQ
 
 	minProviderCollateral, maxProviderCollateral := DealProviderCollateralBounds(proposal.PieceSize, proposal.VerifiedDeal,
 		networkRawPower, networkQAPower, baselinePower, rt.TotalFilCircSupply())
+	if DataOnboardingDealsEnabled && IsDataOnboardingDeal(&proposal) {
+		minProviderCollateral = big.Zero()
+	}
 	if proposal.ProviderCollateral.LessThan(minProviderCollateral) || proposal.ProviderCollateral.GreaterThan(maxProviderCollateral) {
 		return xerrors.Errorf("Provider collateral out of bounds")
 	}
@@ -844,6 +2949,15 @@ func validateDeal(rt Runtime, deal ClientDealProposal, networkRawPower, networkQ
 	if proposal.ClientCollateral.LessThan(minClientCollateral) || proposal.ClientCollateral.GreaterThan(maxClientCollateral) {
 		return xerrors.Errorf("Client collateral out of bounds")
 	}
+
+	if sla := proposal.RetrievalSLA; sla != nil {
+		if sla.Availability == 0 || sla.Availability > RetrievalAvailabilityDenominator {
+			return xerrors.Errorf("retrieval availability %d out of bounds", sla.Availability)
+		}
+		if sla.ViolationPenalty.LessThanEqual(big.Zero()) || sla.ViolationPenalty.GreaterThan(proposal.ProviderCollateral) {
+			return xerrors.Errorf("retrieval violation penalty %v out of bounds", sla.ViolationPenalty)
+		}
+	}
 	return nil
 }
 
@@ -855,15 +2969,8 @@ func validateDeal(rt Runtime, deal ClientDealProposal, networkRawPower, networkQ
 // the designated recipient address of withdrawals (which is the same, for simple account parties).
 func escrowAddress(rt Runtime, address addr.Address) (nominal addr.Address, recipient addr.Address, approved []addr.Address) {
 	// Resolve the provided address to the canonical form against which the balance is held.
-	nominal, ok := rt.ResolveAddress(address)
-	if !ok {
-		rt.Abortf(exitcode.ErrIllegalArgument, "failed to resolve address %v", address)
-	}
-
-	codeID, ok := rt.GetActorCodeCID(nominal)
-	if !ok {
-		rt.Abortf(exitcode.ErrIllegalArgument, "no code for address %v", nominal)
-	}
+	nominal, codeID, err := builtin.ResolveActorAddress(rt, address)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to resolve escrow address %v", address)
 
 	if codeID.Equals(builtin.StorageMinerActorCodeID) {
 		// Storage miner actor entry; implied funds recipient is the associated owner address.
@@ -874,6 +2981,24 @@ func escrowAddress(rt Runtime, address addr.Address) (nominal addr.Address, reci
 	return nominal, nominal, []addr.Address{nominal}
 }
 
+// Resolves a provider address, checking that it is a miner actor and that the caller
+// is one of its worker or control addresses. Returns the resolved address.
+func validateProviderControlCaller(rt Runtime, providerRaw addr.Address) addr.Address {
+	provider, ok := rt.ResolveAddress(providerRaw)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve provider address %v", providerRaw)
+	}
+	codeID, ok := rt.GetActorCodeCID(provider)
+	builtin.RequireParam(rt, ok, "no codeId for address %v", provider)
+	if !codeID.Equals(builtin.StorageMinerActorCodeID) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "provider %v is not a StorageMinerActor", provider)
+	}
+
+	_, worker, controllers := builtin.RequestMinerControlAddrs(rt, provider)
+	rt.ValidateImmediateCallerIs(append(controllers, worker)...)
+	return provider
+}
+
 func getDealProposal(proposals *DealArray, dealID abi.DealID) (*DealProposal, error) {
 	proposal, found, err := proposals.Get(dealID)
 	if err != nil {
@@ -886,6 +3011,101 @@ func getDealProposal(proposals *DealArray, dealID abi.DealID) (*DealProposal, er
 	return proposal, nil
 }
 
+// Requests funds to cover a shortfall in a client's escrow from the client's escrow funder, if any.
+// The request is charged against the funder's allowance for the epoch before it is sent, so that
+// re-entrant publications cannot exceed the allowance. Any part of the request that does not reach
+// the client's escrow, because the request failed or the funder paid less, is then restored to the
+// allowance.
+func requestEscrowFunds(rt Runtime, client addr.Address, amountToLock abi.TokenAmount) {
+	var funder addr.Address
+	request := big.Zero()
+	escrowBefore := big.Zero()
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withEscrowTable(ReadOnlyPermission).
+			withLockedTable(ReadOnlyPermission).withEscrowFunders(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		escrowFunder, found, err := msm.getEscrowFunder(client)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load escrow funder")
+		if !found {
+			return
+		}
+		shortfall, err := msm.balanceShortfall(client, amountToLock)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute client balance shortfall")
+		request = big.Min(shortfall, escrowFunder.Allowance(rt.CurrEpoch()))
+		if !request.GreaterThan(big.Zero()) {
+			return
+		}
+
+		escrowBefore, err = msm.escrowTable.Get(client)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get escrow balance for %v", client)
+		funder = escrowFunder.Funder
+		escrowFunder.recordRequest(rt.CurrEpoch(), request)
+		err = msm.escrowFunders.Put(abi.AddrKey(client), escrowFunder)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set escrow funder for %v", client)
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	if !request.GreaterThan(big.Zero()) {
+		return
+	}
+
+	code := rt.Send(funder, MethodRequestEscrowFunds, &RequestEscrowFundsParams{Client: client, Amount: request}, big.Zero(), &builtin.Discard{})
+	if !code.IsSuccess() {
+		rt.Log(rtt.INFO, "failed to request %v escrow funds for client %v from funder %v, exitcode: %d", request, client, funder, code)
+	}
+
+	funded := big.Zero()
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withEscrowTable(ReadOnlyPermission).
+			withEscrowFunders(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		escrowAfter, err := msm.escrowTable.Get(client)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get escrow balance for %v", client)
+		funded = big.Min(big.Max(big.Sub(escrowAfter, escrowBefore), big.Zero()), request)
+		if funded.Equals(request) {
+			return
+		}
+
+		// The funder may have been removed or replaced while handling the request.
+		escrowFunder, found, err := msm.getEscrowFunder(client)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load escrow funder")
+		if !found || escrowFunder.Funder != funder {
+			return
+		}
+		escrowFunder.restoreRequest(rt.CurrEpoch(), big.Sub(request, funded))
+		err = msm.escrowFunders.Put(abi.AddrKey(client), escrowFunder)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set escrow funder for %v", client)
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+}
+
+// Tests whether a deal client is an actor that authorizes deals with MethodAuthorizeDeal, rather than a
+// signing party. Only actors that are not built in may authorize deals, so that the method is never invoked
+// on a built-in actor. Any other client, including an address that does not resolve to an actor, is taken to
+// be a signing party.
+func isDealClientActor(rt Runtime, client addr.Address) bool {
+	resolved, ok := rt.ResolveAddress(client)
+	if !ok {
+		return false
+	}
+	code, ok := rt.GetActorCodeCID(resolved)
+	return ok && !builtin.IsBuiltinActor(code)
+}
+
+// Requests a client actor's authorization of a deal proposal. Returns whether the client authorized it.
+func requestDealAuthorization(rt Runtime, client addr.Address, proposalCid cid.Cid) bool {
+	code := rt.Send(client, MethodAuthorizeDeal, &AuthorizeDealParams{ProposalCid: proposalCid}, big.Zero(), &builtin.Discard{})
+	if !code.IsSuccess() {
+		rt.Log(rtt.INFO, "client %v did not authorize deal proposal %s, exitcode: %d", client, proposalCid, code)
+		return false
+	}
+	return true
+}
+
 // Requests the current epoch target block reward from the reward actor.
 func requestCurrentBaselinePower(rt Runtime) abi.StoragePower {
 	var ret reward.ThisEpochRewardReturn
@@ -6,6 +6,8 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
 )
 
 func (m *marketStateMutation) lockClientAndProviderBalances(proposal *DealProposal) error {
@@ -99,6 +101,20 @@ func (m *marketStateMutation) maybeLockBalance(addr addr.Address, amount abi.Tok
 	return nil
 }
 
+// Returns the amount by which a participant's escrow falls short of covering an additional amount to lock,
+// which is zero if the escrow is sufficient.
+func (m *marketStateMutation) balanceShortfall(addr addr.Address, amountToLock abi.TokenAmount) (abi.TokenAmount, error) {
+	prevLocked, err := m.lockedTable.Get(addr)
+	if err != nil {
+		return big.Zero(), xerrors.Errorf("failed to get locked balance: %w", err)
+	}
+	escrowBalance, err := m.escrowTable.Get(addr)
+	if err != nil {
+		return big.Zero(), xerrors.Errorf("failed to get escrow balance: %w", err)
+	}
+	return big.Max(big.Sub(big.Add(prevLocked, amountToLock), escrowBalance), big.Zero()), nil
+}
+
 // Return true when the funds in escrow for the input address can cover an additional lockup of amountToLock
 func (m *marketStateMutation) balanceCovered(addr addr.Address, amountToLock abi.TokenAmount) (bool, error) {
 	prevLocked, err := m.lockedTable.Get(addr)
@@ -111,3 +127,60 @@ func (m *marketStateMutation) balanceCovered(addr addr.Address, amountToLock abi
 	}
 	return big.Add(prevLocked, amountToLock).LessThanEqual(escrowBalance), nil
 }
+
+// Totals of the funds locked in escrow for deals, by purpose.
+type LockedTotals struct {
+	ClientCollateral   abi.TokenAmount
+	ProviderCollateral abi.TokenAmount
+	ClientStorageFee   abi.TokenAmount
+}
+
+// Computes the totals of funds locked for the deals in state: the collateral of each deal not yet
+// settled, and the storage fee for the epochs for which each deal's provider is yet to be paid.
+// Data onboarding deals lock no funds.
+func (st *State) ComputeLockedTotals(store adt.Store) (*LockedTotals, error) {
+	proposals, err := AsDealProposalArray(store, st.Proposals)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deal proposals: %w", err)
+	}
+	states, err := AsDealStateArray(store, st.States)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+
+	totals := LockedTotals{
+		ClientCollateral:   big.Zero(),
+		ProviderCollateral: big.Zero(),
+		ClientStorageFee:   big.Zero(),
+	}
+	var proposal DealProposal
+	err = proposals.ForEach(&proposal, func(id int64) error {
+		if IsDataOnboardingDeal(&proposal) {
+			return nil
+		}
+		state, _, err := states.Get(abi.DealID(id))
+		if err != nil {
+			return xerrors.Errorf("failed to get state for deal %d: %w", id, err)
+		}
+		paidUntil := proposal.StartEpoch
+		if state.LastUpdatedEpoch != epochUndefined && state.LastUpdatedEpoch > paidUntil {
+			paidUntil = state.LastUpdatedEpoch
+		}
+		if paidUntil > proposal.EndEpoch {
+			paidUntil = proposal.EndEpoch
+		}
+		feeRemaining, err := dealGetPaymentRemaining(&proposal, paidUntil)
+		if err != nil {
+			return xerrors.Errorf("failed to compute remaining payment for deal %d: %w", id, err)
+		}
+
+		totals.ClientCollateral = big.Add(totals.ClientCollateral, proposal.ClientCollateral)
+		totals.ProviderCollateral = big.Add(totals.ProviderCollateral, proposal.ProviderCollateral)
+		totals.ClientStorageFee = big.Add(totals.ClientStorageFee, feeRemaining)
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to iterate deal proposals: %w", err)
+	}
+	return &totals, nil
+}
@@ -2,19 +2,29 @@ package market
 
 import (
 	"bytes"
+	"fmt"
+	"math"
 
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
 	xerrors "golang.org/x/xerrors"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/verifreg"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
 )
 
 const epochUndefined = abi.ChainEpoch(-1)
 
+// The sector number of a deal activated before the market recorded the sectors of deals.
+// It is above the maximum sector number, so never that of a real sector.
+const DealSectorNumberUnknown = abi.SectorNumber(math.MaxUint64)
+
 // BalanceLockingReason is the reason behind locking an amount.
 type BalanceLockingReason int
 
@@ -27,6 +37,7 @@ const (
 // Bitwidth of AMTs determined empirically from mutation patterns and projections of mainnet data.
 const ProposalsAmtBitwidth = 5
 const StatesAmtBitwidth = 6
+const ActiveDealsAmtBitwidth = 5
 
 type State struct {
 	// Proposals are deals that have been proposed and not yet cleaned up after expiry or termination.
@@ -48,11 +59,15 @@ type State struct {
 	// only the _portion_ of the total escrow amount that is locked.
 	LockedTable cid.Cid // BalanceTable
 
+	// The next deal ID to allocate from the counter. IDs derived from proposal CIDs
+	// (see DealIDsFromProposalCID) are outside the counter's range and do not advance it.
 	NextID abi.DealID
 
 	// Metadata cached for efficient iteration over deals.
-	DealOpsByEpoch cid.Cid // SetMultimap, HAMT[epoch]Set
-	LastCron       abi.ChainEpoch
+	DealOpsByEpoch cid.Cid // ProviderSetMultimap, HAMT[epoch]HAMT[provider]Set
+	// The last epoch whose deal ops have all been processed by cron. Ops of later epochs up to the current one
+	// remain to be processed when a tick reaches its limit of deal updates.
+	LastCron abi.ChainEpoch
 
 	// Total Client Collateral that is locked -> unlocked when deal is terminated
 	TotalClientLockedCollateral abi.TokenAmount
@@ -60,6 +75,61 @@ type State struct {
 	TotalProviderLockedCollateral abi.TokenAmount
 	// Total storage fee that is locked in escrow -> unlocked when payments are made
 	TotalClientStorageFee abi.TokenAmount
+
+	// Standing asks posted by providers, indexed by provider address.
+	ProviderAsks cid.Cid // HAMT[addr]ProviderAsk
+
+	// Unpublished deal proposals revoked by their clients, indexed by client address then proposal CID.
+	// Each entry holds the epoch at which the revocation lapses.
+	RevokedProposals cid.Cid // HAMT[addr]HAMT[ProposalCid]ChainEpoch
+
+	// Deal proposals published by their clients, awaiting acceptance by their providers, indexed by proposal CID.
+	// Client and provider addresses are resolved to ID addresses.
+	ClientProposals cid.Cid // HAMT[ProposalCid]DealProposal
+
+	// Deals that their clients have opted to index by label, keyed by LabelIndexKey.
+	// Entries are not removed when a deal is cleaned up, but are pruned when the key is next indexed.
+	LabelIndex cid.Cid // HAMT[LabelIndexKey]BitField
+
+	// Funders authorized by clients to top up their escrow, indexed by client address.
+	EscrowFunders cid.Cid // HAMT[addr]EscrowFunder
+
+	// Active deals whose sectors their clients have had terminated for the provider's breach of the deal.
+	// When such a deal is settled, its provider collateral is paid to the client rather than burnt.
+	BreachedDeals cid.Cid // Set[DealID]
+
+	// The verified registry allocations of DataCap made for verified deals when they were published, which are
+	// claimed when the deals are activated. An entry is removed when its deal is activated or times out.
+	// Verified deals published before allocations were introduced have no entry.
+	DealAllocations cid.Cid // HAMT[DealID]AllocationID
+
+	// Retrieval violations reported against active deals with retrieval terms. An entry is removed with its deal.
+	RetrievalViolations cid.Cid // HAMT[DealID]RetrievalViolations
+
+	// Proposals of pending deals since replaced by amendment, transfer or change of collateral, indexed by client
+	// address then proposal CID. A superseded proposal is no longer pending, but must not be published again.
+	// Each entry holds the proposal's start epoch, after which it can't be published anyway.
+	SupersededProposals cid.Cid // HAMT[addr]HAMT[ProposalCid]ChainEpoch
+
+	// Activated deals, densely indexed for audit sampling, and the index of each. A deal is added when activated,
+	// and removed when cron deletes its state by moving the last deal indexed into its place.
+	ActiveDeals       cid.Cid // AMT[index]DealID
+	ActiveDealIndexes cid.Cid // HAMT[DealID]index
+	// The largest piece size of any deal added to ActiveDeals, which bounds the weight of a deal in audit sampling.
+	// It is not lowered when the deal is removed.
+	ActiveDealsMaxPieceSize abi.PaddedPieceSize
+}
+
+// The retrieval violations reported against a deal.
+type RetrievalViolations struct {
+	// Start of the violation period in which reports are being counted, and the number reported in it.
+	PeriodStart   abi.ChainEpoch
+	PeriodReports uint64
+	// Epoch of the latest failed retrieval reported. Reports must be of later retrievals.
+	LastEpoch abi.ChainEpoch
+	// Epoch at which the violation awaiting penalty was reported, or -1 if none awaits it.
+	// The provider may dispute the violation until RetrievalDisputeWindow has passed after this epoch.
+	PendingReportEpoch abi.ChainEpoch
 }
 
 func ConstructState(store adt.Store) (*State, error) {
@@ -76,7 +146,7 @@ func ConstructState(store adt.Store) (*State, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create empty map: %w", err)
 	}
-	emptyDealOpsHamtCid, err := StoreEmptySetMultimap(store, builtin.DefaultHamtBitwidth)
+	emptyDealOpsHamtCid, err := StoreEmptyProviderSetMultimap(store, builtin.DefaultHamtBitwidth)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create empty multiset: %w", err)
 	}
@@ -84,6 +154,50 @@ func ConstructState(store adt.Store) (*State, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create empty balance table: %w", err)
 	}
+	emptyProviderAsksMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty provider asks map: %w", err)
+	}
+	emptyRevokedProposalsMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty revoked proposals map: %w", err)
+	}
+	emptyClientProposalsMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty client proposals map: %w", err)
+	}
+	emptyLabelIndexMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty label index map: %w", err)
+	}
+	emptyEscrowFundersMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty escrow funders map: %w", err)
+	}
+	emptyDealAllocationsMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty deal allocations map: %w", err)
+	}
+	emptyBreachedDealsSetCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty breached deals set: %w", err)
+	}
+	emptyRetrievalViolationsMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty retrieval violations map: %w", err)
+	}
+	emptySupersededProposalsMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty superseded proposals map: %w", err)
+	}
+	emptyActiveDealsArrayCid, err := adt.StoreEmptyArray(store, ActiveDealsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty active deals array: %w", err)
+	}
+	emptyActiveDealIndexesMapCid, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty active deal indexes map: %w", err)
+	}
 
 	return &State{
 		Proposals:        emptyProposalsArrayCid,
@@ -98,6 +212,17 @@ func ConstructState(store adt.Store) (*State, error) {
 		TotalClientLockedCollateral:   abi.NewTokenAmount(0),
 		TotalProviderLockedCollateral: abi.NewTokenAmount(0),
 		TotalClientStorageFee:         abi.NewTokenAmount(0),
+		ProviderAsks:                  emptyProviderAsksMapCid,
+		RevokedProposals:              emptyRevokedProposalsMapCid,
+		ClientProposals:               emptyClientProposalsMapCid,
+		LabelIndex:                    emptyLabelIndexMapCid,
+		EscrowFunders:                 emptyEscrowFundersMapCid,
+		BreachedDeals:                 emptyBreachedDealsSetCid,
+		DealAllocations:               emptyDealAllocationsMapCid,
+		RetrievalViolations:           emptyRetrievalViolationsMapCid,
+		SupersededProposals:           emptySupersededProposalsMapCid,
+		ActiveDeals:                   emptyActiveDealsArrayCid,
+		ActiveDealIndexes:             emptyActiveDealIndexesMapCid,
 	}, nil
 }
 
@@ -109,7 +234,8 @@ func (m *marketStateMutation) updatePendingDealState(rt Runtime, state *DealStat
 	amountSlashed = abi.NewTokenAmount(0)
 
 	everUpdated := state.LastUpdatedEpoch != epochUndefined
-	everSlashed := state.SlashEpoch != epochUndefined
+	// A deal whose sector was terminated only once the deal had ended is settled as expired.
+	everSlashed := state.SlashEpoch != epochUndefined && state.SlashEpoch < deal.EndEpoch
 
 	builtin.RequireState(rt, !everUpdated || (state.LastUpdatedEpoch <= epoch), "deal updated at future epoch %d", state.LastUpdatedEpoch)
 
@@ -147,6 +273,10 @@ func (m *marketStateMutation) updatePendingDealState(rt Runtime, state *DealStat
 		}
 	}
 
+	if everSlashed && IsDataOnboardingDeal(deal) {
+		// No funds were locked for the deal, so there is nothing to unlock or slash.
+		return amountSlashed, epochUndefined, true
+	}
 	if everSlashed {
 		// unlock client collateral and locked storage fee
 		paymentRemaining, err := dealGetPaymentRemaining(deal, state.SlashEpoch)
@@ -183,6 +313,9 @@ func (m *marketStateMutation) updatePendingDealState(rt Runtime, state *DealStat
 // Slash a portion of provider's collateral, and unlock remaining collaterals
 // for both provider and client.
 func (m *marketStateMutation) processDealInitTimedOut(rt Runtime, deal *DealProposal) abi.TokenAmount {
+	if IsDataOnboardingDeal(deal) {
+		return big.Zero()
+	}
 	if err := m.unlockBalance(deal.Client, deal.TotalStorageFee(), ClientStorageFee); err != nil {
 		rt.Abortf(exitcode.ErrIllegalState, "failure unlocking client storage fee: %s", err)
 	}
@@ -207,6 +340,9 @@ func (m *marketStateMutation) processDealInitTimedOut(rt Runtime, deal *DealProp
 // Normal expiration. Unlock collaterals for both provider and client.
 func (m *marketStateMutation) processDealExpired(rt Runtime, deal *DealProposal, state *DealState) {
 	builtin.RequireState(rt, state.SectorStartEpoch != epochUndefined, "sector start epoch undefined")
+	if IsDataOnboardingDeal(deal) {
+		return
+	}
 
 	// Note: payment has already been completed at this point (_rtProcessDealPaymentEpochsElapsed)
 	err := m.unlockBalance(deal.Provider, deal.ProviderCollateral, ProviderCollateral)
@@ -222,6 +358,51 @@ func (m *marketStateMutation) generateStorageDealID() abi.DealID {
 	return ret
 }
 
+// Allocates the first free deal ID at or after the one derived from a proposal CID.
+func (m *marketStateMutation) generateDerivedDealID(proposalCid cid.Cid) (abi.DealID, error) {
+	id, err := DerivedDealID(proposalCid)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		_, found, err := m.dealProposals.Get(id)
+		if err != nil {
+			return 0, xerrors.Errorf("failed to get deal proposal %d: %w", id, err)
+		}
+		if !found {
+			return id, nil
+		}
+		id = nextDerivedDealID(id)
+	}
+}
+
+// Finds the ID of a deal published with an ID derived from its proposal CID.
+// The search ends at the first free ID, so a deal is not found if its proposal was published with a
+// counter ID or if a deal it collided with has since been deleted.
+func (m *marketStateMutation) findDerivedDealID(proposalCid cid.Cid) (abi.DealID, bool, error) {
+	id, err := DerivedDealID(proposalCid)
+	if err != nil {
+		return 0, false, err
+	}
+	for {
+		existing, found, err := m.dealProposals.Get(id)
+		if err != nil {
+			return 0, false, xerrors.Errorf("failed to get deal proposal %d: %w", id, err)
+		}
+		if !found {
+			return 0, false, nil
+		}
+		existingCid, err := existing.Cid()
+		if err != nil {
+			return 0, false, xerrors.Errorf("failed to take cid of deal proposal %d: %w", id, err)
+		}
+		if existingCid.Equals(proposalCid) {
+			return id, true, nil
+		}
+		id = nextDerivedDealID(id)
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // State utility functions
 ////////////////////////////////////////////////////////////////////////////////
@@ -260,15 +441,15 @@ func dealGetPaymentRemaining(deal *DealProposal, slashEpoch abi.ChainEpoch) (abi
 }
 
 // MarketStateMutationPermission is the mutation permission on a state field
-type MarketStateMutationPermission int
+type MarketStateMutationPermission = adt.MutationPermission
 
 const (
 	// Invalid means NO permission
-	Invalid MarketStateMutationPermission = iota
+	Invalid = adt.PermitNone
 	// ReadOnlyPermission allows reading but not mutating the field
-	ReadOnlyPermission
+	ReadOnlyPermission = adt.PermitReadOnly
 	// WritePermission allows mutating the field
-	WritePermission
+	WritePermission = adt.PermitWrite
 )
 
 type marketStateMutation struct {
@@ -288,7 +469,7 @@ type marketStateMutation struct {
 	pendingDeals  *adt.Set
 
 	dpePermit    MarketStateMutationPermission
-	dealsByEpoch *SetMultimap
+	dealsByEpoch *ProviderSetMultimap
 
 	lockedPermit                  MarketStateMutationPermission
 	lockedTable                   *adt.BalanceTable
@@ -296,6 +477,38 @@ type marketStateMutation struct {
 	totalProviderLockedCollateral abi.TokenAmount
 	totalClientStorageFee         abi.TokenAmount
 
+	askPermit    MarketStateMutationPermission
+	providerAsks *adt.Map
+
+	revokedPermit    MarketStateMutationPermission
+	revokedProposals *adt.Map
+
+	clientProposalPermit MarketStateMutationPermission
+	clientProposals      *adt.Map
+
+	labelPermit MarketStateMutationPermission
+	labelIndex  *adt.Map
+
+	funderPermit  MarketStateMutationPermission
+	escrowFunders *adt.Map
+
+	allocationPermit MarketStateMutationPermission
+	dealAllocations  *adt.Map
+
+	breachedPermit MarketStateMutationPermission
+	breachedDeals  *adt.Set
+
+	violationPermit     MarketStateMutationPermission
+	retrievalViolations *adt.Map
+
+	supersededPermit    MarketStateMutationPermission
+	supersededProposals *adt.Map
+
+	activePermit            MarketStateMutationPermission
+	activeDeals             *adt.Array
+	activeDealIndexes       *adt.Map
+	activeDealsMaxPieceSize abi.PaddedPieceSize
+
 	nextDealId abi.DealID
 }
 
@@ -348,13 +561,99 @@ func (m *marketStateMutation) build() (*marketStateMutation, error) {
 	}
 
 	if m.dpePermit != Invalid {
-		dbe, err := AsSetMultimap(m.store, m.st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
+		dbe, err := AsProviderSetMultimap(m.store, m.st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
 		if err != nil {
 			return nil, xerrors.Errorf("failed to load deals by epoch: %w", err)
 		}
 		m.dealsByEpoch = dbe
 	}
 
+	if m.askPermit != Invalid {
+		asks, err := adt.AsMap(m.store, m.st.ProviderAsks, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load provider asks: %w", err)
+		}
+		m.providerAsks = asks
+	}
+
+	if m.revokedPermit != Invalid {
+		revoked, err := adt.AsMap(m.store, m.st.RevokedProposals, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load revoked proposals: %w", err)
+		}
+		m.revokedProposals = revoked
+	}
+
+	if m.clientProposalPermit != Invalid {
+		clientProposals, err := adt.AsMap(m.store, m.st.ClientProposals, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load client proposals: %w", err)
+		}
+		m.clientProposals = clientProposals
+	}
+
+	if m.labelPermit != Invalid {
+		labels, err := adt.AsMap(m.store, m.st.LabelIndex, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load label index: %w", err)
+		}
+		m.labelIndex = labels
+	}
+
+	if m.funderPermit != Invalid {
+		funders, err := adt.AsMap(m.store, m.st.EscrowFunders, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load escrow funders: %w", err)
+		}
+		m.escrowFunders = funders
+	}
+
+	if m.allocationPermit != Invalid {
+		allocations, err := adt.AsMap(m.store, m.st.DealAllocations, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load deal allocations: %w", err)
+		}
+		m.dealAllocations = allocations
+	}
+
+	if m.breachedPermit != Invalid {
+		breached, err := adt.AsSet(m.store, m.st.BreachedDeals, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load breached deals: %w", err)
+		}
+		m.breachedDeals = breached
+	}
+
+	if m.violationPermit != Invalid {
+		violations, err := adt.AsMap(m.store, m.st.RetrievalViolations, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load retrieval violations: %w", err)
+		}
+		m.retrievalViolations = violations
+	}
+
+	if m.supersededPermit != Invalid {
+		superseded, err := adt.AsMap(m.store, m.st.SupersededProposals, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load superseded proposals: %w", err)
+		}
+		m.supersededProposals = superseded
+	}
+
+	if m.activePermit != Invalid {
+		active, err := adt.AsArray(m.store, m.st.ActiveDeals, ActiveDealsAmtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load active deals: %w", err)
+		}
+		indexes, err := adt.AsMap(m.store, m.st.ActiveDealIndexes, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load active deal indexes: %w", err)
+		}
+		m.activeDeals = active
+		m.activeDealIndexes = indexes
+		m.activeDealsMaxPieceSize = m.st.ActiveDealsMaxPieceSize
+	}
+
 	m.nextDealId = m.st.NextID
 
 	return m, nil
@@ -390,47 +689,657 @@ func (m *marketStateMutation) withDealsByEpoch(permit MarketStateMutationPermiss
 	return m
 }
 
+func (m *marketStateMutation) withProviderAsks(permit MarketStateMutationPermission) *marketStateMutation {
+	m.askPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withRevokedProposals(permit MarketStateMutationPermission) *marketStateMutation {
+	m.revokedPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withClientProposals(permit MarketStateMutationPermission) *marketStateMutation {
+	m.clientProposalPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withLabelIndex(permit MarketStateMutationPermission) *marketStateMutation {
+	m.labelPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withEscrowFunders(permit MarketStateMutationPermission) *marketStateMutation {
+	m.funderPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withDealAllocations(permit MarketStateMutationPermission) *marketStateMutation {
+	m.allocationPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withBreachedDeals(permit MarketStateMutationPermission) *marketStateMutation {
+	m.breachedPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withRetrievalViolations(permit MarketStateMutationPermission) *marketStateMutation {
+	m.violationPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withSupersededProposals(permit MarketStateMutationPermission) *marketStateMutation {
+	m.supersededPermit = permit
+	return m
+}
+
+func (m *marketStateMutation) withActiveDeals(permit MarketStateMutationPermission) *marketStateMutation {
+	m.activePermit = permit
+	return m
+}
+
 func (m *marketStateMutation) commitState() error {
-	var err error
-	if m.proposalPermit == WritePermission {
-		if m.st.Proposals, err = m.dealProposals.Root(); err != nil {
-			return xerrors.Errorf("failed to flush deal dealProposals: %w", err)
-		}
+	// Only the structures modified since they were loaded are re-serialized.
+	if err := adt.FlushIfModified(m.proposalPermit, m.dealProposals, &m.st.Proposals); err != nil {
+		return xerrors.Errorf("failed to flush deal dealProposals: %w", err)
 	}
 
-	if m.statePermit == WritePermission {
-		if m.st.States, err = m.dealStates.Root(); err != nil {
-			return xerrors.Errorf("failed to flush deal states: %w", err)
-		}
+	if err := adt.FlushIfModified(m.statePermit, m.dealStates, &m.st.States); err != nil {
+		return xerrors.Errorf("failed to flush deal states: %w", err)
 	}
 
+	if err := adt.FlushIfModified(m.lockedPermit, m.lockedTable, &m.st.LockedTable); err != nil {
+		return xerrors.Errorf("failed to flush locked table: %w", err)
+	}
 	if m.lockedPermit == WritePermission {
-		if m.st.LockedTable, err = m.lockedTable.Root(); err != nil {
-			return xerrors.Errorf("failed to flush locked table: %w", err)
-		}
 		m.st.TotalClientLockedCollateral = m.totalClientLockedCollateral.Copy()
 		m.st.TotalProviderLockedCollateral = m.totalProviderLockedCollateral.Copy()
 		m.st.TotalClientStorageFee = m.totalClientStorageFee.Copy()
 	}
 
-	if m.escrowPermit == WritePermission {
-		if m.st.EscrowTable, err = m.escrowTable.Root(); err != nil {
-			return xerrors.Errorf("failed to flush escrow table: %w", err)
+	if err := adt.FlushIfModified(m.escrowPermit, m.escrowTable, &m.st.EscrowTable); err != nil {
+		return xerrors.Errorf("failed to flush escrow table: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.pendingPermit, m.pendingDeals, &m.st.PendingProposals); err != nil {
+		return xerrors.Errorf("failed to flush pending deals: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.dpePermit, m.dealsByEpoch, &m.st.DealOpsByEpoch); err != nil {
+		return xerrors.Errorf("failed to flush deals by epoch: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.askPermit, m.providerAsks, &m.st.ProviderAsks); err != nil {
+		return xerrors.Errorf("failed to flush provider asks: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.revokedPermit, m.revokedProposals, &m.st.RevokedProposals); err != nil {
+		return xerrors.Errorf("failed to flush revoked proposals: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.clientProposalPermit, m.clientProposals, &m.st.ClientProposals); err != nil {
+		return xerrors.Errorf("failed to flush client proposals: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.labelPermit, m.labelIndex, &m.st.LabelIndex); err != nil {
+		return xerrors.Errorf("failed to flush label index: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.funderPermit, m.escrowFunders, &m.st.EscrowFunders); err != nil {
+		return xerrors.Errorf("failed to flush escrow funders: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.allocationPermit, m.dealAllocations, &m.st.DealAllocations); err != nil {
+		return xerrors.Errorf("failed to flush deal allocations: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.breachedPermit, m.breachedDeals, &m.st.BreachedDeals); err != nil {
+		return xerrors.Errorf("failed to flush breached deals: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.violationPermit, m.retrievalViolations, &m.st.RetrievalViolations); err != nil {
+		return xerrors.Errorf("failed to flush retrieval violations: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.supersededPermit, m.supersededProposals, &m.st.SupersededProposals); err != nil {
+		return xerrors.Errorf("failed to flush superseded proposals: %w", err)
+	}
+
+	if err := adt.FlushIfModified(m.activePermit, m.activeDeals, &m.st.ActiveDeals); err != nil {
+		return xerrors.Errorf("failed to flush active deals: %w", err)
+	}
+	if err := adt.FlushIfModified(m.activePermit, m.activeDealIndexes, &m.st.ActiveDealIndexes); err != nil {
+		return xerrors.Errorf("failed to flush active deal indexes: %w", err)
+	}
+	if m.activePermit == WritePermission {
+		m.st.ActiveDealsMaxPieceSize = m.activeDealsMaxPieceSize
+	}
+
+	m.st.NextID = m.nextDealId
+	return nil
+}
+
+// Returns the verified registry allocation made for a deal that has not yet been activated or timed out,
+// and whether there is one.
+func (st *State) GetDealAllocation(store adt.Store, dealID abi.DealID) (verifreg.AllocationID, bool, error) {
+	allocations, err := adt.AsMap(store, st.DealAllocations, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to load deal allocations: %w", err)
+	}
+	var value cbg.CborInt
+	found, err := allocations.Get(abi.UIntKey(uint64(dealID)), &value)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to get allocation for deal %d: %w", dealID, err)
+	}
+	return verifreg.AllocationID(value), found, nil
+}
+
+// Records the verified registry allocation made for a deal.
+func (m *marketStateMutation) putDealAllocation(dealID abi.DealID, allocation verifreg.AllocationID) error {
+	value := cbg.CborInt(allocation)
+	if err := m.dealAllocations.Put(abi.UIntKey(uint64(dealID)), &value); err != nil {
+		return xerrors.Errorf("failed to put allocation for deal %d: %w", dealID, err)
+	}
+	return nil
+}
+
+// Removes and returns the verified registry allocation made for a deal, and whether there was one.
+func (m *marketStateMutation) popDealAllocation(dealID abi.DealID) (verifreg.AllocationID, bool, error) {
+	var value cbg.CborInt
+	found, err := m.dealAllocations.Pop(abi.UIntKey(uint64(dealID)), &value)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to remove allocation for deal %d: %w", dealID, err)
+	}
+	return verifreg.AllocationID(value), found, nil
+}
+
+// Returns the epoch at which a deal is queued for its next update by cron, or -1 if it is not queued.
+// A deal is first queued at an epoch derived from its start epoch and ID, and is thereafter queued
+// DealUpdatesInterval epochs after each update.
+// Cron processes a queued deal at the first tick at or after this epoch that is within its limit on updates.
+func (m *marketStateMutation) dealUpdateEpoch(dealID abi.DealID, proposal *DealProposal, state *DealState) (abi.ChainEpoch, error) {
+	epoch := GenRandNextEpoch(proposal.StartEpoch, dealID)
+	if state.LastUpdatedEpoch != epochUndefined {
+		epoch = state.LastUpdatedEpoch + DealUpdatesInterval
+	}
+	queued, err := m.dealsByEpoch.Has(epoch, proposal.Provider, dealID)
+	if err != nil {
+		return epochUndefined, xerrors.Errorf("failed to check deal ops at epoch %d for deal %d: %w", epoch, dealID, err)
+	}
+	if !queued {
+		return epochUndefined, nil
+	}
+	return epoch, nil
+}
+
+// Checks that a miner may activate the deals of a sector: that each is named once, is a pending deal of the
+// miner that is yet to be activated, and starts and ends within the sector's term. Returns the deals' proposals.
+func (m *marketStateMutation) checkSectorDealsActivatable(minerAddr addr.Address, dealIDs []abi.DealID,
+	sectorExpiry, currEpoch abi.ChainEpoch) ([]*DealProposal, error) {
+	if _, _, _, err := validateAndComputeDealWeight(m.dealProposals, dealIDs, minerAddr, sectorExpiry, currEpoch); err != nil {
+		return nil, err
+	}
+	proposals := make([]*DealProposal, len(dealIDs))
+	for i, dealID := range dealIDs {
+		_, found, err := m.dealStates.Get(dealID)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get state for dealId %d: %w", dealID, err)
+		}
+		if found {
+			return nil, exitcode.ErrIllegalArgument.Wrapf("deal %d already included in another sector", dealID)
+		}
+
+		proposal, err := getDealProposal(m.dealProposals, dealID)
+		if err != nil {
+			return nil, err
+		}
+		propc, err := proposal.Cid()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to calculate proposal CID: %w", err)
+		}
+		has, err := m.pendingDeals.Has(abi.CidKey(propc))
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get pending proposal %v: %w", propc, err)
+		}
+		if !has {
+			return nil, exitcode.ErrIllegalState.Wrapf("tried to activate deal that was not in the pending set (%s)", propc)
+		}
+		proposals[i] = proposal
+	}
+	return proposals, nil
+}
+
+// Activates the deals of a sector, as checked by checkSectorDealsActivatable, removing the allocations
+// made for verified deals. Returns the verified deals' activated bytes and the allocations to be claimed.
+func (m *marketStateMutation) activateSectorDeals(dealIDs []abi.DealID, proposals []*DealProposal, sectorNumber abi.SectorNumber,
+	currEpoch abi.ChainEpoch) ([]verifreg.ActivatedBytes, []verifreg.AllocationID, error) {
+	var verifiedActivations []verifreg.ActivatedBytes
+	var allocationIDs []verifreg.AllocationID
+	for i, dealID := range dealIDs {
+		if err := m.dealStates.Set(dealID, &DealState{
+			SectorStartEpoch: currEpoch,
+			LastUpdatedEpoch: epochUndefined,
+			SlashEpoch:       epochUndefined,
+			SectorNumber:     sectorNumber,
+		}); err != nil {
+			return nil, nil, xerrors.Errorf("failed to set deal state %d: %w", dealID, err)
+		}
+
+		proposal := proposals[i]
+		if err := m.addActiveDeal(dealID, proposal.PieceSize); err != nil {
+			return nil, nil, err
+		}
+		if !proposal.VerifiedDeal {
+			continue
+		}
+		verifiedActivations = append(verifiedActivations, verifreg.ActivatedBytes{
+			Client:   proposal.Client,
+			Provider: proposal.Provider,
+			DealSize: big.NewIntUnsigned(uint64(proposal.PieceSize)),
+		})
+		allocationID, found, err := m.popDealAllocation(dealID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			allocationIDs = append(allocationIDs, allocationID)
 		}
 	}
+	return verifiedActivations, allocationIDs, nil
+}
+
+// Adds an activated deal to the end of the active deals index.
+func (m *marketStateMutation) addActiveDeal(dealID abi.DealID, pieceSize abi.PaddedPieceSize) error {
+	index := m.activeDeals.Length()
+	value := cbg.CborInt(dealID)
+	if err := m.activeDeals.Set(index, &value); err != nil {
+		return xerrors.Errorf("failed to index active deal %d: %w", dealID, err)
+	}
+	indexValue := cbg.CborInt(index)
+	if err := m.activeDealIndexes.Put(abi.UIntKey(uint64(dealID)), &indexValue); err != nil {
+		return xerrors.Errorf("failed to record index of active deal %d: %w", dealID, err)
+	}
+	if pieceSize > m.activeDealsMaxPieceSize {
+		m.activeDealsMaxPieceSize = pieceSize
+	}
+	return nil
+}
 
-	if m.pendingPermit == WritePermission {
-		if m.st.PendingProposals, err = m.pendingDeals.Root(); err != nil {
-			return xerrors.Errorf("failed to flush pending deals: %w", err)
+// Removes a deal from the active deals index, moving the last deal indexed into its place.
+func (m *marketStateMutation) removeActiveDeal(dealID abi.DealID) error {
+	var index cbg.CborInt
+	found, err := m.activeDealIndexes.Get(abi.UIntKey(uint64(dealID)), &index)
+	if err != nil {
+		return xerrors.Errorf("failed to get index of active deal %d: %w", dealID, err)
+	}
+	if !found {
+		return xerrors.Errorf("deal %d is not indexed as active", dealID)
+	}
+	last := m.activeDeals.Length() - 1
+	if uint64(index) != last {
+		var lastDeal cbg.CborInt
+		found, err := m.activeDeals.Get(last, &lastDeal)
+		if err != nil {
+			return xerrors.Errorf("failed to get last active deal: %w", err)
+		}
+		if !found {
+			return xerrors.Errorf("no active deal at last index %d", last)
+		}
+		if err := m.activeDeals.Set(uint64(index), &lastDeal); err != nil {
+			return xerrors.Errorf("failed to move active deal %d: %w", lastDeal, err)
 		}
+		if err := m.activeDealIndexes.Put(abi.UIntKey(uint64(lastDeal)), &index); err != nil {
+			return xerrors.Errorf("failed to record index of active deal %d: %w", lastDeal, err)
+		}
+	}
+	if err := m.activeDeals.Delete(last); err != nil {
+		return xerrors.Errorf("failed to delete last active deal: %w", err)
+	}
+	if err := m.activeDealIndexes.Delete(abi.UIntKey(uint64(dealID))); err != nil {
+		return xerrors.Errorf("failed to delete index of active deal %d: %w", dealID, err)
 	}
+	return nil
+}
 
-	if m.dpePermit == WritePermission {
-		if m.st.DealOpsByEpoch, err = m.dealsByEpoch.Root(); err != nil {
-			return xerrors.Errorf("failed to flush deals by epoch: %w", err)
+// Replaces the proposal prev of a deal, whose CID is prevCid, returning the CID of the replacement.
+// A deal not yet updated is still pending under its proposal CID, which is moved to the replacement's.
+// The superseded proposal is then barred from being published again as another deal until it starts.
+func (m *marketStateMutation) replaceDealProposal(dealID abi.DealID, prev *DealProposal, prevCid cid.Cid, proposal *DealProposal,
+	currEpoch abi.ChainEpoch) (cid.Cid, error) {
+	newCid, err := proposal.Cid()
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to calculate CID for proposal %d: %w", dealID, err)
+	}
+	pending, err := m.pendingDeals.Has(abi.CidKey(prevCid))
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to check pending proposal %s: %w", prevCid, err)
+	}
+	if pending {
+		if err = m.pendingDeals.Delete(abi.CidKey(prevCid)); err != nil {
+			return cid.Undef, xerrors.Errorf("failed to delete pending proposal %s: %w", prevCid, err)
 		}
+		if err = m.pendingDeals.Put(abi.CidKey(newCid)); err != nil {
+			return cid.Undef, xerrors.Errorf("failed to record pending proposal %s: %w", newCid, err)
+		}
+		if err = m.supersedeProposal(prev.Client, prevCid, prev.StartEpoch, currEpoch); err != nil {
+			return cid.Undef, err
+		}
+	}
+	if err = m.dealProposals.Set(dealID, proposal); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to set deal proposal %d: %w", dealID, err)
 	}
+	return newCid, nil
+}
 
-	m.st.NextID = m.nextDealId
+// Penalizes the violation reported against a deal that its provider did not dispute within the dispute window,
+// if any. The penalty, up to the provider collateral remaining, is slashed from the provider and the deal's
+// proposal replaced with one of reduced collateral. Returns the penalty and the deal's current proposal.
+func (m *marketStateMutation) penalizeRetrievalViolation(dealID abi.DealID, deal *DealProposal, currEpoch abi.ChainEpoch) (abi.TokenAmount, *DealProposal, error) {
+	var violations RetrievalViolations
+	found, err := m.retrievalViolations.Get(abi.UIntKey(uint64(dealID)), &violations)
+	if err != nil {
+		return big.Zero(), nil, xerrors.Errorf("failed to get retrieval violations of deal %d: %w", dealID, err)
+	}
+	if !found || violations.PendingReportEpoch == epochUndefined || currEpoch <= violations.PendingReportEpoch+RetrievalDisputeWindow {
+		return big.Zero(), deal, nil
+	}
+
+	violations.PendingReportEpoch = epochUndefined
+	if err = m.retrievalViolations.Put(abi.UIntKey(uint64(dealID)), &violations); err != nil {
+		return big.Zero(), nil, xerrors.Errorf("failed to put retrieval violations of deal %d: %w", dealID, err)
+	}
+	penalty := big.Min(deal.RetrievalViolationPenalty(), deal.ProviderCollateral)
+	if penalty.IsZero() {
+		return penalty, deal, nil
+	}
+	if err = m.slashBalance(deal.Provider, penalty, ProviderCollateral); err != nil {
+		return big.Zero(), nil, xerrors.Errorf("failed to slash provider collateral of deal %d: %w", dealID, err)
+	}
+	dealCid, err := deal.Cid()
+	if err != nil {
+		return big.Zero(), nil, xerrors.Errorf("failed to calculate CID for proposal %d: %w", dealID, err)
+	}
+	penalized := *deal
+	penalized.ProviderCollateral = big.Sub(deal.ProviderCollateral, penalty)
+	if _, err = m.replaceDealProposal(dealID, deal, dealCid, &penalized, currEpoch); err != nil {
+		return big.Zero(), nil, err
+	}
+	return penalty, &penalized, nil
+}
+
+// Loads a provider's standing ask, treating an expired ask as absent.
+func (m *marketStateMutation) getActiveProviderAsk(provider addr.Address, currEpoch abi.ChainEpoch) (*ProviderAsk, bool, error) {
+	var ask ProviderAsk
+	found, err := m.providerAsks.Get(abi.AddrKey(provider), &ask)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to get provider ask for %v: %w", provider, err)
+	}
+	if !found || currEpoch >= ask.Expiry {
+		return nil, false, nil
+	}
+	return &ask, true, nil
+}
+
+// Records a client's revocation of a proposal until the expiry epoch, replacing any prior revocation
+// of the same proposal. Lapsed revocations by the client are pruned first, and the number of
+// revocations held for any one client is bounded by MaxRevokedProposalsPerClient.
+func (m *marketStateMutation) revokeProposal(client addr.Address, proposal cid.Cid, expiry, currEpoch abi.ChainEpoch) error {
+	revocations, err := m.loadClientRevocations(client)
+	if err != nil {
+		return err
+	}
+
+	var lapsed []cid.Cid
+	var revocationExpiry cbg.CborInt
+	count := 0
+	err = revocations.ForEach(&revocationExpiry, func(k string) error {
+		revoked, err := cid.Cast([]byte(k))
+		if err != nil {
+			return err
+		}
+		if currEpoch >= abi.ChainEpoch(revocationExpiry) {
+			lapsed = append(lapsed, revoked)
+		} else if !revoked.Equals(proposal) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to iterate revocations for client %v: %w", client, err)
+	}
+	for _, revoked := range lapsed {
+		if err := revocations.Delete(abi.CidKey(revoked)); err != nil {
+			return xerrors.Errorf("failed to delete lapsed revocation for client %v: %w", client, err)
+		}
+	}
+	if count >= MaxRevokedProposalsPerClient {
+		return exitcode.ErrForbidden.Wrapf("client %v already holds %d revocations", client, count)
+	}
+
+	expiryValue := cbg.CborInt(expiry)
+	if err := revocations.Put(abi.CidKey(proposal), &expiryValue); err != nil {
+		return xerrors.Errorf("failed to put revocation for client %v: %w", client, err)
+	}
+	root, err := revocations.Root()
+	if err != nil {
+		return xerrors.Errorf("failed to flush revocations for client %v: %w", client, err)
+	}
+	newRoot := cbg.CborCid(root)
+	if err := m.revokedProposals.Put(abi.AddrKey(client), &newRoot); err != nil {
+		return xerrors.Errorf("failed to put revocations for client %v: %w", client, err)
+	}
 	return nil
 }
+
+// Checks whether a client holds an unlapsed revocation of a proposal.
+func (m *marketStateMutation) isProposalRevoked(client addr.Address, proposal cid.Cid, currEpoch abi.ChainEpoch) (bool, error) {
+	var root cbg.CborCid
+	found, err := m.revokedProposals.Get(abi.AddrKey(client), &root)
+	if err != nil {
+		return false, xerrors.Errorf("failed to get revocations for client %v: %w", client, err)
+	}
+	if !found {
+		return false, nil
+	}
+	revocations, err := adt.AsMap(m.store, cid.Cid(root), builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return false, xerrors.Errorf("failed to load revocations for client %v: %w", client, err)
+	}
+	var expiry cbg.CborInt
+	found, err = revocations.Get(abi.CidKey(proposal), &expiry)
+	if err != nil {
+		return false, xerrors.Errorf("failed to get revocation of %v for client %v: %w", proposal, client, err)
+	}
+	return found && currEpoch < abi.ChainEpoch(expiry), nil
+}
+
+// Removes a proposal awaiting acceptance, if it was recorded by the given client.
+func (m *marketStateMutation) removeClientProposal(client addr.Address, proposalCid cid.Cid) error {
+	var proposal DealProposal
+	found, err := m.clientProposals.Get(abi.CidKey(proposalCid), &proposal)
+	if err != nil {
+		return xerrors.Errorf("failed to get client proposal %s: %w", proposalCid, err)
+	}
+	if !found || proposal.Client != client {
+		return nil
+	}
+	return m.clientProposals.Delete(abi.CidKey(proposalCid))
+}
+
+// Bars a client's proposal, superseded by a replacement while its deal was pending, from being published
+// again until its start epoch has passed. Superseded proposals of the client that have since started are pruned first.
+func (m *marketStateMutation) supersedeProposal(client addr.Address, proposal cid.Cid, startEpoch, currEpoch abi.ChainEpoch) error {
+	superseded, err := m.loadClientProposalEpochs(m.supersededProposals, client)
+	if err != nil {
+		return err
+	}
+
+	var lapsed []cid.Cid
+	var supersededStart cbg.CborInt
+	err = superseded.ForEach(&supersededStart, func(k string) error {
+		if currEpoch > abi.ChainEpoch(supersededStart) {
+			c, err := cid.Cast([]byte(k))
+			if err != nil {
+				return err
+			}
+			lapsed = append(lapsed, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to iterate superseded proposals for client %v: %w", client, err)
+	}
+	for _, c := range lapsed {
+		if err := superseded.Delete(abi.CidKey(c)); err != nil {
+			return xerrors.Errorf("failed to delete lapsed superseded proposal for client %v: %w", client, err)
+		}
+	}
+
+	startValue := cbg.CborInt(startEpoch)
+	if err := superseded.Put(abi.CidKey(proposal), &startValue); err != nil {
+		return xerrors.Errorf("failed to put superseded proposal for client %v: %w", client, err)
+	}
+	root, err := superseded.Root()
+	if err != nil {
+		return xerrors.Errorf("failed to flush superseded proposals for client %v: %w", client, err)
+	}
+	newRoot := cbg.CborCid(root)
+	if err := m.supersededProposals.Put(abi.AddrKey(client), &newRoot); err != nil {
+		return xerrors.Errorf("failed to put superseded proposals for client %v: %w", client, err)
+	}
+	return nil
+}
+
+// Checks whether a proposal of a client was superseded while its deal was pending, and has yet to start.
+func (m *marketStateMutation) isProposalSuperseded(client addr.Address, proposal cid.Cid, currEpoch abi.ChainEpoch) (bool, error) {
+	var root cbg.CborCid
+	found, err := m.supersededProposals.Get(abi.AddrKey(client), &root)
+	if err != nil {
+		return false, xerrors.Errorf("failed to get superseded proposals for client %v: %w", client, err)
+	}
+	if !found {
+		return false, nil
+	}
+	superseded, err := adt.AsMap(m.store, cid.Cid(root), builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return false, xerrors.Errorf("failed to load superseded proposals for client %v: %w", client, err)
+	}
+	var startEpoch cbg.CborInt
+	found, err = superseded.Get(abi.CidKey(proposal), &startEpoch)
+	if err != nil {
+		return false, xerrors.Errorf("failed to get superseded proposal %v for client %v: %w", proposal, client, err)
+	}
+	return found && currEpoch <= abi.ChainEpoch(startEpoch), nil
+}
+
+// Checks whether a proposal of a client may be published: that it is not already pending, has not been
+// superseded since it was published, and is not revoked by the client. Returns the reason it may not be if not.
+func (m *marketStateMutation) checkProposalPublishable(client addr.Address, proposal cid.Cid, currEpoch abi.ChainEpoch) (bool, string, error) {
+	pending, err := m.pendingDeals.Has(abi.CidKey(proposal))
+	if err != nil {
+		return false, "", xerrors.Errorf("failed to check for existence of deal proposal %s: %w", proposal, err)
+	}
+	if pending {
+		return false, fmt.Sprintf("cannot publish duplicate deal proposal %s", proposal), nil
+	}
+	superseded, err := m.isProposalSuperseded(client, proposal, currEpoch)
+	if err != nil {
+		return false, "", err
+	}
+	if superseded {
+		return false, fmt.Sprintf("proposal %s was published and has since been replaced", proposal), nil
+	}
+	revoked, err := m.isProposalRevoked(client, proposal, currEpoch)
+	if err != nil {
+		return false, "", err
+	}
+	if revoked {
+		return false, fmt.Sprintf("proposal %s revoked by client %v", proposal, client), nil
+	}
+	return true, "", nil
+}
+
+// Loads the revocations held for a client, or an empty map if there are none.
+func (m *marketStateMutation) loadClientRevocations(client addr.Address) (*adt.Map, error) {
+	return m.loadClientProposalEpochs(m.revokedProposals, client)
+}
+
+// Loads a client's map of proposal CIDs to epochs from a map indexed by client, or an empty map if there is none.
+func (m *marketStateMutation) loadClientProposalEpochs(clients *adt.Map, client addr.Address) (*adt.Map, error) {
+	var root cbg.CborCid
+	found, err := clients.Get(abi.AddrKey(client), &root)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get proposals for client %v: %w", client, err)
+	}
+	if !found {
+		return adt.MakeEmptyMap(m.store, builtin.DefaultHamtBitwidth)
+	}
+	return adt.AsMap(m.store, cid.Cid(root), builtin.DefaultHamtBitwidth)
+}
+
+// Adds a deal to the label index under a key. Deals under the key that have since been cleaned up
+// are pruned first, and the number of deals under any one key is bounded by MaxDealsPerLabelIndexKey.
+// Requires read permission on deal proposals.
+func (m *marketStateMutation) indexDealLabel(key LabelIndexKey, dealID abi.DealID) error {
+	deals, err := m.loadLabelIndexEntry(key)
+	if err != nil {
+		return err
+	}
+	if indexed, err := deals.IsSet(uint64(dealID)); err != nil {
+		return xerrors.Errorf("failed to check label index entry: %w", err)
+	} else if indexed {
+		return nil
+	}
+
+	var removed []uint64
+	count := 0
+	err = deals.ForEach(func(id uint64) error {
+		_, found, err := m.dealProposals.Get(abi.DealID(id))
+		if err != nil {
+			return err
+		}
+		if found {
+			count++
+		} else {
+			removed = append(removed, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to iterate label index entry: %w", err)
+	}
+	if count >= MaxDealsPerLabelIndexKey {
+		return exitcode.ErrForbidden.Wrapf("label index entry already holds %d deals", count)
+	}
+	for _, id := range removed {
+		deals.Unset(id)
+	}
+	deals.Set(uint64(dealID))
+
+	if err := m.labelIndex.Put(key, deals); err != nil {
+		return xerrors.Errorf("failed to put label index entry: %w", err)
+	}
+	return nil
+}
+
+// Loads the deals indexed under a key, or an empty set if there are none.
+func (m *marketStateMutation) loadLabelIndexEntry(key LabelIndexKey) (*bitfield.BitField, error) {
+	deals := bitfield.New()
+	if _, err := m.labelIndex.Get(key, &deals); err != nil {
+		return nil, xerrors.Errorf("failed to get label index entry: %w", err)
+	}
+	return &deals, nil
+}
+
+func (m *marketStateMutation) getEscrowFunder(client addr.Address) (*EscrowFunder, bool, error) {
+	var funder EscrowFunder
+	found, err := m.escrowFunders.Get(abi.AddrKey(client), &funder)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to get escrow funder for %v: %w", client, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &funder, true, nil
+}
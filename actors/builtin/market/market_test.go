@@ -39,6 +39,14 @@ func mustCbor(o cbor.Marshaler) []byte {
 	return buf.Bytes()
 }
 
+func mustLabel(s string) market.DealLabel {
+	label, err := market.NewLabelFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return label
+}
+
 func TestExports(t *testing.T) {
 	mock.CheckActorExports(t, market.Actor{})
 }
@@ -49,7 +57,7 @@ func TestRemoveAllError(t *testing.T) {
 	rt := builder.Build(t)
 	store := adt.AsStore(rt)
 
-	smm, err := market.MakeEmptySetMultimap(store, builtin.DefaultHamtBitwidth)
+	smm, err := market.MakeEmptyProviderSetMultimap(store, builtin.DefaultHamtBitwidth)
 	require.NoError(t, err)
 
 	if err := smm.RemoveAll(42); err != nil {
@@ -57,6 +65,108 @@ func TestRemoveAllError(t *testing.T) {
 	}
 }
 
+func TestTypedIteration(t *testing.T) {
+	rt := mock.NewBuilder(tutil.NewIDAddr(t, 100)).Build(t)
+	store := adt.AsStore(rt)
+	ids := []abi.DealID{40, 3, 17, 8, 1 << 63}
+
+	t.Run("proposals", func(t *testing.T) {
+		arr, err := adt.MakeEmptyArray(store, market.ProposalsAmtBitwidth)
+		require.NoError(t, err)
+		root, err := arr.Root()
+		require.NoError(t, err)
+		proposals, err := market.AsDealProposalArray(store, root)
+		require.NoError(t, err)
+		for _, id := range ids {
+			require.NoError(t, proposals.Set(id, &market.DealProposal{
+				PieceCID:   tutil.MakeCID(fmt.Sprintf("%d", id), &market.PieceCIDPrefix),
+				Client:     tutil.NewIDAddr(t, 101),
+				Provider:   tutil.NewIDAddr(t, 102),
+				StartEpoch: abi.ChainEpoch(id % 1000),
+			}))
+		}
+
+		var seen []*market.DealProposal
+		require.NoError(t, proposals.ForEachProposal(func(id abi.DealID, proposal *market.DealProposal) error {
+			seen = append(seen, proposal)
+			return nil
+		}))
+		require.Len(t, seen, 5)
+		for i, id := range []abi.DealID{3, 8, 17, 40, 1 << 63} {
+			// Retained proposals are distinct.
+			assert.Equal(t, abi.ChainEpoch(id%1000), seen[i].StartEpoch)
+		}
+
+		var inRange []abi.DealID
+		require.NoError(t, proposals.ForEachProposalInRange(8, 40, func(id abi.DealID, _ *market.DealProposal) error {
+			inRange = append(inRange, id)
+			return nil
+		}))
+		assert.Equal(t, []abi.DealID{8, 17}, inRange)
+
+		require.NoError(t, proposals.ForEachProposalInRange(41, 41, func(id abi.DealID, _ *market.DealProposal) error {
+			t.Fatalf("unexpected deal %d in empty range", id)
+			return nil
+		}))
+		err = proposals.ForEachProposalInRange(40, 8, func(abi.DealID, *market.DealProposal) error { return nil })
+		assert.Error(t, err)
+	})
+
+	t.Run("states", func(t *testing.T) {
+		arr, err := adt.MakeEmptyArray(store, market.StatesAmtBitwidth)
+		require.NoError(t, err)
+		root, err := arr.Root()
+		require.NoError(t, err)
+		states, err := market.AsDealStateArray(store, root)
+		require.NoError(t, err)
+		for _, id := range ids {
+			require.NoError(t, states.Set(id, &market.DealState{SectorStartEpoch: abi.ChainEpoch(id % 1000)}))
+		}
+
+		var seen []abi.DealID
+		require.NoError(t, states.ForEachState(func(id abi.DealID, state *market.DealState) error {
+			assert.Equal(t, abi.ChainEpoch(id%1000), state.SectorStartEpoch)
+			seen = append(seen, id)
+			return nil
+		}))
+		assert.Equal(t, []abi.DealID{3, 8, 17, 40, 1 << 63}, seen)
+
+		seen = nil
+		require.NoError(t, states.ForEachStateInRange(9, 1<<63+1, func(id abi.DealID, _ *market.DealState) error {
+			seen = append(seen, id)
+			return nil
+		}))
+		assert.Equal(t, []abi.DealID{17, 40, 1 << 63}, seen)
+	})
+
+	t.Run("deal ops by epoch", func(t *testing.T) {
+		p1 := tutil.NewIDAddr(t, 102)
+		p2 := tutil.NewIDAddr(t, 103)
+		dobe, err := market.MakeEmptyProviderSetMultimap(store, builtin.DefaultHamtBitwidth)
+		require.NoError(t, err)
+		require.NoError(t, dobe.PutMany(5, p1, []abi.DealID{9, 300}))
+		require.NoError(t, dobe.PutMany(5, p2, []abi.DealID{1, 42}))
+		require.NoError(t, dobe.PutMany(3, p2, []abi.DealID{7}))
+		require.NoError(t, dobe.PutMany(8, p1, []abi.DealID{2}))
+
+		type op struct {
+			epoch    abi.ChainEpoch
+			provider address.Address
+			id       abi.DealID
+		}
+		var seen []op
+		require.NoError(t, dobe.ForEachInRange(3, 8, func(epoch abi.ChainEpoch, provider address.Address, id abi.DealID) error {
+			seen = append(seen, op{epoch, provider, id})
+			return nil
+		}))
+		assert.Equal(t, []op{{3, p2, 7}, {5, p2, 1}, {5, p1, 9}, {5, p2, 42}, {5, p1, 300}}, seen)
+
+		noop := func(abi.ChainEpoch, address.Address, abi.DealID) error { return nil }
+		assert.Error(t, dobe.ForEachInRange(8, 3, noop))
+		assert.Error(t, dobe.ForEachInRange(-1, 3, noop))
+	})
+}
+
 func TestMarketActor(t *testing.T) {
 	owner := tutil.NewIDAddr(t, 101)
 	provider := tutil.NewIDAddr(t, 102)
@@ -94,7 +204,7 @@ func TestMarketActor(t *testing.T) {
 		emptyStatesArrayCid, err := adt.StoreEmptyArray(store, market.StatesAmtBitwidth)
 		assert.NoError(t, err)
 
-		emptyMultiMap, err := market.StoreEmptySetMultimap(store, builtin.DefaultHamtBitwidth)
+		emptyMultiMap, err := market.StoreEmptyProviderSetMultimap(store, builtin.DefaultHamtBitwidth)
 		assert.NoError(t, err)
 
 		var state market.State
@@ -411,9 +521,10 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 	control := tutil.NewIDAddr(t, 200)
 	mAddr := &minerAddrs{owner, worker, provider, []address.Address{control}}
 
-	assertNGoodDeals := func(t *testing.T, dobe *market.SetMultimap, e abi.ChainEpoch, n int) {
+	assertNGoodDeals := func(t *testing.T, dobe *market.ProviderSetMultimap, e abi.ChainEpoch, n int) {
 		count := 0
-		err := dobe.ForEach(e, func(id abi.DealID) error {
+		err := dobe.ForEach(e, func(p address.Address, id abi.DealID) error {
+			assert.Equal(t, provider, p)
 			assert.Equal(t, uint64(e%market.DealUpdatesInterval), uint64(id%market.DealUpdatesInterval))
 			count++
 			return nil
@@ -439,7 +550,7 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 		// Check that DOBE has exactly 3 deals scheduled every epoch in the day following the start time
 		var st market.State
 		rt.GetState(&st)
-		dobe, err := market.AsSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
+		dobe, err := market.AsProviderSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
 		require.NoError(t, err)
 		for e := abi.ChainEpoch(market.DealUpdatesInterval); e < abi.ChainEpoch(2*market.DealUpdatesInterval); e++ {
 			assertNGoodDeals(t, dobe, e, 3)
@@ -470,7 +581,7 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 		}
 		var st market.State
 		rt.GetState(&st)
-		dobe, err := market.AsSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
+		dobe, err := market.AsProviderSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
 		require.NoError(t, err)
 		for e := abi.ChainEpoch(2880); e < abi.ChainEpoch(2880)+startEpoch; e++ {
 			assertNGoodDeals(t, dobe, e, 1)
@@ -487,7 +598,7 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 			assert.Equal(t, abi.DealID(i), dealID)
 		}
 		rt.GetState(&st)
-		dobe, err = market.AsSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
+		dobe, err = market.AsProviderSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
 		require.NoError(t, err)
 		for e := startEpoch; e < startEpoch+500; e++ {
 			assertNGoodDeals(t, dobe, e, 1)
@@ -575,12 +686,15 @@ func TestPublishStorageDeals(t *testing.T) {
 		// expect a call to verify the above signature
 		rt.ExpectVerifySignature(sig, deal.Client, buf.Bytes(), nil)
 
-		// request is sent to the VerigReg actor using the resolved address
-		param := &verifreg.UseBytesParams{
-			Address:  clientResolved,
-			DealSize: big.NewIntUnsigned(uint64(deal.PieceSize)),
+		// request is sent to the VerigReg actor using the resolved addresses
+		param := &verifreg.CreateAllocationParams{
+			Client:     clientResolved,
+			Provider:   providerResolved,
+			Size:       big.NewIntUnsigned(uint64(deal.PieceSize)),
+			Expiration: deal.StartEpoch,
 		}
-		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.UseBytes, param, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.CreateAllocation, param, abi.NewTokenAmount(0),
+			&verifreg.CreateAllocationReturn{AllocationID: 5}, exitcode.Ok)
 
 		deal2 := deal
 		deal2.Client = clientResolved
@@ -596,6 +710,7 @@ func TestPublishStorageDeals(t *testing.T) {
 		prop := actor.getDealProposal(rt, dealId)
 		require.EqualValues(t, clientResolved, prop.Client)
 		require.EqualValues(t, providerResolved, prop.Provider)
+		actor.assertDealAllocation(rt, dealId, 5)
 		actor.checkState(rt)
 	})
 
@@ -829,6 +944,24 @@ func TestPublishStorageDealsFailures(t *testing.T) {
 				},
 				exitCode: exitcode.ErrIllegalArgument,
 			},
+			"retrieval availability of zero": {
+				setup: func(_ *mock.Runtime, _ *marketActorTestHarness, d *market.DealProposal) {
+					d.RetrievalSLA = &market.RetrievalSLA{Availability: 0, ViolationPenalty: big.NewInt(1)}
+				},
+				exitCode: exitcode.ErrIllegalArgument,
+			},
+			"retrieval availability greater than denominator": {
+				setup: func(_ *mock.Runtime, _ *marketActorTestHarness, d *market.DealProposal) {
+					d.RetrievalSLA = &market.RetrievalSLA{Availability: market.RetrievalAvailabilityDenominator + 1, ViolationPenalty: big.NewInt(1)}
+				},
+				exitCode: exitcode.ErrIllegalArgument,
+			},
+			"retrieval violation penalty greater than provider collateral": {
+				setup: func(_ *mock.Runtime, _ *marketActorTestHarness, d *market.DealProposal) {
+					d.RetrievalSLA = &market.RetrievalSLA{Availability: 9_900, ViolationPenalty: big.Add(d.ProviderCollateral, big.NewInt(1))}
+				},
+				exitCode: exitcode.ErrIllegalArgument,
+			},
 			"client does not have enough balance for collateral": {
 				setup: func(rt *mock.Runtime, a *marketActorTestHarness, d *market.DealProposal) {
 					a.addParticipantFunds(rt, client, big.Sub(d.ClientBalanceRequirement(), big.NewInt(1)))
@@ -1109,6 +1242,208 @@ func TestActivateDeals(t *testing.T) {
 		actor.assertDealsNotActivated(rt, currentEpoch, dealId4)
 		actor.checkState(rt)
 	})
+
+	t.Run("verified deal allocations are claimed for the sector", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt.SetEpoch(currentEpoch)
+
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal1.VerifiedDeal = true
+		deal2 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+		deal3 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+2)
+		deal3.VerifiedDeal = true
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1}, publishDealReq{deal: deal2}, publishDealReq{deal: deal3})
+		actor.assertDealAllocation(rt, dealIds[0], 0)
+		actor.assertDealAllocation(rt, dealIds[2], 1)
+		_, allocated := actor.getDealAllocation(rt, dealIds[1])
+		assert.False(t, allocated)
+
+		// Both allocations are claimed in a single call, and removed from the market.
+		actor.activateDealsInSector(rt, 3, sectorExpiry, provider, currentEpoch, dealIds...)
+		actor.checkState(rt)
+	})
+
+	t.Run("activation fails if verified deal allocations cannot be claimed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt.SetEpoch(currentEpoch)
+
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal.VerifiedDeal = true
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.ClaimAllocations,
+			&verifreg.ClaimAllocationsParams{Provider: provider, Sector: 0, AllocationIDs: []verifreg.AllocationID{0}},
+			abi.NewTokenAmount(0), nil, exitcode.ErrIllegalArgument)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "failed to claim allocations", func() {
+			rt.Call(actor.ActivateDeals, mkActivateDealParams(sectorExpiry, dealIds...))
+		})
+		rt.Verify()
+
+		actor.assertDealsNotActivated(rt, currentEpoch, dealIds...)
+		actor.assertDealAllocation(rt, dealIds[0], 0)
+		actor.checkState(rt)
+	})
+}
+
+func TestBatchActivateDeals(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+
+	startEpoch := abi.ChainEpoch(10)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	currentEpoch := abi.ChainEpoch(5)
+	sectorExpiry := endEpoch + 100
+
+	batchActivate := func(rt *mock.Runtime, actor *marketActorTestHarness, sectors ...market.SectorDealActivation) []uint64 {
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		ret := rt.Call(actor.BatchActivateDeals, &market.BatchActivateDealsParams{Sectors: sectors}).(*market.BatchActivateDealsReturn)
+		rt.Verify()
+		activated, err := ret.Activated.All(uint64(len(sectors)))
+		require.NoError(t, err)
+		return activated
+	}
+	assertActivatedInSector := func(rt *mock.Runtime, actor *marketActorTestHarness, sectorNumber abi.SectorNumber, dealIDs ...abi.DealID) {
+		for _, dealID := range dealIDs {
+			s := actor.getDealState(rt, dealID)
+			assert.Equal(t, currentEpoch, s.SectorStartEpoch)
+			assert.Equal(t, sectorNumber, s.SectorNumber)
+		}
+	}
+
+	t.Run("activates the deals of each sector", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt.SetEpoch(currentEpoch)
+
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal1.VerifiedDeal = true
+		deal2 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+		deal3 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+2)
+		deal3.VerifiedDeal = true
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1}, publishDealReq{deal: deal2}, publishDealReq{deal: deal3})
+
+		// Allocations are claimed for each sector, and activated bytes recorded once for the batch.
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.ClaimAllocations,
+			&verifreg.ClaimAllocationsParams{Provider: provider, Sector: 1, AllocationIDs: []verifreg.AllocationID{0}},
+			abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.ClaimAllocations,
+			&verifreg.ClaimAllocationsParams{Provider: provider, Sector: 2, AllocationIDs: []verifreg.AllocationID{1}},
+			abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RecordActivatedBytes,
+			&verifreg.RecordActivatedBytesParams{Activations: []verifreg.ActivatedBytes{
+				{Client: client, Provider: provider, DealSize: big.NewIntUnsigned(uint64(deal1.PieceSize))},
+				{Client: client, Provider: provider, DealSize: big.NewIntUnsigned(uint64(deal3.PieceSize))},
+			}}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		activated := batchActivate(rt, actor,
+			market.SectorDealActivation{SectorNumber: 1, SectorExpiry: sectorExpiry, DealIDs: dealIds[:2]},
+			market.SectorDealActivation{SectorNumber: 2, SectorExpiry: sectorExpiry, DealIDs: dealIds[2:]},
+		)
+		assert.Equal(t, []uint64{0, 1}, activated)
+
+		assertActivatedInSector(rt, actor, 1, dealIds[:2]...)
+		assertActivatedInSector(rt, actor, 2, dealIds[2])
+		actor.checkState(rt)
+	})
+
+	t.Run("a sector with a deal that cannot be activated does not affect the others", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt.SetEpoch(currentEpoch)
+
+		dealId1 := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		dealId2 := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch+1)
+		dealId3 := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch+2)
+
+		activated := batchActivate(rt, actor,
+			market.SectorDealActivation{SectorNumber: 1, SectorExpiry: sectorExpiry, DealIDs: []abi.DealID{dealId1}},
+			// The sector expires before the second deal ends, so neither of its deals is activated.
+			market.SectorDealActivation{SectorNumber: 2, SectorExpiry: endEpoch, DealIDs: []abi.DealID{dealId3, dealId2}},
+		)
+		assert.Equal(t, []uint64{0}, activated)
+
+		assertActivatedInSector(rt, actor, 1, dealId1)
+		actor.assertDealsNotActivated(rt, currentEpoch, dealId2, dealId3)
+		actor.checkState(rt)
+	})
+
+	t.Run("a deal named by more than one sector is activated in the first", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt.SetEpoch(currentEpoch)
+
+		dealId1 := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		dealId2 := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch+1)
+
+		activated := batchActivate(rt, actor,
+			market.SectorDealActivation{SectorNumber: 1, SectorExpiry: sectorExpiry, DealIDs: []abi.DealID{dealId1}},
+			market.SectorDealActivation{SectorNumber: 2, SectorExpiry: sectorExpiry, DealIDs: []abi.DealID{dealId2, dealId1}},
+		)
+		assert.Equal(t, []uint64{0}, activated)
+
+		assertActivatedInSector(rt, actor, 1, dealId1)
+		actor.assertDealsNotActivated(rt, currentEpoch, dealId2)
+		actor.checkState(rt)
+	})
+
+	t.Run("a sector naming a deal of an earlier sector claims no allocations", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt.SetEpoch(currentEpoch)
+
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal2 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+		deal2.VerifiedDeal = true
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1}, publishDealReq{deal: deal2})
+
+		// The second sector fails before its verified deal's allocation is claimed.
+		activated := batchActivate(rt, actor,
+			market.SectorDealActivation{SectorNumber: 1, SectorExpiry: sectorExpiry, DealIDs: dealIds[:1]},
+			market.SectorDealActivation{SectorNumber: 2, SectorExpiry: sectorExpiry, DealIDs: []abi.DealID{dealIds[1], dealIds[0]}},
+		)
+		assert.Equal(t, []uint64{0}, activated)
+
+		assertActivatedInSector(rt, actor, 1, dealIds[0])
+		actor.assertDealsNotActivated(rt, currentEpoch, dealIds[1])
+		actor.assertDealAllocation(rt, dealIds[1], 0)
+		actor.checkState(rt)
+	})
+
+	t.Run("a sector whose allocations cannot be claimed is not activated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt.SetEpoch(currentEpoch)
+
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal1.VerifiedDeal = true
+		deal2 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1}, publishDealReq{deal: deal2})
+
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.ClaimAllocations,
+			&verifreg.ClaimAllocationsParams{Provider: provider, Sector: 1, AllocationIDs: []verifreg.AllocationID{0}},
+			abi.NewTokenAmount(0), nil, exitcode.ErrIllegalArgument)
+		activated := batchActivate(rt, actor,
+			market.SectorDealActivation{SectorNumber: 1, SectorExpiry: sectorExpiry, DealIDs: dealIds[:1]},
+			market.SectorDealActivation{SectorNumber: 2, SectorExpiry: sectorExpiry, DealIDs: dealIds[1:]},
+		)
+		assert.Equal(t, []uint64{1}, activated)
+
+		actor.assertDealsNotActivated(rt, currentEpoch, dealIds[0])
+		actor.assertDealAllocation(rt, dealIds[0], 0)
+		assertActivatedInSector(rt, actor, 2, dealIds[1])
+		actor.checkState(rt)
+	})
 }
 
 func TestActivateDealFailures(t *testing.T) {
@@ -1336,10 +1671,9 @@ func TestOnMinerSectorsTerminate(t *testing.T) {
 		// set current epoch such that deal3 expires but the other two do not
 		newEpoch := rt.SetEpoch(endEpoch - 1)
 
-		// terminating all three deals ONLY terminates deal1 and deal2 because deal3 has expired
+		// terminating all three deals records the termination epoch for each, which for deal3 is its end epoch
 		actor.terminateDeals(rt, provider, dealId1, dealId2, dealId3)
-		actor.assertDealsTerminated(rt, newEpoch, dealId1, dealId2)
-		actor.assertDeaslNotTerminated(rt, dealId3)
+		actor.assertDealsTerminated(rt, newEpoch, dealId1, dealId2, dealId3)
 		actor.checkState(rt)
 	})
 
@@ -1412,7 +1746,7 @@ func TestOnMinerSectorsTerminate(t *testing.T) {
 		actor.checkState(rt)
 	})
 
-	t.Run("do not terminate deal if end epoch is equal to or less than current epoch", func(t *testing.T) {
+	t.Run("record deal end epoch if end epoch is equal to or less than termination epoch", func(t *testing.T) {
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
 		rt.SetEpoch(currentEpoch)
 
@@ -1421,7 +1755,7 @@ func TestOnMinerSectorsTerminate(t *testing.T) {
 		actor.activateDeals(rt, sectorExpiry, provider, currentEpoch, dealId1)
 		rt.SetEpoch(endEpoch)
 		actor.terminateDeals(rt, provider, dealId1)
-		actor.assertDeaslNotTerminated(rt, dealId1)
+		actor.assertDealsTerminated(rt, endEpoch, dealId1)
 
 		// deal2 has end epoch less than current epoch when terminate is called
 		rt.SetEpoch(currentEpoch)
@@ -1429,7 +1763,43 @@ func TestOnMinerSectorsTerminate(t *testing.T) {
 		actor.activateDeals(rt, sectorExpiry, provider, currentEpoch, dealId2)
 		rt.SetEpoch(endEpoch + 1)
 		actor.terminateDeals(rt, provider, dealId2)
-		actor.assertDeaslNotTerminated(rt, dealId2)
+		actor.assertDealsTerminated(rt, endEpoch, dealId2)
+		actor.checkState(rt)
+	})
+
+	t.Run("report deals not found", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+
+		dealId1 := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		dealId2 := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch+1)
+		actor.activateDeals(rt, sectorExpiry, provider, currentEpoch, dealId1, dealId2)
+		missing := dealId2 + 100
+
+		ret := actor.terminateDeals(rt, provider, dealId1, missing, dealId2, dealId1)
+		assert.Equal(t, []abi.DealID{missing}, ret.NotFound)
+		actor.assertDealsTerminated(rt, currentEpoch, dealId1, dealId2)
+
+		// Terminating again reports nothing missing and leaves the slash epochs unchanged.
+		rt.SetEpoch(currentEpoch + 1)
+		ret = actor.terminateDeals(rt, provider, dealId1, dealId2)
+		assert.Empty(t, ret.NotFound)
+		actor.assertDealsTerminated(rt, currentEpoch, dealId1, dealId2)
+		actor.checkState(rt)
+	})
+
+	t.Run("fail when terminating too many deals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+
+		params := mkTerminateDealParams(currentEpoch, make([]abi.DealID, market.MaxDealsTerminatedPerCall+1)...)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too many deals to terminate", func() {
+			rt.Call(actor.OnMinerSectorsTerminate, params)
+		})
+
+		rt.Verify()
 		actor.checkState(rt)
 	})
 
@@ -1533,6 +1903,9 @@ func TestCronTick(t *testing.T) {
 		actor.checkState(rt,
 			"no deal proposal for deal state \\d+",
 			"pending proposal with cid \\w+ not found within proposals .*",
+			"total client locked collateral, \\d+, does not match deals, 0",
+			"total provider locked collateral, \\d+, does not match deals, 0",
+			"total client storage fee, \\d+, does not match deals, 0",
 			"deal op found for deal id \\d+ with missing proposal at epoch \\d+",
 		)
 	})
@@ -1888,40 +2261,43 @@ func TestCronTickTimedoutDeals(t *testing.T) {
 
 	t.Run("timed out and verified deals are slashed, deleted AND sent to the Registry actor", func(t *testing.T) {
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
-		// deal1 and deal2 are verified
+		// deal1, deal2 and deal3 are verified
 		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
 		deal1.VerifiedDeal = true
 		deal2 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
 		deal2.VerifiedDeal = true
-
-		// deal3 is NOT verified
 		deal3 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+2)
+		deal3.VerifiedDeal = true
+
+		// deal4 is NOT verified
+		deal4 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+3)
 
 		//  publishing verified deals
 		rt.SetCaller(worker, builtin.AccountActorCodeID)
-		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal1},
-			publishDealReq{deal2}, publishDealReq{deal3})
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1},
+			publishDealReq{deal: deal2}, publishDealReq{deal: deal3}, publishDealReq{deal: deal4})
+
+		// deal1 and deal2 were published before allocations, so used their client's datacap directly
+		actor.removeDealAllocation(rt, dealIds[0])
+		actor.removeDealAllocation(rt, dealIds[1])
 
 		// do a cron tick for it -> all should time out and get slashed
-		// ONLY deal1 and deal2 should be sent to the Registry actor
+		// ONLY deal1 and deal2 should be sent to the Registry actor; the client removes deal3's expired allocation
 		rt.SetEpoch(processEpoch(t, dealIds[len(dealIds)-1], startEpoch))
 
-		// expected sends to the registry actor
-		param1 := &verifreg.RestoreBytesParams{
+		// expected send to the registry actor, restoring both deals at once
+		param := &verifreg.RestoreBytesBatchParams{Restorations: []verifreg.RestoreBytesParams{{
 			Address:  deal1.Client,
 			DealSize: big.NewIntUnsigned(uint64(deal1.PieceSize)),
-		}
-		param2 := &verifreg.RestoreBytesParams{
+		}, {
 			Address:  deal2.Client,
 			DealSize: big.NewIntUnsigned(uint64(deal2.PieceSize)),
-		}
+		}}}
 
-		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RestoreBytes, param1,
-			abi.NewTokenAmount(0), nil, exitcode.Ok)
-		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RestoreBytes, param2,
-			abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RestoreBytesBatch, param,
+			abi.NewTokenAmount(0), &verifreg.RestoreBytesBatchReturn{}, exitcode.Ok)
 
-		expectedBurn := big.Mul(big.NewInt(3), deal1.ProviderCollateral)
+		expectedBurn := big.Mul(big.NewInt(4), deal1.ProviderCollateral)
 		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedBurn, nil, exitcode.Ok)
 		actor.cronTick(rt)
 
@@ -1932,6 +2308,34 @@ func TestCronTickTimedoutDeals(t *testing.T) {
 		actor.assertDealDeleted(rt, dealIds[0], &deal1)
 		actor.assertDealDeleted(rt, dealIds[1], &deal2)
 		actor.assertDealDeleted(rt, dealIds[2], &deal3)
+		actor.assertDealDeleted(rt, dealIds[3], &deal4)
+		_, allocated := actor.getDealAllocation(rt, dealIds[2])
+		assert.False(t, allocated)
+		actor.checkState(rt)
+	})
+
+	t.Run("a failure to restore data cap does not prevent timed out deals being removed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal1.VerifiedDeal = true
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1})
+		actor.removeDealAllocation(rt, dealIds[0])
+		rt.SetEpoch(processEpoch(t, dealIds[0], startEpoch))
+
+		param := &verifreg.RestoreBytesBatchParams{Restorations: []verifreg.RestoreBytesParams{{
+			Address:  deal1.Client,
+			DealSize: big.NewIntUnsigned(uint64(deal1.PieceSize)),
+		}}}
+		ret := &verifreg.RestoreBytesBatchReturn{Failures: []verifreg.RestoreBytesFailure{{Index: 0, Code: exitcode.ErrIllegalArgument}}}
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RestoreBytesBatch, param,
+			abi.NewTokenAmount(0), ret, exitcode.Ok)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, deal1.ProviderCollateral, nil, exitcode.Ok)
+		actor.cronTick(rt)
+
+		actor.assertAccountZero(rt, provider)
+		actor.assertDealDeleted(rt, dealIds[0], &deal1)
 		actor.checkState(rt)
 	})
 }
@@ -2219,6 +2623,27 @@ func TestCronTickDealSlashing(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("deal terminated after its end epoch records the end epoch and is settled as expired", func(t *testing.T) {
+		t.Parallel()
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		d := actor.getDealProposal(rt, dealId)
+
+		// the sector is terminated after the deal ended, but before the deal's expiry is processed
+		rt.SetEpoch(endEpoch + 10)
+		actor.terminateDeals(rt, provider, dealId)
+		actor.assertDealsTerminated(rt, endEpoch, dealId)
+
+		current := rt.SetEpoch(endEpoch + 300)
+		pay, slashed := actor.cronTickAndAssertBalances(rt, client, provider, current, dealId)
+		duration := big.NewInt(int64(endEpoch - startEpoch))
+		require.EqualValues(t, big.Mul(duration, d.StoragePricePerEpoch), pay)
+		require.EqualValues(t, big.Zero(), slashed)
+		actor.assertDealDeleted(rt, dealId, d)
+
+		actor.checkState(rt)
+	})
+
 	t.Run("deal is correctly processed twice in the same crontick and slashed", func(t *testing.T) {
 		t.Parallel()
 		// start epoch should equal first processing epoch for logic to work
@@ -2375,31 +2800,139 @@ func TestCronTickDealSlashing(t *testing.T) {
 	})
 }
 
-func TestMarketActorDeals(t *testing.T) {
+func TestDataOnboardingDeals(t *testing.T) {
 	owner := tutil.NewIDAddr(t, 101)
 	provider := tutil.NewIDAddr(t, 102)
 	worker := tutil.NewIDAddr(t, 103)
 	client := tutil.NewIDAddr(t, 104)
-	minerAddrs := &minerAddrs{owner, worker, provider, nil}
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
 
-	var st market.State
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 400
 
-	// Test adding provider funds from both worker and owner address
-	rt, actor := basicMarketSetup(t, owner, provider, worker, client)
-	actor.addProviderFunds(rt, abi.NewTokenAmount(20000000), minerAddrs)
-	rt.GetState(&st)
-	assert.Equal(t, abi.NewTokenAmount(20000000), actor.getEscrowBalance(rt, provider))
+	onboardingDeal := func() market.DealProposal {
+		deal := generateDealProposalWithCollateral(client, provider, big.Zero(), big.Zero(), startEpoch, endEpoch)
+		deal.StoragePricePerEpoch = big.Zero()
+		deal.VerifiedDeal = true
+		return deal
+	}
+	balanceTables := func(rt *mock.Runtime) (cid.Cid, cid.Cid) {
+		var st market.State
+		rt.GetState(&st)
+		return st.EscrowTable, st.LockedTable
+	}
+	setup := func(t *testing.T) (*mock.Runtime, *marketActorTestHarness) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		// A non-zero minimum provider collateral, from which onboarding deals are exempt.
+		rt.SetCirculatingSupply(actor.networkQAPower)
+		return rt, actor
+	}
 
-	actor.addParticipantFunds(rt, client, abi.NewTokenAmount(20000000))
+	t.Run("deal is published and expires without balances", func(t *testing.T) {
+		rt, actor := setup(t)
+		escrow, locked := balanceTables(rt)
 
-	dealProposal := generateDealProposal(client, provider, abi.ChainEpoch(1), abi.ChainEpoch(200*builtin.EpochsInDay))
-	params := &market.PublishStorageDealsParams{Deals: []market.ClientDealProposal{{Proposal: dealProposal}}}
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: onboardingDeal()})
+		actor.activateDeals(rt, sectorExpiry, provider, 0, dealIds[0])
+		d := actor.getDealProposal(rt, dealIds[0])
+
+		rt.SetEpoch(endEpoch + 5)
+		actor.cronTick(rt)
+		actor.assertDealDeleted(rt, dealIds[0], d)
+
+		escrowAfter, lockedAfter := balanceTables(rt)
+		assert.Equal(t, escrow, escrowAfter)
+		assert.Equal(t, locked, lockedAfter)
+		actor.checkState(rt)
+	})
+
+	t.Run("terminated deal is removed without slashing", func(t *testing.T) {
+		rt, actor := setup(t)
+		escrow, locked := balanceTables(rt)
 
-	// First attempt at publishing the deal should work
-	{
 		rt.SetCaller(worker, builtin.AccountActorCodeID)
-		actor.publishDeals(rt, minerAddrs, publishDealReq{deal: dealProposal})
-	}
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: onboardingDeal()})
+		actor.activateDeals(rt, sectorExpiry, provider, 0, dealIds[0])
+		d := actor.getDealProposal(rt, dealIds[0])
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.terminateDeals(rt, provider, dealIds[0])
+		rt.SetEpoch(processEpoch(t, dealIds[0], startEpoch) + market.DealUpdatesInterval)
+		actor.cronTick(rt)
+		actor.assertDealDeleted(rt, dealIds[0], d)
+
+		escrowAfter, lockedAfter := balanceTables(rt)
+		assert.Equal(t, escrow, escrowAfter)
+		assert.Equal(t, locked, lockedAfter)
+		actor.checkState(rt)
+	})
+
+	t.Run("timed out deal is removed without slashing", func(t *testing.T) {
+		rt, actor := setup(t)
+		escrow, locked := balanceTables(rt)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: onboardingDeal()})
+		d := actor.getDealProposal(rt, dealIds[0])
+
+		// The deal's allocation is left for the client to remove, and nothing is burnt.
+		rt.SetEpoch(processEpoch(t, dealIds[0], startEpoch))
+		actor.cronTick(rt)
+		actor.assertDealDeleted(rt, dealIds[0], d)
+
+		escrowAfter, lockedAfter := balanceTables(rt)
+		assert.Equal(t, escrow, escrowAfter)
+		assert.Equal(t, locked, lockedAfter)
+		actor.checkState(rt)
+	})
+
+	t.Run("minimum provider collateral applies when onboarding deals are disabled", func(t *testing.T) {
+		market.DataOnboardingDealsEnabled = false
+		defer func() { market.DataOnboardingDealsEnabled = true }()
+		rt, actor := setup(t)
+
+		deal := onboardingDeal()
+		params := mkPublishStorageParams(deal)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		expectQueryNetworkInfo(rt, actor)
+		rt.ExpectVerifySignature(crypto.Signature{}, deal.Client, mustCbor(&deal), nil)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+			rt.Call(actor.PublishStorageDeals, params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+}
+
+func TestMarketActorDeals(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	minerAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	var st market.State
+
+	// Test adding provider funds from both worker and owner address
+	rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+	actor.addProviderFunds(rt, abi.NewTokenAmount(20000000), minerAddrs)
+	rt.GetState(&st)
+	assert.Equal(t, abi.NewTokenAmount(20000000), actor.getEscrowBalance(rt, provider))
+
+	actor.addParticipantFunds(rt, client, abi.NewTokenAmount(20000000))
+
+	dealProposal := generateDealProposal(client, provider, abi.ChainEpoch(1), abi.ChainEpoch(200*builtin.EpochsInDay))
+	params := &market.PublishStorageDealsParams{Deals: []market.ClientDealProposal{{Proposal: dealProposal}}}
+
+	// First attempt at publishing the deal should work
+	{
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, minerAddrs, publishDealReq{deal: dealProposal})
+	}
 
 	// Second attempt at publishing the same deal should fail
 	{
@@ -2415,7 +2948,7 @@ func TestMarketActorDeals(t *testing.T) {
 		rt.Verify()
 	}
 
-	dealProposal.Label = "foo"
+	dealProposal.Label = mustLabel("foo")
 
 	// Same deal with a different label should work
 	{
@@ -2425,6 +2958,100 @@ func TestMarketActorDeals(t *testing.T) {
 	actor.checkState(rt)
 }
 
+func TestDerivedDealIDs(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+
+	setup := func(t *testing.T) (*mock.Runtime, *marketActorTestHarness) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.addProviderFunds(rt, abi.NewTokenAmount(20000000), mAddrs)
+		actor.addParticipantFunds(rt, client, abi.NewTokenAmount(20000000))
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		return rt, actor
+	}
+	derivedID := func(t *testing.T, deal market.DealProposal) abi.DealID {
+		pcid, err := deal.Cid()
+		require.NoError(t, err)
+		id, err := market.DerivedDealID(pcid)
+		require.NoError(t, err)
+		return id
+	}
+	nextID := func(rt *mock.Runtime) abi.DealID {
+		var st market.State
+		rt.GetState(&st)
+		return st.NextID
+	}
+	enable := func() func() {
+		market.DealIDsFromProposalCID = true
+		return func() { market.DealIDsFromProposalCID = false }
+	}
+
+	t.Run("deals take IDs derived from their proposal CIDs", func(t *testing.T) {
+		defer enable()()
+		rt, actor := setup(t)
+
+		deal1 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch+1)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1}, publishDealReq{deal: deal2})
+		assert.Equal(t, []abi.DealID{derivedID(t, deal1), derivedID(t, deal2)}, dealIds)
+		for _, id := range dealIds {
+			assert.True(t, market.IsDerivedDealID(id))
+		}
+
+		// The counter is not advanced.
+		assert.Equal(t, abi.DealID(0), nextID(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("republishing a pending proposal returns its deal ID without locking more funds", func(t *testing.T) {
+		defer enable()()
+		rt, actor := setup(t)
+
+		deal1 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1})
+		clientLocked := actor.getLockedBalance(rt, client)
+		providerLocked := actor.getLockedBalance(rt, provider)
+
+		// The republished deal is returned in input order alongside a new one.
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch+1)
+		republished := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal2}, publishDealReq{deal: deal1})
+		assert.Equal(t, []abi.DealID{derivedID(t, deal2), dealIds[0]}, republished)
+
+		assert.Equal(t, big.Add(clientLocked, deal2.ClientBalanceRequirement()), actor.getLockedBalance(rt, client))
+		assert.Equal(t, big.Add(providerLocked, deal2.ProviderCollateral), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+	})
+
+	t.Run("deals published with counter IDs are kept when derived IDs are enabled", func(t *testing.T) {
+		rt, actor := setup(t)
+
+		deal1 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1})
+		assert.Equal(t, abi.DealID(0), dealIds[0])
+
+		defer enable()()
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch+1)
+		dealIds = actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal2})
+		assert.Equal(t, derivedID(t, deal2), dealIds[0])
+		assert.Equal(t, abi.DealID(1), nextID(rt))
+		actor.getDealProposal(rt, 0)
+
+		// A deal with a counter ID cannot be found from its proposal, so is rejected as a duplicate.
+		params := actor.expectPublishDeals(rt, mAddrs, publishDealReq{deal: deal1})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+			rt.Call(actor.PublishStorageDeals, params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
 func TestMaxDealLabelSize(t *testing.T) {
 	owner := tutil.NewIDAddr(t, 101)
 	provider := tutil.NewIDAddr(t, 102)
@@ -2443,7 +3070,7 @@ func TestMaxDealLabelSize(t *testing.T) {
 	actor.addParticipantFunds(rt, client, abi.NewTokenAmount(20000000))
 
 	dealProposal := generateDealProposal(client, provider, abi.ChainEpoch(1), abi.ChainEpoch(200*builtin.EpochsInDay))
-	dealProposal.Label = string(make([]byte, market.DealMaxLabelSize))
+	dealProposal.Label = mustLabel(string(make([]byte, market.DealMaxLabelSize)))
 	params := &market.PublishStorageDealsParams{Deals: []market.ClientDealProposal{{Proposal: dealProposal}}}
 
 	// Label at max size should work.
@@ -2452,7 +3079,7 @@ func TestMaxDealLabelSize(t *testing.T) {
 		actor.publishDeals(rt, minerAddrs, publishDealReq{deal: dealProposal})
 	}
 
-	dealProposal.Label = string(make([]byte, market.DealMaxLabelSize+1))
+	dealProposal.Label = market.NewLabelFromBytes(make([]byte, market.DealMaxLabelSize+1))
 
 	// Label greater than max size should fail.
 	{
@@ -2801,146 +3428,2902 @@ type marketActorTestHarness struct {
 
 	networkQAPower       abi.StoragePower
 	networkBaselinePower abi.StoragePower
-}
 
-func (h *marketActorTestHarness) constructAndVerify(rt *mock.Runtime) {
-	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
-	ret := rt.Call(h.Constructor, nil)
-	assert.Nil(h.t, ret)
-	rt.Verify()
+	// The ID returned for the next verified registry allocation expected to be made.
+	nextAllocationID verifreg.AllocationID
 }
 
-func (h *marketActorTestHarness) verifyDealsForActivation(rt *mock.Runtime, provider address.Address,
-	sectorDeals []market.SectorDeals) *market.VerifyDealsForActivationReturn {
-	param := &market.VerifyDealsForActivationParams{Sectors: sectorDeals}
-	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
-	rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+func TestProviderAsks(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
 
-	ret := rt.Call(h.VerifyDealsForActivation, param)
-	rt.Verify()
+	startEpoch := abi.ChainEpoch(42)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	standingAsk := market.ProviderAsk{
+		MinPricePerEpoch:            big.NewInt(10),
+		MinPieceSize:                abi.PaddedPieceSize(1024),
+		MaxPieceSize:                abi.PaddedPieceSize(4096),
+		MinStartDelay:               startEpoch - 10,
+		MinDuration:                 180 * builtin.EpochsInDay,
+		MaxDuration:                 200 * builtin.EpochsInDay,
+		MaxProviderCollateral:       big.NewInt(100),
+		MaxTotalProviderCollateral:  big.NewInt(250),
+		ProviderCollateralCommitted: big.Zero(),
+		VerifiedOnly:                false,
+		Expiry:                      abi.ChainEpoch(20),
+	}
 
-	val, ok := ret.(*market.VerifyDealsForActivationReturn)
-	require.True(h.t, ok)
-	require.NotNil(h.t, val)
-	return val
-}
+	t.Run("post and withdraw an ask", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.postProviderAsk(rt, mAddrs, standingAsk)
 
-type minerAddrs struct {
-	owner    address.Address
-	worker   address.Address
-	provider address.Address
-	control  []address.Address
-}
+		ask, found := actor.getProviderAsk(rt, provider)
+		require.True(t, found)
+		assert.Equal(t, standingAsk, *ask)
 
-// addProviderFunds is a helper method to setup provider market funds
-func (h *marketActorTestHarness) addProviderFunds(rt *mock.Runtime, amount abi.TokenAmount, minerAddrs *minerAddrs) {
-	rt.SetReceived(amount)
-	rt.SetAddressActorType(minerAddrs.provider, builtin.StorageMinerActorCodeID)
-	rt.SetCaller(minerAddrs.owner, builtin.AccountActorCodeID)
-	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		actor.withdrawProviderAsk(rt, mAddrs)
+		_, found = actor.getProviderAsk(rt, provider)
+		require.False(t, found)
 
-	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker)
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			actor.withdrawProviderAsk(rt, mAddrs)
+		})
+		actor.checkState(rt)
+	})
 
-	rt.Call(h.AddBalance, &minerAddrs.provider)
-	rt.Verify()
-	rt.SetBalance(big.Add(rt.Balance(), amount))
-}
+	t.Run("fails to post an inconsistent ask", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		ask := standingAsk
+		ask.MinPieceSize = ask.MaxPieceSize * 2
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "minimum piece size", func() {
+			actor.postProviderAsk(rt, mAddrs, ask)
+		})
 
-// addParticipantFunds is a helper method to setup non-provider storage market participant funds
-func (h *marketActorTestHarness) addParticipantFunds(rt *mock.Runtime, addr address.Address, amount abi.TokenAmount) {
-	rt.SetReceived(amount)
-	rt.SetCaller(addr, builtin.AccountActorCodeID)
-	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		ask = standingAsk
+		ask.Expiry = rt.Epoch()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be after current epoch", func() {
+			actor.postProviderAsk(rt, mAddrs, ask)
+		})
 
-	rt.Call(h.AddBalance, &addr)
-	rt.Verify()
-	rt.SetBalance(big.Add(rt.Balance(), amount))
-}
+		ask = standingAsk
+		ask.MaxTotalProviderCollateral = big.Sub(ask.MaxProviderCollateral, big.NewInt(1))
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "less than maximum per deal", func() {
+			actor.postProviderAsk(rt, mAddrs, ask)
+		})
 
-func (h *marketActorTestHarness) withdrawProviderBalance(rt *mock.Runtime, withDrawAmt, expectedSend abi.TokenAmount, miner *minerAddrs) {
-	rt.SetCaller(miner.worker, builtin.AccountActorCodeID)
-	rt.ExpectValidateCallerAddr(miner.owner, miner.worker)
-	expectGetControlAddresses(rt, miner.provider, miner.owner, miner.worker)
+		ask = standingAsk
+		ask.ProviderCollateralCommitted = big.NewInt(1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "already committed", func() {
+			actor.postProviderAsk(rt, mAddrs, ask)
+		})
 
-	params := market.WithdrawBalanceParams{
-		ProviderOrClientAddress: miner.provider,
-		Amount:                  withDrawAmt,
-	}
+		ask = standingAsk
+		ask.MinStartDelay = -1
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "negative minimum start delay", func() {
+			actor.postProviderAsk(rt, mAddrs, ask)
+		})
 
-	rt.ExpectSend(miner.owner, builtin.MethodSend, nil, expectedSend, nil, exitcode.Ok)
-	ret := rt.Call(h.WithdrawBalance, &params)
-	withdrawn, ok := ret.(*abi.TokenAmount)
-	require.True(h.t, ok, "unexpected return type from WithdrawBalance")
-	require.NotNil(h.t, withdrawn)
-	rt.Verify()
+		ask = standingAsk
+		ask.MinDuration = ask.MaxDuration + 1
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "duration range", func() {
+			actor.postProviderAsk(rt, mAddrs, ask)
+		})
+		actor.checkState(rt)
+	})
 
-	assert.Equal(h.t, expectedSend, *withdrawn, "return value indicates %s withdrawn but expected %s", *withdrawn, expectedSend)
-}
+	t.Run("fails to post an ask from a non-control address", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(worker)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.PostProviderAsk, &market.PostProviderAskParams{Provider: provider, Ask: standingAsk})
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
 
-func (h *marketActorTestHarness) withdrawClientBalance(rt *mock.Runtime, client address.Address, withDrawAmt, expectedSend abi.TokenAmount) {
-	rt.SetCaller(client, builtin.AccountActorCodeID)
-	rt.ExpectSend(client, builtin.MethodSend, nil, expectedSend, nil, exitcode.Ok)
-	rt.ExpectValidateCallerAddr(client)
+	t.Run("client publishes a deal matching the standing ask", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.postProviderAsk(rt, mAddrs, standingAsk)
 
-	params := market.WithdrawBalanceParams{
-		ProviderOrClientAddress: client,
-		Amount:                  withDrawAmt,
-	}
-	ret := rt.Call(h.WithdrawBalance, &params)
-	withdrawn, ok := ret.(*abi.TokenAmount)
-	require.True(h.t, ok, "unexpected return type from WithdrawBalance")
-	require.NotNil(h.t, withdrawn)
-	rt.Verify()
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+		require.Len(t, dealIDs, 1)
+		actor.checkState(rt)
+	})
 
-	assert.Equal(h.t, expectedSend, *withdrawn, "return value indicates %s withdrawn but expected %s", *withdrawn, expectedSend)
-}
+	t.Run("client deals not matching the standing ask are dropped", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.postProviderAsk(rt, mAddrs, standingAsk)
 
-func (h *marketActorTestHarness) cronTickNoChange(rt *mock.Runtime, client, provider address.Address) {
-	var st market.State
-	rt.GetState(&st)
-	epochCid := st.DealOpsByEpoch
+		cheap := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		cheap.StoragePricePerEpoch = big.Sub(standingAsk.MinPricePerEpoch, big.NewInt(1))
+		large := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+1, endEpoch)
+		large.PieceSize = standingAsk.MaxPieceSize * 2
+		collateralized := actor.generateDealWithCollateralAndAddFunds(rt, client, mAddrs,
+			big.Add(standingAsk.MaxProviderCollateral, big.NewInt(1)), big.NewInt(10), startEpoch+2, endEpoch)
 
-	// fetch current client and provider escrow balances
-	cLocked := h.getLockedBalance(rt, client)
-	cEscrow := h.getEscrowBalance(rt, client)
-	pLocked := h.getLockedBalance(rt, provider)
-	pEscrow := h.getEscrowBalance(rt, provider)
+		publishDropped := func(deal market.DealProposal) {
+			rt.SetCaller(client, builtin.AccountActorCodeID)
+			rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+			expectGetControlAddresses(rt, provider, owner, worker)
+			expectQueryNetworkInfo(rt, actor)
+			rt.ExpectVerifySignature(crypto.Signature{}, client, mustCbor(&deal), nil)
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+				rt.Call(actor.PublishStorageDeals, mkPublishStorageParams(deal))
+			})
+			rt.Verify()
+		}
+		// Deals starting too soon for the provider to seal, or lasting too long or too briefly.
+		earlyStart := rt.Epoch() + standingAsk.MinStartDelay - 1
+		early := actor.generateDealAndAddFunds(rt, client, mAddrs, earlyStart, earlyStart+standingAsk.MinDuration)
+		long := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+4, startEpoch+4+standingAsk.MaxDuration+1)
+		short := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+5, startEpoch+5+standingAsk.MinDuration-1)
+
+		publishDropped(cheap)
+		publishDropped(large)
+		publishDropped(collateralized)
+		publishDropped(early)
+		publishDropped(long)
+		publishDropped(short)
+
+		// An otherwise matching unverified deal is dropped by a verified-only ask.
+		verifiedOnly := standingAsk
+		verifiedOnly.VerifiedOnly = true
+		actor.postProviderAsk(rt, mAddrs, verifiedOnly)
+		unverified := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+3, endEpoch)
+		publishDropped(unverified)
+		actor.checkState(rt)
+	})
 
-	h.cronTick(rt)
+	t.Run("deals published against the ask commit no more than its total collateral", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.postProviderAsk(rt, mAddrs, standingAsk)
 
-	rt.GetState(&st)
-	require.True(h.t, epochCid.Equals(st.DealOpsByEpoch))
-	require.EqualValues(h.t, cEscrow, h.getEscrowBalance(rt, client))
-	require.EqualValues(h.t, cLocked, h.getLockedBalance(rt, client))
-	require.EqualValues(h.t, pEscrow, h.getEscrowBalance(rt, provider))
-	require.EqualValues(h.t, pLocked, h.getLockedBalance(rt, provider))
-}
+		// Each deal commits the ask's per-deal maximum. Only two fit within the total.
+		var deals []market.DealProposal
+		for i := 0; i < 3; i++ {
+			deals = append(deals, actor.generateDealWithCollateralAndAddFunds(rt, client, mAddrs,
+				standingAsk.MaxProviderCollateral, big.NewInt(10), startEpoch+abi.ChainEpoch(i), endEpoch))
+		}
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		expectQueryNetworkInfo(rt, actor)
+		for i := range deals {
+			rt.ExpectVerifySignature(crypto.Signature{}, client, mustCbor(&deals[i]), nil)
+		}
+		ret := rt.Call(actor.PublishStorageDeals, mkPublishStorageParams(deals...)).(*market.PublishStorageDealsReturn)
+		rt.Verify()
+		assert.Len(t, ret.IDs, 2)
+		valid, err := ret.ValidDeals.All(math.MaxUint64)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{0, 1}, valid)
 
-// if this is the first crontick for the deal, it's next tick will be scheduled at `desiredNextEpoch`
-// if this is not the first crontick, the `desiredNextEpoch` param is ignored.
-func (h *marketActorTestHarness) cronTickAndAssertBalances(rt *mock.Runtime, client, provider address.Address,
-	currentEpoch abi.ChainEpoch, dealId abi.DealID) (payment abi.TokenAmount, amountSlashed abi.TokenAmount) {
-	// fetch current client and provider escrow balances
-	cLocked := h.getLockedBalance(rt, client)
-	cEscrow := h.getEscrowBalance(rt, client)
-	pLocked := h.getLockedBalance(rt, provider)
-	pEscrow := h.getEscrowBalance(rt, provider)
-	amountSlashed = big.Zero()
+		ask, found := actor.getProviderAsk(rt, provider)
+		require.True(t, found)
+		assert.Equal(t, big.NewInt(200), ask.ProviderCollateralCommitted)
 
-	s := h.getDealState(rt, dealId)
-	d := h.getDealProposal(rt, dealId)
+		// A later deal exceeding the remaining collateral is dropped, while one within it is published.
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		expectQueryNetworkInfo(rt, actor)
+		rt.ExpectVerifySignature(crypto.Signature{}, client, mustCbor(&deals[2]), nil)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+			rt.Call(actor.PublishStorageDeals, mkPublishStorageParams(deals[2]))
+		})
+		rt.Verify()
 
-	// end epoch for payment calc
-	paymentEnd := d.EndEpoch
-	if s.SlashEpoch != -1 {
-		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, d.ProviderCollateral, nil, exitcode.Ok)
-		amountSlashed = d.ProviderCollateral
+		remaining := actor.generateDealWithCollateralAndAddFunds(rt, client, mAddrs, big.NewInt(50), big.NewInt(10),
+			startEpoch+3, endEpoch)
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: remaining})
+		ask, found = actor.getProviderAsk(rt, provider)
+		require.True(t, found)
+		assert.Equal(t, standingAsk.MaxTotalProviderCollateral, ask.ProviderCollateralCommitted)
 
-		if s.SlashEpoch < d.StartEpoch {
-			paymentEnd = d.StartEpoch
-		} else {
-			paymentEnd = s.SlashEpoch
-		}
+		// Deals published by the provider itself aren't counted against the ask.
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: deals[2]})
+		ask, found = actor.getProviderAsk(rt, provider)
+		require.True(t, found)
+		assert.Equal(t, standingAsk.MaxTotalProviderCollateral, ask.ProviderCollateralCommitted)
+		actor.checkState(rt)
+	})
+
+	t.Run("deals matching the ask must be published by their client", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.postProviderAsk(rt, mAddrs, standingAsk)
+
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		other := tutil.NewIDAddr(t, 105)
+		rt.SetCaller(other, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		expectQueryNetworkInfo(rt, actor)
+		rt.ExpectVerifySignature(crypto.Signature{}, client, mustCbor(&deal), nil)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+			rt.Call(actor.PublishStorageDeals, mkPublishStorageParams(deal))
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("client cannot publish without an unexpired ask", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+
+		publishAsClient := func() {
+			rt.SetCaller(client, builtin.AccountActorCodeID)
+			rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+			expectGetControlAddresses(rt, provider, owner, worker)
+			rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not worker or control address", func() {
+				rt.Call(actor.PublishStorageDeals, mkPublishStorageParams(deal))
+			})
+			rt.Verify()
+		}
+		publishAsClient()
+
+		actor.postProviderAsk(rt, mAddrs, standingAsk)
+		rt.SetEpoch(standingAsk.Expiry)
+		publishAsClient()
+		actor.checkState(rt)
+	})
+}
+
+func TestRevokeDealProposal(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(42)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	expiry := abi.ChainEpoch(20)
+
+	publishRevoked := func(rt *mock.Runtime, actor *marketActorTestHarness, deal market.DealProposal) {
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		expectQueryNetworkInfo(rt, actor)
+		rt.ExpectVerifySignature(crypto.Signature{}, client, mustCbor(&deal), nil)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+			rt.Call(actor.PublishStorageDeals, mkPublishStorageParams(deal))
+		})
+		rt.Verify()
+	}
+
+	t.Run("revoked proposal cannot be published until the revocation lapses", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		actor.revokeDealProposal(rt, client, &deal, expiry)
+		publishRevoked(rt, actor, deal)
+
+		rt.SetEpoch(expiry)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+		require.Len(t, dealIDs, 1)
+		actor.checkState(rt)
+	})
+
+	t.Run("revocation applies only to the revoked proposal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		revoked := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		other := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+1, endEpoch)
+		actor.revokeDealProposal(rt, client, &revoked, expiry)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs, publishDealReq{deal: other})
+		require.Len(t, dealIDs, 1)
+		actor.checkState(rt)
+	})
+
+	t.Run("revocation by another client has no effect", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		actor.revokeDealProposal(rt, tutil.NewIDAddr(t, 105), &deal, expiry)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+		require.Len(t, dealIDs, 1)
+		actor.checkState(rt)
+	})
+
+	t.Run("revocation can be renewed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		actor.revokeDealProposal(rt, client, &deal, expiry)
+		actor.revokeDealProposal(rt, client, &deal, expiry+10)
+
+		rt.SetEpoch(expiry)
+		publishRevoked(rt, actor, deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to revoke with invalid parameters", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "wrong prefix", func() {
+			actor.revokeDealProposalCid(rt, client, tutil.MakeCID("proposal", &market.PieceCIDPrefix), expiry)
+		})
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be after current epoch", func() {
+			actor.revokeDealProposal(rt, client, &deal, rt.Epoch())
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exceeds maximum", func() {
+			actor.revokeDealProposal(rt, client, &deal, rt.Epoch()+market.MaxProposalRevocationDuration+1)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to revoke a published proposal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "already been published", func() {
+			actor.revokeDealProposal(rt, client, &deal, expiry)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("revocations per client are bounded and lapsed revocations are pruned", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		revoke := func(i int, expiry abi.ChainEpoch) {
+			actor.revokeDealProposalCid(rt, client, tutil.MakeCID(fmt.Sprintf("proposal-%d", i), &market.DealProposalCIDPrefix), expiry)
+		}
+		for i := 0; i < market.MaxRevokedProposalsPerClient; i++ {
+			revoke(i, expiry)
+		}
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "already holds", func() {
+			revoke(market.MaxRevokedProposalsPerClient, expiry)
+		})
+		// Renewing an existing revocation is not limited.
+		revoke(0, expiry+1)
+
+		// Once the revocations lapse they are pruned to make room for new ones.
+		rt.SetEpoch(expiry)
+		revoke(market.MaxRevokedProposalsPerClient, expiry+1)
+		actor.checkState(rt)
+	})
+}
+
+func TestDealLabelIndex(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+
+	publishLabelled := func(rt *mock.Runtime, actor *marketActorTestHarness, label string, endEpoch abi.ChainEpoch) abi.DealID {
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal.Label = mustLabel(label)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		return actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+	}
+
+	t.Run("indexed deal is found by its exact label", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealID := publishLabelled(rt, actor, "order-1", endEpoch)
+		publishLabelled(rt, actor, "order-2", endEpoch+1)
+
+		assert.Empty(t, actor.lookupDealsByLabel(rt, client, "order-1"))
+		actor.indexDealLabels(rt, client, dealID)
+		// Indexing again has no effect.
+		actor.indexDealLabels(rt, client, dealID)
+
+		assert.Equal(t, []abi.DealID{dealID}, actor.lookupDealsByLabel(rt, client, "order-1"))
+		assert.Empty(t, actor.lookupDealsByLabel(rt, client, "order-2"))
+		assert.Empty(t, actor.lookupDealsByLabel(rt, tutil.NewIDAddr(t, 105), "order-1"))
+		actor.checkState(rt)
+	})
+
+	t.Run("labels sharing an indexed prefix are distinguished on lookup", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		prefix := strings.Repeat("x", market.DealLabelIndexPrefixSize)
+		dealA := publishLabelled(rt, actor, prefix+"a", endEpoch)
+		dealB := publishLabelled(rt, actor, prefix+"b", endEpoch+1)
+		actor.indexDealLabels(rt, client, dealA, dealB)
+
+		assert.Equal(t, []abi.DealID{dealA}, actor.lookupDealsByLabel(rt, client, prefix+"a"))
+		assert.Equal(t, []abi.DealID{dealB}, actor.lookupDealsByLabel(rt, client, prefix+"b"))
+		assert.Empty(t, actor.lookupDealsByLabel(rt, client, prefix))
+		actor.checkState(rt)
+	})
+
+	t.Run("string and bytes labels with the same content are distinguished on lookup", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		stringDeal := publishLabelled(rt, actor, "order-1", endEpoch)
+		bytesLabel := market.NewLabelFromBytes([]byte("order-1"))
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+		deal.Label = bytesLabel
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		bytesDeal := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+		actor.indexDealLabels(rt, client, stringDeal, bytesDeal)
+
+		assert.Equal(t, []abi.DealID{stringDeal}, actor.lookupDealsByLabel(rt, client, "order-1"))
+		assert.Equal(t, []abi.DealID{bytesDeal}, actor.lookupDealsByDealLabel(rt, client, bytesLabel))
+		actor.checkState(rt)
+	})
+
+	t.Run("only the client may index a deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealID := publishLabelled(rt, actor, "order-1", endEpoch)
+		unlabelled := publishLabelled(rt, actor, "", endEpoch+1)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the client", func() {
+			actor.indexDealLabels(rt, provider, dealID)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such deal", func() {
+			actor.indexDealLabels(rt, client, dealID+100)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "has no label", func() {
+			actor.indexDealLabels(rt, client, unlabelled)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("deals per key are bounded and cleaned up deals are pruned", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		var dealIDs []abi.DealID
+		for i := 0; i < market.MaxDealsPerLabelIndexKey; i++ {
+			dealIDs = append(dealIDs, publishLabelled(rt, actor, "order", endEpoch+abi.ChainEpoch(i)))
+		}
+		actor.indexDealLabels(rt, client, dealIDs...)
+
+		// A deal starting later remains when the others time out.
+		laterStart := startEpoch + builtin.EpochsInDay
+		later := actor.generateDealAndAddFunds(rt, client, mAddrs, laterStart, laterStart+200*builtin.EpochsInDay)
+		later.Label = mustLabel("order")
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		laterID := actor.publishDeals(rt, mAddrs, publishDealReq{deal: later})[0]
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "already holds", func() {
+			actor.indexDealLabels(rt, client, laterID)
+		})
+
+		rt.SetEpoch(processEpoch(t, dealIDs[len(dealIDs)-1], startEpoch))
+		d := actor.getDealProposal(rt, dealIDs[0])
+		slashed := big.Mul(d.ProviderCollateral, big.NewInt(int64(len(dealIDs))))
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, slashed, nil, exitcode.Ok)
+		actor.cronTick(rt)
+		assert.Empty(t, actor.lookupDealsByLabel(rt, client, "order"))
+
+		actor.indexDealLabels(rt, client, laterID)
+		assert.Equal(t, []abi.DealID{laterID}, actor.lookupDealsByLabel(rt, client, "order"))
+		actor.checkState(rt)
+	})
+}
+
+func TestVerifyPieceInclusion(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+
+	publishAggregate := func(rt *mock.Runtime, actor *marketActorTestHarness) abi.DealID {
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal.PieceCID = pieceCommitmentCID(t, pieceAll)
+		deal.PieceSize = 512
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		return actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+	}
+
+	t.Run("verifies a sub-piece of a published deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealID := publishAggregate(rt, actor)
+
+		actor.verifyPieceInclusion(rt, &market.VerifyPieceInclusionParams{
+			DealID:       dealID,
+			SubPieceCID:  pieceCommitmentCID(t, pieceC),
+			SubPieceSize: 128,
+			Proof:        market.PieceInclusionProof{Index: 2, Path: [][]byte{mustDecodeHex(t, pieceD), mustDecodeHex(t, pieceAB)}},
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails with an invalid proof", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealID := publishAggregate(rt, actor)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid inclusion proof", func() {
+			actor.verifyPieceInclusion(rt, &market.VerifyPieceInclusionParams{
+				DealID:       dealID,
+				SubPieceCID:  pieceCommitmentCID(t, pieceC),
+				SubPieceSize: 128,
+				Proof:        market.PieceInclusionProof{Index: 1, Path: [][]byte{mustDecodeHex(t, pieceD), mustDecodeHex(t, pieceAB)}},
+			})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for an unknown deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealID := publishAggregate(rt, actor)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such deal", func() {
+			actor.verifyPieceInclusion(rt, &market.VerifyPieceInclusionParams{
+				DealID:       dealID + 1,
+				SubPieceCID:  pieceCommitmentCID(t, pieceAll),
+				SubPieceSize: 512,
+			})
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestEscrowFunder(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	funder := tutil.NewIDAddr(t, 105)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+
+	t.Run("requests the client's shortfall from the funder", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, client, abi.NewTokenAmount(1))
+		actor.authorizeEscrowFunder(rt, client, funder, deal.ClientBalanceRequirement())
+
+		shortfall := big.Sub(deal.ClientBalanceRequirement(), abi.NewTokenAmount(1))
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal, escrowRequest: &escrowRequest{
+			funder: funder,
+			amount: shortfall,
+			funded: shortfall,
+		}})
+
+		assert.Equal(t, deal.ClientBalanceRequirement(), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, deal.ClientBalanceRequirement(), actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+	})
+
+	t.Run("drops the deal if the funder does not pay", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.authorizeEscrowFunder(rt, client, funder, deal.ClientBalanceRequirement())
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		params := actor.expectPublishDeals(rt, mAddrs, publishDealReq{deal: deal, escrowRequest: &escrowRequest{
+			funder:   funder,
+			amount:   deal.ClientBalanceRequirement(),
+			funded:   big.Zero(),
+			exitCode: exitcode.ErrForbidden,
+		}})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+			rt.Call(actor.PublishStorageDeals, params)
+		})
+		rt.Verify()
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("bounds requests by the funder's allowance per epoch", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch-builtin.EpochsInDay)
+		actor.addProviderFunds(rt, big.Add(deal1.ProviderCollateral, deal2.ProviderCollateral), mAddrs)
+		allowance := deal1.ClientBalanceRequirement()
+		actor.authorizeEscrowFunder(rt, client, funder, allowance)
+
+		// The first deal exhausts the allowance, so no funds are requested for the second.
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		params := actor.expectPublishDeals(rt, mAddrs,
+			publishDealReq{deal: deal1, escrowRequest: &escrowRequest{funder: funder, amount: allowance, funded: allowance}},
+			publishDealReq{deal: deal2},
+		)
+		ret := rt.Call(actor.PublishStorageDeals, params)
+		rt.Verify()
+		valid, err := ret.(*market.PublishStorageDealsReturn).ValidDeals.All(math.MaxUint64)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{0}, valid)
+
+		// The allowance is restored in the next epoch.
+		rt.SetEpoch(rt.Epoch() + 1)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal2, escrowRequest: &escrowRequest{
+			funder: funder,
+			amount: deal2.ClientBalanceRequirement(),
+			funded: deal2.ClientBalanceRequirement(),
+		}})
+
+		assert.Equal(t, big.Add(deal1.ClientBalanceRequirement(), deal2.ClientBalanceRequirement()), actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+	})
+
+	t.Run("requests funds only for deals that may be published", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch-builtin.EpochsInDay)
+		actor.addProviderFunds(rt, big.Add(deal1.ProviderCollateral, deal2.ProviderCollateral), mAddrs)
+		actor.addParticipantFunds(rt, client, deal1.ClientBalanceRequirement())
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal1})
+
+		// The funder is asked to cover only the second deal, since the first is already pending.
+		actor.authorizeEscrowFunder(rt, client, funder, big.Add(deal1.ClientBalanceRequirement(), deal2.ClientBalanceRequirement()))
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		params := actor.expectPublishDeals(rt, mAddrs,
+			publishDealReq{deal: deal1},
+			publishDealReq{deal: deal2, escrowRequest: &escrowRequest{
+				funder: funder,
+				amount: deal2.ClientBalanceRequirement(),
+				funded: deal2.ClientBalanceRequirement(),
+			}},
+		)
+		ret := rt.Call(actor.PublishStorageDeals, params)
+		rt.Verify()
+		valid, err := ret.(*market.PublishStorageDealsReturn).ValidDeals.All(math.MaxUint64)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1}, valid)
+
+		assert.Equal(t, big.Add(deal1.ClientBalanceRequirement(), deal2.ClientBalanceRequirement()), actor.getEscrowBalance(rt, client))
+		actor.checkState(rt)
+	})
+
+	t.Run("requests the shortfall of a client's deals in the batch at once", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch-builtin.EpochsInDay)
+		actor.addProviderFunds(rt, big.Add(deal1.ProviderCollateral, deal2.ProviderCollateral), mAddrs)
+		total := big.Add(deal1.ClientBalanceRequirement(), deal2.ClientBalanceRequirement())
+		actor.authorizeEscrowFunder(rt, client, funder, total)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs,
+			publishDealReq{deal: deal1, escrowRequest: &escrowRequest{funder: funder, amount: total, funded: total}},
+			publishDealReq{deal: deal2},
+		)
+		require.Len(t, dealIDs, 2)
+		assert.Equal(t, total, actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+	})
+
+	t.Run("restores the allowance when a request is not funded", func(t *testing.T) {
+		// The request either fails or succeeds without the funder paying.
+		for _, code := range []exitcode.ExitCode{exitcode.ErrForbidden, exitcode.Ok} {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+			actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+			allowance := deal.ClientBalanceRequirement()
+			actor.authorizeEscrowFunder(rt, client, funder, allowance)
+
+			rt.SetCaller(worker, builtin.AccountActorCodeID)
+			params := actor.expectPublishDeals(rt, mAddrs, publishDealReq{deal: deal, escrowRequest: &escrowRequest{
+				funder:   funder,
+				amount:   allowance,
+				funded:   big.Zero(),
+				exitCode: code,
+			}})
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+				rt.Call(actor.PublishStorageDeals, params)
+			})
+			rt.Verify()
+			rt.Reset()
+
+			// The whole allowance remains to request in the same epoch.
+			rt.SetCaller(worker, builtin.AccountActorCodeID)
+			actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal, escrowRequest: &escrowRequest{
+				funder: funder,
+				amount: allowance,
+				funded: allowance,
+			}})
+			assert.Equal(t, allowance, actor.getEscrowBalance(rt, client), "exit code %d", code)
+			actor.checkState(rt)
+		}
+	})
+
+	t.Run("rejects a built-in actor as funder", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.SetAddressActorType(funder, builtin.MultisigActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "not an actor that may fund escrow", func() {
+			rt.Call(actor.AuthorizeEscrowFunder, &market.AuthorizeEscrowFunderParams{Funder: funder, MaxPerEpoch: abi.NewTokenAmount(1)})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a negative maximum", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.authorizeEscrowFunder(rt, client, funder, abi.NewTokenAmount(-1))
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("removing an absent authorization has no effect", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.authorizeEscrowFunder(rt, client, funder, big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("only the authorized funder may fund a client's escrow", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		amount := abi.NewTokenAmount(100)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the escrow funder", func() {
+			actor.fundClientEscrow(rt, funder, client, amount)
+		})
+		rt.Reset()
+
+		actor.authorizeEscrowFunder(rt, client, funder, amount)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the escrow funder", func() {
+			actor.fundClientEscrow(rt, worker, client, amount)
+		})
+		rt.Reset()
+
+		actor.fundClientEscrow(rt, funder, client, amount)
+		assert.Equal(t, amount, actor.getEscrowBalance(rt, client))
+
+		// A zero maximum removes the authorization.
+		actor.authorizeEscrowFunder(rt, client, funder, big.Zero())
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the escrow funder", func() {
+			actor.fundClientEscrow(rt, funder, client, amount)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestDealClientActor(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	clientActor := tutil.NewIDAddr(t, 105)
+	clientActorCode := tutil.MakeCID("dealclient", nil)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	authorized := exitcode.Ok
+
+	t.Run("publishes deals authorized by a client actor without a signature", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := generateDealProposal(clientActor, provider, startEpoch, endEpoch)
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		actor.addProviderFunds(rt, big.Add(deal1.ProviderCollateral, deal2.ProviderCollateral), mAddrs)
+		actor.addParticipantFunds(rt, clientActor, deal1.ClientBalanceRequirement())
+		actor.addParticipantFunds(rt, client, deal2.ClientBalanceRequirement())
+		rt.SetAddressActorType(clientActor, clientActorCode)
+
+		// Deals with signing clients in the same batch are still verified by signature.
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs,
+			publishDealReq{deal: deal1, authorization: &authorized},
+			publishDealReq{deal: deal2},
+		)
+		require.Len(t, dealIDs, 2)
+		assert.Equal(t, deal1.ClientBalanceRequirement(), actor.getLockedBalance(rt, clientActor))
+		actor.checkState(rt)
+	})
+
+	t.Run("drops deals the client actor does not authorize", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(clientActor, provider, startEpoch, endEpoch)
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, clientActor, deal.ClientBalanceRequirement())
+		rt.SetAddressActorType(clientActor, clientActorCode)
+
+		rejected := exitcode.ErrForbidden
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		params := actor.expectPublishDeals(rt, mAddrs, publishDealReq{deal: deal, authorization: &rejected})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+			rt.Call(actor.PublishStorageDeals, params)
+		})
+		rt.Verify()
+		rt.Reset()
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, clientActor))
+		actor.checkState(rt)
+	})
+
+	t.Run("verifies the signature of a built-in client actor rather than asking it to authorize", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(clientActor, provider, startEpoch, endEpoch)
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, clientActor, deal.ClientBalanceRequirement())
+		rt.SetAddressActorType(clientActor, builtin.MultisigActorCodeID)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+		require.Len(t, dealIDs, 1)
+		actor.checkState(rt)
+	})
+}
+
+func TestPublishStorageDealsAggregated(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	aggregateSig := crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("aggregate")}
+
+	t.Run("publishes deals with a single aggregate signature", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal2 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+1, endEpoch)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		params := actor.expectPublishDealsAggregated(rt, mAddrs, aggregateSig, deal1, deal2)
+		ret := rt.Call(actor.PublishStorageDealsAggregated, params).(*market.PublishStorageDealsReturn)
+		rt.Verify()
+
+		require.Len(t, ret.IDs, 2)
+		assert.Equal(t, deal1, *actor.getDealProposal(rt, ret.IDs[0]))
+		assert.Equal(t, deal2, *actor.getDealProposal(rt, ret.IDs[1]))
+		actor.checkState(rt)
+	})
+
+	t.Run("drops invalid deals after verifying the aggregate", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		// The second deal has no funds to cover it.
+		deal2 := generateDealProposal(client, provider, startEpoch+1, endEpoch)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		params := actor.expectPublishDealsAggregated(rt, mAddrs, aggregateSig, deal1, deal2)
+		ret := rt.Call(actor.PublishStorageDealsAggregated, params).(*market.PublishStorageDealsReturn)
+		rt.Verify()
+
+		require.Len(t, ret.IDs, 1)
+		valid, err := ret.ValidDeals.All(2)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{0}, valid)
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects an invalid aggregate signature", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		pcid, err := deal.Cid()
+		require.NoError(t, err)
+		rt.ExpectVerifyAggregateSignature(aggregateSig, []address.Address{client}, [][]byte{pcid.Bytes()}, errors.New("bad signature"))
+		params := &market.PublishStorageDealsAggregatedParams{Deals: []market.DealProposal{deal}, AggregateSignature: aggregateSig}
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid aggregate signature", func() {
+			rt.Call(actor.PublishStorageDealsAggregated, params)
+		})
+		rt.Verify()
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a non-BLS signature", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		params := &market.PublishStorageDealsAggregatedParams{
+			Deals:              []market.DealProposal{deal},
+			AggregateSignature: crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("aggregate")},
+		}
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be BLS", func() {
+			rt.Call(actor.PublishStorageDealsAggregated, params)
+		})
+		rt.Reset()
+	})
+
+	t.Run("rejects a deal with a client actor", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		clientActor := tutil.NewIDAddr(t, 105)
+		rt.SetAddressActorType(clientActor, tutil.MakeCID("dealclient", nil))
+		deal := generateDealProposal(clientActor, provider, startEpoch, endEpoch)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		params := &market.PublishStorageDealsAggregatedParams{Deals: []market.DealProposal{deal}, AggregateSignature: aggregateSig}
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "not a signing party", func() {
+			rt.Call(actor.PublishStorageDealsAggregated, params)
+		})
+		rt.Reset()
+	})
+}
+
+func TestRepairLockedTotals(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	recordedTotals := func(rt *mock.Runtime) market.LockedTotals {
+		var st market.State
+		rt.GetState(&st)
+		return market.LockedTotals{
+			ClientCollateral:   st.TotalClientLockedCollateral,
+			ProviderCollateral: st.TotalProviderLockedCollateral,
+			ClientStorageFee:   st.TotalClientStorageFee,
+		}
+	}
+
+	t.Run("totals without drift are unchanged", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		rt.SetEpoch(processEpoch(t, dealId, startEpoch) + 10)
+		actor.cronTick(rt)
+		before := recordedTotals(rt)
+
+		ret := actor.repairLockedTotals(rt)
+		assert.Equal(t, before, ret.Recorded)
+		assert.Equal(t, before, ret.Computed)
+		assert.Equal(t, before, recordedTotals(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("drifted totals are recomputed from deals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		expected := recordedTotals(rt)
+
+		var st market.State
+		rt.GetState(&st)
+		st.TotalClientLockedCollateral = big.Add(st.TotalClientLockedCollateral, big.NewInt(7))
+		st.TotalClientStorageFee = big.Sub(st.TotalClientStorageFee, big.NewInt(7))
+		rt.ReplaceState(&st)
+		drifted := recordedTotals(rt)
+		actor.checkState(rt,
+			"total client locked collateral, \\d+, does not match deals, \\d+",
+			"total client storage fee, \\d+, does not match deals, \\d+",
+		)
+
+		ret := actor.repairLockedTotals(rt)
+		assert.Equal(t, drifted, ret.Recorded)
+		assert.Equal(t, expected, ret.Computed)
+		assert.Equal(t, expected, recordedTotals(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("only the system actor may repair totals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetCaller(owner, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.RepairLockedTotals, nil)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+}
+
+func TestGetBalance(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+
+	t.Run("returns escrow and locked balances of client and provider", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+
+		ret := actor.getBalance(rt, client)
+		assert.Equal(t, actor.getEscrowBalance(rt, client), ret.Balance)
+		assert.Equal(t, deal.ClientBalanceRequirement(), ret.Locked)
+
+		ret = actor.getBalance(rt, provider)
+		assert.Equal(t, actor.getEscrowBalance(rt, provider), ret.Balance)
+		assert.Equal(t, deal.ProviderCollateral, ret.Locked)
+		actor.checkState(rt)
+	})
+
+	t.Run("address without escrow has zero balances", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		ret := actor.getBalance(rt, tutil.NewIDAddr(t, 999))
+		assert.True(t, ret.Balance.IsZero())
+		assert.True(t, ret.Locked.IsZero())
+	})
+
+	t.Run("fails for unresolvable address", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		unknown := tutil.NewBLSAddr(t, 999)
+		rt.SetCaller(owner, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			rt.Call(actor.GetBalance, &unknown)
+		})
+	})
+}
+
+func TestSettleDealPayments(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("pays earned fees ahead of cron, which pays the remainder", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		clientEscrow := actor.getEscrowBalance(rt, client)
+		providerEscrow := actor.getEscrowBalance(rt, provider)
+
+		settleEpoch := startEpoch + 10
+		rt.SetEpoch(settleEpoch)
+		actor.settleDealPayments(rt, client, dealId)
+
+		payment := big.Mul(big.NewInt(int64(settleEpoch-startEpoch)), deal.StoragePricePerEpoch)
+		assert.Equal(t, big.Sub(clientEscrow, payment), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Add(providerEscrow, payment), actor.getEscrowBalance(rt, provider))
+		assert.Equal(t, settleEpoch, actor.getDealState(rt, dealId).LastUpdatedEpoch)
+		actor.checkState(rt)
+
+		// cron pays only for the epochs since settlement
+		cronEpoch := processEpoch(t, dealId, startEpoch) + market.DealUpdatesInterval
+		rt.SetEpoch(cronEpoch)
+		cronPayment, slashed := actor.cronTickAndAssertBalances(rt, client, provider, cronEpoch, dealId)
+		assert.Equal(t, big.Mul(big.NewInt(int64(cronEpoch-settleEpoch)), deal.StoragePricePerEpoch), cronPayment)
+		assert.True(t, slashed.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a deal that is not active", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+
+		rt.SetEpoch(startEpoch)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "is not active", func() {
+			actor.settleDealPayments(rt, client, dealIds[0])
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a deal that has not started", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(startEpoch - 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "does not start", func() {
+			actor.settleDealPayments(rt, provider, dealId)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a deal that has ended", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(endEpoch)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "ended", func() {
+			actor.settleDealPayments(rt, provider, dealId)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a slashed deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.terminateDeals(rt, provider, dealId)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "was slashed", func() {
+			actor.settleDealPayments(rt, provider, dealId)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for an unknown deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			actor.settleDealPayments(rt, client, abi.DealID(42))
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestAmendDealProposal(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("lowers the price of an active deal after paying for elapsed epochs", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		providerEscrow := actor.getEscrowBalance(rt, provider)
+
+		currEpoch := startEpoch + 10
+		rt.SetEpoch(currEpoch)
+		newPrice := big.Div(deal.StoragePricePerEpoch, big.NewInt(2))
+		actor.amendDealProposal(rt, client, worker, mAddrs, newDealAmendment(t, dealId, deal, newPrice, endEpoch))
+
+		amended := actor.getDealProposal(rt, dealId)
+		assert.Equal(t, newPrice, amended.StoragePricePerEpoch)
+		assert.Equal(t, endEpoch, amended.EndEpoch)
+		assert.Equal(t, currEpoch, actor.getDealState(rt, dealId).LastUpdatedEpoch)
+
+		payment := big.Mul(big.NewInt(int64(currEpoch-startEpoch)), deal.StoragePricePerEpoch)
+		assert.Equal(t, big.Add(providerEscrow, payment), actor.getEscrowBalance(rt, provider))
+		remainingFee := big.Mul(big.NewInt(int64(endEpoch-currEpoch)), newPrice)
+		assert.Equal(t, big.Add(deal.ClientCollateral, remainingFee), actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+	})
+
+	t.Run("raises the price and shortens a pending deal, which may still be activated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addParticipantFunds(rt, client, big.Mul(deal.StoragePricePerEpoch, big.NewInt(int64(deal.Duration()))))
+
+		newPrice := big.Mul(deal.StoragePricePerEpoch, big.NewInt(2))
+		newEnd := endEpoch - builtin.EpochsInDay
+		actor.amendDealProposal(rt, worker, client, mAddrs, newDealAmendment(t, dealId, deal, newPrice, newEnd))
+
+		newFee := big.Mul(big.NewInt(int64(newEnd-startEpoch)), newPrice)
+		assert.Equal(t, big.Add(deal.ClientCollateral, newFee), actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+
+		actor.activateDeals(rt, sectorExpiry, provider, 0, dealId)
+		actor.checkState(rt)
+	})
+
+	t.Run("the original proposal of an amended pending deal cannot be published again", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+		published := actor.getDealProposal(rt, dealId)
+		newPrice := big.Div(deal.StoragePricePerEpoch, big.NewInt(2))
+		actor.amendDealProposal(rt, worker, client, mAddrs, newDealAmendment(t, dealId, published, newPrice, endEpoch))
+
+		// The parties could cover a second deal, but the client signed the proposal for the first.
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, client, deal.ClientBalanceRequirement())
+		actor.publishInvalidDeal(rt, mAddrs, deal)
+		rt.SetEpoch(startEpoch)
+		actor.publishInvalidDeal(rt, mAddrs, deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails when the client cannot cover the higher fee", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+
+		newPrice := big.Mul(deal.StoragePricePerEpoch, big.NewInt(2))
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "failed to lock client storage fee", func() {
+			actor.amendDealProposal(rt, worker, client, mAddrs, newDealAmendment(t, dealId, deal, newPrice, endEpoch))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("cannot extend an active deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "cannot extend active deal", func() {
+			actor.amendDealProposal(rt, client, worker, mAddrs, newDealAmendment(t, dealId, deal, deal.StoragePricePerEpoch, endEpoch+1))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("cannot change the end of a verified deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal.VerifiedDeal = true
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+		published := actor.getDealProposal(rt, dealId)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "cannot change end of verified deal", func() {
+			actor.amendDealProposal(rt, client, worker, mAddrs, newDealAmendment(t, dealId, published, deal.StoragePricePerEpoch, endEpoch-builtin.EpochsInDay))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects an amendment of superseded terms", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		lowered := newDealAmendment(t, dealId, deal, big.Div(deal.StoragePricePerEpoch, big.NewInt(2)), endEpoch)
+		actor.amendDealProposal(rt, client, worker, mAddrs, lowered)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "not the current proposal", func() {
+			actor.amendDealProposal(rt, client, worker, mAddrs, newDealAmendment(t, dealId, deal, big.Zero(), endEpoch))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a caller that is not a party to the deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		amendment := newDealAmendment(t, dealId, deal, big.Zero(), endEpoch)
+
+		rt.SetCaller(tutil.NewIDAddr(t, 999), builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "neither client nor provider", func() {
+			rt.Call(actor.AmendDealProposal, &market.AmendDealProposalParams{Amendment: amendment})
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestTransferDealClient(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	newClient := tutil.NewIDAddr(t, 105)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("moves the remaining obligations of an active deal to the new client", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		label, err := deal.Label.ToString()
+		require.NoError(t, err)
+		actor.indexDealLabels(rt, client, dealId)
+		providerEscrow := actor.getEscrowBalance(rt, provider)
+		clientEscrow := actor.getEscrowBalance(rt, client)
+
+		currEpoch := startEpoch + 10
+		rt.SetEpoch(currEpoch)
+		remainingFee := big.Mul(big.NewInt(int64(endEpoch-currEpoch)), deal.StoragePricePerEpoch)
+		obligations := big.Add(remainingFee, deal.ClientCollateral)
+		actor.addParticipantFunds(rt, newClient, obligations)
+		transferredCid := actor.transferDealClient(rt, client, newDealClientTransfer(t, dealId, deal, newClient))
+
+		transferred := actor.getDealProposal(rt, dealId)
+		assert.Equal(t, newClient, transferred.Client)
+		c, err := transferred.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, c, transferredCid)
+
+		// The old client paid for the elapsed epochs, and has no funds left locked.
+		payment := big.Mul(big.NewInt(int64(currEpoch-startEpoch)), deal.StoragePricePerEpoch)
+		assert.Equal(t, big.Add(providerEscrow, payment), actor.getEscrowBalance(rt, provider))
+		assert.Equal(t, big.Sub(clientEscrow, payment), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		assert.Equal(t, obligations, actor.getLockedBalance(rt, newClient))
+
+		// The deal is no longer found by its old client's label.
+		assert.Empty(t, actor.lookupDealsByLabel(rt, client, label))
+		actor.checkState(rt)
+
+		// The new client pays for the rest of the deal.
+		rt.SetEpoch(endEpoch + 1)
+		actor.cronTick(rt)
+		assert.Equal(t, big.Sub(obligations, remainingFee), actor.getEscrowBalance(rt, newClient))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, newClient))
+		actor.checkState(rt)
+	})
+
+	t.Run("replaces the pending proposal of a deal not yet updated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addParticipantFunds(rt, newClient, deal.ClientBalanceRequirement())
+
+		actor.transferDealClient(rt, client, newDealClientTransfer(t, dealId, deal, newClient))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		assert.Equal(t, deal.ClientBalanceRequirement(), actor.getLockedBalance(rt, newClient))
+		actor.checkState(rt)
+	})
+
+	t.Run("the original proposal of a deal transferred before its start cannot be published again", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addParticipantFunds(rt, newClient, deal.ClientBalanceRequirement())
+		actor.transferDealClient(rt, client, newDealClientTransfer(t, dealId, deal, newClient))
+
+		// The funds released from the original client would cover the deal again, but it was published once.
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.publishInvalidDeal(rt, mAddrs, *deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails when the new client cannot cover the obligations", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addParticipantFunds(rt, newClient, big.Sub(deal.ClientBalanceRequirement(), big.NewInt(1)))
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "failed to lock new client funds", func() {
+			actor.transferDealClient(rt, client, newDealClientTransfer(t, dealId, deal, newClient))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a deal not yet activated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "is not active", func() {
+			actor.transferDealClient(rt, client, newDealClientTransfer(t, dealId, deal, newClient))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a caller that is not the client", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the client", func() {
+			actor.transferDealClient(rt, worker, newDealClientTransfer(t, dealId, deal, newClient))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a transfer not signed by the new client", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		transfer := newDealClientTransfer(t, dealId, deal, newClient)
+
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		signed, err := market.SigningBytes(market.SigningDomainDealClientTransfer, &transfer)
+		require.NoError(t, err)
+		rt.ExpectVerifySignature(crypto.Signature{}, newClient, signed, errors.New("bad signature"))
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid signature", func() {
+			rt.Call(actor.TransferDealClient, &market.TransferDealClientParams{Transfer: transfer})
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestAddProviderCollateral(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+	amount := abi.NewTokenAmount(1000)
+
+	t.Run("locks additional collateral for a pending deal, which may still be activated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addProviderFunds(rt, amount, mAddrs)
+		locked := actor.getLockedBalance(rt, provider)
+
+		addedCid := actor.addProviderCollateral(rt, worker, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+
+		added := actor.getDealProposal(rt, dealId)
+		assert.Equal(t, big.Add(deal.ProviderCollateral, amount), added.ProviderCollateral)
+		c, err := added.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, c, addedCid)
+		assert.Equal(t, big.Add(locked, amount), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+
+		actor.activateDeals(rt, sectorExpiry, provider, 0, dealId)
+		rt.SetEpoch(endEpoch + 1)
+		actor.cronTick(rt)
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+	})
+
+	t.Run("locks additional collateral for an active deal, released at its end", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addProviderFunds(rt, amount, mAddrs)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.addProviderCollateral(rt, worker, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+		assert.Equal(t, big.Add(deal.ProviderCollateral, amount), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+
+		rt.SetEpoch(endEpoch + 1)
+		actor.cronTick(rt)
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+	})
+
+	t.Run("the original proposal of a pending deal with added collateral cannot be published again", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addProviderFunds(rt, amount, mAddrs)
+		actor.addProviderCollateral(rt, worker, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, client, deal.ClientBalanceRequirement())
+		actor.publishInvalidDeal(rt, mAddrs, *deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails when the provider cannot cover the collateral", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "failed to lock provider collateral", func() {
+			actor.addProviderCollateral(rt, worker, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a deal not activated by its start", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addProviderFunds(rt, amount, mAddrs)
+
+		rt.SetEpoch(startEpoch + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "was not activated by its start", func() {
+			actor.addProviderCollateral(rt, worker, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects an addition to superseded terms", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+		actor.addProviderFunds(rt, big.Mul(amount, big.NewInt(2)), mAddrs)
+		actor.addProviderCollateral(rt, worker, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "not the current proposal", func() {
+			actor.addProviderCollateral(rt, worker, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a non-positive amount", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be positive", func() {
+			params := newProviderCollateralAddition(t, dealId, deal, big.Zero())
+			rt.Call(actor.AddProviderCollateral, &params)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a caller that is not the provider", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.addProviderCollateral(rt, client, mAddrs, newProviderCollateralAddition(t, dealId, deal, amount))
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestReportRetrievalViolation(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	reporter := tutil.NewIDAddr(t, 105)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+	penalty := big.NewInt(4)
+
+	publishAndActivateDealWithSLA := func(rt *mock.Runtime, actor *marketActorTestHarness, availability uint64) abi.DealID {
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal.RetrievalSLA = &market.RetrievalSLA{Availability: availability, ViolationPenalty: penalty}
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+		actor.activateDeals(rt, sectorExpiry, provider, 0, dealId)
+		return dealId
+	}
+
+	t.Run("burns the penalty of an undisputed violation from provider collateral", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		deal := actor.getDealProposal(rt, dealId)
+		clientEscrow := actor.getEscrowBalance(rt, client)
+
+		rt.SetEpoch(startEpoch + 10)
+		ret := actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+5), big.Zero())
+		assert.Equal(t, startEpoch+10+market.RetrievalDisputeWindow, ret.DisputeDeadline)
+
+		// Nothing is penalized while the violation may be disputed.
+		assert.Equal(t, deal, actor.getDealProposal(rt, dealId))
+		assert.Equal(t, deal.ProviderCollateral, actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+
+		// The penalty is burnt when cron next updates the deal, which is then settled with its reduced collateral.
+		rt.SetEpoch(endEpoch + 1)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, penalty, nil, exitcode.Ok)
+		actor.cronTick(rt)
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, provider))
+		assert.Equal(t, clientEscrow, big.Add(actor.getEscrowBalance(rt, client), deal.TotalStorageFee()))
+		actor.checkState(rt)
+	})
+
+	t.Run("penalizes an undisputed violation when the next is reported", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		deal := actor.getDealProposal(rt, dealId)
+		providerEscrow := actor.getEscrowBalance(rt, provider)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+5), big.Zero())
+
+		// The proposal is unchanged until the dispute window closes.
+		rt.SetEpoch(startEpoch + 10 + market.RetrievalDisputeWindow + 1)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+6), penalty)
+
+		penalized := actor.getDealProposal(rt, dealId)
+		assert.Equal(t, big.Sub(deal.ProviderCollateral, penalty), penalized.ProviderCollateral)
+		assert.Equal(t, big.Sub(providerEscrow, penalty), actor.getEscrowBalance(rt, provider))
+		assert.Equal(t, penalized.ProviderCollateral, actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+	})
+
+	t.Run("the original proposal of a deal penalized before its start cannot be published again", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		lateStart := startEpoch + 2*market.RetrievalDisputeWindow
+		lateEnd := lateStart + 200*builtin.EpochsInDay
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, lateStart, lateEnd)
+		deal.RetrievalSLA = &market.RetrievalSLA{Availability: 9_900, ViolationPenalty: penalty}
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+		actor.activateDeals(rt, lateEnd+100, provider, 0, dealId)
+
+		rt.SetEpoch(10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, &deal, 5), big.Zero())
+		rt.SetEpoch(10 + market.RetrievalDisputeWindow + 1)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, &deal, 6), penalty)
+		require.True(t, rt.Epoch() < lateStart)
+
+		actor.addProviderFunds(rt, deal.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, client, deal.ClientBalanceRequirement())
+		actor.publishInvalidDeal(rt, mAddrs, deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("penalty is limited to the collateral remaining", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		epoch := startEpoch + 10
+
+		// The deal's collateral of 10 covers two full penalties and part of a third.
+		for i, burnt := range []int64{0, 4, 4} {
+			rt.SetEpoch(epoch)
+			deal := actor.getDealProposal(rt, dealId)
+			actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+abi.ChainEpoch(i)), big.NewInt(burnt))
+			epoch += market.RetrievalDisputeWindow + 1
+		}
+		assert.Equal(t, big.NewInt(2), actor.getLockedBalance(rt, provider))
+
+		// A violation can't be reported once the pending penalty exhausts the collateral.
+		rt.SetEpoch(epoch)
+		deal := actor.getDealProposal(rt, dealId)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "no provider collateral remaining", func() {
+			actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+3), big.Zero())
+		})
+		actor.checkState(rt)
+
+		rt.SetEpoch(endEpoch + 1)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, big.NewInt(2), nil, exitcode.Ok)
+		actor.cronTick(rt)
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+	})
+
+	t.Run("provider disputes a violation with the client's receipt", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+5), big.Zero())
+		rt.SetEpoch(startEpoch + 10 + market.RetrievalDisputeWindow)
+		actor.disputeRetrievalViolation(rt, worker, mAddrs, market.RetrievalReceipt{DealID: dealId, Epoch: startEpoch + 5})
+		actor.checkState(rt)
+
+		// Nothing is burnt for the dismissed violation.
+		rt.SetEpoch(endEpoch + 1)
+		actor.cronTick(rt)
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a dispute after the window closes", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, actor.getDealProposal(rt, dealId), startEpoch+5), big.Zero())
+		rt.SetEpoch(startEpoch + 10 + market.RetrievalDisputeWindow + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "dispute window", func() {
+			actor.disputeRetrievalViolation(rt, worker, mAddrs, market.RetrievalReceipt{DealID: dealId, Epoch: startEpoch + 5})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a dispute with a receipt for another retrieval", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, actor.getDealProposal(rt, dealId), startEpoch+5), big.Zero())
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "not the violation", func() {
+			actor.disputeRetrievalViolation(rt, worker, mAddrs, market.RetrievalReceipt{DealID: dealId, Epoch: startEpoch + 4})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a dispute with no violation pending", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+
+		rt.SetEpoch(startEpoch + 10)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no violation", func() {
+			actor.disputeRetrievalViolation(rt, worker, mAddrs, market.RetrievalReceipt{DealID: dealId, Epoch: startEpoch + 5})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a dispute not sent by the provider", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, actor.getDealProposal(rt, dealId), startEpoch+5), big.Zero())
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		expectGetControlAddresses(rt, provider, owner, worker)
+		rt.ExpectValidateCallerAddr(worker)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.DisputeRetrievalViolation, &market.DisputeRetrievalViolationParams{
+				Receipt: market.RetrievalReceipt{DealID: dealId, Epoch: startEpoch + 5},
+			})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a violation while another awaits dispute", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+5), big.Zero())
+		rt.SetEpoch(startEpoch + 10 + market.RetrievalDisputeWindow)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "awaits dispute", func() {
+			actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+6), big.Zero())
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a violation no later than the last reported", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+5), big.Zero())
+		actor.disputeRetrievalViolation(rt, worker, mAddrs, market.RetrievalReceipt{DealID: dealId, Epoch: startEpoch + 5})
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be after", func() {
+			actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+5), big.Zero())
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("bounds the violations reported in a period by the availability committed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		// Committing to serve 10% of retrievals admits one violation per period.
+		dealId := publishAndActivateDealWithSLA(rt, actor, 1_000)
+		require.EqualValues(t, 1, market.RetrievalViolationsPerPeriodMax(1_000))
+		deal := actor.getDealProposal(rt, dealId)
+
+		periodStart := startEpoch + 10
+		rt.SetEpoch(periodStart)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+5), big.Zero())
+		actor.disputeRetrievalViolation(rt, worker, mAddrs, market.RetrievalReceipt{DealID: dealId, Epoch: startEpoch + 5})
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "the most its availability allows", func() {
+			actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+6), big.Zero())
+		})
+
+		// Another may be reported in the next period.
+		rt.SetEpoch(periodStart + market.RetrievalViolationPeriod)
+		actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+6), big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a deal without retrieval terms", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		rt.SetEpoch(startEpoch + 10)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "has no retrieval terms", func() {
+			actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, actor.getDealProposal(rt, dealId), startEpoch), big.Zero())
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a violation outside the deal's active term", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		deal := actor.getDealProposal(rt, dealId)
+		rt.SetEpoch(startEpoch + 10)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "outside the active term", func() {
+			actor.reportRetrievalViolation(rt, reporter, newRetrievalViolation(t, dealId, deal, startEpoch+11), big.Zero())
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a violation not signed by the client in the violation domain", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := publishAndActivateDealWithSLA(rt, actor, 9_900)
+		violation := newRetrievalViolation(t, dealId, actor.getDealProposal(rt, dealId), startEpoch)
+
+		// The client's signature is over the violation prefixed by its domain, never the bare violation.
+		signed, err := market.SigningBytes(market.SigningDomainRetrievalViolation, &violation)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte(market.SigningDomainRetrievalViolation), mustCbor(&violation)...), signed)
+
+		rt.SetCaller(reporter, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.ExpectVerifySignature(crypto.Signature{}, client, signed, errors.New("bad signature"))
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid signature", func() {
+			rt.Call(actor.ReportRetrievalViolation, &market.ReportRetrievalViolationParams{Violation: violation})
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestOnMinerDealsReplaced(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("slashes a started deal as terminated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		replacedEpoch := startEpoch + 100
+		rt.SetEpoch(replacedEpoch)
+		actor.replaceDeals(rt, provider, dealId)
+		actor.assertDealsTerminated(rt, replacedEpoch, dealId)
+
+		// Cron slashes the provider's collateral.
+		rt.SetEpoch(replacedEpoch + market.DealUpdatesInterval)
+		_, slashed := actor.cronTickAndAssertBalances(rt, client, provider, rt.Epoch(), dealId)
+		assert.Equal(t, deal.ProviderCollateral, slashed)
+		actor.assertDealDeleted(rt, dealId, deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("slashes a deal yet to start", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.SetEpoch(startEpoch - 1)
+		actor.replaceDeals(rt, provider, dealId)
+		actor.assertDealsTerminated(rt, startEpoch-1, dealId)
+
+		rt.SetEpoch(processEpoch(t, dealId, startEpoch))
+		_, slashed := actor.cronTickAndAssertBalances(rt, client, provider, rt.Epoch(), dealId)
+		assert.Equal(t, deal.ProviderCollateral, slashed)
+		actor.assertDealDeleted(rt, dealId, deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("leaves a slashed deal for cron", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		rt.SetEpoch(startEpoch + 100)
+		actor.terminateDeals(rt, provider, dealId)
+
+		actor.replaceDeals(rt, provider, dealId)
+		actor.assertDealsTerminated(rt, startEpoch+100, dealId)
+		actor.checkState(rt)
+	})
+
+	t.Run("ignores a deal that does not exist", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.replaceDeals(rt, provider, abi.DealID(42))
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if caller is not the provider", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the provider", func() {
+			actor.replaceDeals(rt, tutil.NewIDAddr(t, 501), dealId)
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestContestDealSlash(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("reverses a slash recorded after the deal ended", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		// The sector is terminated after the deal ended, but before cron processes the deal's expiry.
+		rt.SetEpoch(endEpoch + 10)
+		actor.terminateDeals(rt, provider, dealId)
+		actor.assertDealsTerminated(rt, endEpoch, dealId)
+
+		actor.contestDealSlash(rt, worker, mAddrs, dealId)
+		actor.assertDeaslNotTerminated(rt, dealId)
+
+		current := rt.SetEpoch(endEpoch + 300)
+		pay, slashed := actor.cronTickAndAssertBalances(rt, client, provider, current, dealId)
+		assert.Equal(t, big.Mul(big.NewInt(int64(endEpoch-startEpoch)), deal.StoragePricePerEpoch), pay)
+		assert.Equal(t, big.Zero(), slashed)
+		actor.assertDealDeleted(rt, dealId, deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("slash recorded before the deal ended stands", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.SetEpoch(endEpoch - 10)
+		actor.terminateDeals(rt, provider, dealId)
+
+		rt.SetEpoch(endEpoch + 10)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "before its end", func() {
+			actor.contestDealSlash(rt, worker, mAddrs, dealId)
+		})
+		actor.assertDealsTerminated(rt, endEpoch-10, dealId)
+
+		current := rt.SetEpoch(endEpoch + 300)
+		_, slashed := actor.cronTickAndAssertBalances(rt, client, provider, current, dealId)
+		assert.Equal(t, deal.ProviderCollateral, slashed)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails once the contest window has closed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(endEpoch + 10)
+		actor.terminateDeals(rt, provider, dealId)
+
+		// The window is measured from the recorded slash epoch, the deal's end.
+		rt.SetEpoch(endEpoch + market.DealSlashContestWindow + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "window to contest", func() {
+			actor.contestDealSlash(rt, worker, mAddrs, dealId)
+		})
+		actor.assertDealsTerminated(rt, endEpoch, dealId)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails once cron has settled the deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(endEpoch + 10)
+		actor.terminateDeals(rt, provider, dealId)
+		current := rt.SetEpoch(endEpoch + 300)
+		actor.cronTickAndAssertBalances(rt, client, provider, current, dealId)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "failed to get deal proposal", func() {
+			actor.contestDealSlash(rt, worker, mAddrs, dealId)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a deal with no recorded slash", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(endEpoch + 10)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "no recorded slash", func() {
+			actor.contestDealSlash(rt, worker, mAddrs, dealId)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if caller is not a control address of the provider", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(endEpoch + 10)
+		actor.terminateDeals(rt, provider, dealId)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.contestDealSlash(rt, client, mAddrs, dealId)
+		})
+		actor.assertDealsTerminated(rt, endEpoch, dealId)
+		actor.checkState(rt)
+	})
+}
+
+func TestTerminateBreachedDeal(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+	sectorNumber := abi.SectorNumber(7)
+
+	t.Run("pays slashed provider collateral to client", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		deal := actor.getDealProposal(rt, dealId)
+
+		rt.SetEpoch(startEpoch)
+		actor.terminateBreachedDeal(rt, client, dealId, sectorNumber)
+		assert.True(t, actor.isDealBreached(rt, dealId))
+
+		// The provider's miner reports the deal's sector terminated.
+		actor.terminateDeals(rt, provider, dealId)
+
+		clientEscrow := actor.getEscrowBalance(rt, client)
+		providerEscrow := actor.getEscrowBalance(rt, provider)
+		cronEpoch := processEpoch(t, dealId, startEpoch)
+		rt.SetEpoch(cronEpoch)
+		actor.cronTick(rt)
+
+		assert.Equal(t, big.Add(clientEscrow, deal.ProviderCollateral), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Sub(providerEscrow, deal.ProviderCollateral).Int64(), actor.getEscrowBalance(rt, provider).Int64())
+		assert.EqualValues(t, 0, actor.getLockedBalance(rt, client).Int64())
+		assert.EqualValues(t, 0, actor.getLockedBalance(rt, provider).Int64())
+		actor.assertDealDeleted(rt, dealId, deal)
+
+		assert.False(t, actor.isDealBreached(rt, dealId))
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if caller is not the client", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(startEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "not the client", func() {
+			rt.Call(actor.TerminateBreachedDeal, &market.TerminateBreachedDealParams{DealID: dealId, SectorNumber: sectorNumber})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a deal that is not active", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})
+
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "is not active", func() {
+			rt.Call(actor.TerminateBreachedDeal, &market.TerminateBreachedDealParams{DealID: dealIds[0], SectorNumber: sectorNumber})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a slashed deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(startEpoch)
+		actor.terminateDeals(rt, provider, dealId)
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "was slashed", func() {
+			rt.Call(actor.TerminateBreachedDeal, &market.TerminateBreachedDealParams{DealID: dealId, SectorNumber: sectorNumber})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if the miner rejects the termination", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(startEpoch)
+		rt.SetCaller(client, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.ExpectSend(provider, builtin.MethodsMiner.TerminateBreachedSector, &builtin.TerminateBreachedSectorParams{
+			SectorNumber: sectorNumber,
+			DealID:       dealId,
+		}, big.Zero(), nil, exitcode.ErrForbidden)
+		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+			rt.Call(actor.TerminateBreachedDeal, &market.TerminateBreachedDealParams{DealID: dealId, SectorNumber: sectorNumber})
+		})
+	})
+}
+
+func TestSampleDealsForAudit(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+	randomness := abi.Randomness("audit randomness")
+
+	// Publishes and activates deals with the given piece sizes.
+	setupDeals := func(rt *mock.Runtime, actor *marketActorTestHarness, sizes ...abi.PaddedPieceSize) []abi.DealID {
+		var reqs []publishDealReq
+		for i, size := range sizes {
+			deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+abi.ChainEpoch(i))
+			deal.PieceSize = size
+			reqs = append(reqs, publishDealReq{deal: deal})
+		}
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, mAddrs, reqs...)
+		actor.activateDeals(rt, sectorExpiry, provider, 0, dealIDs...)
+		return dealIDs
+	}
+
+	t.Run("weights samples by piece size", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealIDs := setupDeals(rt, actor, 2048, 2048<<10)
+		rt.SetEpoch(startEpoch)
+
+		// The small deal is accepted once in 1024 draws, so the large deal takes every sample yielded.
+		samples := actor.sampleDealsForAudit(rt, startEpoch, 0, 64, randomness)
+		assert.Greater(t, len(samples), 16)
+		assert.Less(t, len(samples), 64)
+		for _, id := range samples {
+			assert.Equal(t, dealIDs[1], id)
+		}
+		actor.checkState(rt)
+	})
+
+	t.Run("samples deals of equal size uniformly", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealIDs := setupDeals(rt, actor, 2048, 2048)
+		rt.SetEpoch(startEpoch)
+
+		// Every deal is active and as large as the largest, so each sample yields one.
+		samples := actor.sampleDealsForAudit(rt, startEpoch, 0, 64, randomness)
+		require.Len(t, samples, 64)
+		drawn := map[abi.DealID]int{}
+		for _, id := range samples {
+			require.Contains(t, dealIDs, id)
+			drawn[id]++
+		}
+		assert.Greater(t, drawn[dealIDs[0]], 16)
+		assert.Greater(t, drawn[dealIDs[1]], 16)
+		actor.checkState(rt)
+	})
+
+	t.Run("samples deals with IDs derived from their proposal CIDs", func(t *testing.T) {
+		market.DealIDsFromProposalCID = true
+		defer func() { market.DealIDsFromProposalCID = false }()
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealIDs := setupDeals(rt, actor, 2048, 2048)
+		for _, id := range dealIDs {
+			require.True(t, market.IsDerivedDealID(id))
+		}
+		rt.SetEpoch(startEpoch)
+
+		samples := actor.sampleDealsForAudit(rt, startEpoch, 0, 16, randomness)
+		require.Len(t, samples, 16)
+		for _, id := range samples {
+			assert.Contains(t, dealIDs, id)
+		}
+		actor.checkState(rt)
+	})
+
+	t.Run("stops sampling deals cleaned up after expiry", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealIDs := setupDeals(rt, actor, 2048, 2048)
+		late := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, sectorExpiry)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		lateID := actor.publishDeals(rt, mAddrs, publishDealReq{deal: late})[0]
+		actor.activateDeals(rt, sectorExpiry, provider, 0, lateID)
+
+		var expired []*market.DealProposal
+		for _, id := range dealIDs {
+			expired = append(expired, actor.getDealProposal(rt, id))
+		}
+		rt.SetEpoch(endEpoch + 10)
+		actor.cronTick(rt)
+		for i, id := range dealIDs {
+			actor.assertDealDeleted(rt, id, expired[i])
+		}
+
+		samples := actor.sampleDealsForAudit(rt, rt.Epoch(), 0, 16, randomness)
+		require.Len(t, samples, 16)
+		for _, id := range samples {
+			assert.Equal(t, lateID, id)
+		}
+		actor.checkState(rt)
+	})
+
+	t.Run("pages of samples match a single draw", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		setupDeals(rt, actor, 2048, 4096, 8192)
+		rt.SetEpoch(startEpoch)
+
+		all := actor.sampleDealsForAudit(rt, startEpoch, 0, 10, randomness)
+		first := actor.sampleDealsForAudit(rt, startEpoch, 0, 4, randomness)
+		rest := actor.sampleDealsForAudit(rt, startEpoch, 4, 6, randomness)
+		assert.Equal(t, all, append(first, rest...))
+		actor.checkState(rt)
+	})
+
+	t.Run("excludes deals not activated or slashed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealIDs := setupDeals(rt, actor, 2048, 2048)
+
+		unactivated := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+1, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: unactivated})
+
+		rt.SetEpoch(startEpoch)
+		actor.terminateDeals(rt, provider, dealIDs[0])
+
+		// Samples drawing the other deals yield nothing.
+		samples := actor.sampleDealsForAudit(rt, startEpoch, 0, 16, randomness)
+		assert.NotEmpty(t, samples)
+		assert.Less(t, len(samples), 16)
+		for _, id := range samples {
+			assert.Equal(t, dealIDs[1], id)
+		}
+		actor.checkState(rt)
+	})
+
+	t.Run("returns no samples without active deals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(startEpoch)
+
+		rt.ExpectValidateCallerAny()
+		ret := rt.Call(actor.SampleDealsForAudit, &market.SampleDealsForAuditParams{Epoch: startEpoch, Count: 4}).(*market.SampleDealsForAuditReturn)
+		rt.Verify()
+		assert.Empty(t, ret.DealIDs)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails with invalid parameters", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(startEpoch)
+
+		for _, params := range []*market.SampleDealsForAuditParams{
+			{Epoch: startEpoch + 1, Count: 1},
+			{Epoch: -1, Count: 1},
+			{Epoch: startEpoch, Count: 0},
+			{Epoch: startEpoch, Count: market.DealAuditSamplesMax + 1},
+			{Epoch: startEpoch, Offset: math.MaxUint64, Count: 2},
+		} {
+			rt.ExpectValidateCallerAny()
+			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+				rt.Call(actor.SampleDealsForAudit, params)
+			})
+		}
+		actor.checkState(rt)
+	})
+}
+
+func TestGetDealStatus(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("deal moves from pending through active and expired to settled", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+		assert.Equal(t, market.DealStatusPending, actor.getDealStatus(rt, dealId))
+
+		rt.SetEpoch(startEpoch - 1)
+		actor.activateDeals(rt, sectorExpiry, provider, rt.Epoch(), dealId)
+		assert.Equal(t, market.DealStatusActive, actor.getDealStatus(rt, dealId))
+
+		rt.SetEpoch(endEpoch - 1)
+		assert.Equal(t, market.DealStatusActive, actor.getDealStatus(rt, dealId))
+		rt.SetEpoch(endEpoch)
+		assert.Equal(t, market.DealStatusExpired, actor.getDealStatus(rt, dealId))
+
+		rt.SetEpoch(endEpoch + 100)
+		actor.cronTickAndAssertBalances(rt, client, provider, rt.Epoch(), dealId)
+		actor.assertDealDeleted(rt, dealId, &deal)
+		assert.Equal(t, market.DealStatusSettled, actor.getDealStatus(rt, dealId))
+		actor.checkState(rt)
+	})
+
+	t.Run("deal not activated by its start epoch times out", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+
+		rt.SetEpoch(startEpoch)
+		assert.Equal(t, market.DealStatusPending, actor.getDealStatus(rt, dealId))
+		rt.SetEpoch(startEpoch + 1)
+		assert.Equal(t, market.DealStatusTimedOut, actor.getDealStatus(rt, dealId))
+		actor.checkState(rt)
+	})
+
+	t.Run("deal of a terminated sector is slashed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(startEpoch + 10)
+		actor.terminateDeals(rt, provider, dealId)
+		assert.Equal(t, market.DealStatusSlashed, actor.getDealStatus(rt, dealId))
+
+		// The deal remains slashed past its end epoch.
+		rt.SetEpoch(endEpoch)
+		assert.Equal(t, market.DealStatusSlashed, actor.getDealStatus(rt, dealId))
+		actor.checkState(rt)
+	})
+
+	t.Run("deal of a sector terminated at its end epoch is expired", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.SetEpoch(endEpoch)
+		actor.terminateDeals(rt, provider, dealId)
+		actor.assertDealsTerminated(rt, endEpoch, dealId)
+		assert.Equal(t, market.DealStatusExpired, actor.getDealStatus(rt, dealId))
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for unknown deals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such deal", func() {
+			actor.getDealStatus(rt, abi.DealID(100))
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such deal", func() {
+			actor.getDealStatus(rt, market.DerivedDealIDBase+1)
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestGetDealActivation(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("reports the sector and epoch of activation", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+
+		ret := actor.getDealActivation(rt, dealId)
+		assert.Equal(t, provider, ret.Provider)
+		assert.Equal(t, abi.ChainEpoch(-1), ret.ActivationEpoch)
+		assert.Equal(t, market.DealSectorNumberUnknown, ret.SectorNumber)
+
+		rt.SetEpoch(startEpoch - 10)
+		actor.activateDealsInSector(rt, 42, sectorExpiry, provider, rt.Epoch(), dealId)
+
+		ret = actor.getDealActivation(rt, dealId)
+		assert.Equal(t, provider, ret.Provider)
+		assert.Equal(t, startEpoch-10, ret.ActivationEpoch)
+		assert.Equal(t, abi.SectorNumber(42), ret.SectorNumber)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for unknown deals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such deal", func() {
+			actor.getDealActivation(rt, abi.DealID(100))
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestGetDealUpdateEpoch(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	t.Run("reports the first update and each update after it", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+
+		first := market.GenRandNextEpoch(startEpoch, dealId)
+		assert.Equal(t, first, actor.getDealUpdateEpoch(rt, dealId))
+
+		actor.activateDeals(rt, sectorExpiry, provider, 0, dealId)
+		assert.Equal(t, first, actor.getDealUpdateEpoch(rt, dealId))
+
+		// A deal is updated at the tick at which cron reaches it, which may be after its scheduled epoch.
+		current := rt.SetEpoch(first + 10)
+		actor.cronTick(rt)
+		assert.Equal(t, current+market.DealUpdatesInterval, actor.getDealUpdateEpoch(rt, dealId))
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for unknown deals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such deal", func() {
+			actor.getDealUpdateEpoch(rt, abi.DealID(100))
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestClientPublishedProposals(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	other := tutil.NewIDAddr(t, 105)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+
+	t.Run("provider accepts a proposal published by its client", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		pcids := actor.publishStorageDealsFromClient(rt, client, deal)
+		expectedCid, err := deal.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{expectedCid}, pcids)
+		assert.True(t, actor.hasClientProposal(rt, expectedCid))
+
+		// No funds are locked until the proposal is accepted.
+		assert.Equal(t, int64(0), actor.getLockedBalance(rt, client).Int64())
+		actor.checkState(rt)
+
+		ret := actor.acceptDealProposals(rt, mAddrs, pcids...)
+		require.Len(t, ret.IDs, 1)
+		assert.Equal(t, deal, *actor.getDealProposal(rt, ret.IDs[0]))
+		assert.Equal(t, deal.ClientBalanceRequirement(), actor.getLockedBalance(rt, client))
+		assert.Equal(t, deal.ProviderCollateral, actor.getLockedBalance(rt, provider))
+		assert.False(t, actor.hasClientProposal(rt, expectedCid))
+		actor.checkState(rt)
+	})
+
+	t.Run("proposal not accepted remains awaiting acceptance", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		// The second deal's client funds are not deposited.
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch+1)
+		actor.addProviderFunds(rt, deal2.ProviderCollateral, mAddrs)
+		pcids := actor.publishStorageDealsFromClient(rt, client, deal1, deal2)
+
+		ret := actor.acceptDealProposals(rt, mAddrs, pcids...)
+		require.Len(t, ret.IDs, 1)
+		assert.False(t, actor.hasClientProposal(rt, pcids[0]))
+		assert.True(t, actor.hasClientProposal(rt, pcids[1]))
+
+		// Once funded, the remaining proposal may be accepted.
+		actor.addParticipantFunds(rt, client, deal2.ClientBalanceRequirement())
+		ret = actor.acceptDealProposals(rt, mAddrs, pcids[1])
+		require.Len(t, ret.IDs, 1)
+		assert.False(t, actor.hasClientProposal(rt, pcids[1]))
+		actor.checkState(rt)
+	})
+
+	t.Run("only the client may publish its proposals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the client", func() {
+			actor.publishStorageDealsFromClient(rt, other, deal)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("proposal already awaiting acceptance is rejected", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		actor.publishStorageDealsFromClient(rt, client, deal)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "already awaiting acceptance", func() {
+			actor.publishStorageDealsFromClient(rt, client, deal)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("proposal with elapsed start epoch is rejected", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		rt.SetEpoch(startEpoch + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "has already elapsed", func() {
+			actor.publishStorageDealsFromClient(rt, client, deal)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("accepting an unknown proposal fails", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		pcid, err := deal.Cid()
+		require.NoError(t, err)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no client proposal", func() {
+			rt.Call(actor.AcceptDealProposals, &market.AcceptDealProposalsParams{Proposals: []market.DealProposalRef{{ProposalCid: pcid}}})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("accepting removes proposals whose start epoch has elapsed", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		elapsedDeal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		liveDeal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+10, endEpoch)
+		pcids := actor.publishStorageDealsFromClient(rt, client, elapsedDeal, liveDeal)
+
+		rt.SetEpoch(startEpoch + 1)
+		ret := actor.acceptDealProposals(rt, mAddrs, pcids...)
+		require.Len(t, ret.IDs, 1)
+		assert.Equal(t, liveDeal, *actor.getDealProposal(rt, ret.IDs[0]))
+		validDeals, err := ret.ValidDeals.All(2)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1}, validDeals)
+		assert.False(t, actor.hasClientProposal(rt, pcids[0]))
+		assert.False(t, actor.hasClientProposal(rt, pcids[1]))
+		actor.checkState(rt)
+	})
+
+	t.Run("accepting only elapsed proposals removes them without publishing", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		pcid := actor.publishStorageDealsFromClient(rt, client, deal)[0]
+
+		rt.SetEpoch(startEpoch + 1)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		ret := rt.Call(actor.AcceptDealProposals, &market.AcceptDealProposalsParams{Proposals: []market.DealProposalRef{{ProposalCid: pcid}}}).(*market.PublishStorageDealsReturn)
+		rt.Verify()
+		assert.Empty(t, ret.IDs)
+		assert.False(t, actor.hasClientProposal(rt, pcid))
+		actor.checkState(rt)
+	})
+
+	t.Run("client withdraws a proposal awaiting acceptance", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal1 := generateDealProposal(client, provider, startEpoch, endEpoch)
+		deal2 := generateDealProposal(client, provider, startEpoch, endEpoch+1)
+		pcids := actor.publishStorageDealsFromClient(rt, client, deal1, deal2)
+
+		actor.withdrawDealProposals(rt, client, pcids[0])
+		assert.False(t, actor.hasClientProposal(rt, pcids[0]))
+		assert.True(t, actor.hasClientProposal(rt, pcids[1]))
+
+		// A withdrawn proposal can no longer be accepted.
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no client proposal", func() {
+			rt.Call(actor.AcceptDealProposals, &market.AcceptDealProposalsParams{Proposals: []market.DealProposalRef{{ProposalCid: pcids[0]}}})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("only the client may withdraw its proposal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		pcid := actor.publishStorageDealsFromClient(rt, client, deal)[0]
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not the client", func() {
+			actor.withdrawDealProposals(rt, other, pcid)
+		})
+		assert.True(t, actor.hasClientProposal(rt, pcid))
+		actor.checkState(rt)
+	})
+
+	t.Run("withdrawing an unknown proposal fails", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		pcid, err := deal.Cid()
+		require.NoError(t, err)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no client proposal", func() {
+			actor.withdrawDealProposals(rt, client, pcid)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("revoking a proposal removes it from those awaiting acceptance", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := generateDealProposal(client, provider, startEpoch, endEpoch)
+		pcid := actor.publishStorageDealsFromClient(rt, client, deal)[0]
+
+		// Another party's revocation of the CID leaves the client's proposal in place.
+		actor.revokeDealProposalCid(rt, other, pcid, startEpoch)
+		assert.True(t, actor.hasClientProposal(rt, pcid))
+
+		actor.revokeDealProposalCid(rt, client, pcid, startEpoch)
+		assert.False(t, actor.hasClientProposal(rt, pcid))
+		actor.checkState(rt)
+	})
+}
+
+func TestCronTickDealUpdateLimit(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay
+	sectorExpiry := endEpoch + 100
+
+	lastCron := func(rt *mock.Runtime) abi.ChainEpoch {
+		var st market.State
+		rt.GetState(&st)
+		return st.LastCron
+	}
+	setLimit := func(limit int) func() {
+		prev := market.MaxDealUpdatesPerCronTick
+		market.MaxDealUpdatesPerCronTick = limit
+		return func() { market.MaxDealUpdatesPerCronTick = prev }
+	}
+
+	t.Run("updates beyond the limit continue at the next tick", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		var dealIDs []abi.DealID
+		for i := 0; i < 3; i++ {
+			dealIDs = append(dealIDs, actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch+abi.ChainEpoch(i), 0, sectorExpiry))
+		}
+
+		// The first updates of all the deals schedule their next updates for the same epoch.
+		firstUpdate := startEpoch + market.DealUpdatesInterval
+		rt.SetEpoch(firstUpdate)
+		actor.cronTick(rt)
+		assert.Equal(t, firstUpdate, lastCron(rt))
+		for _, id := range dealIDs {
+			assert.Equal(t, firstUpdate, actor.getDealState(rt, id).LastUpdatedEpoch)
+		}
+
+		defer setLimit(2)()
+		nextUpdate := firstUpdate + market.DealUpdatesInterval
+		rt.SetEpoch(nextUpdate + 10)
+		actor.cronTick(rt)
+		// The epoch holding the updates is cut short, so remains to be processed.
+		assert.Equal(t, nextUpdate-1, lastCron(rt))
+		var updated []abi.DealID
+		for _, id := range dealIDs {
+			if actor.getDealState(rt, id).LastUpdatedEpoch == rt.Epoch() {
+				updated = append(updated, id)
+			}
+		}
+		assert.Len(t, updated, 2)
+		actor.checkState(rt)
+
+		rt.SetEpoch(nextUpdate + 11)
+		actor.cronTick(rt)
+		assert.Equal(t, rt.Epoch(), lastCron(rt))
+		for _, id := range dealIDs {
+			if actor.getDealState(rt, id).LastUpdatedEpoch == rt.Epoch() {
+				updated = append(updated, id)
+			}
+		}
+		assert.ElementsMatch(t, dealIDs, updated)
+		actor.checkState(rt)
+	})
+
+	t.Run("tick stops at the limit between epochs", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealID1 := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		dealID2 := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch+1, 0, sectorExpiry)
+		epoch1 := processEpoch(t, dealID1, startEpoch)
+		epoch2 := processEpoch(t, dealID2, startEpoch)
+		require.Less(t, epoch1, epoch2)
+
+		defer setLimit(1)()
+		rt.SetEpoch(epoch2)
+		actor.cronTick(rt)
+		assert.Equal(t, epoch1, lastCron(rt))
+		assert.Equal(t, epoch2, actor.getDealState(rt, dealID1).LastUpdatedEpoch)
+		assert.Equal(t, abi.ChainEpoch(-1), actor.getDealState(rt, dealID2).LastUpdatedEpoch)
+
+		// Having reached the limit again, the tick leaves the current epoch for the next.
+		rt.SetEpoch(epoch2 + 1)
+		actor.cronTick(rt)
+		assert.Equal(t, epoch2, lastCron(rt))
+		assert.Equal(t, epoch2+1, actor.getDealState(rt, dealID2).LastUpdatedEpoch)
+
+		rt.SetEpoch(epoch2 + 2)
+		actor.cronTick(rt)
+		assert.Equal(t, epoch2+2, lastCron(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("an epoch cut short between providers resumes with the rest", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		provider2 := tutil.NewIDAddr(t, 501)
+		mAddrs2 := &minerAddrs{owner, worker, provider2, nil}
+		var dealIDs []abi.DealID
+		for i := 0; i < 2; i++ {
+			dealIDs = append(dealIDs, actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch+abi.ChainEpoch(i), 0, sectorExpiry))
+			dealIDs = append(dealIDs, actor.publishAndActivateDeal(rt, client, mAddrs2, startEpoch, endEpoch+abi.ChainEpoch(i), 0, sectorExpiry))
+		}
+
+		firstUpdate := startEpoch + market.DealUpdatesInterval
+		rt.SetEpoch(firstUpdate)
+		actor.cronTick(rt)
+
+		// The next updates of both providers' deals are scheduled for the same epoch, grouped by provider.
+		nextUpdate := firstUpdate + market.DealUpdatesInterval
+		var st market.State
+		rt.GetState(&st)
+		dobe, err := market.AsProviderSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
+		require.NoError(t, err)
+		scheduled := map[address.Address][]abi.DealID{}
+		require.NoError(t, dobe.ForEach(nextUpdate, func(p address.Address, id abi.DealID) error {
+			scheduled[p] = append(scheduled[p], id)
+			return nil
+		}))
+		assert.ElementsMatch(t, []abi.DealID{dealIDs[0], dealIDs[2]}, scheduled[provider])
+		assert.ElementsMatch(t, []abi.DealID{dealIDs[1], dealIDs[3]}, scheduled[provider2])
+
+		defer setLimit(3)()
+		rt.SetEpoch(nextUpdate)
+		actor.cronTick(rt)
+		assert.Equal(t, nextUpdate-1, lastCron(rt))
+		var updated []abi.DealID
+		for _, id := range dealIDs {
+			if actor.getDealState(rt, id).LastUpdatedEpoch == rt.Epoch() {
+				updated = append(updated, id)
+			}
+		}
+		assert.Len(t, updated, 3)
+		actor.checkState(rt)
+
+		rt.SetEpoch(nextUpdate + 1)
+		actor.cronTick(rt)
+		assert.Equal(t, rt.Epoch(), lastCron(rt))
+		for _, id := range dealIDs {
+			if actor.getDealState(rt, id).LastUpdatedEpoch == rt.Epoch() {
+				updated = append(updated, id)
+			}
+		}
+		assert.ElementsMatch(t, dealIDs, updated)
+		actor.checkState(rt)
+	})
+}
+
+func (h *marketActorTestHarness) constructAndVerify(rt *mock.Runtime) {
+	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+	ret := rt.Call(h.Constructor, nil)
+	assert.Nil(h.t, ret)
+	rt.Verify()
+}
+
+func (h *marketActorTestHarness) verifyDealsForActivation(rt *mock.Runtime, provider address.Address,
+	sectorDeals []market.SectorDeals) *market.VerifyDealsForActivationReturn {
+	param := &market.VerifyDealsForActivationParams{Sectors: sectorDeals}
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+
+	ret := rt.Call(h.VerifyDealsForActivation, param)
+	rt.Verify()
+
+	val, ok := ret.(*market.VerifyDealsForActivationReturn)
+	require.True(h.t, ok)
+	require.NotNil(h.t, val)
+	return val
+}
+
+type minerAddrs struct {
+	owner    address.Address
+	worker   address.Address
+	provider address.Address
+	control  []address.Address
+}
+
+// addProviderFunds is a helper method to setup provider market funds
+func (h *marketActorTestHarness) addProviderFunds(rt *mock.Runtime, amount abi.TokenAmount, minerAddrs *minerAddrs) {
+	rt.SetReceived(amount)
+	rt.SetAddressActorType(minerAddrs.provider, builtin.StorageMinerActorCodeID)
+	rt.SetCaller(minerAddrs.owner, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker)
+
+	rt.Call(h.AddBalance, &minerAddrs.provider)
+	rt.Verify()
+	rt.SetBalance(big.Add(rt.Balance(), amount))
+}
+
+// addParticipantFunds is a helper method to setup non-provider storage market participant funds
+func (h *marketActorTestHarness) addParticipantFunds(rt *mock.Runtime, addr address.Address, amount abi.TokenAmount) {
+	rt.SetReceived(amount)
+	rt.SetCaller(addr, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+
+	rt.Call(h.AddBalance, &addr)
+	rt.Verify()
+	rt.SetBalance(big.Add(rt.Balance(), amount))
+}
+
+func (h *marketActorTestHarness) withdrawProviderBalance(rt *mock.Runtime, withDrawAmt, expectedSend abi.TokenAmount, miner *minerAddrs) {
+	rt.SetCaller(miner.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(miner.owner, miner.worker)
+	expectGetControlAddresses(rt, miner.provider, miner.owner, miner.worker)
+
+	params := market.WithdrawBalanceParams{
+		ProviderOrClientAddress: miner.provider,
+		Amount:                  withDrawAmt,
+	}
+
+	rt.ExpectSend(miner.owner, builtin.MethodSend, nil, expectedSend, nil, exitcode.Ok)
+	ret := rt.Call(h.WithdrawBalance, &params)
+	withdrawn, ok := ret.(*abi.TokenAmount)
+	require.True(h.t, ok, "unexpected return type from WithdrawBalance")
+	require.NotNil(h.t, withdrawn)
+	rt.Verify()
+
+	assert.Equal(h.t, expectedSend, *withdrawn, "return value indicates %s withdrawn but expected %s", *withdrawn, expectedSend)
+}
+
+func (h *marketActorTestHarness) withdrawClientBalance(rt *mock.Runtime, client address.Address, withDrawAmt, expectedSend abi.TokenAmount) {
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+	rt.ExpectSend(client, builtin.MethodSend, nil, expectedSend, nil, exitcode.Ok)
+	rt.ExpectValidateCallerAddr(client)
+
+	params := market.WithdrawBalanceParams{
+		ProviderOrClientAddress: client,
+		Amount:                  withDrawAmt,
+	}
+	ret := rt.Call(h.WithdrawBalance, &params)
+	withdrawn, ok := ret.(*abi.TokenAmount)
+	require.True(h.t, ok, "unexpected return type from WithdrawBalance")
+	require.NotNil(h.t, withdrawn)
+	rt.Verify()
+
+	assert.Equal(h.t, expectedSend, *withdrawn, "return value indicates %s withdrawn but expected %s", *withdrawn, expectedSend)
+}
+
+func (h *marketActorTestHarness) cronTickNoChange(rt *mock.Runtime, client, provider address.Address) {
+	var st market.State
+	rt.GetState(&st)
+	epochCid := st.DealOpsByEpoch
+
+	// fetch current client and provider escrow balances
+	cLocked := h.getLockedBalance(rt, client)
+	cEscrow := h.getEscrowBalance(rt, client)
+	pLocked := h.getLockedBalance(rt, provider)
+	pEscrow := h.getEscrowBalance(rt, provider)
+
+	h.cronTick(rt)
+
+	rt.GetState(&st)
+	require.True(h.t, epochCid.Equals(st.DealOpsByEpoch))
+	require.EqualValues(h.t, cEscrow, h.getEscrowBalance(rt, client))
+	require.EqualValues(h.t, cLocked, h.getLockedBalance(rt, client))
+	require.EqualValues(h.t, pEscrow, h.getEscrowBalance(rt, provider))
+	require.EqualValues(h.t, pLocked, h.getLockedBalance(rt, provider))
+}
+
+// if this is the first crontick for the deal, it's next tick will be scheduled at `desiredNextEpoch`
+// if this is not the first crontick, the `desiredNextEpoch` param is ignored.
+func (h *marketActorTestHarness) cronTickAndAssertBalances(rt *mock.Runtime, client, provider address.Address,
+	currentEpoch abi.ChainEpoch, dealId abi.DealID) (payment abi.TokenAmount, amountSlashed abi.TokenAmount) {
+	// fetch current client and provider escrow balances
+	cLocked := h.getLockedBalance(rt, client)
+	cEscrow := h.getEscrowBalance(rt, client)
+	pLocked := h.getLockedBalance(rt, provider)
+	pEscrow := h.getEscrowBalance(rt, provider)
+	amountSlashed = big.Zero()
+
+	s := h.getDealState(rt, dealId)
+	d := h.getDealProposal(rt, dealId)
+
+	// end epoch for payment calc
+	paymentEnd := d.EndEpoch
+	slashed := s.SlashEpoch != -1 && s.SlashEpoch < d.EndEpoch
+	if slashed {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, d.ProviderCollateral, nil, exitcode.Ok)
+		amountSlashed = d.ProviderCollateral
+
+		if s.SlashEpoch < d.StartEpoch {
+			paymentEnd = d.StartEpoch
+		} else {
+			paymentEnd = s.SlashEpoch
+		}
 	} else if currentEpoch < paymentEnd {
 		paymentEnd = currentEpoch
 	}
@@ -2961,7 +6344,7 @@ func (h *marketActorTestHarness) cronTickAndAssertBalances(rt *mock.Runtime, cli
 	updatedProviderLocked := pLocked
 	// if the deal has expired or been slashed, locked amount will be zero for provider and client.
 	isDealExpired := paymentEnd == d.EndEpoch
-	if isDealExpired || s.SlashEpoch != -1 {
+	if isDealExpired || slashed {
 		updatedClientLocked = big.Zero()
 		updatedProviderLocked = big.Zero()
 	}
@@ -2984,12 +6367,58 @@ func (h *marketActorTestHarness) cronTick(rt *mock.Runtime) {
 	rt.Verify()
 }
 
-type publishDealReq struct {
-	deal market.DealProposal
-}
+type publishDealReq struct {
+	deal market.DealProposal
+	// request expected to the client's escrow funder, if any
+	escrowRequest *escrowRequest
+	// exit code with which a client actor responds to the request to authorize the deal,
+	// if the client is not a signing party
+	authorization *exitcode.ExitCode
+}
+
+// An expected request for funds to a client's escrow funder.
+type escrowRequest struct {
+	funder address.Address
+	amount abi.TokenAmount
+	// amount the funder adds to the client's escrow in response
+	funded   abi.TokenAmount
+	exitCode exitcode.ExitCode
+}
+
+func (h *marketActorTestHarness) publishDeals(rt *mock.Runtime, minerAddrs *minerAddrs, publishDealReqs ...publishDealReq) []abi.DealID {
+	params := h.expectPublishDeals(rt, minerAddrs, publishDealReqs...)
+	ret := rt.Call(h.PublishStorageDeals, params)
+	rt.Verify()
+
+	resp, ok := ret.(*market.PublishStorageDealsReturn)
+	require.True(h.t, ok, "unexpected type returned from call to PublishStorageDeals")
+	require.Len(h.t, resp.IDs, len(publishDealReqs))
+
+	// assert state after publishing the deals
+	dealIds := resp.IDs
+	for i, deaId := range dealIds {
+		expected := publishDealReqs[i].deal
+		p := h.getDealProposal(rt, deaId)
+
+		require.Equal(h.t, expected.StartEpoch, p.StartEpoch)
+		require.Equal(h.t, expected.EndEpoch, p.EndEpoch)
+		require.Equal(h.t, expected.PieceCID, p.PieceCID)
+		require.Equal(h.t, expected.PieceSize, p.PieceSize)
+		require.Equal(h.t, expected.Client, p.Client)
+		require.Equal(h.t, expected.Provider, p.Provider)
+		require.True(h.t, expected.Label.Equals(p.Label))
+		require.Equal(h.t, expected.VerifiedDeal, p.VerifiedDeal)
+		require.Equal(h.t, expected.StoragePricePerEpoch, p.StoragePricePerEpoch)
+		require.Equal(h.t, expected.ClientCollateral, p.ClientCollateral)
+		require.Equal(h.t, expected.ProviderCollateral, p.ProviderCollateral)
+		require.Equal(h.t, expected.RetrievalSLA, p.RetrievalSLA)
+	}
 
-func (h *marketActorTestHarness) publishDeals(rt *mock.Runtime, minerAddrs *minerAddrs, publishDealReqs ...publishDealReq) []abi.DealID {
+	return resp.IDs
+}
 
+// Sets up the expectations for publishing deals, returning the parameters to publish them.
+func (h *marketActorTestHarness) expectPublishDeals(rt *mock.Runtime, minerAddrs *minerAddrs, publishDealReqs ...publishDealReq) *market.PublishStorageDealsParams {
 	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
 	rt.ExpectSend(
 		minerAddrs.provider,
@@ -3011,45 +6440,180 @@ func (h *marketActorTestHarness) publishDeals(rt *mock.Runtime, minerAddrs *mine
 		clientProposal := market.ClientDealProposal{Proposal: pdr.deal, ClientSignature: sig}
 		params.Deals = append(params.Deals, clientProposal)
 
-		// expect a call to verify the above signature
-		rt.ExpectVerifySignature(sig, pdr.deal.Client, buf.Bytes(), nil)
+		if pdr.authorization != nil {
+			pcid, err := pdr.deal.Cid()
+			require.NoError(h.t, err)
+			rt.ExpectSend(pdr.deal.Client, market.MethodAuthorizeDeal, &market.AuthorizeDealParams{ProposalCid: pcid}, big.Zero(), nil, *pdr.authorization)
+		} else {
+			// expect a call to verify the above signature
+			rt.ExpectVerifySignature(sig, pdr.deal.Client, buf.Bytes(), nil)
+		}
+		if pdr.escrowRequest != nil {
+			h.expectEscrowRequest(rt, pdr.deal.Client, pdr.escrowRequest)
+		}
 		if pdr.deal.VerifiedDeal {
-			param := &verifreg.UseBytesParams{
-				Address:  pdr.deal.Client,
-				DealSize: big.NewIntUnsigned(uint64(pdr.deal.PieceSize)),
+			param := &verifreg.CreateAllocationParams{
+				Client:     pdr.deal.Client,
+				Provider:   minerAddrs.provider,
+				Size:       big.NewIntUnsigned(uint64(pdr.deal.PieceSize)),
+				Expiration: pdr.deal.StartEpoch,
 			}
+			ret := &verifreg.CreateAllocationReturn{AllocationID: h.nextAllocationID}
+			h.nextAllocationID++
 
-			rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.UseBytes, param, abi.NewTokenAmount(0), nil, exitcode.Ok)
+			rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.CreateAllocation, param, abi.NewTokenAmount(0), ret, exitcode.Ok)
 		}
 	}
 
-	ret := rt.Call(h.PublishStorageDeals, &params)
+	return &params
+}
+
+// Publishes a deal that is expected to be dropped as invalid, failing the call.
+func (h *marketActorTestHarness) publishInvalidDeal(rt *mock.Runtime, minerAddrs *minerAddrs, deal market.DealProposal) {
+	rt.SetCaller(minerAddrs.worker, builtin.AccountActorCodeID)
+	params := h.expectPublishDeals(rt, minerAddrs, publishDealReq{deal: deal})
+	rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "All deal proposals invalid", func() {
+		rt.Call(h.PublishStorageDeals, params)
+	})
+	rt.Reset()
+}
+
+// Sets up the expectations for publishing deals with a valid aggregate signature, returning the parameters to publish them.
+func (h *marketActorTestHarness) expectPublishDealsAggregated(rt *mock.Runtime, minerAddrs *minerAddrs, sig crypto.Signature,
+	deals ...market.DealProposal) *market.PublishStorageDealsAggregatedParams {
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	var signers []address.Address
+	var plaintexts [][]byte
+	for _, deal := range deals {
+		pcid, err := deal.Cid()
+		require.NoError(h.t, err)
+		signers = append(signers, deal.Client)
+		plaintexts = append(plaintexts, pcid.Bytes())
+	}
+	rt.ExpectVerifyAggregateSignature(sig, signers, plaintexts, nil)
+	rt.ExpectSend(
+		minerAddrs.provider,
+		builtin.MethodsMiner.ControlAddresses,
+		nil,
+		big.Zero(),
+		&miner.GetControlAddressesReturn{Owner: minerAddrs.owner, Worker: minerAddrs.worker, ControlAddrs: minerAddrs.control},
+		exitcode.Ok,
+	)
+	expectQueryNetworkInfo(rt, h)
+	return &market.PublishStorageDealsAggregatedParams{Deals: deals, AggregateSignature: sig}
+}
+
+func (h *marketActorTestHarness) postProviderAsk(rt *mock.Runtime, minerAddrs *minerAddrs, ask market.ProviderAsk) {
+	rt.SetCaller(minerAddrs.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(minerAddrs.control, minerAddrs.worker)...)
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker, minerAddrs.control...)
+
+	rt.Call(h.PostProviderAsk, &market.PostProviderAskParams{Provider: minerAddrs.provider, Ask: ask})
 	rt.Verify()
+}
 
-	resp, ok := ret.(*market.PublishStorageDealsReturn)
-	require.True(h.t, ok, "unexpected type returned from call to PublishStorageDeals")
-	require.Len(h.t, resp.IDs, len(publishDealReqs))
+func (h *marketActorTestHarness) withdrawProviderAsk(rt *mock.Runtime, minerAddrs *minerAddrs) {
+	rt.SetCaller(minerAddrs.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(minerAddrs.control, minerAddrs.worker)...)
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker, minerAddrs.control...)
 
-	// assert state after publishing the deals
-	dealIds := resp.IDs
-	for i, deaId := range dealIds {
-		expected := publishDealReqs[i].deal
-		p := h.getDealProposal(rt, deaId)
+	rt.Call(h.WithdrawProviderAsk, &market.WithdrawProviderAskParams{Provider: minerAddrs.provider})
+	rt.Verify()
+}
 
-		require.Equal(h.t, expected.StartEpoch, p.StartEpoch)
-		require.Equal(h.t, expected.EndEpoch, p.EndEpoch)
-		require.Equal(h.t, expected.PieceCID, p.PieceCID)
-		require.Equal(h.t, expected.PieceSize, p.PieceSize)
-		require.Equal(h.t, expected.Client, p.Client)
-		require.Equal(h.t, expected.Provider, p.Provider)
-		require.Equal(h.t, expected.Label, p.Label)
-		require.Equal(h.t, expected.VerifiedDeal, p.VerifiedDeal)
-		require.Equal(h.t, expected.StoragePricePerEpoch, p.StoragePricePerEpoch)
-		require.Equal(h.t, expected.ClientCollateral, p.ClientCollateral)
-		require.Equal(h.t, expected.ProviderCollateral, p.ProviderCollateral)
-	}
+func (h *marketActorTestHarness) getProviderAsk(rt *mock.Runtime, provider address.Address) (*market.ProviderAsk, bool) {
+	var st market.State
+	rt.GetState(&st)
 
-	return resp.IDs
+	asks, err := adt.AsMap(adt.AsStore(rt), st.ProviderAsks, builtin.DefaultHamtBitwidth)
+	require.NoError(h.t, err)
+	var ask market.ProviderAsk
+	found, err := asks.Get(abi.AddrKey(provider), &ask)
+	require.NoError(h.t, err)
+	return &ask, found
+}
+
+func (h *marketActorTestHarness) revokeDealProposal(rt *mock.Runtime, client address.Address, proposal *market.DealProposal, expiry abi.ChainEpoch) {
+	pcid, err := proposal.Cid()
+	require.NoError(h.t, err)
+	h.revokeDealProposalCid(rt, client, pcid, expiry)
+}
+
+func (h *marketActorTestHarness) revokeDealProposalCid(rt *mock.Runtime, client address.Address, proposalCid cid.Cid, expiry abi.ChainEpoch) {
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+
+	rt.Call(h.RevokeDealProposal, &market.RevokeDealProposalParams{ProposalCid: proposalCid, Expiry: expiry})
+	rt.Verify()
+}
+
+func (h *marketActorTestHarness) indexDealLabels(rt *mock.Runtime, client address.Address, dealIDs ...abi.DealID) {
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+
+	rt.Call(h.IndexDealLabels, &market.IndexDealLabelsParams{DealIDs: dealIDs})
+	rt.Verify()
+}
+
+func (h *marketActorTestHarness) lookupDealsByLabel(rt *mock.Runtime, client address.Address, label string) []abi.DealID {
+	return h.lookupDealsByDealLabel(rt, client, mustLabel(label))
+}
+
+func (h *marketActorTestHarness) lookupDealsByDealLabel(rt *mock.Runtime, client address.Address, label market.DealLabel) []abi.DealID {
+	rt.ExpectValidateCallerAny()
+
+	ret := rt.Call(h.LookupDealsByLabel, &market.LookupDealsByLabelParams{Client: client, Label: label})
+	rt.Verify()
+	return ret.(*market.LookupDealsByLabelReturn).DealIDs
+}
+
+func (h *marketActorTestHarness) verifyPieceInclusion(rt *mock.Runtime, params *market.VerifyPieceInclusionParams) {
+	rt.ExpectValidateCallerAny()
+
+	rt.Call(h.VerifyPieceInclusion, params)
+	rt.Verify()
+}
+
+// Code CID of the escrow funders in tests, which are not built-in actors.
+var escrowFunderActorCode = tutil.MakeCID("escrowfunder", nil)
+
+func (h *marketActorTestHarness) authorizeEscrowFunder(rt *mock.Runtime, client, funder address.Address, maxPerEpoch abi.TokenAmount) {
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	rt.SetAddressActorType(funder, escrowFunderActorCode)
+
+	rt.Call(h.AuthorizeEscrowFunder, &market.AuthorizeEscrowFunderParams{Funder: funder, MaxPerEpoch: maxPerEpoch})
+	rt.Verify()
+}
+
+func (h *marketActorTestHarness) fundClientEscrow(rt *mock.Runtime, funder, client address.Address, amount abi.TokenAmount) {
+	rt.SetReceived(amount)
+	rt.SetCaller(funder, escrowFunderActorCode)
+	rt.ExpectValidateCallerAny()
+
+	rt.Call(h.FundClientEscrow, &client)
+	rt.Verify()
+	rt.SetBalance(big.Add(rt.Balance(), amount))
+}
+
+// Expects a request to the client's escrow funder, simulating the funder's response by adding
+// the funded amount to the client's escrow.
+func (h *marketActorTestHarness) expectEscrowRequest(rt *mock.Runtime, client address.Address, req *escrowRequest) {
+	params := &market.RequestEscrowFundsParams{Client: client, Amount: req.amount}
+	rt.ExpectSendWithEffect(req.funder, market.MethodRequestEscrowFunds, params, big.Zero(), nil, req.exitCode, func() {
+		if req.funded.IsZero() {
+			return
+		}
+		var st market.State
+		rt.GetState(&st)
+		et, err := adt.AsBalanceTable(adt.AsStore(rt), st.EscrowTable)
+		require.NoError(h.t, err)
+		require.NoError(h.t, et.Add(client, req.funded))
+		st.EscrowTable, err = et.Root()
+		require.NoError(h.t, err)
+		rt.ReplaceState(&st)
+		rt.SetBalance(big.Add(rt.Balance(), req.funded))
+	})
 }
 
 func (h *marketActorTestHarness) assertDealsNotActivated(rt *mock.Runtime, epoch abi.ChainEpoch, dealIDs ...abi.DealID) {
@@ -3067,10 +6631,39 @@ func (h *marketActorTestHarness) assertDealsNotActivated(rt *mock.Runtime, epoch
 }
 
 func (h *marketActorTestHarness) activateDeals(rt *mock.Runtime, sectorExpiry abi.ChainEpoch, provider address.Address, currentEpoch abi.ChainEpoch, dealIDs ...abi.DealID) {
+	h.activateDealsInSector(rt, 0, sectorExpiry, provider, currentEpoch, dealIDs...)
+}
+
+func (h *marketActorTestHarness) activateDealsInSector(rt *mock.Runtime, sectorNumber abi.SectorNumber, sectorExpiry abi.ChainEpoch, provider address.Address, currentEpoch abi.ChainEpoch, dealIDs ...abi.DealID) {
 	rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
 	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
 
-	params := &market.ActivateDealsParams{DealIDs: dealIDs, SectorExpiry: sectorExpiry}
+	params := &market.ActivateDealsParams{DealIDs: dealIDs, SectorExpiry: sectorExpiry, SectorNumber: sectorNumber}
+
+	var verifiedActivations []verifreg.ActivatedBytes
+	var allocationIDs []verifreg.AllocationID
+	for _, d := range dealIDs {
+		proposal := h.getDealProposal(rt, d)
+		if proposal.VerifiedDeal {
+			verifiedActivations = append(verifiedActivations, verifreg.ActivatedBytes{
+				Client:   proposal.Client,
+				Provider: proposal.Provider,
+				DealSize: big.NewIntUnsigned(uint64(proposal.PieceSize)),
+			})
+			if id, found := h.getDealAllocation(rt, d); found {
+				allocationIDs = append(allocationIDs, id)
+			}
+		}
+	}
+	if len(allocationIDs) > 0 {
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.ClaimAllocations,
+			&verifreg.ClaimAllocationsParams{Provider: provider, Sector: sectorNumber, AllocationIDs: allocationIDs},
+			abi.NewTokenAmount(0), nil, exitcode.Ok)
+	}
+	if len(verifiedActivations) > 0 {
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RecordActivatedBytes,
+			&verifreg.RecordActivatedBytesParams{Activations: verifiedActivations}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+	}
 
 	ret := rt.Call(h.ActivateDeals, params)
 	rt.Verify()
@@ -3080,6 +6673,9 @@ func (h *marketActorTestHarness) activateDeals(rt *mock.Runtime, sectorExpiry ab
 	for _, d := range dealIDs {
 		s := h.getDealState(rt, d)
 		require.EqualValues(h.t, currentEpoch, s.SectorStartEpoch)
+		require.EqualValues(h.t, sectorNumber, s.SectorNumber)
+		_, allocated := h.getDealAllocation(rt, d)
+		require.False(h.t, allocated)
 	}
 }
 
@@ -3153,6 +6749,48 @@ func (h *marketActorTestHarness) getDealState(rt *mock.Runtime, dealID abi.DealI
 	return s
 }
 
+func (h *marketActorTestHarness) isDealBreached(rt *mock.Runtime, dealID abi.DealID) bool {
+	var st market.State
+	rt.GetState(&st)
+
+	breached, err := adt.AsSet(adt.AsStore(rt), st.BreachedDeals, builtin.DefaultHamtBitwidth)
+	require.NoError(h.t, err)
+
+	found, err := breached.Has(abi.UIntKey(uint64(dealID)))
+	require.NoError(h.t, err)
+	return found
+}
+
+func (h *marketActorTestHarness) getDealAllocation(rt *mock.Runtime, dealID abi.DealID) (verifreg.AllocationID, bool) {
+	var st market.State
+	rt.GetState(&st)
+
+	id, found, err := st.GetDealAllocation(adt.AsStore(rt), dealID)
+	require.NoError(h.t, err)
+	return id, found
+}
+
+func (h *marketActorTestHarness) assertDealAllocation(rt *mock.Runtime, dealID abi.DealID, expected verifreg.AllocationID) {
+	id, found := h.getDealAllocation(rt, dealID)
+	require.True(h.t, found, "no allocation for deal %d", dealID)
+	require.Equal(h.t, expected, id)
+}
+
+// Removes a deal's allocation, as if the deal had been published before allocations were made for verified deals.
+func (h *marketActorTestHarness) removeDealAllocation(rt *mock.Runtime, dealID abi.DealID) {
+	var st market.State
+	rt.GetState(&st)
+
+	allocations, err := adt.AsMap(adt.AsStore(rt), st.DealAllocations, builtin.DefaultHamtBitwidth)
+	require.NoError(h.t, err)
+	found, err := allocations.TryDelete(abi.UIntKey(uint64(dealID)))
+	require.NoError(h.t, err)
+	require.True(h.t, found)
+	st.DealAllocations, err = allocations.Root()
+	require.NoError(h.t, err)
+	rt.ReplaceState(&st)
+}
+
 func (h *marketActorTestHarness) assertLockedFundStates(rt *mock.Runtime, storageFee, providerCollateral, clientCollateral abi.TokenAmount) {
 	var st market.State
 	rt.GetState(&st)
@@ -3201,7 +6839,7 @@ func (h *marketActorTestHarness) assertDeaslNotTerminated(rt *mock.Runtime, deal
 	}
 }
 
-func (h *marketActorTestHarness) terminateDeals(rt *mock.Runtime, minerAddr address.Address, dealIds ...abi.DealID) {
+func (h *marketActorTestHarness) terminateDeals(rt *mock.Runtime, minerAddr address.Address, dealIds ...abi.DealID) *market.OnMinerSectorsTerminateReturn {
 	rt.SetCaller(minerAddr, builtin.StorageMinerActorCodeID)
 	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
 
@@ -3209,7 +6847,14 @@ func (h *marketActorTestHarness) terminateDeals(rt *mock.Runtime, minerAddr addr
 
 	ret := rt.Call(h.OnMinerSectorsTerminate, params)
 	rt.Verify()
-	require.Nil(h.t, ret)
+	return ret.(*market.OnMinerSectorsTerminateReturn)
+}
+
+func (h *marketActorTestHarness) replaceDeals(rt *mock.Runtime, minerAddr address.Address, dealIds ...abi.DealID) {
+	rt.SetCaller(minerAddr, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	rt.Call(h.OnMinerDealsReplaced, &market.OnMinerDealsReplacedParams{DealIDs: dealIds})
+	rt.Verify()
 }
 
 func (h *marketActorTestHarness) publishAndActivateDeal(rt *mock.Runtime, client address.Address, minerAddrs *minerAddrs,
@@ -3232,12 +6877,246 @@ func (h *marketActorTestHarness) updateLastUpdated(rt *mock.Runtime, dealId abi.
 	require.NoError(h.t, err)
 	require.NotNil(h.t, s)
 
-	require.NoError(h.t, states.Set(dealId, &market.DealState{s.SectorStartEpoch, newLastUpdated, s.SlashEpoch}))
+	require.NoError(h.t, states.Set(dealId, &market.DealState{s.SectorStartEpoch, newLastUpdated, s.SlashEpoch, s.SectorNumber}))
 	st.States, err = states.Root()
 	require.NoError(h.t, err)
 	rt.ReplaceState(&st)
 }
 
+func (h *marketActorTestHarness) getBalance(rt *mock.Runtime, addr address.Address) *market.GetBalanceReturn {
+	rt.SetCaller(tutil.NewIDAddr(h.t, 1000), builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.GetBalance, &addr).(*market.GetBalanceReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *marketActorTestHarness) getDealStatus(rt *mock.Runtime, dealID abi.DealID) market.DealStatus {
+	rt.SetCaller(tutil.NewIDAddr(h.t, 1000), builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.GetDealStatus, &market.GetDealStatusParams{DealID: dealID}).(*market.GetDealStatusReturn)
+	rt.Verify()
+	return ret.Status
+}
+
+func (h *marketActorTestHarness) getDealActivation(rt *mock.Runtime, dealID abi.DealID) *market.GetDealActivationReturn {
+	rt.SetCaller(tutil.NewIDAddr(h.t, 1000), builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.GetDealActivation, &market.GetDealActivationParams{DealID: dealID}).(*market.GetDealActivationReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *marketActorTestHarness) getDealUpdateEpoch(rt *mock.Runtime, dealID abi.DealID) abi.ChainEpoch {
+	rt.SetCaller(tutil.NewIDAddr(h.t, 1000), builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.GetDealUpdateEpoch, &market.GetDealUpdateEpochParams{DealID: dealID}).(*market.GetDealUpdateEpochReturn)
+	rt.Verify()
+	return ret.Epoch
+}
+
+func (h *marketActorTestHarness) publishStorageDealsFromClient(rt *mock.Runtime, client address.Address, proposals ...market.DealProposal) []cid.Cid {
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	ret := rt.Call(h.PublishStorageDealsFromClient, &market.PublishStorageDealsFromClientParams{Proposals: proposals}).(*market.PublishStorageDealsFromClientReturn)
+	rt.Verify()
+	return ret.ProposalCids
+}
+
+func (h *marketActorTestHarness) acceptDealProposals(rt *mock.Runtime, minerAddrs *minerAddrs, proposalCids ...cid.Cid) *market.PublishStorageDealsReturn {
+	rt.SetCaller(minerAddrs.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker, minerAddrs.control...)
+	expectQueryNetworkInfo(rt, h)
+
+	params := market.AcceptDealProposalsParams{}
+	for _, pcid := range proposalCids {
+		params.Proposals = append(params.Proposals, market.DealProposalRef{ProposalCid: pcid})
+	}
+	ret := rt.Call(h.AcceptDealProposals, &params).(*market.PublishStorageDealsReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *marketActorTestHarness) withdrawDealProposals(rt *mock.Runtime, client address.Address, proposalCids ...cid.Cid) {
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+
+	params := market.WithdrawDealProposalsParams{}
+	for _, pcid := range proposalCids {
+		params.Proposals = append(params.Proposals, market.DealProposalRef{ProposalCid: pcid})
+	}
+	rt.Call(h.WithdrawDealProposals, &params)
+	rt.Verify()
+}
+
+func (h *marketActorTestHarness) hasClientProposal(rt *mock.Runtime, proposalCid cid.Cid) bool {
+	var st market.State
+	rt.GetState(&st)
+	proposals, err := adt.AsMap(adt.AsStore(rt), st.ClientProposals, builtin.DefaultHamtBitwidth)
+	require.NoError(h.t, err)
+	found, err := proposals.Has(abi.CidKey(proposalCid))
+	require.NoError(h.t, err)
+	return found
+}
+
+func (h *marketActorTestHarness) settleDealPayments(rt *mock.Runtime, caller address.Address, dealIDs ...abi.DealID) {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	rt.Call(h.SettleDealPayments, &market.SettleDealPaymentsParams{DealIDs: dealIDs})
+	rt.Verify()
+}
+
+// Amends a deal as sent by the caller, expecting the signer's signature over the amendment to be verified.
+func (h *marketActorTestHarness) amendDealProposal(rt *mock.Runtime, caller, signer address.Address, minerAddrs *minerAddrs,
+	amendment market.DealAmendment) cid.Cid {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker, minerAddrs.control...)
+	signed, err := market.SigningBytes(market.SigningDomainDealAmendment, &amendment)
+	require.NoError(h.t, err)
+	rt.ExpectVerifySignature(crypto.Signature{}, signer, signed, nil)
+	ret := rt.Call(h.AmendDealProposal, &market.AmendDealProposalParams{Amendment: amendment}).(*market.AmendDealProposalReturn)
+	rt.Verify()
+	return ret.ProposalCid
+}
+
+func (h *marketActorTestHarness) transferDealClient(rt *mock.Runtime, caller address.Address, transfer market.DealClientTransfer) cid.Cid {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	signed, err := market.SigningBytes(market.SigningDomainDealClientTransfer, &transfer)
+	require.NoError(h.t, err)
+	rt.ExpectVerifySignature(crypto.Signature{}, transfer.NewClient, signed, nil)
+	ret := rt.Call(h.TransferDealClient, &market.TransferDealClientParams{Transfer: transfer}).(*market.TransferDealClientReturn)
+	rt.Verify()
+	return ret.ProposalCid
+}
+
+func (h *marketActorTestHarness) addProviderCollateral(rt *mock.Runtime, caller address.Address, minerAddrs *minerAddrs,
+	params market.AddProviderCollateralParams) cid.Cid {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker, minerAddrs.control...)
+	rt.ExpectValidateCallerAddr(append(minerAddrs.control, minerAddrs.worker)...)
+	ret := rt.Call(h.AddProviderCollateral, &params).(*market.AddProviderCollateralReturn)
+	rt.Verify()
+	return ret.ProposalCid
+}
+
+func (h *marketActorTestHarness) contestDealSlash(rt *mock.Runtime, caller address.Address, minerAddrs *minerAddrs, dealIDs ...abi.DealID) {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker, minerAddrs.control...)
+	rt.ExpectValidateCallerAddr(append(minerAddrs.control, minerAddrs.worker)...)
+	rt.Call(h.ContestDealSlash, &market.ContestDealSlashParams{Provider: minerAddrs.provider, DealIDs: dealIDs})
+	rt.Verify()
+}
+
+func newProviderCollateralAddition(t *testing.T, dealID abi.DealID, deal *market.DealProposal, amount abi.TokenAmount) market.AddProviderCollateralParams {
+	pcid, err := deal.Cid()
+	require.NoError(t, err)
+	return market.AddProviderCollateralParams{
+		DealID:      dealID,
+		ProposalCid: pcid,
+		Amount:      amount,
+	}
+}
+
+// Reports a violation as sent by the caller, expecting the client's signature over it to be verified and
+// the penalty of any previous violation to be burnt.
+func (h *marketActorTestHarness) reportRetrievalViolation(rt *mock.Runtime, caller address.Address,
+	violation market.RetrievalViolation, burnt abi.TokenAmount) *market.ReportRetrievalViolationReturn {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	client := h.getDealProposal(rt, violation.DealID).Client
+	signed, err := market.SigningBytes(market.SigningDomainRetrievalViolation, &violation)
+	require.NoError(h.t, err)
+	rt.ExpectVerifySignature(crypto.Signature{}, client, signed, nil)
+	if !burnt.IsZero() {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, burnt, nil, exitcode.Ok)
+	}
+	ret := rt.Call(h.ReportRetrievalViolation, &market.ReportRetrievalViolationParams{Violation: violation}).(*market.ReportRetrievalViolationReturn)
+	rt.Verify()
+	return ret
+}
+
+// Disputes a violation as sent by the caller, expecting the client's signature over the receipt to be verified.
+func (h *marketActorTestHarness) disputeRetrievalViolation(rt *mock.Runtime, caller address.Address, minerAddrs *minerAddrs,
+	receipt market.RetrievalReceipt) {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	expectGetControlAddresses(rt, minerAddrs.provider, minerAddrs.owner, minerAddrs.worker, minerAddrs.control...)
+	rt.ExpectValidateCallerAddr(append(minerAddrs.control, minerAddrs.worker)...)
+	client := h.getDealProposal(rt, receipt.DealID).Client
+	signed, err := market.SigningBytes(market.SigningDomainRetrievalReceipt, &receipt)
+	require.NoError(h.t, err)
+	rt.ExpectVerifySignature(crypto.Signature{}, client, signed, nil)
+	rt.Call(h.DisputeRetrievalViolation, &market.DisputeRetrievalViolationParams{Receipt: receipt})
+	rt.Verify()
+}
+
+func newRetrievalViolation(t *testing.T, dealID abi.DealID, deal *market.DealProposal, epoch abi.ChainEpoch) market.RetrievalViolation {
+	pcid, err := deal.Cid()
+	require.NoError(t, err)
+	return market.RetrievalViolation{
+		DealID:      dealID,
+		ProposalCid: pcid,
+		Epoch:       epoch,
+	}
+}
+
+func newDealClientTransfer(t *testing.T, dealID abi.DealID, deal *market.DealProposal, newClient address.Address) market.DealClientTransfer {
+	pcid, err := deal.Cid()
+	require.NoError(t, err)
+	return market.DealClientTransfer{
+		DealID:      dealID,
+		ProposalCid: pcid,
+		NewClient:   newClient,
+	}
+}
+
+func newDealAmendment(t *testing.T, dealID abi.DealID, deal *market.DealProposal, price abi.TokenAmount, endEpoch abi.ChainEpoch) market.DealAmendment {
+	pcid, err := deal.Cid()
+	require.NoError(t, err)
+	return market.DealAmendment{
+		DealID:               dealID,
+		ProposalCid:          pcid,
+		StoragePricePerEpoch: price,
+		EndEpoch:             endEpoch,
+	}
+}
+
+// Requests termination of the sector holding a deal as its client, expecting the deal's provider to accept.
+func (h *marketActorTestHarness) terminateBreachedDeal(rt *mock.Runtime, client address.Address, dealID abi.DealID, sectorNumber abi.SectorNumber) {
+	provider := h.getDealProposal(rt, dealID).Provider
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+	rt.ExpectSend(provider, builtin.MethodsMiner.TerminateBreachedSector, &builtin.TerminateBreachedSectorParams{
+		SectorNumber: sectorNumber,
+		DealID:       dealID,
+	}, big.Zero(), nil, exitcode.Ok)
+	rt.Call(h.TerminateBreachedDeal, &market.TerminateBreachedDealParams{DealID: dealID, SectorNumber: sectorNumber})
+	rt.Verify()
+}
+
+func (h *marketActorTestHarness) sampleDealsForAudit(rt *mock.Runtime, epoch abi.ChainEpoch, offset, count uint64,
+	randomness abi.Randomness) []abi.DealID {
+	rt.ExpectValidateCallerAny()
+	rt.ExpectGetRandomnessBeacon(builtin.DomainSeparationTag_MarketDealAuditSeed, epoch, nil, randomness)
+	ret := rt.Call(h.SampleDealsForAudit, &market.SampleDealsForAuditParams{
+		Epoch:  epoch,
+		Offset: offset,
+		Count:  count,
+	}).(*market.SampleDealsForAuditReturn)
+	rt.Verify()
+	return ret.DealIDs
+}
+
+func (h *marketActorTestHarness) repairLockedTotals(rt *mock.Runtime) *market.RepairLockedTotalsReturn {
+	rt.SetCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+	ret := rt.Call(h.RepairLockedTotals, nil).(*market.RepairLockedTotalsReturn)
+	rt.Verify()
+	return ret
+}
+
 func (h *marketActorTestHarness) deleteDealProposal(rt *mock.Runtime, dealId abi.DealID) {
 	var st market.State
 	rt.GetState(&st)
@@ -3266,7 +7145,7 @@ func (h *marketActorTestHarness) generateAndPublishDealForPiece(rt *mock.Runtime
 	clientCollateral := big.NewInt(10)
 	providerCollateral := big.NewInt(10)
 
-	deal := market.DealProposal{PieceCID: pieceCID, PieceSize: pieceSize, Client: client, Provider: minerAddrs.provider, Label: "label", StartEpoch: startEpoch,
+	deal := market.DealProposal{PieceCID: pieceCID, PieceSize: pieceSize, Client: client, Provider: minerAddrs.provider, Label: mustLabel("label"), StartEpoch: startEpoch,
 		EndEpoch: endEpoch, StoragePricePerEpoch: storagePerEpoch, ProviderCollateral: providerCollateral, ClientCollateral: clientCollateral}
 
 	// add funds
@@ -3318,7 +7197,7 @@ func generateDealProposalWithCollateral(client, provider address.Address, provid
 	pieceSize := abi.PaddedPieceSize(2048)
 	storagePerEpoch := big.NewInt(10)
 
-	return market.DealProposal{PieceCID: pieceCid, PieceSize: pieceSize, Client: client, Provider: provider, Label: "label", StartEpoch: startEpoch,
+	return market.DealProposal{PieceCID: pieceCid, PieceSize: pieceSize, Client: client, Provider: provider, Label: mustLabel("label"), StartEpoch: startEpoch,
 		EndEpoch: endEpoch, StoragePricePerEpoch: storagePerEpoch, ProviderCollateral: providerCollateral, ClientCollateral: clientCollateral}
 }
 
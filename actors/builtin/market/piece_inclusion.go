@@ -0,0 +1,99 @@
+package market
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/bits"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+)
+
+// Size in bytes of a node in a piece commitment tree.
+const pieceNodeSize = 32
+
+// A Merkle proof that a sub-piece's commitment is the root of a subtree of an aggregate piece's
+// commitment tree, as in the sub-tree proof of a PoDSI data segment inclusion proof.
+type PieceInclusionProof struct {
+	// Index of the sub-piece's subtree among the subtrees of the same size in the aggregate piece,
+	// counting from the left.
+	Index uint64
+	// Sibling nodes on the path from the sub-piece's subtree root up to the aggregate piece's root,
+	// starting from the bottom.
+	Path [][]byte
+}
+
+// Verifies a proof that a sub-piece of the given padded size is included in an aggregate piece of
+// the given padded size. Both pieces are identified by their unsealed piece commitment CIDs.
+func VerifyPieceInclusionProof(pieceCID cid.Cid, pieceSize abi.PaddedPieceSize, subPieceCID cid.Cid,
+	subPieceSize abi.PaddedPieceSize, proof *PieceInclusionProof) error {
+	if err := pieceSize.Validate(); err != nil {
+		return xerrors.Errorf("invalid piece size: %w", err)
+	}
+	if err := subPieceSize.Validate(); err != nil {
+		return xerrors.Errorf("invalid sub-piece size: %w", err)
+	}
+	if subPieceSize > pieceSize {
+		return xerrors.Errorf("sub-piece size %d exceeds piece size %d", subPieceSize, pieceSize)
+	}
+	root, err := pieceCommitment(pieceCID)
+	if err != nil {
+		return xerrors.Errorf("invalid piece CID: %w", err)
+	}
+	node, err := pieceCommitment(subPieceCID)
+	if err != nil {
+		return xerrors.Errorf("invalid sub-piece CID: %w", err)
+	}
+
+	// Both sizes are powers of two, so the sub-piece's subtree is this many levels below the root.
+	subtrees := uint64(pieceSize / subPieceSize)
+	depth := bits.TrailingZeros64(subtrees)
+	if len(proof.Path) != depth {
+		return xerrors.Errorf("proof path length %d does not match sub-piece depth %d", len(proof.Path), depth)
+	}
+	if proof.Index >= subtrees {
+		return xerrors.Errorf("sub-piece index %d out of range for %d sub-pieces", proof.Index, subtrees)
+	}
+
+	index := proof.Index
+	for level, sibling := range proof.Path {
+		if len(sibling) != pieceNodeSize {
+			return xerrors.Errorf("proof node at level %d has length %d, expected %d", level, len(sibling), pieceNodeSize)
+		}
+		if index&1 == 0 {
+			node = hashPieceNodes(node, sibling)
+		} else {
+			node = hashPieceNodes(sibling, node)
+		}
+		index >>= 1
+	}
+	if !bytes.Equal(node, root) {
+		return xerrors.Errorf("proof does not match piece commitment")
+	}
+	return nil
+}
+
+// Extracts the root of a piece commitment tree from an unsealed piece commitment CID.
+func pieceCommitment(c cid.Cid) ([]byte, error) {
+	if c.Prefix() != PieceCIDPrefix {
+		return nil, xerrors.Errorf("CID %s is not a piece commitment", c)
+	}
+	decoded, err := mh.Decode(c.Hash())
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode multihash of %s: %w", c, err)
+	}
+	return decoded.Digest, nil
+}
+
+// Computes a parent node in a piece commitment tree, which is the SHA-256 digest of its children
+// truncated to 254 bits.
+func hashPieceNodes(left, right []byte) []byte {
+	h := sha256.New()
+	_, _ = h.Write(left)
+	_, _ = h.Write(right)
+	digest := h.Sum(nil)
+	digest[pieceNodeSize-1] &= 0x3f
+	return digest
+}
@@ -0,0 +1,105 @@
+package market_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
+	tutil "github.com/filecoin-project/specs-actors/v8/support/testing"
+)
+
+// Commitments of a 512 byte piece aggregated from four 128 byte sub-pieces A, B, C and D.
+// A is all zeros; B, C and D are filled with the bytes 0x01, 0x02 and 0x03 respectively, with the
+// top two bits of each 32 byte node cleared.
+const (
+	pieceA   = "3731bb99ac689f66eef5973e4a94da188f4ddcae580724fc6f3fd60dfd488333"
+	pieceB   = "e7f5207f26898ffae14afd9e9247b4851c224e0f41ee7d168e6a42861014e924"
+	pieceC   = "ea0e07b127ce58fe5d30a3d5dcb8c5618f36158356d405a47dab97d7ddf4890d"
+	pieceD   = "26a9ded528a5e27a3f8a3c6fd25d9b0155bbce761386c4bab2057cc2c30e4f09"
+	pieceAB  = "c2788fd859ac6839e815bab8883d03e5c7b8edbb69eed03640a9a78e0b79500a"
+	pieceCD  = "35bec799f8b51e83cea4ae4de0876f57b428cc0c346c8084aaed0baa84442202"
+	pieceAll = "9d58f38ac16b1f727bccee45a3431c63b1da922579ea82df34fc6fd07bc89102"
+
+	// Commitment of a 256 byte zero piece.
+	zeroPiece256 = "642a607ef886b004bf2c1978463ae1d4693ac0f410eb2d1b7a47fe205e5e750f"
+)
+
+func TestVerifyPieceInclusionProof(t *testing.T) {
+	t.Run("sub-piece of a zero piece", func(t *testing.T) {
+		// A 128 byte zero piece is the left child of a 256 byte zero piece, with an identical sibling.
+		proof := market.PieceInclusionProof{Index: 0, Path: [][]byte{mustDecodeHex(t, pieceA)}}
+		require.NoError(t, market.VerifyPieceInclusionProof(pieceCommitmentCID(t, zeroPiece256), 256, pieceCommitmentCID(t, pieceA), 128, &proof))
+	})
+
+	t.Run("each sub-piece of an aggregate piece", func(t *testing.T) {
+		root := pieceCommitmentCID(t, pieceAll)
+		cases := []struct {
+			subPiece string
+			proof    market.PieceInclusionProof
+		}{
+			{pieceA, market.PieceInclusionProof{Index: 0, Path: [][]byte{mustDecodeHex(t, pieceB), mustDecodeHex(t, pieceCD)}}},
+			{pieceB, market.PieceInclusionProof{Index: 1, Path: [][]byte{mustDecodeHex(t, pieceA), mustDecodeHex(t, pieceCD)}}},
+			{pieceC, market.PieceInclusionProof{Index: 2, Path: [][]byte{mustDecodeHex(t, pieceD), mustDecodeHex(t, pieceAB)}}},
+			{pieceD, market.PieceInclusionProof{Index: 3, Path: [][]byte{mustDecodeHex(t, pieceC), mustDecodeHex(t, pieceAB)}}},
+		}
+		for _, tc := range cases {
+			assert.NoError(t, market.VerifyPieceInclusionProof(root, 512, pieceCommitmentCID(t, tc.subPiece), 128, &tc.proof))
+		}
+
+		// A 256 byte sub-piece one level down.
+		proof := market.PieceInclusionProof{Index: 1, Path: [][]byte{mustDecodeHex(t, pieceAB)}}
+		assert.NoError(t, market.VerifyPieceInclusionProof(root, 512, pieceCommitmentCID(t, pieceCD), 256, &proof))
+
+		// The piece itself, with an empty proof.
+		assert.NoError(t, market.VerifyPieceInclusionProof(root, 512, root, 512, &market.PieceInclusionProof{}))
+	})
+
+	t.Run("invalid proofs", func(t *testing.T) {
+		root := pieceCommitmentCID(t, pieceAll)
+		subPiece := pieceCommitmentCID(t, pieceC)
+		path := [][]byte{mustDecodeHex(t, pieceD), mustDecodeHex(t, pieceAB)}
+		cases := []struct {
+			desc         string
+			pieceCID     cid.Cid
+			subPieceCID  cid.Cid
+			subPieceSize abi.PaddedPieceSize
+			proof        market.PieceInclusionProof
+			err          string
+		}{
+			{"wrong index", root, subPiece, 128, market.PieceInclusionProof{Index: 3, Path: path}, "does not match"},
+			{"index out of range", root, subPiece, 128, market.PieceInclusionProof{Index: 4, Path: path}, "out of range"},
+			{"path too short", root, subPiece, 128, market.PieceInclusionProof{Index: 2, Path: path[:1]}, "path length"},
+			{"path too long", root, subPiece, 128, market.PieceInclusionProof{Index: 2, Path: append(path, path[0])}, "path length"},
+			{"wrong sibling", root, subPiece, 128, market.PieceInclusionProof{Index: 2, Path: [][]byte{path[1], path[0]}}, "does not match"},
+			{"short node", root, subPiece, 128, market.PieceInclusionProof{Index: 2, Path: [][]byte{path[0][:31], path[1]}}, "has length 31"},
+			{"wrong sub-piece", root, pieceCommitmentCID(t, pieceB), 128, market.PieceInclusionProof{Index: 2, Path: path}, "does not match"},
+			{"sub-piece larger than piece", root, subPiece, 1024, market.PieceInclusionProof{}, "exceeds piece size"},
+			{"invalid sub-piece size", root, subPiece, 100, market.PieceInclusionProof{Index: 2, Path: path}, "invalid sub-piece size"},
+			{"sub-piece not a piece commitment", root, tutil.MakeCID("C", nil), 128, market.PieceInclusionProof{Index: 2, Path: path}, "not a piece commitment"},
+		}
+		for _, tc := range cases {
+			err := market.VerifyPieceInclusionProof(tc.pieceCID, 512, tc.subPieceCID, tc.subPieceSize, &tc.proof)
+			if assert.Error(t, err, tc.desc) {
+				assert.Contains(t, err.Error(), tc.err, tc.desc)
+			}
+		}
+	})
+}
+
+func pieceCommitmentCID(t *testing.T, commitment string) cid.Cid {
+	digest, err := mh.Encode(mustDecodeHex(t, commitment), mh.SHA2_256_TRUNC254_PADDED)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.FilCommitmentUnsealed, digest)
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
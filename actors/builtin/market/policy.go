@@ -10,6 +10,10 @@ import (
 // The number of epochs between payment and other state processing for deals.
 const DealUpdatesInterval = builtin.EpochsInDay // PARAM_SPEC
 
+// Maximum number of scheduled deal updates processed in a single cron tick. Updates beyond this limit
+// are deferred to subsequent ticks, which take them in order of their scheduled epochs.
+var MaxDealUpdatesPerCronTick = 10_000 // PARAM_SPEC
+
 // The percentage of normalized cirulating
 // supply that must be covered by provider collateral in a deal
 var ProviderCollateralSupplyTarget = builtin.BigFrac{
@@ -17,15 +21,71 @@ var ProviderCollateralSupplyTarget = builtin.BigFrac{
 	Denominator: big.NewInt(100),
 }
 
+// Number of epochs after the slash epoch recorded for a deal whose sector was terminated at or after the
+// deal's end within which its provider may contest the slash.
+var DealSlashContestWindow = abi.ChainEpoch(builtin.EpochsInDay) // PARAM_SPEC
+
 // Minimum deal duration.
 var DealMinDuration = abi.ChainEpoch(180 * builtin.EpochsInDay) // PARAM_SPEC
 
 // Maximum deal duration
 var DealMaxDuration = abi.ChainEpoch(540 * builtin.EpochsInDay) // PARAM_SPEC
 
+// Maximum number of samples that may be drawn for audit in a single call.
+const DealAuditSamplesMax = 1024 // PARAM_SPEC
+
+// Maximum number of deals that may be terminated in a single call from a miner.
+// A miner terminating more deals at once splits them across calls.
+const MaxDealsTerminatedPerCall = 8192 // PARAM_SPEC
+
 // DealMaxLabelSize is the maximum size of a deal label.
 const DealMaxLabelSize = 256
 
+// Number of leading bytes of a deal label from which its label index key is computed.
+const DealLabelIndexPrefixSize = 64 // PARAM_SPEC
+
+// Maximum number of deals indexed under a single client and label prefix.
+// Only a deal's client may index it, and only under its own address, so this bounds the cost of a lookup
+// without letting other parties crowd out a client's entries.
+const MaxDealsPerLabelIndexKey = 16 // PARAM_SPEC
+
+// Maximum number of epochs for which a client's revocation of a deal proposal may be held.
+// A client may renew a revocation for a proposal that remains publishable for longer.
+var MaxProposalRevocationDuration = abi.ChainEpoch(30 * builtin.EpochsInDay) // PARAM_SPEC
+
+// Maximum number of unlapsed proposal revocations held for a single client.
+const MaxRevokedProposalsPerClient = 1024 // PARAM_SPEC
+
+// Whether verified deals may be published with no price and no collateral, as data onboarding deals
+// exempt from the minimum provider collateral.
+var DataOnboardingDealsEnabled = true // PARAM_SPEC
+
+// Whether new deals take IDs derived from their proposal CIDs (see DerivedDealID) rather than from
+// the sequential counter. Deals published under either scheme remain valid when it changes, as the
+// two ranges of IDs are disjoint, and republishing a pending proposal under derived IDs returns the
+// ID it already holds.
+var DealIDsFromProposalCID = false // PARAM_SPEC
+
+// Denominator of the share of retrieval requests a provider commits to serve in a deal's retrieval terms.
+const RetrievalAvailabilityDenominator = 10_000
+
+// Number of epochs over which the retrieval violations reported against a deal are counted.
+var RetrievalViolationPeriod = abi.ChainEpoch(builtin.EpochsInDay) // PARAM_SPEC
+
+// Number of retrievals a client is taken to request of a deal's piece in each violation period.
+// A provider committing to serve a share of retrievals may be reported for failing at most that share of them
+// (rounded up) in a period.
+var RetrievalChecksPerPeriod = uint64(10) // PARAM_SPEC
+
+// Number of epochs after a retrieval violation is reported within which the provider may dispute it.
+// An undisputed violation is penalized once this window has passed.
+var RetrievalDisputeWindow = abi.ChainEpoch(4 * builtin.EpochsInHour) // PARAM_SPEC
+
+// The maximum number of retrieval violations that may be reported against a deal in a violation period.
+func RetrievalViolationsPerPeriodMax(availability uint64) uint64 {
+	return (RetrievalChecksPerPeriod*availability + RetrievalAvailabilityDenominator - 1) / RetrievalAvailabilityDenominator
+}
+
 // Bounds (inclusive) on deal duration
 func DealDurationBounds(_ abi.PaddedPieceSize) (min abi.ChainEpoch, max abi.ChainEpoch) {
 	return DealMinDuration, DealMaxDuration
@@ -2,7 +2,9 @@ package market
 
 import (
 	"reflect"
+	"sort"
 
+	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	cid "github.com/ipfs/go-cid"
 	cbg "github.com/whyrusleeping/cbor-gen"
@@ -11,35 +13,39 @@ import (
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
 )
 
-type SetMultimap struct {
+// A two-level multimap of deal IDs, keyed first by epoch and then by provider.
+// Grouping each epoch's deals by provider lets the deals of different providers be processed independently,
+// so that one provider's large deal set doesn't hold up the others'.
+type ProviderSetMultimap struct {
 	mp            *adt.Map
 	store         adt.Store
 	innerBitwidth int
 }
 
-// Interprets a store as a HAMT-based map of HAMT-based sets with root `r`.
-// Both inner and outer HAMTs are interpreted with branching factor 2^bitwidth.
-func AsSetMultimap(s adt.Store, r cid.Cid, outerBitwidth, innerBitwidth int) (*SetMultimap, error) {
+// Interprets a store as a HAMT-based map, keyed by epoch, of HAMT-based maps, keyed by provider,
+// of HAMT-based sets with root `r`.
+// The outer HAMT is interpreted with branching factor 2^outerBitwidth, and the inner ones with 2^innerBitwidth.
+func AsProviderSetMultimap(s adt.Store, r cid.Cid, outerBitwidth, innerBitwidth int) (*ProviderSetMultimap, error) {
 	m, err := adt.AsMap(s, r, outerBitwidth)
 	if err != nil {
 		return nil, err
 	}
-	return &SetMultimap{mp: m, store: s, innerBitwidth: innerBitwidth}, nil
+	return &ProviderSetMultimap{mp: m, store: s, innerBitwidth: innerBitwidth}, nil
 }
 
 // Creates a new map backed by an empty HAMT and flushes it to the store.
-// Both inner and outer HAMTs have branching factor 2^bitwidth.
-func MakeEmptySetMultimap(s adt.Store, bitwidth int) (*SetMultimap, error) {
+// All HAMTs have branching factor 2^bitwidth.
+func MakeEmptyProviderSetMultimap(s adt.Store, bitwidth int) (*ProviderSetMultimap, error) {
 	m, err := adt.MakeEmptyMap(s, bitwidth)
 	if err != nil {
 		return nil, err
 	}
-	return &SetMultimap{mp: m, store: s, innerBitwidth: bitwidth}, nil
+	return &ProviderSetMultimap{mp: m, store: s, innerBitwidth: bitwidth}, nil
 }
 
 // Writes a new empty map to the store and returns its CID.
-func StoreEmptySetMultimap(s adt.Store, bitwidth int) (cid.Cid, error) {
-	mm, err := MakeEmptySetMultimap(s, bitwidth)
+func StoreEmptyProviderSetMultimap(s adt.Store, bitwidth int) (cid.Cid, error) {
+	mm, err := MakeEmptyProviderSetMultimap(s, bitwidth)
 	if err != nil {
 		return cid.Undef, err
 	}
@@ -47,14 +53,26 @@ func StoreEmptySetMultimap(s adt.Store, bitwidth int) (cid.Cid, error) {
 }
 
 // Returns the root cid of the underlying HAMT.
-func (mm *SetMultimap) Root() (cid.Cid, error) {
+func (mm *ProviderSetMultimap) Root() (cid.Cid, error) {
 	return mm.mp.Root()
 }
 
-func (mm *SetMultimap) Put(epoch abi.ChainEpoch, v abi.DealID) error {
-	// Load the hamt under key, or initialize a new empty one if not found.
-	k := abi.UIntKey(uint64(epoch))
-	set, found, err := mm.get(k)
+// Returns whether any set has been modified since the multimap was loaded or its root last computed.
+func (mm *ProviderSetMultimap) Modified() bool {
+	return mm.mp.Modified()
+}
+
+func (mm *ProviderSetMultimap) Put(epoch abi.ChainEpoch, provider addr.Address, v abi.DealID) error {
+	return mm.PutMany(epoch, provider, []abi.DealID{v})
+}
+
+func (mm *ProviderSetMultimap) PutMany(epoch abi.ChainEpoch, provider addr.Address, vs []abi.DealID) error {
+	// Load the provider map and set under the keys, or initialize new empty ones if not found.
+	providers, err := mm.getOrMakeProviders(epoch)
+	if err != nil {
+		return err
+	}
+	set, found, err := mm.getSet(providers, provider)
 	if err != nil {
 		return err
 	}
@@ -65,98 +83,198 @@ func (mm *SetMultimap) Put(epoch abi.ChainEpoch, v abi.DealID) error {
 		}
 	}
 
-	// Add to the set.
-	if err = set.Put(dealKey(v)); err != nil {
-		return xerrors.Errorf("failed to add key to set %v: %w", epoch, err)
+	for _, v := range vs {
+		if err = set.Put(dealKey(v)); err != nil {
+			return xerrors.Errorf("failed to add key to set %v/%v: %w", epoch, provider, err)
+		}
 	}
+	return mm.putSet(epoch, providers, provider, set)
+}
 
-	src, err := set.Root()
+// Removes values for an epoch and provider. The keys remain, even if no values do.
+func (mm *ProviderSetMultimap) RemoveMany(epoch abi.ChainEpoch, provider addr.Address, vs []abi.DealID) error {
+	providers, found, err := mm.getProviders(epoch)
 	if err != nil {
-		return xerrors.Errorf("failed to flush set root: %w", err)
+		return err
 	}
-	// Store the new set root under key.
-	newSetRoot := cbg.CborCid(src)
-	err = mm.mp.Put(k, &newSetRoot)
+	if !found {
+		return xerrors.Errorf("no providers for key %v", epoch)
+	}
+	set, found, err := mm.getSet(providers, provider)
 	if err != nil {
-		return xerrors.Errorf("failed to store set: %w", err)
+		return err
+	}
+	if !found {
+		return xerrors.Errorf("no set for key %v/%v", epoch, provider)
+	}
+
+	for _, v := range vs {
+		if err = set.Delete(dealKey(v)); err != nil {
+			return xerrors.Errorf("failed to remove key from set %v/%v: %w", epoch, provider, err)
+		}
+	}
+	return mm.putSet(epoch, providers, provider, set)
+}
+
+// Removes all values for an epoch.
+func (mm *ProviderSetMultimap) RemoveAll(key abi.ChainEpoch) error {
+	if _, err := mm.mp.TryDelete(abi.UIntKey(uint64(key))); err != nil {
+		return xerrors.Errorf("failed to delete set key %v: %w", key, err)
 	}
 	return nil
 }
 
-func (mm *SetMultimap) PutMany(epoch abi.ChainEpoch, vs []abi.DealID) error {
-	// Load the hamt under key, or initialize a new empty one if not found.
-	k := abi.UIntKey(uint64(epoch))
-	set, found, err := mm.get(k)
-	if err != nil {
+// Iterates the providers with entries for an epoch, iteration halts if the function returns an error.
+// Providers are visited in a deterministic order, which is not that of their addresses.
+func (mm *ProviderSetMultimap) ForEachProvider(epoch abi.ChainEpoch, fn func(provider addr.Address) error) error {
+	providers, found, err := mm.getProviders(epoch)
+	if err != nil || !found {
 		return err
 	}
-	if !found {
-		set, err = adt.MakeEmptySet(mm.store, mm.innerBitwidth)
+	var setRoot cbg.CborCid
+	return providers.ForEach(&setRoot, func(k string) error {
+		provider, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return xerrors.Errorf("invalid provider key %x: %w", k, err)
+		}
+		return fn(provider)
+	})
+}
+
+// Iterates all entries for an epoch and provider, iteration halts if the function returns an error.
+func (mm *ProviderSetMultimap) ForEachForProvider(epoch abi.ChainEpoch, provider addr.Address, fn func(id abi.DealID) error) error {
+	providers, found, err := mm.getProviders(epoch)
+	if err != nil || !found {
+		return err
+	}
+	set, found, err := mm.getSet(providers, provider)
+	if err != nil || !found {
+		return err
+	}
+	return set.ForEach(func(k string) error {
+		v, err := parseDealKey(k)
 		if err != nil {
 			return err
 		}
+		return fn(v)
+	})
+}
+
+// Returns whether a value is present for an epoch and provider.
+func (mm *ProviderSetMultimap) Has(epoch abi.ChainEpoch, provider addr.Address, v abi.DealID) (bool, error) {
+	providers, found, err := mm.getProviders(epoch)
+	if err != nil || !found {
+		return false, err
+	}
+	set, found, err := mm.getSet(providers, provider)
+	if err != nil || !found {
+		return false, err
 	}
+	return set.Has(dealKey(v))
+}
 
-	// Add to the set.
-	for _, v := range vs {
-		if err = set.Put(dealKey(v)); err != nil {
-			return xerrors.Errorf("failed to add key to set %v: %w", epoch, err)
+// Iterates all entries for an epoch, provider by provider, iteration halts if the function returns an error.
+func (mm *ProviderSetMultimap) ForEach(epoch abi.ChainEpoch, fn func(provider addr.Address, id abi.DealID) error) error {
+	return mm.ForEachProvider(epoch, func(provider addr.Address) error {
+		return mm.ForEachForProvider(epoch, provider, func(id abi.DealID) error {
+			return fn(provider, id)
+		})
+	})
+}
+
+// Iterates the entries for each key in the half-open epoch range [from, to), in ascending order of epoch
+// and then of deal ID. Each epoch in the range is looked up, so the cost is proportional to its length.
+// Iteration halts if the function returns an error.
+func (mm *ProviderSetMultimap) ForEachInRange(from, to abi.ChainEpoch, fn func(epoch abi.ChainEpoch, provider addr.Address, id abi.DealID) error) error {
+	if from < 0 || to < from {
+		return xerrors.Errorf("invalid epoch range [%d, %d)", from, to)
+	}
+	type entry struct {
+		provider addr.Address
+		id       abi.DealID
+	}
+	for epoch := from; epoch < to; epoch++ {
+		// Sets iterate in hash order, so collect and sort the entries in each epoch.
+		var entries []entry
+		if err := mm.ForEach(epoch, func(provider addr.Address, id abi.DealID) error {
+			entries = append(entries, entry{provider, id})
+			return nil
+		}); err != nil {
+			return err
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+		for _, e := range entries {
+			if err := fn(epoch, e.provider, e.id); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
 
-	src, err := set.Root()
+func (mm *ProviderSetMultimap) getProviders(epoch abi.ChainEpoch) (*adt.Map, bool, error) {
+	var providersRoot cbg.CborCid
+	found, err := mm.mp.Get(abi.UIntKey(uint64(epoch)), &providersRoot)
 	if err != nil {
-		return xerrors.Errorf("failed to flush set root: %w", err)
+		return nil, false, xerrors.Errorf("failed to load providers key: %v: %w", epoch, err)
 	}
-	// Store the new set root under key.
-	newSetRoot := cbg.CborCid(src)
-	err = mm.mp.Put(k, &newSetRoot)
+	if !found {
+		return nil, false, nil
+	}
+	providers, err := adt.AsMap(mm.store, cid.Cid(providersRoot), mm.innerBitwidth)
 	if err != nil {
-		return xerrors.Errorf("failed to store set: %w", err)
+		return nil, false, err
 	}
-	return nil
+	return providers, true, nil
 }
 
-// Removes all values for a key.
-func (mm *SetMultimap) RemoveAll(key abi.ChainEpoch) error {
-	if _, err := mm.mp.TryDelete(abi.UIntKey(uint64(key))); err != nil {
-		return xerrors.Errorf("failed to delete set key %v: %w", key, err)
+func (mm *ProviderSetMultimap) getOrMakeProviders(epoch abi.ChainEpoch) (*adt.Map, error) {
+	providers, found, err := mm.getProviders(epoch)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	if !found {
+		return adt.MakeEmptyMap(mm.store, mm.innerBitwidth)
+	}
+	return providers, nil
 }
 
-// Iterates all entries for a key, iteration halts if the function returns an error.
-func (mm *SetMultimap) ForEach(epoch abi.ChainEpoch, fn func(id abi.DealID) error) error {
-	set, found, err := mm.get(abi.UIntKey(uint64(epoch)))
+func (mm *ProviderSetMultimap) getSet(providers *adt.Map, provider addr.Address) (*adt.Set, bool, error) {
+	var setRoot cbg.CborCid
+	found, err := providers.Get(abi.AddrKey(provider), &setRoot)
 	if err != nil {
-		return err
+		return nil, false, xerrors.Errorf("failed to load set key: %v: %w", provider, err)
 	}
-	if found {
-		return set.ForEach(func(k string) error {
-			v, err := parseDealKey(k)
-			if err != nil {
-				return err
-			}
-			return fn(v)
-		})
+	if !found {
+		return nil, false, nil
 	}
-	return nil
+	set, err := adt.AsSet(mm.store, cid.Cid(setRoot), mm.innerBitwidth)
+	if err != nil {
+		return nil, false, err
+	}
+	return set, true, nil
 }
 
-func (mm *SetMultimap) get(key abi.Keyer) (*adt.Set, bool, error) {
-	var setRoot cbg.CborCid
-	found, err := mm.mp.Get(key, &setRoot)
+// Stores a provider's set in the epoch's provider map, and the provider map under the epoch.
+func (mm *ProviderSetMultimap) putSet(epoch abi.ChainEpoch, providers *adt.Map, provider addr.Address, set *adt.Set) error {
+	src, err := set.Root()
 	if err != nil {
-		return nil, false, xerrors.Errorf("failed to load set key: %v: %w", key, err)
+		return xerrors.Errorf("failed to flush set root: %w", err)
 	}
-	var set *adt.Set
-	if found {
-		set, err = adt.AsSet(mm.store, cid.Cid(setRoot), mm.innerBitwidth)
-		if err != nil {
-			return nil, false, err
-		}
+	newSetRoot := cbg.CborCid(src)
+	if err = providers.Put(abi.AddrKey(provider), &newSetRoot); err != nil {
+		return xerrors.Errorf("failed to store set: %w", err)
 	}
-	return set, found, nil
+
+	prc, err := providers.Root()
+	if err != nil {
+		return xerrors.Errorf("failed to flush providers root: %w", err)
+	}
+	newProvidersRoot := cbg.CborCid(prc)
+	if err = mm.mp.Put(abi.UIntKey(uint64(epoch)), &newProvidersRoot); err != nil {
+		return xerrors.Errorf("failed to store providers: %w", err)
+	}
+	return nil
 }
 
 func dealKey(e abi.DealID) abi.Keyer {
@@ -0,0 +1,27 @@
+package market
+
+import (
+	"bytes"
+
+	"github.com/filecoin-project/go-state-types/cbor"
+	"golang.org/x/xerrors"
+)
+
+// Prefixes of the payloads that deal parties sign to authorize market operations. They separate the domain of
+// each operation's signatures, so that a signature made for one cannot be presented for another.
+const (
+	SigningDomainRetrievalViolation = "fil-market-retrieval-violation:"
+	SigningDomainRetrievalReceipt   = "fil-market-retrieval-receipt:"
+	SigningDomainDealAmendment      = "fil-market-deal-amendment:"
+	SigningDomainDealClientTransfer = "fil-market-deal-client-transfer:"
+)
+
+// Returns the bytes signed to authorize a payload in a signing domain: the domain's prefix followed by the
+// payload's CBOR encoding.
+func SigningBytes(domain string, payload cbor.Marshaler) ([]byte, error) {
+	buf := bytes.NewBufferString(domain)
+	if err := payload.MarshalCBOR(buf); err != nil {
+		return nil, xerrors.Errorf("failed to marshal payload for signing: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -9,6 +9,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 
@@ -23,6 +24,7 @@ type DealSummary struct {
 	SectorStartEpoch abi.ChainEpoch
 	LastUpdatedEpoch abi.ChainEpoch
 	SlashEpoch       abi.ChainEpoch
+	SectorNumber     abi.SectorNumber
 }
 
 type StateSummary struct {
@@ -57,6 +59,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 	proposalCids := make(map[cid.Cid]struct{})
 	maxDealID := int64(-1)
 	proposalStats := make(map[abi.DealID]*DealSummary)
+	proposalPieceSizes := make(map[abi.DealID]abi.PaddedPieceSize)
 	expectedDealOps := make(map[abi.DealID]struct{})
 	totalProposalCollateral := abi.NewTokenAmount(0)
 
@@ -76,7 +79,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 
 			// keep some state
 			proposalCids[pcid] = struct{}{}
-			if dealID > maxDealID {
+			if dealID > maxDealID && !IsDerivedDealID(abi.DealID(dealID)) {
 				maxDealID = dealID
 			}
 			proposalStats[abi.DealID(dealID)] = &DealSummary{
@@ -86,8 +89,10 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 				SectorStartEpoch: abi.ChainEpoch(-1),
 				LastUpdatedEpoch: abi.ChainEpoch(-1),
 				SlashEpoch:       abi.ChainEpoch(-1),
+				SectorNumber:     DealSectorNumberUnknown,
 			}
 
+			proposalPieceSizes[abi.DealID(dealID)] = proposal.PieceSize
 			totalProposalCollateral = big.Sum(totalProposalCollateral, proposal.ClientCollateral, proposal.ProviderCollateral)
 
 			acc.Require(proposal.Client.Protocol() == address.ID, "client address for deal %d is not an ID address", dealID)
@@ -97,7 +102,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 		acc.RequireNoError(err, "error iterating proposals")
 	}
 
-	// next id should be higher than any existing deal
+	// next id should be higher than any existing deal allocated from the counter
 	acc.Require(int64(st.NextID) > maxDealID, "next id, %d, is not greater than highest id in proposals, %d", st.NextID, maxDealID)
 
 	//
@@ -137,6 +142,10 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 				stats.SectorStartEpoch = dealState.SectorStartEpoch
 				stats.LastUpdatedEpoch = dealState.LastUpdatedEpoch
 				stats.SlashEpoch = dealState.SlashEpoch
+				stats.SectorNumber = dealState.SectorNumber
+
+				acc.Require(dealState.SlashEpoch <= stats.EndEpoch,
+					"deal %d state slashed after deal end %d: %v", dealID, stats.EndEpoch, dealState)
 			}
 
 			dealStateCount++
@@ -206,6 +215,18 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 			"locked total, %s, does not sum to provider locked, %s, client locked, %s, and client storage fee, %s",
 			lockedTotal, st.TotalProviderLockedCollateral, st.TotalClientLockedCollateral, st.TotalClientStorageFee)
 
+		// recorded totals should not have drifted from the funds locked for deals
+		if computed, err := st.ComputeLockedTotals(store); err != nil {
+			acc.Addf("error computing locked totals: %v", err)
+		} else {
+			acc.Require(computed.ClientCollateral.Equals(st.TotalClientLockedCollateral),
+				"total client locked collateral, %s, does not match deals, %s", st.TotalClientLockedCollateral, computed.ClientCollateral)
+			acc.Require(computed.ProviderCollateral.Equals(st.TotalProviderLockedCollateral),
+				"total provider locked collateral, %s, does not match deals, %s", st.TotalProviderLockedCollateral, computed.ProviderCollateral)
+			acc.Require(computed.ClientStorageFee.Equals(st.TotalClientStorageFee),
+				"total client storage fee, %s, does not match deals, %s", st.TotalClientStorageFee, computed.ClientStorageFee)
+		}
+
 		// assert escrow <= actor balance
 		// lockTable item <= escrow item and escrowTotal <= balance implies lockTable total <= balance
 		escrowTotal, err := escrowTable.Total()
@@ -220,7 +241,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 
 	dealOpEpochCount := uint64(0)
 	dealOpCount := uint64(0)
-	if dealOps, err := AsSetMultimap(store, st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth); err != nil {
+	if dealOps, err := AsProviderSetMultimap(store, st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth); err != nil {
 		acc.Addf("error loading deal ops: %v", err)
 	} else {
 		// get into internals just to iterate through full data structure
@@ -232,9 +253,13 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 			}
 
 			dealOpEpochCount++
-			return dealOps.ForEach(abi.ChainEpoch(epoch), func(id abi.DealID) error {
-				_, found := proposalStats[id]
+			return dealOps.ForEach(abi.ChainEpoch(epoch), func(provider address.Address, id abi.DealID) error {
+				stats, found := proposalStats[id]
 				acc.Require(found, "deal op found for deal id %d with missing proposal at epoch %d", id, epoch)
+				if found {
+					acc.Require(stats.Provider == provider, "deal op for deal id %d at epoch %d under provider %v, deal provider is %v",
+						id, epoch, provider, stats.Provider)
+				}
 				delete(expectedDealOps, id)
 				dealOpCount++
 				return nil
@@ -245,6 +270,267 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 
 	acc.Require(len(expectedDealOps) == 0, "missing deal ops for proposals: %v", expectedDealOps)
 
+	//
+	// Provider Asks
+	//
+
+	if asks, err := adt.AsMap(store, st.ProviderAsks, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading provider asks: %v", err)
+	} else {
+		var ask ProviderAsk
+		err = asks.ForEach(&ask, func(key string) error {
+			provider, err := address.NewFromBytes([]byte(key))
+			if err != nil {
+				return err
+			}
+			acc.Require(ask.MinPieceSize <= ask.MaxPieceSize, "provider %v ask min piece size %d exceeds max %d",
+				provider, ask.MinPieceSize, ask.MaxPieceSize)
+			acc.Require(ask.MinPricePerEpoch.GreaterThanEqual(big.Zero()), "provider %v ask has negative min price %v",
+				provider, ask.MinPricePerEpoch)
+			acc.Require(ask.MaxProviderCollateral.GreaterThanEqual(big.Zero()), "provider %v ask has negative max collateral %v",
+				provider, ask.MaxProviderCollateral)
+			acc.Require(ask.ProviderCollateralCommitted.GreaterThanEqual(big.Zero()), "provider %v ask has negative committed collateral %v",
+				provider, ask.ProviderCollateralCommitted)
+			acc.Require(ask.ProviderCollateralCommitted.LessThanEqual(ask.MaxTotalProviderCollateral),
+				"provider %v ask committed collateral %v exceeds total max %v", provider, ask.ProviderCollateralCommitted, ask.MaxTotalProviderCollateral)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating provider asks")
+	}
+
+	//
+	// Revoked Proposals
+	//
+
+	if revoked, err := adt.AsMap(store, st.RevokedProposals, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading revoked proposals: %v", err)
+	} else {
+		var root cbg.CborCid
+		err = revoked.ForEach(&root, func(key string) error {
+			client, err := address.NewFromBytes([]byte(key))
+			if err != nil {
+				return err
+			}
+			acc.Require(client.Protocol() == address.ID, "revoked proposals client %v is not an ID address", client)
+
+			revocations, err := adt.AsMap(store, cid.Cid(root), builtin.DefaultHamtBitwidth)
+			if err != nil {
+				return err
+			}
+			keys, err := revocations.CollectKeys()
+			if err != nil {
+				return err
+			}
+			acc.Require(len(keys) > 0, "client %v has an empty revocation map", client)
+			acc.Require(len(keys) <= MaxRevokedProposalsPerClient, "client %v has %d revocations, more than maximum %d",
+				client, len(keys), MaxRevokedProposalsPerClient)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating revoked proposals")
+	}
+
+	//
+	// Superseded Proposals
+	//
+
+	if superseded, err := adt.AsMap(store, st.SupersededProposals, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading superseded proposals: %v", err)
+	} else {
+		var root cbg.CborCid
+		err = superseded.ForEach(&root, func(key string) error {
+			client, err := address.NewFromBytes([]byte(key))
+			if err != nil {
+				return err
+			}
+			acc.Require(client.Protocol() == address.ID, "superseded proposals client %v is not an ID address", client)
+
+			proposals, err := adt.AsMap(store, cid.Cid(root), builtin.DefaultHamtBitwidth)
+			if err != nil {
+				return err
+			}
+			keys, err := proposals.CollectKeys()
+			if err != nil {
+				return err
+			}
+			acc.Require(len(keys) > 0, "client %v has an empty superseded proposal map", client)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating superseded proposals")
+	}
+
+	//
+	// Active Deals
+	//
+
+	activeDealIndexes := make(map[abi.DealID]uint64)
+	if activeDeals, err := adt.AsArray(store, st.ActiveDeals, ActiveDealsAmtBitwidth); err != nil {
+		acc.Addf("error loading active deals: %v", err)
+	} else {
+		var dealID cbg.CborInt
+		err = activeDeals.ForEach(&dealID, func(index int64) error {
+			acc.Require(uint64(index) < activeDeals.Length(), "active deal %d at index %d beyond length %d", dealID, index, activeDeals.Length())
+			_, duplicate := activeDealIndexes[abi.DealID(dealID)]
+			acc.Require(!duplicate, "deal %d indexed as active more than once", dealID)
+			activeDealIndexes[abi.DealID(dealID)] = uint64(index)
+
+			stats, found := proposalStats[abi.DealID(dealID)]
+			acc.Require(found && stats.SectorStartEpoch != epochUndefined, "active deal %d has no deal state", dealID)
+			pieceSize := proposalPieceSizes[abi.DealID(dealID)]
+			acc.Require(pieceSize <= st.ActiveDealsMaxPieceSize, "active deal %d piece size %d exceeds max %d", dealID, pieceSize, st.ActiveDealsMaxPieceSize)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating active deals")
+		acc.Require(uint64(len(activeDealIndexes)) == activeDeals.Length(), "active deals length %d does not match %d deals indexed", activeDeals.Length(), len(activeDealIndexes))
+	}
+	for dealID, stats := range proposalStats {
+		if stats.SectorStartEpoch != epochUndefined {
+			_, found := activeDealIndexes[dealID]
+			acc.Require(found, "deal %d with state is not indexed as active", dealID)
+		}
+	}
+
+	if indexes, err := adt.AsMap(store, st.ActiveDealIndexes, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading active deal indexes: %v", err)
+	} else {
+		var index cbg.CborInt
+		count := 0
+		err = indexes.ForEach(&index, func(key string) error {
+			dealID, err := abi.ParseUIntKey(key)
+			if err != nil {
+				return err
+			}
+			expected, found := activeDealIndexes[abi.DealID(dealID)]
+			acc.Require(found && expected == uint64(index), "active deal %d recorded at index %d, indexed at %d", dealID, index, expected)
+			count++
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating active deal indexes")
+		acc.Require(count == len(activeDealIndexes), "%d active deal indexes recorded for %d active deals", count, len(activeDealIndexes))
+	}
+
+	//
+	// Client Proposals
+	//
+
+	if clientProposals, err := adt.AsMap(store, st.ClientProposals, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading client proposals: %v", err)
+	} else {
+		var proposal DealProposal
+		err = clientProposals.ForEach(&proposal, func(key string) error {
+			keyCid, err := cid.Cast([]byte(key))
+			if err != nil {
+				return err
+			}
+			pcid, err := proposal.Cid()
+			if err != nil {
+				return err
+			}
+			acc.Require(keyCid.Equals(pcid), "client proposal keyed by %s has CID %s", keyCid, pcid)
+			acc.Require(proposal.Client.Protocol() == address.ID, "client proposal %s client %v is not an ID address", pcid, proposal.Client)
+			acc.Require(proposal.Provider.Protocol() == address.ID, "client proposal %s provider %v is not an ID address", pcid, proposal.Provider)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating client proposals")
+	}
+
+	//
+	// Label Index
+	//
+
+	if labels, err := adt.AsMap(store, st.LabelIndex, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading label index: %v", err)
+	} else {
+		var deals bitfield.BitField
+		err = labels.ForEach(&deals, func(key string) error {
+			count, err := deals.Count()
+			if err != nil {
+				return err
+			}
+			acc.Require(count > 0, "label index key %x has no deals", key)
+			acc.Require(count <= MaxDealsPerLabelIndexKey, "label index key %x has %d deals, more than maximum %d",
+				key, count, MaxDealsPerLabelIndexKey)
+			return deals.ForEach(func(id uint64) error {
+				acc.Require(IsDerivedDealID(abi.DealID(id)) || abi.DealID(id) < st.NextID, "label index key %x has deal %d not yet allocated", key, id)
+				return nil
+			})
+		})
+		acc.RequireNoError(err, "error iterating label index")
+	}
+
+	//
+	// Escrow Funders
+	//
+
+	if funders, err := adt.AsMap(store, st.EscrowFunders, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading escrow funders: %v", err)
+	} else {
+		var funder EscrowFunder
+		err = funders.ForEach(&funder, func(key string) error {
+			client, err := address.NewFromBytes([]byte(key))
+			if err != nil {
+				return err
+			}
+			acc.Require(client.Protocol() == address.ID, "escrow funder client %v is not an ID address", client)
+			acc.Require(funder.Funder.Protocol() == address.ID, "client %v escrow funder %v is not an ID address", client, funder.Funder)
+			acc.Require(funder.MaxPerEpoch.GreaterThan(big.Zero()), "client %v escrow funder has non-positive max per epoch %v",
+				client, funder.MaxPerEpoch)
+			acc.Require(funder.RequestedInEpoch.GreaterThanEqual(big.Zero()), "client %v escrow funder has negative amount requested %v",
+				client, funder.RequestedInEpoch)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating escrow funders")
+	}
+
+	//
+	// Deal Allocations
+	//
+
+	if allocations, err := adt.AsMap(store, st.DealAllocations, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading deal allocations: %v", err)
+	} else {
+		allocationDeals := make(map[int64]abi.DealID)
+		var allocationID cbg.CborInt
+		err = allocations.ForEach(&allocationID, func(key string) error {
+			dealID, err := abi.ParseUIntKey(key)
+			if err != nil {
+				return err
+			}
+			deal, found := proposalStats[abi.DealID(dealID)]
+			acc.Require(found, "allocation %d for deal %d with no proposal", allocationID, dealID)
+			acc.Require(!found || deal.SectorStartEpoch == epochUndefined, "allocation %d for activated deal %d", allocationID, dealID)
+			other, duplicate := allocationDeals[int64(allocationID)]
+			acc.Require(!duplicate, "allocation %d for both deal %d and deal %d", allocationID, other, dealID)
+			allocationDeals[int64(allocationID)] = abi.DealID(dealID)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating deal allocations")
+	}
+
+	//
+	// Retrieval Violations
+	//
+
+	if retrievalViolations, err := adt.AsMap(store, st.RetrievalViolations, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading retrieval violations: %v", err)
+	} else {
+		var violations RetrievalViolations
+		err = retrievalViolations.ForEach(&violations, func(key string) error {
+			dealID, err := abi.ParseUIntKey(key)
+			if err != nil {
+				return err
+			}
+			deal, found := proposalStats[abi.DealID(dealID)]
+			acc.Require(found, "retrieval violations of deal %d with no proposal", dealID)
+			acc.Require(!found || deal.SectorStartEpoch != epochUndefined, "retrieval violations of inactive deal %d", dealID)
+			acc.Require(!found || violations.LastEpoch >= deal.SectorStartEpoch, "deal %d violation at %d before activation at %d",
+				dealID, violations.LastEpoch, deal.SectorStartEpoch)
+			acc.Require(violations.PendingReportEpoch == epochUndefined || violations.PendingReportEpoch >= violations.PeriodStart,
+				"deal %d violation pending from %d before its period start %d", dealID, violations.PendingReportEpoch, violations.PeriodStart)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating retrieval violations")
+	}
+
 	return &StateSummary{
 		Deals:                proposalStats,
 		PendingProposalCount: pendingProposalCount,
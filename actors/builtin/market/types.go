@@ -1,8 +1,12 @@
 package market
 
 import (
+	"sort"
+
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/cbor"
 	. "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"golang.org/x/xerrors"
 
 	"github.com/ipfs/go-cid"
 )
@@ -42,6 +46,28 @@ func (t *DealArray) Delete(id abi.DealID) error {
 	return t.Array.Delete(uint64(id))
 }
 
+// Iterates all proposals in ascending order of deal ID.
+// Each call receives a distinct proposal, which the function may retain.
+// Iteration halts if the function returns an error.
+func (t *DealArray) ForEachProposal(fn func(id abi.DealID, proposal *DealProposal) error) error {
+	var proposal DealProposal
+	return t.Array.ForEach(&proposal, func(i int64) error {
+		p := proposal
+		return fn(abi.DealID(i), &p)
+	})
+}
+
+// Iterates the proposals with deal IDs in the half-open range [start, end), in ascending order of deal ID.
+// Each call receives a distinct proposal, which the function may retain.
+// Iteration halts if the function returns an error.
+func (t *DealArray) ForEachProposalInRange(start, end abi.DealID, fn func(id abi.DealID, proposal *DealProposal) error) error {
+	var proposal DealProposal
+	return forEachInRange(t.Array, start, end, &proposal, func(id abi.DealID) error {
+		p := proposal
+		return fn(id, &p)
+	})
+}
+
 // A specialization of a array to deals.
 // It is an error to query for a key that doesn't exist.
 type DealMetaArray struct {
@@ -49,9 +75,10 @@ type DealMetaArray struct {
 }
 
 type DealState struct {
-	SectorStartEpoch abi.ChainEpoch // -1 if not yet included in proven sector
-	LastUpdatedEpoch abi.ChainEpoch // -1 if deal state never updated
-	SlashEpoch       abi.ChainEpoch // -1 if deal never slashed
+	SectorStartEpoch abi.ChainEpoch   // -1 if not yet included in proven sector
+	LastUpdatedEpoch abi.ChainEpoch   // -1 if deal state never updated
+	SlashEpoch       abi.ChainEpoch   // -1 if deal never slashed
+	SectorNumber     abi.SectorNumber // DealSectorNumberUnknown if activated before sectors were recorded
 }
 
 // Interprets a store as balance table with root `r`.
@@ -81,6 +108,7 @@ func (t *DealMetaArray) Get(id abi.DealID) (*DealState, bool, error) {
 			SectorStartEpoch: epochUndefined,
 			LastUpdatedEpoch: epochUndefined,
 			SlashEpoch:       epochUndefined,
+			SectorNumber:     DealSectorNumberUnknown,
 		}, false, nil
 	}
 	return &value, true, nil
@@ -90,6 +118,65 @@ func (t *DealMetaArray) Set(k abi.DealID, value *DealState) error {
 	return t.Array.Set(uint64(k), value)
 }
 
+// Sets the states of many deals, in ascending order of deal ID so that writes to the same AMT nodes
+// are adjacent.
+func (t *DealMetaArray) SetMany(states map[abi.DealID]*DealState) error {
+	ids := make([]abi.DealID, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if err := t.Array.Set(uint64(id), states[id]); err != nil {
+			return xerrors.Errorf("failed to set deal state %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
 func (t *DealMetaArray) Delete(id abi.DealID) error {
 	return t.Array.Delete(uint64(id))
 }
+
+// Iterates the states of all activated deals in ascending order of deal ID.
+// Each call receives a distinct state, which the function may retain.
+// Iteration halts if the function returns an error.
+func (t *DealMetaArray) ForEachState(fn func(id abi.DealID, state *DealState) error) error {
+	var state DealState
+	return t.Array.ForEach(&state, func(i int64) error {
+		s := state
+		return fn(abi.DealID(i), &s)
+	})
+}
+
+// Iterates the states of activated deals with deal IDs in the half-open range [start, end),
+// in ascending order of deal ID.
+// Each call receives a distinct state, which the function may retain.
+// Iteration halts if the function returns an error.
+func (t *DealMetaArray) ForEachStateInRange(start, end abi.DealID, fn func(id abi.DealID, state *DealState) error) error {
+	var state DealState
+	return forEachInRange(t.Array, start, end, &state, func(id abi.DealID) error {
+		s := state
+		return fn(id, &s)
+	})
+}
+
+// Iterates the entries of an array with keys in [start, end), skipping the entries before start
+// rather than visiting and discarding them.
+func forEachInRange(arr *Array, start, end abi.DealID, out cbor.Unmarshaler, fn func(id abi.DealID) error) error {
+	if end < start {
+		return xerrors.Errorf("invalid deal ID range [%d, %d)", start, end)
+	}
+	stopErr := xerrors.New("stop")
+	err := arr.ForEachFrom(uint64(start), out, func(i int64) error {
+		id := abi.DealID(i)
+		if id >= end {
+			return stopErr
+		}
+		return fn(id)
+	})
+	if err == stopErr {
+		return nil
+	}
+	return err
+}
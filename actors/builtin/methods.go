@@ -10,6 +10,11 @@ const (
 	MethodConstructor = builtin0.MethodConstructor
 )
 
+// The first of the method numbers that built-in actors invoke on actors that are not built in, to call back
+// or notify them. The range lies beyond the methods of every built-in actor, so that such a call can't invoke
+// a built-in actor's method.
+const MethodsExternalStart = abi.MethodNum(1000)
+
 var MethodsAccount = struct {
 	Constructor   abi.MethodNum
 	PubkeyAddress abi.MethodNum
@@ -30,7 +35,9 @@ var MethodsReward = struct {
 	AwardBlockReward abi.MethodNum
 	ThisEpochReward  abi.MethodNum
 	UpdateNetworkKPI abi.MethodNum
-}{MethodConstructor, 2, 3, 4}
+	PauseMinting     abi.MethodNum
+	ResumeMinting    abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6}
 
 var MethodsMultisig = struct {
 	Constructor                 abi.MethodNum
@@ -52,16 +59,44 @@ var MethodsPaych = struct {
 }{MethodConstructor, 2, 3, 4}
 
 var MethodsMarket = struct {
-	Constructor              abi.MethodNum
-	AddBalance               abi.MethodNum
-	WithdrawBalance          abi.MethodNum
-	PublishStorageDeals      abi.MethodNum
-	VerifyDealsForActivation abi.MethodNum
-	ActivateDeals            abi.MethodNum
-	OnMinerSectorsTerminate  abi.MethodNum
-	ComputeDataCommitment    abi.MethodNum
-	CronTick                 abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9}
+	Constructor                   abi.MethodNum
+	AddBalance                    abi.MethodNum
+	WithdrawBalance               abi.MethodNum
+	PublishStorageDeals           abi.MethodNum
+	VerifyDealsForActivation      abi.MethodNum
+	ActivateDeals                 abi.MethodNum
+	OnMinerSectorsTerminate       abi.MethodNum
+	ComputeDataCommitment         abi.MethodNum
+	CronTick                      abi.MethodNum
+	PostProviderAsk               abi.MethodNum
+	WithdrawProviderAsk           abi.MethodNum
+	RevokeDealProposal            abi.MethodNum
+	IndexDealLabels               abi.MethodNum
+	LookupDealsByLabel            abi.MethodNum
+	VerifyPieceInclusion          abi.MethodNum
+	AuthorizeEscrowFunder         abi.MethodNum
+	FundClientEscrow              abi.MethodNum
+	RepairLockedTotals            abi.MethodNum
+	PublishStorageDealsAggregated abi.MethodNum
+	SettleDealPayments            abi.MethodNum
+	SampleDealsForAudit           abi.MethodNum
+	TerminateBreachedDeal         abi.MethodNum
+	GetBalance                    abi.MethodNum
+	GetDealStatus                 abi.MethodNum
+	PublishStorageDealsFromClient abi.MethodNum
+	AcceptDealProposals           abi.MethodNum
+	AmendDealProposal             abi.MethodNum
+	GetDealActivation             abi.MethodNum
+	TransferDealClient            abi.MethodNum
+	AddProviderCollateral         abi.MethodNum
+	ReportRetrievalViolation      abi.MethodNum
+	BatchActivateDeals            abi.MethodNum
+	GetDealUpdateEpoch            abi.MethodNum
+	WithdrawDealProposals         abi.MethodNum
+	OnMinerDealsReplaced          abi.MethodNum
+	DisputeRetrievalViolation     abi.MethodNum
+	ContestDealSlash              abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 36, 37}
 
 var MethodsPower = struct {
 	Constructor              abi.MethodNum
@@ -73,44 +108,98 @@ var MethodsPower = struct {
 	Deprecated1              abi.MethodNum
 	SubmitPoRepForBulkVerify abi.MethodNum
 	CurrentTotalPower        abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9}
+	RecordProvingPeriod      abi.MethodNum
+	MinerFaultStatus         abi.MethodNum
+	NetworkVersion           abi.MethodNum
+	ReleaseCronQuarantine    abi.MethodNum
+	CronQuarantinedMiners    abi.MethodNum
+	UpdateClaimedProofType   abi.MethodNum
+	PowerCheckpoint          abi.MethodNum
+	CurrentTotalPowerBrief   abi.MethodNum
+	NetworkFaultRate         abi.MethodNum
+	MinerRawPower            abi.MethodNum
+	MinerCount               abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
 
 var MethodsMiner = struct {
-	Constructor              abi.MethodNum
-	ControlAddresses         abi.MethodNum
-	ChangeWorkerAddress      abi.MethodNum
-	ChangePeerID             abi.MethodNum
-	SubmitWindowedPoSt       abi.MethodNum
-	PreCommitSector          abi.MethodNum
-	ProveCommitSector        abi.MethodNum
-	ExtendSectorExpiration   abi.MethodNum
-	TerminateSectors         abi.MethodNum
-	DeclareFaults            abi.MethodNum
-	DeclareFaultsRecovered   abi.MethodNum
-	OnDeferredCronEvent      abi.MethodNum
-	CheckSectorProven        abi.MethodNum
-	ApplyRewards             abi.MethodNum
-	ReportConsensusFault     abi.MethodNum
-	WithdrawBalance          abi.MethodNum
-	ConfirmSectorProofsValid abi.MethodNum
-	ChangeMultiaddrs         abi.MethodNum
-	CompactPartitions        abi.MethodNum
-	CompactSectorNumbers     abi.MethodNum
-	ConfirmUpdateWorkerKey   abi.MethodNum
-	RepayDebt                abi.MethodNum
-	ChangeOwnerAddress       abi.MethodNum
-	DisputeWindowedPoSt      abi.MethodNum
-	PreCommitSectorBatch     abi.MethodNum
-	ProveCommitAggregate     abi.MethodNum
-	ProveReplicaUpdates      abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27}
+	Constructor                 abi.MethodNum
+	ControlAddresses            abi.MethodNum
+	ChangeWorkerAddress         abi.MethodNum
+	ChangePeerID                abi.MethodNum
+	SubmitWindowedPoSt          abi.MethodNum
+	PreCommitSector             abi.MethodNum
+	ProveCommitSector           abi.MethodNum
+	ExtendSectorExpiration      abi.MethodNum
+	TerminateSectors            abi.MethodNum
+	DeclareFaults               abi.MethodNum
+	DeclareFaultsRecovered      abi.MethodNum
+	OnDeferredCronEvent         abi.MethodNum
+	CheckSectorProven           abi.MethodNum
+	ApplyRewards                abi.MethodNum
+	ReportConsensusFault        abi.MethodNum
+	WithdrawBalance             abi.MethodNum
+	ConfirmSectorProofsValid    abi.MethodNum
+	ChangeMultiaddrs            abi.MethodNum
+	CompactPartitions           abi.MethodNum
+	CompactSectorNumbers        abi.MethodNum
+	ConfirmUpdateWorkerKey      abi.MethodNum
+	RepayDebt                   abi.MethodNum
+	ChangeOwnerAddress          abi.MethodNum
+	DisputeWindowedPoSt         abi.MethodNum
+	PreCommitSectorBatch        abi.MethodNum
+	ProveCommitAggregate        abi.MethodNum
+	ProveReplicaUpdates         abi.MethodNum
+	TerminatedSectorCounts      abi.MethodNum
+	ChangeOwnerSettings         abi.MethodNum
+	PartitionExpirations        abi.MethodNum
+	SubmitWindowedPoStRelayed   abi.MethodNum
+	DeclareFaultsAndRecoveries  abi.MethodNum
+	SectorManifest              abi.MethodNum
+	GetVestingFunds             abi.MethodNum
+	ChangeBeneficiary           abi.MethodNum
+	GetBeneficiary              abi.MethodNum
+	ExtendSectorExpiration2     abi.MethodNum
+	ProveCommitSectorsNI        abi.MethodNum
+	ChangeWindowPoStProofType   abi.MethodNum
+	CleanUpExpiredPreCommits    abi.MethodNum
+	MovePartitions              abi.MethodNum
+	DeclareMaintenanceWindow    abi.MethodNum
+	ProveReplicaUpdates2        abi.MethodNum
+	EstimateDeadlinePenalty     abi.MethodNum
+	SubmitWindowedPoStAggregate abi.MethodNum
+	CancelWorkerChange          abi.MethodNum
+	SetNotificationReceiver     abi.MethodNum
+	DeadlineSectorCounts        abi.MethodNum
+	RebalanceSectors            abi.MethodNum
+	FindSector                  abi.MethodNum
+	DroppedCronEvents           abi.MethodNum
+	TerminateBreachedSector     abi.MethodNum
+	RollbackReplicaUpdates      abi.MethodNum
+	EstimateAggregateFees       abi.MethodNum
+	GetControlChangeHistory     abi.MethodNum
+	SetExpirationReminder       abi.MethodNum
+	PreCommitSectorBatch2       abi.MethodNum
+	ProveCommitSector2          abi.MethodNum
+	TerminateSectors2           abi.MethodNum
+	DeclareFaultsRecovered2     abi.MethodNum
+	CompactPartitions2          abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 36, 37, 38, 39, 40, 41, 42, 43, 44, 45, 46, 47, 48, 49, 50, 51, 52, 53, 54, 55, 56, 57, 58, 59, 60, 61}
 
 var MethodsVerifiedRegistry = struct {
 	Constructor                 abi.MethodNum
 	AddVerifier                 abi.MethodNum
 	RemoveVerifier              abi.MethodNum
 	AddVerifiedClient           abi.MethodNum
-	UseBytes                    abi.MethodNum
+	Deprecated1                 abi.MethodNum
 	RestoreBytes                abi.MethodNum
 	RemoveVerifiedClientDataCap abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7}
+	RecordActivatedBytes        abi.MethodNum
+	DataCapUsage                abi.MethodNum
+	RestoreBytesBatch           abi.MethodNum
+	VerifySectorClaims          abi.MethodNum
+	CreateAllocation            abi.MethodNum
+	ClaimAllocations            abi.MethodNum
+	RemoveExpiredAllocations    abi.MethodNum
+	RemoveSectorClaims          abi.MethodNum
+	GetClientAllocations        abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
@@ -0,0 +1,45 @@
+package miner
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+)
+
+// The terms on which a beneficiary other than the owner receives the funds withdrawn from the miner.
+// The owner, as beneficiary, is not limited by a term.
+type BeneficiaryTerm struct {
+	// Total amount that may be withdrawn to the beneficiary.
+	Quota abi.TokenAmount
+	// Amount withdrawn to the beneficiary so far.
+	UsedQuota abi.TokenAmount
+	// Epoch at which the term expires, after which nothing more may be withdrawn to the beneficiary.
+	Expiration abi.ChainEpoch
+}
+
+// Returns the amount that may yet be withdrawn under the term.
+func (t *BeneficiaryTerm) Available(currEpoch abi.ChainEpoch) abi.TokenAmount {
+	if currEpoch >= t.Expiration {
+		return big.Zero()
+	}
+	return big.Max(big.Sub(t.Quota, t.UsedQuota), big.Zero())
+}
+
+// A change of beneficiary proposed by the owner.
+// The change takes effect once approved by both the current beneficiary and the nominee. The approval of
+// a current beneficiary with nothing left to withdraw is implied, as is that of the owner as nominee.
+type PendingBeneficiaryChange struct {
+	NewBeneficiary        addr.Address // Must be an ID address
+	NewQuota              abi.TokenAmount
+	NewExpiration         abi.ChainEpoch
+	ApprovedByBeneficiary bool
+	ApprovedByNominee     bool
+}
+
+// Returns the amount that may be withdrawn to the miner's beneficiary from the available balance.
+func (info *MinerInfo) withdrawableByBeneficiary(available abi.TokenAmount, currEpoch abi.ChainEpoch) abi.TokenAmount {
+	if info.Beneficiary == info.Owner {
+		return available
+	}
+	return big.Min(available, info.BeneficiaryTerm.Available(currEpoch))
+}
@@ -6,11 +6,15 @@ const (
 	BurnMethodDisputeWindowedPoSt      BurnMethod = "DisputeWindowedPoSt"
 	BurnMethodPreCommitSectorBatch     BurnMethod = "PreCommitSectorBatch"
 	BurnMethodProveCommitAggregate     BurnMethod = "ProveCommitAggregate"
+	BurnMethodConfirmSectorProofsValid BurnMethod = "ConfirmSectorProofsValid"
+	BurnMethodProveCommitSectorsNI     BurnMethod = "ProveCommitSectorsNI"
 	BurnMethodDeclareFaultsRecovered   BurnMethod = "DeclareFaultsRecovered"
 	BurnMethodApplyRewards             BurnMethod = "ApplyRewards"
 	BurnMethodReportConsensusFault     BurnMethod = "ReportConsensusFault"
+	BurnMethodConsensusFaultBond       BurnMethod = "ConsensusFaultBond"
 	BurnMethodWithdrawBalance          BurnMethod = "WithdrawBalance "
 	BurnMethodRepayDebt                BurnMethod = "RepayDebt"
 	BurnMethodProcessEarlyTerminations BurnMethod = "ProcessEarlyTerminations"
 	BurnMethodHandleProvingDeadline    BurnMethod = "HandleProvingDeadline "
+	BurnMethodCleanUpExpiredPreCommits BurnMethod = "CleanUpExpiredPreCommits"
 )
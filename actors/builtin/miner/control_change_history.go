@@ -0,0 +1,77 @@
+package miner
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// The kind of step in a change of a miner's owner or worker.
+type ControlChangeKind uint64
+
+const (
+	// The owner proposed a new owner.
+	OwnerChangeProposed ControlChangeKind = iota
+	// The owner withdrew a proposed new owner.
+	OwnerChangeRevoked
+	// The proposed owner confirmed the change, becoming the owner.
+	OwnerChangeConfirmed
+	// The owner scheduled a change of worker.
+	WorkerChangeProposed
+	// The owner cancelled a scheduled change of worker.
+	WorkerChangeCancelled
+	// A scheduled change of worker took effect.
+	WorkerChangeConfirmed
+)
+
+// A recorded step in a change of a miner's owner or worker.
+type ControlChangeEvent struct {
+	Kind ControlChangeKind
+	// The new owner or worker.
+	Address addr.Address
+	// The epoch at which the step was recorded.
+	Epoch abi.ChainEpoch
+	// The epoch at which a worker change takes, or was scheduled to take, effect. For owner changes, the epoch
+	// at which the step was recorded.
+	EffectiveAt abi.ChainEpoch
+}
+
+// The most recent owner and worker change events, oldest first.
+type ControlChangeHistory struct {
+	Events []ControlChangeEvent
+}
+
+// Loads the recorded owner and worker change events, oldest first, which are empty if none are recorded.
+func (st *State) LoadControlChangeHistory(store adt.Store) ([]ControlChangeEvent, error) {
+	if st.ControlChanges == nil {
+		return nil, nil
+	}
+	var history ControlChangeHistory
+	if err := store.Get(store.Context(), *st.ControlChanges, &history); err != nil {
+		return nil, xerrors.Errorf("failed to load control change history (%s): %w", *st.ControlChanges, err)
+	}
+	return history.Events, nil
+}
+
+// Appends owner and worker change events to the history, dropping the oldest beyond MaxControlChangeHistory.
+func (st *State) recordControlChanges(store adt.Store, events ...ControlChangeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	history, err := st.LoadControlChangeHistory(store)
+	if err != nil {
+		return err
+	}
+	history = append(history, events...)
+	if len(history) > MaxControlChangeHistory {
+		history = history[len(history)-MaxControlChangeHistory:]
+	}
+	c, err := store.Put(store.Context(), &ControlChangeHistory{Events: history})
+	if err != nil {
+		return xerrors.Errorf("failed to store control change history: %w", err)
+	}
+	st.ControlChanges = &c
+	return nil
+}
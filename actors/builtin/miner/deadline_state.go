@@ -24,6 +24,11 @@ type Deadlines struct {
 	// to make new sector assignment cheaper. At the moment, assigning a sector requires
 	// loading all deadlines to figure out where best to assign new sectors.
 	Due [WPoStPeriodDeadlines]cid.Cid // []Deadline
+
+	// Whether any deadline has been updated since the deadlines were loaded or last saved.
+	modified bool
+	// Bitmask of the indices of deadlines updated since the deadlines were loaded or last saved.
+	updated uint64
 }
 
 // Deadline holds the state for all sectors due at a specific deadline.
@@ -92,8 +97,15 @@ type WindowedPoSt struct {
 	// this array will always have a single element (independent of number
 	// of partitions).
 	Proofs []proof.PoStProof
+	// Epoch of the chain commit randomness to which the submission was bound,
+	// or NoChainCommitEpoch if it was recorded before the epoch was tracked.
+	ChainCommitEpoch abi.ChainEpoch
 }
 
+// Marks a WindowedPoSt recorded without its chain commit epoch, which is taken to be the
+// challenge epoch of its deadline.
+const NoChainCommitEpoch = abi.ChainEpoch(-1)
+
 // Bitwidth of AMTs determined empirically from mutation patterns and projections of mainnet data.
 const DeadlinePartitionsAmtBitwidth = 3 // Usually a small array
 const DeadlineExpirationAmtBitwidth = 5
@@ -111,6 +123,7 @@ func ConstructDeadlines(emptyDeadlineCid cid.Cid) *Deadlines {
 	for i := range d.Due {
 		d.Due[i] = emptyDeadlineCid
 	}
+	d.modified = true
 	return d
 }
 
@@ -154,6 +167,8 @@ func (d *Deadlines) UpdateDeadline(store adt.Store, dlIdx uint64, deadline *Dead
 		return err
 	}
 	d.Due[dlIdx] = dlCid
+	d.modified = true
+	d.updated |= 1 << dlIdx
 
 	return nil
 }
@@ -289,6 +304,42 @@ func (d *Deadline) AddExpirationPartitions(store adt.Store, expirationEpoch abi.
 	return nil
 }
 
+// Returns the sectors of all partitions scheduled to expire on time at an epoch, which is quantized
+// to the end of the deadline.
+func (dl *Deadline) OnTimeExpirations(store adt.Store, epoch abi.ChainEpoch, quant builtin.QuantSpec) (bitfield.BitField, error) {
+	key := quant.QuantizeUp(epoch)
+	queue, err := LoadBitfieldQueue(store, dl.ExpirationsEpochs, quant, DeadlineExpirationAmtBitwidth)
+	if err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to load expiration queue: %w", err)
+	}
+	var partitions bitfield.BitField
+	found, err := queue.Get(uint64(key), &partitions)
+	if err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to lookup partitions expiring at %d: %w", key, err)
+	}
+	if !found {
+		return bitfield.New(), nil
+	}
+
+	var sectors []bitfield.BitField
+	err = partitions.ForEach(func(partIdx uint64) error {
+		partition, err := dl.LoadPartition(store, partIdx)
+		if err != nil {
+			return err
+		}
+		expiring, err := partition.OnTimeExpirations(store, key)
+		if err != nil {
+			return xerrors.Errorf("failed to find expirations of partition %d: %w", partIdx, err)
+		}
+		sectors = append(sectors, expiring)
+		return nil
+	})
+	if err != nil {
+		return bitfield.BitField{}, err
+	}
+	return bitfield.MultiMerge(sectors...)
+}
+
 // PopExpiredSectors terminates expired sectors from all partitions.
 // Returns the expired sector aggregates.
 func (dl *Deadline) PopExpiredSectors(store adt.Store, until abi.ChainEpoch, quant builtin.QuantSpec) (*ExpirationSet, error) {
@@ -650,6 +701,21 @@ func (dl *Deadline) TerminateSectors(
 // terminations.
 func (dl *Deadline) RemovePartitions(store adt.Store, toRemove bitfield.BitField, quant builtin.QuantSpec) (
 	live, dead bitfield.BitField, removedPower PowerPair, err error,
+) {
+	return dl.removePartitions(store, toRemove, quant, false)
+}
+
+// RemovePartitionsAllowingEarlyTerminations behaves like RemovePartitions, but only refuses
+// to remove partitions that themselves have un-processed early terminations. Partitions that
+// are retained keep their pending early terminations, re-indexed to their new positions.
+func (dl *Deadline) RemovePartitionsAllowingEarlyTerminations(store adt.Store, toRemove bitfield.BitField, quant builtin.QuantSpec) (
+	live, dead bitfield.BitField, removedPower PowerPair, err error,
+) {
+	return dl.removePartitions(store, toRemove, quant, true)
+}
+
+func (dl *Deadline) removePartitions(store adt.Store, toRemove bitfield.BitField, quant builtin.QuantSpec, allowEarlyTerminations bool) (
+	live, dead bitfield.BitField, removedPower PowerPair, err error,
 ) {
 	oldPartitions, err := dl.PartitionsArray(store)
 	if err != nil {
@@ -675,13 +741,28 @@ func (dl *Deadline) RemovePartitions(store adt.Store, toRemove bitfield.BitField
 		}
 	}
 
-	// Should already be checked earlier, but we might as well check again.
-	noEarlyTerminations, err := dl.EarlyTerminations.IsEmpty()
-	if err != nil {
-		return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to check for early terminations: %w", err)
-	}
-	if !noEarlyTerminations {
-		return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("cannot remove partitions from deadline with early terminations: %w", err)
+	if allowEarlyTerminations {
+		// Only the partitions being removed must be free of early terminations.
+		for partIdx := range toRemoveSet { //nolint:nomaprange
+			pending, err := dl.EarlyTerminations.IsSet(partIdx)
+			if err != nil {
+				return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to check for early terminations: %w", err)
+			}
+			if pending {
+				return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xc.ErrIllegalArgument.Wrapf(
+					"cannot remove partition %d: has un-processed early terminations", partIdx,
+				)
+			}
+		}
+	} else {
+		// Should already be checked earlier, but we might as well check again.
+		noEarlyTerminations, err := dl.EarlyTerminations.IsEmpty()
+		if err != nil {
+			return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to check for early terminations: %w", err)
+		}
+		if !noEarlyTerminations {
+			return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("cannot remove partitions from deadline with early terminations: %w", err)
+		}
 	}
 
 	newPartitions, err := adt.MakeEmptyArray(store, DeadlinePartitionsAmtBitwidth)
@@ -790,6 +871,20 @@ func (dl *Deadline) RemovePartitions(store adt.Store, toRemove bitfield.BitField
 		}
 	}
 
+	// Shift early terminations of retained partitions along with the partitions themselves.
+	// The cut bitfield is re-built so that it may still be modified in place, as when terminations are popped.
+	shifted, err := bitfield.CutBitField(dl.EarlyTerminations, toRemove)
+	if err != nil {
+		return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to cut removed partitions from early terminations: %w", err)
+	}
+	shiftedRuns, err := shifted.RunIterator()
+	if err != nil {
+		return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to iterate shifted early terminations: %w", err)
+	}
+	if dl.EarlyTerminations, err = bitfield.NewFromIter(shiftedRuns); err != nil {
+		return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to rebuild shifted early terminations: %w", err)
+	}
+
 	return live, dead, removedPower, nil
 }
 
@@ -1004,6 +1099,8 @@ type PoStResult struct {
 	IgnoredSectors bitfield.BitField
 	// Bitfield of partitions that were proven.
 	Partitions bitfield.BitField
+	// Counts of sectors newly marked faulty, recovered, and declared skipped by the proof.
+	NewFaultySectors, RecoveredSectors, SkippedSectors uint64
 }
 
 // RecordProvenSectors processes a series of posts, recording proven partitions
@@ -1037,7 +1134,7 @@ func (dl *Deadline) RecordProvenSectors(
 	} else if empty, err := alreadyProven.IsEmpty(); err != nil {
 		return nil, xerrors.Errorf("failed to check proven intersection is empty: %w", err)
 	} else if !empty {
-		return nil, xc.ErrIllegalArgument.Wrapf("partition already proven: %v", alreadyProven)
+		return nil, ErrPartitionAlreadyProven.Wrapf("partition already proven: %v", alreadyProven)
 	}
 
 	partitions, err := dl.PartitionsArray(store)
@@ -1051,6 +1148,7 @@ func (dl *Deadline) RecordProvenSectors(
 	retractedRecoveryPowerTotal := NewPowerPairZero()
 	recoveredPowerTotal := NewPowerPairZero()
 	powerDelta := NewPowerPairZero()
+	var newFaultySectors, recoveredSectors, skippedSectors uint64
 	var rescheduledPartitions []uint64
 
 	// Accumulate sectors info for proof verification.
@@ -1063,6 +1161,15 @@ func (dl *Deadline) RecordProvenSectors(
 			return nil, xc.ErrNotFound.Wrapf("no such partition %d", post.Index)
 		}
 
+		faultCountBefore, err := partition.Faults.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to count faults for partition %d: %w", post.Index, err)
+		}
+		skippedCount, err := post.Skipped.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to count skipped sectors for partition %d: %w", post.Index, err)
+		}
+
 		// Process new faults and accumulate new faulty power.
 		// This updates the faults in partition state ahead of calculating the sectors to include for proof.
 		newPowerDelta, newFaultPower, retractedRecoveryPower, hasNewFaults, err := partition.RecordSkippedFaults(
@@ -1078,6 +1185,16 @@ func (dl *Deadline) RecordProvenSectors(
 			rescheduledPartitions = append(rescheduledPartitions, post.Index)
 		}
 
+		faultCountAfter, err := partition.Faults.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to count faults for partition %d: %w", post.Index, err)
+		}
+		// Recoveries that were skipped have been removed above, so what remains is about to be recovered.
+		recoveryCount, err := partition.Recoveries.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to count recoveries for partition %d: %w", post.Index, err)
+		}
+
 		recoveredPower, err := partition.RecoverFaults(store, sectors, ssize, quant)
 		if err != nil {
 			return nil, xerrors.Errorf("failed to recover faulty sectors for partition %d: %w", post.Index, err)
@@ -1096,6 +1213,9 @@ func (dl *Deadline) RecordProvenSectors(
 		retractedRecoveryPowerTotal = retractedRecoveryPowerTotal.Add(retractedRecoveryPower)
 		recoveredPowerTotal = recoveredPowerTotal.Add(recoveredPower)
 		powerDelta = powerDelta.Add(newPowerDelta).Add(recoveredPower)
+		newFaultySectors += faultCountAfter - faultCountBefore
+		recoveredSectors += recoveryCount
+		skippedSectors += skippedCount
 
 		// Record the post.
 		dl.PartitionsPoSted.Set(post.Index)
@@ -1138,19 +1258,24 @@ func (dl *Deadline) RecordProvenSectors(
 		RecoveredPower:         recoveredPowerTotal,
 		RetractedRecoveryPower: retractedRecoveryPowerTotal,
 		Partitions:             partitionIndexes,
+		NewFaultySectors:       newFaultySectors,
+		RecoveredSectors:       recoveredSectors,
+		SkippedSectors:         skippedSectors,
 	}, nil
 }
 
 // RecordPoStProofs records a set of optimistically accepted PoSt proofs
-// (usually one), associating them with the given partitions.
-func (dl *Deadline) RecordPoStProofs(store adt.Store, partitions bitfield.BitField, proofs []proof.PoStProof) error {
+// (usually one), associating them with the given partitions and the epoch
+// of the chain commit randomness they were submitted with.
+func (dl *Deadline) RecordPoStProofs(store adt.Store, partitions bitfield.BitField, proofs []proof.PoStProof, chainCommitEpoch abi.ChainEpoch) error {
 	proofArr, err := dl.OptimisticProofsArray(store)
 	if err != nil {
 		return xerrors.Errorf("failed to load proofs: %w", err)
 	}
 	err = proofArr.AppendContinuous(&WindowedPoSt{
-		Partitions: partitions,
-		Proofs:     proofs,
+		Partitions:       partitions,
+		Proofs:           proofs,
+		ChainCommitEpoch: chainCommitEpoch,
 	})
 	if err != nil {
 		return xerrors.Errorf("failed to store proof: %w", err)
@@ -1165,29 +1290,29 @@ func (dl *Deadline) RecordPoStProofs(store adt.Store, partitions bitfield.BitFie
 }
 
 // TakePoStProofs removes and returns a PoSt proof by index, along with the
-// associated partitions. This method takes the PoSt from the PoSt submissions
-// snapshot.
-func (dl *Deadline) TakePoStProofs(store adt.Store, idx uint64) (partitions bitfield.BitField, proofs []proof.PoStProof, err error) {
+// associated partitions and chain commit epoch. This method takes the PoSt
+// from the PoSt submissions snapshot.
+func (dl *Deadline) TakePoStProofs(store adt.Store, idx uint64) (partitions bitfield.BitField, proofs []proof.PoStProof, chainCommitEpoch abi.ChainEpoch, err error) {
 	proofArr, err := dl.OptimisticProofsSnapshotArray(store)
 	if err != nil {
-		return bitfield.New(), nil, xerrors.Errorf("failed to load proofs: %w", err)
+		return bitfield.New(), nil, NoChainCommitEpoch, xerrors.Errorf("failed to load proofs: %w", err)
 	}
 
 	// Extract and remove the proof from the proofs array, leaving a hole.
 	// This will not affect concurrent attempts to refute other proofs.
 	var post WindowedPoSt
 	if found, err := proofArr.Pop(idx, &post); err != nil {
-		return bitfield.New(), nil, xerrors.Errorf("failed to retrieve proof %d: %w", idx, err)
+		return bitfield.New(), nil, NoChainCommitEpoch, xerrors.Errorf("failed to retrieve proof %d: %w", idx, err)
 	} else if !found {
-		return bitfield.New(), nil, xc.ErrIllegalArgument.Wrapf("proof %d not found", idx)
+		return bitfield.New(), nil, NoChainCommitEpoch, xc.ErrIllegalArgument.Wrapf("proof %d not found", idx)
 	}
 
 	root, err := proofArr.Root()
 	if err != nil {
-		return bitfield.New(), nil, xerrors.Errorf("failed to save proofs: %w", err)
+		return bitfield.New(), nil, NoChainCommitEpoch, xerrors.Errorf("failed to save proofs: %w", err)
 	}
 	dl.OptimisticPoStSubmissionsSnapshot = root
-	return post.Partitions, post.Proofs, nil
+	return post.Partitions, post.Proofs, post.ChainCommitEpoch, nil
 }
 
 // DisputeInfo includes all the information necessary to dispute a post to the
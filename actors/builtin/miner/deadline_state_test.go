@@ -269,6 +269,46 @@ func TestDeadlines(t *testing.T) {
 		require.Error(t, err, "should have failed to remove a partition with early terminations")
 	})
 
+	t.Run("can remove partitions without early terminations when allowed", func(t *testing.T) {
+		store := ipld.NewADTStore(context.Background())
+		dl := emptyDeadline(t, store)
+		addThenTerminate(t, store, dl, true)
+
+		// Process partition 0's terminations only, leaving partition 1's pending.
+		_, hasMore, err := dl.PopEarlyTerminations(store, 1, 2)
+		require.NoError(t, err)
+		require.True(t, hasMore)
+
+		// Partition 1 still has early terminations.
+		_, _, _, err = dl.RemovePartitionsAllowingEarlyTerminations(store, bf(1), quantSpec)
+		require.Error(t, err, "should have failed to remove a partition with early terminations")
+
+		live, dead, removedPower, err := dl.RemovePartitionsAllowingEarlyTerminations(store, bf(0), quantSpec)
+		require.NoError(t, err, "should have removed partitions")
+		assertBitfieldEquals(t, live, 2, 4)
+		assertBitfieldEquals(t, dead, 1, 3)
+		livePower := miner.PowerForSectors(sectorSize, selectSectors(t, sectors, live))
+		require.True(t, livePower.Equals(removedPower))
+
+		// The pending early terminations moved with partition 1 to index 0.
+		assertBitfieldEquals(t, dl.EarlyTerminations, 0)
+
+		dlState.withTerminations(6).
+			withPartitions(
+				bf(5, 6, 7, 8),
+				bf(9),
+			).assert(t, store, dl)
+
+		// The pending termination is then processed from the partition's new index.
+		result, hasMore, err := dl.PopEarlyTerminations(store, 1, 2)
+		require.NoError(t, err)
+		require.False(t, hasMore)
+		assert.Equal(t, uint64(1), result.PartitionsProcessed)
+		assert.Equal(t, uint64(1), result.SectorsProcessed)
+		assertBitfieldEquals(t, result.Sectors[15], 6)
+		assertBitfieldEmpty(t, dl.EarlyTerminations)
+	})
+
 	t.Run("can pop early terminations in multiple steps", func(t *testing.T) {
 		store := ipld.NewADTStore(context.Background())
 		dl := emptyDeadline(t, store)
@@ -0,0 +1,156 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// Returns the epoch from which a sector has been continuously faulty, if it is in a fault streak.
+// A streak starts at the end of the first challenge window for which the sector was found faulty, and
+// ends at the end of the first for which it was not. A sector terminated while faulty may retain its
+// streak until the sector's info is removed.
+func (st *State) GetFaultStreakStart(store adt.Store, sectorNo abi.SectorNumber) (abi.ChainEpoch, bool, error) {
+	if st.FaultStreakStarts == nil {
+		return 0, false, nil
+	}
+	starts, err := adt.AsMap(store, *st.FaultStreakStarts, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to load fault streaks: %w", err)
+	}
+	var out cbg.CborInt
+	found, err := starts.Get(abi.UIntKey(uint64(sectorNo)), &out)
+	if err != nil {
+		return 0, false, xerrors.Errorf("failed to get fault streak for sector %d: %w", sectorNo, err)
+	}
+	return abi.ChainEpoch(out), found, nil
+}
+
+// Starts a fault streak at an epoch for each of a deadline's faulty sectors not already in one, and ends
+// the streak of each of the deadline's sectors that is no longer faulty.
+func (st *State) updateFaultStreaks(store adt.Store, deadline *Deadline, epoch abi.ChainEpoch) error {
+	noneTracked, err := st.FaultStreakSectors.IsEmpty()
+	if err != nil {
+		return xerrors.Errorf("failed to check fault streak sectors: %w", err)
+	}
+	if noneTracked && deadline.FaultyPower.IsZero() {
+		return nil
+	}
+
+	partitions, err := deadline.PartitionsArray(store)
+	if err != nil {
+		return err
+	}
+	var started, ended []bitfield.BitField
+	var partition Partition
+	err = partitions.ForEach(&partition, func(_ int64) error {
+		start, err := bitfield.SubtractBitField(partition.Faults, st.FaultStreakSectors)
+		if err != nil {
+			return xerrors.Errorf("failed to compute new fault streaks: %w", err)
+		}
+		tracked, err := bitfield.IntersectBitField(partition.Sectors, st.FaultStreakSectors)
+		if err != nil {
+			return xerrors.Errorf("failed to compute fault streaks in partition: %w", err)
+		}
+		end, err := bitfield.SubtractBitField(tracked, partition.Faults)
+		if err != nil {
+			return xerrors.Errorf("failed to compute ended fault streaks: %w", err)
+		}
+		started = append(started, start)
+		ended = append(ended, end)
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to walk partitions: %w", err)
+	}
+
+	allStarted, err := bitfield.MultiMerge(started...)
+	if err != nil {
+		return xerrors.Errorf("failed to merge new fault streaks: %w", err)
+	}
+	allEnded, err := bitfield.MultiMerge(ended...)
+	if err != nil {
+		return xerrors.Errorf("failed to merge ended fault streaks: %w", err)
+	}
+	if err := st.endFaultStreaks(store, allEnded); err != nil {
+		return err
+	}
+	if empty, err := allStarted.IsEmpty(); err != nil {
+		return xerrors.Errorf("failed to check new fault streaks: %w", err)
+	} else if empty {
+		return nil
+	}
+	err = st.updateFaultStreakStarts(store, func(m *adt.Map) error {
+		value := cbg.CborInt(epoch)
+		return allStarted.ForEach(func(sectorNo uint64) error {
+			if err := m.Put(abi.UIntKey(sectorNo), &value); err != nil {
+				return xerrors.Errorf("failed to put fault streak for sector %d: %w", sectorNo, err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	st.FaultStreakSectors, err = bitfield.MergeBitFields(st.FaultStreakSectors, allStarted)
+	if err != nil {
+		return xerrors.Errorf("failed to add fault streak sectors: %w", err)
+	}
+	return nil
+}
+
+// Ends any fault streaks of sectors.
+func (st *State) endFaultStreaks(store adt.Store, sectorNos bitfield.BitField) error {
+	toEnd, err := bitfield.IntersectBitField(sectorNos, st.FaultStreakSectors)
+	if err != nil {
+		return xerrors.Errorf("failed to compute fault streaks to end: %w", err)
+	}
+	if empty, err := toEnd.IsEmpty(); err != nil {
+		return xerrors.Errorf("failed to check fault streaks to end: %w", err)
+	} else if empty {
+		return nil
+	}
+	err = st.updateFaultStreakStarts(store, func(m *adt.Map) error {
+		return toEnd.ForEach(func(sectorNo uint64) error {
+			if _, err := m.TryDelete(abi.UIntKey(sectorNo)); err != nil {
+				return xerrors.Errorf("failed to delete fault streak for sector %d: %w", sectorNo, err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	st.FaultStreakSectors, err = bitfield.SubtractBitField(st.FaultStreakSectors, toEnd)
+	if err != nil {
+		return xerrors.Errorf("failed to remove fault streak sectors: %w", err)
+	}
+	return nil
+}
+
+// Applies a change to the fault streak starts, creating the map on first use.
+func (st *State) updateFaultStreakStarts(store adt.Store, update func(m *adt.Map) error) error {
+	var starts *adt.Map
+	var err error
+	if st.FaultStreakStarts == nil {
+		starts, err = adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	} else {
+		starts, err = adt.AsMap(store, *st.FaultStreakStarts, builtin.DefaultHamtBitwidth)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to load fault streaks: %w", err)
+	}
+	if err := update(starts); err != nil {
+		return err
+	}
+	root, err := starts.Root()
+	if err != nil {
+		return xerrors.Errorf("failed to flush fault streaks: %w", err)
+	}
+	st.FaultStreakStarts = &root
+	return nil
+}
@@ -0,0 +1,150 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	xc "github.com/filecoin-project/go-state-types/exitcode"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// A period declared in advance by a miner for planned maintenance, during which sectors it declares faulty
+// pay a reduced fee for their continued faults.
+// The total length of the windows starting in each quota period is limited.
+type MaintenanceWindow struct {
+	// First epoch of the window.
+	Start abi.ChainEpoch
+	// First epoch after the window.
+	End abi.ChainEpoch
+	// Total length of the windows declared starting in the quota period containing Start, including this one.
+	QuotaUsed abi.ChainEpoch
+	// Sectors declared faulty during the window, excluding any already faulty when declared.
+	Faults bitfield.BitField
+}
+
+// Whether the window includes an epoch.
+func (w *MaintenanceWindow) Active(epoch abi.ChainEpoch) bool {
+	return w.Start <= epoch && epoch < w.End
+}
+
+// Index of the quota period containing an epoch, with periods aligned to epoch zero.
+func maintenanceQuotaPeriod(epoch abi.ChainEpoch) int64 {
+	return int64(epoch / MaintenanceQuotaPeriod)
+}
+
+// Checks a window to be declared at the current epoch against the miner's previous window, if any,
+// returning the window to record.
+func newMaintenanceWindow(prev *MaintenanceWindow, currEpoch, start, duration abi.ChainEpoch) (*MaintenanceWindow, error) {
+	if duration <= 0 {
+		return nil, xc.ErrIllegalArgument.Wrapf("maintenance window duration %d must be positive", duration)
+	}
+	if start < currEpoch+MaintenanceWindowNotice {
+		return nil, xc.ErrIllegalArgument.Wrapf("maintenance window must start at or after epoch %d, was %d",
+			currEpoch+MaintenanceWindowNotice, start)
+	}
+	end := start + duration
+	if maintenanceQuotaPeriod(start) != maintenanceQuotaPeriod(end-1) {
+		return nil, xc.ErrIllegalArgument.Wrapf("maintenance window [%d, %d) spans the end of a quota period", start, end)
+	}
+
+	quotaUsed := duration
+	if prev != nil {
+		if prev.End > currEpoch {
+			return nil, xc.ErrForbidden.Wrapf("maintenance window [%d, %d) has not yet ended", prev.Start, prev.End)
+		}
+		if maintenanceQuotaPeriod(prev.Start) == maintenanceQuotaPeriod(start) {
+			quotaUsed += prev.QuotaUsed
+		}
+	}
+	if quotaUsed > MaxMaintenanceEpochsPerQuotaPeriod {
+		return nil, xc.ErrForbidden.Wrapf("maintenance windows of %d epochs exceed quota of %d epochs per quota period",
+			quotaUsed, MaxMaintenanceEpochsPerQuotaPeriod)
+	}
+	return &MaintenanceWindow{
+		Start:     start,
+		End:       end,
+		QuotaUsed: quotaUsed,
+		Faults:    bitfield.New(),
+	}, nil
+}
+
+// Records sectors being declared faulty in a deadline as faults of the window.
+// Sectors already faulty are excluded, so that faults pre-dating the window continue to pay the full fee.
+func (w *MaintenanceWindow) recordDeclaredFaults(store adt.Store, dl *Deadline, partitionSectors PartitionSectorMap) error {
+	partitions, err := dl.PartitionsArray(store)
+	if err != nil {
+		return err
+	}
+	return partitionSectors.ForEach(func(partIdx uint64, sectorNos bitfield.BitField) error {
+		var partition Partition
+		if found, err := partitions.Get(partIdx, &partition); err != nil {
+			return xc.ErrIllegalState.Wrapf("failed to load partition %d: %w", partIdx, err)
+		} else if !found {
+			return xc.ErrNotFound.Wrapf("no such partition %d", partIdx)
+		}
+		newFaults, err := bitfield.SubtractBitField(sectorNos, partition.Faults)
+		if err != nil {
+			return xerrors.Errorf("failed to subtract existing faults in partition %d: %w", partIdx, err)
+		}
+		w.Faults, err = bitfield.MergeBitFields(w.Faults, newFaults)
+		if err != nil {
+			return xerrors.Errorf("failed to record maintenance faults in partition %d: %w", partIdx, err)
+		}
+		return nil
+	})
+}
+
+// Returns the power of the sectors faulty in a deadline that were declared faulty during the miner's
+// maintenance window, if the window includes the current epoch, else zero power.
+func (st *State) MaintenanceFaultyPower(store adt.Store, dlIdx uint64, currEpoch abi.ChainEpoch) (PowerPair, error) {
+	w := st.MaintenanceWindow
+	if w == nil || !w.Active(currEpoch) {
+		return NewPowerPairZero(), nil
+	}
+
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return NewPowerPairZero(), err
+	}
+	dl, err := deadlines.LoadDeadline(store, dlIdx)
+	if err != nil {
+		return NewPowerPairZero(), err
+	}
+	partitions, err := dl.PartitionsArray(store)
+	if err != nil {
+		return NewPowerPairZero(), err
+	}
+
+	var faults []bitfield.BitField
+	var partition Partition
+	if err := partitions.ForEach(&partition, func(partIdx int64) error {
+		partFaults, err := bitfield.IntersectBitField(partition.Faults, w.Faults)
+		if err != nil {
+			return xerrors.Errorf("failed to intersect faults in partition %d: %w", partIdx, err)
+		}
+		faults = append(faults, partFaults)
+		return nil
+	}); err != nil {
+		return NewPowerPairZero(), err
+	}
+	allFaults, err := bitfield.MultiMerge(faults...)
+	if err != nil {
+		return NewPowerPairZero(), xerrors.Errorf("failed to merge maintenance faults: %w", err)
+	}
+	if empty, err := allFaults.IsEmpty(); err != nil {
+		return NewPowerPairZero(), xerrors.Errorf("failed to check maintenance faults: %w", err)
+	} else if empty {
+		return NewPowerPairZero(), nil
+	}
+
+	info, err := st.GetInfo(store)
+	if err != nil {
+		return NewPowerPairZero(), err
+	}
+	sectors, err := st.LoadSectorInfos(store, allFaults)
+	if err != nil {
+		return NewPowerPairZero(), xerrors.Errorf("failed to load maintenance fault sectors: %w", err)
+	}
+	return PowerForSectors(info.SectorSize, sectors), nil
+}
@@ -21,13 +21,13 @@ import (
 	miner3 "github.com/filecoin-project/specs-actors/v3/actors/builtin/miner"
 	miner5 "github.com/filecoin-project/specs-actors/v5/actors/builtin/miner"
 	cid "github.com/ipfs/go-cid"
-	cbg "github.com/whyrusleeping/cbor-gen"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/power"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/reward"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/verifreg"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime/proof"
 	. "github.com/filecoin-project/specs-actors/v8/actors/util"
@@ -40,7 +40,17 @@ type Runtime = runtime.Runtime
 const (
 	// The first 1000 actor-specific codes are left open for user error, i.e. things that might
 	// actually happen without programming error in the actor code.
-	//ErrToBeDetermined = exitcode.FirstActorSpecificExitCode + iota
+	// A Window PoSt submission proves some, but not all, of its partitions a second time at a deadline.
+	ErrPartitionAlreadyProven = exitcode.FirstActorSpecificExitCode + iota
+	// A Window PoSt submission targets a deadline other than the one currently open.
+	ErrWrongDeadline
+	// Sectors or partitions are modified in a deadline that may not currently change, because it is
+	// open for proving, about to open, or may still have its proofs disputed.
+	ErrImmutableDeadline
+	// The miner's unlocked balance cannot cover the initial pledge required for sectors.
+	ErrInsufficientPledge
+	// A pre-committed sector is proven after its prove-commit deadline has passed.
+	ErrPreCommitExpired
 
 	// The following errors are particular cases of illegal state.
 	// They're not expected to ever happen, but if they do, distinguished codes can help us
@@ -79,6 +89,40 @@ func (a Actor) Exports() []interface{} {
 		25:                        a.PreCommitSectorBatch,
 		26:                        a.ProveCommitAggregate,
 		27:                        a.ProveReplicaUpdates,
+		28:                        a.TerminatedSectorCounts,
+		29:                        a.ChangeOwnerSettings,
+		30:                        a.PartitionExpirations,
+		31:                        a.SubmitWindowedPoStRelayed,
+		32:                        a.DeclareFaultsAndRecoveries,
+		33:                        a.SectorManifest,
+		34:                        a.GetVestingFunds,
+		35:                        a.ChangeBeneficiary,
+		36:                        a.GetBeneficiary,
+		37:                        a.ExtendSectorExpiration2,
+		38:                        a.ProveCommitSectorsNI,
+		39:                        a.ChangeWindowPoStProofType,
+		40:                        a.CleanUpExpiredPreCommits,
+		41:                        a.MovePartitions,
+		42:                        a.DeclareMaintenanceWindow,
+		43:                        a.ProveReplicaUpdates2,
+		44:                        a.EstimateDeadlinePenalty,
+		45:                        a.SubmitWindowedPoStAggregate,
+		46:                        a.CancelWorkerChange,
+		47:                        a.SetNotificationReceiver,
+		48:                        a.DeadlineSectorCounts,
+		49:                        a.RebalanceSectors,
+		50:                        a.FindSector,
+		51:                        a.DroppedCronEvents,
+		52:                        a.TerminateBreachedSector,
+		53:                        a.RollbackReplicaUpdates,
+		54:                        a.EstimateAggregateFees,
+		55:                        a.GetControlChangeHistory,
+		56:                        a.SetExpirationReminder,
+		57:                        a.PreCommitSectorBatch2,
+		58:                        a.ProveCommitSector2,
+		59:                        a.TerminateSectors2,
+		60:                        a.DeclareFaultsRecovered2,
+		61:                        a.CompactPartitions2,
 	}
 }
 
@@ -182,6 +226,8 @@ func (a Actor) ChangeWorkerAddress(rt Runtime, params *ChangeWorkerAddressParams
 	}
 
 	var st State
+	var scheduled bool
+	effectiveAt := rt.CurrEpoch() + WorkerKeyChangeDelay
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 
@@ -191,22 +237,43 @@ func (a Actor) ChangeWorkerAddress(rt Runtime, params *ChangeWorkerAddressParams
 		// save the new control addresses
 		info.ControlAddresses = controlAddrs
 
-		// save newWorker addr key change request
-		if newWorker != info.Worker && info.PendingWorkerKey == nil {
-			info.PendingWorkerKey = &WorkerKeyChange{
-				NewWorker:   newWorker,
-				EffectiveAt: rt.CurrEpoch() + WorkerKeyChangeDelay,
+		// schedule newWorker addr key change request after any already pending
+		store := adt.AsStore(rt)
+		applyDueWorkerKeyChanges(rt, &st, info)
+		var err error
+		scheduled, err = info.scheduleWorkerKeyChange(store, newWorker, effectiveAt)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to schedule worker key change")
+		if scheduled {
+			pending, err := info.LoadPendingWorkerKeys(store)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load pending worker key changes")
+			if len(pending) > MaxPendingWorkerKeyChanges {
+				rt.Abortf(exitcode.ErrForbidden, "too many pending worker key changes, limit %d", MaxPendingWorkerKeyChanges)
 			}
+			err = st.recordControlChanges(store, ControlChangeEvent{
+				Kind:        WorkerChangeProposed,
+				Address:     newWorker,
+				Epoch:       rt.CurrEpoch(),
+				EffectiveAt: effectiveAt,
+			})
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record worker change proposal")
 		}
 
-		err := st.SaveInfo(adt.AsStore(rt), info)
+		err = st.SaveInfo(adt.AsStore(rt), info)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
 	})
 
+	// Effect the change at its effective epoch even if the miner has no deadline cron running,
+	// or the owner doesn't confirm it.
+	if scheduled {
+		enrollCronEvent(rt, effectiveAt, CronEventWorkerKeyChange)
+	}
+
 	return nil
 }
 
 // Triggers a worker address change if a change has been requested and its effective epoch has arrived.
+// A change is also effected by cron at its effective epoch, so this need only be called to effect it sooner
+// than the cron callback.
 func (a Actor) ConfirmUpdateWorkerKey(rt Runtime, params *abi.EmptyValue) *abi.EmptyValue {
 	var st State
 	rt.StateTransaction(&st, func() {
@@ -221,6 +288,98 @@ func (a Actor) ConfirmUpdateWorkerKey(rt Runtime, params *abi.EmptyValue) *abi.E
 	return nil
 }
 
+type CancelWorkerChangeParams struct {
+	EffectiveAt abi.ChainEpoch // Epoch at which the change to cancel was scheduled to take effect.
+}
+
+// Cancels a pending worker key change that has not yet taken effect.
+// Changes scheduled after the cancelled one remain pending.
+func (a Actor) CancelWorkerChange(rt Runtime, params *CancelWorkerChangeParams) *abi.EmptyValue {
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+
+		// Only the Owner is allowed to cancel a worker change.
+		rt.ValidateImmediateCallerIs(info.Owner)
+
+		store := adt.AsStore(rt)
+		applyDueWorkerKeyChanges(rt, &st, info)
+
+		cancelled, err := info.cancelWorkerKeyChange(store, params.EffectiveAt)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to cancel worker key change")
+		if cancelled == nil {
+			rt.Abortf(exitcode.ErrNotFound, "no pending worker key change effective at %d", params.EffectiveAt)
+		}
+		err = st.recordControlChanges(store, ControlChangeEvent{
+			Kind:        WorkerChangeCancelled,
+			Address:     cancelled.NewWorker,
+			Epoch:       rt.CurrEpoch(),
+			EffectiveAt: cancelled.EffectiveAt,
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record worker change cancellation")
+
+		err = st.SaveInfo(store, info)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
+	})
+
+	return nil
+}
+
+type SetNotificationReceiverParams struct {
+	NewReceiver *addr.Address // Clears the receiver if nil.
+}
+
+// Sets or clears the actor notified with MethodNotifyPreCommitsExpired when expired pre-commitments are cleaned up.
+// The receiver must be an actor that is not built in, as built-in actors don't implement the notification methods.
+func (a Actor) SetNotificationReceiver(rt Runtime, params *SetNotificationReceiverParams) *abi.EmptyValue {
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(info.Owner)
+
+		var receiver *addr.Address
+		if params.NewReceiver != nil {
+			resolved, ok := rt.ResolveAddress(*params.NewReceiver)
+			if !ok {
+				rt.Abortf(exitcode.ErrIllegalArgument, "unable to resolve notification receiver address %v", *params.NewReceiver)
+			}
+			code, ok := rt.GetActorCodeCID(resolved)
+			if !ok || builtin.IsBuiltinActor(code) {
+				rt.Abortf(exitcode.ErrForbidden, "notification receiver %v is not an actor that may be notified", resolved)
+			}
+			receiver = &resolved
+		}
+		info.NotificationReceiver = receiver
+		err := st.SaveInfo(adt.AsStore(rt), info)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
+	})
+	return nil
+}
+
+type SetExpirationReminderParams struct {
+	Periods uint64 // Disables reminders if zero.
+}
+
+// Sets the number of proving periods before sectors expire at which deadline cron reminds the notification receiver
+// of their expiration with MethodNotifySectorsExpiring. No reminders are sent while there is no receiver.
+func (a Actor) SetExpirationReminder(rt Runtime, params *SetExpirationReminderParams) *abi.EmptyValue {
+	if params.Periods > MaxExpirationReminderPeriods {
+		rt.Abortf(exitcode.ErrIllegalArgument, "reminder %d proving periods ahead exceeds maximum %d",
+			params.Periods, MaxExpirationReminderPeriods)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(info.Owner)
+
+		info.ExpirationReminderPeriods = params.Periods
+		err := st.SaveInfo(adt.AsStore(rt), info)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
+	})
+	return nil
+}
+
 // Proposes or confirms a change of owner address.
 // If invoked by the current owner, proposes a new owner address for confirmation. If the proposed address is the
 // current owner address, revokes any existing proposal.
@@ -236,9 +395,15 @@ func (a Actor) ChangeOwnerAddress(rt Runtime, newAddress *addr.Address) *abi.Emp
 	var st State
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
+		event := ControlChangeEvent{Address: *newAddress, Epoch: rt.CurrEpoch(), EffectiveAt: rt.CurrEpoch()}
 		if rt.Caller() == info.Owner || info.PendingOwnerAddress == nil {
 			// Propose new address.
 			rt.ValidateImmediateCallerIs(info.Owner)
+			event.Kind = OwnerChangeProposed
+			if *newAddress == info.Owner && info.PendingOwnerAddress != nil {
+				event.Kind = OwnerChangeRevoked
+				event.Address = *info.PendingOwnerAddress
+			}
 			info.PendingOwnerAddress = newAddress
 		} else { // info.PendingOwnerAddress != nil
 			// Confirm the proposal.
@@ -248,7 +413,12 @@ func (a Actor) ChangeOwnerAddress(rt Runtime, newAddress *addr.Address) *abi.Emp
 				rt.Abortf(exitcode.ErrIllegalArgument, "expected confirmation of %v, got %v",
 					info.PendingOwnerAddress, newAddress)
 			}
+			// An owner that is its own beneficiary passes that role to the new owner.
+			if info.Beneficiary == info.Owner {
+				info.Beneficiary = *info.PendingOwnerAddress
+			}
 			info.Owner = *info.PendingOwnerAddress
+			event.Kind = OwnerChangeConfirmed
 		}
 
 		// Clear any resulting no-op change.
@@ -256,12 +426,152 @@ func (a Actor) ChangeOwnerAddress(rt Runtime, newAddress *addr.Address) *abi.Emp
 			info.PendingOwnerAddress = nil
 		}
 
+		// Proposing the current owner with nothing pending changes nothing, so isn't recorded.
+		if event.Kind != OwnerChangeProposed || *newAddress != info.Owner {
+			err := st.recordControlChanges(adt.AsStore(rt), event)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record owner change")
+		}
+
+		err := st.SaveInfo(adt.AsStore(rt), info)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save miner info")
+	})
+	return nil
+}
+
+type ChangeOwnerSettingsParams = OwnerSettings
+
+// Sets the thresholds enforced on messages committing sectors, replacing any set before.
+// Only the owner may change them.
+func (a Actor) ChangeOwnerSettings(rt Runtime, params *ChangeOwnerSettingsParams) *abi.EmptyValue {
+	err := params.validate()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid owner settings")
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(info.Owner)
+
+		settings := *params
+		st.OwnerSettings = &settings
+	})
+	return nil
+}
+
+type ChangeBeneficiaryParams struct {
+	NewBeneficiary addr.Address
+	NewQuota       abi.TokenAmount
+	NewExpiration  abi.ChainEpoch
+}
+
+// Proposes or approves a change of the beneficiary to which withdrawn funds are paid.
+// The owner proposes a new beneficiary and term, replacing any pending proposal. A beneficiary other than
+// the owner must be given a positive quota, while the owner itself takes no quota or expiration.
+// The current beneficiary and the nominee each approve the proposal by repeating it, and the change
+// takes effect once both have approved.
+func (a Actor) ChangeBeneficiary(rt Runtime, params *ChangeBeneficiaryParams) *abi.EmptyValue {
+	newBeneficiary, ok := rt.ResolveAddress(params.NewBeneficiary)
+	if !ok {
+		rt.Abortf(exitcode.ErrIllegalArgument, "unable to resolve address %v", params.NewBeneficiary)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		caller := rt.Caller()
+
+		if caller == info.Owner {
+			rt.ValidateImmediateCallerIs(info.Owner)
+			if newBeneficiary != info.Owner {
+				if !params.NewQuota.GreaterThan(big.Zero()) {
+					rt.Abortf(exitcode.ErrIllegalArgument, "beneficiary quota %v must be positive", params.NewQuota)
+				}
+				if params.NewExpiration <= rt.CurrEpoch() {
+					rt.Abortf(exitcode.ErrIllegalArgument, "beneficiary expiration %d must be after current epoch %d",
+						params.NewExpiration, rt.CurrEpoch())
+				}
+			} else if !params.NewQuota.IsZero() || params.NewExpiration != 0 {
+				rt.Abortf(exitcode.ErrIllegalArgument, "owner as beneficiary takes no quota or expiration, got %v and %d",
+					params.NewQuota, params.NewExpiration)
+			}
+			info.PendingBeneficiaryTerm = &PendingBeneficiaryChange{
+				NewBeneficiary:        newBeneficiary,
+				NewQuota:              params.NewQuota,
+				NewExpiration:         params.NewExpiration,
+				ApprovedByBeneficiary: !info.BeneficiaryTerm.Available(rt.CurrEpoch()).GreaterThan(big.Zero()),
+				ApprovedByNominee:     false,
+			}
+		} else {
+			pending := info.PendingBeneficiaryTerm
+			if pending == nil {
+				rt.Abortf(exitcode.ErrForbidden, "no beneficiary change proposed")
+			}
+			rt.ValidateImmediateCallerIs(info.Beneficiary, pending.NewBeneficiary)
+			if newBeneficiary != pending.NewBeneficiary || !params.NewQuota.Equals(pending.NewQuota) ||
+				params.NewExpiration != pending.NewExpiration {
+				rt.Abortf(exitcode.ErrIllegalArgument, "expected approval of %v with quota %v expiring at %d",
+					pending.NewBeneficiary, pending.NewQuota, pending.NewExpiration)
+			}
+			if caller == info.Beneficiary {
+				pending.ApprovedByBeneficiary = true
+			}
+			if caller == pending.NewBeneficiary {
+				pending.ApprovedByNominee = true
+			}
+		}
+
+		pending := info.PendingBeneficiaryTerm
+		if pending.ApprovedByBeneficiary && (pending.ApprovedByNominee || pending.NewBeneficiary == info.Owner) {
+			info.Beneficiary = pending.NewBeneficiary
+			info.BeneficiaryTerm = BeneficiaryTerm{
+				Quota:      pending.NewQuota,
+				UsedQuota:  big.Zero(),
+				Expiration: pending.NewExpiration,
+			}
+			info.PendingBeneficiaryTerm = nil
+		}
+
 		err := st.SaveInfo(adt.AsStore(rt), info)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save miner info")
 	})
 	return nil
 }
 
+type GetControlChangeHistoryReturn struct {
+	Events []ControlChangeEvent
+}
+
+// Returns the miner's most recent owner and worker change events, oldest first.
+func (a Actor) GetControlChangeHistory(rt Runtime, _ *abi.EmptyValue) *GetControlChangeHistoryReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	var st State
+	rt.StateReadonly(&st)
+	events, err := st.LoadControlChangeHistory(adt.AsStore(rt))
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load control change history")
+	return &GetControlChangeHistoryReturn{Events: events}
+}
+
+type ActiveBeneficiary struct {
+	Beneficiary addr.Address
+	Term        BeneficiaryTerm
+}
+
+type GetBeneficiaryReturn struct {
+	Active   ActiveBeneficiary
+	Proposed *PendingBeneficiaryChange
+}
+
+// Returns the miner's beneficiary and its term, and any proposed change to them.
+func (a Actor) GetBeneficiary(rt Runtime, _ *abi.EmptyValue) *GetBeneficiaryReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	var st State
+	rt.StateReadonly(&st)
+	info := getMinerInfo(rt, &st)
+	return &GetBeneficiaryReturn{
+		Active:   ActiveBeneficiary{Beneficiary: info.Beneficiary, Term: info.BeneficiaryTerm},
+		Proposed: info.PendingBeneficiaryTerm,
+	}
+}
+
 //type ChangePeerIDParams struct {
 //	NewID abi.PeerID
 //}
@@ -304,6 +614,51 @@ func (a Actor) ChangeMultiaddrs(rt Runtime, params *ChangeMultiaddrsParams) *abi
 	return nil
 }
 
+type ChangeWindowPoStProofTypeParams struct {
+	NewProofType abi.RegisteredPoStProof
+}
+
+// Changes the proof type with which the miner proves its storage, e.g. to migrate to a newer proof version
+// without creating a new miner actor and giving up its address and peer info.
+// The miner must have no sectors, so that none are bound to the prior proof type.
+// May only be invoked by the owner.
+func (a Actor) ChangeWindowPoStProofType(rt Runtime, params *ChangeWindowPoStProofTypeParams) *abi.EmptyValue {
+	if !CanWindowPoStProof(params.NewProofType) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "proof type %d not allowed for miner actors", params.NewProofType)
+	}
+	sectorSize, err := params.NewProofType.SectorSize()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid proof type %d", params.NewProofType)
+	partitionSectors, err := builtin.PoStProofWindowPoStPartitionSectors(params.NewProofType)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid proof type %d", params.NewProofType)
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(info.Owner)
+
+		if params.NewProofType == info.WindowPoStProofType {
+			rt.Abortf(exitcode.ErrIllegalArgument, "miner already uses proof type %d", params.NewProofType)
+		}
+
+		hasSectors, err := st.HasSectors(adt.AsStore(rt))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check for sectors")
+		if hasSectors {
+			rt.Abortf(exitcode.ErrForbidden, "cannot change proof type of miner with sectors")
+		}
+
+		info.WindowPoStProofType = params.NewProofType
+		info.SectorSize = sectorSize
+		info.WindowPoStPartitionSectors = partitionSectors
+		err = st.SaveInfo(adt.AsStore(rt), info)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
+	})
+
+	code := rt.Send(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedProofType,
+		&power.UpdateClaimedProofTypeParams{NewProofType: params.NewProofType}, big.Zero(), &builtin.Discard{})
+	builtin.RequireSuccess(rt, code, "failed to update claimed proof type")
+	return nil
+}
+
 //////////////////
 // WindowedPoSt //
 //////////////////
@@ -334,8 +689,144 @@ type PoStPartition = miner0.PoStPartition
 //}
 type SubmitWindowedPoStParams = miner0.SubmitWindowedPoStParams
 
-// Invoked by miner's worker address to submit their fallback post
-func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams) *abi.EmptyValue {
+// Summary of the effect of an accepted Window PoSt submission.
+type SubmitWindowedPoStReturn struct {
+	// Sectors that became faulty because they were skipped in the proof.
+	NewFaultySectors uint64
+	// Sectors declared for recovery that were recovered by the proof.
+	RecoveredSectors uint64
+	// Sectors declared skipped by the submitter, including any already faulty.
+	SkippedSectors uint64
+	// Power activated or deactivated by the submission.
+	PowerDelta PowerPair
+	// Whether all partitions submitted had already been proven at the deadline, so the submission
+	// was accepted without effect.
+	AlreadyProven bool
+}
+
+// Invoked by miner's worker address to submit their fallback post.
+// A submission for partitions that have all already been proven at the deadline, such as a resubmission
+// of an accepted proof, succeeds without processing the proof or any skipped sectors, and reports AlreadyProven.
+// A submission that proves only some of its partitions a second time is rejected with ErrPartitionAlreadyProven.
+func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams) *SubmitWindowedPoStReturn {
+	return submitWindowedPoSt(rt, params, false, func(st *State, info *MinerInfo) {
+		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+	})
+}
+
+type SubmitWindowedPoStAggregateParams struct {
+	// The deadline index which the submission targets.
+	Deadline uint64
+	// The partitions being proven.
+	Partitions []PoStPartition
+	// Aggregate of one proof for each partition, of the miner's Window PoSt proof type.
+	AggregateProof proof.PoStProof
+	// The epoch at which the proofs are being committed to a particular chain.
+	ChainCommitEpoch abi.ChainEpoch
+	// The ticket randomness on the chain at the chain commit epoch.
+	ChainCommitRand abi.Randomness
+}
+
+// Submits a Window PoSt for up to MaxAggregatedWindowPoStPartitions partitions of the current deadline as
+// a single aggregate proof, so that a miner with many partitions may prove them in fewer messages.
+// The aggregate is verified at once rather than recorded for optimistic verification, so may not be disputed.
+// Only the current deadline is open for proving, so an aggregate can't span deadlines.
+func (a Actor) SubmitWindowedPoStAggregate(rt Runtime, params *SubmitWindowedPoStAggregateParams) *SubmitWindowedPoStReturn {
+	return submitWindowedPoSt(rt, &SubmitWindowedPoStParams{
+		Deadline:         params.Deadline,
+		Partitions:       params.Partitions,
+		Proofs:           []proof.PoStProof{params.AggregateProof},
+		ChainCommitEpoch: params.ChainCommitEpoch,
+		ChainCommitRand:  params.ChainCommitRand,
+	}, true, func(st *State, info *MinerInfo) {
+		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+	})
+}
+
+// A Window PoSt relayed on behalf of the miner's worker, with the worker's signature over the PoSt's intent.
+type SubmitWindowedPoStRelayedParams struct {
+	PoSt SubmitWindowedPoStParams
+	// Must match the miner's PoStRelayNonce.
+	Nonce uint64
+	// The worker's signature over the PoStIntent for the submission, in SigningDomainPoStIntent.
+	Signature crypto.Signature
+}
+
+// Prefix of the payload a worker signs to have a Window PoSt relayed. It separates the domain of these
+// signatures from that of other messages the worker signs, as the market's signing domains do.
+const SigningDomainPoStIntent = "fil-miner-post-intent:"
+
+// The content of a relayed Window PoSt signed by the miner's worker.
+type PoStIntent struct {
+	Miner      addr.Address
+	Deadline   uint64
+	Partitions []PoStPartition
+	// Blake2b-256 digest of the serialized proofs.
+	ProofsHash       []byte
+	ChainCommitEpoch abi.ChainEpoch
+	Nonce            uint64
+}
+
+// Accepts a Window PoSt from any caller, provided it carries the worker's signature over its intent.
+// This allows a third party to pay the gas for a PoSt when the worker's account can't, such as
+// during a fee spike. The nonce prevents a signed intent from being replayed.
+func (a Actor) SubmitWindowedPoStRelayed(rt Runtime, params *SubmitWindowedPoStRelayedParams) *SubmitWindowedPoStReturn {
+	return submitWindowedPoSt(rt, &params.PoSt, false, func(st *State, info *MinerInfo) {
+		rt.ValidateImmediateCallerAcceptAny()
+		if params.Nonce != st.PoStRelayNonce {
+			rt.Abortf(exitcode.ErrIllegalArgument, "invalid relay nonce %d, expected %d", params.Nonce, st.PoStRelayNonce)
+		}
+
+		proofsHash, err := hashPoStProofs(rt, params.PoSt.Proofs)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to hash proofs")
+		intent := PoStIntent{
+			Miner:            rt.Receiver(),
+			Deadline:         params.PoSt.Deadline,
+			Partitions:       params.PoSt.Partitions,
+			ProofsHash:       proofsHash,
+			ChainCommitEpoch: params.PoSt.ChainCommitEpoch,
+			Nonce:            params.Nonce,
+		}
+		signed, err := market.SigningBytes(SigningDomainPoStIntent, &intent)
+		builtin.RequireNoErr(rt, err, exitcode.ErrSerialization, "failed to serialize PoSt intent")
+		err = rt.VerifySignature(params.Signature, info.Worker, signed)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid PoSt intent signature")
+
+		st.PoStRelayNonce++
+	})
+}
+
+// Whether a non-empty set of submitted partitions have all been proven at a deadline.
+func allPartitionsPoSted(dl *Deadline, postPartitions []PoStPartition) (bool, error) {
+	if len(postPartitions) == 0 {
+		return false, nil
+	}
+	for _, post := range postPartitions {
+		if proven, err := dl.PartitionsPoSted.IsSet(post.Index); err != nil {
+			return false, err
+		} else if !proven {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Returns the digest of Window PoSt proofs committed to by a PoStIntent.
+func hashPoStProofs(rt Runtime, proofs []proof.PoStProof) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for i := range proofs {
+		if err := proofs[i].MarshalCBOR(&buf); err != nil {
+			return nil, err
+		}
+	}
+	digest := rt.HashBlake2b(buf.Bytes())
+	return digest[:], nil
+}
+
+// Processes a Window PoSt submission. The authorize function validates the caller, and may
+// update state, once the miner's info is loaded.
+// If aggregate is set, the single proof is an aggregate of the partitions' proofs and is verified at once.
+func submitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams, aggregate bool, authorize func(st *State, info *MinerInfo)) *SubmitWindowedPoStReturn {
 	currEpoch := rt.CurrEpoch()
 	store := adt.AsStore(rt)
 	var st State
@@ -361,12 +852,13 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 
 	var postResult *PoStResult
 	var info *MinerInfo
+	alreadyProven := false
 	rt.StateTransaction(&st, func() {
 		info = getMinerInfo(rt, &st)
 		maxProofSize, err := info.WindowPoStProofType.ProofSize()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to determine max window post proof size")
 
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		authorize(&st, info)
 
 		// Make sure the miner is using the correct proof type.
 		if params.Proofs[0].PoStProof != info.WindowPoStProofType {
@@ -374,12 +866,19 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 		}
 
 		// Make sure the proof size doesn't exceed the max. We could probably check for an exact match, but this is safer.
-		if maxSize := maxProofSize * uint64(len(params.Partitions)); uint64(len(params.Proofs[0].ProofBytes)) > maxSize {
+		maxSize := maxProofSize * uint64(len(params.Partitions))
+		if aggregate {
+			maxSize = MaxAggregateWindowPoStProofSize
+		}
+		if uint64(len(params.Proofs[0].ProofBytes)) > maxSize {
 			rt.Abortf(exitcode.ErrIllegalArgument, "expected proof to be smaller than %d bytes", maxSize)
 		}
 
 		// Validate that the miner didn't try to prove too many partitions at once.
 		submissionPartitionLimit := loadPartitionsSectorsMax(info.WindowPoStPartitionSectors)
+		if aggregate {
+			submissionPartitionLimit = MaxAggregatedWindowPoStPartitions
+		}
 		if uint64(len(params.Partitions)) > submissionPartitionLimit {
 			rt.Abortf(exitcode.ErrIllegalArgument, "too many partitions %d, limit %d", len(params.Partitions), submissionPartitionLimit)
 		}
@@ -395,13 +894,14 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 
 		// The miner may only submit a proof for the current deadline.
 		if params.Deadline != currDeadline.Index {
-			rt.Abortf(exitcode.ErrIllegalArgument, "invalid deadline %d at epoch %d, expected %d",
+			rt.Abortf(ErrWrongDeadline, "invalid deadline %d at epoch %d, expected %d",
 				params.Deadline, currEpoch, currDeadline.Index)
 		}
 
-		// Verify that the PoSt was committed to the chain at most WPoStChallengeLookback+WPoStChallengeWindow in the past.
-		if params.ChainCommitEpoch < currDeadline.Challenge {
-			rt.Abortf(exitcode.ErrIllegalArgument, "expected chain commit epoch %d to be after %d", params.ChainCommitEpoch, currDeadline.Challenge)
+		// Verify that the PoSt was committed to the chain at most WPoStChainCommitLookback before the deadline's
+		// challenge epoch, and so at most WPoStChainCommitLookback+WPoStChallengeLookback+WPoStChallengeWindow in the past.
+		if earliest := currDeadline.Challenge - WPoStChainCommitLookback; params.ChainCommitEpoch < earliest {
+			rt.Abortf(exitcode.ErrIllegalArgument, "expected chain commit epoch %d to be after %d", params.ChainCommitEpoch, earliest)
 		}
 		if params.ChainCommitEpoch >= currEpoch {
 			rt.Abortf(exitcode.ErrIllegalArgument, "chain commit epoch %d must be less than the current epoch %d", params.ChainCommitEpoch, currEpoch)
@@ -421,6 +921,12 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 		deadline, err := deadlines.LoadDeadline(store, params.Deadline)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.Deadline)
 
+		alreadyProven, err = allPartitionsPoSted(deadline, params.Partitions)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check proven partitions for deadline %d", params.Deadline)
+		if alreadyProven {
+			return
+		}
+
 		// Record proven sectors/partitions, returning updates to power and the final set of sectors
 		// proven/skipped.
 		//
@@ -449,9 +955,19 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 			rt.Abortf(exitcode.ErrIllegalArgument, "cannot prove partitions with no active sectors")
 		}
 
-		// If we're not recovering power, record the proof for optimistic verification.
-		if postResult.RecoveredPower.IsZero() {
-			err = deadline.RecordPoStProofs(store, postResult.Partitions, params.Proofs)
+		// An aggregate is always checked, since the proofs of individual partitions can't be disputed.
+		// Otherwise, if we're not recovering power, record the proof for optimistic verification.
+		if aggregate {
+			sectorInfos, err := sectors.LoadForProof(postResult.Sectors, postResult.IgnoredSectors)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors for post verification")
+
+			partitionCount, err := postResult.Partitions.Count()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count proven partitions")
+
+			err = verifyAggregateWindowedPost(rt, currDeadline.Challenge, sectorInfos, partitionCount, params.Proofs[0])
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "aggregate window post failed")
+		} else if postResult.RecoveredPower.IsZero() {
+			err = deadline.RecordPoStProofs(store, postResult.Partitions, params.Proofs, params.ChainCommitEpoch)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record proof for optimistic verification", params.Deadline)
 		} else {
 			// otherwise, check the proof
@@ -469,17 +985,29 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
 	})
 
+	if alreadyProven {
+		return &SubmitWindowedPoStReturn{
+			PowerDelta:    NewPowerPairZero(),
+			AlreadyProven: true,
+		}
+	}
+
 	// Restore power for recovered sectors. Remove power for new faults.
 	// NOTE: It would be permissible to delay the power loss until the deadline closes, but that would require
 	// additional accounting state.
 	// https://github.com/filecoin-project/specs-actors/issues/414
-	requestUpdatePower(rt, postResult.PowerDelta)
+	requestUpdatePower(rt, postResult.PowerDelta, postResult.NewFaultyPower.Sub(postResult.RecoveredPower))
 
 	rt.StateReadonly(&st)
 	err := st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
 
-	return nil
+	return &SubmitWindowedPoStReturn{
+		NewFaultySectors: postResult.NewFaultySectors,
+		RecoveredSectors: postResult.RecoveredSectors,
+		SkippedSectors:   postResult.SkippedSectors,
+		PowerDelta:       postResult.PowerDelta,
+	}
 }
 
 // type DisputeWindowedPoStParams struct {
@@ -510,6 +1038,7 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 	toReward := abi.NewTokenAmount(0)
 	pledgeDelta := abi.NewTokenAmount(0)
 	powerDelta := NewPowerPairZero()
+	faultyPowerDelta := NewPowerPairZero()
 	var st State
 	rt.StateTransaction(&st, func() {
 		dlInfo := st.DeadlineInfo(currEpoch)
@@ -541,7 +1070,7 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 			// This operation REMOVES the PoSt from the snapshot so
 			// it can't be disputed again. If this method fails,
 			// this operation must be rolled back.
-			partitions, proofs, err := dlCurrent.TakePoStProofs(store, params.PoStIndex)
+			partitions, proofs, chainCommitEpoch, err := dlCurrent.TakePoStProofs(store, params.PoStIndex)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load proof for dispute")
 
 			// Load the partition info we need for the dispute.
@@ -558,8 +1087,16 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 			sectorInfos, err := sectors.LoadForProof(disputeInfo.AllSectorNos, disputeInfo.IgnoredSectorNos)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors to dispute window post")
 
-			// Check proof, we fail if validation succeeds.
-			err = verifyWindowedPost(rt, targetDeadline.Challenge, sectorInfos, proofs)
+			// Check the chain commitment and then the proof, we fail if validation succeeds.
+			// Submissions recorded before the chain commit epoch was tracked were committed at
+			// or after the challenge epoch.
+			if chainCommitEpoch == NoChainCommitEpoch {
+				chainCommitEpoch = targetDeadline.Challenge
+			}
+			err = validateChainCommitEpoch(targetDeadline, chainCommitEpoch)
+			if err == nil {
+				err = verifyWindowedPost(rt, targetDeadline.Challenge, sectorInfos, proofs)
+			}
 			if err == nil {
 				rt.Abortf(exitcode.ErrIllegalArgument, "failed to dispute valid post")
 				return
@@ -573,8 +1110,10 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 			// However, some of these sectors may have been
 			// terminated. That's fine, we'll skip them.
 			faultExpirationEpoch := targetDeadline.Last() + FaultMaxAge
+			faultyBefore := dlCurrent.FaultyPower
 			powerDelta, err = dlCurrent.RecordFaults(store, sectors, info.SectorSize, QuantSpecForDeadline(targetDeadline), faultExpirationEpoch, disputeInfo.DisputedSectors)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare faults")
+			faultyPowerDelta = dlCurrent.FaultyPower.Sub(faultyBefore)
 
 			err = deadlinesCurrent.UpdateDeadline(store, params.Deadline, dlCurrent)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", params.Deadline)
@@ -616,7 +1155,7 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 		}
 	})
 
-	requestUpdatePower(rt, powerDelta)
+	requestUpdatePower(rt, powerDelta, faultyPowerDelta)
 
 	if !toReward.IsZero() {
 		// Try to send the reward to the reporter.
@@ -629,7 +1168,7 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 		}
 	}
 	burnFunds(rt, toBurn, BurnMethodDisputeWindowedPoSt)
-	notifyPledgeChanged(rt, pledgeDelta)
+	notifyPledgeChanged(rt, big.Zero(), big.Zero(), pledgeDelta)
 	rt.StateReadonly(&st)
 
 	err := st.CheckBalanceInvariants(rt.CurrentBalance())
@@ -660,8 +1199,7 @@ type PreCommitSectorParams = miner0.SectorPreCommitInfo
 // This method may be deprecated and removed in the future.
 func (a Actor) PreCommitSector(rt Runtime, params *PreCommitSectorParams) *abi.EmptyValue {
 	// This is a direct method call to self, not a message send.
-	batchParams := &PreCommitSectorBatchParams{Sectors: []miner0.SectorPreCommitInfo{*params}}
-	a.PreCommitSectorBatch(rt, batchParams)
+	preCommitSectorBatch(rt, []miner0.SectorPreCommitInfo{*params}, nil, false)
 	return nil
 }
 
@@ -676,121 +1214,183 @@ type PreCommitSectorBatchParams = miner5.PreCommitSectorBatchParams
 // to the storage market actor.
 // This method calculates the sector's power, locks a pre-commit deposit for the sector, stores information about the
 // sector in state and waits for it to be proven or expire.
+// The whole batch is aborted if any sector fails validation. See PreCommitSectorBatch2 to skip invalid sectors.
 func (a Actor) PreCommitSectorBatch(rt Runtime, params *PreCommitSectorBatchParams) *abi.EmptyValue {
+	checkPreCommitBatchSettings(rt, len(params.Sectors))
+	preCommitSectorBatch(rt, params.Sectors, nil, false)
+	return nil
+}
+
+type PreCommitSectorBatch2Params struct {
+	Sectors []miner0.SectorPreCommitInfo
+	// Optional manifests of the piece layout of sectors in the batch, at most one per sector.
+	Manifests []SectorManifest
+}
+
+type PreCommitSectorBatchReturn struct {
+	// Indices in the batch of sectors that failed validation and were not pre-committed.
+	FailedSectors bitfield.BitField
+	// Numbers of the sectors that were pre-committed.
+	AcceptedSectors bitfield.BitField
+}
+
+// Pre-commits a batch of sectors as PreCommitSectorBatch, except that a sector that fails validation
+// is dropped from the batch and reported in the return value, while the remaining sectors are pre-committed.
+// A sector whose number is already allocated, whose deals the market actor fails to verify, or whose deposit
+// the available balance cannot cover after that of the sectors before it in the batch, also fails validation.
+// The batch is aborted only if no sector is valid.
+// Manifests of the piece layout of sectors may be recorded with them.
+func (a Actor) PreCommitSectorBatch2(rt Runtime, params *PreCommitSectorBatch2Params) *PreCommitSectorBatchReturn {
+	checkPreCommitBatchSettings(rt, len(params.Sectors))
+	failed, accepted := preCommitSectorBatch(rt, params.Sectors, params.Manifests, true)
+	return &PreCommitSectorBatchReturn{
+		FailedSectors:   failed,
+		AcceptedSectors: accepted,
+	}
+}
+
+func checkPreCommitBatchSettings(rt Runtime, batchSize int) {
+	var st State
+	rt.StateReadonly(&st)
+	settings := st.GetOwnerSettings()
+	err := settings.checkPreCommitBatch(uint64(batchSize), rt.BaseFee())
+	builtin.RequireNoErr(rt, err, exitcode.ErrForbidden, "pre-commit batch refused by owner settings")
+}
+
+// Pre-commits the sectors of a batch, returning the indices of those dropped as invalid and the numbers
+// of those pre-committed. Unless skipInvalid is set, the first invalid sector aborts the whole batch instead.
+func preCommitSectorBatch(rt Runtime, sectors []miner0.SectorPreCommitInfo, manifests []SectorManifest, skipInvalid bool) (failed bitfield.BitField, accepted bitfield.BitField) {
 	currEpoch := rt.CurrEpoch()
-	if len(params.Sectors) == 0 {
+	if len(sectors) == 0 {
 		rt.Abortf(exitcode.ErrIllegalArgument, "batch empty")
-	} else if len(params.Sectors) > PreCommitSectorBatchMaxSize {
-		rt.Abortf(exitcode.ErrIllegalArgument, "batch of %d too large, max %d", len(params.Sectors), PreCommitSectorBatchMaxSize)
+	} else if len(sectors) > PreCommitSectorBatchMaxSize {
+		rt.Abortf(exitcode.ErrIllegalArgument, "batch of %d too large, max %d", len(sectors), PreCommitSectorBatchMaxSize)
 	}
 
+	var st State
+	rt.StateReadonly(&st)
+	info := getMinerInfo(rt, &st)
+
 	// Check per-sector preconditions before opening state transaction or sending other messages.
-	challengeEarliest := currEpoch - MaxPreCommitRandomnessLookback
-	sectorsDeals := make([]market.SectorDeals, len(params.Sectors))
+	failed = bitfield.New()
+	var firstFailure error
+	dropSector := func(i int, err error) {
+		if !skipInvalid {
+			rt.Abortf(exitcode.Unwrap(err, exitcode.ErrIllegalArgument), "invalid pre-commit of sector %d: %s", sectors[i].SectorNumber, err)
+		}
+		rt.Log(rtt.INFO, "invalid pre-commit %d of sector %d: %s", i, sectors[i].SectorNumber, err)
+		failed.Set(uint64(i))
+		if firstFailure == nil {
+			firstFailure = err
+		}
+	}
+	requireValidSectors := func(validCount int) {
+		if validCount == 0 {
+			rt.Abortf(exitcode.Unwrap(firstFailure, exitcode.ErrIllegalArgument), "no valid sectors in batch: %s", firstFailure)
+		}
+	}
+
+	requestedNumbers := bitfield.New()
+	for _, precommit := range sectors {
+		requestedNumbers.Set(uint64(precommit.SectorNumber))
+	}
+	err := validateSectorManifests(manifests, requestedNumbers)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid sector manifests")
+
+	var allocatedSectors bitfield.BitField
+	err = adt.AsStore(rt).Get(rt.Context(), st.AllocatedSectors, &allocatedSectors)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load allocated sectors")
+
+	validIdxs := make([]int, 0, len(sectors))
 	sectorNumbers := bitfield.New()
-	for i, precommit := range params.Sectors {
+	for i := range sectors {
+		precommit := &sectors[i]
 		// Bitfied.IsSet() is fast when there are only locally-set values.
 		set, err := sectorNumbers.IsSet(uint64(precommit.SectorNumber))
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "error checking sector number")
 		if set {
-			rt.Abortf(exitcode.ErrIllegalArgument, "duplicate sector number %d", precommit.SectorNumber)
-		}
-		sectorNumbers.Set(uint64(precommit.SectorNumber))
-
-		if !CanPreCommitSealProof(precommit.SealProof) {
-			rt.Abortf(exitcode.ErrIllegalArgument, "unsupported seal proof type %v", precommit.SealProof)
-		}
-		if precommit.SectorNumber > abi.MaxSectorNumber {
-			rt.Abortf(exitcode.ErrIllegalArgument, "sector number %d out of range 0..(2^63-1)", precommit.SectorNumber)
-		}
-		if !precommit.SealedCID.Defined() {
-			rt.Abortf(exitcode.ErrIllegalArgument, "sealed CID undefined")
-		}
-		if precommit.SealedCID.Prefix() != SealedCIDPrefix {
-			rt.Abortf(exitcode.ErrIllegalArgument, "sealed CID had wrong prefix")
-		}
-		if precommit.SealRandEpoch >= currEpoch {
-			rt.Abortf(exitcode.ErrIllegalArgument, "seal challenge epoch %v must be before now %v", precommit.SealRandEpoch, rt.CurrEpoch())
-		}
-		if precommit.SealRandEpoch < challengeEarliest {
-			rt.Abortf(exitcode.ErrIllegalArgument, "seal challenge epoch %v too old, must be after %v", precommit.SealRandEpoch, challengeEarliest)
+			dropSector(i, exitcode.ErrIllegalArgument.Wrapf("duplicate sector number %d", precommit.SectorNumber))
+			continue
 		}
-
-		// Require sector lifetime meets minimum by assuming activation happens at last epoch permitted for seal proof.
-		// This could make sector maximum lifetime validation more lenient if the maximum sector limit isn't hit first.
-		maxActivation := currEpoch + MaxProveCommitDuration[precommit.SealProof]
-		validateExpiration(rt, maxActivation, precommit.Expiration, precommit.SealProof)
-
-		if precommit.ReplaceCapacity {
-			rt.Abortf(exitcode.SysErrForbidden, "cc upgrade through precommit discontinued, use lightweight cc upgrade instead")
+		if err := validatePreCommit(rt, precommit, info); err != nil {
+			dropSector(i, err)
+			continue
 		}
-
-		sectorsDeals[i] = market.SectorDeals{
-			SectorExpiry: precommit.Expiration,
-			DealIDs:      precommit.DealIDs,
+		allocated, err := allocatedSectors.IsSet(uint64(precommit.SectorNumber))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "error checking sector number allocation")
+		if allocated {
+			dropSector(i, exitcode.ErrIllegalArgument.Wrapf("sector number %d already allocated", precommit.SectorNumber))
+			continue
 		}
+		sectorNumbers.Set(uint64(precommit.SectorNumber))
+		validIdxs = append(validIdxs, i)
 	}
+	requireValidSectors(len(validIdxs))
 
 	// gather information from other actors
 	rewardStats := requestCurrentEpochBlockReward(rt)
 	pwrTotal := requestCurrentTotalPower(rt)
-	dealWeights := requestDealWeights(rt, sectorsDeals)
+	sectorsDeals := make([]market.SectorDeals, len(validIdxs))
+	for vi, i := range validIdxs {
+		sectorsDeals[vi] = market.SectorDeals{
+			SectorExpiry: sectors[i].Expiration,
+			DealIDs:      sectors[i].DealIDs,
+		}
+	}
+	var dealWeights []market.SectorWeights
+	dealCodes := make([]exitcode.ExitCode, len(validIdxs))
+	if skipInvalid {
+		dealWeights, dealCodes = requestDealWeightsIsolatingFailures(rt, sectorsDeals)
+	} else {
+		dealWeights = requestDealWeights(rt, sectorsDeals).Sectors
+	}
 
-	if len(dealWeights.Sectors) != len(params.Sectors) {
+	if len(dealWeights) != len(validIdxs) {
 		rt.Abortf(exitcode.ErrIllegalState, "deal weight request returned %d records, expected %d",
-			len(dealWeights.Sectors), len(params.Sectors))
+			len(dealWeights), len(validIdxs))
 	}
 
+	// Ensure total deal space does not exceed sector size.
+	validSectors := make([]int, 0, len(validIdxs))
+	validWeights := make([]market.SectorWeights, 0, len(validIdxs))
+	for vi, i := range validIdxs {
+		if code := dealCodes[vi]; !code.IsSuccess() {
+			dropSector(i, code.Wrapf("failed to verify deals and get deal weight"))
+			sectorNumbers.Unset(uint64(sectors[i].SectorNumber))
+			continue
+		}
+		dealWeight := dealWeights[vi]
+		if dealWeight.DealSpace > uint64(info.SectorSize) {
+			dropSector(i, exitcode.ErrIllegalArgument.Wrapf("deals too large to fit in sector %d > %d", dealWeight.DealSpace, info.SectorSize))
+			sectorNumbers.Unset(uint64(sectors[i].SectorNumber))
+			continue
+		}
+		validSectors = append(validSectors, i)
+		validWeights = append(validWeights, dealWeight)
+	}
+	requireValidSectors(len(validSectors))
+
 	store := adt.AsStore(rt)
-	var st State
-	var err error
 	feeToBurn := abi.NewTokenAmount(0)
+	totalDepositRequired := big.Zero()
 	var needsCron bool
 	rt.StateTransaction(&st, func() {
-		// Aggregate fee applies only when batching.
-		if len(params.Sectors) > 1 {
-			aggregateFee := AggregatePreCommitNetworkFee(len(params.Sectors), rt.BaseFee())
-			// AggregateFee applied to fee debt to consolidate burn with outstanding debts
-			err := st.ApplyPenalty(aggregateFee)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+
+		if ConsensusFaultActive(info, currEpoch) {
+			rt.Abortf(exitcode.ErrForbidden, "pre-commit not allowed during active consensus fault")
 		}
 
-		// available balance already accounts for fee debt so it is correct to call
-		// this before RepayDebts. We would have to
-		// subtract fee debt explicitly if we called this after.
+		// The balance available for the deposits and aggregate fee of the sectors accepted, net of any fee debt.
 		availableBalance, err := st.GetAvailableBalance(rt.CurrentBalance())
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate available balance")
-		feeToBurn = RepayDebtsOrAbort(rt, &st)
-
-		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
-
-		if ConsensusFaultActive(info, currEpoch) {
-			rt.Abortf(exitcode.ErrForbidden, "pre-commit not allowed during active consensus fault")
-		}
 
-		chainInfos := make([]*SectorPreCommitOnChainInfo, len(params.Sectors))
-		totalDepositRequired := big.Zero()
+		chainInfos := make([]*SectorPreCommitOnChainInfo, 0, len(validSectors))
 		cleanUpEvents := map[abi.ChainEpoch][]uint64{}
-		dealCountMax := SectorDealsMax(info.SectorSize)
-		for i, precommit := range params.Sectors {
-			// Sector must have the same Window PoSt proof type as the miner's recorded seal type.
-			sectorWPoStProof, err := precommit.SealProof.RegisteredWindowPoStProof()
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to lookup Window PoSt proof type for sector seal proof %d", precommit.SealProof)
-			if sectorWPoStProof != info.WindowPoStProofType {
-				rt.Abortf(exitcode.ErrIllegalArgument, "sector Window PoSt proof type %d must match miner Window PoSt proof type %d (seal proof type %d)",
-					sectorWPoStProof, info.WindowPoStProofType, precommit.SealProof)
-			}
-
-			if uint64(len(precommit.DealIDs)) > dealCountMax {
-				rt.Abortf(exitcode.ErrIllegalArgument, "too many deals for sector %d > %d", len(precommit.DealIDs), dealCountMax)
-			}
-
-			// Ensure total deal space does not exceed sector size.
-			dealWeight := dealWeights.Sectors[i]
-			if dealWeight.DealSpace > uint64(info.SectorSize) {
-				rt.Abortf(exitcode.ErrIllegalArgument, "deals too large to fit in sector %d > %d", dealWeight.DealSpace, info.SectorSize)
-			}
+		for vi, i := range validSectors {
+			precommit := sectors[i]
+			dealWeight := validWeights[vi]
 
 			// Estimate the sector weight using the current epoch as an estimate for activation,
 			// and compute the pre-commit deposit using that weight.
@@ -799,32 +1399,60 @@ func (a Actor) PreCommitSectorBatch(rt Runtime, params *PreCommitSectorBatchPara
 			sectorWeight := QAPowerForWeight(info.SectorSize, duration, dealWeight.DealWeight, dealWeight.VerifiedDealWeight)
 			depositReq := PreCommitDepositForPower(rewardStats.ThisEpochRewardSmoothed, pwrTotal.QualityAdjPowerSmoothed, sectorWeight)
 
+			// When skipping invalid sectors, one whose deposit cannot be covered along with those of the sectors
+			// accepted so far and the aggregate fee is dropped. Otherwise the shortfall aborts the batch below.
+			if skipInvalid {
+				aggregateFee := EstimatePreCommitBatchFee(len(chainInfos)+1, rt.BaseFee())
+				if required := big.Sum(totalDepositRequired, depositReq, aggregateFee); availableBalance.LessThan(required) {
+					dropSector(i, exitcode.ErrInsufficientFunds.Wrapf("insufficient funds %v for pre-commit deposit: %v", availableBalance, required))
+					sectorNumbers.Unset(uint64(precommit.SectorNumber))
+					continue
+				}
+			}
+
 			// Build on-chain record.
-			chainInfos[i] = &SectorPreCommitOnChainInfo{
+			chainInfos = append(chainInfos, &SectorPreCommitOnChainInfo{
 				Info:               SectorPreCommitInfo(precommit),
 				PreCommitDeposit:   depositReq,
 				PreCommitEpoch:     currEpoch,
 				DealWeight:         dealWeight.DealWeight,
 				VerifiedDealWeight: dealWeight.VerifiedDealWeight,
-			}
+			})
 			totalDepositRequired = big.Add(totalDepositRequired, depositReq)
 
 			// Calculate pre-commit cleanup
-			msd, ok := MaxProveCommitDuration[precommit.SealProof]
-			if !ok {
-				rt.Abortf(exitcode.ErrIllegalArgument, "no max seal duration set for proof type: %d", precommit.SealProof)
-			}
-			// PreCommitCleanUpDelay > 0 here is critical for the batch verification of proofs. Without it, if a proof arrived exactly on the
-			// due epoch, ProveCommitSector would accept it, then the expiry event would remove it, and then
-			// ConfirmSectorProofsValid would fail to find it.
-			cleanUpBound := currEpoch + msd + ExpiredPreCommitCleanUpDelay
+			cleanUpBound := currEpoch + MaxProveCommitDuration[precommit.SealProof] + ExpiredPreCommitCleanUpDelay
 			cleanUpEvents[cleanUpBound] = append(cleanUpEvents[cleanUpBound], uint64(precommit.SectorNumber))
 		}
+		requireValidSectors(len(chainInfos))
 
-		// Batch update actor state.
+		// Aggregate fee applies only when batching.
+		if aggregateFee := EstimatePreCommitBatchFee(len(chainInfos), rt.BaseFee()); !aggregateFee.IsZero() {
+			// AggregateFee applied to fee debt to consolidate burn with outstanding debts
+			err := st.ApplyPenalty(aggregateFee)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
+		}
+
+		// available balance already accounts for fee debt so it is correct to call
+		// this before RepayDebts. We would have to
+		// subtract fee debt explicitly if we called this after.
+		availableBalance, err = st.GetAvailableBalance(rt.CurrentBalance())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate available balance")
+		feeToBurn = RepayDebtsOrAbort(rt, &st)
 		if availableBalance.LessThan(totalDepositRequired) {
 			rt.Abortf(exitcode.ErrInsufficientFunds, "insufficient funds %v for pre-commit deposit: %v", availableBalance, totalDepositRequired)
 		}
+
+		var validManifests []SectorManifest
+		for _, m := range manifests {
+			valid, err := sectorNumbers.IsSet(uint64(m.SectorNumber))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "error checking sector number")
+			if valid {
+				validManifests = append(validManifests, m)
+			}
+		}
+
+		// Batch update actor state.
 		err = st.AddPreCommitDeposit(totalDepositRequired)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add pre-commit deposit %v", totalDepositRequired)
 
@@ -834,6 +1462,9 @@ func (a Actor) PreCommitSectorBatch(rt Runtime, params *PreCommitSectorBatchPara
 		err = st.PutPrecommittedSectors(store, chainInfos...)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to write pre-committed sectors")
 
+		err = st.PutSectorManifests(store, validManifests...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to write sector manifests")
+
 		err = st.AddPreCommitCleanUps(store, cleanUpEvents)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add pre-commit expiry to queue")
 
@@ -843,16 +1474,64 @@ func (a Actor) PreCommitSectorBatch(rt Runtime, params *PreCommitSectorBatchPara
 	})
 
 	burnFunds(rt, feeToBurn, BurnMethodPreCommitSectorBatch)
+	notifyPledgeChanged(rt, big.Zero(), totalDepositRequired, big.Zero())
 	rt.StateReadonly(&st)
 	err = st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
 	if needsCron {
 		newDlInfo := st.DeadlineInfo(currEpoch)
-		enrollCronEvent(rt, newDlInfo.Last(), &CronEventPayload{
-			EventType: CronEventProvingDeadline,
-		})
+		enrollCronEvent(rt, newDlInfo.Last(), CronEventProvingDeadline)
+	}
+	return failed, sectorNumbers
+}
+
+// Checks the preconditions of pre-committing a sector that do not depend on other sectors or actors.
+func validatePreCommit(rt Runtime, precommit *miner0.SectorPreCommitInfo, info *MinerInfo) error {
+	currEpoch := rt.CurrEpoch()
+	if !CanPreCommitSealProof(precommit.SealProof) {
+		return exitcode.ErrIllegalArgument.Wrapf("unsupported seal proof type %v", precommit.SealProof)
+	}
+	if precommit.SectorNumber > abi.MaxSectorNumber {
+		return exitcode.ErrIllegalArgument.Wrapf("sector number %d out of range 0..(2^63-1)", precommit.SectorNumber)
+	}
+	if !precommit.SealedCID.Defined() {
+		return exitcode.ErrIllegalArgument.Wrapf("sealed CID undefined")
+	}
+	if precommit.SealedCID.Prefix() != SealedCIDPrefix {
+		return exitcode.ErrIllegalArgument.Wrapf("sealed CID had wrong prefix")
+	}
+	if precommit.SealRandEpoch >= currEpoch {
+		return exitcode.ErrIllegalArgument.Wrapf("seal challenge epoch %v must be before now %v", precommit.SealRandEpoch, currEpoch)
+	}
+	challengeEarliest := currEpoch - MaxPreCommitRandomnessLookback
+	if precommit.SealRandEpoch < challengeEarliest {
+		return exitcode.ErrIllegalArgument.Wrapf("seal challenge epoch %v too old, must be after %v", precommit.SealRandEpoch, challengeEarliest)
+	}
+
+	// Require sector lifetime meets minimum by assuming activation happens at last epoch permitted for seal proof.
+	// This could make sector maximum lifetime validation more lenient if the maximum sector limit isn't hit first.
+	maxActivation := currEpoch + MaxProveCommitDuration[precommit.SealProof]
+	if err := checkExpiration(currEpoch, maxActivation, precommit.Expiration, precommit.SealProof); err != nil {
+		return exitcode.ErrIllegalArgument.Wrapf("invalid sector expiration: %w", err)
+	}
+
+	if precommit.ReplaceCapacity {
+		return exitcode.SysErrForbidden.Wrapf("cc upgrade through precommit discontinued, use lightweight cc upgrade instead")
 	}
 
+	// Sector must have the same Window PoSt proof type as the miner's recorded seal type.
+	sectorWPoStProof, err := precommit.SealProof.RegisteredWindowPoStProof()
+	if err != nil {
+		return exitcode.ErrIllegalArgument.Wrapf("failed to lookup Window PoSt proof type for sector seal proof %d: %w", precommit.SealProof, err)
+	}
+	if sectorWPoStProof != info.WindowPoStProofType {
+		return exitcode.ErrIllegalArgument.Wrapf("sector Window PoSt proof type %d must match miner Window PoSt proof type %d (seal proof type %d)",
+			sectorWPoStProof, info.WindowPoStProofType, precommit.SealProof)
+	}
+
+	if dealCountMax := SectorDealsMax(info.SectorSize); uint64(len(precommit.DealIDs)) > dealCountMax {
+		return exitcode.ErrIllegalArgument.Wrapf("too many deals for sector %d > %d", len(precommit.DealIDs), dealCountMax)
+	}
 	return nil
 }
 
@@ -886,12 +1565,20 @@ func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregatePara
 	info := getMinerInfo(rt, &st)
 	rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
 
+	// The network fee is checked for all addressed sectors, an upper bound on the fee for those proven.
+	settings := st.GetOwnerSettings()
+	err = settings.checkSectorCount(aggSectorsCount)
+	builtin.RequireNoErr(rt, err, exitcode.ErrForbidden, "aggregate refused by owner settings")
+	err = settings.checkBatchNetworkFee(AggregateProveCommitNetworkFee(int(aggSectorsCount), rt.BaseFee()))
+	builtin.RequireNoErr(rt, err, exitcode.ErrForbidden, "aggregate refused by owner settings")
+
 	precommits, err := st.GetAllPrecommittedSectors(store, params.SectorNumbers)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get precommits")
 
 	// compute data commitments and validate each precommit
 	computeDataCommitmentsInputs := make([]*market.SectorDataSpec, len(precommits))
 	precommitsToConfirm := []*SectorPreCommitOnChainInfo{}
+	var lateSectorNos []abi.SectorNumber
 	for i, precommit := range precommits {
 		msd, ok := MaxProveCommitDuration[precommit.Info.SealProof]
 		if !ok {
@@ -900,6 +1587,7 @@ func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregatePara
 		proveCommitDue := precommit.PreCommitEpoch + msd
 		if rt.CurrEpoch() > proveCommitDue {
 			rt.Log(rtt.WARN, "skipping commitment for sector %d, too late at %d, due %d", precommit.Info.SectorNumber, rt.CurrEpoch(), proveCommitDue)
+			lateSectorNos = append(lateSectorNos, precommit.Info.SectorNumber)
 		} else {
 			precommitsToConfirm = append(precommitsToConfirm, precommit)
 		}
@@ -959,7 +1647,27 @@ func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregatePara
 	rew := requestCurrentEpochBlockReward(rt)
 	pwr := requestCurrentTotalPower(rt)
 
-	confirmSectorProofsValid(rt, precommitsToConfirm, rew.ThisEpochBaselinePower, rew.ThisEpochRewardSmoothed, pwr.QualityAdjPowerSmoothed)
+	validPreCommits := activatePreCommitDeals(rt, precommitsToConfirm)
+	// When all prove commits have failed abort early
+	if len(validPreCommits) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "all prove commits failed to validate")
+	}
+	confirmSectorProofsValid(rt, validPreCommits, rew.ThisEpochBaselinePower, rew.ThisEpochRewardSmoothed, pwr.QualityAdjPowerSmoothed)
+
+	// The sectors proven too late have nonetheless been proven, so release their deposits now rather than
+	// leaving them all to be burnt at clean up. Part is refunded and the rest burnt with the aggregate fee.
+	latePenalty := big.Zero()
+	if len(lateSectorNos) > 0 && LatePreCommitDepositRefund.Numerator.GreaterThan(big.Zero()) {
+		var lateDeposit abi.TokenAmount
+		rt.StateTransaction(&st, func() {
+			lateDeposit, err = st.DiscardPreCommittedSectors(store, rt.CurrEpoch(), lateSectorNos...)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to discard late pre-committed sectors")
+			err = st.AddPreCommitDeposit(lateDeposit.Neg())
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to release pre-commit deposit %v", lateDeposit)
+		})
+		latePenalty = big.Sub(lateDeposit, LatePreCommitDepositRefundAmount(lateDeposit))
+		notifyPledgeChanged(rt, big.Zero(), lateDeposit.Neg(), big.Zero())
+	}
 
 	// Compute and burn the aggregate network fee. We need to re-load the state as
 	// confirmSectorProofsValid can change it.
@@ -967,13 +1675,13 @@ func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregatePara
 	aggregateFee := AggregateProveCommitNetworkFee(len(precommitsToConfirm), rt.BaseFee())
 	unlockedBalance, err := st.GetUnlockedBalance(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to determine unlocked balance")
-	if unlockedBalance.LessThan(aggregateFee) {
+	if unlockedBalance.LessThan(big.Add(aggregateFee, latePenalty)) {
 		rt.Abortf(exitcode.ErrInsufficientFunds,
 			"remaining unlocked funds after prove-commit (%s) are insufficient to pay aggregation fee of %s",
 			unlockedBalance, aggregateFee,
 		)
 	}
-	burnFunds(rt, aggregateFee, BurnMethodProveCommitAggregate)
+	burnFunds(rt, big.Add(aggregateFee, latePenalty), BurnMethodProveCommitAggregate)
 
 	err = st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
@@ -981,6 +1689,207 @@ func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregatePara
 	return nil
 }
 
+type ProveCommitSectorsNIParams struct {
+	Sectors        []SectorNIActivationInfo
+	SealProof      abi.RegisteredSealProof
+	AggregateProof []byte
+}
+
+// A committed-capacity sector to be activated without pre-commitment.
+type SectorNIActivationInfo struct {
+	SectorNumber  abi.SectorNumber
+	SealedCID     cid.Cid `checked:"true"` // CommR
+	SealRandEpoch abi.ChainEpoch
+	Expiration    abi.ChainEpoch
+}
+
+// Verifies an aggregate proof of replication of committed-capacity sectors that were never pre-committed,
+// and activates them at once. The sectors carry no deals, so no pre-commit deposit is required and their
+// sealed data is known without reference to the market actor.
+// The seal randomness is drawn at each sector's seal randomness epoch, which must fall within the much shorter
+// MaxProveCommitNIRandomnessLookback of this message's epoch. Without a pre-commitment to fix the replica on chain, the challenge
+// seed is drawn PreCommitChallengeDelay later under a distinct domain, with the sector's CommR as entropy,
+// so that the challenges cannot be known before the replica they test has been sealed.
+func (a Actor) ProveCommitSectorsNI(rt Runtime, params *ProveCommitSectorsNIParams) *abi.EmptyValue {
+	currEpoch := rt.CurrEpoch()
+	sectorCount := uint64(len(params.Sectors))
+	if sectorCount > MaxAggregatedSectors {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many sectors addressed, addressed %d want <= %d", sectorCount, MaxAggregatedSectors)
+	} else if sectorCount < MinAggregatedSectors {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too few sectors addressed, addressed %d want >= %d", sectorCount, MinAggregatedSectors)
+	}
+	if uint64(len(params.AggregateProof)) > MaxAggregateProofSize {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector prove-commit proof of size %d exceeds max size of %d",
+			len(params.AggregateProof), MaxAggregateProofSize)
+	}
+	if !CanPreCommitSealProof(params.SealProof) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "unsupported seal proof type %v", params.SealProof)
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	info := getMinerInfo(rt, &st)
+	rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+
+	sectorWPoStProof, err := params.SealProof.RegisteredWindowPoStProof()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to lookup Window PoSt proof type for seal proof %d", params.SealProof)
+	if sectorWPoStProof != info.WindowPoStProofType {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector Window PoSt proof type %d must match miner Window PoSt proof type %d (seal proof type %d)",
+			sectorWPoStProof, info.WindowPoStProofType, params.SealProof)
+	}
+
+	settings := st.GetOwnerSettings()
+	err = settings.checkSectorCount(sectorCount)
+	builtin.RequireNoErr(rt, err, exitcode.ErrForbidden, "aggregate refused by owner settings")
+	aggregateFee := AggregateProveCommitNetworkFee(len(params.Sectors), rt.BaseFee())
+	err = settings.checkBatchNetworkFee(aggregateFee)
+	builtin.RequireNoErr(rt, err, exitcode.ErrForbidden, "aggregate refused by owner settings")
+
+	receiver := rt.Receiver()
+	minerActorID, err := addr.IDFromAddress(receiver)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "runtime provided non-ID receiver address %s", receiver)
+	buf := new(bytes.Buffer)
+	err = receiver.MarshalCBOR(buf)
+	receiverBytes := buf.Bytes()
+	builtin.RequireNoErr(rt, err, exitcode.ErrSerialization, "failed to marshal address for seal verification challenge")
+
+	challengeEarliest := currEpoch - MaxProveCommitNIRandomnessLookback
+	sectorNumbers := bitfield.New()
+	for _, sector := range params.Sectors {
+		set, err := sectorNumbers.IsSet(uint64(sector.SectorNumber))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "error checking sector number")
+		if set {
+			rt.Abortf(exitcode.ErrIllegalArgument, "duplicate sector number %d", sector.SectorNumber)
+		}
+		sectorNumbers.Set(uint64(sector.SectorNumber))
+
+		if sector.SectorNumber > abi.MaxSectorNumber {
+			rt.Abortf(exitcode.ErrIllegalArgument, "sector number %d out of range 0..(2^63-1)", sector.SectorNumber)
+		}
+		if !sector.SealedCID.Defined() {
+			rt.Abortf(exitcode.ErrIllegalArgument, "sealed CID undefined")
+		}
+		if sector.SealedCID.Prefix() != SealedCIDPrefix {
+			rt.Abortf(exitcode.ErrIllegalArgument, "sealed CID had wrong prefix")
+		}
+		if sector.SealRandEpoch >= currEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "seal challenge epoch %v must be before now %v", sector.SealRandEpoch, currEpoch)
+		}
+		if sector.SealRandEpoch < challengeEarliest {
+			rt.Abortf(exitcode.ErrIllegalArgument, "seal challenge epoch %v too old, must be after %v", sector.SealRandEpoch, challengeEarliest)
+		}
+		if sector.SealRandEpoch+PreCommitChallengeDelay >= currEpoch {
+			rt.Abortf(exitcode.ErrIllegalArgument, "interactive challenge epoch %v must be before now %v",
+				sector.SealRandEpoch+PreCommitChallengeDelay, currEpoch)
+		}
+		validateExpiration(rt, currEpoch, sector.Expiration, params.SealProof)
+	}
+
+	// The unsealed CID of a committed-capacity sector depends only on its size.
+	commD := requestUnsealedSectorCIDs(rt, &market.SectorDataSpec{SectorType: params.SealProof})[0]
+	svis := make([]proof.AggregateSealVerifyInfo, len(params.Sectors))
+	for i, sector := range params.Sectors {
+		svInfoRandomness := rt.GetRandomnessFromTickets(crypto.DomainSeparationTag_SealRandomness, sector.SealRandEpoch, receiverBytes)
+		seedEntropy := append(append([]byte{}, receiverBytes...), sector.SealedCID.Bytes()...)
+		svInfoInteractiveRandomness := rt.GetRandomnessFromBeacon(builtin.DomainSeparationTag_NonInteractiveSealChallengeSeed,
+			sector.SealRandEpoch+PreCommitChallengeDelay, seedEntropy)
+		svis[i] = proof.AggregateSealVerifyInfo{
+			Number:                sector.SectorNumber,
+			InteractiveRandomness: abi.InteractiveSealRandomness(svInfoInteractiveRandomness),
+			Randomness:            abi.SealRandomness(svInfoRandomness),
+			SealedCID:             sector.SealedCID,
+			UnsealedCID:           commD,
+		}
+	}
+
+	err = rt.VerifyAggregateSeals(
+		proof.AggregateSealVerifyProofAndInfos{
+			Infos:          svis,
+			Proof:          params.AggregateProof,
+			Miner:          abi.ActorID(minerActorID),
+			SealProof:      params.SealProof,
+			AggregateProof: abi.RegisteredAggregationProof_SnarkPackV1,
+		})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "aggregate seal verify failed")
+
+	rew := requestCurrentEpochBlockReward(rt)
+	pwr := requestCurrentTotalPower(rt)
+	circulatingSupply := rt.TotalFilCircSupply()
+
+	store := adt.AsStore(rt)
+	totalPledge := big.Zero()
+	feeToBurn := big.Zero()
+	var needsCron bool
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		if ConsensusFaultActive(info, currEpoch) {
+			rt.Abortf(exitcode.ErrForbidden, "prove-commit not allowed during active consensus fault")
+		}
+
+		// The aggregate fee is applied to fee debt to consolidate its burn with outstanding debts.
+		err := st.ApplyPenalty(aggregateFee)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
+		feeToBurn = RepayDebtsOrAbort(rt, &st)
+
+		err = st.AllocateSectorNumbers(store, sectorNumbers, DenyCollisions)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to allocate sector ids %v", sectorNumbers)
+
+		newSectors := make([]*SectorOnChainInfo, len(params.Sectors))
+		newSectorNos := make([]abi.SectorNumber, len(params.Sectors))
+		for i, sector := range params.Sectors {
+			sectorPower := QAPowerForWeight(info.SectorSize, sector.Expiration-currEpoch, big.Zero(), big.Zero())
+			initialPledge := InitialPledgeForPower(sectorPower, rew.ThisEpochBaselinePower, rew.ThisEpochRewardSmoothed,
+				pwr.QualityAdjPowerSmoothed, circulatingSupply)
+			newSectors[i] = &SectorOnChainInfo{
+				SectorNumber:          sector.SectorNumber,
+				SealProof:             params.SealProof,
+				SealedCID:             sector.SealedCID,
+				Expiration:            sector.Expiration,
+				Activation:            currEpoch,
+				DealWeight:            big.Zero(),
+				VerifiedDealWeight:    big.Zero(),
+				InitialPledge:         initialPledge,
+				ExpectedDayReward:     ExpectedRewardForPower(rew.ThisEpochRewardSmoothed, pwr.QualityAdjPowerSmoothed, sectorPower, builtin.EpochsInDay),
+				ExpectedStoragePledge: ExpectedRewardForPower(rew.ThisEpochRewardSmoothed, pwr.QualityAdjPowerSmoothed, sectorPower, InitialPledgeProjectionPeriod),
+				ReplacedDayReward:     big.Zero(),
+			}
+			newSectorNos[i] = sector.SectorNumber
+			totalPledge = big.Add(totalPledge, initialPledge)
+		}
+
+		err = st.ActivateSectorsWithoutPreCommit(store, currEpoch, newSectorNos...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to activate sectors")
+
+		err = st.PutSectors(store, newSectors...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put new sectors")
+
+		err = st.AssignSectorsToDeadlines(store, currEpoch, newSectors, info.WindowPoStPartitionSectors, info.SectorSize)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to assign new sectors to deadlines")
+
+		unlockedBalance, err := st.GetUnlockedBalance(rt.CurrentBalance())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate unlocked balance")
+		if unlockedBalance.LessThan(totalPledge) {
+			rt.Abortf(ErrInsufficientPledge, "insufficient funds for aggregate initial pledge requirement %s, available: %s", totalPledge, unlockedBalance)
+		}
+		err = st.AddInitialPledge(totalPledge)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add initial pledge %v", totalPledge)
+
+		needsCron = !st.DeadlineCronActive
+		st.DeadlineCronActive = true
+	})
+
+	burnFunds(rt, feeToBurn, BurnMethodProveCommitSectorsNI)
+	notifyPledgeChanged(rt, totalPledge, big.Zero(), big.Zero())
+	rt.StateReadonly(&st)
+	err = st.CheckBalanceInvariants(rt.CurrentBalance())
+	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
+	if needsCron {
+		newDlInfo := st.DeadlineInfo(currEpoch)
+		enrollCronEvent(rt, newDlInfo.Last(), CronEventProvingDeadline)
+	}
+	return nil
+}
+
 //type ProveCommitSectorParams struct {
 //	SectorNumber abi.SectorNumber
 //	ReplicaProof        []byte
@@ -989,16 +1898,39 @@ type ProveCommitSectorParams = miner0.ProveCommitSectorParams
 
 // Checks state of the corresponding sector pre-commitment, then schedules the proof to be verified in bulk
 // by the power actor.
-// If valid, the power actor will call ConfirmSectorProofsValid at the end of the same epoch as this message.
+// If valid, the power actor will call ConfirmSectorProofsValid at the end of the same epoch as this message,
+// or of a later epoch if the miner submitted more proofs than can be verified in one.
 func (a Actor) ProveCommitSector(rt Runtime, params *ProveCommitSectorParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerAcceptAny()
+	proveCommitSector(rt, params.SectorNumber, params.Proof, big.Zero())
+	return nil
+}
 
-	if params.SectorNumber > abi.MaxSectorNumber {
+type ProveCommitSector2Params struct {
+	SectorNumber abi.SectorNumber
+	Proof        []byte
+	// Fee paid from the miner's balance to the power actor, ordering the proof's verification ahead of the
+	// miner's others should more be submitted in the epoch than can be verified. Zero for none.
+	PriorityFee abi.TokenAmount
+}
+
+// Proves a pre-committed sector as ProveCommitSector, paying a priority fee for the proof's verification.
+// Any caller may submit a proof, but only the owner, worker or a control address may pay a priority fee.
+func (a Actor) ProveCommitSector2(rt Runtime, params *ProveCommitSector2Params) *abi.EmptyValue {
+	rt.ValidateImmediateCallerAcceptAny()
+	if params.PriorityFee.LessThan(big.Zero()) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "negative priority fee %v", params.PriorityFee)
+	}
+	proveCommitSector(rt, params.SectorNumber, params.Proof, params.PriorityFee)
+	return nil
+}
+
+func proveCommitSector(rt Runtime, sectorNo abi.SectorNumber, proof []byte, priorityFee abi.TokenAmount) {
+	if sectorNo > abi.MaxSectorNumber {
 		rt.Abortf(exitcode.ErrIllegalArgument, "sector number greater than maximum")
 	}
 
 	store := adt.AsStore(rt)
-	sectorNo := params.SectorNumber
 
 	var st State
 	rt.StateReadonly(&st)
@@ -1011,9 +1943,9 @@ func (a Actor) ProveCommitSector(rt Runtime, params *ProveCommitSectorParams) *a
 
 	maxProofSize, err := precommit.Info.SealProof.ProofSize()
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to determine max proof size for sector %v", sectorNo)
-	if uint64(len(params.Proof)) > maxProofSize {
+	if uint64(len(proof)) > maxProofSize {
 		rt.Abortf(exitcode.ErrIllegalArgument, "sector prove-commit proof of size %d exceeds max size of %d",
-			len(params.Proof), maxProofSize)
+			len(proof), maxProofSize)
 	}
 
 	msd, ok := MaxProveCommitDuration[precommit.Info.SealProof]
@@ -1022,28 +1954,45 @@ func (a Actor) ProveCommitSector(rt Runtime, params *ProveCommitSectorParams) *a
 	}
 	proveCommitDue := precommit.PreCommitEpoch + msd
 	if rt.CurrEpoch() > proveCommitDue {
-		rt.Abortf(exitcode.ErrIllegalArgument, "commitment proof for %d too late at %d, due %d", sectorNo, rt.CurrEpoch(), proveCommitDue)
+		rt.Abortf(ErrPreCommitExpired, "commitment proof for %d too late at %d, due %d", sectorNo, rt.CurrEpoch(), proveCommitDue)
 	}
 
 	svi := getVerifyInfo(rt, &SealVerifyStuff{
 		SealedCID:           precommit.Info.SealedCID,
 		InteractiveEpoch:    precommit.PreCommitEpoch + PreCommitChallengeDelay,
 		SealRandEpoch:       precommit.Info.SealRandEpoch,
-		Proof:               params.Proof,
+		Proof:               proof,
 		DealIDs:             precommit.Info.DealIDs,
 		SectorNumber:        precommit.Info.SectorNumber,
 		RegisteredSealProof: precommit.Info.SealProof,
 	})
 
+	// Record the sector as proven so that its pre-commitment is retained until the proof is confirmed.
+	rt.StateTransaction(&st, func() {
+		if priorityFee.GreaterThan(big.Zero()) {
+			info := getMinerInfo(rt, &st)
+			if !isControlAddress(info, rt.Caller()) {
+				rt.Abortf(exitcode.ErrForbidden, "caller %s may not pay a priority fee for miner", rt.Caller())
+			}
+			availableBalance, err := st.GetAvailableBalance(rt.CurrentBalance())
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate available balance")
+			if availableBalance.LessThan(priorityFee) {
+				rt.Abortf(exitcode.ErrInsufficientFunds, "insufficient funds %v for priority fee %v", availableBalance, priorityFee)
+			}
+		}
+
+		err := st.RecordPreCommitsProven(store, rt.CurrEpoch(), sectorNo)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record proven pre-commit %v", sectorNo)
+	})
+
 	code := rt.Send(
 		builtin.StoragePowerActorAddr,
 		builtin.MethodsPower.SubmitPoRepForBulkVerify,
-		svi,
-		abi.NewTokenAmount(0),
+		&power.SubmitPoRepForBulkVerifyParams{SealInfo: *svi, ProveCommitDue: proveCommitDue},
+		priorityFee,
 		&builtin.Discard{},
 	)
 	builtin.RequireSuccess(rt, code, "failed to submit proof for bulk verification")
-	return nil
 }
 
 func (a Actor) ConfirmSectorProofsValid(rt Runtime, params *builtin.ConfirmSectorProofsParams) *abi.EmptyValue {
@@ -1057,53 +2006,110 @@ func (a Actor) ConfirmSectorProofsValid(rt Runtime, params *builtin.ConfirmSecto
 		)
 	}
 
+	if len(params.Failures) > 0 {
+		discardFailedProofs(rt, params.Failures)
+	}
+
 	var st State
 	rt.StateReadonly(&st)
 	store := adt.AsStore(rt)
 
-	// This skips missing pre-commits.
-	precommittedSectors, err := st.FindPrecommittedSectors(store, params.Sectors...)
+	// This skips sectors that are not awaiting confirmation of a proof accepted this epoch.
+	precommittedSectors, err := st.FindProvenPreCommits(store, rt.CurrEpoch(), params.Sectors...)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load pre-committed sectors")
 
-	confirmSectorProofsValid(rt, precommittedSectors, params.RewardBaselinePower, params.RewardSmoothed, params.QualityAdjPowerSmoothed)
+	validPreCommits := activatePreCommitDeals(rt, precommittedSectors)
+	if len(validPreCommits) > 0 {
+		confirmSectorProofsValid(rt, validPreCommits, params.RewardBaselinePower, params.RewardSmoothed, params.QualityAdjPowerSmoothed)
+	} else if len(params.Failures) == 0 && len(params.Deferred) == 0 {
+		// Aborting would revert the discard of pre-commitments with failed proofs, and the deferral of others.
+		rt.Abortf(exitcode.ErrIllegalArgument, "all prove commits failed to validate")
+	}
 
+	if len(params.Deferred) > 0 {
+		rt.StateTransaction(&st, func() {
+			err := st.DeferProvenPreCommits(store, rt.CurrEpoch(), params.Deferred...)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to defer proven pre-commits")
+		})
+	}
 	return nil
 }
 
-func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainInfo, thisEpochBaselinePower big.Int,
-	thisEpochRewardSmoothed smoothing.FilterEstimate, qualityAdjPowerSmoothed smoothing.FilterEstimate) {
+// Discards the pre-commitments of sectors whose proofs failed verification this epoch, burning their deposits.
+// Sectors not awaiting confirmation of a proof, including those also proven successfully, are skipped.
+func discardFailedProofs(rt Runtime, failures []builtin.SectorProofFailure) {
+	sectorNos := make([]abi.SectorNumber, 0, len(failures))
+	for _, failure := range failures {
+		sectorNos = append(sectorNos, failure.SectorNumber)
+	}
 
-	circulatingSupply := rt.TotalFilCircSupply()
+	var st State
+	store := adt.AsStore(rt)
+	depositToBurn := big.Zero()
+	rt.StateTransaction(&st, func() {
+		precommits, err := st.FindProvenPreCommits(store, rt.CurrEpoch(), sectorNos...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load pre-committed sectors")
+		if len(precommits) == 0 {
+			return
+		}
 
-	// 1. Activate deals, skipping pre-commits with invalid deals.
-	//    - calls the market actor.
-	// 2. Add new sectors.
-	//    - loads and saves sectors.
-	//    - loads and saves deadlines/partitions
-	//
-	// Ideally, we'd combine some of these operations, but at least we have
-	// a constant number of them.
+		failedSectorNos := make([]abi.SectorNumber, len(precommits))
+		for i, precommit := range precommits {
+			failedSectorNos[i] = precommit.Info.SectorNumber
+		}
+		depositToBurn, err = st.DiscardPreCommittedSectors(store, rt.CurrEpoch(), failedSectorNos...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to discard pre-committed sectors")
+		err = st.AddPreCommitDeposit(depositToBurn.Neg())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to release pre-commit deposit %v", depositToBurn)
+	})
 
-	activation := rt.CurrEpoch()
-	// Pre-commits for new sectors.
-	var validPreCommits []*SectorPreCommitOnChainInfo
+	for _, failure := range failures {
+		rt.Log(rtt.INFO, "storage provider %s proof for sector %d failed verification, exit code %d",
+			rt.Receiver(), failure.SectorNumber, failure.Code)
+	}
+	burnFunds(rt, depositToBurn, BurnMethodConfirmSectorProofsValid)
+	notifyPledgeChanged(rt, big.Zero(), depositToBurn.Neg(), big.Zero())
+}
+
+// Activates the deals of pre-committed sectors, returning the pre-commitments whose deals were all activated.
+// The deals of all the sectors are activated in a single call to the market actor.
+func activatePreCommitDeals(rt Runtime, preCommits []*SectorPreCommitOnChainInfo) []*SectorPreCommitOnChainInfo {
+	var sectorDeals []market.SectorDealActivation
 	for _, precommit := range preCommits {
 		if len(precommit.Info.DealIDs) > 0 {
-			// Check (and activate) storage deals associated to sector. Abort if checks failed.
-			// TODO: we should batch these calls...
-			// https://github.com/filecoin-project/specs-actors/issues/474
-			code := rt.Send(
-				builtin.StorageMarketActorAddr,
-				builtin.MethodsMarket.ActivateDeals,
-				&market.ActivateDealsParams{
-					DealIDs:      precommit.Info.DealIDs,
-					SectorExpiry: precommit.Info.Expiration,
-				},
-				abi.NewTokenAmount(0),
-				&builtin.Discard{},
-			)
+			sectorDeals = append(sectorDeals, market.SectorDealActivation{
+				SectorNumber: precommit.Info.SectorNumber,
+				SectorExpiry: precommit.Info.Expiration,
+				DealIDs:      precommit.Info.DealIDs,
+			})
+		}
+	}
+
+	activated := bitfield.New()
+	if len(sectorDeals) > 0 {
+		var ret market.BatchActivateDealsReturn
+		code := rt.Send(
+			builtin.StorageMarketActorAddr,
+			builtin.MethodsMarket.BatchActivateDeals,
+			&market.BatchActivateDealsParams{Sectors: sectorDeals},
+			abi.NewTokenAmount(0),
+			&ret,
+		)
+		if code.IsSuccess() {
+			activated = ret.Activated
+		} else {
+			rt.Log(rtt.INFO, "failed to activate deals on %d sectors, exit code %d", len(sectorDeals), code)
+		}
+	}
 
-			if code != exitcode.Ok {
+	var validPreCommits []*SectorPreCommitOnChainInfo
+	dealSectorIdx := uint64(0)
+	for _, precommit := range preCommits {
+		if len(precommit.Info.DealIDs) > 0 {
+			ok, err := activated.IsSet(dealSectorIdx)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to read activated sectors")
+			dealSectorIdx++
+			if !ok {
 				rt.Log(rtt.INFO, "failed to activate deals on sector %d, dropping from prove commit set", precommit.Info.SectorNumber)
 				continue
 			}
@@ -1111,11 +2117,15 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 
 		validPreCommits = append(validPreCommits, precommit)
 	}
+	return validPreCommits
+}
 
-	// When all prove commits have failed abort early
-	if len(validPreCommits) == 0 {
-		rt.Abortf(exitcode.ErrIllegalArgument, "all prove commits failed to validate")
-	}
+// Activates sectors for pre-commitments with valid proofs and deals.
+func confirmSectorProofsValid(rt Runtime, validPreCommits []*SectorPreCommitOnChainInfo, thisEpochBaselinePower big.Int,
+	thisEpochRewardSmoothed smoothing.FilterEstimate, qualityAdjPowerSmoothed smoothing.FilterEstimate) {
+
+	circulatingSupply := rt.TotalFilCircSupply()
+	activation := rt.CurrEpoch()
 
 	totalPledge := big.Zero()
 	depositToUnlock := big.Zero()
@@ -1167,11 +2177,11 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 			totalPledge = big.Add(totalPledge, initialPledge)
 		}
 
-		err := st.PutSectors(store, newSectors...)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put new sectors")
+		err := st.ActivatePreCommittedSectors(store, rt.CurrEpoch(), newSectorNos...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to activate precommited sectors")
 
-		err = st.DeletePrecommittedSectors(store, newSectorNos...)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete precommited sectors")
+		err = st.PutSectors(store, newSectors...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put new sectors")
 
 		err = st.AssignSectorsToDeadlines(store, rt.CurrEpoch(), newSectors, info.WindowPoStPartitionSectors, info.SectorSize)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to assign new sectors to deadlines")
@@ -1183,7 +2193,7 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 		unlockedBalance, err := st.GetUnlockedBalance(rt.CurrentBalance())
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate unlocked balance")
 		if unlockedBalance.LessThan(totalPledge) {
-			rt.Abortf(exitcode.ErrInsufficientFunds, "insufficient funds for aggregate initial pledge requirement %s, available: %s", totalPledge, unlockedBalance)
+			rt.Abortf(ErrInsufficientPledge, "insufficient funds for aggregate initial pledge requirement %s, available: %s", totalPledge, unlockedBalance)
 		}
 
 		err = st.AddInitialPledge(totalPledge)
@@ -1193,7 +2203,7 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 	})
 
 	// Request pledge update for activated sector.
-	notifyPledgeChanged(rt, big.Sub(totalPledge, newlyVested))
+	notifyPledgeChanged(rt, totalPledge, depositToUnlock.Neg(), newlyVested.Neg())
 }
 
 //type CheckSectorProvenParams struct {
@@ -1221,35 +2231,232 @@ func (a Actor) CheckSectorProven(rt Runtime, params *CheckSectorProvenParams) *a
 	return nil
 }
 
-/////////////////////////
-// Sector Modification //
-/////////////////////////
+type FindSectorParams struct {
+	SectorNumber abi.SectorNumber
+}
 
-//type ExtendSectorExpirationParams struct {
-//	Extensions []ExpirationExtension
-//}
-type ExtendSectorExpirationParams = miner0.ExtendSectorExpirationParams
+type FindSectorReturn struct {
+	Deadline  uint64
+	Partition uint64
+}
 
-//type ExpirationExtension struct {
-//	Deadline      uint64
-//	Partition     uint64
-//	Sectors       bitfield.BitField
-//	NewExpiration abi.ChainEpoch
-//}
+// Reports the deadline and partition to which a sector is assigned.
+// A terminated sector may be found until its partition is compacted, after which it is not found.
+func (a Actor) FindSector(rt Runtime, params *FindSectorParams) *FindSectorReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	if params.SectorNumber > abi.MaxSectorNumber {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector number out of range")
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	store := adt.AsStore(rt)
+	sectorNo := params.SectorNumber
+
+	_, found, err := st.GetSector(store, sectorNo)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector %v", sectorNo)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "sector %v not found", sectorNo)
+	}
+	dlIdx, pIdx, err := st.FindSector(store, sectorNo)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to find sector %v", sectorNo)
+	return &FindSectorReturn{
+		Deadline:  dlIdx,
+		Partition: pIdx,
+	}
+}
+
+/////////////////////////
+// Sector Modification //
+/////////////////////////
+
+//type ExtendSectorExpirationParams struct {
+//	Extensions []ExpirationExtension
+//}
+type ExtendSectorExpirationParams = miner0.ExtendSectorExpirationParams
+
+//type ExpirationExtension struct {
+//	Deadline      uint64
+//	Partition     uint64
+//	Sectors       bitfield.BitField
+//	NewExpiration abi.ChainEpoch
+//}
 type ExpirationExtension = miner0.ExpirationExtension
 
 // Changes the expiration epoch for a sector to a new, later one.
 // The sector must not be terminated or faulty.
 // The sector's power is recomputed for the new expiration.
 func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpirationParams) *abi.EmptyValue {
-	if uint64(len(params.Extensions)) > DeclarationsMax {
-		rt.Abortf(exitcode.ErrIllegalArgument, "too many declarations %d, max %d", len(params.Extensions), DeclarationsMax)
+	extensions := validateExpirationExtensions(rt, params.Extensions)
+
+	currEpoch := rt.CurrEpoch()
+	extendSectorExpirations(rt, extensions, func(_ *State, sector *SectorOnChainInfo, newExpiration abi.ChainEpoch) *SectorOnChainInfo {
+		// Remove "spent" deal weights
+		newDealWeight := big.Div(
+			big.Mul(sector.DealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
+			big.NewInt(int64(sector.Expiration-sector.Activation)),
+		)
+		newVerifiedDealWeight := big.Div(
+			big.Mul(sector.VerifiedDealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
+			big.NewInt(int64(sector.Expiration-sector.Activation)),
+		)
+
+		newSector := *sector
+		newSector.Expiration = newExpiration
+		newSector.DealWeight = newDealWeight
+		newSector.VerifiedDealWeight = newVerifiedDealWeight
+		return &newSector
+	})
+	return nil
+}
+
+type ExtendSectorExpiration2Params struct {
+	Extensions []ExpirationExtension2
+}
+
+type ExpirationExtension2 struct {
+	Deadline  uint64
+	Partition uint64
+	Sectors   bitfield.BitField
+	// The verified registry claims bound to sectors in Sectors. A sector without claims has no verified space.
+	Claims        []SectorVerifiedClaim
+	NewExpiration abi.ChainEpoch
+}
+
+type SectorVerifiedClaim struct {
+	SectorNumber abi.SectorNumber
+	ClaimIDs     []verifreg.AllocationID
+}
+
+// Changes the expiration epoch for sectors to a new, later one, re-pricing them from the verified registry
+// claims bound to each.
+// Rather than pro-rating the sectors' deal weights, the weight of a sector's remaining deals is kept and the
+// space of its claims is weighted over the remaining life of the sector, so a long extension keeps its
+// verified power. The verified registry checks that each claim was made for the sector it is asserted for,
+// so verified space cannot back more than the sector holding its data.
+// Each sector is re-priced from the current epoch, as if upgraded: its expected rewards are recomputed and its
+// pledge is raised if the new power requires more.
+func (a Actor) ExtendSectorExpiration2(rt Runtime, params *ExtendSectorExpiration2Params) *abi.EmptyValue {
+	extensions := make([]ExpirationExtension, len(params.Extensions))
+	var sectorClaims []verifreg.SectorClaims
+	claimed := map[abi.SectorNumber]struct{}{}
+	for i, decl := range params.Extensions {
+		extensions[i] = ExpirationExtension{
+			Deadline:      decl.Deadline,
+			Partition:     decl.Partition,
+			Sectors:       decl.Sectors,
+			NewExpiration: decl.NewExpiration,
+		}
+		for _, claim := range decl.Claims {
+			included, err := decl.Sectors.IsSet(uint64(claim.SectorNumber))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to check sector %d", claim.SectorNumber)
+			if !included {
+				rt.Abortf(exitcode.ErrIllegalArgument, "claim for sector %d not in extension", claim.SectorNumber)
+			}
+			if _, ok := claimed[claim.SectorNumber]; ok {
+				rt.Abortf(exitcode.ErrIllegalArgument, "duplicate claim for sector %d", claim.SectorNumber)
+			}
+			claimed[claim.SectorNumber] = struct{}{}
+			sectorClaims = append(sectorClaims, verifreg.SectorClaims{SectorNumber: claim.SectorNumber, ClaimIDs: claim.ClaimIDs})
+		}
+	}
+	merged := validateExpirationExtensions(rt, extensions)
+
+	claims := map[abi.SectorNumber]abi.StoragePower{}
+	if len(sectorClaims) > 0 {
+		var ret verifreg.VerifySectorClaimsReturn
+		code := rt.Send(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.VerifySectorClaims,
+			&verifreg.VerifySectorClaimsParams{Sectors: sectorClaims}, big.Zero(), &ret)
+		builtin.RequireSuccess(rt, code, "failed to verify claims of %d sectors", len(sectorClaims))
+		builtin.RequireState(rt, len(ret.VerifiedSpace) == len(sectorClaims), "%d verified spaces returned for %d sectors",
+			len(ret.VerifiedSpace), len(sectorClaims))
+		for i, sc := range sectorClaims {
+			claims[sc.SectorNumber] = ret.VerifiedSpace[i]
+		}
 	}
 
+	rewRet := requestCurrentEpochBlockReward(rt)
+	powRet := requestCurrentTotalPower(rt)
+
+	currEpoch := rt.CurrEpoch()
+	extendSectorExpirations(rt, merged, func(st *State, sector *SectorOnChainInfo, newExpiration abi.ChainEpoch) *SectorOnChainInfo {
+		sectorSize, err := sector.SealProof.SectorSize()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get size of sector %d", sector.SectorNumber)
+
+		// Keep the weight of the remaining deals, which don't outlive the old expiration.
+		newDealWeight := big.Div(
+			big.Mul(sector.DealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
+			big.NewInt(int64(sector.Expiration-sector.Activation)),
+		)
+		duration := newExpiration - currEpoch
+		verifiedSpace, ok := claims[sector.SectorNumber]
+		if !ok {
+			verifiedSpace = big.Zero()
+		}
+		newVerifiedDealWeight := big.Mul(verifiedSpace, big.NewInt(int64(duration)))
+		maxWeight := big.Mul(big.NewIntUnsigned(uint64(sectorSize)), big.NewInt(int64(duration)))
+		if big.Add(newDealWeight, newVerifiedDealWeight).GreaterThan(maxWeight) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "verified space %v claimed for sector %d exceeds its free space",
+				verifiedSpace, sector.SectorNumber)
+		}
+
+		newSector := *sector
+		newSector.Expiration = newExpiration
+		newSector.Activation = currEpoch
+		newSector.DealWeight = newDealWeight
+		newSector.VerifiedDealWeight = newVerifiedDealWeight
+
+		pwr := QAPowerForWeight(sectorSize, duration, newDealWeight, newVerifiedDealWeight)
+		newSector.ReplacedDayReward = sector.ExpectedDayReward
+		newSector.ExpectedDayReward = ExpectedRewardForPower(rewRet.ThisEpochRewardSmoothed, powRet.QualityAdjPowerSmoothed, pwr, builtin.EpochsInDay)
+		newSector.ExpectedStoragePledge = ExpectedRewardForPower(rewRet.ThisEpochRewardSmoothed, powRet.QualityAdjPowerSmoothed, pwr, InitialPledgeProjectionPeriod)
+		newSector.ReplacedSectorAge = maxEpoch(0, currEpoch-sector.Activation)
+
+		initialPledge := InitialPledgeForPower(pwr, rewRet.ThisEpochBaselinePower, rewRet.ThisEpochRewardSmoothed,
+			powRet.QualityAdjPowerSmoothed, rt.TotalFilCircSupply())
+		if initialPledge.GreaterThan(sector.InitialPledge) {
+			deficit := big.Sub(initialPledge, sector.InitialPledge)
+
+			unlockedBalance, err := st.GetUnlockedBalance(rt.CurrentBalance())
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate unlocked balance")
+			builtin.RequirePredicate(rt, unlockedBalance.GreaterThanEqual(deficit), ErrInsufficientPledge,
+				"insufficient funds for new initial pledge requirement %s of sector %d, available: %s",
+				deficit, sector.SectorNumber, unlockedBalance)
+
+			err = st.AddInitialPledge(deficit)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add initial pledge")
+			newSector.InitialPledge = initialPledge
+		}
+		return &newSector
+	})
+	return nil
+}
+
+// Sectors to be extended, merged by deadline and partition from the declarations addressing them,
+// with the new expiration of each sector.
+type mergedExpirationExtensions struct {
+	sectors        DeadlineSectorMap
+	newExpirations map[abi.SectorNumber]abi.ChainEpoch
+}
+
+// Checks the number of declarations, deadlines and sectors addressed by expiration extensions, merging
+// declarations that address the same partition. A sector may be declared more than once, but only with
+// the same new expiration.
+func validateExpirationExtensions(rt Runtime, extensions []ExpirationExtension) *mergedExpirationExtensions {
+	if uint64(len(extensions)) > DeclarationsMax {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many declarations %d, max %d", len(extensions), DeclarationsMax)
+	}
+
+	merged := &mergedExpirationExtensions{
+		sectors:        make(DeadlineSectorMap),
+		newExpirations: map[abi.SectorNumber]abi.ChainEpoch{},
+	}
 	// limit the number of sectors declared at once
 	// https://github.com/filecoin-project/specs-actors/issues/416
+	// Sectors are counted as declared, before merging, bounding the iteration over the declarations below.
 	var sectorCount uint64
-	for _, decl := range params.Extensions {
+	for _, decl := range extensions {
 		if decl.Deadline >= WPoStPeriodDeadlines {
 			rt.Abortf(exitcode.ErrIllegalArgument, "deadline %d not in range 0..%d", decl.Deadline, WPoStPeriodDeadlines)
 		}
@@ -1262,14 +2469,44 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 			rt.Abortf(exitcode.ErrIllegalArgument, "sector bitfield integer overflow")
 		}
 		sectorCount += count
+		err = merged.sectors.Add(decl.Deadline, decl.Partition, decl.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
+			"failed to process deadline %d, partition %d", decl.Deadline, decl.Partition,
+		)
 	}
-	if sectorCount > AddressedSectorsMax {
+	limits := loadAddressingLimits(rt)
+	if sectorCount > limits.Sectors {
 		rt.Abortf(exitcode.ErrIllegalArgument,
 			"too many sectors for declaration %d, max %d",
-			sectorCount, AddressedSectorsMax,
+			sectorCount, limits.Sectors,
 		)
 	}
 
+	// limit the number of partitions declared at once
+	err := merged.sectors.Check(limits.Partitions, limits.Sectors)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "cannot process requested parameters")
+
+	for _, decl := range extensions {
+		err := decl.Sectors.ForEach(func(i uint64) error {
+			sectorNo := abi.SectorNumber(i)
+			if prev, ok := merged.newExpirations[sectorNo]; ok && prev != decl.NewExpiration {
+				return xerrors.Errorf("sector %d declared with conflicting expirations %d and %d", sectorNo, prev, decl.NewExpiration)
+			}
+			merged.newExpirations[sectorNo] = decl.NewExpiration
+			return nil
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid declaration for deadline %d, partition %d",
+			decl.Deadline, decl.Partition)
+	}
+	return merged
+}
+
+// Extends the expiration of sectors, replacing each with the sector returned by reprice, which is called
+// within the state transaction once the extension is validated. Power and pledge changes are reported
+// to the power actor.
+// Each deadline and partition addressed is loaded and saved once.
+func extendSectorExpirations(rt Runtime, extensions *mergedExpirationExtensions,
+	reprice func(st *State, sector *SectorOnChainInfo, newExpiration abi.ChainEpoch) *SectorOnChainInfo) {
 	currEpoch := rt.CurrEpoch()
 
 	powerDelta := NewPowerPairZero()
@@ -1281,28 +2518,12 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 
 		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
 
-		deadlines, err := st.LoadDeadlines(adt.AsStore(rt))
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+		msm, err := st.mutator(store).withDeadlines(adt.PermitWrite).
+			withSectors(adt.PermitWrite).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+		deadlines, sectors := msm.deadlines, msm.sectors
 
-		// Group declarations by deadline, and remember iteration order.
-		// This should be merged with the iteration outside the state transaction.
-		declsByDeadline := map[uint64][]*ExpirationExtension{}
-		var deadlinesToLoad []uint64
-		for i := range params.Extensions {
-			// Take a pointer to the value inside the slice, don't
-			// take a reference to the temporary loop variable as it
-			// will be overwritten every iteration.
-			decl := &params.Extensions[i]
-			if _, ok := declsByDeadline[decl.Deadline]; !ok {
-				deadlinesToLoad = append(deadlinesToLoad, decl.Deadline)
-			}
-			declsByDeadline[decl.Deadline] = append(declsByDeadline[decl.Deadline], decl)
-		}
-
-		sectors, err := LoadSectors(store, st.Sectors)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
-
-		for _, dlIdx := range deadlinesToLoad {
+		err = extensions.sectors.ForEach(func(dlIdx uint64, partitionSectors PartitionSectorMap) error {
 			deadline, err := deadlines.LoadDeadline(store, dlIdx)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
 
@@ -1316,18 +2537,19 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 			// Remember iteration order of epochs.
 			var epochsToReschedule []abi.ChainEpoch
 
-			for _, decl := range declsByDeadline[dlIdx] {
+			err = partitionSectors.ForEach(func(partIdx uint64, sectorNos bitfield.BitField) error {
 				var partition Partition
-				found, err := partitions.Get(decl.Partition, &partition)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %v partition %v", dlIdx, decl.Partition)
+				found, err := partitions.Get(partIdx, &partition)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %v partition %v", dlIdx, partIdx)
 				if !found {
-					rt.Abortf(exitcode.ErrNotFound, "no such deadline %v partition %v", dlIdx, decl.Partition)
+					rt.Abortf(exitcode.ErrNotFound, "no such deadline %v partition %v", dlIdx, partIdx)
 				}
 
-				oldSectors, err := sectors.Load(decl.Sectors)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors in deadline %v partition %v", dlIdx, decl.Partition)
+				oldSectors, err := sectors.Load(sectorNos)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors in deadline %v partition %v", dlIdx, partIdx)
 				newSectors := make([]*SectorOnChainInfo, len(oldSectors))
 				for i, sector := range oldSectors {
+					newExpiration := extensions.newExpirations[sector.SectorNumber]
 					if !CanExtendSealProofType(sector.SealProof) {
 						rt.Abortf(exitcode.ErrForbidden, "cannot extend expiration for sector %v with unsupported seal type %v",
 							sector.SectorNumber, sector.SealProof)
@@ -1341,51 +2563,40 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 							currEpoch,
 						)
 					}
-					if decl.NewExpiration < sector.Expiration {
+					if newExpiration < sector.Expiration {
 						rt.Abortf(exitcode.ErrIllegalArgument, "cannot reduce sector %v's expiration to %d from %d",
-							sector.SectorNumber, decl.NewExpiration, sector.Expiration)
+							sector.SectorNumber, newExpiration, sector.Expiration)
+					}
+					validateExpiration(rt, sector.Activation, newExpiration, sector.SealProof)
+
+					newSectors[i] = reprice(&st, sector, newExpiration)
+
+					// Record the new partition expiration epoch for setting outside this loop over partitions.
+					prevEpochPartitions, ok := partitionsByNewEpoch[newExpiration]
+					if !ok {
+						epochsToReschedule = append(epochsToReschedule, newExpiration)
+					}
+					if len(prevEpochPartitions) == 0 || prevEpochPartitions[len(prevEpochPartitions)-1] != partIdx {
+						partitionsByNewEpoch[newExpiration] = append(prevEpochPartitions, partIdx)
 					}
-					validateExpiration(rt, sector.Activation, decl.NewExpiration, sector.SealProof)
-
-					// Remove "spent" deal weights
-					newDealWeight := big.Div(
-						big.Mul(sector.DealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
-						big.NewInt(int64(sector.Expiration-sector.Activation)),
-					)
-					newVerifiedDealWeight := big.Div(
-						big.Mul(sector.VerifiedDealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
-						big.NewInt(int64(sector.Expiration-sector.Activation)),
-					)
-
-					newSector := *sector
-					newSector.Expiration = decl.NewExpiration
-					newSector.DealWeight = newDealWeight
-					newSector.VerifiedDealWeight = newVerifiedDealWeight
-
-					newSectors[i] = &newSector
 				}
 
 				// Overwrite sector infos.
 				err = sectors.Store(newSectors...)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update sectors %v", decl.Sectors)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update sectors %v", sectorNos)
 
 				// Remove old sectors from partition and assign new sectors.
 				partitionPowerDelta, partitionPledgeDelta, err := partition.ReplaceSectors(store, oldSectors, newSectors, info.SectorSize, quant)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector expirations at deadline %v partition %v", dlIdx, decl.Partition)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector expirations at deadline %v partition %v", dlIdx, partIdx)
 
 				powerDelta = powerDelta.Add(partitionPowerDelta)
-				pledgeDelta = big.Add(pledgeDelta, partitionPledgeDelta) // expected to be zero, see note below.
-
-				err = partitions.Set(decl.Partition, &partition)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %v partition %v", dlIdx, decl.Partition)
+				pledgeDelta = big.Add(pledgeDelta, partitionPledgeDelta)
 
-				// Record the new partition expiration epoch for setting outside this loop over declarations.
-				prevEpochPartitions, ok := partitionsByNewEpoch[decl.NewExpiration]
-				partitionsByNewEpoch[decl.NewExpiration] = append(prevEpochPartitions, decl.Partition)
-				if !ok {
-					epochsToReschedule = append(epochsToReschedule, decl.NewExpiration)
-				}
-			}
+				err = partitions.Set(partIdx, &partition)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %v partition %v", dlIdx, partIdx)
+				return nil
+			})
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to extend sectors in deadline %d", dlIdx)
 
 			deadline.Partitions, err = partitions.Root()
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save partitions for deadline %d", dlIdx)
@@ -1400,20 +2611,18 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 
 			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d", dlIdx)
-		}
-
-		st.Sectors, err = sectors.Root()
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors")
+			return nil
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to extend sectors")
 
-		err = st.SaveDeadlines(store, deadlines)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors and deadlines")
 	})
 
-	requestUpdatePower(rt, powerDelta)
-	// Note: the pledge delta is expected to be zero, since pledge is not re-calculated for the extension.
-	// But in case that ever changes, we can do the right thing here.
-	notifyPledgeChanged(rt, pledgeDelta)
-	return nil
+	// Only active sectors are extended, so faulty power is unchanged.
+	requestUpdatePower(rt, powerDelta, NewPowerPairZero())
+	// Note: the pledge delta is zero unless the extension re-calculates pledge.
+	notifyPledgeChanged(rt, pledgeDelta, big.Zero(), big.Zero())
 }
 
 //type TerminateSectorsParams struct {
@@ -1456,24 +2665,43 @@ type TerminateSectorsReturn = miner0.TerminateSectorsReturn
 // This function may be invoked with no new sectors to explicitly process the
 // next batch of sectors.
 func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *TerminateSectorsReturn {
+	return terminateSectors(rt, params.Terminations, false)
+}
+
+type TerminateSectors2Params struct {
+	Terminations []TerminationDeclaration
+	// Whether the value sent with the message is earmarked for the termination penalty of the sectors
+	// processed by this call. Earmarked funds pay the penalty ahead of vesting funds and the available
+	// balance, and any left over are returned to the caller. Otherwise the value is added to the balance.
+	PenaltyFromValue bool
+}
+
+// Terminates sectors as TerminateSectors, except that the termination penalty may be financed by funds
+// sent with the message, without first adding them to the miner's balance, by setting PenaltyFromValue.
+func (a Actor) TerminateSectors2(rt Runtime, params *TerminateSectors2Params) *TerminateSectorsReturn {
+	return terminateSectors(rt, params.Terminations, params.PenaltyFromValue)
+}
+
+func terminateSectors(rt Runtime, terminations []TerminationDeclaration, penaltyFromValue bool) *TerminateSectorsReturn {
 	// Note: this cannot terminate pre-committed but un-proven sectors.
 	// They must be allowed to expire (and deposit burnt).
 
-	if len(params.Terminations) > DeclarationsMax {
+	if len(terminations) > DeclarationsMax {
 		rt.Abortf(exitcode.ErrIllegalArgument,
 			"too many declarations when terminating sectors: %d > %d",
-			len(params.Terminations), DeclarationsMax,
+			len(terminations), DeclarationsMax,
 		)
 	}
 
 	toProcess := make(DeadlineSectorMap)
-	for _, term := range params.Terminations {
+	for _, term := range terminations {
 		err := toProcess.Add(term.Deadline, term.Partition, term.Sectors)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
 			"failed to process deadline %d, partition %d", term.Deadline, term.Partition,
 		)
 	}
-	err := toProcess.Check(AddressedPartitionsMax, AddressedSectorsMax)
+	limits := loadAddressingLimits(rt)
+	err := toProcess.Check(limits.Partitions, limits.Sectors)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "cannot process requested parameters")
 
 	var hadEarlyTerminations bool
@@ -1481,25 +2709,25 @@ func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *Ter
 	store := adt.AsStore(rt)
 	currEpoch := rt.CurrEpoch()
 	powerDelta := NewPowerPairZero()
+	faultyPowerDelta := NewPowerPairZero()
 	rt.StateTransaction(&st, func() {
 		hadEarlyTerminations = havePendingEarlyTerminations(rt, &st)
 
 		info := getMinerInfo(rt, &st)
 		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
 
-		deadlines, err := st.LoadDeadlines(adt.AsStore(rt))
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
-
 		// We're only reading the sectors, so there's no need to save this back.
 		// However, we still want to avoid re-loading this array per-partition.
-		sectors, err := LoadSectors(store, st.Sectors)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors")
+		msm, err := st.mutator(store).withDeadlines(adt.PermitWrite).
+			withSectors(adt.PermitReadOnly).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+		deadlines, sectors := msm.deadlines, msm.sectors
 
 		err = toProcess.ForEach(func(dlIdx uint64, partitionSectors PartitionSectorMap) error {
 			// If the deadline is the current or next deadline to prove, don't allow terminating sectors.
 			// We assume that deadlines are immutable when being proven.
 			if !deadlineIsMutable(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch) {
-				rt.Abortf(exitcode.ErrIllegalArgument, "cannot terminate sectors in immutable deadline %d", dlIdx)
+				rt.Abortf(ErrImmutableDeadline, "cannot terminate sectors in immutable deadline %d", dlIdx)
 			}
 
 			quant := st.QuantSpecForDeadline(dlIdx)
@@ -1507,12 +2735,14 @@ func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *Ter
 			deadline, err := deadlines.LoadDeadline(store, dlIdx)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
 
+			faultyBefore := deadline.FaultyPower
 			removedPower, err := deadline.TerminateSectors(store, sectors, currEpoch, partitionSectors, info.SectorSize, quant)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to terminate sectors in deadline %d", dlIdx)
 
 			st.EarlyTerminations.Set(dlIdx)
 
 			powerDelta = powerDelta.Sub(removedPower)
+			faultyPowerDelta = faultyPowerDelta.Add(deadline.FaultyPower.Sub(faultyBefore))
 
 			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", dlIdx)
@@ -1521,15 +2751,20 @@ func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *Ter
 		})
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to walk sectors")
 
-		err = st.SaveDeadlines(store, deadlines)
+		err = msm.commitState()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
 	})
 
 	epochReward := requestCurrentEpochBlockReward(rt)
 	pwrTotal := requestCurrentTotalPower(rt)
 
+	earmarked := big.Zero()
+	if penaltyFromValue {
+		earmarked = rt.ValueReceived()
+	}
+
 	// Now, try to process these sectors.
-	more := processEarlyTerminations(rt, epochReward.ThisEpochRewardSmoothed, pwrTotal.QualityAdjPowerSmoothed)
+	more, penaltyFromEarmarked := processEarlyTerminations(rt, epochReward.ThisEpochRewardSmoothed, pwrTotal.QualityAdjPowerSmoothed, earmarked)
 	if more && !hadEarlyTerminations {
 		// We have remaining terminations, and we didn't _previously_
 		// have early terminations to process, schedule a cron job.
@@ -1539,14 +2774,125 @@ func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *Ter
 		scheduleEarlyTerminationWork(rt)
 	}
 
+	if refund := big.Sub(earmarked, penaltyFromEarmarked); refund.GreaterThan(big.Zero()) {
+		code := rt.Send(rt.Caller(), builtin.MethodSend, nil, refund, &builtin.Discard{})
+		// If the caller won't accept the funds, they remain in the miner's available balance
+		// rather than undoing the terminations.
+		if !code.IsSuccess() {
+			rt.Log(rtt.ERROR, "failed to return unused penalty funds %v to %s, error code %d", refund, rt.Caller(), code)
+		}
+	}
+
 	rt.StateReadonly(&st)
 	err = st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
 
-	requestUpdatePower(rt, powerDelta)
+	requestUpdatePower(rt, powerDelta, faultyPowerDelta)
 	return &TerminateSectorsReturn{Done: !more}
 }
 
+// Terminates a sector at the request of the market actor, on behalf of the client of a deal in the sector.
+// The sector must be faulty, and have been continuously faulty for more than ClientBreachFaultPeriods proving
+// periods, so that the client need not wait for the sector's faults to reach FaultMaxAge.
+// The termination fee is assessed as for any other early termination.
+func (a Actor) TerminateBreachedSector(rt Runtime, params *builtin.TerminateBreachedSectorParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerIs(builtin.StorageMarketActorAddr)
+
+	if params.SectorNumber > abi.MaxSectorNumber {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector number out of range")
+	}
+	sectorNo := params.SectorNumber
+
+	var hadEarlyTerminations bool
+	var st State
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	powerDelta := NewPowerPairZero()
+	faultyPowerDelta := NewPowerPairZero()
+	rt.StateTransaction(&st, func() {
+		hadEarlyTerminations = havePendingEarlyTerminations(rt, &st)
+		info := getMinerInfo(rt, &st)
+
+		sector, found, err := st.GetSector(store, sectorNo)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector %v", sectorNo)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "sector %v not found", sectorNo)
+		}
+		holdsDeal := false
+		for _, dealID := range sector.DealIDs {
+			if dealID == params.DealID {
+				holdsDeal = true
+				break
+			}
+		}
+		if !holdsDeal {
+			rt.Abortf(exitcode.ErrIllegalArgument, "sector %v does not hold deal %d", sectorNo, params.DealID)
+		}
+
+		streakStart, inStreak, err := st.GetFaultStreakStart(store, sectorNo)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load fault streak of sector %v", sectorNo)
+		if !inStreak || currEpoch <= streakStart+ClientBreachFaultPeriods*WPoStProvingPeriod {
+			rt.Abortf(exitcode.ErrForbidden, "sector %v not faulty for more than %d proving periods", sectorNo, ClientBreachFaultPeriods)
+		}
+
+		dlIdx, pIdx, err := st.FindSector(store, sectorNo)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to find sector %v", sectorNo)
+		if !deadlineIsMutable(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch) {
+			rt.Abortf(ErrImmutableDeadline, "cannot terminate sectors in immutable deadline %d", dlIdx)
+		}
+
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+
+		// The streak is only ended at the end of the deadline's challenge window, so check the sector
+		// has not since been proven.
+		partition, err := deadline.LoadPartition(store, pIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partition %d", pIdx)
+		faulty, err := partition.Faults.IsSet(uint64(sectorNo))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check faults of partition %d", pIdx)
+		if !faulty {
+			rt.Abortf(exitcode.ErrForbidden, "sector %v is not faulty", sectorNo)
+		}
+
+		sectors, err := LoadSectors(store, st.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+		partitionSectors := make(PartitionSectorMap)
+		err = partitionSectors.AddValues(pIdx, uint64(sectorNo))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add sector %v", sectorNo)
+
+		quant := st.QuantSpecForDeadline(dlIdx)
+		faultyBefore := deadline.FaultyPower
+		removedPower, err := deadline.TerminateSectors(store, sectors, currEpoch, partitionSectors, info.SectorSize, quant)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to terminate sector %v in deadline %d", sectorNo, dlIdx)
+
+		st.EarlyTerminations.Set(dlIdx)
+		powerDelta = powerDelta.Sub(removedPower)
+		faultyPowerDelta = deadline.FaultyPower.Sub(faultyBefore)
+
+		err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", dlIdx)
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
+
+	epochReward := requestCurrentEpochBlockReward(rt)
+	pwrTotal := requestCurrentTotalPower(rt)
+
+	more, _ := processEarlyTerminations(rt, epochReward.ThisEpochRewardSmoothed, pwrTotal.QualityAdjPowerSmoothed, big.Zero())
+	if more && !hadEarlyTerminations {
+		scheduleEarlyTerminationWork(rt)
+	}
+
+	rt.StateReadonly(&st)
+	err := st.CheckBalanceInvariants(rt.CurrentBalance())
+	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
+
+	requestUpdatePower(rt, powerDelta, faultyPowerDelta)
+	return nil
+}
+
 ////////////
 // Faults //
 ////////////
@@ -1581,46 +2927,26 @@ func (a Actor) DeclareFaults(rt Runtime, params *DeclareFaultsParams) *abi.Empty
 			"failed to process deadline %d, partition %d", term.Deadline, term.Partition,
 		)
 	}
-	err := toProcess.Check(AddressedPartitionsMax, AddressedSectorsMax)
+	limits := loadAddressingLimits(rt)
+	err := toProcess.Check(limits.Partitions, limits.Sectors)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "cannot process requested parameters")
 
 	store := adt.AsStore(rt)
 	var st State
 	powerDelta := NewPowerPairZero()
+	faultyPowerDelta := NewPowerPairZero()
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
 
-		deadlines, err := st.LoadDeadlines(store)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
-
-		sectors, err := LoadSectors(store, st.Sectors)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+		msm, err := st.mutator(store).withDeadlines(adt.PermitWrite).
+			withSectors(adt.PermitReadOnly).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+		deadlines, sectors := msm.deadlines, msm.sectors
 
-		currEpoch := rt.CurrEpoch()
-		err = toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
-			targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid fault declaration deadline %d", dlIdx)
+		powerDelta, faultyPowerDelta = recordDeclaredFaults(rt, &st, info, deadlines, sectors, toProcess)
 
-			err = validateFRDeclarationDeadline(targetDeadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed fault declaration at deadline %d", dlIdx)
-
-			deadline, err := deadlines.LoadDeadline(store, dlIdx)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
-
-			faultExpirationEpoch := targetDeadline.Last() + FaultMaxAge
-			deadlinePowerDelta, err := deadline.RecordFaults(store, sectors, info.SectorSize, QuantSpecForDeadline(targetDeadline), faultExpirationEpoch, pm)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare faults for deadline %d", dlIdx)
-
-			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d partitions", dlIdx)
-
-			powerDelta = powerDelta.Add(deadlinePowerDelta)
-			return nil
-		})
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate deadlines")
-
-		err = st.SaveDeadlines(store, deadlines)
+		err = msm.commitState()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
 	})
 
@@ -1628,7 +2954,7 @@ func (a Actor) DeclareFaults(rt Runtime, params *DeclareFaultsParams) *abi.Empty
 	// NOTE: It would be permissible to delay the power loss until the deadline closes, but that would require
 	// additional accounting state.
 	// https://github.com/filecoin-project/specs-actors/issues/414
-	requestUpdatePower(rt, powerDelta)
+	requestUpdatePower(rt, powerDelta, faultyPowerDelta)
 
 	// Payment of penalty for declared faults is deferred to the deadline cron.
 	return nil
@@ -1650,21 +2976,41 @@ type DeclareFaultsRecoveredParams = miner0.DeclareFaultsRecoveredParams
 type RecoveryDeclaration = miner0.RecoveryDeclaration
 
 func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecoveredParams) *abi.EmptyValue {
-	if len(params.Recoveries) > DeclarationsMax {
+	declareFaultsRecovered(rt, params.Recoveries, false)
+	return nil
+}
+
+type DeclareFaultsRecovered2Params struct {
+	Recoveries []RecoveryDeclaration
+	// When set, recoveries that would be rejected because the miner's unlocked balance cannot repay
+	// its fee debt are instead queued, and declared by the first deadline cron after the debt is repaid.
+	QueueIfInDebt bool
+}
+
+// Declares recoveries as DeclareFaultsRecovered, except that recoveries may be queued while the miner
+// cannot repay its fee debt, by setting QueueIfInDebt.
+func (a Actor) DeclareFaultsRecovered2(rt Runtime, params *DeclareFaultsRecovered2Params) *abi.EmptyValue {
+	declareFaultsRecovered(rt, params.Recoveries, params.QueueIfInDebt)
+	return nil
+}
+
+func declareFaultsRecovered(rt Runtime, recoveries []RecoveryDeclaration, queueIfInDebt bool) {
+	if len(recoveries) > DeclarationsMax {
 		rt.Abortf(exitcode.ErrIllegalArgument,
 			"too many recovery declarations for a single message: %d > %d",
-			len(params.Recoveries), DeclarationsMax,
+			len(recoveries), DeclarationsMax,
 		)
 	}
 
 	toProcess := make(DeadlineSectorMap)
-	for _, term := range params.Recoveries {
+	for _, term := range recoveries {
 		err := toProcess.Add(term.Deadline, term.Partition, term.Sectors)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
 			"failed to process deadline %d, partition %d", term.Deadline, term.Partition,
 		)
 	}
-	err := toProcess.Check(AddressedPartitionsMax, AddressedSectorsMax)
+	limits := loadAddressingLimits(rt)
+	err := toProcess.Check(limits.Partitions, limits.Sectors)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "cannot process requested parameters")
 
 	store := adt.AsStore(rt)
@@ -1672,8 +3018,18 @@ func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecovered
 	feeToBurn := abi.NewTokenAmount(0)
 	rt.StateTransaction(&st, func() {
 		// Verify unlocked funds cover both InitialPledgeRequirement and FeeDebt
-		// and repay fee debt now.
-		feeToBurn = RepayDebtsOrAbort(rt, &st)
+		// and repay fee debt now, unless the recoveries may be queued until the fee debt is repaid.
+		queue := false
+		if queueIfInDebt {
+			var err error
+			feeToBurn, err = st.repayDebts(rt.CurrentBalance())
+			queue = exitcode.Unwrap(err, exitcode.Ok) == exitcode.ErrInsufficientFunds
+			if !queue {
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "unlocked balance can not repay fee debt")
+			}
+		} else {
+			feeToBurn = RepayDebtsOrAbort(rt, &st)
+		}
 
 		info := getMinerInfo(rt, &st)
 		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
@@ -1681,32 +3037,127 @@ func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecovered
 			rt.Abortf(exitcode.ErrForbidden, "recovery not allowed during active consensus fault")
 		}
 
-		deadlines, err := st.LoadDeadlines(adt.AsStore(rt))
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+		currEpoch := rt.CurrEpoch()
+		if queue {
+			queued, err := st.LoadQueuedRecoveries(store)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load queued recoveries")
+			err = toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+				_, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid recovery declaration deadline %d", dlIdx)
+				return pm.ForEach(func(partIdx uint64, sectorNos bitfield.BitField) error {
+					return queued.Add(dlIdx, partIdx, sectorNos)
+				})
+			})
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to queue recoveries")
+			err = queued.Check(limits.Partitions, limits.Sectors)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "too many queued recoveries")
+
+			err = st.SaveQueuedRecoveries(store, queued)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save queued recoveries")
+			rt.Log(rtt.INFO, "miner %s queued recoveries pending repayment of fee debt %v", rt.Receiver(), st.FeeDebt)
+			return
+		}
 
-		sectors, err := LoadSectors(store, st.Sectors)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+		msm, err := st.mutator(store).withDeadlines(adt.PermitWrite).
+			withSectors(adt.PermitReadOnly).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+		deadlines, sectors := msm.deadlines, msm.sectors
 
-		currEpoch := rt.CurrEpoch()
-		err = toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
-			targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid recovery declaration deadline %d", dlIdx)
-			err = validateFRDeclarationDeadline(targetDeadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed recovery declaration at deadline %d", dlIdx)
+		recordDeclaredRecoveries(rt, &st, info, deadlines, sectors, toProcess)
 
-			deadline, err := deadlines.LoadDeadline(store, dlIdx)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
+
+	burnFunds(rt, feeToBurn, BurnMethodDeclareFaultsRecovered)
+	rt.StateReadonly(&st)
+	err = st.CheckBalanceInvariants(rt.CurrentBalance())
+	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
 
-			err = deadline.DeclareFaultsRecovered(store, sectors, info.SectorSize, pm)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare recoveries for deadline %d", dlIdx)
+	// Power is not restored yet, but when the recovered sectors are successfully PoSted.
+}
 
-			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d", dlIdx)
-			return nil
-		})
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to walk sectors")
+type DeclareFaultsAndRecoveriesParams struct {
+	Faults     []FaultDeclaration
+	Recoveries []RecoveryDeclaration
+}
 
-		err = st.SaveDeadlines(store, deadlines)
+// Declares faults and recoveries in a single message, for a miner whose sectors at a deadline are in mixed health.
+// The declarations are subject to the same checks as DeclareFaults and DeclareFaultsRecovered, with the limits
+// applying to their combined total. A sector may not be declared both faulty and recovered.
+func (a Actor) DeclareFaultsAndRecoveries(rt Runtime, params *DeclareFaultsAndRecoveriesParams) *abi.EmptyValue {
+	if count := len(params.Faults) + len(params.Recoveries); count > DeclarationsMax {
+		rt.Abortf(exitcode.ErrIllegalArgument,
+			"too many fault and recovery declarations for a single message: %d > %d",
+			count, DeclarationsMax,
+		)
+	}
+
+	faults := make(DeadlineSectorMap)
+	recoveries := make(DeadlineSectorMap)
+	combined := make(DeadlineSectorMap)
+	for _, decl := range params.Faults {
+		err := faults.Add(decl.Deadline, decl.Partition, decl.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
+			"failed to process deadline %d, partition %d", decl.Deadline, decl.Partition,
+		)
+		err = combined.Add(decl.Deadline, decl.Partition, decl.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
+			"failed to process deadline %d, partition %d", decl.Deadline, decl.Partition,
+		)
+	}
+	for _, decl := range params.Recoveries {
+		err := recoveries.Add(decl.Deadline, decl.Partition, decl.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
+			"failed to process deadline %d, partition %d", decl.Deadline, decl.Partition,
+		)
+		err = combined.Add(decl.Deadline, decl.Partition, decl.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
+			"failed to process deadline %d, partition %d", decl.Deadline, decl.Partition,
+		)
+	}
+	limits := loadAddressingLimits(rt)
+	err := combined.Check(limits.Partitions, limits.Sectors)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "cannot process requested parameters")
+
+	// The combined map holds the union of the declared sectors, so is smaller than the sum of the two only
+	// if some sector is declared both faulty and recovered.
+	_, faultCount, err := faults.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to count faults")
+	_, recoveryCount, err := recoveries.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to count recoveries")
+	_, combinedCount, err := combined.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to count declarations")
+	if combinedCount != faultCount+recoveryCount {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sectors declared both faulty and recovered")
+	}
+
+	store := adt.AsStore(rt)
+	var st State
+	feeToBurn := abi.NewTokenAmount(0)
+	powerDelta := NewPowerPairZero()
+	faultyPowerDelta := NewPowerPairZero()
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+
+		// Recoveries require the fee debt to be repaid, as in DeclareFaultsRecovered.
+		if len(params.Recoveries) > 0 {
+			feeToBurn = RepayDebtsOrAbort(rt, &st)
+			if ConsensusFaultActive(info, rt.CurrEpoch()) {
+				rt.Abortf(exitcode.ErrForbidden, "recovery not allowed during active consensus fault")
+			}
+		}
+
+		msm, err := st.mutator(store).withDeadlines(adt.PermitWrite).
+			withSectors(adt.PermitReadOnly).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+		deadlines, sectors := msm.deadlines, msm.sectors
+
+		powerDelta, faultyPowerDelta = recordDeclaredFaults(rt, &st, info, deadlines, sectors, faults)
+		recordDeclaredRecoveries(rt, &st, info, deadlines, sectors, recoveries)
+
+		err = msm.commitState()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
 	})
 
@@ -1715,7 +3166,93 @@ func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecovered
 	err = st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
 
-	// Power is not restored yet, but when the recovered sectors are successfully PoSted.
+	// Remove power for the new faults. Power for recovered sectors is restored when they are next PoSted.
+	requestUpdatePower(rt, powerDelta, faultyPowerDelta)
+	return nil
+}
+
+// Records declared faults in the deadlines, returning the change in power.
+func recordDeclaredFaults(rt Runtime, st *State, info *MinerInfo, deadlines *Deadlines, sectors Sectors, toProcess DeadlineSectorMap) (PowerPair, PowerPair) {
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	powerDelta := NewPowerPairZero()
+	faultyPowerDelta := NewPowerPairZero()
+	err := toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+		targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid fault declaration deadline %d", dlIdx)
+
+		err = validateFRDeclarationDeadline(targetDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed fault declaration at deadline %d", dlIdx)
+
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+
+		if window := st.MaintenanceWindow; window != nil && window.Active(currEpoch) {
+			err = window.recordDeclaredFaults(store, deadline, pm)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to record maintenance faults for deadline %d", dlIdx)
+		}
+
+		faultExpirationEpoch := targetDeadline.Last() + FaultMaxAge
+		faultyBefore := deadline.FaultyPower
+		deadlinePowerDelta, err := deadline.RecordFaults(store, sectors, info.SectorSize, QuantSpecForDeadline(targetDeadline), faultExpirationEpoch, pm)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare faults for deadline %d", dlIdx)
+		faultyPowerDelta = faultyPowerDelta.Add(deadline.FaultyPower.Sub(faultyBefore))
+
+		err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d partitions", dlIdx)
+
+		powerDelta = powerDelta.Add(deadlinePowerDelta)
+		return nil
+	})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate deadlines")
+	return powerDelta, faultyPowerDelta
+}
+
+// Records declared recoveries in the deadlines.
+func recordDeclaredRecoveries(rt Runtime, st *State, info *MinerInfo, deadlines *Deadlines, sectors Sectors, toProcess DeadlineSectorMap) {
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	err := toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+		targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid recovery declaration deadline %d", dlIdx)
+		err = validateFRDeclarationDeadline(targetDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed recovery declaration at deadline %d", dlIdx)
+
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+
+		err = deadline.DeclareFaultsRecovered(store, sectors, info.SectorSize, pm)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare recoveries for deadline %d", dlIdx)
+
+		err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d", dlIdx)
+		return nil
+	})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to walk sectors")
+}
+
+type DeclareMaintenanceWindowParams struct {
+	// First epoch of the window.
+	Start abi.ChainEpoch
+	// Number of epochs in the window.
+	Duration abi.ChainEpoch
+}
+
+// Declares a window for planned maintenance, during which sectors declared faulty pay a reduced continued
+// fault fee until the window ends.
+// The window must be declared at least MaintenanceWindowNotice epochs in advance and lie within a single
+// quota period, and the windows starting in a quota period may total at most MaxMaintenanceEpochsPerQuotaPeriod
+// epochs. A window may not be declared while a previously declared window is pending or active.
+func (a Actor) DeclareMaintenanceWindow(rt Runtime, params *DeclareMaintenanceWindowParams) *abi.EmptyValue {
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+
+		window, err := newMaintenanceWindow(st.MaintenanceWindow, rt.CurrEpoch(), params.Start, params.Duration)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid maintenance window")
+		st.MaintenanceWindow = window
+	})
 	return nil
 }
 
@@ -1723,35 +3260,266 @@ func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecovered
 // Maintenance //
 /////////////////
 
-//type CompactPartitionsParams struct {
-//	Deadline   uint64
-//	Partitions bitfield.BitField
-//}
-type CompactPartitionsParams = miner0.CompactPartitionsParams
+//type CompactPartitionsParams struct {
+//	Deadline   uint64
+//	Partitions bitfield.BitField
+//}
+type CompactPartitionsParams = miner0.CompactPartitionsParams
+
+// Compacts a number of partitions at one deadline by removing terminated sectors, re-ordering the remaining sectors,
+// and assigning them to new partitions so as to completely fill all but one partition with live sectors.
+// The addressed partitions are removed from the deadline, and new ones appended.
+// The final partition in the deadline is always included in the compaction, whether or not explicitly requested.
+// Removed sectors are removed from state entirely.
+// May not be invoked if the deadline has any un-processed early terminations.
+func (a Actor) CompactPartitions(rt Runtime, params *CompactPartitionsParams) *abi.EmptyValue {
+	compactPartitions(rt, params.Deadline, params.Partitions, false)
+	return nil
+}
+
+type CompactPartitions2Params struct {
+	Deadline   uint64
+	Partitions bitfield.BitField
+	// When set, a batch of pending early terminations is processed before compacting, and
+	// un-processed early terminations only prevent compaction of the partitions that hold them.
+	ProcessEarlyTerminations bool
+}
+
+// Compacts partitions as CompactPartitions, except that pending early terminations may first be processed,
+// by setting ProcessEarlyTerminations, in which case only the addressed partitions must be free of them.
+func (a Actor) CompactPartitions2(rt Runtime, params *CompactPartitions2Params) *abi.EmptyValue {
+	compactPartitions(rt, params.Deadline, params.Partitions, params.ProcessEarlyTerminations)
+	return nil
+}
+
+func compactPartitions(rt Runtime, dlIdx uint64, partitions bitfield.BitField, allowEarlyTerminations bool) {
+	if dlIdx >= WPoStPeriodDeadlines {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid deadline %v", dlIdx)
+	}
+
+	partitionCount, err := partitions.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to parse partitions bitfield")
+
+	store := adt.AsStore(rt)
+	var st State
+	rt.StateReadonly(&st)
+	info := getMinerInfo(rt, &st)
+	rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+
+	// A cron callback is already scheduled whenever terminations are pending,
+	// so there is no need to schedule one if this doesn't drain the queue.
+	if allowEarlyTerminations && havePendingEarlyTerminations(rt, &st) {
+		epochReward := requestCurrentEpochBlockReward(rt)
+		pwrTotal := requestCurrentTotalPower(rt)
+		processEarlyTerminations(rt, epochReward.ThisEpochRewardSmoothed, pwrTotal.QualityAdjPowerSmoothed, big.Zero())
+	}
+
+	rt.StateTransaction(&st, func() {
+		if !deadlineAvailableForCompaction(st.CurrentProvingPeriodStart(rt.CurrEpoch()), dlIdx, rt.CurrEpoch()) {
+			rt.Abortf(ErrImmutableDeadline,
+				"cannot compact deadline %d during its challenge window, or the prior challenge window, or before %d epochs have passed since its last challenge window ended", dlIdx, WPoStDisputeWindow)
+		}
+
+		submissionPartitionLimit := loadPartitionsSectorsMax(info.WindowPoStPartitionSectors)
+		if partitionCount > submissionPartitionLimit {
+			rt.Abortf(exitcode.ErrIllegalArgument, "too many partitions %d, limit %d", partitionCount, submissionPartitionLimit)
+		}
+
+		quant := st.QuantSpecForDeadline(dlIdx)
+
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+
+		var live, dead bitfield.BitField
+		var removedPower PowerPair
+		if allowEarlyTerminations {
+			live, dead, removedPower, err = deadline.RemovePartitionsAllowingEarlyTerminations(store, partitions, quant)
+		} else {
+			live, dead, removedPower, err = deadline.RemovePartitions(store, partitions, quant)
+		}
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove partitions from deadline %d", dlIdx)
+
+		err = st.DeleteSectors(store, dead)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete dead sectors")
+
+		sectors, err := st.LoadSectorInfos(store, live)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load moved sectors")
+
+		proven := true
+		addedPower, err := deadline.AddSectors(store, info.WindowPoStPartitionSectors, proven, sectors, info.SectorSize, quant)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add back moved sectors")
+
+		if !removedPower.Equals(addedPower) {
+			rt.Abortf(exitcode.ErrIllegalState, "power changed when compacting partitions: was %v, is now %v", removedPower, addedPower)
+		}
+		err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", dlIdx)
+
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
+}
+
+type MovePartitionsParams struct {
+	OrigDeadline uint64
+	DestDeadline uint64
+	Partitions   bitfield.BitField
+}
+
+// Moves a number of partitions from one deadline to another, e.g. to consolidate them onto fewer deadlines.
+// As in compaction, terminated sectors are removed from state, and the live sectors re-assigned to partitions
+// at the destination deadline, filling its last partition first. The addressed partitions are removed from the
+// origin deadline.
+// The addressed partitions must have no faults, unproven sectors, or un-processed early terminations.
+// Partitions may be moved only once proofs at the origin deadline may no longer be disputed, and only to a
+// deadline whose challenge window next opens before the origin's, so that they are proven at least once
+// each proving period.
+func (a Actor) MovePartitions(rt Runtime, params *MovePartitionsParams) *abi.EmptyValue {
+	if params.OrigDeadline >= WPoStPeriodDeadlines {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid origin deadline %v", params.OrigDeadline)
+	}
+	if params.DestDeadline >= WPoStPeriodDeadlines {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid destination deadline %v", params.DestDeadline)
+	}
+	if params.OrigDeadline == params.DestDeadline {
+		rt.Abortf(exitcode.ErrIllegalArgument, "cannot move partitions within deadline %d", params.OrigDeadline)
+	}
+
+	partitionCount, err := params.Partitions.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to parse partitions bitfield")
+	if partitionCount == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no partitions to move")
+	}
+
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+
+		provingPeriodStart := st.CurrentProvingPeriodStart(currEpoch)
+		if !deadlineAvailableForCompaction(provingPeriodStart, params.OrigDeadline, currEpoch) {
+			rt.Abortf(ErrImmutableDeadline,
+				"cannot move partitions from deadline %d during its challenge window, or the prior challenge window, or before %d epochs have passed since its last challenge window ended", params.OrigDeadline, WPoStDisputeWindow)
+		}
+		if !deadlineIsMutable(provingPeriodStart, params.DestDeadline, currEpoch) {
+			rt.Abortf(ErrImmutableDeadline,
+				"cannot move partitions to deadline %d during its challenge window, or the prior challenge window", params.DestDeadline)
+		}
+		origNextOpen := NewDeadlineInfo(provingPeriodStart, params.OrigDeadline, currEpoch).NextNotElapsed().Open
+		destNextOpen := NewDeadlineInfo(provingPeriodStart, params.DestDeadline, currEpoch).NextNotElapsed().Open
+		if destNextOpen > origNextOpen {
+			rt.Abortf(exitcode.ErrForbidden, "cannot move partitions to deadline %d opening at %d, after deadline %d opening at %d",
+				params.DestDeadline, destNextOpen, params.OrigDeadline, origNextOpen)
+		}
+
+		submissionPartitionLimit := loadPartitionsSectorsMax(info.WindowPoStPartitionSectors)
+		if partitionCount > submissionPartitionLimit {
+			rt.Abortf(exitcode.ErrIllegalArgument, "too many partitions %d, limit %d", partitionCount, submissionPartitionLimit)
+		}
+
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+
+		origDeadline, err := deadlines.LoadDeadline(store, params.OrigDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.OrigDeadline)
+		destDeadline, err := deadlines.LoadDeadline(store, params.DestDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.DestDeadline)
+
+		live, dead, removedPower, err := origDeadline.RemovePartitionsAllowingEarlyTerminations(store, params.Partitions, st.QuantSpecForDeadline(params.OrigDeadline))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove partitions from deadline %d", params.OrigDeadline)
+
+		err = st.DeleteSectors(store, dead)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete dead sectors")
+
+		sectors, err := st.LoadSectorInfos(store, live)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load moved sectors")
+
+		proven := true
+		addedPower, err := destDeadline.AddSectors(store, info.WindowPoStPartitionSectors, proven, sectors, info.SectorSize, st.QuantSpecForDeadline(params.DestDeadline))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add moved sectors to deadline %d", params.DestDeadline)
+
+		if !removedPower.Equals(addedPower) {
+			rt.Abortf(exitcode.ErrIllegalState, "power changed when moving partitions: was %v, is now %v", removedPower, addedPower)
+		}
+
+		destPartitions, err := destDeadline.PartitionsArray(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", params.DestDeadline)
+		if destPartitions.Length() > MaxPartitionsPerDeadline {
+			rt.Abortf(exitcode.ErrIllegalArgument, "moving partitions would leave %d partitions at deadline %d, limit %d",
+				destPartitions.Length(), params.DestDeadline, MaxPartitionsPerDeadline)
+		}
+
+		err = deadlines.UpdateDeadline(store, params.OrigDeadline, origDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", params.OrigDeadline)
+		err = deadlines.UpdateDeadline(store, params.DestDeadline, destDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", params.DestDeadline)
+
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
+	return nil
+}
+
+type RebalanceSectorsParams struct {
+	OrigDeadline uint64
+	DestDeadline uint64
+	// Partitions at the origin deadline from which to take sectors.
+	Partitions bitfield.BitField
+	// Maximum number of live sectors to move to the destination deadline.
+	MaxSectors uint64
+}
 
-// Compacts a number of partitions at one deadline by removing terminated sectors, re-ordering the remaining sectors,
-// and assigning them to new partitions so as to completely fill all but one partition with live sectors.
-// The addressed partitions are removed from the deadline, and new ones appended.
-// The final partition in the deadline is always included in the compaction, whether or not explicitly requested.
-// Removed sectors are removed from state entirely.
-// May not be invoked if the deadline has any un-processed early terminations.
-func (a Actor) CompactPartitions(rt Runtime, params *CompactPartitionsParams) *abi.EmptyValue {
-	if params.Deadline >= WPoStPeriodDeadlines {
-		rt.Abortf(exitcode.ErrIllegalArgument, "invalid deadline %v", params.Deadline)
+// Moves live sectors from a deadline to a less loaded one.
+// The addressed partitions are removed from the origin deadline, as when moving partitions. Up to MaxSectors
+// of their live sectors are assigned to the destination deadline, and the rest re-assigned to the origin.
+// No more sectors are moved than would leave the destination with as many live sectors as the origin.
+// The same restrictions on the deadlines and partitions apply as for MovePartitions.
+func (a Actor) RebalanceSectors(rt Runtime, params *RebalanceSectorsParams) *abi.EmptyValue {
+	if params.OrigDeadline >= WPoStPeriodDeadlines {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid origin deadline %v", params.OrigDeadline)
+	}
+	if params.DestDeadline >= WPoStPeriodDeadlines {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid destination deadline %v", params.DestDeadline)
+	}
+	if params.OrigDeadline == params.DestDeadline {
+		rt.Abortf(exitcode.ErrIllegalArgument, "cannot rebalance sectors within deadline %d", params.OrigDeadline)
+	}
+	if params.MaxSectors == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no sectors to move")
 	}
 
 	partitionCount, err := params.Partitions.Count()
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to parse partitions bitfield")
+	if partitionCount == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no partitions to take sectors from")
+	}
 
 	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
 	var st State
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
 
-		if !deadlineAvailableForCompaction(st.CurrentProvingPeriodStart(rt.CurrEpoch()), params.Deadline, rt.CurrEpoch()) {
-			rt.Abortf(exitcode.ErrForbidden,
-				"cannot compact deadline %d during its challenge window, or the prior challenge window, or before %d epochs have passed since its last challenge window ended", params.Deadline, WPoStDisputeWindow)
+		provingPeriodStart := st.CurrentProvingPeriodStart(currEpoch)
+		if !deadlineAvailableForCompaction(provingPeriodStart, params.OrigDeadline, currEpoch) {
+			rt.Abortf(ErrImmutableDeadline,
+				"cannot move sectors from deadline %d during its challenge window, or the prior challenge window, or before %d epochs have passed since its last challenge window ended", params.OrigDeadline, WPoStDisputeWindow)
+		}
+		if !deadlineIsMutable(provingPeriodStart, params.DestDeadline, currEpoch) {
+			rt.Abortf(ErrImmutableDeadline,
+				"cannot move sectors to deadline %d during its challenge window, or the prior challenge window", params.DestDeadline)
+		}
+		origNextOpen := NewDeadlineInfo(provingPeriodStart, params.OrigDeadline, currEpoch).NextNotElapsed().Open
+		destNextOpen := NewDeadlineInfo(provingPeriodStart, params.DestDeadline, currEpoch).NextNotElapsed().Open
+		if destNextOpen > origNextOpen {
+			rt.Abortf(exitcode.ErrForbidden, "cannot move sectors to deadline %d opening at %d, after deadline %d opening at %d",
+				params.DestDeadline, destNextOpen, params.OrigDeadline, origNextOpen)
 		}
 
 		submissionPartitionLimit := loadPartitionsSectorsMax(info.WindowPoStPartitionSectors)
@@ -1759,32 +3527,58 @@ func (a Actor) CompactPartitions(rt Runtime, params *CompactPartitionsParams) *a
 			rt.Abortf(exitcode.ErrIllegalArgument, "too many partitions %d, limit %d", partitionCount, submissionPartitionLimit)
 		}
 
-		quant := st.QuantSpecForDeadline(params.Deadline)
-
 		deadlines, err := st.LoadDeadlines(store)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
 
-		deadline, err := deadlines.LoadDeadline(store, params.Deadline)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.Deadline)
+		origDeadline, err := deadlines.LoadDeadline(store, params.OrigDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.OrigDeadline)
+		destDeadline, err := deadlines.LoadDeadline(store, params.DestDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.DestDeadline)
 
-		live, dead, removedPower, err := deadline.RemovePartitions(store, params.Partitions, quant)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove partitions from deadline %d", params.Deadline)
+		if origDeadline.LiveSectors <= destDeadline.LiveSectors+1 {
+			rt.Abortf(exitcode.ErrForbidden, "cannot balance deadline %d with %d live sectors against deadline %d with %d",
+				params.OrigDeadline, origDeadline.LiveSectors, params.DestDeadline, destDeadline.LiveSectors)
+		}
+		moveLimit := (origDeadline.LiveSectors - destDeadline.LiveSectors) / 2
+		if params.MaxSectors < moveLimit {
+			moveLimit = params.MaxSectors
+		}
+
+		origQuant := st.QuantSpecForDeadline(params.OrigDeadline)
+		live, dead, removedPower, err := origDeadline.RemovePartitionsAllowingEarlyTerminations(store, params.Partitions, origQuant)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove partitions from deadline %d", params.OrigDeadline)
 
 		err = st.DeleteSectors(store, dead)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete dead sectors")
 
 		sectors, err := st.LoadSectorInfos(store, live)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load moved sectors")
+		moveCount := len(sectors)
+		if uint64(moveCount) > moveLimit {
+			moveCount = int(moveLimit)
+		}
 
 		proven := true
-		addedPower, err := deadline.AddSectors(store, info.WindowPoStPartitionSectors, proven, sectors, info.SectorSize, quant)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add back moved sectors")
+		movedPower, err := destDeadline.AddSectors(store, info.WindowPoStPartitionSectors, proven, sectors[:moveCount], info.SectorSize, st.QuantSpecForDeadline(params.DestDeadline))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add moved sectors to deadline %d", params.DestDeadline)
+		keptPower, err := origDeadline.AddSectors(store, info.WindowPoStPartitionSectors, proven, sectors[moveCount:], info.SectorSize, origQuant)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to re-add sectors to deadline %d", params.OrigDeadline)
 
-		if !removedPower.Equals(addedPower) {
-			rt.Abortf(exitcode.ErrIllegalState, "power changed when compacting partitions: was %v, is now %v", removedPower, addedPower)
+		if addedPower := movedPower.Add(keptPower); !removedPower.Equals(addedPower) {
+			rt.Abortf(exitcode.ErrIllegalState, "power changed when rebalancing sectors: was %v, is now %v", removedPower, addedPower)
+		}
+
+		destPartitions, err := destDeadline.PartitionsArray(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", params.DestDeadline)
+		if destPartitions.Length() > MaxPartitionsPerDeadline {
+			rt.Abortf(exitcode.ErrIllegalArgument, "moving sectors would leave %d partitions at deadline %d, limit %d",
+				destPartitions.Length(), params.DestDeadline, MaxPartitionsPerDeadline)
 		}
-		err = deadlines.UpdateDeadline(store, params.Deadline, deadline)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", params.Deadline)
+
+		err = deadlines.UpdateDeadline(store, params.OrigDeadline, origDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", params.OrigDeadline)
+		err = deadlines.UpdateDeadline(store, params.DestDeadline, destDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", params.DestDeadline)
 
 		err = st.SaveDeadlines(store, deadlines)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
@@ -1838,6 +3632,10 @@ func (a Actor) ApplyRewards(rt Runtime, params *builtin.ApplyRewardParams) *abi.
 	if params.Penalty.Sign() < 0 {
 		rt.Abortf(exitcode.ErrIllegalArgument, "cannot penalize a negative amount of funds")
 	}
+	rewardToLock, lockedRewardVestingSpec := LockedRewardFromReward(params.Reward, params.VestingSpecID)
+	if lockedRewardVestingSpec == nil {
+		rt.Abortf(exitcode.ErrIllegalArgument, "unknown vesting spec %d", params.VestingSpecID)
+	}
 
 	var st State
 	pledgeDeltaTotal := big.Zero()
@@ -1847,8 +3645,6 @@ func (a Actor) ApplyRewards(rt Runtime, params *builtin.ApplyRewardParams) *abi.
 		store := adt.AsStore(rt)
 		rt.ValidateImmediateCallerIs(builtin.RewardActorAddr)
 
-		rewardToLock, lockedRewardVestingSpec := LockedRewardFromReward(params.Reward)
-
 		// This ensures the miner has sufficient funds to lock up amountToLock.
 		// This should always be true if reward actor sends reward funds with the message.
 		unlockedBalance, err := st.GetUnlockedBalance(rt.CurrentBalance())
@@ -1874,7 +3670,7 @@ func (a Actor) ApplyRewards(rt Runtime, params *builtin.ApplyRewardParams) *abi.
 		toBurn = big.Add(penaltyFromVesting, penaltyFromBalance)
 	})
 
-	notifyPledgeChanged(rt, pledgeDeltaTotal)
+	notifyPledgeChanged(rt, big.Zero(), big.Zero(), pledgeDeltaTotal)
 	burnFunds(rt, toBurn, BurnMethodApplyRewards)
 	rt.StateReadonly(&st)
 	err := st.CheckBalanceInvariants(rt.CurrentBalance())
@@ -1890,6 +3686,11 @@ func (a Actor) ApplyRewards(rt Runtime, params *builtin.ApplyRewardParams) *abi.
 //}
 type ReportConsensusFaultParams = miner0.ReportConsensusFaultParams
 
+// Reports a consensus fault by this miner, penalizing it and rewarding the reporter.
+// The reporter must send at least ConsensusFaultReporterBond with the report. The required bond is
+// forfeit if the report is invalid: the fault cannot be verified, is by another miner, or is not yet
+// in the past. A report of a fault the miner has already been penalized for aborts, refunding the bond,
+// since reports of the same fault by different reporters may race.
 func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultParams) *abi.EmptyValue {
 	// Note: only the first report of any fault is processed because it sets the
 	// ConsensusFaultElapsed state variable to an epoch after the fault, and reports prior to
@@ -1897,29 +3698,47 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
 	reporter := rt.Caller()
 
+	// The value sent with the report is held as a bond against an invalid report.
+	// It is returned with the reward on success.
+	bond := rt.ValueReceived()
+	if bond.LessThan(ConsensusFaultReporterBond) {
+		rt.Abortf(exitcode.ErrInsufficientFunds, "reporter bond %v less than required %v", bond, ConsensusFaultReporterBond)
+	}
+
+	// Aborting would refund the bond, so an invalid report completes successfully with the bond forfeited.
 	fault, err := rt.VerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra)
 	if err != nil {
-		rt.Abortf(exitcode.ErrIllegalArgument, "fault not verified: %s", err)
+		forfeitReporterBond(rt, reporter, bond, "consensus fault not verified: %s", err)
+		return nil
 	}
 	if fault.Target != rt.Receiver() {
-		rt.Abortf(exitcode.ErrIllegalArgument, "fault by %v reported to miner %v", fault.Target, rt.Receiver())
+		forfeitReporterBond(rt, reporter, bond, "fault by %v reported to miner %v", fault.Target, rt.Receiver())
+		return nil
 	}
 
 	// Elapsed since the fault (i.e. since the higher of the two blocks)
 	currEpoch := rt.CurrEpoch()
 	faultAge := currEpoch - fault.Epoch
 	if faultAge <= 0 {
-		rt.Abortf(exitcode.ErrIllegalArgument, "invalid fault epoch %v ahead of current %v", fault.Epoch, currEpoch)
+		forfeitReporterBond(rt, reporter, bond, "invalid fault epoch %v ahead of current %v", fault.Epoch, currEpoch)
+		return nil
+	}
+
+	// A fault before the end of the last exclusion period has already been penalized, or is covered by it.
+	var st State
+	rt.StateReadonly(&st)
+	if info := getMinerInfo(rt, &st); fault.Epoch < info.ConsensusFaultElapsed {
+		rt.Abortf(exitcode.ErrForbidden, "fault epoch %d is too old, last exclusion period ended at %d", fault.Epoch, info.ConsensusFaultElapsed)
 	}
 
 	// Penalize miner consensus fault fee
 	// Give a portion of this to the reporter as reward
-	var st State
 	rewardStats := requestCurrentEpochBlockReward(rt)
 	// The policy amounts we should burn and send to reporter
 	// These may differ from actual funds send when miner goes into fee debt
 	thisEpochReward := smoothing.Estimate(&rewardStats.ThisEpochRewardSmoothed)
-	faultPenalty := ConsensusFaultPenalty(thisEpochReward)
+	faultPenalty, err := ConsensusFaultPenalty(thisEpochReward, rt.NetworkVersion())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute consensus fault penalty")
 	slasherReward := RewardForConsensusSlashReport(thisEpochReward)
 	pledgeDelta := big.Zero()
 
@@ -1929,16 +3748,13 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 
-		// verify miner hasn't already been faulted
-		if fault.Epoch < info.ConsensusFaultElapsed {
-			rt.Abortf(exitcode.ErrForbidden, "fault epoch %d is too old, last exclusion period ended at %d", fault.Epoch, info.ConsensusFaultElapsed)
-		}
-
 		err := st.ApplyPenalty(faultPenalty)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
 
 		// Pay penalty
-		penaltyFromVesting, penaltyFromBalance, err := st.RepayPartialDebtInPriorityOrder(adt.AsStore(rt), currEpoch, rt.CurrentBalance())
+		// The reporter's bond is not available to pay the penalty.
+		availableBalance := big.Sub(rt.CurrentBalance(), bond)
+		penaltyFromVesting, penaltyFromBalance, err := st.RepayPartialDebtInPriorityOrder(adt.AsStore(rt), currEpoch, availableBalance)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to pay fees")
 		// Burn the amount actually payable. Any difference in this and faultPenalty already recorded as FeeDebt
 		burnAmount = big.Add(penaltyFromVesting, penaltyFromBalance)
@@ -1952,12 +3768,12 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 		err = st.SaveInfo(adt.AsStore(rt), info)
 		builtin.RequireNoErr(rt, err, exitcode.ErrSerialization, "failed to save miner info")
 	})
-	code := rt.Send(reporter, builtin.MethodSend, nil, rewardAmount, &builtin.Discard{})
+	code := rt.Send(reporter, builtin.MethodSend, nil, big.Add(rewardAmount, bond), &builtin.Discard{})
 	if !code.IsSuccess() {
-		rt.Log(rtt.ERROR, "failed to send reward")
+		rt.Log(rtt.ERROR, "failed to send reward and bond")
 	}
 	burnFunds(rt, burnAmount, BurnMethodReportConsensusFault)
-	notifyPledgeChanged(rt, pledgeDelta)
+	notifyPledgeChanged(rt, big.Zero(), big.Zero(), pledgeDelta)
 
 	rt.StateReadonly(&st)
 	err = st.CheckBalanceInvariants(rt.CurrentBalance())
@@ -1966,14 +3782,28 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 	return nil
 }
 
+// Burns the required bond of an invalid consensus fault report, and refunds any excess to the reporter.
+func forfeitReporterBond(rt Runtime, reporter addr.Address, bond abi.TokenAmount, reason string, args ...interface{}) {
+	rt.Log(rtt.INFO, "invalid consensus fault report, burning reporter bond %v: %s", ConsensusFaultReporterBond, fmt.Sprintf(reason, args...))
+	burnFunds(rt, ConsensusFaultReporterBond, BurnMethodConsensusFaultBond)
+	excess := big.Sub(bond, ConsensusFaultReporterBond)
+	if excess.GreaterThan(big.Zero()) {
+		code := rt.Send(reporter, builtin.MethodSend, nil, excess, &builtin.Discard{})
+		if !code.IsSuccess() {
+			rt.Log(rtt.ERROR, "failed to refund excess reporter bond %v to %v, exitcode: %d", excess, reporter, code)
+		}
+	}
+}
+
 //type WithdrawBalanceParams struct {
 //	AmountRequested abi.TokenAmount
 //}
 type WithdrawBalanceParams = miner0.WithdrawBalanceParams
 
-// Attempt to withdraw the specified amount from the miner's available balance.
-// Only owner key has permission to withdraw.
-// If less than the specified amount is available, yields the entire available balance.
+// Attempt to withdraw the specified amount from the miner's available balance, paying it to the beneficiary.
+// Only the owner and beneficiary have permission to withdraw.
+// If less than the specified amount is available, yields the entire available balance, further limited
+// by the term of a beneficiary other than the owner.
 // Returns the amount withdrawn.
 func (a Actor) WithdrawBalance(rt Runtime, params *WithdrawBalanceParams) *abi.TokenAmount {
 	var st State
@@ -1984,12 +3814,17 @@ func (a Actor) WithdrawBalance(rt Runtime, params *WithdrawBalanceParams) *abi.T
 	newlyVested := big.Zero()
 	feeToBurn := big.Zero()
 	availableBalance := big.Zero()
+	amountWithdrawn := big.Zero()
 	rt.StateTransaction(&st, func() {
 		var err error
 		info = getMinerInfo(rt, &st)
-		// Only the owner is allowed to withdraw the balance as it belongs to/is controlled by the owner
-		// and not the worker.
-		rt.ValidateImmediateCallerIs(info.Owner)
+		// Only the owner and beneficiary are allowed to withdraw the balance as it belongs to/is controlled
+		// by them and not the worker.
+		callers := []addr.Address{info.Owner}
+		if info.Beneficiary != info.Owner {
+			callers = append(callers, info.Beneficiary)
+		}
+		rt.ValidateImmediateCallerIs(callers...)
 
 		// Ensure we don't have any pending terminations.
 		if count, err := st.EarlyTerminations.Count(); err != nil {
@@ -2015,21 +3850,26 @@ func (a Actor) WithdrawBalance(rt Runtime, params *WithdrawBalanceParams) *abi.T
 		// Verify unlocked funds cover both InitialPledgeRequirement and FeeDebt
 		// and repay fee debt now.
 		feeToBurn = RepayDebtsOrAbort(rt, &st)
+
+		amountWithdrawn = big.Min(info.withdrawableByBeneficiary(availableBalance, rt.CurrEpoch()), params.AmountRequested)
+		if info.Beneficiary != info.Owner && amountWithdrawn.GreaterThan(big.Zero()) {
+			info.BeneficiaryTerm.UsedQuota = big.Add(info.BeneficiaryTerm.UsedQuota, amountWithdrawn)
+			err = st.SaveInfo(adt.AsStore(rt), info)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save miner info")
+		}
 	})
 
-	amountWithdrawn := big.Min(availableBalance, params.AmountRequested)
 	builtin.RequireState(rt, amountWithdrawn.GreaterThanEqual(big.Zero()), "negative amount to withdraw: %v", amountWithdrawn)
 	builtin.RequireState(rt, amountWithdrawn.LessThanEqual(availableBalance), "amount to withdraw %v < available %v", amountWithdrawn, availableBalance)
 
 	if amountWithdrawn.GreaterThan(abi.NewTokenAmount(0)) {
-		code := rt.Send(info.Owner, builtin.MethodSend, nil, amountWithdrawn, &builtin.Discard{})
+		code := rt.Send(info.Beneficiary, builtin.MethodSend, nil, amountWithdrawn, &builtin.Discard{})
 		builtin.RequireSuccess(rt, code, "failed to withdraw balance")
 	}
 
 	burnFunds(rt, feeToBurn, BurnMethodWithdrawBalance)
 
-	pledgeDelta := newlyVested.Neg()
-	notifyPledgeChanged(rt, pledgeDelta)
+	notifyPledgeChanged(rt, big.Zero(), big.Zero(), newlyVested.Neg())
 
 	err := st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
@@ -2050,7 +3890,7 @@ func (a Actor) RepayDebt(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock fee debt")
 	})
 
-	notifyPledgeChanged(rt, fromVesting.Neg())
+	notifyPledgeChanged(rt, big.Zero(), big.Zero(), fromVesting.Neg())
 	burnFunds(rt, big.Sum(fromVesting, fromBalance), BurnMethodRepayDebt)
 	err := st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
@@ -2058,14 +3898,259 @@ func (a Actor) RepayDebt(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 	return nil
 }
 
+// Cleans up the miner's expired pre-commitments immediately, burning their deposits, rather than
+// leaving them to deadline cron once the clean up delay has passed.
+// Pre-commitments that may yet be proven are retained. The notification receiver, if any, is notified
+// of the clean up as it is by deadline cron.
+func (a Actor) CleanUpExpiredPreCommits(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+
+	var st State
+	var depositToBurn, fromVesting, fromBalance abi.TokenAmount
+	var notifyReceiver addr.Address
+	var expiredNotification *PreCommitsExpiredParams
+	rt.StateTransaction(&st, func() {
+		var expired bitfield.BitField
+		var err error
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(info.Owner, info.Worker)
+
+		expired, depositToBurn, err = st.CleanUpExpiredPreCommitsNow(store, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to expire pre-committed sectors")
+		if info.NotificationReceiver != nil {
+			empty, err := expired.IsEmpty()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check expired pre-commits")
+			if !empty {
+				notifyReceiver = *info.NotificationReceiver
+				expiredNotification = &PreCommitsExpiredParams{Sectors: expired, DepositBurnt: depositToBurn}
+			}
+		}
+
+		err = st.ApplyPenalty(depositToBurn)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
+		rt.Log(rtt.DEBUG, "storage provider %s penalized %s for expired pre commits", rt.Receiver(), depositToBurn)
+
+		fromVesting, fromBalance, err = st.RepayPartialDebtInPriorityOrder(store, currEpoch, rt.CurrentBalance())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock penalty")
+	})
+
+	burnFunds(rt, big.Sum(fromVesting, fromBalance), BurnMethodCleanUpExpiredPreCommits)
+	notifyPledgeChanged(rt, big.Zero(), depositToBurn.Neg(), fromVesting.Neg())
+	if expiredNotification != nil {
+		notifyPreCommitsExpired(rt, notifyReceiver, expiredNotification)
+	}
+	err := st.CheckBalanceInvariants(rt.CurrentBalance())
+	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
+	return nil
+}
+
 type ReplicaUpdate = miner7.ReplicaUpdate
 
 type ProveReplicaUpdatesParams = miner7.ProveReplicaUpdatesParams
 
 func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams) *bitfield.BitField {
+	updates := make([]ReplicaUpdate2, len(params.Updates))
+	for i, update := range params.Updates {
+		updates[i] = ReplicaUpdate2{
+			SectorID:           update.SectorID,
+			Deadline:           update.Deadline,
+			Partition:          update.Partition,
+			NewSealedSectorCID: update.NewSealedSectorCID,
+			Deals:              update.Deals,
+			UpdateProofType:    update.UpdateProofType,
+			ReplicaProof:       update.ReplicaProof,
+		}
+	}
+	results := proveReplicaUpdates(rt, updates, nil, false, false)
+
+	succeededSectors := bitfield.New()
+	for i, code := range results {
+		if code == exitcode.Ok {
+			succeededSectors.Set(uint64(updates[i].SectorID))
+		}
+	}
+	return &succeededSectors
+}
+
+type ReplicaUpdate2 struct {
+	SectorID           abi.SectorNumber
+	Deadline           uint64
+	Partition          uint64
+	NewSealedSectorCID cid.Cid `checked:"true"`
+	// The unsealed CID of the sector's new data, which must match the commitment computed from the deals.
+	NewUnsealedSectorCID cid.Cid `checked:"true"`
+	Deals                []abi.DealID
+	UpdateProofType      abi.RegisteredUpdateProof
+	ReplicaProof         []byte
+}
+
+type ProveReplicaUpdates2Params struct {
+	Updates []ReplicaUpdate2
+	// Optional manifests of the piece layout of the updated sectors, at most one per sector.
+	// A sector updated without a manifest loses any manifest describing its replaced data.
+	Manifests []SectorManifest
+	// When set, sectors that already hold deals may be updated, ending those deals without penalty.
+	ReplaceDeals bool
+}
+
+type ReplicaUpdateResult struct {
+	SectorNumber abi.SectorNumber
+	// Ok if the sector was updated, or the reason the update was skipped.
+	Code exitcode.ExitCode
+}
+
+type ProveReplicaUpdates2Return struct {
+	// The result of each update, in the order of the params.
+	Results []ReplicaUpdateResult
+}
+
+// Like ProveReplicaUpdates, but with the unsealed CID of each sector's new data given explicitly, and returning
+// the outcome of each update rather than only the sectors updated.
+// An update without an unsealed CID is skipped. An update whose unsealed CID does not match the commitment
+// computed from its deals fails the whole message, as do invalid proofs.
+// The deals of a sector updated with ReplaceDeals are settled in the market actor as of the current epoch,
+// without slashing the provider's collateral, in the same message as the new deals are activated.
+func (a Actor) ProveReplicaUpdates2(rt Runtime, params *ProveReplicaUpdates2Params) *ProveReplicaUpdates2Return {
+	codes := proveReplicaUpdates(rt, params.Updates, params.Manifests, true, params.ReplaceDeals)
+
+	results := make([]ReplicaUpdateResult, len(codes))
+	for i, code := range codes {
+		results[i] = ReplicaUpdateResult{
+			SectorNumber: params.Updates[i].SectorID,
+			Code:         code,
+		}
+	}
+	return &ProveReplicaUpdates2Return{Results: results}
+}
+
+type RollbackReplicaUpdatesParams struct {
+	Sectors bitfield.BitField
+}
+
+// Rolls back the replica updates of committed-capacity sectors, restoring the sectors' infos as they were before
+// the updates and terminating the deals the updates activated.
+// An update may be rolled back only before the sector's deadline next opens after the update, so that no
+// Window PoSt can have covered the new replica. The deadline must also be mutable, the sector healthy, and its
+// expiration unchanged since the update. Any initial pledge added for the update is released, while the
+// terminated deals are settled by the market actor as for a terminated sector, and the verified registry
+// claims made for them are removed.
+func (a Actor) RollbackReplicaUpdates(rt Runtime, params *RollbackReplicaUpdatesParams) *abi.EmptyValue {
+	count, err := params.Sectors.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to count sectors")
+	builtin.RequireParam(rt, count > 0, "no sectors to roll back")
+	builtin.RequireParam(rt, count <= ProveReplicaUpdatesMaxSize, "too many sectors (%d > %d)", count, ProveReplicaUpdatesMaxSize)
+
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	powerDelta := NewPowerPairZero()
+	pledgeDelta := big.Zero()
+	var dealIDs []abi.DealID
+	var verifiedSectors []uint64
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+
+		sectors, err := LoadSectors(store, st.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+
+		var restored []*SectorOnChainInfo
+		err = params.Sectors.ForEach(func(sno uint64) error {
+			sectorNo := abi.SectorNumber(sno)
+			rollback, found, err := st.GetReplicaUpdateRollback(store, sectorNo)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load replica update rollback for sector %d", sectorNo)
+			if !found || currEpoch >= rollback.Expiry {
+				rt.Abortf(exitcode.ErrForbidden, "no replica update of sector %d to roll back", sectorNo)
+			}
+			sector, found, err := sectors.Get(sectorNo)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector %d", sectorNo)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "sector %d not found", sectorNo)
+			}
+			if sector.Expiration != rollback.Sector.Expiration {
+				rt.Abortf(exitcode.ErrForbidden, "sector %d expiration changed since its update", sectorNo)
+			}
+
+			dlIdx, pIdx, err := st.FindSector(store, sectorNo)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to find sector %d", sectorNo)
+			if !deadlineIsMutable(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch) {
+				rt.Abortf(ErrImmutableDeadline, "cannot roll back sectors in immutable deadline %d", dlIdx)
+			}
+			healthy, err := st.CheckSectorActive(store, dlIdx, pIdx, sectorNo, true)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check sector %d", sectorNo)
+			if !healthy {
+				rt.Abortf(exitcode.ErrForbidden, "sector %d is not healthy", sectorNo)
+			}
+
+			deadline, err := deadlines.LoadDeadline(store, dlIdx)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+			partitions, err := deadline.PartitionsArray(store)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", dlIdx)
+			var partition Partition
+			_, err = partitions.Get(pIdx, &partition)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d partition %d", dlIdx, pIdx)
+
+			partitionPowerDelta, partitionPledgeDelta, err := partition.ReplaceSectors(store,
+				[]*SectorOnChainInfo{sector},
+				[]*SectorOnChainInfo{&rollback.Sector},
+				info.SectorSize,
+				st.QuantSpecForDeadline(dlIdx))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector at deadline %d partition %d", dlIdx, pIdx)
+			powerDelta = powerDelta.Add(partitionPowerDelta)
+			pledgeDelta = big.Add(pledgeDelta, partitionPledgeDelta)
+
+			err = partitions.Set(pIdx, &partition)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d partition %d", dlIdx, pIdx)
+			deadline.Partitions, err = partitions.Root()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save partitions for deadline %d", dlIdx)
+			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d", dlIdx)
+
+			restored = append(restored, &rollback.Sector)
+			dealIDs = append(dealIDs, sector.DealIDs...)
+			if !sector.VerifiedDealWeight.IsZero() {
+				verifiedSectors = append(verifiedSectors, sno)
+			}
+			return nil
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to roll back replica updates")
+
+		err = sectors.Store(restored...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to restore sector infos")
+		st.Sectors, err = sectors.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors")
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+
+		err = st.AddInitialPledge(pledgeDelta)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to release initial pledge")
+
+		// The manifests describe the data of the updated replicas.
+		err = st.DeleteSectorManifests(store, params.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete sector manifests")
+		err = st.DeleteReplicaUpdateRollbacks(store, params.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete replica update rollbacks")
+	})
+
+	requestTerminateDeals(rt, currEpoch, dealIDs)
+	requestRemoveSectorClaims(rt, verifiedSectors)
+	notifyPledgeChanged(rt, pledgeDelta, big.Zero(), big.Zero())
+	// Only healthy sectors are rolled back, so faulty power is unchanged.
+	requestUpdatePower(rt, powerDelta, NewPowerPairZero())
+	return nil
+}
+
+// Applies replica updates, returning an exit code for each update: Ok if applied, or the reason it was skipped.
+// Updates must name an unsealed CID if requireUnsealedCID is set, and any unsealed CID named must match the
+// commitment computed from the update's deals.
+// Sectors holding deals are skipped unless replaceDeals is set, in which case their deals are settled.
+func proveReplicaUpdates(rt Runtime, updates []ReplicaUpdate2, manifests []SectorManifest, requireUnsealedCID, replaceDeals bool) []exitcode.ExitCode {
 	// Validate inputs
 
-	builtin.RequireParam(rt, len(params.Updates) <= ProveReplicaUpdatesMaxSize, "too many updates (%d > %d)", len(params.Updates), ProveReplicaUpdatesMaxSize)
+	builtin.RequireParam(rt, len(updates) <= ProveReplicaUpdatesMaxSize, "too many updates (%d > %d)", len(updates), ProveReplicaUpdatesMaxSize)
 
 	store := adt.AsStore(rt)
 	var stReadOnly State
@@ -2074,6 +4159,10 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 
 	rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
 
+	settings := stReadOnly.GetOwnerSettings()
+	err := settings.checkSectorCount(uint64(len(updates)))
+	builtin.RequireNoErr(rt, err, exitcode.ErrForbidden, "replica updates refused by owner settings")
+
 	sectors, err := LoadSectors(store, stReadOnly.Sectors)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
 
@@ -2081,60 +4170,78 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 	pledgeDelta := big.Zero()
 
 	type updateAndSectorInfo struct {
-		update     *ReplicaUpdate
+		update     *ReplicaUpdate2
 		sectorInfo *SectorOnChainInfo
 	}
 
 	var sectorsDeals []market.SectorDeals
 	var sectorsDataSpec []*market.SectorDataSpec
+	updatedSectors := bitfield.New()
+	for _, update := range updates {
+		updatedSectors.Set(uint64(update.SectorID))
+	}
+	err = validateSectorManifests(manifests, updatedSectors)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid sector manifests")
+
+	results := make([]exitcode.ExitCode, len(updates))
+	skip := func(i int, code exitcode.ExitCode, msg string, args ...interface{}) {
+		rt.Log(rtt.INFO, msg, args...)
+		results[i] = code
+	}
+
 	var validatedUpdates []*updateAndSectorInfo
 	sectorNumbers := bitfield.New()
-	for i := range params.Updates {
-		update := params.Updates[i]
+	for i := range updates {
+		update := updates[i]
 		// Bitfied.IsSet() is fast when there are only locally-set values.
 		set, err := sectorNumbers.IsSet(uint64(update.SectorID))
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "error checking sector number")
 		if set {
-			rt.Log(rtt.INFO, "duplicate sector being updated %d, skipping", update.SectorID)
+			skip(i, exitcode.ErrIllegalArgument, "duplicate sector being updated %d, skipping", update.SectorID)
 			continue
 		}
 
 		sectorNumbers.Set(uint64(update.SectorID))
 
 		if len(update.ReplicaProof) > 4096 {
-			rt.Log(rtt.INFO, "update proof is too large (%d), skipping sector %d", len(update.ReplicaProof), update.SectorID)
+			skip(i, exitcode.ErrIllegalArgument, "update proof is too large (%d), skipping sector %d", len(update.ReplicaProof), update.SectorID)
 			continue
 		}
 
 		if len(update.Deals) <= 0 {
-			rt.Log(rtt.INFO, "must have deals to update, skipping sector %d", update.SectorID)
+			skip(i, exitcode.ErrIllegalArgument, "must have deals to update, skipping sector %d", update.SectorID)
 			continue
 		}
 
 		if uint64(len(update.Deals)) > SectorDealsMax(info.SectorSize) {
-			rt.Log(rtt.INFO, "more deals than policy allows, skipping sector %d", update.SectorID)
+			skip(i, exitcode.ErrIllegalArgument, "more deals than policy allows, skipping sector %d", update.SectorID)
 			continue
 		}
 
 		if update.Deadline >= WPoStPeriodDeadlines {
-			rt.Log(rtt.INFO, "deadline %d not in range 0..%d, skipping sector %d", update.Deadline, WPoStPeriodDeadlines, update.SectorID)
+			skip(i, exitcode.ErrIllegalArgument, "deadline %d not in range 0..%d, skipping sector %d", update.Deadline, WPoStPeriodDeadlines, update.SectorID)
 			continue
 		}
 
 		if !update.NewSealedSectorCID.Defined() {
-			rt.Log(rtt.INFO, "new sealed CID undefined, skipping sector %d", update.SectorID)
+			skip(i, exitcode.ErrIllegalArgument, "new sealed CID undefined, skipping sector %d", update.SectorID)
 			continue
 		}
 
 		if update.NewSealedSectorCID.Prefix() != SealedCIDPrefix {
-			rt.Log(rtt.INFO, "new sealed CID had wrong prefix %s, skipping sector %d", update.NewSealedSectorCID, update.SectorID)
+			skip(i, exitcode.ErrIllegalArgument, "new sealed CID had wrong prefix %s, skipping sector %d", update.NewSealedSectorCID, update.SectorID)
+			continue
+		}
+
+		if requireUnsealedCID && !update.NewUnsealedSectorCID.Defined() {
+			skip(i, exitcode.ErrIllegalArgument, "new unsealed CID undefined, skipping sector %d", update.SectorID)
 			continue
 		}
 
 		// If the deadline is the current or next deadline to prove, don't allow updating sectors.
 		// We assume that deadlines are immutable when being proven.
 		if !deadlineIsMutable(stReadOnly.CurrentProvingPeriodStart(rt.CurrEpoch()), update.Deadline, rt.CurrEpoch()) {
-			rt.Log(rtt.INFO, "cannot upgrade sectors in immutable deadline %d, skipping sector %d", update.Deadline, update.SectorID)
+			skip(i, ErrImmutableDeadline, "cannot upgrade sectors in immutable deadline %d, skipping sector %d", update.Deadline, update.SectorID)
 			continue
 		}
 
@@ -2143,18 +4250,18 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "error checking sector health")
 
 		if !healthy {
-			rt.Log(rtt.INFO, "sector isn't healthy, skipping sector %d", update.SectorID)
+			skip(i, exitcode.ErrForbidden, "sector isn't healthy, skipping sector %d", update.SectorID)
 			continue
 		}
 
 		sectorInfo, err := sectors.MustGet(update.SectorID)
 		if err != nil {
-			rt.Log(rtt.INFO, "failed to get sector, skipping sector %d", update.SectorID)
+			skip(i, exitcode.ErrNotFound, "failed to get sector, skipping sector %d", update.SectorID)
 			continue
 		}
 
-		if len(sectorInfo.DealIDs) != 0 {
-			rt.Log(rtt.INFO, "cannot update sector with deals, skipping sector %d", update.SectorID)
+		if len(sectorInfo.DealIDs) != 0 && !replaceDeals {
+			skip(i, exitcode.ErrForbidden, "cannot update sector with deals, skipping sector %d", update.SectorID)
 			continue
 		}
 
@@ -2164,13 +4271,14 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 			&market.ActivateDealsParams{
 				DealIDs:      update.Deals,
 				SectorExpiry: sectorInfo.Expiration,
+				SectorNumber: update.SectorID,
 			},
 			abi.NewTokenAmount(0),
 			&builtin.Discard{},
 		)
 
 		if code != exitcode.Ok {
-			rt.Log(rtt.INFO, "failed to activate deals, skipping sector %d", update.SectorID)
+			skip(i, code, "failed to activate deals, skipping sector %d", update.SectorID)
 			continue
 		}
 
@@ -2190,6 +4298,13 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 
 	// Errors past this point cause the ProveReplicaUpdates call to fail (no more skipping sectors)
 
+	// The deals replaced by the updates are terminated, their data no longer being stored.
+	var replacedDealIDs []abi.DealID
+	for _, updateWithSectorInfo := range validatedUpdates {
+		replacedDealIDs = append(replacedDealIDs, updateWithSectorInfo.sectorInfo.DealIDs...)
+	}
+	requestTerminateReplacedDeals(rt, replacedDealIDs)
+
 	dealWeights := requestDealWeights(rt, sectorsDeals)
 	builtin.RequirePredicate(rt, len(dealWeights.Sectors) == len(validatedUpdates), exitcode.ErrIllegalState,
 		"deal weight request returned %d records, expected %d", len(dealWeights.Sectors), len(validatedUpdates))
@@ -2199,7 +4314,7 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 		"unsealed sector cid request returned %d records, expected %d", len(unsealedSectorCIDs), len(validatedUpdates))
 
 	type updateWithDetails struct {
-		update            *ReplicaUpdate
+		update            *ReplicaUpdate2
 		sectorInfo        *SectorOnChainInfo
 		dealWeight        market.SectorWeights
 		unsealedSectorCID cid.Cid
@@ -2209,6 +4324,10 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 	declsByDeadline := map[uint64][]*updateWithDetails{}
 	var deadlinesToLoad []uint64
 	for i, updateWithSectorInfo := range validatedUpdates {
+		if update := updateWithSectorInfo.update; update.NewUnsealedSectorCID.Defined() && !update.NewUnsealedSectorCID.Equals(unsealedSectorCIDs[i]) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "unsealed CID %s for sector %d does not match deals' data commitment %s",
+				update.NewUnsealedSectorCID, update.SectorID, unsealedSectorCIDs[i])
+		}
 		if _, ok := declsByDeadline[updateWithSectorInfo.update.Deadline]; !ok {
 			deadlinesToLoad = append(deadlinesToLoad, updateWithSectorInfo.update.Deadline)
 		}
@@ -2230,6 +4349,7 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
 
 		newSectors := make([]*SectorOnChainInfo, len(validatedUpdates))
+		var rollbacks []*ReplicaUpdateRollback
 		for _, dlIdx := range deadlinesToLoad {
 			deadline, err := deadlines.LoadDeadline(store, dlIdx)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
@@ -2238,6 +4358,8 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", dlIdx)
 
 			quant := st.QuantSpecForDeadline(dlIdx)
+			// An update may be rolled back until the deadline next opens, when a PoSt may first cover the new replica.
+			rollbackExpiry := NewDeadlineInfo(st.CurrentProvingPeriodStart(rt.CurrEpoch()), dlIdx, rt.CurrEpoch()).NextNotElapsed().Open
 
 			for i, updateWithDetails := range declsByDeadline[dlIdx] {
 				updateProofType, err := updateWithDetails.sectorInfo.SealProof.RegisteredUpdateProof()
@@ -2286,7 +4408,7 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 
 					unlockedBalance, err := st.GetUnlockedBalance(rt.CurrentBalance())
 					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate unlocked balance")
-					builtin.RequirePredicate(rt, unlockedBalance.GreaterThanEqual(deficit), exitcode.ErrInsufficientFunds, "insufficient funds for new initial pledge requirement %s, available: %s, skipping sector %d",
+					builtin.RequirePredicate(rt, unlockedBalance.GreaterThanEqual(deficit), ErrInsufficientPledge, "insufficient funds for new initial pledge requirement %s, available: %s, skipping sector %d",
 						deficit, unlockedBalance, updateWithDetails.sectorInfo.SectorNumber)
 
 					err = st.AddInitialPledge(deficit)
@@ -2311,61 +4433,306 @@ func (a Actor) ProveReplicaUpdates(rt Runtime, params *ProveReplicaUpdatesParams
 					info.SectorSize,
 					quant)
 
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector at deadline %d partition %d", updateWithDetails.update.Deadline, updateWithDetails.update.Partition)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector at deadline %d partition %d", updateWithDetails.update.Deadline, updateWithDetails.update.Partition)
+
+				powerDelta = powerDelta.Add(partitionPowerDelta)
+				pledgeDelta = big.Add(pledgeDelta, partitionPledgeDelta)
+
+				err = partitions.Set(updateWithDetails.update.Partition, &partition)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %v partition %v",
+					updateWithDetails.update.Deadline,
+					updateWithDetails.update.Partition)
+
+				newSectors[i] = &newSectorInfo
+				succeededSectors.Set(uint64(newSectorInfo.SectorNumber))
+				// Deals replaced by the update have been settled, so only a committed-capacity sector can be restored.
+				if len(updateWithDetails.sectorInfo.DealIDs) == 0 {
+					rollbacks = append(rollbacks, &ReplicaUpdateRollback{Sector: *updateWithDetails.sectorInfo, Expiry: rollbackExpiry})
+				}
+			}
+
+			deadline.Partitions, err = partitions.Root()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save partitions for deadline %d", dlIdx)
+
+			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d", dlIdx)
+		}
+
+		successCount, err := succeededSectors.Count()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count succeededSectors")
+		builtin.RequirePredicate(rt, successCount == uint64(len(validatedUpdates)), exitcode.ErrIllegalState, "unexpected successcount %d != %d", successCount, len(validatedUpdates))
+
+		// Overwrite sector infos.
+		err = sectors.Store(newSectors...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update sector infos")
+
+		st.Sectors, err = sectors.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors")
+
+		// Replace the manifests of updated sectors. Manifests of skipped sectors are ignored.
+		err = st.DeleteSectorManifests(store, succeededSectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete replaced sector manifests")
+		var updatedManifests []SectorManifest
+		for _, m := range manifests {
+			succeeded, err := succeededSectors.IsSet(uint64(m.SectorNumber))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check updated sector %d", m.SectorNumber)
+			if succeeded {
+				updatedManifests = append(updatedManifests, m)
+			}
+		}
+		err = st.PutSectorManifests(store, updatedManifests...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to write sector manifests")
+
+		// Any earlier update of these sectors can no longer be rolled back.
+		err = st.DeleteReplicaUpdateRollbacks(store, succeededSectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete replica update rollbacks")
+		err = st.PutReplicaUpdateRollbacks(store, rollbacks...)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record replica update rollbacks")
+
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+
+	})
+
+	notifyPledgeChanged(rt, pledgeDelta, big.Zero(), big.Zero())
+	// Only healthy sectors are updated, so faulty power is unchanged.
+	requestUpdatePower(rt, powerDelta, NewPowerPairZero())
+
+	return results
+}
+
+type TerminatedSectorCountsReturn struct {
+	// Sectors terminated early whose termination fees have not yet been processed.
+	PendingSettlement uint64
+	// Terminated sectors with no outstanding termination fee, and not yet removed by compaction.
+	Settled uint64
+}
+
+// Reports how many of the miner's terminated sectors are still awaiting processing of their
+// early termination fees, and how many are fully settled.
+// Pending sectors hold no power, but their termination fees are yet to be deducted from the
+// miner's balance.
+func (a Actor) TerminatedSectorCounts(rt Runtime, _ *abi.EmptyValue) *TerminatedSectorCountsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	pending, settled, err := st.CountTerminatedSectors(adt.AsStore(rt))
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count terminated sectors")
+	return &TerminatedSectorCountsReturn{
+		PendingSettlement: pending,
+		Settled:           settled,
+	}
+}
+
+type DeadlineSectorCountsReturn struct {
+	// Number of live sectors assigned to each deadline, indexed by deadline.
+	LiveSectors []uint64
+}
+
+// Reports the distribution of the miner's live sectors over its deadlines.
+// A miner may use this to detect an imbalanced assignment and correct it with RebalanceSectors.
+func (a Actor) DeadlineSectorCounts(rt Runtime, _ *abi.EmptyValue) *DeadlineSectorCountsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	counts, err := st.CountDeadlineLiveSectors(adt.AsStore(rt))
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count live sectors")
+	return &DeadlineSectorCountsReturn{LiveSectors: counts}
+}
+
+type PartitionExpirationsParams struct {
+	Deadline  uint64
+	Partition uint64
+}
+
+type PartitionExpirationsReturn struct {
+	Expirations []PartitionExpiration
+}
+
+// Reports the expiration queue of a partition: each epoch at which sectors are scheduled to expire,
+// with the number of sectors expiring on time and early and the power they hold.
+// This allows the miner's future power to be forecast without loading the sectors' info.
+func (a Actor) PartitionExpirations(rt Runtime, params *PartitionExpirationsParams) *PartitionExpirationsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	if params.Deadline >= WPoStPeriodDeadlines {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid deadline %d of %d", params.Deadline, WPoStPeriodDeadlines)
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	store := adt.AsStore(rt)
+	deadlines, err := st.LoadDeadlines(store)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+	deadline, err := deadlines.LoadDeadline(store, params.Deadline)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.Deadline)
+	partition, err := deadline.LoadPartition(store, params.Partition)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partition %d:%d", params.Deadline, params.Partition)
+	expirations, err := partition.ExpirationSchedule(store)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load expirations of partition %d:%d", params.Deadline, params.Partition)
+	return &PartitionExpirationsReturn{Expirations: expirations}
+}
+
+type SectorManifestParams struct {
+	SectorNumber abi.SectorNumber
+}
+
+type SectorManifestReturn struct {
+	// Nil if no manifest was committed for the sector.
+	Manifest *cid.Cid
+}
+
+// Returns the manifest of the piece layout committed for a pre-committed or active sector, if any.
+func (a Actor) SectorManifest(rt Runtime, params *SectorManifestParams) *SectorManifestReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	store := adt.AsStore(rt)
+	stage, err := st.SectorActivationStage(store, params.SectorNumber, rt.CurrEpoch())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector %d", params.SectorNumber)
+	if stage == SectorActivationNone {
+		rt.Abortf(exitcode.ErrNotFound, "sector %d not pre-committed or active", params.SectorNumber)
+	}
+
+	manifest, found, err := st.GetSectorManifest(store, params.SectorNumber)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load manifest of sector %d", params.SectorNumber)
+	if !found {
+		return &SectorManifestReturn{}
+	}
+	return &SectorManifestReturn{Manifest: &manifest}
+}
+
+type GetVestingFundsReturn struct {
+	// Funds vesting at each epoch, in order of epoch.
+	Vesting []VestingFund
+}
 
-				powerDelta = powerDelta.Add(partitionPowerDelta)
-				pledgeDelta = big.Add(pledgeDelta, partitionPledgeDelta)
+// Returns the miner's schedule of vesting funds.
+// Funds scheduled to vest at or before the current epoch are included until they are unlocked by
+// the miner's next change of balance.
+func (a Actor) GetVestingFunds(rt Runtime, _ *abi.EmptyValue) *GetVestingFundsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
 
-				err = partitions.Set(updateWithDetails.update.Partition, &partition)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %v partition %v",
-					updateWithDetails.update.Deadline,
-					updateWithDetails.update.Partition)
+	var st State
+	rt.StateReadonly(&st)
+	funds, err := st.LoadVestingFunds(adt.AsStore(rt))
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load vesting funds")
+	return &GetVestingFundsReturn{Vesting: funds.Funds}
+}
 
-				newSectors[i] = &newSectorInfo
-				succeededSectors.Set(uint64(newSectorInfo.SectorNumber))
-			}
+type EstimateDeadlinePenaltyParams struct {
+	Deadline uint64
+}
 
-			deadline.Partitions, err = partitions.Root()
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save partitions for deadline %d", dlIdx)
+type EstimateDeadlinePenaltyReturn struct {
+	// Epoch at which the deadline next closes and its penalty is charged.
+	Epoch abi.ChainEpoch
+	// Power currently faulty in the deadline.
+	FaultyPower PowerPair
+	// Fee that the current faults would pay when the deadline closes.
+	Penalty abi.TokenAmount
+}
 
-			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d", dlIdx)
-		}
+// Estimates the continued fault fee to be charged when a deadline next closes, given the sectors faulty
+// in the deadline now and the current reward and network power estimates.
+// Faults detected or declared, and recoveries proven, before the deadline closes are not anticipated,
+// nor are changes to the estimates, so the fee charged may differ.
+func (a Actor) EstimateDeadlinePenalty(rt Runtime, params *EstimateDeadlinePenaltyParams) *EstimateDeadlinePenaltyReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	if params.Deadline >= WPoStPeriodDeadlines {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid deadline %d of %d", params.Deadline, WPoStPeriodDeadlines)
+	}
 
-		successCount, err := succeededSectors.Count()
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count succeededSectors")
-		builtin.RequirePredicate(rt, successCount == uint64(len(validatedUpdates)), exitcode.ErrIllegalState, "unexpected successcount %d != %d", successCount, len(validatedUpdates))
+	rewardStats := requestCurrentEpochBlockReward(rt)
+	pwrTotal := requestCurrentTotalPower(rt)
 
-		// Overwrite sector infos.
-		err = sectors.Store(newSectors...)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update sector infos")
+	var st State
+	rt.StateReadonly(&st)
+	store := adt.AsStore(rt)
+	dlInfo := NewDeadlineInfo(st.ProvingPeriodStart, params.Deadline, rt.CurrEpoch()).NextNotElapsed()
+
+	deadlines, err := st.LoadDeadlines(store)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+	deadline, err := deadlines.LoadDeadline(store, params.Deadline)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.Deadline)
+	maintenanceFaultyPower, err := st.MaintenanceFaultyPower(store, params.Deadline, dlInfo.Last())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load maintenance faults")
+
+	penalty := continuedFaultPenalty(
+		rewardStats.ThisEpochRewardSmoothed,
+		pwrTotal.QualityAdjPowerSmoothed,
+		deadline.FaultyPower.QA,
+		maintenanceFaultyPower.QA,
+	)
+	return &EstimateDeadlinePenaltyReturn{
+		Epoch:       dlInfo.Last(),
+		FaultyPower: deadline.FaultyPower,
+		Penalty:     penalty,
+	}
+}
 
-		st.Sectors, err = sectors.Root()
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors")
+type EstimateAggregateFeesParams struct {
+	// Numbers of sectors for which to estimate fees.
+	BatchSizes []uint64
+}
 
-		err = st.SaveDeadlines(store, deadlines)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+type AggregateFeeEstimate struct {
+	BatchSize uint64
+	// Fee burnt by PreCommitSectorBatch for a batch of this size.
+	PreCommitBatchFee abi.TokenAmount
+	// Fee burnt by ProveCommitAggregate for an aggregate of this size.
+	ProveCommitAggregateFee abi.TokenAmount
+}
 
-	})
+type EstimateAggregateFeesReturn struct {
+	// The base fee at which the fees are evaluated.
+	BaseFee   abi.TokenAmount
+	Estimates []AggregateFeeEstimate
+}
 
-	notifyPledgeChanged(rt, pledgeDelta)
-	requestUpdatePower(rt, powerDelta)
+// Evaluates the network fees for pre-commit batches and prove-commit aggregates of the given sizes at the
+// current base fee, with the same computation as the methods that charge them.
+// The fees are independent of the miner's state, and are not checked against the owner's limits.
+func (a Actor) EstimateAggregateFees(rt Runtime, params *EstimateAggregateFeesParams) *EstimateAggregateFeesReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	if len(params.BatchSizes) > MaxAggregatedSectors {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many batch sizes %d, max %d", len(params.BatchSizes), MaxAggregatedSectors)
+	}
 
-	return &succeededSectors
+	baseFee := rt.BaseFee()
+	estimates := make([]AggregateFeeEstimate, len(params.BatchSizes))
+	for i, size := range params.BatchSizes {
+		if size > MaxAggregatedSectors {
+			rt.Abortf(exitcode.ErrIllegalArgument, "batch size %d exceeds max %d", size, MaxAggregatedSectors)
+		}
+		estimates[i] = AggregateFeeEstimate{
+			BatchSize:               size,
+			PreCommitBatchFee:       EstimatePreCommitBatchFee(int(size), baseFee),
+			ProveCommitAggregateFee: EstimateProveCommitAggregateFee(int(size), baseFee),
+		}
+	}
+	return &EstimateAggregateFeesReturn{BaseFee: baseFee, Estimates: estimates}
 }
 
 //////////
 // Cron //
 //////////
 
-//type CronEventPayload struct {
-//	EventType CronEventType
-//}
-type CronEventPayload = miner0.CronEventPayload
+// The version of cron event payloads enrolled by this actor.
+// Payloads enrolled before the version was recorded decode as version zero.
+const CronEventPayloadVersion = uint64(1)
+
+type CronEventPayload struct {
+	EventType CronEventType
+	Version   uint64
+}
 
 type CronEventType = miner0.CronEventType
 
 const (
+	CronEventWorkerKeyChange          = miner0.CronEventWorkerKeyChange
 	CronEventProvingDeadline          = miner0.CronEventProvingDeadline
 	CronEventProcessEarlyTerminations = miner0.CronEventProcessEarlyTerminations
 )
@@ -2373,19 +4740,34 @@ const (
 func (a Actor) OnDeferredCronEvent(rt Runtime, params *builtin.DeferredCronEventParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerIs(builtin.StoragePowerActorAddr)
 
-	var payload miner0.CronEventPayload
-	err := payload.UnmarshalCBOR(bytes.NewBuffer(params.EventPayload))
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unmarshal miner cron payload into expected structure")
+	payload, err := DecodeCronEventPayload(params.EventPayload)
+	if err != nil {
+		rt.Log(rtt.ERROR, "onDeferredCronEvent failed to decode payload: %v", err)
+		dropCronEvent(rt)
+		return nil
+	}
+	if payload.Version > CronEventPayloadVersion {
+		rt.Log(rtt.ERROR, "onDeferredCronEvent unknown payload version %d for event type %v", payload.Version, payload.EventType)
+		dropCronEvent(rt)
+		return nil
+	}
 
 	switch payload.EventType {
+	case CronEventWorkerKeyChange:
+		var st State
+		rt.StateTransaction(&st, func() {
+			info := getMinerInfo(rt, &st)
+			processPendingWorker(info, rt, &st)
+		})
 	case CronEventProvingDeadline:
 		handleProvingDeadline(rt, params.RewardSmoothed, params.QualityAdjPowerSmoothed)
 	case CronEventProcessEarlyTerminations:
-		if processEarlyTerminations(rt, params.RewardSmoothed, params.QualityAdjPowerSmoothed) {
+		if more, _ := processEarlyTerminations(rt, params.RewardSmoothed, params.QualityAdjPowerSmoothed, big.Zero()); more {
 			scheduleEarlyTerminationWork(rt)
 		}
 	default:
 		rt.Log(rtt.ERROR, "onDeferredCronEvent invalid event type: %v", payload.EventType)
+		dropCronEvent(rt)
 	}
 
 	var st State
@@ -2395,6 +4777,41 @@ func (a Actor) OnDeferredCronEvent(rt Runtime, params *builtin.DeferredCronEvent
 	return nil
 }
 
+// Decodes a cron event payload of the current or a later version, or a legacy payload
+// enrolled before payloads were versioned.
+func DecodeCronEventPayload(raw []byte) (*CronEventPayload, error) {
+	var payload CronEventPayload
+	if err := payload.UnmarshalCBOR(bytes.NewReader(raw)); err == nil {
+		return &payload, nil
+	}
+	var legacy miner0.CronEventPayload
+	if err := legacy.UnmarshalCBOR(bytes.NewReader(raw)); err != nil {
+		return nil, xerrors.Errorf("failed to unmarshal miner cron payload: %w", err)
+	}
+	return &CronEventPayload{EventType: legacy.EventType, Version: 0}, nil
+}
+
+// Counts a cron event that was received but not processed.
+func dropCronEvent(rt Runtime) {
+	var st State
+	rt.StateTransaction(&st, func() {
+		st.DroppedCronEvents++
+	})
+}
+
+type DroppedCronEventsReturn struct {
+	Count uint64
+}
+
+// Returns the number of cron events dropped by the miner because their payloads could not be
+// decoded, were of an unknown version, or carried an unknown event type.
+func (a Actor) DroppedCronEvents(rt Runtime, _ *abi.EmptyValue) *DroppedCronEventsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	var st State
+	rt.StateReadonly(&st)
+	return &DroppedCronEventsReturn{Count: st.DroppedCronEvents}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Utility functions & helpers
 ////////////////////////////////////////////////////////////////////////////////
@@ -2402,15 +4819,22 @@ func (a Actor) OnDeferredCronEvent(rt Runtime, params *builtin.DeferredCronEvent
 // TODO: We're using the current power+epoch reward. Technically, we
 // should use the power/reward at the time of termination.
 // https://github.com/filecoin-project/specs-actors/v7/pull/648
-func processEarlyTerminations(rt Runtime, rewardSmoothed smoothing.FilterEstimate, qualityAdjPowerSmoothed smoothing.FilterEstimate) (more bool) {
+// Funds earmarked for the penalty, which are included in the current balance, pay the penalty
+// of the terminations processed before any other funds, and are otherwise left untouched.
+// Returns the amount of the earmarked funds burnt.
+func processEarlyTerminations(rt Runtime, rewardSmoothed smoothing.FilterEstimate, qualityAdjPowerSmoothed smoothing.FilterEstimate,
+	earmarked abi.TokenAmount) (more bool, fromEarmarked abi.TokenAmount) {
 	store := adt.AsStore(rt)
 
 	var (
-		result           TerminationResult
-		dealsToTerminate []market.OnMinerSectorsTerminateParams
-		penalty          = big.Zero()
-		pledgeDelta      = big.Zero()
+		result             TerminationResult
+		dealsToTerminate   []market.OnMinerSectorsTerminateParams
+		verifiedSectors    []uint64
+		penalty            = big.Zero()
+		initialPledgeDelta = big.Zero()
+		lockedRewardsDelta = big.Zero()
 	)
+	fromEarmarked = big.Zero()
 
 	var st State
 	rt.StateTransaction(&st, func() {
@@ -2443,6 +4867,9 @@ func processEarlyTerminations(rt Runtime, rewardSmoothed smoothing.FilterEstimat
 			for _, sector := range sectors {
 				params.DealIDs = append(params.DealIDs, sector.DealIDs...)
 				totalInitialPledge = big.Add(totalInitialPledge, sector.InitialPledge)
+				if !sector.VerifiedDealWeight.IsZero() {
+					verifiedSectors = append(verifiedSectors, uint64(sector.SectorNumber))
+				}
 			}
 			penalty = big.Add(penalty, terminationPenalty(info.SectorSize, epoch,
 				rewardSmoothed, qualityAdjPowerSmoothed, sectors))
@@ -2459,19 +4886,23 @@ func processEarlyTerminations(rt Runtime, rewardSmoothed smoothing.FilterEstimat
 		// Remove pledge requirement.
 		err = st.AddInitialPledge(totalInitialPledge.Neg())
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add initial pledge %v", totalInitialPledge.Neg())
-		pledgeDelta = big.Sub(pledgeDelta, totalInitialPledge)
+		initialPledgeDelta = totalInitialPledge.Neg()
+
+		// Pay the penalty from earmarked funds first, which are then excluded from the unlocked balance.
+		fromEarmarked = big.Min(earmarked, penalty)
+		st.FeeDebt = big.Sub(st.FeeDebt, fromEarmarked)
 
 		// Use unlocked pledge to pay down outstanding fee debt
-		penaltyFromVesting, penaltyFromBalance, err := st.RepayPartialDebtInPriorityOrder(store, rt.CurrEpoch(), rt.CurrentBalance())
+		penaltyFromVesting, penaltyFromBalance, err := st.RepayPartialDebtInPriorityOrder(store, rt.CurrEpoch(), big.Sub(rt.CurrentBalance(), earmarked))
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to pay penalty")
-		penalty = big.Add(penaltyFromVesting, penaltyFromBalance)
-		pledgeDelta = big.Sub(pledgeDelta, penaltyFromVesting)
+		penalty = big.Sum(fromEarmarked, penaltyFromVesting, penaltyFromBalance)
+		lockedRewardsDelta = penaltyFromVesting.Neg()
 	})
 
 	// We didn't do anything, abort.
 	if result.IsEmpty() {
 		rt.Log(rtt.INFO, "no early terminations")
-		return more
+		return more, fromEarmarked
 	}
 
 	// Burn penalty.
@@ -2479,15 +4910,16 @@ func processEarlyTerminations(rt Runtime, rewardSmoothed smoothing.FilterEstimat
 	burnFunds(rt, penalty, BurnMethodProcessEarlyTerminations)
 
 	// Return pledge.
-	notifyPledgeChanged(rt, pledgeDelta)
+	notifyPledgeChanged(rt, initialPledgeDelta, big.Zero(), lockedRewardsDelta)
 
 	// Terminate deals.
 	for _, params := range dealsToTerminate {
 		requestTerminateDeals(rt, params.Epoch, params.DealIDs)
 	}
+	requestRemoveSectorClaims(rt, verifiedSectors)
 
 	// reschedule cron worker, if necessary.
-	return more
+	return more, fromEarmarked
 }
 
 // Invoked at the end of the last epoch for each proving deadline.
@@ -2498,11 +4930,20 @@ func handleProvingDeadline(rt Runtime,
 	store := adt.AsStore(rt)
 
 	hadEarlyTerminations := false
+	periodEnded := false
+	periodMissedPoSt := false
 
 	powerDeltaTotal := NewPowerPairZero()
+	faultyPowerDelta := NewPowerPairZero()
 	penaltyTotal := abi.NewTokenAmount(0)
-	pledgeDeltaTotal := abi.NewTokenAmount(0)
-
+	initialPledgeDelta := abi.NewTokenAmount(0)
+	preCommitDepositDelta := abi.NewTokenAmount(0)
+	lockedRewardsDelta := abi.NewTokenAmount(0)
+
+	var notifyReceiver addr.Address
+	var expiredNotification *PreCommitsExpiredParams
+	var expirationReminder *SectorsExpiringParams
+	var expiredVerifiedSectors []uint64
 	var continueCron bool
 	var st State
 	rt.StateTransaction(&st, func() {
@@ -2512,7 +4953,7 @@ func handleProvingDeadline(rt Runtime,
 			// from locked vesting funds before funds free this epoch.
 			newlyVested, err := st.UnlockVestedFunds(store, rt.CurrEpoch())
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to vest funds")
-			pledgeDeltaTotal = big.Add(pledgeDeltaTotal, newlyVested.Neg())
+			lockedRewardsDelta = big.Add(lockedRewardsDelta, newlyVested.Neg())
 		}
 
 		{
@@ -2522,8 +4963,17 @@ func handleProvingDeadline(rt Runtime,
 		}
 
 		{
-			depositToBurn, err := st.CleanUpExpiredPreCommits(store, currEpoch)
+			expired, depositToBurn, err := st.CleanUpExpiredPreCommits(store, currEpoch)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to expire pre-committed sectors")
+			preCommitDepositDelta = depositToBurn.Neg()
+			if info := getMinerInfo(rt, &st); info.NotificationReceiver != nil {
+				empty, err := expired.IsEmpty()
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check expired pre-commits")
+				if !empty {
+					notifyReceiver = *info.NotificationReceiver
+					expiredNotification = &PreCommitsExpiredParams{Sectors: expired, DepositBurnt: depositToBurn}
+				}
+			}
 
 			err = st.ApplyPenalty(depositToBurn)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
@@ -2535,19 +4985,44 @@ func handleProvingDeadline(rt Runtime,
 		hadEarlyTerminations = havePendingEarlyTerminations(rt, &st)
 
 		{
+			endingDeadline := st.DeadlineInfo(currEpoch)
+			maintenanceFaultyPower, err := st.MaintenanceFaultyPower(store, endingDeadline.Index, currEpoch)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load maintenance faults")
+
 			result, err := st.AdvanceDeadline(store, currEpoch)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to advance deadline")
 
+			// Sectors expiring on time keep their infos until compaction. Those expiring early have their
+			// claims removed when their termination is processed.
+			sectors, err := LoadSectors(store, st.Sectors)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+			expiredSectors, err := sectors.Load(result.OnTimeExpired)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load expired sectors")
+			for _, sector := range expiredSectors {
+				if !sector.VerifiedDealWeight.IsZero() {
+					expiredVerifiedSectors = append(expiredVerifiedSectors, uint64(sector.SectorNumber))
+				}
+			}
+
+			// Only a deadline that was processed can hold sectors due to expire.
+			if info := getMinerInfo(rt, &st); info.NotificationReceiver != nil && info.ExpirationReminderPeriods > 0 && result.Deadline != nil {
+				expirationReminder = findExpirationReminder(rt, result.Deadline, endingDeadline, info.ExpirationReminderPeriods)
+				notifyReceiver = *info.NotificationReceiver
+			}
+
 			// Faults detected by this missed PoSt pay no penalty, but sectors that were already faulty
 			// and remain faulty through this deadline pay the fault fee.
-			penaltyTarget := PledgePenaltyForContinuedFault(
+			// Those declared faulty during an active maintenance window pay a reduced fee.
+			penaltyTarget := continuedFaultPenalty(
 				rewardSmoothed,
 				qualityAdjPowerSmoothed,
 				result.PreviouslyFaultyPower.QA,
+				maintenanceFaultyPower.QA,
 			)
 
 			powerDeltaTotal = powerDeltaTotal.Add(result.PowerDelta)
-			pledgeDeltaTotal = big.Add(pledgeDeltaTotal, result.PledgeDelta)
+			faultyPowerDelta = result.FaultyPowerDelta
+			initialPledgeDelta = big.Add(initialPledgeDelta, result.PledgeDelta)
 
 			err = st.ApplyPenalty(penaltyTarget)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
@@ -2556,25 +5031,46 @@ func handleProvingDeadline(rt Runtime,
 			penaltyFromVesting, penaltyFromBalance, err := st.RepayPartialDebtInPriorityOrder(store, currEpoch, rt.CurrentBalance())
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock penalty")
 			penaltyTotal = big.Add(penaltyFromVesting, penaltyFromBalance)
-			pledgeDeltaTotal = big.Sub(pledgeDeltaTotal, penaltyFromVesting)
+			lockedRewardsDelta = big.Sub(lockedRewardsDelta, penaltyFromVesting)
+
+			// The proving period ends with its last deadline, after which any sector not proven in
+			// the period is faulty.
+			if endingDeadline.PeriodStarted() && endingDeadline.Index == WPoStPeriodDeadlines-1 {
+				periodEnded = true
+				periodMissedPoSt, err = st.HasFaultyPower(store)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check for faulty power")
+
+				err = st.PruneReplicaUpdateRollbacks(store, currEpoch)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to prune replica update rollbacks")
+			}
 		}
 
+		applyQueuedRecoveries(rt, &st)
+
 		continueCron = st.ContinueDeadlineCron()
 		if !continueCron {
 			st.DeadlineCronActive = false
 		}
 	})
 	// Remove power for new faults, and burn penalties.
-	requestUpdatePower(rt, powerDeltaTotal)
+	requestUpdatePower(rt, powerDeltaTotal, faultyPowerDelta)
 	burnFunds(rt, penaltyTotal, BurnMethodHandleProvingDeadline)
-	notifyPledgeChanged(rt, pledgeDeltaTotal)
+	notifyPledgeChanged(rt, initialPledgeDelta, preCommitDepositDelta, lockedRewardsDelta)
+	if periodEnded {
+		notifyProvingPeriodEnded(rt, periodMissedPoSt)
+	}
+	if expiredNotification != nil {
+		notifyPreCommitsExpired(rt, notifyReceiver, expiredNotification)
+	}
+	if expirationReminder != nil {
+		notifySectorsExpiring(rt, notifyReceiver, expirationReminder)
+	}
+	requestRemoveSectorClaims(rt, expiredVerifiedSectors)
 
 	// Schedule cron callback for next deadline's last epoch.
 	if continueCron {
 		newDlInfo := st.DeadlineInfo(currEpoch + 1)
-		enrollCronEvent(rt, newDlInfo.Last(), &CronEventPayload{
-			EventType: CronEventProvingDeadline,
-		})
+		enrollCronEvent(rt, newDlInfo.Last(), CronEventProvingDeadline)
 	} else {
 		rt.Log(rtt.INFO, "miner %s going inactive, deadline cron discontinued", rt.Receiver())
 	}
@@ -2586,7 +5082,7 @@ func handleProvingDeadline(rt Runtime,
 	// handle them at the next epoch.
 	if !hadEarlyTerminations && hasEarlyTerminations {
 		// First, try to process some of these terminations.
-		if processEarlyTerminations(rt, rewardSmoothed, qualityAdjPowerSmoothed) {
+		if more, _ := processEarlyTerminations(rt, rewardSmoothed, qualityAdjPowerSmoothed, big.Zero()); more {
 			// If that doesn't work, just defer till the next epoch.
 			scheduleEarlyTerminationWork(rt)
 		}
@@ -2596,34 +5092,118 @@ func handleProvingDeadline(rt Runtime,
 	}
 }
 
+// Declares recoveries that were queued while the miner was in fee debt, once that debt is repaid.
+// Recoveries at deadlines whose fault declaration cutoff has passed remain queued for a later cron, while
+// those that can no longer be declared, e.g. because the sectors have since been terminated, are dropped.
+func applyQueuedRecoveries(rt Runtime, st *State) {
+	if st.QueuedRecoveries == nil || !st.IsDebtFree() {
+		return
+	}
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	info := getMinerInfo(rt, st)
+	if ConsensusFaultActive(info, currEpoch) {
+		return
+	}
+
+	queued, err := st.LoadQueuedRecoveries(store)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load queued recoveries")
+
+	deadlines, err := st.LoadDeadlines(store)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+
+	sectors, err := LoadSectors(store, st.Sectors)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+
+	remaining := make(DeadlineSectorMap)
+	err = queued.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+		targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
+		if err != nil {
+			return err
+		}
+		if validateFRDeclarationDeadline(targetDeadline) != nil {
+			return pm.ForEach(func(partIdx uint64, sectorNos bitfield.BitField) error {
+				return remaining.Add(dlIdx, partIdx, sectorNos)
+			})
+		}
+
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		if err != nil {
+			return xerrors.Errorf("failed to load deadline %d: %w", dlIdx, err)
+		}
+		err = pm.ForEach(func(partIdx uint64, sectorNos bitfield.BitField) error {
+			partition := make(PartitionSectorMap)
+			if err := partition.Add(partIdx, sectorNos); err != nil {
+				return err
+			}
+			if err := deadline.DeclareFaultsRecovered(store, sectors, info.SectorSize, partition); err != nil {
+				rt.Log(rtt.WARN, "miner %s dropped queued recovery at deadline %d, partition %d: %v", rt.Receiver(), dlIdx, partIdx, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return deadlines.UpdateDeadline(store, dlIdx, deadline)
+	})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare queued recoveries")
+
+	err = st.SaveDeadlines(store, deadlines)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+
+	err = st.SaveQueuedRecoveries(store, remaining)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save queued recoveries")
+}
+
 // Check expiry is exactly *the epoch before* the start of a proving period.
 func validateExpiration(rt Runtime, activation, expiration abi.ChainEpoch, sealProof abi.RegisteredSealProof) {
+	err := checkExpiration(rt.CurrEpoch(), activation, expiration, sealProof)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid sector expiration")
+}
+
+// Checks a sector expiration against the lifetime limits for its activation epoch and seal proof.
+func checkExpiration(currEpoch, activation, expiration abi.ChainEpoch, sealProof abi.RegisteredSealProof) error {
 	// Expiration must be after activation. Check this explicitly to avoid an underflow below.
 	if expiration <= activation {
-		rt.Abortf(exitcode.ErrIllegalArgument, "sector expiration %v must be after activation (%v)", expiration, activation)
+		return xerrors.Errorf("sector expiration %v must be after activation (%v)", expiration, activation)
 	}
 	// expiration cannot be less than minimum after activation
 	if expiration-activation < MinSectorExpiration {
-		rt.Abortf(exitcode.ErrIllegalArgument, "invalid expiration %d, total sector lifetime (%d) must exceed %d after activation %d",
+		return xerrors.Errorf("invalid expiration %d, total sector lifetime (%d) must exceed %d after activation %d",
 			expiration, expiration-activation, MinSectorExpiration, activation)
 	}
 
-	// expiration cannot exceed MaxSectorExpirationExtension from now
-	if expiration > rt.CurrEpoch()+MaxSectorExpirationExtension {
-		rt.Abortf(exitcode.ErrIllegalArgument, "invalid expiration %d, cannot be more than %d past current epoch %d",
-			expiration, MaxSectorExpirationExtension, rt.CurrEpoch())
+	// expiration cannot exceed the maximum extension from now for the sector's seal proof and age
+	age := currEpoch - activation
+	if age < 0 {
+		age = 0
+	}
+	maxExtension, err := MaxSectorExpirationExtensionFor(sealProof, age)
+	if err != nil {
+		return xerrors.Errorf("unrecognized seal proof type %d: %w", sealProof, err)
+	}
+	if expiration > currEpoch+maxExtension {
+		return xerrors.Errorf("invalid expiration %d, cannot be more than %d past current epoch %d",
+			expiration, maxExtension, currEpoch)
 	}
 
 	// total sector lifetime cannot exceed SectorMaximumLifetime for the sector's seal proof
 	maxLifetime, err := builtin.SealProofSectorMaximumLifetime(sealProof)
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "unrecognized seal proof type %d", sealProof)
+	if err != nil {
+		return xerrors.Errorf("unrecognized seal proof type %d: %w", sealProof, err)
+	}
 	if expiration-activation > maxLifetime {
-		rt.Abortf(exitcode.ErrIllegalArgument, "invalid expiration %d, total sector lifetime (%d) cannot exceed %d after activation %d",
+		return xerrors.Errorf("invalid expiration %d, total sector lifetime (%d) cannot exceed %d after activation %d",
 			expiration, expiration-activation, maxLifetime, activation)
 	}
+	return nil
 }
 
-func enrollCronEvent(rt Runtime, eventEpoch abi.ChainEpoch, callbackPayload *CronEventPayload) {
+func enrollCronEvent(rt Runtime, eventEpoch abi.ChainEpoch, eventType CronEventType) {
+	callbackPayload := &CronEventPayload{
+		EventType: eventType,
+		Version:   CronEventPayloadVersion,
+	}
 	payload := new(bytes.Buffer)
 	err := callbackPayload.MarshalCBOR(payload)
 	if err != nil {
@@ -2642,16 +5222,18 @@ func enrollCronEvent(rt Runtime, eventEpoch abi.ChainEpoch, callbackPayload *Cro
 	builtin.RequireSuccess(rt, code, "failed to enroll cron event")
 }
 
-func requestUpdatePower(rt Runtime, delta PowerPair) {
-	if delta.IsZero() {
+// Requests the power actor update the miner's claimed power, and record the change in its faulty power.
+func requestUpdatePower(rt Runtime, delta PowerPair, faultyDelta PowerPair) {
+	if delta.IsZero() && faultyDelta.QA.IsZero() {
 		return
 	}
 	code := rt.Send(
 		builtin.StoragePowerActorAddr,
 		builtin.MethodsPower.UpdateClaimedPower,
 		&power.UpdateClaimedPowerParams{
-			RawByteDelta:         delta.Raw,
-			QualityAdjustedDelta: delta.QA,
+			RawByteDelta:               delta.Raw,
+			QualityAdjustedDelta:       delta.QA,
+			FaultyQualityAdjustedDelta: faultyDelta.QA,
 		},
 		abi.NewTokenAmount(0),
 		&builtin.Discard{},
@@ -2661,7 +5243,7 @@ func requestUpdatePower(rt Runtime, delta PowerPair) {
 
 func requestTerminateDeals(rt Runtime, epoch abi.ChainEpoch, dealIDs []abi.DealID) {
 	for len(dealIDs) > 0 {
-		size := min64(cbg.MaxLength, uint64(len(dealIDs)))
+		size := min64(market.MaxDealsTerminatedPerCall, uint64(len(dealIDs)))
 		code := rt.Send(
 			builtin.StorageMarketActorAddr,
 			builtin.MethodsMarket.OnMinerSectorsTerminate,
@@ -2677,12 +5259,42 @@ func requestTerminateDeals(rt Runtime, epoch abi.ChainEpoch, dealIDs []abi.DealI
 	}
 }
 
+// Removes the verified registry claims of sectors that no longer hold the claimed data.
+func requestRemoveSectorClaims(rt Runtime, sectorNos []uint64) {
+	if len(sectorNos) == 0 {
+		return
+	}
+	code := rt.Send(
+		builtin.VerifiedRegistryActorAddr,
+		builtin.MethodsVerifiedRegistry.RemoveSectorClaims,
+		&verifreg.RemoveSectorClaimsParams{Sectors: bitfield.NewFromSet(sectorNos)},
+		abi.NewTokenAmount(0),
+		&builtin.Discard{},
+	)
+	builtin.RequireSuccess(rt, code, "failed to remove sector claims, exit code %v", code)
+}
+
+func requestTerminateReplacedDeals(rt Runtime, dealIDs []abi.DealID) {
+	for len(dealIDs) > 0 {
+		size := min64(market.MaxDealsTerminatedPerCall, uint64(len(dealIDs)))
+		code := rt.Send(
+			builtin.StorageMarketActorAddr,
+			builtin.MethodsMarket.OnMinerDealsReplaced,
+			&market.OnMinerDealsReplacedParams{
+				DealIDs: dealIDs[:size],
+			},
+			abi.NewTokenAmount(0),
+			&builtin.Discard{},
+		)
+		builtin.RequireSuccess(rt, code, "failed to terminate replaced deals, exit code %v", code)
+		dealIDs = dealIDs[size:]
+	}
+}
+
 func scheduleEarlyTerminationWork(rt Runtime) {
 	rt.Log(rtt.INFO, "scheduling early terminations with cron...")
 
-	enrollCronEvent(rt, rt.CurrEpoch()+1, &CronEventPayload{
-		EventType: CronEventProcessEarlyTerminations,
-	})
+	enrollCronEvent(rt, rt.CurrEpoch()+1, CronEventProcessEarlyTerminations)
 }
 
 func havePendingEarlyTerminations(rt Runtime, st *State) bool {
@@ -2692,7 +5304,57 @@ func havePendingEarlyTerminations(rt Runtime, st *State) bool {
 	return !noEarlyTerminations
 }
 
+// Checks that a Window PoSt's chain commit randomness was drawn within the epochs permitted
+// for the deadline it proves.
+func validateChainCommitEpoch(dlInfo *dline.Info, chainCommitEpoch abi.ChainEpoch) error {
+	if earliest := dlInfo.Challenge - WPoStChainCommitLookback; chainCommitEpoch < earliest {
+		return xerrors.Errorf("chain commit epoch %d before %d", chainCommitEpoch, earliest)
+	}
+	if chainCommitEpoch >= dlInfo.Close {
+		return xerrors.Errorf("chain commit epoch %d not before deadline close %d", chainCommitEpoch, dlInfo.Close)
+	}
+	return nil
+}
+
 func verifyWindowedPost(rt Runtime, challengeEpoch abi.ChainEpoch, sectors []*SectorOnChainInfo, proofs []proof.PoStProof) error {
+	postRandomness, sectorProofInfo, minerActorID := windowedPostPublicInputs(rt, challengeEpoch, sectors)
+
+	// Get public inputs
+	pvInfo := proof.WindowPoStVerifyInfo{
+		Randomness:        postRandomness,
+		Proofs:            proofs,
+		ChallengedSectors: sectorProofInfo,
+		Prover:            minerActorID,
+	}
+
+	// Verify the PoSt ReplicaProof
+	err := rt.VerifyPoSt(pvInfo)
+	if err != nil {
+		return fmt.Errorf("invalid PoSt %+v: %w", pvInfo, err)
+	}
+	return nil
+}
+
+func verifyAggregateWindowedPost(rt Runtime, challengeEpoch abi.ChainEpoch, sectors []*SectorOnChainInfo, partitions uint64, aggregate proof.PoStProof) error {
+	postRandomness, sectorProofInfo, minerActorID := windowedPostPublicInputs(rt, challengeEpoch, sectors)
+
+	pvInfo := proof.AggregateWindowPoStVerifyInfo{
+		Randomness:        postRandomness,
+		AggregateProof:    aggregate,
+		Partitions:        partitions,
+		ChallengedSectors: sectorProofInfo,
+		Prover:            minerActorID,
+	}
+
+	err := rt.VerifyAggregatePoSt(pvInfo)
+	if err != nil {
+		return fmt.Errorf("invalid aggregate PoSt %+v: %w", pvInfo, err)
+	}
+	return nil
+}
+
+// Returns the challenge randomness, challenged sectors and prover of a Window PoSt for a set of sectors.
+func windowedPostPublicInputs(rt Runtime, challengeEpoch abi.ChainEpoch, sectors []*SectorOnChainInfo) (abi.PoStRandomness, []proof.SectorInfo, abi.ActorID) {
 	minerActorID, err := addr.IDFromAddress(rt.Receiver())
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "runtime provided bad receiver address %v", rt.Receiver())
 
@@ -2711,21 +5373,7 @@ func verifyWindowedPost(rt Runtime, challengeEpoch abi.ChainEpoch, sectors []*Se
 			SealedCID:    s.SealedCID,
 		}
 	}
-
-	// Get public inputs
-	pvInfo := proof.WindowPoStVerifyInfo{
-		Randomness:        abi.PoStRandomness(postRandomness),
-		Proofs:            proofs,
-		ChallengedSectors: sectorProofInfo,
-		Prover:            abi.ActorID(minerActorID),
-	}
-
-	// Verify the PoSt ReplicaProof
-	err = rt.VerifyPoSt(pvInfo)
-	if err != nil {
-		return fmt.Errorf("invalid PoSt %+v: %w", pvInfo, err)
-	}
-	return nil
+	return abi.PoStRandomness(postRandomness), sectorProofInfo, abi.ActorID(minerActorID)
 }
 
 // SealVerifyParams is the structure of information that must be sent with a
@@ -2803,6 +5451,37 @@ func requestUnsealedSectorCIDs(rt Runtime, dataCommitmentInputs ...*market.Secto
 }
 
 func requestDealWeights(rt Runtime, sectors []market.SectorDeals) *market.VerifyDealsForActivationReturn {
+	dealWeights, code := tryRequestDealWeights(rt, sectors)
+	builtin.RequireSuccess(rt, code, "failed to verify deals and get deal weight")
+	return dealWeights
+}
+
+// Requests the deal weights of sectors as requestDealWeights, except that sectors whose deals fail verification
+// are isolated rather than aborting. Should verification of the whole batch fail, the deals of each sector are
+// verified alone, and the exit code returned for each sector is that of its own verification.
+func requestDealWeightsIsolatingFailures(rt Runtime, sectors []market.SectorDeals) ([]market.SectorWeights, []exitcode.ExitCode) {
+	codes := make([]exitcode.ExitCode, len(sectors))
+	dealWeights, code := tryRequestDealWeights(rt, sectors)
+	if code.IsSuccess() {
+		return dealWeights.Sectors, codes
+	}
+
+	weights := make([]market.SectorWeights, len(sectors))
+	for i, sector := range sectors {
+		dealWeights, code := tryRequestDealWeights(rt, []market.SectorDeals{sector})
+		if !code.IsSuccess() {
+			codes[i] = code
+			continue
+		}
+		if len(dealWeights.Sectors) != 1 {
+			rt.Abortf(exitcode.ErrIllegalState, "deal weight request returned %d records, expected 1", len(dealWeights.Sectors))
+		}
+		weights[i] = dealWeights.Sectors[0]
+	}
+	return weights, codes
+}
+
+func tryRequestDealWeights(rt Runtime, sectors []market.SectorDeals) (*market.VerifyDealsForActivationReturn, exitcode.ExitCode) {
 	// Short-circuit if there are no deals in any of the sectors.
 	dealCount := 0
 	for _, sector := range sectors {
@@ -2819,7 +5498,7 @@ func requestDealWeights(rt Runtime, sectors []market.SectorDeals) *market.Verify
 				VerifiedDealWeight: big.Zero(),
 			}
 		}
-		return emptyResult
+		return emptyResult, exitcode.Ok
 	}
 
 	var dealWeights market.VerifyDealsForActivationReturn
@@ -2832,8 +5511,7 @@ func requestDealWeights(rt Runtime, sectors []market.SectorDeals) *market.Verify
 		abi.NewTokenAmount(0),
 		&dealWeights,
 	)
-	builtin.RequireSuccess(rt, code, "failed to verify deals and get deal weight")
-	return &dealWeights
+	return &dealWeights, code
 }
 
 // Requests the current epoch target block reward from the reward actor.
@@ -2853,45 +5531,18 @@ func requestCurrentTotalPower(rt Runtime) *power.CurrentTotalPowerReturn {
 	return &pwr
 }
 
-// Resolves an address to an ID address and verifies that it is address of an account or multisig actor.
+// Resolves an owner or control address to an ID address, which must be that of a principal actor.
 func resolveControlAddress(rt Runtime, raw addr.Address) addr.Address {
-	resolved, ok := rt.ResolveAddress(raw)
-	if !ok {
-		rt.Abortf(exitcode.ErrIllegalArgument, "unable to resolve address %v", raw)
-	}
-	ownerCode, ok := rt.GetActorCodeCID(resolved)
-	if !ok {
-		rt.Abortf(exitcode.ErrIllegalArgument, "no code for address %v", resolved)
-	}
-	if !builtin.IsPrincipal(ownerCode) {
-		rt.Abortf(exitcode.ErrIllegalArgument, "owner actor type must be a principal, was %v", ownerCode)
-	}
+	resolved, err := builtin.ResolvePrincipalAddress(rt, raw)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid control address %v", raw)
 	return resolved
 }
 
-// Resolves an address to an ID address and verifies that it is address of an account actor with an associated BLS key.
+// Resolves a worker address to an ID address, which must be that of an account actor with a BLS key.
 // The worker must be BLS since the worker key will be used alongside a BLS-VRF.
 func resolveWorkerAddress(rt Runtime, raw addr.Address) addr.Address {
-	resolved, ok := rt.ResolveAddress(raw)
-	if !ok {
-		rt.Abortf(exitcode.ErrIllegalArgument, "unable to resolve address %v", raw)
-	}
-	workerCode, ok := rt.GetActorCodeCID(resolved)
-	if !ok {
-		rt.Abortf(exitcode.ErrIllegalArgument, "no code for address %v", resolved)
-	}
-	if workerCode != builtin.AccountActorCodeID {
-		rt.Abortf(exitcode.ErrIllegalArgument, "worker actor type must be an account, was %v", workerCode)
-	}
-
-	if raw.Protocol() != addr.BLS {
-		var pubkey addr.Address
-		code := rt.Send(resolved, builtin.MethodsAccount.PubkeyAddress, nil, big.Zero(), &pubkey)
-		builtin.RequireSuccess(rt, code, "failed to fetch account pubkey from %v", resolved)
-		if pubkey.Protocol() != addr.BLS {
-			rt.Abortf(exitcode.ErrIllegalArgument, "worker account %v must have BLS pubkey, was %v", resolved, pubkey.Protocol())
-		}
-	}
+	resolved, err := builtin.ResolveBLSAccountAddress(rt, raw)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid worker address %v", raw)
 	return resolved
 }
 
@@ -2903,13 +5554,58 @@ func burnFunds(rt Runtime, amt abi.TokenAmount, bt BurnMethod) {
 	}
 }
 
-func notifyPledgeChanged(rt Runtime, pledgeDelta abi.TokenAmount) {
-	if !pledgeDelta.IsZero() {
-		code := rt.Send(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &pledgeDelta, big.Zero(), &builtin.Discard{})
+// Reports changes in initial pledge, pre-commit deposits, and locked rewards to the power actor.
+func notifyPledgeChanged(rt Runtime, initialPledgeDelta, preCommitDepositDelta, lockedRewardsDelta abi.TokenAmount) {
+	if !initialPledgeDelta.IsZero() || !preCommitDepositDelta.IsZero() || !lockedRewardsDelta.IsZero() {
+		params := power.UpdatePledgeTotalParams{
+			InitialPledgeDelta:    initialPledgeDelta,
+			PreCommitDepositDelta: preCommitDepositDelta,
+			LockedRewardsDelta:    lockedRewardsDelta,
+		}
+		code := rt.Send(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &params, big.Zero(), &builtin.Discard{})
 		builtin.RequireSuccess(rt, code, "failed to update total pledge")
 	}
 }
 
+// Reports to the power actor whether the miner missed a Window PoSt in the proving period just ended.
+func notifyProvingPeriodEnded(rt Runtime, missedPoSt bool) {
+	code := rt.Send(builtin.StoragePowerActorAddr, builtin.MethodsPower.RecordProvingPeriod,
+		&power.RecordProvingPeriodParams{MissedPoSt: missedPoSt}, big.Zero(), &builtin.Discard{})
+	builtin.RequireSuccess(rt, code, "failed to record proving period")
+}
+
+// Notifies the miner's notification receiver of expired pre-commitments. A failure is logged and ignored,
+// so that a receiver cannot obstruct deadline cron.
+func notifyPreCommitsExpired(rt Runtime, receiver addr.Address, params *PreCommitsExpiredParams) {
+	code := rt.Send(receiver, MethodNotifyPreCommitsExpired, params, big.Zero(), &builtin.Discard{})
+	if !code.IsSuccess() {
+		rt.Log(rtt.WARN, "failed to notify %v of expired pre-commits, exitcode: %d", receiver, code)
+	}
+}
+
+// Returns a reminder of the sectors of a deadline, as just advanced, that are due to expire the given number of
+// proving periods after its end, or nil if there are none.
+func findExpirationReminder(rt Runtime, deadline *Deadline, dlInfo *dline.Info, periods uint64) *SectorsExpiringParams {
+	expiration := dlInfo.Last() + abi.ChainEpoch(periods)*WPoStProvingPeriod
+	expiring, err := deadline.OnTimeExpirations(adt.AsStore(rt), expiration, QuantSpecForDeadline(dlInfo))
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to find sectors expiring at %d", expiration)
+	empty, err := expiring.IsEmpty()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check expiring sectors")
+	if empty {
+		return nil
+	}
+	return &SectorsExpiringParams{Sectors: expiring, Expiration: expiration}
+}
+
+// Reminds the miner's notification receiver of expiring sectors. As with other notifications,
+// a failure is logged and ignored.
+func notifySectorsExpiring(rt Runtime, receiver addr.Address, params *SectorsExpiringParams) {
+	code := rt.Send(receiver, MethodNotifySectorsExpiring, params, big.Zero(), &builtin.Discard{})
+	if !code.IsSuccess() {
+		rt.Log(rtt.WARN, "failed to notify %v of expiring sectors, exitcode: %d", receiver, code)
+	}
+}
+
 // Assigns proving period offset randomly in the range [0, WPoStProvingPeriod) by hashing
 // the actor's address and current epoch.
 func assignProvingPeriodOffset(myAddr addr.Address, currEpoch abi.ChainEpoch, hash func(data []byte) [32]byte) (abi.ChainEpoch, error) {
@@ -2959,17 +5655,35 @@ func currentDeadlineIndex(currEpoch abi.ChainEpoch, periodStart abi.ChainEpoch)
 
 // Update worker address with pending worker key if exists and delay has passed
 func processPendingWorker(info *MinerInfo, rt Runtime, st *State) {
-	if info.PendingWorkerKey == nil || rt.CurrEpoch() < info.PendingWorkerKey.EffectiveAt {
+	if !applyDueWorkerKeyChanges(rt, st, info) {
 		return
 	}
 
-	info.Worker = info.PendingWorkerKey.NewWorker
-	info.PendingWorkerKey = nil
-
 	err := st.SaveInfo(adt.AsStore(rt), info)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
 }
 
+// Makes effective any worker key changes due by the current epoch, recording their confirmation.
+// Returns whether any change was made effective, the caller being responsible for saving the info.
+func applyDueWorkerKeyChanges(rt Runtime, st *State, info *MinerInfo) bool {
+	store := adt.AsStore(rt)
+	applied, err := info.applyWorkerKeyChanges(store, rt.CurrEpoch())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply pending worker key changes")
+
+	events := make([]ControlChangeEvent, len(applied))
+	for i, change := range applied {
+		events[i] = ControlChangeEvent{
+			Kind:        WorkerChangeConfirmed,
+			Address:     change.NewWorker,
+			Epoch:       rt.CurrEpoch(),
+			EffectiveAt: change.EffectiveAt,
+		}
+	}
+	err = st.recordControlChanges(store, events...)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record worker change confirmations")
+	return len(applied) > 0
+}
+
 // Computes deadline information for a fault or recovery declaration.
 // If the deadline has not yet elapsed, the declaration is taken as being for the current proving period.
 // If the deadline has elapsed, it's instead taken as being for the next proving period after the current epoch.
@@ -3047,6 +5761,27 @@ func getMinerInfo(rt Runtime, st *State) *MinerInfo {
 	return info
 }
 
+// Returns whether an address is the miner's owner, worker or one of its control addresses.
+func isControlAddress(info *MinerInfo, a addr.Address) bool {
+	if a == info.Owner || a == info.Worker {
+		return true
+	}
+	for _, ca := range info.ControlAddresses {
+		if a == ca {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the limits on partitions and sectors addressed by a declaration, for the miner's proof type.
+func loadAddressingLimits(rt Runtime) AddressingLimits {
+	var st State
+	rt.StateReadonly(&st)
+	info := getMinerInfo(rt, &st)
+	return AddressingLimitsForProof(info.WindowPoStProofType)
+}
+
 func min64(a, b uint64) uint64 {
 	if a < b {
 		return a
@@ -273,7 +273,7 @@ func TestCommitments(t *testing.T) {
 		// Deals too large for sector
 		dealWeight := big.Mul(big.NewIntUnsigned(32<<30), big.NewInt(int64(expiration-rt.Epoch())))
 		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "deals too large", func() {
-			actor.preCommitSector(rt, actor.makePreCommit(0, challengeEpoch, expiration, []abi.DealID{1}), preCommitConf{
+			actor.preCommitSector(rt, actor.makePreCommit(102, challengeEpoch, expiration, []abi.DealID{1}), preCommitConf{
 				dealWeight:         dealWeight,
 				verifiedDealWeight: big.Zero(),
 				dealSpace:          32<<30 + 1,
@@ -643,6 +643,254 @@ func TestPreCommitBatch(t *testing.T) {
 			actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: sectors}, preCommitBatchConf{firstForMiner: true}, big.Zero())
 		})
 	})
+
+	t.Run("batch2 drops invalid sector", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+
+		sectorExpiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		sectors := []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, nil),
+			*actor.makePreCommit(101, precommitEpoch-1, sectorExpiration, nil),
+			*actor.makePreCommit(102, precommitEpoch-1, rt.Epoch(), nil), // Expires too soon
+		}
+
+		precommits := actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: sectors},
+			preCommitBatchConf{firstForMiner: true, skipInvalid: true, failedSectors: []uint64{2}}, big.Zero())
+		assert.Equal(t, abi.SectorNumber(100), precommits[0].Info.SectorNumber)
+		assert.Equal(t, abi.SectorNumber(101), precommits[1].Info.SectorNumber)
+
+		st := getState(rt)
+		_, found, err := st.GetPrecommittedSector(rt.AdtStore(), 102)
+		require.NoError(t, err)
+		assert.False(t, found)
+		actor.checkState(rt)
+	})
+
+	t.Run("batch2 of only invalid sectors is rejected", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+
+		sectors := []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(100, precommitEpoch-1, rt.Epoch(), nil), // Expires too soon
+			*actor.makePreCommit(101, precommitEpoch, rt.Epoch(), nil),   // Randomness too recent
+		}
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "sector expiration", func() {
+			actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: sectors}, preCommitBatchConf{firstForMiner: true, skipInvalid: true}, big.Zero())
+		})
+	})
+
+	t.Run("batch2 drops sector with deals too large", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+
+		sectorExpiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		sectors := []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, []abi.DealID{1}),
+			*actor.makePreCommit(101, precommitEpoch-1, sectorExpiration, nil),
+		}
+		weights := []market.SectorWeights{{
+			DealSpace:          uint64(actor.sectorSize) + 1,
+			DealWeight:         big.NewInt(1),
+			VerifiedDealWeight: big.Zero(),
+		}}
+
+		// The market reports the oversized deals, so the harness cannot predict the dropped sector's
+		// expectations; they are set up explicitly here.
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		expectQueryNetworkInfo(rt, actor)
+		rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.VerifyDealsForActivation,
+			&market.VerifyDealsForActivationParams{Sectors: []market.SectorDeals{
+				{SectorExpiry: sectorExpiration, DealIDs: []abi.DealID{1}},
+				{SectorExpiry: sectorExpiration, DealIDs: nil},
+			}}, big.Zero(),
+			&market.VerifyDealsForActivationReturn{Sectors: append(weights, market.SectorWeights{
+				DealWeight: big.Zero(), VerifiedDealWeight: big.Zero(),
+			})}, exitcode.Ok)
+		expectedDeposit := actor.expectedPreCommitDeposit(rt, sectorExpiration, big.Zero(), big.Zero())
+		expectUpdatePledgeTotal(rt, big.Zero(), expectedDeposit, big.Zero())
+		st := getState(rt)
+		dlInfo = miner.NewDeadlineInfoFromOffsetAndEpoch(st.ProvingPeriodStart, rt.Epoch())
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent,
+			makeDeadlineCronEventParams(t, dlInfo.Last()), big.Zero(), nil, exitcode.Ok)
+
+		ret := rt.Call(actor.a.PreCommitSectorBatch2, &miner.PreCommitSectorBatch2Params{Sectors: sectors}).(*miner.PreCommitSectorBatchReturn)
+		rt.Verify()
+		failed, err := ret.FailedSectors.All(2)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{0}, failed)
+
+		st = getState(rt)
+		_, found, err := st.GetPrecommittedSector(rt.AdtStore(), 100)
+		require.NoError(t, err)
+		assert.False(t, found)
+		_, found, err = st.GetPrecommittedSector(rt.AdtStore(), 101)
+		require.NoError(t, err)
+		assert.True(t, found)
+		actor.checkState(rt)
+	})
+
+	t.Run("batch2 drops sector whose deals fail verification", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+
+		sectorExpiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		sectors := []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, []abi.DealID{1}),
+			*actor.makePreCommit(101, precommitEpoch-1, sectorExpiration, []abi.DealID{2}),
+			*actor.makePreCommit(102, precommitEpoch-1, sectorExpiration, nil),
+		}
+		weight := market.SectorWeights{
+			DealSpace:          uint64(actor.sectorSize),
+			DealWeight:         big.NewInt(int64(actor.sectorSize)),
+			VerifiedDealWeight: big.Zero(),
+		}
+
+		// Verification of the batch fails, so the deals of each sector are verified alone.
+		// The sector without deals needs no verification.
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		expectQueryNetworkInfo(rt, actor)
+		rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.VerifyDealsForActivation,
+			&market.VerifyDealsForActivationParams{Sectors: []market.SectorDeals{
+				{SectorExpiry: sectorExpiration, DealIDs: []abi.DealID{1}},
+				{SectorExpiry: sectorExpiration, DealIDs: []abi.DealID{2}},
+				{SectorExpiry: sectorExpiration, DealIDs: nil},
+			}}, big.Zero(), &market.VerifyDealsForActivationReturn{}, exitcode.ErrIllegalArgument)
+		rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.VerifyDealsForActivation,
+			&market.VerifyDealsForActivationParams{Sectors: []market.SectorDeals{
+				{SectorExpiry: sectorExpiration, DealIDs: []abi.DealID{1}},
+			}}, big.Zero(), &market.VerifyDealsForActivationReturn{}, exitcode.ErrIllegalArgument)
+		rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.VerifyDealsForActivation,
+			&market.VerifyDealsForActivationParams{Sectors: []market.SectorDeals{
+				{SectorExpiry: sectorExpiration, DealIDs: []abi.DealID{2}},
+			}}, big.Zero(), &market.VerifyDealsForActivationReturn{Sectors: []market.SectorWeights{weight}}, exitcode.Ok)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, miner.AggregatePreCommitNetworkFee(2, big.Zero()), nil, exitcode.Ok)
+		expectedDeposit := big.Add(
+			actor.expectedPreCommitDeposit(rt, sectorExpiration, weight.DealWeight, weight.VerifiedDealWeight),
+			actor.expectedPreCommitDeposit(rt, sectorExpiration, big.Zero(), big.Zero()),
+		)
+		expectUpdatePledgeTotal(rt, big.Zero(), expectedDeposit, big.Zero())
+		st := getState(rt)
+		dlInfo = miner.NewDeadlineInfoFromOffsetAndEpoch(st.ProvingPeriodStart, rt.Epoch())
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent,
+			makeDeadlineCronEventParams(t, dlInfo.Last()), big.Zero(), nil, exitcode.Ok)
+
+		ret := rt.Call(actor.a.PreCommitSectorBatch2, &miner.PreCommitSectorBatch2Params{Sectors: sectors}).(*miner.PreCommitSectorBatchReturn)
+		rt.Verify()
+		failed, err := ret.FailedSectors.All(3)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{0}, failed)
+		accepted, err := ret.AcceptedSectors.All(miner.PreCommitSectorBatchMaxSize)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{101, 102}, accepted)
+
+		precommit := actor.getPreCommit(rt, 101)
+		assert.Equal(t, weight.DealWeight, precommit.DealWeight)
+		actor.checkState(rt)
+	})
+
+	t.Run("batch2 drops duplicate sector", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+
+		sectorExpiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		sectors := []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, nil),
+			*actor.makePreCommit(101, precommitEpoch-1, sectorExpiration, nil),
+			*actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, nil),
+		}
+		actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: sectors},
+			preCommitBatchConf{firstForMiner: true, skipInvalid: true, failedSectors: []uint64{2}}, big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("batch2 drops sector number already allocated", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+
+		sectorExpiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		actor.preCommitSector(rt, actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, nil), preCommitConf{}, true)
+
+		sectors := []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, nil),
+			*actor.makePreCommit(101, precommitEpoch-1, sectorExpiration, nil),
+		}
+		precommits := actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: sectors},
+			preCommitBatchConf{skipInvalid: true, failedSectors: []uint64{0}}, big.Zero())
+		assert.Equal(t, abi.SectorNumber(101), precommits[1].Info.SectorNumber)
+		actor.checkState(rt)
+	})
+
+	t.Run("batch2 drops sectors whose deposit cannot be covered", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+
+		// The balance covers the deposit of a single sector.
+		sectorExpiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		rt.SetBalance(actor.expectedPreCommitDeposit(rt, sectorExpiration, big.Zero(), big.Zero()))
+
+		sectors := []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(100, precommitEpoch-1, sectorExpiration, nil),
+			*actor.makePreCommit(101, precommitEpoch-1, sectorExpiration, nil),
+			*actor.makePreCommit(102, precommitEpoch-1, sectorExpiration, nil),
+		}
+		actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: sectors},
+			preCommitBatchConf{firstForMiner: true, skipInvalid: true, failedSectors: []uint64{1, 2}}, big.Zero())
+		actor.checkState(rt)
+
+		// A batch none of whose deposits can be covered is rejected.
+		sectors = []miner0.SectorPreCommitInfo{
+			*actor.makePreCommit(103, precommitEpoch-1, sectorExpiration, nil),
+		}
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "insufficient funds", func() {
+			actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: sectors},
+				preCommitBatchConf{skipInvalid: true}, big.Zero())
+		})
+	})
 }
 
 func TestProveCommit(t *testing.T) {
@@ -874,7 +1122,7 @@ func TestProveCommit(t *testing.T) {
 
 		// Too late.
 		rt.SetEpoch(precommitEpoch + miner.MaxProveCommitDuration[precommit.Info.SealProof] + 1)
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+		rt.ExpectAbort(miner.ErrPreCommitExpired, func() {
 			actor.proveCommitSectorAndConfirm(rt, precommit, makeProveCommit(sectorNo), proveCommitConf{})
 		})
 		rt.Reset()
@@ -953,7 +1201,7 @@ func TestProveCommit(t *testing.T) {
 		rt.SetBalance(big.Sum(st.PreCommitDeposits, st.InitialPledge, st.LockedFunds))
 
 		rt.SetEpoch(precommitEpoch + miner.MaxProveCommitDuration[actor.sealProofType] - 1)
-		rt.ExpectAbort(exitcode.ErrInsufficientFunds, func() {
+		rt.ExpectAbort(miner.ErrInsufficientPledge, func() {
 			actor.proveCommitSectorAndConfirm(rt, precommit, makeProveCommit(actor.nextSectorNo), proveCommitConf{})
 		})
 		rt.Reset()
@@ -1036,8 +1284,9 @@ func TestProveCommit(t *testing.T) {
 		rt.SetEpoch(rt.Epoch() + miner.PreCommitChallengeDelay + 1)
 		actor.proveCommitSector(rt, precommit, makeProveCommit(sectorNo))
 
-		// confirm at sector expiration (this probably can't happen)
+		// confirm at sector expiration (this probably can't happen, as the proof would be too late)
 		rt.SetEpoch(precommit.Info.Expiration)
+		actor.recordPreCommitProven(rt, sectorNo)
 		// sector skipped but no failure occurs
 		actor.confirmSectorProofsValid(rt, proveCommitConf{}, precommit)
 		rt.ExpectLogsContain("less than minimum. ignoring")
@@ -1045,17 +1294,241 @@ func TestProveCommit(t *testing.T) {
 		// it still skips if sector lifetime is negative
 		rt.ClearLogs()
 		rt.SetEpoch(precommit.Info.Expiration + 1)
+		actor.recordPreCommitProven(rt, sectorNo)
 		actor.confirmSectorProofsValid(rt, proveCommitConf{}, precommit)
 		rt.ExpectLogsContain("less than minimum. ignoring")
 
 		// it fails up to the miniumum expiration
 		rt.ClearLogs()
 		rt.SetEpoch(precommit.Info.Expiration - miner.MinSectorExpiration + 1)
+		actor.recordPreCommitProven(rt, sectorNo)
 		actor.confirmSectorProofsValid(rt, proveCommitConf{}, precommit)
 		rt.ExpectLogsContain("less than minimum. ignoring")
 		actor.checkState(rt)
 	})
 
+	t.Run("sector proven in an earlier epoch is not confirmed", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		deadline := actor.deadline(rt)
+
+		sectorNo := abi.SectorNumber(100)
+		params := actor.makePreCommit(sectorNo, precommitEpoch-1, deadline.PeriodEnd()+defaultSectorExpiration*miner.WPoStProvingPeriod, nil)
+		precommit := actor.preCommitSector(rt, params, preCommitConf{}, true)
+
+		rt.SetEpoch(precommitEpoch + miner.PreCommitChallengeDelay + 1)
+		actor.proveCommitSector(rt, precommit, makeProveCommit(sectorNo))
+		assert.Equal(t, miner.SectorActivationProven, actor.activationStage(rt, sectorNo))
+
+		// The proof was not confirmed in the epoch it was accepted, so the sector is pre-committed again.
+		rt.SetEpoch(rt.Epoch() + 1)
+		assert.Equal(t, miner.SectorActivationPreCommitted, actor.activationStage(rt, sectorNo))
+
+		rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StoragePowerActorAddr)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "all prove commits failed to validate", func() {
+			rt.Call(actor.a.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+				Sectors:                 []abi.SectorNumber{sectorNo},
+				RewardSmoothed:          actor.epochRewardSmooth,
+				RewardBaselinePower:     actor.baselinePower,
+				QualityAdjPowerSmoothed: actor.epochQAPowerSmooth,
+			})
+		})
+		rt.Reset()
+
+		// The sector may be proven again.
+		actor.proveCommitSectorAndConfirm(rt, precommit, makeProveCommit(sectorNo), proveCommitConf{})
+		assert.Equal(t, miner.SectorActivationActive, actor.activationStage(rt, sectorNo))
+
+		// A repeated confirmation of an active sector is skipped.
+		rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StoragePowerActorAddr)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "all prove commits failed to validate", func() {
+			rt.Call(actor.a.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+				Sectors:                 []abi.SectorNumber{sectorNo},
+				RewardSmoothed:          actor.epochRewardSmooth,
+				RewardBaselinePower:     actor.baselinePower,
+				QualityAdjPowerSmoothed: actor.epochQAPowerSmooth,
+			})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("proof deferred by the power actor is confirmed in the next epoch", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		deadline := actor.deadline(rt)
+
+		sectorNo := abi.SectorNumber(100)
+		params := actor.makePreCommit(sectorNo, precommitEpoch-1, deadline.PeriodEnd()+defaultSectorExpiration*miner.WPoStProvingPeriod, nil)
+		precommit := actor.preCommitSector(rt, params, preCommitConf{}, true)
+
+		rt.SetEpoch(precommitEpoch + miner.PreCommitChallengeDelay + 1)
+		actor.proveCommitSector(rt, precommit, makeProveCommit(sectorNo))
+
+		// The power actor defers the proof's verification, confirming no other sector.
+		rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StoragePowerActorAddr)
+		rt.Call(actor.a.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+			RewardSmoothed:          actor.epochRewardSmooth,
+			RewardBaselinePower:     actor.baselinePower,
+			QualityAdjPowerSmoothed: actor.epochQAPowerSmooth,
+			Deferred:                []abi.SectorNumber{sectorNo},
+		})
+		rt.Verify()
+
+		// The sector remains proven in the next epoch, when its proof is confirmed.
+		rt.SetEpoch(rt.Epoch() + 1)
+		assert.Equal(t, miner.SectorActivationProven, actor.activationStage(rt, sectorNo))
+		actor.checkState(rt)
+
+		actor.confirmSectorProofsValid(rt, proveCommitConf{}, precommit)
+		assert.Equal(t, miner.SectorActivationActive, actor.activationStage(rt, sectorNo))
+		actor.checkState(rt)
+	})
+
+	t.Run("priority fee of ProveCommitSector2 is paid to the power actor from the miner's balance", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		deadline := actor.deadline(rt)
+		expiration := deadline.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+
+		precommit1 := actor.preCommitSector(rt, actor.makePreCommit(100, precommitEpoch-1, expiration, nil), preCommitConf{}, true)
+		precommit2 := actor.preCommitSector(rt, actor.makePreCommit(101, precommitEpoch-1, expiration, nil), preCommitConf{}, false)
+		rt.SetEpoch(precommitEpoch + miner.PreCommitChallengeDelay + 1)
+
+		fee := abi.NewTokenAmount(1_000)
+		balance := rt.Balance()
+		actor.proveCommitSector2By(rt, actor.worker, precommit1, &miner.ProveCommitSector2Params{
+			SectorNumber: 100,
+			Proof:        makeProveCommit(100).Proof,
+			PriorityFee:  fee,
+		})
+		assert.Equal(t, big.Sub(balance, fee), rt.Balance())
+
+		// Only the miner's own addresses may spend its balance.
+		params := &miner.ProveCommitSector2Params{SectorNumber: 101, Proof: makeProveCommit(101).Proof, PriorityFee: fee}
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "may not pay a priority fee", func() {
+			actor.proveCommitSector2By(rt, tutil.NewIDAddr(t, 5000), precommit2, params)
+		})
+		rt.Reset()
+
+		// The fee may not exceed the available balance.
+		st := getState(rt)
+		available, err := st.GetAvailableBalance(rt.Balance())
+		require.NoError(t, err)
+		params.PriorityFee = big.Add(available, big.NewInt(1))
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "priority fee", func() {
+			actor.proveCommitSector2By(rt, actor.worker, precommit2, params)
+		})
+		rt.Reset()
+
+		params.PriorityFee = big.NewInt(-1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "negative priority fee", func() {
+			actor.proveCommitSector2By(rt, actor.worker, precommit2, params)
+		})
+		rt.Reset()
+
+		// A zero fee may be paid by anyone.
+		params.PriorityFee = big.Zero()
+		actor.proveCommitSector2By(rt, tutil.NewIDAddr(t, 5000), precommit2, params)
+		actor.checkState(rt)
+	})
+
+	t.Run("pre-commitment with a failed proof is discarded and its deposit burnt", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		deadline := actor.deadline(rt)
+
+		failedNo := abi.SectorNumber(100)
+		validNo := abi.SectorNumber(101)
+		expiration := deadline.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		failed := actor.preCommitSector(rt, actor.makePreCommit(failedNo, precommitEpoch-1, expiration, nil), preCommitConf{}, true)
+		valid := actor.preCommitSector(rt, actor.makePreCommit(validNo, precommitEpoch-1, expiration, nil), preCommitConf{}, false)
+
+		rt.SetEpoch(precommitEpoch + miner.PreCommitChallengeDelay + 1)
+		actor.proveCommitSector(rt, failed, makeProveCommit(failedNo))
+		actor.proveCommitSector(rt, valid, makeProveCommit(validNo))
+
+		// The failed pre-commitment's deposit is burnt, and the valid sector activated.
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, failed.PreCommitDeposit, nil, exitcode.Ok)
+		expectUpdatePledgeTotal(rt, big.Zero(), failed.PreCommitDeposit.Neg(), big.Zero())
+		actor.confirmSectorProofsValidInternal(rt, proveCommitConf{}, valid)
+
+		rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StoragePowerActorAddr)
+		rt.Call(actor.a.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+			Sectors:                 []abi.SectorNumber{validNo},
+			RewardSmoothed:          actor.epochRewardSmooth,
+			RewardBaselinePower:     actor.baselinePower,
+			QualityAdjPowerSmoothed: actor.epochQAPowerSmooth,
+			Failures:                []builtin.SectorProofFailure{{SectorNumber: failedNo, Code: exitcode.ErrIllegalArgument}},
+		})
+		rt.Verify()
+		rt.ExpectLogsContain("failed verification")
+
+		assert.Equal(t, miner.SectorActivationNone, actor.activationStage(rt, failedNo))
+		assert.Equal(t, miner.SectorActivationActive, actor.activationStage(rt, validNo))
+		assert.True(t, getState(rt).PreCommitDeposits.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("failed proofs are discarded when no proof is valid", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		deadline := actor.deadline(rt)
+
+		sectorNo := abi.SectorNumber(100)
+		params := actor.makePreCommit(sectorNo, precommitEpoch-1, deadline.PeriodEnd()+defaultSectorExpiration*miner.WPoStProvingPeriod, nil)
+		precommit := actor.preCommitSector(rt, params, preCommitConf{}, true)
+
+		rt.SetEpoch(precommitEpoch + miner.PreCommitChallengeDelay + 1)
+		actor.proveCommitSector(rt, precommit, makeProveCommit(sectorNo))
+
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, precommit.PreCommitDeposit, nil, exitcode.Ok)
+		expectUpdatePledgeTotal(rt, big.Zero(), precommit.PreCommitDeposit.Neg(), big.Zero())
+
+		rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StoragePowerActorAddr)
+		rt.Call(actor.a.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+			RewardSmoothed:          actor.epochRewardSmooth,
+			RewardBaselinePower:     actor.baselinePower,
+			QualityAdjPowerSmoothed: actor.epochQAPowerSmooth,
+			Failures:                []builtin.SectorProofFailure{{SectorNumber: sectorNo, Code: exitcode.ErrIllegalArgument}},
+		})
+		rt.Verify()
+
+		assert.Equal(t, miner.SectorActivationNone, actor.activationStage(rt, sectorNo))
+		assert.True(t, getState(rt).PreCommitDeposits.IsZero())
+		actor.checkState(rt)
+	})
+
 	t.Run("verify proof does not vest funds", func(t *testing.T) {
 		actor := newHarness(t, periodOffset)
 		actor.setProofType(abi.RegisteredSealProof_StackedDrg32GiBV1_1)
@@ -1220,6 +1693,383 @@ func TestAggregateProveCommit(t *testing.T) {
 		assert.Equal(t, tenSectorsInitialPledge, st.InitialPledge)
 
 	})
+
+	lateSetup := func(t *testing.T) (*mock.Runtime, *actorHarness, []*miner.SectorPreCommitOnChainInfo, []*miner.SectorPreCommitOnChainInfo) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+		msd := miner.MaxProveCommitDuration[actor.sealProofType]
+		// Far enough out to leave the minimum lifetime after the last pre-commitment.
+		expiration := dlInfo.PeriodEnd() + 2*defaultSectorExpiration*miner.WPoStProvingPeriod
+
+		var late, onTime []*miner.SectorPreCommitOnChainInfo
+		for i := 0; i < 2; i++ {
+			params := actor.makePreCommit(abi.SectorNumber(i), precommitEpoch-1, expiration, nil)
+			late = append(late, actor.preCommitSector(rt, params, preCommitConf{}, i == 0))
+		}
+		// Pre-commit the remaining sectors just in time to be proven with the late ones.
+		rt.SetEpoch(precommitEpoch + msd - miner.PreCommitChallengeDelay)
+		for i := 2; i < 6; i++ {
+			params := actor.makePreCommit(abi.SectorNumber(i), rt.Epoch()-1, expiration, nil)
+			onTime = append(onTime, actor.preCommitSector(rt, params, preCommitConf{}, false))
+		}
+		rt.SetEpoch(precommitEpoch + msd + 1)
+		return rt, actor, late, onTime
+	}
+
+	t.Run("deposits of sectors proven too late are partly refunded", func(t *testing.T) {
+		rt, actor, late, onTime := lateSetup(t)
+		sectorNosBf := bitfield.New()
+		for _, precommit := range append(late, onTime...) {
+			sectorNosBf.Set(uint64(precommit.Info.SectorNumber))
+		}
+		balanceBefore := rt.Balance()
+		lateDeposit := big.Add(late[0].PreCommitDeposit, late[1].PreCommitDeposit)
+		refund := miner.LatePreCommitDepositRefundAmount(lateDeposit)
+		require.True(t, refund.GreaterThan(big.Zero()))
+		require.True(t, refund.LessThan(lateDeposit))
+
+		actor.proveCommitAggregateSector(rt, proveCommitConf{}, append(late, onTime...), makeProveCommitAggregate(sectorNosBf), big.Zero())
+
+		// Late pre-commitments are removed, and only the refund of their deposits remains with the miner.
+		st := getState(rt)
+		assert.True(t, st.PreCommitDeposits.IsZero())
+		for _, precommit := range late {
+			_, found, err := st.GetPrecommittedSector(rt.AdtStore(), precommit.Info.SectorNumber)
+			require.NoError(t, err)
+			assert.False(t, found)
+			active, err := st.HasSectorNo(rt.AdtStore(), precommit.Info.SectorNumber)
+			require.NoError(t, err)
+			assert.False(t, active)
+		}
+		for _, precommit := range onTime {
+			active, err := st.HasSectorNo(rt.AdtStore(), precommit.Info.SectorNumber)
+			require.NoError(t, err)
+			assert.True(t, active)
+		}
+		fee := miner.AggregateProveCommitNetworkFee(len(onTime), big.Zero())
+		assert.Equal(t, big.Sub(balanceBefore, big.Sum(fee, lateDeposit, refund.Neg())), rt.Balance())
+		actor.checkState(rt)
+	})
+
+	t.Run("deposits of sectors proven too late are left for clean up when refunds are disabled", func(t *testing.T) {
+		refund := miner.LatePreCommitDepositRefund
+		miner.LatePreCommitDepositRefund = builtin.BigFrac{Numerator: big.Zero(), Denominator: big.NewInt(1)}
+		defer func() { miner.LatePreCommitDepositRefund = refund }()
+
+		rt, actor, late, onTime := lateSetup(t)
+		sectorNosBf := bitfield.New()
+		for _, precommit := range append(late, onTime...) {
+			sectorNosBf.Set(uint64(precommit.Info.SectorNumber))
+		}
+		balanceBefore := rt.Balance()
+
+		actor.proveCommitAggregateSector(rt, proveCommitConf{}, append(late, onTime...), makeProveCommitAggregate(sectorNosBf), big.Zero())
+
+		st := getState(rt)
+		assert.Equal(t, big.Add(late[0].PreCommitDeposit, late[1].PreCommitDeposit), st.PreCommitDeposits)
+		for _, precommit := range late {
+			_, found, err := st.GetPrecommittedSector(rt.AdtStore(), precommit.Info.SectorNumber)
+			require.NoError(t, err)
+			assert.True(t, found)
+		}
+		fee := miner.AggregateProveCommitNetworkFee(len(onTime), big.Zero())
+		assert.Equal(t, big.Sub(balanceBefore, fee), rt.Balance())
+		actor.checkState(rt)
+	})
+}
+
+func TestProveCommitSectorsNI(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	sectorNos := []abi.SectorNumber{100, 101, 102, 103}
+
+	setup := func(t *testing.T) (*actorHarness, *mock.Runtime, abi.ChainEpoch) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		rt.SetEpoch(periodOffset + miner.PreCommitChallengeDelay + 1)
+		actor.constructAndVerify(rt)
+		expiration := actor.deadline(rt).PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		return actor, rt, expiration
+	}
+
+	t.Run("committed-capacity sectors are activated without pre-commitment", func(t *testing.T) {
+		actor, rt, expiration := setup(t)
+
+		params := actor.makeProveCommitSectorsNI(sectorNos, rt.Epoch()-miner.PreCommitChallengeDelay-1, expiration)
+		sectors := actor.proveCommitSectorsNI(rt, params, big.Zero(), true)
+
+		st := getState(rt)
+		totalPledge := big.Zero()
+		for i, sector := range sectors {
+			assert.Equal(t, sectorNos[i], sector.SectorNumber)
+			assert.Equal(t, rt.Epoch(), sector.Activation)
+			assert.Equal(t, expiration, sector.Expiration)
+			assert.Empty(t, sector.DealIDs)
+			assert.Equal(t, miner.SectorActivationActive, actor.activationStage(rt, sector.SectorNumber))
+			totalPledge = big.Add(totalPledge, sector.InitialPledge)
+
+			dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sector.SectorNumber)
+			require.NoError(t, err)
+			_, partition := actor.getDeadlineAndPartition(rt, dlIdx, pIdx)
+			unproven, err := partition.Unproven.IsSet(uint64(sector.SectorNumber))
+			require.NoError(t, err)
+			assert.True(t, unproven)
+		}
+		assert.Equal(t, totalPledge, st.InitialPledge)
+		assert.True(t, st.PreCommitDeposits.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects sector numbers already allocated", func(t *testing.T) {
+		actor, rt, expiration := setup(t)
+
+		precommit := actor.makePreCommit(sectorNos[0], rt.Epoch()-1, expiration, nil)
+		actor.preCommitSector(rt, precommit, preCommitConf{}, true)
+
+		params := actor.makeProveCommitSectorsNI(sectorNos, rt.Epoch()-miner.PreCommitChallengeDelay-1, expiration)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "failed to allocate sector ids", func() {
+			actor.proveCommitSectorsNI(rt, params, big.Zero(), false)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects seal randomness older than the lookback", func(t *testing.T) {
+		actor, rt, expiration := setup(t)
+		rt.SetEpoch(rt.Epoch() + miner.MaxProveCommitNIRandomnessLookback + 1)
+
+		// Seal randomness within the pre-commit lookback is too old to prove without pre-commitment.
+		require.Less(t, miner.MaxProveCommitNIRandomnessLookback+1, miner.MaxPreCommitRandomnessLookback)
+		params := actor.makeProveCommitSectorsNI(sectorNos, rt.Epoch()-miner.MaxProveCommitNIRandomnessLookback-1, expiration)
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too old", func() {
+			rt.Call(actor.a.ProveCommitSectorsNI, params)
+		})
+		rt.Reset()
+
+		// The oldest seal randomness permitted is accepted.
+		params = actor.makeProveCommitSectorsNI(sectorNos, rt.Epoch()-miner.MaxProveCommitNIRandomnessLookback, expiration)
+		actor.proveCommitSectorsNI(rt, params, big.Zero(), true)
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects seal randomness too recent to draw a later challenge seed", func(t *testing.T) {
+		actor, rt, expiration := setup(t)
+
+		// Drawing the challenge seed at the seal randomness epoch would reveal the challenges before the replica is fixed.
+		params := actor.makeProveCommitSectorsNI(sectorNos, rt.Epoch()-miner.PreCommitChallengeDelay, expiration)
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "interactive challenge epoch", func() {
+			rt.Call(actor.a.ProveCommitSectorsNI, params)
+		})
+		rt.Reset()
+	})
+
+	t.Run("rejects too few sectors", func(t *testing.T) {
+		actor, rt, expiration := setup(t)
+
+		params := actor.makeProveCommitSectorsNI(sectorNos[:miner.MinAggregatedSectors-1], rt.Epoch()-miner.PreCommitChallengeDelay-1, expiration)
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too few sectors", func() {
+			rt.Call(actor.a.ProveCommitSectorsNI, params)
+		})
+		rt.Reset()
+	})
+}
+
+func TestCleanUpExpiredPreCommits(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+
+	setup := func(t *testing.T) (*actorHarness, *mock.Runtime, *miner.SectorPreCommitOnChainInfo) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		rt.SetEpoch(periodOffset + 1)
+		actor.constructAndVerify(rt)
+		expiration := actor.deadline(rt).PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		precommit := actor.preCommitSector(rt, actor.makePreCommit(100, rt.Epoch()-1, expiration, nil), preCommitConf{}, true)
+		return actor, rt, precommit
+	}
+
+	t.Run("expired pre-commit is cleaned up before the clean up delay passes", func(t *testing.T) {
+		actor, rt, precommit := setup(t)
+		rt.SetEpoch(precommit.PreCommitEpoch + miner.MaxProveCommitDuration[actor.sealProofType] + 1)
+
+		actor.cleanUpExpiredPreCommits(rt, precommit.PreCommitDeposit, nil)
+		st := getState(rt)
+		_, found, err := st.GetPrecommittedSector(rt.AdtStore(), precommit.Info.SectorNumber)
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.True(t, st.PreCommitDeposits.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("pre-commit that may yet be proven is retained", func(t *testing.T) {
+		actor, rt, precommit := setup(t)
+		rt.SetEpoch(precommit.PreCommitEpoch + miner.MaxProveCommitDuration[actor.sealProofType])
+
+		actor.cleanUpExpiredPreCommits(rt, big.Zero(), nil)
+		_, found, err := getState(rt).GetPrecommittedSector(rt.AdtStore(), precommit.Info.SectorNumber)
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		// The pre-commit remains queued for clean up once it expires.
+		rt.SetEpoch(rt.Epoch() + 1)
+		actor.cleanUpExpiredPreCommits(rt, precommit.PreCommitDeposit, nil)
+		actor.checkState(rt)
+	})
+
+	t.Run("only the owner or worker may clean up", func(t *testing.T) {
+		actor, rt, _ := setup(t)
+		other := tutil.NewIDAddr(t, 1000)
+
+		rt.SetCaller(other, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner, actor.worker)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.CleanUpExpiredPreCommits, nil)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestPreCommitExpiryNotification(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	receiver := tutil.NewIDAddr(t, 1000)
+
+	setup := func(t *testing.T) (*actorHarness, *mock.Runtime, *miner.SectorPreCommitOnChainInfo) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		rt.SetEpoch(periodOffset + 1)
+		actor.constructAndVerify(rt)
+		expiration := actor.deadline(rt).PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		precommit := actor.preCommitSector(rt, actor.makePreCommit(100, rt.Epoch()-1, expiration, nil), preCommitConf{}, true)
+		return actor, rt, precommit
+	}
+
+	// Advances through the deadline cron that cleans up the pre-commit, with the expected notification.
+	expirePreCommit := func(t *testing.T, actor *actorHarness, rt *mock.Runtime, precommit *miner.SectorPreCommitOnChainInfo,
+		notification *miner.PreCommitsExpiredParams, notificationExit exitcode.ExitCode) {
+		cleanUpEpoch := precommit.PreCommitEpoch + miner.MaxProveCommitDuration[actor.sealProofType] + miner.ExpiredPreCommitCleanUpDelay
+		dlinfo := actor.deadline(rt)
+		for dlinfo.Open <= cleanUpEpoch {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
+		rt.SetEpoch(dlinfo.Last())
+		actor.onDeadlineCron(rt, &cronConfig{
+			noEnrollment:                 true,
+			expiredPrecommitPenalty:      precommit.PreCommitDeposit,
+			expiredPrecommitNotification: notification,
+			notificationExit:             notificationExit,
+		})
+		_, found, err := getState(rt).GetPrecommittedSector(rt.AdtStore(), precommit.Info.SectorNumber)
+		require.NoError(t, err)
+		assert.False(t, found)
+	}
+
+	t.Run("receiver is notified of expired pre-commits", func(t *testing.T) {
+		actor, rt, precommit := setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+		assert.Equal(t, &receiver, actor.getInfo(rt).NotificationReceiver)
+
+		expirePreCommit(t, actor, rt, precommit, &miner.PreCommitsExpiredParams{
+			Sectors:      bitfield.NewFromSet([]uint64{uint64(precommit.Info.SectorNumber)}),
+			DepositBurnt: precommit.PreCommitDeposit,
+		}, exitcode.Ok)
+		actor.checkState(rt)
+	})
+
+	t.Run("receiver failure does not abort cron", func(t *testing.T) {
+		actor, rt, precommit := setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+
+		expirePreCommit(t, actor, rt, precommit, &miner.PreCommitsExpiredParams{
+			Sectors:      bitfield.NewFromSet([]uint64{uint64(precommit.Info.SectorNumber)}),
+			DepositBurnt: precommit.PreCommitDeposit,
+		}, exitcode.ErrForbidden)
+		actor.checkState(rt)
+	})
+
+	t.Run("no notification once receiver is cleared", func(t *testing.T) {
+		actor, rt, precommit := setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+		actor.setNotificationReceiver(rt, nil)
+		assert.Nil(t, actor.getInfo(rt).NotificationReceiver)
+
+		expirePreCommit(t, actor, rt, precommit, nil, exitcode.Ok)
+		actor.checkState(rt)
+	})
+
+	t.Run("receiver address is resolved", func(t *testing.T) {
+		actor, rt, _ := setup(t)
+		receiverKey := tutil.NewBLSAddr(t, 1)
+		rt.AddIDAddress(receiverKey, receiver)
+		rt.SetAddressActorType(receiver, notificationReceiverCode)
+
+		actor.setNotificationReceiver(rt, &receiverKey)
+		assert.Equal(t, &receiver, actor.getInfo(rt).NotificationReceiver)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to set unresolvable receiver", func(t *testing.T) {
+		actor, rt, _ := setup(t)
+		unknown := tutil.NewBLSAddr(t, 2)
+
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.setNotificationReceiver(rt, &unknown)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("receiver is notified of pre-commits cleaned up early", func(t *testing.T) {
+		actor, rt, precommit := setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+		rt.SetEpoch(precommit.PreCommitEpoch + miner.MaxProveCommitDuration[actor.sealProofType] + 1)
+
+		actor.cleanUpExpiredPreCommits(rt, precommit.PreCommitDeposit, &miner.PreCommitsExpiredParams{
+			Sectors:      bitfield.NewFromSet([]uint64{uint64(precommit.Info.SectorNumber)}),
+			DepositBurnt: precommit.PreCommitDeposit,
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to set a built-in actor as receiver", func(t *testing.T) {
+		actor, rt, _ := setup(t)
+		rt.SetAddressActorType(receiver, builtin.MultisigActorCodeID)
+
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "not an actor that may be notified", func() {
+			rt.Call(actor.a.SetNotificationReceiver, &miner.SetNotificationReceiverParams{NewReceiver: &receiver})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("only the owner may set the receiver", func(t *testing.T) {
+		actor, rt, _ := setup(t)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.SetNotificationReceiver, &miner.SetNotificationReceiverParams{NewReceiver: &receiver})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
 }
 
 func TestBatchMethodNetworkFees(t *testing.T) {
@@ -79,6 +79,55 @@ type State struct {
 
 	// True when miner cron is active, false otherwise
 	DeadlineCronActive bool
+
+	// Recovery declarations queued while the miner's fee debt could not be repaid, to be declared
+	// by deadline cron once it is. Nil when nothing is queued.
+	QueuedRecoveries *cid.Cid // QueuedRecoveries
+
+	// Pre-committed sectors whose prove-commitments were accepted at ProvenPreCommitsEpoch, awaiting confirmation
+	// of their proofs at the end of that epoch. Entries from an earlier epoch are stale, their proofs having
+	// failed verification, and are discarded when the next proof is accepted.
+	ProvenPreCommits      bitfield.BitField
+	ProvenPreCommitsEpoch abi.ChainEpoch
+
+	// Thresholds set by the owner on messages committing sectors. Nil when never set.
+	OwnerSettings *OwnerSettings
+
+	// Nonce expected of the next Window PoSt relayed with a worker-signed intent.
+	PoStRelayNonce uint64
+
+	// Manifests committing to the piece layout of sectors. Nil until the first manifest is recorded.
+	SectorManifests *cid.Cid // Map, HAMT[SectorNumber]cid.Cid
+
+	// Deadlines with no live sectors nor other state to process at the end of their challenge windows,
+	// which deadline cron skips without loading them. A deadline is added when found empty at the end of
+	// its challenge window, and removed when next updated.
+	EmptyDeadlines bitfield.BitField
+
+	// The miner's most recently declared maintenance window. Nil when never declared.
+	MaintenanceWindow *MaintenanceWindow
+
+	// Number of cron events received but not processed, because their payloads could not be decoded,
+	// were of an unknown version, or carried an unknown event type.
+	DroppedCronEvents uint64
+
+	// Sectors found faulty at the end of each of their challenge windows since a recorded epoch, and
+	// those epochs. The epochs are nil until the first sector is found faulty.
+	FaultStreakSectors bitfield.BitField
+	FaultStreakStarts  *cid.Cid // Map, HAMT[SectorNumber]ChainEpoch
+
+	// The prior infos of committed-capacity sectors recently updated with a replica, from which the updates
+	// may be rolled back. Nil until the first update is recorded.
+	ReplicaUpdateRollbacks *cid.Cid // Map, HAMT[SectorNumber]ReplicaUpdateRollback
+
+	// The most recent proposals, confirmations and withdrawals of owner and worker changes, at most
+	// MaxControlChangeHistory of them. Nil until the first change is proposed.
+	ControlChanges *cid.Cid // ControlChangeHistory
+}
+
+// Recovery declarations awaiting repayment of a miner's fee debt, with at most one entry per partition.
+type QueuedRecoveries struct {
+	Recoveries []RecoveryDeclaration
 }
 
 // Bitwidth of AMTs determined empirically from mutation patterns and projections of mainnet data.
@@ -98,7 +147,8 @@ type MinerInfo struct {
 	// Additional addresses that are permitted to submit messages controlling this actor (optional).
 	ControlAddresses []addr.Address // Must all be ID addresses.
 
-	PendingWorkerKey *WorkerKeyChange
+	// Scheduled worker key changes, keyed by the epoch at which each takes effect (optional).
+	PendingWorkerKeys *cid.Cid // Array, AMT[ChainEpoch]WorkerKeyChange
 
 	// Byte array representing a Libp2p identity that should be used when connecting to this miner.
 	PeerId abi.PeerID
@@ -126,6 +176,23 @@ type MinerInfo struct {
 	// A proposed new owner account for this miner.
 	// Must be confirmed by a message from the pending address itself.
 	PendingOwnerAddress *addr.Address
+
+	// Account to which funds withdrawn from this miner are paid.
+	// This is the owner unless the owner has designated another beneficiary.
+	Beneficiary addr.Address // Must be an ID-address.
+
+	// The terms limiting withdrawals to a beneficiary other than the owner.
+	BeneficiaryTerm BeneficiaryTerm
+
+	// A proposed change of beneficiary, awaiting approval.
+	PendingBeneficiaryTerm *PendingBeneficiaryChange
+
+	// Actor notified when deadline cron cleans up expired pre-commitments (optional).
+	NotificationReceiver *addr.Address // Must be an ID address.
+
+	// Number of proving periods before its expiration at which the notification receiver is reminded
+	// of a sector's expiration, or zero for no reminders.
+	ExpirationReminderPeriods uint64
 }
 
 type WorkerKeyChange struct {
@@ -207,6 +274,10 @@ func ConstructState(store adt.Store, infoCid cid.Cid, periodStart abi.ChainEpoch
 	if err != nil {
 		return nil, xerrors.Errorf("failed to construct empty deadlines: %w", err)
 	}
+	allDeadlines := make([]uint64, WPoStPeriodDeadlines)
+	for i := range allDeadlines {
+		allDeadlines[i] = uint64(i)
+	}
 	emptyVestingFundsCid, err := store.Put(store.Context(), ConstructVestingFunds())
 	if err != nil {
 		return nil, xerrors.Errorf("failed to construct empty vesting funds: %w", err)
@@ -232,6 +303,10 @@ func ConstructState(store adt.Store, infoCid cid.Cid, periodStart abi.ChainEpoch
 		Deadlines:                  emptyDeadlinesCid,
 		EarlyTerminations:          bitfield.New(),
 		DeadlineCronActive:         false,
+		ProvenPreCommits:           bitfield.New(),
+		ProvenPreCommitsEpoch:      -1,
+		EmptyDeadlines:             bitfield.NewFromSet(allDeadlines),
+		FaultStreakSectors:         bitfield.New(),
 	}, nil
 }
 
@@ -251,7 +326,7 @@ func ConstructMinerInfo(owner, worker addr.Address, controlAddrs []addr.Address,
 		Owner:                      owner,
 		Worker:                     worker,
 		ControlAddresses:           controlAddrs,
-		PendingWorkerKey:           nil,
+		PendingWorkerKeys:          nil,
 		PeerId:                     pid,
 		Multiaddrs:                 multiAddrs,
 		WindowPoStProofType:        windowPoStProofType,
@@ -259,6 +334,9 @@ func ConstructMinerInfo(owner, worker addr.Address, controlAddrs []addr.Address,
 		WindowPoStPartitionSectors: partitionSectors,
 		ConsensusFaultElapsed:      abi.ChainEpoch(-1),
 		PendingOwnerAddress:        nil,
+		Beneficiary:                owner,
+		BeneficiaryTerm:            BeneficiaryTerm{Quota: big.Zero(), UsedQuota: big.Zero(), Expiration: 0},
+		PendingBeneficiaryTerm:     nil,
 	}, nil
 }
 
@@ -443,7 +521,15 @@ func (st *State) DeletePrecommittedSectors(store adt.Store, sectorNos ...abi.Sec
 		}
 	}
 	st.PreCommittedSectors, err = precommitted.Root()
-	return err
+	if err != nil {
+		return err
+	}
+
+	st.ProvenPreCommits, err = bitfield.SubtractBitField(st.ProvenPreCommits, sectorNumbersBitfield(sectorNos))
+	if err != nil {
+		return xerrors.Errorf("failed to remove proven pre-commits: %w", err)
+	}
+	return nil
 }
 
 func (st *State) HasSectorNo(store adt.Store, sectorNo abi.SectorNumber) (bool, error) {
@@ -502,7 +588,16 @@ func (st *State) DeleteSectors(store adt.Store, sectorNos bitfield.BitField) err
 	}
 
 	st.Sectors, err = sectors.Root()
-	return err
+	if err != nil {
+		return err
+	}
+	if err = st.DeleteSectorManifests(store, sectorNos); err != nil {
+		return err
+	}
+	if err = st.DeleteReplicaUpdateRollbacks(store, sectorNos); err != nil {
+		return err
+	}
+	return st.endFaultStreaks(store, sectorNos)
 }
 
 // Iterates sectors.
@@ -701,6 +796,135 @@ func (st *State) CheckSectorActive(store adt.Store, dlIdx, pIdx uint64, sector a
 	return true, nil
 }
 
+// Returns whether any of the miner's deadlines has faulty power, i.e. whether some sector was not proven
+// at the most recent occurrence of its deadline. A sector detected faulty remains so at least until the
+// next occurrence of its deadline, so at the end of a proving period this reports any missed proof in it.
+func (st *State) HasFaultyPower(store adt.Store) (bool, error) {
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return false, err
+	}
+	faulty := false
+	err = deadlines.ForEach(store, func(_ uint64, dl *Deadline) error {
+		faulty = faulty || !dl.FaultyPower.IsZero()
+		return nil
+	})
+	if err != nil {
+		return false, xerrors.Errorf("failed to check deadlines for faulty power: %w", err)
+	}
+	return faulty, nil
+}
+
+// Hints to the cost of proving a partition, from which a prover may decide how to split a
+// deadline's partitions between Window PoSt messages.
+type PartitionProofCost struct {
+	// Number of sectors a partition holds under the miner's registered Window PoSt proof type.
+	PartitionSectors uint64
+	// Sectors in the partition that are not terminated, including faulty and unproven sectors.
+	LiveSectors uint64
+	// Estimated cost of proving the partition, out of PartitionProofCostIndexMax for a full partition.
+	CostIndex uint64
+}
+
+// Returns the proof cost hints for each partition of a deadline, in partition order.
+func (st *State) PartitionProofCosts(store adt.Store, dlIdx uint64) ([]PartitionProofCost, error) {
+	info, err := st.GetInfo(store)
+	if err != nil {
+		return nil, err
+	}
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return nil, err
+	}
+	dl, err := deadlines.LoadDeadline(store, dlIdx)
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := dl.PartitionsArray(store)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := make([]PartitionProofCost, 0, partitions.Length())
+	var partition Partition
+	err = partitions.ForEach(&partition, func(partIdx int64) error {
+		live, err := partition.LiveSectors()
+		if err != nil {
+			return xerrors.Errorf("failed to compute live sectors in deadline %d partition %d: %w", dlIdx, partIdx, err)
+		}
+		liveCount, err := live.Count()
+		if err != nil {
+			return xerrors.Errorf("failed to count live sectors in deadline %d partition %d: %w", dlIdx, partIdx, err)
+		}
+		costs = append(costs, PartitionProofCost{
+			PartitionSectors: info.WindowPoStPartitionSectors,
+			LiveSectors:      liveCount,
+			CostIndex:        PartitionProofCostIndex(liveCount, info.WindowPoStPartitionSectors),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return costs, nil
+}
+
+// Counts the terminated sectors recorded in the miner's partitions.
+// A sector terminated early remains pending until its termination fee has been processed, which may
+// take some epochs after termination. The remaining terminated sectors, including those which expired
+// on time, are settled. Settled sectors are dropped from the count when their partitions are compacted.
+func (st *State) CountTerminatedSectors(store adt.Store) (pending, settled uint64, err error) {
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = deadlines.ForEach(store, func(dlIdx uint64, dl *Deadline) error {
+		partitions, err := dl.PartitionsArray(store)
+		if err != nil {
+			return err
+		}
+		var partition Partition
+		return partitions.ForEach(&partition, func(partIdx int64) error {
+			terminated, err := partition.Terminated.Count()
+			if err != nil {
+				return xerrors.Errorf("failed to count terminated sectors in deadline %d partition %d: %w", dlIdx, partIdx, err)
+			}
+			pendingSectors, err := partition.PendingEarlyTerminations(store)
+			if err != nil {
+				return xerrors.Errorf("failed to load pending terminations in deadline %d partition %d: %w", dlIdx, partIdx, err)
+			}
+			partitionPending, err := pendingSectors.Count()
+			if err != nil {
+				return xerrors.Errorf("failed to count pending terminations in deadline %d partition %d: %w", dlIdx, partIdx, err)
+			}
+			pending += partitionPending
+			settled += terminated - partitionPending
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return pending, settled, nil
+}
+
+// Counts the live sectors assigned to each deadline, indexed by deadline.
+func (st *State) CountDeadlineLiveSectors(store adt.Store) ([]uint64, error) {
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]uint64, WPoStPeriodDeadlines)
+	err = deadlines.ForEach(store, func(dlIdx uint64, dl *Deadline) error {
+		counts[dlIdx] = dl.LiveSectors
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
 // Loads sector info for a sequence of sectors.
 func (st *State) LoadSectorInfos(store adt.Store, sectors bitfield.BitField) ([]*SectorOnChainInfo, error) {
 	sectorsArr, err := LoadSectors(store, st.Sectors)
@@ -719,12 +943,130 @@ func (st *State) LoadDeadlines(store adt.Store) (*Deadlines, error) {
 	return &deadlines, nil
 }
 
+// Writes the deadlines to state, unless no deadline has been updated since they were loaded.
+// Updated deadlines are no longer known to be empty.
 func (st *State) SaveDeadlines(store adt.Store, deadlines *Deadlines) error {
+	if !deadlines.modified {
+		return nil
+	}
 	c, err := store.Put(store.Context(), deadlines)
 	if err != nil {
 		return err
 	}
 	st.Deadlines = c
+	for dlIdx := uint64(0); dlIdx < WPoStPeriodDeadlines; dlIdx++ {
+		if deadlines.updated&(1<<dlIdx) != 0 {
+			st.EmptyDeadlines.Unset(dlIdx)
+		}
+	}
+	deadlines.modified = false
+	deadlines.updated = 0
+	return nil
+}
+
+// Loads the sub-structures of state used within a state transaction, writing back those
+// loaded with write permission and modified when the state is committed.
+type minerStateMutation struct {
+	st    *State
+	store adt.Store
+
+	sectorsPermit adt.MutationPermission
+	sectors       Sectors
+
+	deadlinesPermit adt.MutationPermission
+	deadlines       *Deadlines
+}
+
+func (st *State) mutator(store adt.Store) *minerStateMutation {
+	return &minerStateMutation{st: st, store: store}
+}
+
+func (m *minerStateMutation) build() (*minerStateMutation, error) {
+	if m.sectorsPermit != adt.PermitNone {
+		sectors, err := LoadSectors(m.store, m.st.Sectors)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load sectors: %w", err)
+		}
+		m.sectors = sectors
+	}
+
+	if m.deadlinesPermit != adt.PermitNone {
+		deadlines, err := m.st.LoadDeadlines(m.store)
+		if err != nil {
+			return nil, err
+		}
+		m.deadlines = deadlines
+	}
+
+	return m, nil
+}
+
+func (m *minerStateMutation) withSectors(permit adt.MutationPermission) *minerStateMutation {
+	m.sectorsPermit = permit
+	return m
+}
+
+func (m *minerStateMutation) withDeadlines(permit adt.MutationPermission) *minerStateMutation {
+	m.deadlinesPermit = permit
+	return m
+}
+
+func (m *minerStateMutation) commitState() error {
+	if err := adt.FlushIfModified(m.sectorsPermit, m.sectors, &m.st.Sectors); err != nil {
+		return xerrors.Errorf("failed to flush sectors: %w", err)
+	}
+
+	if m.deadlinesPermit == adt.PermitWrite {
+		if err := m.st.SaveDeadlines(m.store, m.deadlines); err != nil {
+			return xerrors.Errorf("failed to flush deadlines: %w", err)
+		}
+	}
+	return nil
+}
+
+// Loads the queued recovery declarations, which are empty if none are queued.
+func (st *State) LoadQueuedRecoveries(store adt.Store) (DeadlineSectorMap, error) {
+	queued := make(DeadlineSectorMap)
+	if st.QueuedRecoveries == nil {
+		return queued, nil
+	}
+	var recoveries QueuedRecoveries
+	if err := store.Get(store.Context(), *st.QueuedRecoveries, &recoveries); err != nil {
+		return nil, xerrors.Errorf("failed to load queued recoveries (%s): %w", *st.QueuedRecoveries, err)
+	}
+	for _, decl := range recoveries.Recoveries {
+		if err := queued.Add(decl.Deadline, decl.Partition, decl.Sectors); err != nil {
+			return nil, xerrors.Errorf("failed to add queued recovery at deadline %d, partition %d: %w", decl.Deadline, decl.Partition, err)
+		}
+	}
+	return queued, nil
+}
+
+// Saves the queued recovery declarations, clearing the queue if there are none.
+func (st *State) SaveQueuedRecoveries(store adt.Store, queued DeadlineSectorMap) error {
+	var recoveries QueuedRecoveries
+	err := queued.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+		return pm.ForEach(func(partIdx uint64, sectorNos bitfield.BitField) error {
+			recoveries.Recoveries = append(recoveries.Recoveries, RecoveryDeclaration{
+				Deadline:  dlIdx,
+				Partition: partIdx,
+				Sectors:   sectorNos,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to collect queued recoveries: %w", err)
+	}
+	if len(recoveries.Recoveries) == 0 {
+		st.QueuedRecoveries = nil
+		return nil
+	}
+	c, err := store.Put(store.Context(), &recoveries)
+	if err != nil {
+		return xerrors.Errorf("failed to store queued recoveries: %w", err)
+	}
+	st.QueuedRecoveries = &c
 	return nil
 }
 
@@ -755,6 +1097,44 @@ func (st *State) ContinueDeadlineCron() bool {
 		!st.LockedFunds.IsZero()
 }
 
+// Returns true if the miner has any sectors, whether pre-committed, committed, or live in a deadline.
+func (st *State) HasSectors(store adt.Store) (bool, error) {
+	precommitted, err := adt.AsMap(store, st.PreCommittedSectors, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return false, err
+	}
+	errFound := fmt.Errorf("found")
+	var info SectorPreCommitOnChainInfo
+	if err := precommitted.ForEach(&info, func(_ string) error {
+		return errFound
+	}); err == errFound {
+		return true, nil
+	} else if err != nil {
+		return false, xerrors.Errorf("failed to iterate pre-committed sectors: %w", err)
+	}
+
+	sectors, err := LoadSectors(store, st.Sectors)
+	if err != nil {
+		return false, xerrors.Errorf("failed to load sectors: %w", err)
+	}
+	if sectors.Length() > 0 {
+		return true, nil
+	}
+
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return false, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+	live := false
+	if err := deadlines.ForEach(store, func(_ uint64, dl *Deadline) error {
+		live = live || dl.LiveSectors > 0
+		return nil
+	}); err != nil {
+		return false, xerrors.Errorf("failed to iterate deadlines: %w", err)
+	}
+	return live, nil
+}
+
 //
 // Funds and vesting
 //
@@ -1022,71 +1402,125 @@ func (st *State) AddPreCommitCleanUps(store adt.Store, cleanUpEvents map[abi.Cha
 	return nil
 }
 
-func (st *State) CleanUpExpiredPreCommits(store adt.Store, currEpoch abi.ChainEpoch) (depositToBurn abi.TokenAmount, err error) {
+// Cleans up pre-commitments whose clean up delay after expiration has passed, returning the numbers
+// of the sectors cleaned up and the deposit to burn.
+func (st *State) CleanUpExpiredPreCommits(store adt.Store, currEpoch abi.ChainEpoch) (expired bitfield.BitField, depositToBurn abi.TokenAmount, err error) {
+	return st.cleanUpPreCommits(store, currEpoch, currEpoch)
+}
+
+// Cleans up all expired pre-commitments, forgoing the clean up delay during which they remain in state,
+// returning the numbers of the sectors cleaned up and the deposit to burn.
+func (st *State) CleanUpExpiredPreCommitsNow(store adt.Store, currEpoch abi.ChainEpoch) (expired bitfield.BitField, depositToBurn abi.TokenAmount, err error) {
+	// Clean up epochs are quantized up, so this includes some pre-commits yet to expire.
+	until := st.QuantSpecEveryDeadline().QuantizeUp(currEpoch + ExpiredPreCommitCleanUpDelay)
+	return st.cleanUpPreCommits(store, currEpoch, until)
+}
+
+// Cleans up the expired pre-commitments among those queued for clean up at or before an epoch, returning
+// the numbers of the sectors cleaned up and the deposit to burn. Queued pre-commitments that have not yet expired are re-queued.
+func (st *State) cleanUpPreCommits(store adt.Store, currEpoch, until abi.ChainEpoch) (expired bitfield.BitField, depositToBurn abi.TokenAmount, err error) {
+	expired = bitfield.New()
 	depositToBurn = abi.NewTokenAmount(0)
 
 	// cleanup expired pre-committed sectors
 	cleanUpQ, err := LoadBitfieldQueue(store, st.PreCommittedSectorsCleanUp, st.QuantSpecEveryDeadline(), PrecommitCleanUpAmtBitwidth)
 	if err != nil {
-		return depositToBurn, xerrors.Errorf("failed to load sector expiry queue: %w", err)
+		return expired, depositToBurn, xerrors.Errorf("failed to load sector expiry queue: %w", err)
 	}
 
-	sectors, modified, err := cleanUpQ.PopUntil(currEpoch)
+	sectors, modified, err := cleanUpQ.PopUntil(until)
 	if err != nil {
-		return depositToBurn, xerrors.Errorf("failed to pop expired sectors: %w", err)
-	}
-
-	if modified {
-		st.PreCommittedSectorsCleanUp, err = cleanUpQ.Root()
-		if err != nil {
-			return depositToBurn, xerrors.Errorf("failed to save pre commit clean up queue: %w", err)
-		}
+		return expired, depositToBurn, xerrors.Errorf("failed to pop expired sectors: %w", err)
 	}
 
 	var precommitsToDelete []abi.SectorNumber
+	var precommitsToDefer []uint64
+	precommitsNotExpired := map[abi.ChainEpoch][]uint64{}
 	if err = sectors.ForEach(func(i uint64) error {
 		sectorNo := abi.SectorNumber(i)
-		sector, found, err := st.GetPrecommittedSector(store, sectorNo)
+		stage, err := st.SectorActivationStage(store, sectorNo, currEpoch)
 		if err != nil {
 			return err
 		}
-		if !found {
+		switch stage {
+		case SectorActivationNone, SectorActivationActive:
 			// already committed/deleted
 			return nil
+		case SectorActivationProven:
+			// A proof accepted this epoch is yet to be confirmed, so defer clean up to a later epoch.
+			precommitsToDefer = append(precommitsToDefer, i)
+			return nil
+		}
+		if err := validateActivationTransition(sectorNo, stage, SectorActivationNone); err != nil {
+			return err
+		}
+		sector, _, err := st.GetPrecommittedSector(store, sectorNo)
+		if err != nil {
+			return err
+		}
+		// A pre-commit popped ahead of its clean up epoch may not have expired, in which case it's
+		// restored to the queue at that epoch.
+		if msd, ok := MaxProveCommitDuration[sector.Info.SealProof]; ok && until > currEpoch && currEpoch <= sector.PreCommitEpoch+msd {
+			cleanUpEpoch := sector.PreCommitEpoch + msd + ExpiredPreCommitCleanUpDelay
+			precommitsNotExpired[cleanUpEpoch] = append(precommitsNotExpired[cleanUpEpoch], i)
+			return nil
 		}
 
 		// mark it for deletion
 		precommitsToDelete = append(precommitsToDelete, sectorNo)
+		expired.Set(i)
 
 		// increment deposit to burn
 		depositToBurn = big.Add(depositToBurn, sector.PreCommitDeposit)
 		return nil
 	}); err != nil {
-		return big.Zero(), xerrors.Errorf("failed to check pre-commit expiries: %w", err)
+		return expired, big.Zero(), xerrors.Errorf("failed to check pre-commit expiries: %w", err)
+	}
+
+	if len(precommitsToDefer) > 0 {
+		if err := cleanUpQ.AddToQueueValues(currEpoch+1, precommitsToDefer...); err != nil {
+			return expired, big.Zero(), xerrors.Errorf("failed to defer pre-commit clean up: %w", err)
+		}
+		modified = true
+	}
+	if len(precommitsNotExpired) > 0 {
+		if err := cleanUpQ.AddManyToQueueValues(precommitsNotExpired); err != nil {
+			return expired, big.Zero(), xerrors.Errorf("failed to restore unexpired pre-commits to clean up queue: %w", err)
+		}
+		modified = true
+	}
+	if modified {
+		st.PreCommittedSectorsCleanUp, err = cleanUpQ.Root()
+		if err != nil {
+			return expired, depositToBurn, xerrors.Errorf("failed to save pre commit clean up queue: %w", err)
+		}
 	}
 
 	// Actually delete it.
 	if len(precommitsToDelete) > 0 {
 		if err := st.DeletePrecommittedSectors(store, precommitsToDelete...); err != nil {
-			return big.Zero(), fmt.Errorf("failed to delete pre-commits: %w", err)
+			return expired, big.Zero(), fmt.Errorf("failed to delete pre-commits: %w", err)
 		}
 	}
 
 	st.PreCommitDeposits = big.Sub(st.PreCommitDeposits, depositToBurn)
 	if st.PreCommitDeposits.LessThan(big.Zero()) {
-		return big.Zero(), xerrors.Errorf("pre-commit clean up caused negative deposits: %v", st.PreCommitDeposits)
+		return expired, big.Zero(), xerrors.Errorf("pre-commit clean up caused negative deposits: %v", st.PreCommitDeposits)
 	}
 
 	// This deposit was locked separately to pledge collateral so there's no pledge change here.
-	return depositToBurn, nil
+	return expired, depositToBurn, nil
 }
 
 type AdvanceDeadlineResult struct {
 	PledgeDelta           abi.TokenAmount
 	PowerDelta            PowerPair
-	PreviouslyFaultyPower PowerPair // Power that was faulty before this advance (including recovering)
-	DetectedFaultyPower   PowerPair // Power of new faults and failed recoveries
-	TotalFaultyPower      PowerPair // Total faulty power after detecting faults (before expiring sectors)
+	PreviouslyFaultyPower PowerPair         // Power that was faulty before this advance (including recovering)
+	DetectedFaultyPower   PowerPair         // Power of new faults and failed recoveries
+	TotalFaultyPower      PowerPair         // Total faulty power after detecting faults (before expiring sectors)
+	FaultyPowerDelta      PowerPair         // Change in faulty power, including that of faulty sectors expiring
+	Deadline              *Deadline         // The deadline after processing, or nil if it had no live sectors to process
+	OnTimeExpired         bitfield.BitField // Sectors that expired on time
 	// Note that failed recovery power is included in both PreviouslyFaultyPower and DetectedFaultyPower,
 	// so TotalFaultyPower is not simply their sum.
 }
@@ -1124,6 +1558,9 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 			NewPowerPairZero(),
 			NewPowerPairZero(),
 			NewPowerPairZero(),
+			NewPowerPairZero(),
+			nil,
+			bitfield.New(),
 		}, nil
 	}
 
@@ -1134,6 +1571,22 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 		st.ProvingPeriodStart = dlInfo.PeriodStart + WPoStProvingPeriod
 	}
 
+	// An empty deadline has nothing to process, so isn't loaded.
+	if empty, err := st.EmptyDeadlines.IsSet(dlInfo.Index); err != nil {
+		return nil, xerrors.Errorf("failed to check for empty deadline %d: %w", dlInfo.Index, err)
+	} else if empty {
+		return &AdvanceDeadlineResult{
+			pledgeDelta,
+			powerDelta,
+			NewPowerPairZero(),
+			detectedFaultyPower,
+			NewPowerPairZero(),
+			NewPowerPairZero(),
+			nil,
+			bitfield.New(),
+		}, nil
+	}
+
 	deadlines, err := st.LoadDeadlines(store)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to load deadlines: %w", err)
@@ -1149,12 +1602,16 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 	if live, err := deadline.IsLive(); err != nil {
 		return nil, xerrors.Errorf("failed to determine if miner is live: %w", err)
 	} else if !live {
+		st.EmptyDeadlines.Set(dlInfo.Index)
 		return &AdvanceDeadlineResult{
 			pledgeDelta,
 			powerDelta,
 			previouslyFaultyPower,
 			detectedFaultyPower,
 			deadline.FaultyPower,
+			NewPowerPairZero(),
+			nil,
+			bitfield.New(),
 		}, nil
 	}
 
@@ -1172,6 +1629,7 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 		// dropped along with faulty sectors expiring this round.
 		totalFaultyPower = deadline.FaultyPower
 	}
+	onTimeExpired := bitfield.New()
 	{
 		// Expire sectors that are due, either for on-time expiration or "early" faulty-for-too-long.
 		expired, err := deadline.PopExpiredSectors(store, dlInfo.Last(), quant)
@@ -1187,6 +1645,8 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 			return nil, xerrors.Errorf("failed to reduce %v initial pledge for expiring sectors: %w", expired.OnTimePledge, err)
 		}
 
+		onTimeExpired = expired.OnTimeSectors
+
 		// Record reduction in power of the amount of expiring active power.
 		// Faulty power has already been lost, so the amount expiring can be excluded from the delta.
 		powerDelta = powerDelta.Sub(expired.ActivePower)
@@ -1203,6 +1663,10 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 		}
 	}
 
+	if err = st.updateFaultStreaks(store, deadline, dlInfo.Last()); err != nil {
+		return nil, xerrors.Errorf("failed to update fault streaks for deadline %d: %w", dlInfo.Index, err)
+	}
+
 	// Save new deadline state.
 	err = deadlines.UpdateDeadline(store, dlInfo.Index, deadline)
 	if err != nil {
@@ -1214,6 +1678,13 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 		return nil, xerrors.Errorf("failed to save deadlines: %w", err)
 	}
 
+	// A deadline left with nothing to process is skipped until it's next updated.
+	if live, err := deadline.IsLive(); err != nil {
+		return nil, xerrors.Errorf("failed to determine if deadline %d is live: %w", dlInfo.Index, err)
+	} else if !live {
+		st.EmptyDeadlines.Set(dlInfo.Index)
+	}
+
 	// Compute penalties all together.
 	// Be very careful when changing these as any changes can affect rounding.
 	return &AdvanceDeadlineResult{
@@ -1222,6 +1693,9 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 		PreviouslyFaultyPower: previouslyFaultyPower,
 		DetectedFaultyPower:   detectedFaultyPower,
 		TotalFaultyPower:      totalFaultyPower,
+		FaultyPowerDelta:      deadline.FaultyPower.Sub(previouslyFaultyPower),
+		Deadline:              deadline,
+		OnTimeExpired:         onTimeExpired,
 	}, nil
 }
 
@@ -661,6 +661,79 @@ func TestAddPreCommitExpiry(t *testing.T) {
 	})
 }
 
+func TestSectorActivationStages(t *testing.T) {
+	setup := func(t *testing.T) *stateHarness {
+		harness := constructStateHarness(t, abi.ChainEpoch(0))
+		pc1 := newPreCommitOnChain(1, tutils.MakeCID("1", &miner.SealedCIDPrefix), abi.NewTokenAmount(1), 1)
+		pc2 := newPreCommitOnChain(2, tutils.MakeCID("2", &miner.SealedCIDPrefix), abi.NewTokenAmount(1), 1)
+		require.NoError(t, harness.s.PutPrecommittedSectors(harness.store, pc1, pc2))
+		require.NoError(t, harness.s.AddPreCommitDeposit(abi.NewTokenAmount(2)))
+		require.NoError(t, harness.s.AddPreCommitCleanUps(harness.store, map[abi.ChainEpoch][]uint64{100: {1, 2}}))
+		return harness
+	}
+	stage := func(h *stateHarness, sectorNo abi.SectorNumber, epoch abi.ChainEpoch) miner.SectorActivationStage {
+		stage, err := h.s.SectorActivationStage(h.store, sectorNo, epoch)
+		require.NoError(h.t, err)
+		return stage
+	}
+
+	t.Run("proof is awaiting confirmation only in the epoch it is recorded", func(t *testing.T) {
+		harness := setup(t)
+		assert.Equal(t, miner.SectorActivationNone, stage(harness, 3, 10))
+		assert.Equal(t, miner.SectorActivationPreCommitted, stage(harness, 1, 10))
+
+		require.NoError(t, harness.s.RecordPreCommitsProven(harness.store, 10, 1))
+		require.NoError(t, harness.s.RecordPreCommitsProven(harness.store, 10, 1))
+		assert.Equal(t, miner.SectorActivationProven, stage(harness, 1, 10))
+		assert.Equal(t, miner.SectorActivationPreCommitted, stage(harness, 2, 10))
+		assert.Equal(t, miner.SectorActivationPreCommitted, stage(harness, 1, 11))
+
+		// Recording a proof in a later epoch discards the stale one.
+		require.NoError(t, harness.s.RecordPreCommitsProven(harness.store, 11, 2))
+		assert.Equal(t, miner.SectorActivationPreCommitted, stage(harness, 1, 11))
+		assert.Equal(t, miner.SectorActivationProven, stage(harness, 2, 11))
+	})
+
+	t.Run("activation removes pre-commitment", func(t *testing.T) {
+		harness := setup(t)
+		require.NoError(t, harness.s.RecordPreCommitsProven(harness.store, 10, 1))
+		require.NoError(t, harness.s.ActivatePreCommittedSectors(harness.store, 10, 1, 2))
+		assert.False(t, harness.hasPreCommit(1))
+		assert.False(t, harness.hasPreCommit(2))
+		empty, err := harness.s.ProvenPreCommits.IsEmpty()
+		require.NoError(t, err)
+		assert.True(t, empty)
+
+		// A sector that is not pre-committed can be neither proven nor activated.
+		require.Error(t, harness.s.RecordPreCommitsProven(harness.store, 10, 1))
+		require.Error(t, harness.s.ActivatePreCommittedSectors(harness.store, 10, 3))
+	})
+
+	t.Run("clean up is deferred for a proof awaiting confirmation", func(t *testing.T) {
+		harness := setup(t)
+		quant := harness.s.QuantSpecEveryDeadline()
+		cleanUpEpoch := quant.QuantizeUp(100)
+		require.NoError(t, harness.s.RecordPreCommitsProven(harness.store, cleanUpEpoch, 1))
+
+		expired, burnt, err := harness.s.CleanUpExpiredPreCommits(harness.store, cleanUpEpoch)
+		require.NoError(t, err)
+		assert.Equal(t, abi.NewTokenAmount(1), burnt)
+		assertBitfieldEquals(t, expired, 2)
+		assert.True(t, harness.hasPreCommit(1))
+		assert.False(t, harness.hasPreCommit(2))
+		ExpectBQ().
+			Add(quant.QuantizeUp(cleanUpEpoch+1), 1).
+			Equals(t, harness.loadPreCommitCleanUps())
+
+		// Unconfirmed, the pre-commitment is cleaned up later.
+		_, burnt, err = harness.s.CleanUpExpiredPreCommits(harness.store, quant.QuantizeUp(cleanUpEpoch+1))
+		require.NoError(t, err)
+		assert.Equal(t, abi.NewTokenAmount(1), burnt)
+		assert.False(t, harness.hasPreCommit(1))
+		assert.True(t, harness.s.PreCommitDeposits.IsZero())
+	})
+}
+
 func TestSectorAssignment(t *testing.T) {
 	partitionSectors, err := builtin.SealProofWindowPoStPartitionSectors(abi.RegisteredSealProof_StackedDrg32GiBV1_1)
 	require.NoError(t, err)
@@ -745,6 +818,44 @@ func TestSectorAssignment(t *testing.T) {
 	})
 }
 
+func TestPartitionProofCosts(t *testing.T) {
+	harness := constructStateHarness(t, abi.ChainEpoch(0))
+	info, err := harness.s.GetInfo(harness.store)
+	require.NoError(t, err)
+	sectorSize := info.SectorSize
+	partitionSectors := info.WindowPoStPartitionSectors
+
+	// Fill one partition and half of another.
+	sectorInfos := make([]*miner.SectorOnChainInfo, partitionSectors+partitionSectors/2)
+	for i := range sectorInfos {
+		sectorInfos[i] = newSectorOnChainInfo(
+			abi.SectorNumber(i), tutils.MakeCID(fmt.Sprintf("%d", i), &miner.SealedCIDPrefix), big.NewInt(1), abi.ChainEpoch(0),
+		)
+	}
+	require.NoError(t, harness.s.AssignSectorsToDeadlines(harness.store, 0, sectorInfos, partitionSectors, sectorSize))
+
+	dls, err := harness.s.LoadDeadlines(harness.store)
+	require.NoError(t, err)
+	var indexes []uint64
+	require.NoError(t, dls.ForEach(harness.store, func(dlIdx uint64, dl *miner.Deadline) error {
+		costs, err := harness.s.PartitionProofCosts(harness.store, dlIdx)
+		require.NoError(t, err)
+
+		partitions, err := dl.PartitionsArray(harness.store)
+		require.NoError(t, err)
+		require.Equal(t, partitions.Length(), uint64(len(costs)))
+		for _, cost := range costs {
+			assert.Equal(t, partitionSectors, cost.PartitionSectors)
+			indexes = append(indexes, cost.CostIndex)
+		}
+		return nil
+	}))
+	assert.ElementsMatch(t, []uint64{miner.PartitionProofCostIndexMax, miner.PartitionProofCostIndexMax / 2}, indexes)
+
+	_, err = harness.s.PartitionProofCosts(harness.store, miner.WPoStPeriodDeadlines)
+	assert.Error(t, err)
+}
+
 func TestSectorNumberAllocation(t *testing.T) {
 	allocate := func(h *stateHarness, numbers ...uint64) error {
 		return h.s.AllocateSectorNumbers(h.store, bitfield.NewFromSet(numbers), miner.DenyCollisions)
@@ -979,12 +1090,14 @@ func constructStateHarness(t *testing.T, periodBoundary abi.ChainEpoch) *stateHa
 	info := miner.MinerInfo{
 		Owner:                      owner,
 		Worker:                     worker,
-		PendingWorkerKey:           nil,
+		PendingWorkerKeys:          nil,
 		PeerId:                     abi.PeerID("peer"),
 		Multiaddrs:                 testMultiaddrs,
 		WindowPoStProofType:        testWindowPoStProofType,
 		SectorSize:                 sectorSize,
 		WindowPoStPartitionSectors: partitionSectors,
+		Beneficiary:                owner,
+		BeneficiaryTerm:            miner.BeneficiaryTerm{Quota: big.Zero(), UsedQuota: big.Zero()},
 	}
 	infoCid, err := store.Put(context.Background(), &info)
 	require.NoError(t, err)
@@ -11,6 +11,7 @@ import (
 	bitfield "github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/cbor"
 	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/go-state-types/dline"
 	"github.com/filecoin-project/go-state-types/exitcode"
@@ -26,6 +27,7 @@ import (
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/power"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/reward"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/verifreg"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime/proof"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
@@ -432,6 +434,7 @@ func TestWindowPost(t *testing.T) {
 		require.NoError(t, err)
 		require.True(t, found)
 		assertBitfieldEquals(t, post.Partitions, pIdx)
+		assert.Equal(t, dlinfo.Challenge, post.ChainCommitEpoch)
 
 		// Advance to end-of-deadline cron to verify no penalties.
 		advanceDeadline(rt, actor, &cronConfig{})
@@ -457,6 +460,135 @@ func TestWindowPost(t *testing.T) {
 		actor.disputeWindowPoSt(rt, dlinfo, 0, []*miner.SectorOnChainInfo{sector}, result)
 	})
 
+	t.Run("records chain commitment drawn before the challenge epoch", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
+		rt := builderForHarness(actor).
+			WithEpoch(precommitEpoch).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+		store := rt.AdtStore()
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)[0]
+		pwr := miner.PowerForSector(actor.sectorSize, sector)
+
+		dlIdx, pIdx, err := getState(rt).FindSector(store, sector.SectorNumber)
+		require.NoError(t, err)
+		dlinfo := advanceToDeadline(rt, actor, dlIdx)
+
+		// A chain commitment drawn more than WPoStChainCommitLookback before the challenge is rejected.
+		chainCommitEpoch := dlinfo.Challenge - miner.WPoStChainCommitLookback
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "expected chain commit epoch", func() {
+			params := miner.SubmitWindowedPoStParams{
+				Deadline:         dlinfo.Index,
+				Partitions:       []miner.PoStPartition{{Index: pIdx, Skipped: bitfield.New()}},
+				Proofs:           makePoStProofs(actor.windowPostProofType),
+				ChainCommitEpoch: chainCommitEpoch - 1,
+				ChainCommitRand:  abi.Randomness("chaincommitment"),
+			}
+			actor.submitWindowPoStRaw(rt, dlinfo, []*miner.SectorOnChainInfo{sector}, &params, nil)
+		})
+		rt.Reset()
+
+		// One drawn exactly WPoStChainCommitLookback before the challenge is accepted.
+		params := miner.SubmitWindowedPoStParams{
+			Deadline:         dlinfo.Index,
+			Partitions:       []miner.PoStPartition{{Index: pIdx, Skipped: bitfield.New()}},
+			Proofs:           makePoStProofs(actor.windowPostProofType),
+			ChainCommitEpoch: chainCommitEpoch,
+			ChainCommitRand:  abi.Randomness("chaincommitment"),
+		}
+		actor.submitWindowPoStRaw(rt, dlinfo, []*miner.SectorOnChainInfo{sector}, &params, &poStConfig{
+			expectedPowerDelta: pwr,
+		})
+
+		deadline := actor.getDeadline(rt, dlIdx)
+		posts, err := adt.AsArray(store, deadline.OptimisticPoStSubmissions, miner.DeadlineOptimisticPoStSubmissionsAmtBitwidth)
+		require.NoError(t, err)
+		var post miner.WindowedPoSt
+		found, err := posts.Get(0, &post)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, chainCommitEpoch, post.ChainCommitEpoch)
+
+		// The proof remains valid, so cannot be disputed.
+		advanceDeadline(rt, actor, &cronConfig{})
+		actor.disputeWindowPoSt(rt, dlinfo, 0, []*miner.SectorOnChainInfo{sector}, nil)
+		actor.checkState(rt)
+	})
+
+	t.Run("relayed PoSt with worker-signed intent", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
+		rt := builderForHarness(actor).
+			WithEpoch(precommitEpoch).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+		store := rt.AdtStore()
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)[0]
+		pwr := miner.PowerForSector(actor.sectorSize, sector)
+
+		dlIdx, pIdx, err := getState(rt).FindSector(store, sector.SectorNumber)
+		require.NoError(t, err)
+		dlinfo := advanceToDeadline(rt, actor, dlIdx)
+
+		relayer := tutil.NewIDAddr(t, 5000)
+		sig := crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("intent")}
+		params := &miner.SubmitWindowedPoStRelayedParams{
+			PoSt: miner.SubmitWindowedPoStParams{
+				Deadline:         dlinfo.Index,
+				Partitions:       []miner.PoStPartition{{Index: pIdx, Skipped: bitfield.New()}},
+				Proofs:           makePoStProofs(actor.windowPostProofType),
+				ChainCommitEpoch: dlinfo.Challenge,
+				ChainCommitRand:  abi.Randomness("chaincommitment"),
+			},
+			Signature: sig,
+		}
+
+		// A nonce other than the miner's next relay nonce is rejected.
+		params.Nonce = 1
+		rt.SetCaller(relayer, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid relay nonce 1, expected 0", func() {
+			rt.Call(actor.a.SubmitWindowedPoStRelayed, params)
+		})
+		rt.Reset()
+
+		// An intent not signed by the worker is rejected.
+		params.Nonce = 0
+		rt.ExpectValidateCallerAny()
+		rt.ExpectVerifySignature(sig, actor.worker, relayedPoStIntent(t, rt, params), fmt.Errorf("bad signature"))
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid PoSt intent signature", func() {
+			rt.Call(actor.a.SubmitWindowedPoStRelayed, params)
+		})
+		rt.Reset()
+
+		// A signed intent is accepted from any caller.
+		rt.ExpectValidateCallerAny()
+		rt.ExpectVerifySignature(sig, actor.worker, relayedPoStIntent(t, rt, params), nil)
+		rt.ExpectGetRandomnessTickets(crypto.DomainSeparationTag_PoStChainCommit, params.PoSt.ChainCommitEpoch, nil, params.PoSt.ChainCommitRand)
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedPower, &power.UpdateClaimedPowerParams{
+			RawByteDelta:         pwr.Raw,
+			QualityAdjustedDelta: pwr.QA,
+		}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		ret := rt.Call(actor.a.SubmitWindowedPoStRelayed, params).(*miner.SubmitWindowedPoStReturn)
+		rt.Verify()
+		assert.True(t, pwr.Equals(ret.PowerDelta))
+		assert.Equal(t, uint64(1), getState(rt).PoStRelayNonce)
+
+		deadline := actor.getDeadline(rt, dlIdx)
+		assertBitfieldEquals(t, deadline.PartitionsPoSted, pIdx)
+
+		// The same intent cannot be replayed.
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid relay nonce 0, expected 1", func() {
+			rt.Call(actor.a.SubmitWindowedPoStRelayed, params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
 	t.Run("invalid submissions", func(t *testing.T) {
 		actor := newHarness(t, periodOffset)
 		rt := builderForHarness(actor).
@@ -614,7 +746,7 @@ func TestWindowPost(t *testing.T) {
 		rt.Reset()
 
 		// Deadline not open.
-		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid deadline 2 at epoch", func() {
+		rt.ExpectAbortContainsMessage(miner.ErrWrongDeadline, "invalid deadline 2 at epoch", func() {
 			rt.SetEpoch(rt.Epoch() + miner.WPoStChallengeWindow)
 			params := miner.SubmitWindowedPoStParams{
 				Deadline:         dlInfo.Index,
@@ -634,7 +766,7 @@ func TestWindowPost(t *testing.T) {
 				Deadline:         dlInfo.Index,
 				Partitions:       []miner.PoStPartition{{Index: pIdx, Skipped: bf()}},
 				Proofs:           makePoStProofs(actor.windowPostProofType),
-				ChainCommitEpoch: dlInfo.Challenge - 1,
+				ChainCommitEpoch: dlInfo.Challenge - miner.WPoStChainCommitLookback - 1,
 				ChainCommitRand:  abi.Randomness("chaincommitment"),
 			}
 			actor.submitWindowPoStRaw(rt, dlInfo, []*miner.SectorOnChainInfo{sector}, &params, nil)
@@ -695,7 +827,7 @@ func TestWindowPost(t *testing.T) {
 		rt.Verify()
 	})
 
-	t.Run("test duplicate proof rejected", func(t *testing.T) {
+	t.Run("test duplicate proof accepted without effect", func(t *testing.T) {
 		actor := newHarness(t, periodOffset)
 		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
 		rt := builderForHarness(actor).
@@ -725,9 +857,9 @@ func TestWindowPost(t *testing.T) {
 		deadline := actor.getDeadline(rt, dlIdx)
 		assertBitfieldEquals(t, deadline.PartitionsPoSted, pIdx)
 
-		// Submit a duplicate proof for the same partition. This will be rejected because after ignoring the
-		// already-proven partition, there are no sectors remaining.
-		// The skipped fault declared here has no effect.
+		// Submit a duplicate proof for the same partition. This succeeds without effect, since the partition
+		// has already been proven.
+		// The skipped fault declared here is ignored.
 		commitRand := abi.Randomness("chaincommitment")
 		params := miner.SubmitWindowedPoStParams{
 			Deadline: dlIdx,
@@ -739,23 +871,26 @@ func TestWindowPost(t *testing.T) {
 			ChainCommitEpoch: dlinfo.Challenge,
 			ChainCommitRand:  commitRand,
 		}
-		expectQueryNetworkInfo(rt, actor)
 		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
-
-		// From version 7, a duplicate is explicitly rejected.
 		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
 		rt.ExpectGetRandomnessTickets(crypto.DomainSeparationTag_PoStChainCommit, dlinfo.Challenge, nil, commitRand)
-		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "partition already proven", func() {
-			rt.Call(actor.a.SubmitWindowedPoSt, &params)
-		})
-		rt.Reset()
+		ret := rt.Call(actor.a.SubmitWindowedPoSt, &params).(*miner.SubmitWindowedPoStReturn)
+		rt.Verify()
+		assert.True(t, ret.AlreadyProven)
+		assert.Equal(t, uint64(0), ret.NewFaultySectors)
+		assert.True(t, ret.PowerDelta.IsZero())
+
+		deadline = actor.getDeadline(rt, dlIdx)
+		assertBitfieldEquals(t, deadline.PartitionsPoSted, pIdx)
+		_, partition := actor.findSector(rt, sector.SectorNumber)
+		assertBitfieldEmpty(t, partition.Faults)
 
 		// Advance to end-of-deadline cron to verify no penalties.
 		advanceDeadline(rt, actor, &cronConfig{})
 		actor.checkState(rt)
 	})
 
-	t.Run("test duplicate proof rejected with many partitions", func(t *testing.T) {
+	t.Run("test partially duplicate proof rejected with many partitions", func(t *testing.T) {
 		actor := newHarness(t, periodOffset)
 		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
 		rt := builderForHarness(actor).
@@ -800,8 +935,8 @@ func TestWindowPost(t *testing.T) {
 			sectorsToProve := append(sectors[:actor.partitionSize], lastSector)
 			pwr := miner.PowerForSectors(actor.sectorSize, sectorsToProve)
 
-			// From network version 7, the miner outright rejects attempts to prove a partition twice.
-			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "partition already proven", func() {
+			// A submission proving only some partitions a second time is rejected.
+			rt.ExpectAbortContainsMessage(miner.ErrPartitionAlreadyProven, "partition already proven", func() {
 				actor.submitWindowPoSt(rt, dlinfo, partitions, sectorsToProve, &poStConfig{
 					expectedPowerDelta: pwr,
 				})
@@ -868,12 +1003,16 @@ func TestWindowPost(t *testing.T) {
 		// Now submit PoSt
 		// Power should return for recovered sector.
 		cfg := &poStConfig{
-			expectedPowerDelta: miner.NewPowerPair(pwr.Raw, pwr.QA),
+			expectedPowerDelta:       miner.NewPowerPair(pwr.Raw, pwr.QA),
+			expectedFaultyPowerDelta: pwr.Neg(),
 		}
 		partitions := []miner.PoStPartition{
 			{Index: pIdx, Skipped: bitfield.New()},
 		}
-		actor.submitWindowPoSt(rt, dlinfo, partitions, infos, cfg)
+		ret := actor.submitWindowPoSt(rt, dlinfo, partitions, infos, cfg)
+		assert.Equal(t, uint64(1), ret.RecoveredSectors)
+		assert.Equal(t, uint64(0), ret.NewFaultySectors)
+		assert.Equal(t, uint64(0), ret.SkippedSectors)
 
 		// faulty power has been removed, partition no longer has faults or recoveries
 		deadline, partition := actor.findSector(rt, infos[0].SectorNumber)
@@ -923,12 +1062,16 @@ func TestWindowPost(t *testing.T) {
 		// First sector's power should not be activated.
 		powerActive := miner.PowerForSectors(actor.sectorSize, infos[1:])
 		cfg := &poStConfig{
-			expectedPowerDelta: powerActive,
+			expectedPowerDelta:       powerActive,
+			expectedFaultyPowerDelta: miner.PowerForSectors(actor.sectorSize, infos[:1]),
 		}
 		partitions := []miner.PoStPartition{
 			{Index: pIdx, Skipped: bf(uint64(infos[0].SectorNumber))},
 		}
-		actor.submitWindowPoSt(rt, dlinfo, partitions, infos, cfg)
+		ret := actor.submitWindowPoSt(rt, dlinfo, partitions, infos, cfg)
+		assert.Equal(t, uint64(1), ret.NewFaultySectors)
+		assert.Equal(t, uint64(1), ret.SkippedSectors)
+		assert.Equal(t, uint64(0), ret.RecoveredSectors)
 
 		// expect continued fault fee to be charged during cron
 		faultFee := actor.continuedFaultPenalty(infos[:1])
@@ -997,7 +1140,9 @@ func TestWindowPost(t *testing.T) {
 		rt.Reset()
 
 		// These sectors are detected faulty and pay no penalty this time.
-		advanceDeadline(rt, actor, &cronConfig{continuedFaultsPenalty: big.Zero()})
+		// They were never proven, so add to faulty power without any loss of power.
+		faultyPower := miner.PowerForSectors(actor.sectorSize, infos)
+		advanceDeadline(rt, actor, &cronConfig{continuedFaultsPenalty: big.Zero(), faultyPowerDelta: &faultyPower})
 		actor.checkState(rt)
 	})
 
@@ -1041,7 +1186,11 @@ func TestWindowPost(t *testing.T) {
 		partitions := []miner.PoStPartition{
 			{Index: pIdx, Skipped: bf(uint64(infos[0].SectorNumber))},
 		}
-		actor.submitWindowPoSt(rt, dlinfo, partitions, infos, cfg)
+		ret := actor.submitWindowPoSt(rt, dlinfo, partitions, infos, cfg)
+		// The skipped sector was already faulty, so it is neither newly faulty nor recovered.
+		assert.Equal(t, uint64(1), ret.SkippedSectors)
+		assert.Equal(t, uint64(0), ret.NewFaultySectors)
+		assert.Equal(t, uint64(0), ret.RecoveredSectors)
 
 		// sector will be charged ongoing fee at proving period cron
 		ongoingFee := actor.continuedFaultPenalty(infos[:1])
@@ -1276,6 +1425,101 @@ func TestWindowPost(t *testing.T) {
 	})
 }
 
+func TestSubmitWindowedPoStAggregate(t *testing.T) {
+	miner.WindowPoStProofTypes[abi.RegisteredPoStProof_StackedDrgWindow2KiBV1] = struct{}{}
+	defer func() {
+		delete(miner.WindowPoStProofTypes, abi.RegisteredPoStProof_StackedDrgWindow2KiBV1)
+	}()
+
+	periodOffset := abi.ChainEpoch(100)
+	precommitEpoch := abi.ChainEpoch(1)
+
+	// Sets up a miner with two partitions at deadline 2, returning the sectors in each.
+	setup := func(t *testing.T) (*mock.Runtime, *actorHarness, *dline.Info, []*miner.SectorOnChainInfo) {
+		actor := newHarness(t, periodOffset)
+		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
+		rt := builderForHarness(actor).
+			WithEpoch(precommitEpoch).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+		// Overflow the sectors in every eligible deadline into a second partition at deadline 2.
+		sectorsToCommit := ((miner.WPoStPeriodDeadlines - 2) * actor.partitionSize) + 1
+		sectors := actor.commitAndProveSectors(rt, int(sectorsToCommit), defaultSectorExpiration, nil, true)
+		lastSector := sectors[len(sectors)-1]
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), lastSector.SectorNumber)
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), dlIdx)
+		require.Equal(t, uint64(1), pIdx)
+		dlinfo := advanceToDeadline(rt, actor, dlIdx)
+		return rt, actor, dlinfo, append(sectors[:actor.partitionSize], lastSector)
+	}
+	partitions := []miner.PoStPartition{
+		{Index: 0, Skipped: bitfield.New()},
+		{Index: 1, Skipped: bitfield.New()},
+	}
+
+	t.Run("proves many partitions with one proof", func(t *testing.T) {
+		rt, actor, dlinfo, sectors := setup(t)
+		pwr := miner.PowerForSectors(actor.sectorSize, sectors)
+		ret := actor.submitWindowPoStAggregate(rt, dlinfo, partitions, sectors, &poStConfig{
+			expectedPowerDelta: pwr,
+		})
+		assert.True(t, pwr.Equals(ret.PowerDelta))
+
+		// Both partitions are proven, and the aggregate isn't open to dispute.
+		deadline := actor.getDeadline(rt, dlinfo.Index)
+		assertBitfieldEquals(t, deadline.PartitionsPoSted, 0, 1)
+		submissions, err := deadline.OptimisticProofsArray(rt.AdtStore())
+		require.NoError(t, err)
+		assert.Zero(t, submissions.Length())
+
+		// The aggregate may be resubmitted without effect.
+		ret = actor.submitWindowPoStAggregate(rt, dlinfo, partitions, sectors, &poStConfig{alreadyProven: true})
+		assert.True(t, ret.AlreadyProven)
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects invalid aggregate", func(t *testing.T) {
+		rt, actor, dlinfo, sectors := setup(t)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "aggregate window post failed", func() {
+			actor.submitWindowPoStAggregate(rt, dlinfo, partitions, sectors, &poStConfig{
+				verificationError: fmt.Errorf("invalid aggregate"),
+			})
+		})
+		rt.Reset()
+		assertBitfieldEmpty(t, actor.getDeadline(rt, dlinfo.Index).PartitionsPoSted)
+	})
+
+	t.Run("rejects too many partitions or oversized proof", func(t *testing.T) {
+		rt, actor, dlinfo, _ := setup(t)
+		params := miner.SubmitWindowedPoStAggregateParams{
+			Deadline:         dlinfo.Index,
+			Partitions:       make([]miner.PoStPartition, miner.MaxAggregatedWindowPoStPartitions+1),
+			AggregateProof:   makePoStProofs(actor.windowPostProofType)[0],
+			ChainCommitEpoch: dlinfo.Challenge,
+			ChainCommitRand:  abi.Randomness("chaincommitment"),
+		}
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too many partitions", func() {
+			rt.Call(actor.a.SubmitWindowedPoStAggregate, &params)
+		})
+		rt.Reset()
+
+		params.Partitions = partitions
+		params.AggregateProof.ProofBytes = make([]byte, miner.MaxAggregateWindowPoStProofSize+1)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "expected proof to be smaller", func() {
+			rt.Call(actor.a.SubmitWindowedPoStAggregate, &params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
 func TestDeadlineCron(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
@@ -1337,6 +1581,51 @@ func TestDeadlineCron(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("deadline is recorded empty until assigned sectors, and again once they expire", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		isEmpty := func(dlIdx uint64) bool {
+			empty, err := getState(rt).EmptyDeadlines.IsSet(dlIdx)
+			require.NoError(t, err)
+			return empty
+		}
+		for dlIdx := uint64(0); dlIdx < miner.WPoStPeriodDeadlines; dlIdx++ {
+			assert.True(t, isEmpty(dlIdx))
+		}
+
+		sectors := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+		activePower := miner.PowerForSectors(actor.sectorSize, sectors)
+
+		st := getState(rt)
+		initialPledge := st.InitialPledge
+		dlIdx, _, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+		assert.False(t, isEmpty(dlIdx))
+		assert.True(t, isEmpty((dlIdx+1)%miner.WPoStPeriodDeadlines))
+
+		// Skip forward to the sector's expiration.
+		expiration := st.QuantSpecForDeadline(dlIdx).QuantizeUp(sectors[0].Expiration)
+		remainingPeriods := (expiration-st.ProvingPeriodStart)/miner.WPoStProvingPeriod + 1
+		st.ProvingPeriodStart += remainingPeriods * miner.WPoStProvingPeriod
+		st.CurrentDeadline = dlIdx
+		rt.ReplaceState(st)
+
+		rt.SetEpoch(expiration)
+		powerDelta := activePower.Neg()
+		advanceDeadline(rt, actor, &cronConfig{
+			noEnrollment:              true,
+			expiredSectorsPowerDelta:  &powerDelta,
+			expiredSectorsPledgeDelta: initialPledge.Neg(),
+		})
+		// The expired sector's partition is snapshot at the deadline's next challenge window end.
+		assert.False(t, isEmpty(dlIdx))
+		rt.SetEpoch(expiration + miner.WPoStProvingPeriod)
+		actor.onDeadlineCron(rt, &cronConfig{noEnrollment: true})
+		assert.True(t, isEmpty(dlIdx))
+		actor.checkState(rt)
+	})
+
 	t.Run("sector expires and repays fee debt", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
@@ -1419,6 +1708,7 @@ func TestDeadlineCron(t *testing.T) {
 		activePowerDelta := activePower.Neg()
 		advanceDeadline(rt, actor, &cronConfig{
 			detectedFaultsPowerDelta: &activePowerDelta,
+			faultyPowerDelta:         &totalPower,
 		})
 
 		// expect faulty power to be added to state
@@ -1689,72 +1979,242 @@ func TestDeclareFaults(t *testing.T) {
 	})
 }
 
-func TestDeclareRecoveries(t *testing.T) {
+func TestDeclareMaintenanceWindow(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
 	builder := builderForHarness(actor).
 		WithBalance(bigBalance, big.Zero())
 
-	t.Run("recovery happy path", func(t *testing.T) {
+	// Returns the next epoch at which a deadline opens, at least a given number of epochs from now.
+	nextOpenAfter := func(rt *mock.Runtime, dlIdx uint64, delay abi.ChainEpoch) abi.ChainEpoch {
+		dlinfo := actor.deadline(rt)
+		open := dlinfo.PeriodStart + abi.ChainEpoch(dlIdx)*miner.WPoStChallengeWindow
+		for open < rt.Epoch()+delay {
+			open += miner.WPoStProvingPeriod
+		}
+		return open
+	}
+
+	t.Run("faults declared during window pay reduced fee while it lasts", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
-		oneSector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
-
-		// advance to first proving period and submit so we'll have time to declare the fault next cycle
-		advanceAndSubmitPoSts(rt, actor, oneSector...)
-
-		// Declare the sector as faulted
-		actor.declareFaults(rt, oneSector...)
+		allSectors := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		pwr := miner.PowerForSectors(actor.sectorSize, allSectors)
+		actor.applyRewards(rt, bigRewards, big.Zero())
 
-		// Declare recoveries updates state
 		st := getState(rt)
-		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oneSector[0].SectorNumber)
+		dlIdx, _, err := st.FindSector(rt.AdtStore(), allSectors[0].SectorNumber)
 		require.NoError(t, err)
-		actor.declareRecoveries(rt, dlIdx, pIdx, bf(uint64(oneSector[0].SectorNumber)), big.Zero())
+		advanceAndSubmitPoSts(rt, actor, allSectors...)
 
-		dl := actor.getDeadline(rt, dlIdx)
-		p, err := dl.LoadPartition(rt.AdtStore(), pIdx)
-		require.NoError(t, err)
-		assert.Equal(t, p.Faults, p.Recoveries)
+		// The window opens two deadlines before the sector's deadline and closes after it.
+		open := nextOpenAfter(rt, dlIdx, miner.MaintenanceWindowNotice+2*miner.WPoStChallengeWindow)
+		start := open - 2*miner.WPoStChallengeWindow
+		actor.declareMaintenanceWindow(rt, start, 4*miner.WPoStChallengeWindow)
+		advanceAndSubmitPoSts(rt, actor, allSectors...)
+
+		dlinfo := actor.deadline(rt)
+		for dlinfo.Open < start {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
+		actor.declareFaults(rt, allSectors...)
+		assertBitfieldEquals(t, getState(rt).MaintenanceWindow.Faults, uint64(allSectors[0].SectorNumber))
+
+		for dlinfo.Open < open {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
+		require.Equal(t, dlIdx, dlinfo.Index)
+		advanceDeadline(rt, actor, &cronConfig{
+			continuedFaultsPenalty: miner.PledgePenaltyForContinuedMaintenanceFault(actor.epochRewardSmooth, actor.epochQAPowerSmooth, pwr.QA),
+		})
+
+		// Once the window has ended, the fault pays the full fee.
+		dlinfo = actor.deadline(rt)
+		for dlinfo.Index != dlIdx {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
+		advanceDeadline(rt, actor, &cronConfig{
+			continuedFaultsPenalty: actor.continuedFaultPenalty(allSectors),
+		})
 		actor.checkState(rt)
 	})
 
-	t.Run("recovery must pay back fee debt", func(t *testing.T) {
+	t.Run("faults declared before window pay full fee", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
-		oneSector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
-		// advance to first proving period and submit so we'll have time to declare the fault next cycle
-		advanceAndSubmitPoSts(rt, actor, oneSector...)
+		allSectors := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		actor.applyRewards(rt, bigRewards, big.Zero())
 
-		// Fault will take miner into fee debt
 		st := getState(rt)
-		rt.SetBalance(big.Sum(st.PreCommitDeposits, st.InitialPledge, st.LockedFunds))
-
-		actor.declareFaults(rt, oneSector...)
-
-		st = getState(rt)
-		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oneSector[0].SectorNumber)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), allSectors[0].SectorNumber)
 		require.NoError(t, err)
+		advanceAndSubmitPoSts(rt, actor, allSectors...)
+
+		open := nextOpenAfter(rt, dlIdx, miner.MaintenanceWindowNotice+2*miner.WPoStChallengeWindow)
+		start := open - 2*miner.WPoStChallengeWindow
+		actor.declareMaintenanceWindow(rt, start, 4*miner.WPoStChallengeWindow)
+		actor.declareFaults(rt, allSectors...)
 
-		// Skip to end of proving period.
 		dlinfo := actor.deadline(rt)
-		for dlinfo.Index != dlIdx {
-			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		for dlinfo.Open < start {
+			// The fault's deadline may close before the window starts.
+			penalty := big.Zero()
+			if dlinfo.Index == dlIdx {
+				penalty = actor.continuedFaultPenalty(allSectors)
+			}
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{continuedFaultsPenalty: penalty})
 		}
 
-		// Can't pay during this deadline so miner goes into fee debt
-		ongoingPwr := miner.PowerForSectors(actor.sectorSize, oneSector)
-		ff := miner.PledgePenaltyForContinuedFault(actor.epochRewardSmooth, actor.epochQAPowerSmooth, ongoingPwr.QA)
+		// Faults re-declared during the window are not maintenance faults.
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.Call(actor.a.DeclareFaults, &miner.DeclareFaultsParams{Faults: []miner.FaultDeclaration{{
+			Deadline:  dlIdx,
+			Partition: pIdx,
+			Sectors:   bf(uint64(allSectors[0].SectorNumber)),
+		}}})
+		rt.Verify()
+		assertBitfieldEmpty(t, getState(rt).MaintenanceWindow.Faults)
+
+		for dlinfo.Open < open {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
 		advanceDeadline(rt, actor, &cronConfig{
-			continuedFaultsPenalty: big.Zero(), // fee is instead added to debt
+			continuedFaultsPenalty: actor.continuedFaultPenalty(allSectors),
 		})
+		actor.checkState(rt)
+	})
 
-		st = getState(rt)
-		assert.Equal(t, ff, st.FeeDebt)
+	t.Run("rejects invalid windows", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		start := rt.Epoch() + miner.MaintenanceWindowNotice
 
-		// Recovery fails when it can't pay back fee debt
-		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "unlocked balance can not repay fee debt", func() {
-			actor.declareRecoveries(rt, dlIdx, pIdx, bf(uint64(oneSector[0].SectorNumber)), big.Zero())
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be positive", func() {
+			actor.declareMaintenanceWindow(rt, start, 0)
+		})
+		rt.Reset()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must start at or after", func() {
+			actor.declareMaintenanceWindow(rt, start-1, 1)
+		})
+		rt.Reset()
+		periodEnd := miner.MaintenanceQuotaPeriod
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "spans the end of a quota period", func() {
+			actor.declareMaintenanceWindow(rt, periodEnd-1, 2)
+		})
+		rt.Reset()
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "exceed quota", func() {
+			actor.declareMaintenanceWindow(rt, start, miner.MaxMaintenanceEpochsPerQuotaPeriod+1)
+		})
+		rt.Reset()
+
+		// A window may not be replaced before it ends.
+		actor.declareMaintenanceWindow(rt, start, 10)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "has not yet ended", func() {
+			actor.declareMaintenanceWindow(rt, start+20, 10)
+		})
+		rt.Reset()
+
+		// Only control addresses may declare a window.
+		rt.SetCaller(tutil.NewIDAddr(t, 1234), builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.DeclareMaintenanceWindow, &miner.DeclareMaintenanceWindowParams{Start: start + 20, Duration: 10})
+		})
+		rt.Reset()
+	})
+
+	t.Run("quota is shared by windows starting in a quota period", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		start := rt.Epoch() + miner.MaintenanceWindowNotice
+		half := miner.MaxMaintenanceEpochsPerQuotaPeriod / 2
+
+		actor.declareMaintenanceWindow(rt, start, half)
+		rt.SetEpoch(start + half)
+		start = rt.Epoch() + miner.MaintenanceWindowNotice
+		actor.declareMaintenanceWindow(rt, start, miner.MaxMaintenanceEpochsPerQuotaPeriod-half)
+		assert.Equal(t, miner.MaxMaintenanceEpochsPerQuotaPeriod, getState(rt).MaintenanceWindow.QuotaUsed)
+
+		rt.SetEpoch(start + miner.MaxMaintenanceEpochsPerQuotaPeriod)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "exceed quota", func() {
+			actor.declareMaintenanceWindow(rt, rt.Epoch()+miner.MaintenanceWindowNotice, 1)
+		})
+		rt.Reset()
+
+		// The quota is restored in the next quota period.
+		nextPeriod := miner.MaintenanceQuotaPeriod
+		actor.declareMaintenanceWindow(rt, nextPeriod, miner.MaxMaintenanceEpochsPerQuotaPeriod)
+		assert.Equal(t, miner.MaxMaintenanceEpochsPerQuotaPeriod, getState(rt).MaintenanceWindow.QuotaUsed)
+	})
+}
+
+func TestDeclareRecoveries(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("recovery happy path", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		oneSector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+
+		// advance to first proving period and submit so we'll have time to declare the fault next cycle
+		advanceAndSubmitPoSts(rt, actor, oneSector...)
+
+		// Declare the sector as faulted
+		actor.declareFaults(rt, oneSector...)
+
+		// Declare recoveries updates state
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oneSector[0].SectorNumber)
+		require.NoError(t, err)
+		actor.declareRecoveries(rt, dlIdx, pIdx, bf(uint64(oneSector[0].SectorNumber)), big.Zero())
+
+		dl := actor.getDeadline(rt, dlIdx)
+		p, err := dl.LoadPartition(rt.AdtStore(), pIdx)
+		require.NoError(t, err)
+		assert.Equal(t, p.Faults, p.Recoveries)
+		actor.checkState(rt)
+	})
+
+	t.Run("recovery must pay back fee debt", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		oneSector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		// advance to first proving period and submit so we'll have time to declare the fault next cycle
+		advanceAndSubmitPoSts(rt, actor, oneSector...)
+
+		// Fault will take miner into fee debt
+		st := getState(rt)
+		rt.SetBalance(big.Sum(st.PreCommitDeposits, st.InitialPledge, st.LockedFunds))
+
+		actor.declareFaults(rt, oneSector...)
+
+		st = getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oneSector[0].SectorNumber)
+		require.NoError(t, err)
+
+		// Skip to end of proving period.
+		dlinfo := actor.deadline(rt)
+		for dlinfo.Index != dlIdx {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
+
+		// Can't pay during this deadline so miner goes into fee debt
+		ongoingPwr := miner.PowerForSectors(actor.sectorSize, oneSector)
+		ff := miner.PledgePenaltyForContinuedFault(actor.epochRewardSmooth, actor.epochQAPowerSmooth, ongoingPwr.QA)
+		advanceDeadline(rt, actor, &cronConfig{
+			continuedFaultsPenalty: big.Zero(), // fee is instead added to debt
+		})
+
+		st = getState(rt)
+		assert.Equal(t, ff, st.FeeDebt)
+
+		// Recovery fails when it can't pay back fee debt
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "unlocked balance can not repay fee debt", func() {
+			actor.declareRecoveries(rt, dlIdx, pIdx, bf(uint64(oneSector[0].SectorNumber)), big.Zero())
 		})
 
 		// Recovery pays back fee debt and IP requirements and succeeds
@@ -1771,6 +2231,78 @@ func TestDeclareRecoveries(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("queued recovery is declared by cron once fee debt is repaid", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		oneSector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, oneSector...)
+
+		// Fault will take miner into fee debt
+		st := getState(rt)
+		rt.SetBalance(big.Sum(st.PreCommitDeposits, st.InitialPledge, st.LockedFunds))
+		actor.declareFaults(rt, oneSector...)
+
+		st = getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oneSector[0].SectorNumber)
+		require.NoError(t, err)
+
+		dlinfo := actor.deadline(rt)
+		for dlinfo.Index != dlIdx {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
+		ongoingPwr := miner.PowerForSectors(actor.sectorSize, oneSector)
+		ff := miner.PledgePenaltyForContinuedFault(actor.epochRewardSmooth, actor.epochQAPowerSmooth, ongoingPwr.QA)
+		advanceDeadline(rt, actor, &cronConfig{
+			continuedFaultsPenalty: big.Zero(), // fee is instead added to debt
+		})
+		st = getState(rt)
+		assert.Equal(t, ff, st.FeeDebt)
+
+		// Recovery is queued rather than rejected.
+		actor.queueRecoveries(rt, dlIdx, pIdx, bf(uint64(oneSector[0].SectorNumber)))
+		st = getState(rt)
+		require.NotNil(t, st.QueuedRecoveries)
+		p, err := actor.getDeadline(rt, dlIdx).LoadPartition(rt.AdtStore(), pIdx)
+		require.NoError(t, err)
+		assertBitfieldEmpty(t, p.Recoveries)
+
+		// Queued recoveries remain queued while the miner is still in debt.
+		advanceDeadline(rt, actor, &cronConfig{})
+		st = getState(rt)
+		require.NotNil(t, st.QueuedRecoveries)
+		actor.checkState(rt)
+
+		// The next cron after the debt is repaid declares the recovery.
+		actor.repayDebt(rt, ff, big.Zero(), ff)
+		advanceDeadline(rt, actor, &cronConfig{})
+		st = getState(rt)
+		assert.Nil(t, st.QueuedRecoveries)
+		p, err = actor.getDeadline(rt, dlIdx).LoadPartition(rt.AdtStore(), pIdx)
+		require.NoError(t, err)
+		assert.Equal(t, p.Faults, p.Recoveries)
+		actor.checkState(rt)
+	})
+
+	t.Run("queueing recovery without fee debt declares it immediately", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		oneSector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, oneSector...)
+		actor.declareFaults(rt, oneSector...)
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oneSector[0].SectorNumber)
+		require.NoError(t, err)
+		actor.queueRecoveries(rt, dlIdx, pIdx, bf(uint64(oneSector[0].SectorNumber)))
+
+		st = getState(rt)
+		assert.Nil(t, st.QueuedRecoveries)
+		p, err := actor.getDeadline(rt, dlIdx).LoadPartition(rt.AdtStore(), pIdx)
+		require.NoError(t, err)
+		assert.Equal(t, p.Faults, p.Recoveries)
+		actor.checkState(rt)
+	})
+
 	t.Run("recovery fails during active consensus fault", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
@@ -1799,6 +2331,80 @@ func TestDeclareRecoveries(t *testing.T) {
 	})
 }
 
+func TestDeclareFaultsAndRecoveries(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("declares faults and recoveries in one message", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+
+		// Fault the first sector.
+		actor.declareFaults(rt, sectors[0])
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+		dlIdx1, pIdx1, err := st.FindSector(rt.AdtStore(), sectors[1].SectorNumber)
+		require.NoError(t, err)
+		require.Equal(t, dlIdx, dlIdx1)
+		require.Equal(t, pIdx, pIdx1)
+
+		// Recover the first sector while faulting the second.
+		params := &miner.DeclareFaultsAndRecoveriesParams{
+			Faults: []miner.FaultDeclaration{{
+				Deadline:  dlIdx,
+				Partition: pIdx,
+				Sectors:   bf(uint64(sectors[1].SectorNumber)),
+			}},
+			Recoveries: []miner.RecoveryDeclaration{{
+				Deadline:  dlIdx,
+				Partition: pIdx,
+				Sectors:   bf(uint64(sectors[0].SectorNumber)),
+			}},
+		}
+		actor.declareFaultsAndRecoveries(rt, params, miner.PowerForSector(actor.sectorSize, sectors[1]).Neg())
+
+		dl := actor.getDeadline(rt, dlIdx)
+		p, err := dl.LoadPartition(rt.AdtStore(), pIdx)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, p.Faults, uint64(sectors[0].SectorNumber), uint64(sectors[1].SectorNumber))
+		assertBitfieldEquals(t, p.Recoveries, uint64(sectors[0].SectorNumber))
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects sector declared both faulty and recovered", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+
+		dlIdx, pIdx, err := getState(rt).FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+		params := &miner.DeclareFaultsAndRecoveriesParams{
+			Faults: []miner.FaultDeclaration{{
+				Deadline:  dlIdx,
+				Partition: pIdx,
+				Sectors:   bf(uint64(sectors[0].SectorNumber)),
+			}},
+			Recoveries: []miner.RecoveryDeclaration{{
+				Deadline:  dlIdx,
+				Partition: pIdx,
+				Sectors:   bf(uint64(sectors[0].SectorNumber)),
+			}},
+		}
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "sectors declared both faulty and recovered", func() {
+			rt.Call(actor.a.DeclareFaultsAndRecoveries, params)
+		})
+		actor.checkState(rt)
+	})
+}
+
 func TestExtendSectorExpiration(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
@@ -1870,6 +2476,42 @@ func TestExtendSectorExpiration(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("rejects extension past max for seal proof and sector age", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector := commitSector(t, rt)
+
+		// sectors older than a day may only be extended 300 days past the current epoch
+		prev := miner.MaxExpirationExtensionSchedule[sector.SealProof]
+		reduced := abi.ChainEpoch(300 * builtin.EpochsInDay)
+		miner.MaxExpirationExtensionSchedule[sector.SealProof] = []miner.ExpirationExtensionStep{
+			{MinAge: 0, MaxExtension: miner.MaxSectorExpirationExtension},
+			{MinAge: builtin.EpochsInDay, MaxExtension: reduced},
+		}
+		defer func() { miner.MaxExpirationExtensionSchedule[sector.SealProof] = prev }()
+
+		rt.SetEpoch(sector.Expiration)
+		newExpiration := rt.Epoch() + miner.WPoStProvingPeriod*400
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sector.SectorNumber)
+		require.NoError(t, err)
+
+		params := &miner.ExtendSectorExpirationParams{
+			Extensions: []miner.ExpirationExtension{{
+				Deadline:      dlIdx,
+				Partition:     pIdx,
+				Sectors:       bf(uint64(sector.SectorNumber)),
+				NewExpiration: newExpiration,
+			}},
+		}
+
+		expectedMessage := fmt.Sprintf("cannot be more than %d past current epoch", reduced)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, expectedMessage, func() {
+			actor.extendSectors(rt, params)
+		})
+		actor.checkState(rt)
+	})
+
 	t.Run("rejects extension past max for seal proof", func(t *testing.T) {
 		rt := builder.Build(t)
 		sector := commitSector(t, rt)
@@ -2053,41 +2695,145 @@ func TestExtendSectorExpiration(t *testing.T) {
 		actor.checkState(rt)
 	})
 
-	t.Run("supports extensions off deadline boundary", func(t *testing.T) {
+	t.Run("merges declarations for the same partition", func(t *testing.T) {
 		rt := builder.Build(t)
-		oldSector := commitSector(t, rt)
-		advanceAndSubmitPoSts(rt, actor, oldSector)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 3, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
 
 		st := getState(rt)
-		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oldSector.SectorNumber)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
 		require.NoError(t, err)
+		for _, sector := range sectors[1:] {
+			otherDlIdx, otherPIdx, err := st.FindSector(rt.AdtStore(), sector.SectorNumber)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{dlIdx, pIdx}, []uint64{otherDlIdx, otherPIdx}, "test error: sectors should share a partition")
+		}
 
-		extension := 42*miner.WPoStProvingPeriod + miner.WPoStProvingPeriod/3
-		newExpiration := oldSector.Expiration + extension
+		expiration1 := sectors[0].Expiration + 21*miner.WPoStProvingPeriod
+		expiration2 := sectors[0].Expiration + 42*miner.WPoStProvingPeriod
 		params := &miner.ExtendSectorExpirationParams{
 			Extensions: []miner.ExpirationExtension{{
 				Deadline:      dlIdx,
 				Partition:     pIdx,
-				Sectors:       bf(uint64(oldSector.SectorNumber)),
-				NewExpiration: newExpiration,
+				Sectors:       bf(uint64(sectors[0].SectorNumber)),
+				NewExpiration: expiration1,
+			}, {
+				Deadline:      dlIdx,
+				Partition:     pIdx,
+				Sectors:       bf(uint64(sectors[1].SectorNumber), uint64(sectors[2].SectorNumber)),
+				NewExpiration: expiration2,
+			}, {
+				// A repeated declaration is harmless.
+				Deadline:      dlIdx,
+				Partition:     pIdx,
+				Sectors:       bf(uint64(sectors[1].SectorNumber)),
+				NewExpiration: expiration2,
 			}},
 		}
-
 		actor.extendSectors(rt, params)
 
-		// assert sector expiration is set to the new value
-		st = getState(rt)
-		newSector := actor.getSector(rt, oldSector.SectorNumber)
-		assert.Equal(t, newExpiration, newSector.Expiration)
-
-		// advance clock to expiration
-		rt.SetEpoch(newSector.Expiration)
-		st.ProvingPeriodStart += miner.WPoStProvingPeriod * ((rt.Epoch()-st.ProvingPeriodStart)/miner.WPoStProvingPeriod + 1)
-		rt.ReplaceState(st)
+		assert.Equal(t, expiration1, actor.getSector(rt, sectors[0].SectorNumber).Expiration)
+		assert.Equal(t, expiration2, actor.getSector(rt, sectors[1].SectorNumber).Expiration)
+		assert.Equal(t, expiration2, actor.getSector(rt, sectors[2].SectorNumber).Expiration)
 
-		// confirm it is not in sector's deadline
-		dlinfo := actor.deadline(rt)
-		assert.NotEqual(t, dlIdx, dlinfo.Index)
+		quant := st.QuantSpecForDeadline(dlIdx)
+		_, partition := actor.getDeadlineAndPartition(rt, dlIdx, pIdx)
+		expirationSet, err := partition.PopExpiredSectors(rt.AdtStore(), quant.QuantizeUp(expiration1), quant)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, expirationSet.OnTimeSectors, uint64(sectors[0].SectorNumber))
+		expirationSet, err = partition.PopExpiredSectors(rt.AdtStore(), quant.QuantizeUp(expiration2), quant)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, expirationSet.OnTimeSectors, uint64(sectors[1].SectorNumber), uint64(sectors[2].SectorNumber))
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects conflicting expirations for a sector", func(t *testing.T) {
+		rt := builder.Build(t)
+		oldSector := commitSector(t, rt)
+		advanceAndSubmitPoSts(rt, actor, oldSector)
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oldSector.SectorNumber)
+		require.NoError(t, err)
+
+		params := &miner.ExtendSectorExpirationParams{
+			Extensions: []miner.ExpirationExtension{{
+				Deadline:      dlIdx,
+				Partition:     pIdx,
+				Sectors:       bf(uint64(oldSector.SectorNumber)),
+				NewExpiration: oldSector.Expiration + miner.WPoStProvingPeriod,
+			}, {
+				Deadline:      dlIdx,
+				Partition:     pIdx,
+				Sectors:       bf(uint64(oldSector.SectorNumber)),
+				NewExpiration: oldSector.Expiration + 2*miner.WPoStProvingPeriod,
+			}},
+		}
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "conflicting expirations", func() {
+			rt.Call(actor.a.ExtendSectorExpiration, params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("counts repeated declarations toward the sector limit", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		// Each declaration is within the limit, as are the sectors merged from them, but not their sum.
+		limit := miner.AddressingLimitsForProof(actor.windowPostProofType).Sectors
+		decl := miner.ExpirationExtension{
+			Deadline:      0,
+			Partition:     0,
+			Sectors:       seq(t, 0, limit/2+1),
+			NewExpiration: abi.ChainEpoch(miner.MaxSectorExpirationExtension),
+		}
+		params := &miner.ExtendSectorExpirationParams{Extensions: []miner.ExpirationExtension{decl, decl}}
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too many sectors for declaration", func() {
+			rt.Call(actor.a.ExtendSectorExpiration, params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("supports extensions off deadline boundary", func(t *testing.T) {
+		rt := builder.Build(t)
+		oldSector := commitSector(t, rt)
+		advanceAndSubmitPoSts(rt, actor, oldSector)
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), oldSector.SectorNumber)
+		require.NoError(t, err)
+
+		extension := 42*miner.WPoStProvingPeriod + miner.WPoStProvingPeriod/3
+		newExpiration := oldSector.Expiration + extension
+		params := &miner.ExtendSectorExpirationParams{
+			Extensions: []miner.ExpirationExtension{{
+				Deadline:      dlIdx,
+				Partition:     pIdx,
+				Sectors:       bf(uint64(oldSector.SectorNumber)),
+				NewExpiration: newExpiration,
+			}},
+		}
+
+		actor.extendSectors(rt, params)
+
+		// assert sector expiration is set to the new value
+		st = getState(rt)
+		newSector := actor.getSector(rt, oldSector.SectorNumber)
+		assert.Equal(t, newExpiration, newSector.Expiration)
+
+		// advance clock to expiration
+		rt.SetEpoch(newSector.Expiration)
+		st.ProvingPeriodStart += miner.WPoStProvingPeriod * ((rt.Epoch()-st.ProvingPeriodStart)/miner.WPoStProvingPeriod + 1)
+		rt.ReplaceState(st)
+
+		// confirm it is not in sector's deadline
+		dlinfo := actor.deadline(rt)
+		assert.NotEqual(t, dlIdx, dlinfo.Index)
 
 		// advance to deadline and submit one last PoSt
 		for dlinfo.Index != dlIdx {
@@ -2111,6 +2857,108 @@ func TestExtendSectorExpiration(t *testing.T) {
 	})
 }
 
+func TestExtendSectorExpiration2(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithEpoch(abi.ChainEpoch(1)).
+		WithBalance(bigBalance, big.Zero())
+
+	commitSector := func(t *testing.T, rt *mock.Runtime) (*miner.SectorOnChainInfo, uint64, uint64) {
+		actor.constructAndVerify(rt)
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)[0]
+		advanceAndSubmitPoSts(rt, actor, sector)
+		dlIdx, pIdx, err := getState(rt).FindSector(rt.AdtStore(), sector.SectorNumber)
+		require.NoError(t, err)
+		return sector, dlIdx, pIdx
+	}
+	extensionParams := func(sector *miner.SectorOnChainInfo, dlIdx, pIdx uint64, claims ...miner.SectorVerifiedClaim) *miner.ExtendSectorExpiration2Params {
+		return &miner.ExtendSectorExpiration2Params{Extensions: []miner.ExpirationExtension2{{
+			Deadline:      dlIdx,
+			Partition:     pIdx,
+			Sectors:       bf(uint64(sector.SectorNumber)),
+			Claims:        claims,
+			NewExpiration: sector.Expiration + 42*miner.WPoStProvingPeriod,
+		}}}
+	}
+
+	t.Run("claimed verified space is weighted over the remaining life", func(t *testing.T) {
+		rt := builder.Build(t)
+		oldSector, dlIdx, pIdx := commitSector(t, rt)
+
+		claimed := big.NewIntUnsigned(uint64(actor.sectorSize))
+		params := extensionParams(oldSector, dlIdx, pIdx, miner.SectorVerifiedClaim{SectorNumber: oldSector.SectorNumber, ClaimIDs: []verifreg.AllocationID{1, 2}})
+		actor.extendSectors2(rt, params, map[abi.SectorNumber]abi.StoragePower{oldSector.SectorNumber: claimed})
+
+		newSector := actor.getSector(rt, oldSector.SectorNumber)
+		duration := params.Extensions[0].NewExpiration - rt.Epoch()
+		assert.Equal(t, params.Extensions[0].NewExpiration, newSector.Expiration)
+		assert.Equal(t, rt.Epoch(), newSector.Activation)
+		assert.Equal(t, big.Mul(claimed, big.NewInt(int64(duration))), newSector.VerifiedDealWeight)
+		assert.Equal(t, rt.Epoch()-oldSector.Activation, newSector.ReplacedSectorAge)
+		assert.Equal(t, big.Mul(big.NewIntUnsigned(uint64(actor.sectorSize)), builtin.VerifiedDealWeightMultiplier),
+			big.Mul(miner.QAPowerForSector(actor.sectorSize, newSector), builtin.QualityBaseMultiplier))
+		assert.True(t, newSector.InitialPledge.GreaterThan(oldSector.InitialPledge))
+		actor.checkState(rt)
+	})
+
+	t.Run("sector without a claim has no verified space", func(t *testing.T) {
+		rt := builder.Build(t)
+		oldSector, dlIdx, pIdx := commitSector(t, rt)
+
+		actor.extendSectors2(rt, extensionParams(oldSector, dlIdx, pIdx), nil)
+
+		newSector := actor.getSector(rt, oldSector.SectorNumber)
+		assert.True(t, newSector.VerifiedDealWeight.IsZero())
+		assert.Equal(t, big.NewIntUnsigned(uint64(actor.sectorSize)), miner.QAPowerForSector(actor.sectorSize, newSector))
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects claim exceeding the sector's space", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		claimed := big.Add(big.NewIntUnsigned(uint64(actor.sectorSize)), big.NewInt(1))
+		params := extensionParams(sector, dlIdx, pIdx, miner.SectorVerifiedClaim{SectorNumber: sector.SectorNumber, ClaimIDs: []verifreg.AllocationID{1}})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exceeds its free space", func() {
+			actor.extendSectors2(rt, params, map[abi.SectorNumber]abi.StoragePower{sector.SectorNumber: claimed})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects claim for a sector not extended", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		params := extensionParams(sector, dlIdx, pIdx, miner.SectorVerifiedClaim{SectorNumber: sector.SectorNumber + 1, ClaimIDs: []verifreg.AllocationID{1}})
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "not in extension", func() {
+			rt.Call(actor.a.ExtendSectorExpiration2, params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects claims the verified registry does not bind to the sector", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		claimIDs := []verifreg.AllocationID{1}
+		params := extensionParams(sector, dlIdx, pIdx, miner.SectorVerifiedClaim{SectorNumber: sector.SectorNumber, ClaimIDs: claimIDs})
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.VerifySectorClaims,
+			&verifreg.VerifySectorClaimsParams{Sectors: []verifreg.SectorClaims{{SectorNumber: sector.SectorNumber, ClaimIDs: claimIDs}}},
+			big.Zero(), &verifreg.VerifySectorClaimsReturn{}, exitcode.ErrForbidden)
+		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+			rt.Call(actor.a.ExtendSectorExpiration2, params)
+		})
+		rt.Reset()
+		assert.Equal(t, sector.Expiration, actor.getSector(rt, sector.SectorNumber).Expiration)
+		actor.checkState(rt)
+	})
+}
+
 func TestTerminateSectors(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
@@ -2163,6 +3011,143 @@ func TestTerminateSectors(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("removes the verified registry claims of a verified sector", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		dlInfo := actor.deadline(rt)
+
+		sectorNo := abi.SectorNumber(100)
+		proveCommitEpoch := precommitEpoch + miner.PreCommitChallengeDelay + 1
+		expiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		verifiedDealWeight := big.Mul(big.NewInt(int64(actor.sectorSize)), big.NewInt(int64(expiration-proveCommitEpoch)))
+		precommitParams := actor.makePreCommit(sectorNo, precommitEpoch-1, expiration, []abi.DealID{1})
+		precommit := actor.preCommitSector(rt, precommitParams, preCommitConf{
+			dealWeight:         big.Zero(),
+			verifiedDealWeight: verifiedDealWeight,
+		}, true)
+
+		rt.SetEpoch(proveCommitEpoch)
+		sector := actor.proveCommitSectorAndConfirm(rt, precommit, makeProveCommit(sectorNo), proveCommitConf{})
+		require.Equal(t, verifiedDealWeight, sector.VerifiedDealWeight)
+		advanceAndSubmitPoSts(rt, actor, sector)
+		actor.applyRewards(rt, bigRewards, big.Zero())
+
+		sectorPower := miner.QAPowerForSector(actor.sectorSize, sector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay)
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod)
+		sectorAge := rt.Epoch() - sector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+
+		// The harness expects the claims of the sector to be removed along with its deals.
+		actor.terminateSectors(rt, bf(uint64(sectorNo)), expectedFee)
+		actor.checkState(rt)
+	})
+
+	t.Run("pays termination fee from earmarked value", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)[0]
+		advanceAndSubmitPoSts(rt, actor, sector)
+		actor.applyRewards(rt, bigRewards, big.Zero())
+		initialLockedFunds := getState(rt).LockedFunds
+
+		sectorPower := miner.QAPowerForSector(actor.sectorSize, sector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay)
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod)
+		sectorAge := rt.Epoch() - sector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+		require.True(t, expectedFee.GreaterThan(big.Zero()))
+
+		// Funds in excess of the fee are returned to the caller, and vesting funds are untouched.
+		balance := rt.Balance()
+		actor.terminateSectorsWithPenaltyFunds(rt, bf(uint64(sector.SectorNumber)), expectedFee, big.Add(expectedFee, big.NewInt(100)), exitcode.Ok)
+
+		st := getState(rt)
+		assert.Equal(t, initialLockedFunds, st.LockedFunds)
+		assert.Equal(t, big.Zero(), st.FeeDebt)
+		assert.Equal(t, big.Zero(), st.InitialPledge)
+		assert.Equal(t, balance, rt.Balance())
+		actor.checkState(rt)
+	})
+
+	t.Run("keeps unused earmarked value the caller does not accept", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)[0]
+		advanceAndSubmitPoSts(rt, actor, sector)
+		actor.applyRewards(rt, bigRewards, big.Zero())
+
+		sectorPower := miner.QAPowerForSector(actor.sectorSize, sector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay)
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod)
+		sectorAge := rt.Epoch() - sector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+
+		// The failed refund doesn't abort the termination.
+		actor.terminateSectorsWithPenaltyFunds(rt, bf(uint64(sector.SectorNumber)), expectedFee, big.Add(expectedFee, big.NewInt(100)), exitcode.ErrForbidden)
+
+		st := getState(rt)
+		assert.Equal(t, big.Zero(), st.InitialPledge)
+		assert.Equal(t, big.Zero(), st.FeeDebt)
+		actor.checkState(rt)
+	})
+
+	t.Run("draws termination fee beyond earmarked value from vesting funds", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)[0]
+		advanceAndSubmitPoSts(rt, actor, sector)
+		actor.applyRewards(rt, bigRewards, big.Zero())
+		initialLockedFunds := getState(rt).LockedFunds
+
+		sectorPower := miner.QAPowerForSector(actor.sectorSize, sector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay)
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod)
+		sectorAge := rt.Epoch() - sector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+
+		earmarked := big.Div(expectedFee, big.NewInt(2))
+		actor.terminateSectorsWithPenaltyFunds(rt, bf(uint64(sector.SectorNumber)), expectedFee, earmarked, exitcode.Ok)
+
+		st := getState(rt)
+		assert.Equal(t, big.Sub(initialLockedFunds, big.Sub(expectedFee, earmarked)), st.LockedFunds)
+		assert.Equal(t, big.Zero(), st.FeeDebt)
+		actor.checkState(rt)
+	})
+
+	t.Run("reports terminated sectors as settled once fees are processed", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+		sectorInfo := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectorInfo...)
+		assert.Equal(t, miner.TerminatedSectorCountsReturn{}, *actor.terminatedSectorCounts(rt))
+		actor.applyRewards(rt, bigRewards, big.Zero())
+
+		sector := sectorInfo[0]
+		sectorSize, err := sector.SealProof.SectorSize()
+		require.NoError(t, err)
+		sectorPower := miner.QAPowerForSector(sectorSize, sector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay)
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod)
+		sectorAge := rt.Epoch() - sector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+		actor.terminateSectors(rt, bf(uint64(sector.SectorNumber)), expectedFee)
+
+		// The termination is processed immediately, so the sector is already settled.
+		assert.Equal(t, miner.TerminatedSectorCountsReturn{PendingSettlement: 0, Settled: 1}, *actor.terminatedSectorCounts(rt))
+		summary, msgs := miner.CheckStateInvariants(getState(rt), rt.AdtStore(), rt.Balance())
+		assert.True(t, msgs.IsEmpty(), strings.Join(msgs.Messages(), "\n"))
+		assert.Equal(t, uint64(0), summary.PendingTerminatedSectors)
+		assert.Equal(t, uint64(1), summary.SettledTerminatedSectors)
+		actor.checkState(rt)
+	})
+
 	t.Run("cannot terminate a sector when the challenge window is open", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
@@ -2187,7 +3172,7 @@ func TestTerminateSectors(t *testing.T) {
 		}}}
 		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
 		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
-		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "cannot terminate sectors in immutable deadline", func() {
+		rt.ExpectAbortContainsMessage(miner.ErrImmutableDeadline, "cannot terminate sectors in immutable deadline", func() {
 			rt.Call(actor.a.TerminateSectors, params)
 		})
 
@@ -2196,41 +3181,155 @@ func TestTerminateSectors(t *testing.T) {
 
 }
 
-func TestWithdrawBalance(t *testing.T) {
+func TestTerminateBreachedSector(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
 	builder := builderForHarness(actor).
 		WithBalance(bigBalance, big.Zero())
+	dealID := abi.DealID(10)
 
-	onePercentBalance := big.Div(bigBalance, big.NewInt(100))
-
-	t.Run("happy path withdraws funds", func(t *testing.T) {
+	// Commits a sector holding a deal, then leaves it faulty through the end of a number of its deadlines.
+	// Returns the sector and the epoch its fault streak started.
+	setup := func(t *testing.T, faultyPeriods int) (*mock.Runtime, *miner.SectorOnChainInfo, abi.ChainEpoch) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, [][]abi.DealID{{dealID}}, true)[0]
+		actor.applyRewards(rt, bigRewards, big.Zero())
+		advanceAndSubmitPoSts(rt, actor, sector)
 
-		// withdraw 1% of balance
-		actor.withdrawFunds(rt, onePercentBalance, onePercentBalance, big.Zero())
+		dlIdx, _, err := getState(rt).FindSector(rt.AdtStore(), sector.SectorNumber)
+		require.NoError(t, err)
+		actor.declareFaults(rt, sector)
+
+		streakStart := abi.ChainEpoch(-1)
+		dlinfo := actor.deadline(rt)
+		for faultyPeriods > 0 {
+			penalty := big.Zero()
+			if dlinfo.Index == dlIdx {
+				penalty = actor.continuedFaultPenalty([]*miner.SectorOnChainInfo{sector})
+				if streakStart < 0 {
+					streakStart = dlinfo.Last()
+				}
+				faultyPeriods--
+			}
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{continuedFaultsPenalty: penalty})
+		}
+		return rt, sector, streakStart
+	}
+
+	t.Run("terminates sector faulty for long enough", func(t *testing.T) {
+		rt, sector, streakStart := setup(t, miner.ClientBreachFaultPeriods+1)
+
+		start, found, err := getState(rt).GetFaultStreakStart(rt.AdtStore(), sector.SectorNumber)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, streakStart, start)
+
+		sectorPower := miner.QAPowerForSector(actor.sectorSize, sector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay)
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod)
+		sectorAge := rt.Epoch() - sector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+
+		actor.terminateBreachedSector(rt, sector, dealID, expectedFee)
+
+		_, partition := actor.findSector(rt, sector.SectorNumber)
+		terminated, err := partition.Terminated.IsSet(uint64(sector.SectorNumber))
+		require.NoError(t, err)
+		assert.True(t, terminated)
+		assert.Equal(t, big.Zero(), getState(rt).InitialPledge)
+
+		// A terminated sector is no longer faulty, so cannot be terminated again.
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not faulty", func() {
+			actor.terminateBreachedSector(rt, sector, dealID, big.Zero())
+		})
+		rt.Reset()
 		actor.checkState(rt)
 	})
 
-	t.Run("fails if miner can't repay fee debt", func(t *testing.T) {
-		rt := builder.Build(t)
-		actor.constructAndVerify(rt)
+	t.Run("rejects sector not faulty for long enough", func(t *testing.T) {
+		rt, sector, _ := setup(t, miner.ClientBreachFaultPeriods)
 
-		st := getState(rt)
-		st.FeeDebt = big.Add(rt.Balance(), abi.NewTokenAmount(1e18))
-		rt.ReplaceState(st)
-		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "unlocked balance can not repay fee debt", func() {
-			actor.withdrawFunds(rt, onePercentBalance, onePercentBalance, big.Zero())
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "not faulty for more than", func() {
+			actor.terminateBreachedSector(rt, sector, dealID, big.Zero())
 		})
+		rt.Reset()
 		actor.checkState(rt)
 	})
 
-	t.Run("withdraw only what we can after fee debt", func(t *testing.T) {
+	t.Run("rejects sector without the deal", func(t *testing.T) {
+		rt, sector, _ := setup(t, miner.ClientBreachFaultPeriods+1)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "does not hold deal", func() {
+			actor.terminateBreachedSector(rt, sector, dealID+1, big.Zero())
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects sector never faulty", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, [][]abi.DealID{{dealID}}, true)[0]
+		advanceAndSubmitPoSts(rt, actor, sector)
 
-		st := getState(rt)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "not faulty for more than", func() {
+			actor.terminateBreachedSector(rt, sector, dealID, big.Zero())
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects caller other than market actor", func(t *testing.T) {
+		rt, sector, _ := setup(t, miner.ClientBreachFaultPeriods+1)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.TerminateBreachedSector, &builtin.TerminateBreachedSectorParams{
+				SectorNumber: sector.SectorNumber,
+				DealID:       dealID,
+			})
+		})
+		rt.Reset()
+	})
+}
+
+func TestWithdrawBalance(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	onePercentBalance := big.Div(bigBalance, big.NewInt(100))
+
+	t.Run("happy path withdraws funds", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		// withdraw 1% of balance
+		actor.withdrawFunds(rt, onePercentBalance, onePercentBalance, big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if miner can't repay fee debt", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		st := getState(rt)
+		st.FeeDebt = big.Add(rt.Balance(), abi.NewTokenAmount(1e18))
+		rt.ReplaceState(st)
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "unlocked balance can not repay fee debt", func() {
+			actor.withdrawFunds(rt, onePercentBalance, onePercentBalance, big.Zero())
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("withdraw only what we can after fee debt", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		st := getState(rt)
 		feeDebt := big.Sub(bigBalance, onePercentBalance)
 		st.FeeDebt = feeDebt
 		rt.ReplaceState(st)
@@ -2240,6 +3339,37 @@ func TestWithdrawBalance(t *testing.T) {
 		actor.withdrawFunds(rt, requested, expectedWithdraw, feeDebt)
 		actor.checkState(rt)
 	})
+
+	t.Run("withdraws to beneficiary within its quota", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		beneficiary := tutil.NewIDAddr(t, 1000)
+		quota := big.Div(onePercentBalance, big.NewInt(2))
+		actor.setBeneficiary(rt, beneficiary, quota, rt.Epoch()+100)
+
+		// The owner withdraws to the beneficiary, up to the quota.
+		actor.withdrawFundsFrom(rt, actor.owner, onePercentBalance, quota, big.Zero())
+		assert.Equal(t, quota, actor.getInfo(rt).BeneficiaryTerm.UsedQuota)
+
+		// Nothing more may be withdrawn once the quota is used.
+		actor.withdrawFundsFrom(rt, beneficiary, onePercentBalance, big.Zero(), big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary withdraws nothing after its term expires", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		beneficiary := tutil.NewIDAddr(t, 1000)
+		expiration := rt.Epoch() + 100
+		actor.setBeneficiary(rt, beneficiary, onePercentBalance, expiration)
+
+		actor.withdrawFundsFrom(rt, beneficiary, big.Div(onePercentBalance, big.NewInt(2)),
+			big.Div(onePercentBalance, big.NewInt(2)), big.Zero())
+
+		rt.SetEpoch(expiration)
+		actor.withdrawFundsFrom(rt, beneficiary, onePercentBalance, big.Zero(), big.Zero())
+		actor.checkState(rt)
+	})
 }
 
 func TestRepayDebts(t *testing.T) {
@@ -2305,7 +3435,7 @@ func TestRepayDebts(t *testing.T) {
 		actor.constructAndVerify(rt)
 
 		rewardAmount := big.Mul(big.NewInt(4), big.NewInt(1e18))
-		amountLocked, _ := miner.LockedRewardFromReward(rewardAmount)
+		amountLocked, _ := miner.LockedRewardFromReward(rewardAmount, builtin.VestingSpecBlockReward)
 		rt.SetBalance(amountLocked)
 		actor.applyRewards(rt, rewardAmount, big.Zero())
 		require.Equal(t, amountLocked, actor.getLockedFunds(rt))
@@ -2344,6 +3474,87 @@ func TestChangePeerID(t *testing.T) {
 	})
 }
 
+func TestChangeWindowPoStProofType(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("miner without sectors changes proof type", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		actor.changeWindowPoStProofType(rt, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		info := actor.getInfo(rt)
+		assert.Equal(t, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1, info.WindowPoStProofType)
+		assert.Equal(t, abi.SectorSize(64<<30), info.SectorSize)
+		partitionSectors, err := builtin.PoStProofWindowPoStPartitionSectors(abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		require.NoError(t, err)
+		assert.Equal(t, partitionSectors, info.WindowPoStPartitionSectors)
+		actor.checkState(rt)
+	})
+
+	t.Run("invalid proof type is rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "not allowed", func() {
+			rt.Call(actor.a.ChangeWindowPoStProofType, &miner.ChangeWindowPoStProofTypeParams{
+				NewProofType: abi.RegisteredPoStProof_StackedDrgWindow2KiBV1,
+			})
+		})
+		rt.Reset()
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "already uses", func() {
+			actor.changeWindowPoStProofType(rt, actor.windowPostProofType)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("only the owner may change proof type", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.ChangeWindowPoStProofType, &miner.ChangeWindowPoStProofTypeParams{
+				NewProofType: abi.RegisteredPoStProof_StackedDrgWindow64GiBV1,
+			})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("miner with a pre-committed sector may not change proof type", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(periodOffset + 1)
+
+		expiration := actor.deadline(rt).PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		actor.preCommitSector(rt, actor.makePreCommit(100, rt.Epoch()-1, expiration, nil), preCommitConf{}, true)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "miner with sectors", func() {
+			actor.changeWindowPoStProofType(rt, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("miner with a committed sector may not change proof type", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "miner with sectors", func() {
+			actor.changeWindowPoStProofType(rt, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
 func TestCompactPartitions(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
@@ -2420,6 +3631,40 @@ func TestCompactPartitions(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("compacting with early termination processing and none pending removes dead sectors", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetEpoch(200)
+		info := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, [][]abi.DealID{{10}, {20}}, true)
+		advanceAndSubmitPoSts(rt, actor, info...) // prove and activate power.
+
+		rt.SetEpoch(rt.Epoch() + 100)
+		actor.applyRewards(rt, bigRewards, big.Zero())
+		tsector := info[0]
+		sectorPower := miner.QAPowerForSector(actor.sectorSize, tsector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay)
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod)
+		sectorAge := rt.Epoch() - tsector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth,
+			sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+		actor.terminateSectors(rt, bitfield.NewFromSet([]uint64{uint64(tsector.SectorNumber)}), expectedFee)
+
+		advanceToEpochWithCron(rt, actor, rt.Epoch()+miner.WPoStDisputeWindow)
+
+		// Terminations were processed immediately so no reward or power requests are made.
+		actor.compactPartitions2(rt, &miner.CompactPartitions2Params{
+			Deadline:                 0,
+			Partitions:               bitfield.NewFromSet([]uint64{0}),
+			ProcessEarlyTerminations: true,
+		})
+
+		st := getState(rt)
+		assertSectorExists(rt.AdtStore(), st, info[1].SectorNumber, 0, 0)
+		assertSectorNotFound(rt.AdtStore(), st, tsector.SectorNumber)
+		actor.checkState(rt)
+	})
+
 	t.Run("fail to compact partitions with faults", func(T *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
@@ -2485,7 +3730,7 @@ func TestCompactPartitions(t *testing.T) {
 		actor.constructAndVerify(rt)
 
 		rt.SetEpoch(periodOffset)
-		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+		rt.ExpectAbort(miner.ErrImmutableDeadline, func() {
 			actor.compactPartitions(rt, 0, bitfield.New())
 		})
 		actor.checkState(rt)
@@ -2496,7 +3741,7 @@ func TestCompactPartitions(t *testing.T) {
 		actor.constructAndVerify(rt)
 
 		rt.SetEpoch(periodOffset)
-		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+		rt.ExpectAbort(miner.ErrImmutableDeadline, func() {
 			actor.compactPartitions(rt, 1, bitfield.New())
 		})
 		actor.checkState(rt)
@@ -2516,7 +3761,7 @@ func TestCompactPartitions(t *testing.T) {
 		actor.constructAndVerify(rt)
 
 		rt.SetEpoch(periodOffset)
-		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+		rt.ExpectAbort(miner.ErrImmutableDeadline, func() {
 			actor.compactPartitions(rt, 47, bitfield.New())
 		})
 		actor.checkState(rt)
@@ -2528,7 +3773,7 @@ func TestCompactPartitions(t *testing.T) {
 		actor.constructAndVerify(rt)
 
 		rt.SetEpoch(disputeEnd)
-		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+		rt.ExpectAbort(miner.ErrImmutableDeadline, func() {
 			actor.compactPartitions(rt, 0, bitfield.New())
 		})
 		actor.checkState(rt)
@@ -2557,6 +3802,150 @@ func TestCompactPartitions(t *testing.T) {
 	})
 }
 
+func TestMovePartitions(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+	lastDeadline := miner.WPoStPeriodDeadlines - 1
+
+	setup := func(t *testing.T) (*mock.Runtime, []*miner.SectorOnChainInfo) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(200)
+		info := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, info...) // prove and activate power.
+		return rt, info
+	}
+
+	t.Run("moved partition is proven at its new deadline", func(t *testing.T) {
+		rt, info := setup(t)
+		advanceToEpochWithCron(rt, actor, rt.Epoch()+miner.WPoStDisputeWindow)
+
+		actor.movePartitions(rt, 0, lastDeadline, bitfield.NewFromSet([]uint64{0}))
+		st := getState(rt)
+		for _, sector := range info {
+			dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sector.SectorNumber)
+			require.NoError(t, err)
+			assert.Equal(t, lastDeadline, dlIdx)
+			assert.Equal(t, uint64(0), pIdx)
+		}
+		actor.checkState(rt)
+
+		advanceAndSubmitPoSts(rt, actor, info...)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to move partitions within a deadline", func(t *testing.T) {
+		rt, _ := setup(t)
+		advanceToEpochWithCron(rt, actor, rt.Epoch()+miner.WPoStDisputeWindow)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "within deadline 0", func() {
+			actor.movePartitions(rt, 0, 0, bitfield.NewFromSet([]uint64{0}))
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to move partitions while their proofs may be disputed", func(t *testing.T) {
+		rt, _ := setup(t)
+
+		rt.ExpectAbortContainsMessage(miner.ErrImmutableDeadline, "cannot move partitions from deadline 0", func() {
+			actor.movePartitions(rt, 0, lastDeadline, bitfield.NewFromSet([]uint64{0}))
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to move partitions to a deadline next opening after the origin", func(t *testing.T) {
+		rt, _ := setup(t)
+		advanceToEpochWithCron(rt, actor, rt.Epoch()+miner.WPoStDisputeWindow)
+
+		// Both deadlines next open in the next proving period, deadline 1 after deadline 0.
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "cannot move partitions to deadline 1", func() {
+			actor.movePartitions(rt, 0, 1, bitfield.NewFromSet([]uint64{0}))
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to move partitions with faults", func(t *testing.T) {
+		rt, info := setup(t)
+		actor.declareFaults(rt, info[0])
+		advanceToEpochWithCron(rt, actor, rt.Epoch()+miner.WPoStDisputeWindow)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "cannot remove partition 0: has faults", func() {
+			actor.movePartitions(rt, 0, lastDeadline, bitfield.NewFromSet([]uint64{0}))
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestRebalanceSectors(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+	lastDeadline := miner.WPoStPeriodDeadlines - 1
+
+	setup := func(t *testing.T) (*mock.Runtime, []*miner.SectorOnChainInfo) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(200)
+		info := actor.commitAndProveSectors(rt, 4, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, info...) // prove and activate power.
+		advanceToEpochWithCron(rt, actor, rt.Epoch()+miner.WPoStDisputeWindow)
+		return rt, info
+	}
+
+	t.Run("moves sectors to balance deadlines", func(t *testing.T) {
+		rt, info := setup(t)
+		counts := actor.deadlineSectorCounts(rt)
+		require.Len(t, counts, int(miner.WPoStPeriodDeadlines))
+		assert.Equal(t, uint64(4), counts[0])
+
+		// At most half the difference in load is moved.
+		actor.rebalanceSectors(rt, 0, lastDeadline, bitfield.NewFromSet([]uint64{0}), 10)
+		counts = actor.deadlineSectorCounts(rt)
+		assert.Equal(t, uint64(2), counts[0])
+		assert.Equal(t, uint64(2), counts[lastDeadline])
+		actor.checkState(rt)
+
+		advanceAndSubmitPoSts(rt, actor, info...)
+		actor.checkState(rt)
+	})
+
+	t.Run("moves at most the requested number of sectors", func(t *testing.T) {
+		rt, _ := setup(t)
+		actor.rebalanceSectors(rt, 0, lastDeadline, bitfield.NewFromSet([]uint64{0}), 1)
+		counts := actor.deadlineSectorCounts(rt)
+		assert.Equal(t, uint64(3), counts[0])
+		assert.Equal(t, uint64(1), counts[lastDeadline])
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to move sectors to a deadline as loaded as the origin", func(t *testing.T) {
+		rt, _ := setup(t)
+		actor.rebalanceSectors(rt, 0, lastDeadline, bitfield.NewFromSet([]uint64{0}), 10)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "cannot balance deadline 0", func() {
+			actor.rebalanceSectors(rt, 0, lastDeadline, bitfield.NewFromSet([]uint64{0}), 10)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to move sectors within a deadline", func(t *testing.T) {
+		rt, _ := setup(t)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "within deadline 0", func() {
+			actor.rebalanceSectors(rt, 0, 0, bitfield.NewFromSet([]uint64{0}), 10)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
 func TestCheckSectorProven(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 
@@ -2587,36 +3976,86 @@ func TestCheckSectorProven(t *testing.T) {
 	})
 }
 
-func TestChangeMultiAddrs(t *testing.T) {
+func TestFindSector(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 
-	t.Run("successfully change multiaddrs", func(t *testing.T) {
+	t.Run("reports deadline and partition of a proven sector", func(t *testing.T) {
 		actor := newHarness(t, periodOffset)
-		builder := builderForHarness(actor).
-			WithBalance(bigBalance, big.Zero())
-		rt := builder.Build(t)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
 		actor.constructAndVerify(rt)
 
-		addr1 := abi.Multiaddrs([]byte("addr1"))
-		addr2 := abi.Multiaddrs([]byte("addr2"))
+		sectors := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		sno := sectors[0].SectorNumber
 
-		actor.changeMultiAddrs(rt, []abi.Multiaddrs{addr1, addr2})
+		st := getState(rt)
+		expectedDl, expectedPart, err := st.FindSector(rt.AdtStore(), sno)
+		require.NoError(t, err)
+
+		ret := actor.callFindSector(rt, sno)
+		assert.Equal(t, expectedDl, ret.Deadline)
+		assert.Equal(t, expectedPart, ret.Partition)
 		actor.checkState(rt)
 	})
 
-	t.Run("clear multiaddrs by passing in empty slice", func(t *testing.T) {
+	t.Run("fails if sector is not found", func(t *testing.T) {
 		actor := newHarness(t, periodOffset)
-		builder := builderForHarness(actor).
-			WithBalance(bigBalance, big.Zero())
-		rt := builder.Build(t)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
 		actor.constructAndVerify(rt)
 
-		actor.changeMultiAddrs(rt, nil)
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			actor.callFindSector(rt, abi.SectorNumber(1))
+		})
 		actor.checkState(rt)
 	})
-}
 
-func TestChangeWorkerAddress(t *testing.T) {
+	t.Run("fails if sector number is out of range", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.callFindSector(rt, abi.MaxSectorNumber+1)
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestChangeMultiAddrs(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+
+	t.Run("successfully change multiaddrs", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		builder := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero())
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		addr1 := abi.Multiaddrs([]byte("addr1"))
+		addr2 := abi.Multiaddrs([]byte("addr2"))
+
+		actor.changeMultiAddrs(rt, []abi.Multiaddrs{addr1, addr2})
+		actor.checkState(rt)
+	})
+
+	t.Run("clear multiaddrs by passing in empty slice", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		builder := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero())
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		actor.changeMultiAddrs(rt, nil)
+		actor.checkState(rt)
+	})
+}
+
+func TestChangeWorkerAddress(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 
 	setupFunc := func() (*mock.Runtime, *actorHarness) {
@@ -2643,17 +4082,18 @@ func TestChangeWorkerAddress(t *testing.T) {
 		actor.changeWorkerAddress(rt, newWorker, effectiveEpoch, originalControlAddrs)
 
 		// assert change has been made in state
-		info := actor.getInfo(rt)
-		assert.Equal(t, info.PendingWorkerKey.NewWorker, newWorker)
-		assert.Equal(t, info.PendingWorkerKey.EffectiveAt, effectiveEpoch)
+		pending := actor.getPendingWorkerKeys(rt)
+		require.Len(t, pending, 1)
+		assert.Equal(t, pending[0].NewWorker, newWorker)
+		assert.Equal(t, pending[0].EffectiveAt, effectiveEpoch)
 
 		// no change if current epoch is less than effective epoch
 		st := getState(rt)
 		deadline := st.DeadlineInfo(rt.Epoch())
 		rt.SetEpoch(deadline.PeriodEnd())
 
-		info = actor.getInfo(rt)
-		require.NotNil(t, info.PendingWorkerKey)
+		info := actor.getInfo(rt)
+		require.NotNil(t, info.PendingWorkerKeys)
 		require.EqualValues(t, actor.worker, info.Worker)
 
 		// move to deadline containing effectiveEpoch
@@ -2672,7 +4112,7 @@ func TestChangeWorkerAddress(t *testing.T) {
 		actor.checkState(rt)
 	})
 
-	t.Run("change cannot be overridden", func(t *testing.T) {
+	t.Run("changes are queued and take effect in order", func(t *testing.T) {
 		rt, actor := setupFunc()
 		actor.constructAndVerify(rt)
 		originalControlAddrs := actor.controlAddrs
@@ -2684,28 +4124,95 @@ func TestChangeWorkerAddress(t *testing.T) {
 		currentEpoch := abi.ChainEpoch(2970)
 		rt.SetEpoch(currentEpoch)
 
-		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
-		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch, originalControlAddrs)
+		effectiveEpoch1 := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch1, originalControlAddrs)
 
-		// no change if current epoch is less than effective epoch
 		st := getState(rt)
 		deadline := st.DeadlineInfo(rt.Epoch())
 		rt.SetEpoch(deadline.PeriodEnd())
 
-		// attempt to change address again
-		actor.changeWorkerAddress(rt, newWorker2, rt.Epoch()+miner.WorkerKeyChangeDelay, originalControlAddrs)
+		// change address again, queued after the first
+		effectiveEpoch2 := rt.Epoch() + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker2, effectiveEpoch2, originalControlAddrs)
 
-		// assert change has not been modified
-		info := actor.getInfo(rt)
-		assert.Equal(t, info.PendingWorkerKey.NewWorker, newWorker1)
-		assert.Equal(t, info.PendingWorkerKey.EffectiveAt, effectiveEpoch)
+		pending := actor.getPendingWorkerKeys(rt)
+		require.Len(t, pending, 2)
+		assert.Equal(t, miner.WorkerKeyChange{NewWorker: newWorker1, EffectiveAt: effectiveEpoch1}, pending[0])
+		assert.Equal(t, miner.WorkerKeyChange{NewWorker: newWorker2, EffectiveAt: effectiveEpoch2}, pending[1])
 
-		rt.SetEpoch(effectiveEpoch)
+		// first change is effected, second remains pending
+		rt.SetEpoch(effectiveEpoch1)
 		actor.confirmUpdateWorkerKey(rt)
+		info := actor.getInfo(rt)
+		assert.Equal(t, newWorker1, info.Worker)
+		assert.Len(t, actor.getPendingWorkerKeys(rt), 1)
 
-		// assert original change is effected
+		rt.SetEpoch(effectiveEpoch2)
+		actor.confirmUpdateWorkerKey(rt)
 		info = actor.getInfo(rt)
-		assert.Equal(t, newWorker1, info.Worker)
+		assert.Equal(t, newWorker2, info.Worker)
+		assert.Nil(t, info.PendingWorkerKeys)
+		actor.checkState(rt)
+	})
+
+	t.Run("change to the worker already pending is not queued", func(t *testing.T) {
+		rt, actor := setupFunc()
+		actor.constructAndVerify(rt)
+
+		newWorker := tutil.NewIDAddr(t, 999)
+		currentEpoch := abi.ChainEpoch(2970)
+		rt.SetEpoch(currentEpoch)
+
+		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker, effectiveEpoch, actor.controlAddrs)
+		rt.SetEpoch(currentEpoch + 1)
+		actor.changeWorkerAddress(rt, newWorker, effectiveEpoch+1, actor.controlAddrs)
+
+		pending := actor.getPendingWorkerKeys(rt)
+		require.Len(t, pending, 1)
+		assert.Equal(t, effectiveEpoch, pending[0].EffectiveAt)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if too many changes are pending", func(t *testing.T) {
+		rt, actor := setupFunc()
+		actor.constructAndVerify(rt)
+
+		currentEpoch := abi.ChainEpoch(2970)
+		for i := 0; i < miner.MaxPendingWorkerKeyChanges; i++ {
+			rt.SetEpoch(currentEpoch + abi.ChainEpoch(i))
+			actor.changeWorkerAddress(rt, tutil.NewIDAddr(t, uint64(1000+i)), rt.Epoch()+miner.WorkerKeyChangeDelay, actor.controlAddrs)
+		}
+		require.Len(t, actor.getPendingWorkerKeys(rt), miner.MaxPendingWorkerKeyChanges)
+
+		rt.SetEpoch(rt.Epoch() + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too many pending worker key changes", func() {
+			actor.changeWorkerAddress(rt, tutil.NewIDAddr(t, 2000), rt.Epoch()+miner.WorkerKeyChangeDelay, actor.controlAddrs)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("cron effects the change at its effective epoch", func(t *testing.T) {
+		rt, actor := setupFunc()
+		actor.constructAndVerify(rt)
+
+		newWorker := tutil.NewIDAddr(t, 999)
+		currentEpoch := abi.ChainEpoch(2970)
+		rt.SetEpoch(currentEpoch)
+		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker, effectiveEpoch, actor.controlAddrs)
+
+		// The change is not effected by a callback before its effective epoch.
+		rt.SetEpoch(effectiveEpoch - 1)
+		actor.onWorkerKeyChangeCron(rt)
+		assert.Equal(t, actor.worker, actor.getInfo(rt).Worker)
+
+		rt.SetEpoch(effectiveEpoch)
+		actor.onWorkerKeyChangeCron(rt)
+		info := actor.getInfo(rt)
+		assert.Equal(t, newWorker, info.Worker)
+		assert.Nil(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 
@@ -2729,7 +4236,7 @@ func TestChangeWorkerAddress(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, actor.worker, info.Worker)
-		require.Nil(t, info.PendingWorkerKey)
+		require.Nil(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 
@@ -2887,6 +4394,65 @@ func TestChangeWorkerAddress(t *testing.T) {
 	})
 }
 
+func TestDeferredCronEventPayloads(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	newWorker := tutil.NewIDAddr(t, 999)
+	currentEpoch := abi.ChainEpoch(2970)
+	effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
+
+	// Sets up a worker key change to be effected by cron at effectiveEpoch.
+	setupFunc := func() (*mock.Runtime, *actorHarness) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetEpoch(currentEpoch)
+		actor.changeWorkerAddress(rt, newWorker, effectiveEpoch, actor.controlAddrs)
+		rt.SetEpoch(effectiveEpoch)
+		return rt, actor
+	}
+
+	t.Run("processes a legacy payload without a version", func(t *testing.T) {
+		rt, actor := setupFunc()
+
+		actor.onDeferredCronEvent(rt, &miner0.CronEventPayload{EventType: miner.CronEventWorkerKeyChange})
+		assert.Equal(t, newWorker, actor.getInfo(rt).Worker)
+		assert.Equal(t, uint64(0), actor.droppedCronEvents(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("drops a payload of an unknown version", func(t *testing.T) {
+		rt, actor := setupFunc()
+
+		actor.onDeferredCronEvent(rt, &miner.CronEventPayload{
+			EventType: miner.CronEventWorkerKeyChange,
+			Version:   miner.CronEventPayloadVersion + 1,
+		})
+		assert.Equal(t, actor.worker, actor.getInfo(rt).Worker)
+		assert.Equal(t, uint64(1), actor.droppedCronEvents(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("drops a payload of an unknown event type", func(t *testing.T) {
+		rt, actor := setupFunc()
+
+		actor.onDeferredCronEvent(rt, &miner.CronEventPayload{
+			EventType: miner.CronEventType(99),
+			Version:   miner.CronEventPayloadVersion,
+		})
+		assert.Equal(t, uint64(1), actor.droppedCronEvents(rt))
+
+		// dropped events accumulate
+		actor.onDeferredCronEventRaw(rt, []byte{0xff})
+		assert.Equal(t, uint64(2), actor.droppedCronEvents(rt))
+
+		assert.Equal(t, actor.worker, actor.getInfo(rt).Worker)
+		actor.checkState(rt)
+	})
+}
+
 func TestConfirmUpdateWorkerKey(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	newWorker := tutil.NewIDAddr(t, 999)
@@ -2911,7 +4477,7 @@ func TestConfirmUpdateWorkerKey(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, info.Worker, newWorker)
-		require.Nil(t, info.PendingWorkerKey)
+		require.Nil(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 
@@ -2931,7 +4497,7 @@ func TestConfirmUpdateWorkerKey(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, actor.worker, info.Worker)
-		require.NotNil(t, info.PendingWorkerKey)
+		require.NotNil(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 
@@ -2946,129 +4512,358 @@ func TestConfirmUpdateWorkerKey(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, actor.worker, info.Worker)
-		require.Nil(t, info.PendingWorkerKey)
+		require.Nil(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 }
 
-func TestChangeOwnerAddress(t *testing.T) {
+func TestCancelWorkerChange(t *testing.T) {
 	actor := newHarness(t, 0)
 	builder := builderForHarness(actor).
 		WithBalance(bigBalance, big.Zero())
-	newAddr := tutil.NewIDAddr(t, 1001)
-	otherAddr := tutil.NewIDAddr(t, 1002)
 
-	t.Run("successful change", func(t *testing.T) {
+	newWorker1 := tutil.NewIDAddr(t, 999)
+	newWorker2 := tutil.NewIDAddr(t, 1023)
+	currentEpoch := abi.ChainEpoch(5)
+
+	t.Run("cancels a pending change", func(t *testing.T) {
 		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
 		actor.constructAndVerify(rt)
 
-		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, newAddr)
+		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch, actor.controlAddrs)
+		actor.cancelWorkerChange(rt, effectiveEpoch)
 
 		info := actor.getInfo(rt)
-		assert.Equal(t, actor.owner, info.Owner)
-		assert.Equal(t, newAddr, *info.PendingOwnerAddress)
-
-		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, newAddr)
+		require.Nil(t, info.PendingWorkerKeys)
 
+		rt.SetEpoch(effectiveEpoch)
+		actor.confirmUpdateWorkerKey(rt)
 		info = actor.getInfo(rt)
-		assert.Equal(t, newAddr, info.Owner)
-		assert.Nil(t, info.PendingOwnerAddress)
+		require.Equal(t, actor.worker, info.Worker)
+		actor.checkState(rt)
 	})
 
-	t.Run("proposed must be valid", func(t *testing.T) {
+	t.Run("later changes remain pending", func(t *testing.T) {
 		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
 		actor.constructAndVerify(rt)
 
-		nominees := []addr.Address{
-			addr.Undef,
-			tutil.NewSECP256K1Addr(t, "asd"),
-			tutil.NewBLSAddr(t, 1234),
-			tutil.NewActorAddr(t, "asd"),
-		}
-		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
-		for _, a := range nominees {
-			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-				actor.changeOwnerAddress(rt, a)
-			})
-		}
+		effectiveEpoch1 := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch1, actor.controlAddrs)
+		rt.SetEpoch(currentEpoch + 1)
+		effectiveEpoch2 := rt.Epoch() + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker2, effectiveEpoch2, actor.controlAddrs)
+
+		actor.cancelWorkerChange(rt, effectiveEpoch1)
+		pending := actor.getPendingWorkerKeys(rt)
+		require.Equal(t, []miner.WorkerKeyChange{{NewWorker: newWorker2, EffectiveAt: effectiveEpoch2}}, pending)
+
+		rt.SetEpoch(effectiveEpoch2)
+		actor.confirmUpdateWorkerKey(rt)
+		info := actor.getInfo(rt)
+		require.Equal(t, newWorker2, info.Worker)
+		actor.checkState(rt)
 	})
 
-	t.Run("withdraw proposal", func(t *testing.T) {
+	t.Run("later change back to the worker is dropped", func(t *testing.T) {
 		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
 		actor.constructAndVerify(rt)
 
-		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, newAddr)
-
-		// Revert it
-		actor.changeOwnerAddress(rt, actor.owner)
+		effectiveEpoch1 := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch1, actor.controlAddrs)
+		rt.SetEpoch(currentEpoch + 1)
+		actor.changeWorkerAddress(rt, actor.worker, rt.Epoch()+miner.WorkerKeyChangeDelay, actor.controlAddrs)
+		require.Len(t, actor.getPendingWorkerKeys(rt), 2)
 
+		actor.cancelWorkerChange(rt, effectiveEpoch1)
 		info := actor.getInfo(rt)
-		assert.Equal(t, actor.owner, info.Owner)
-		assert.Nil(t, info.PendingOwnerAddress)
+		require.Nil(t, info.PendingWorkerKeys)
+		actor.checkState(rt)
+	})
 
-		// New address cannot confirm.
-		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
-		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
-			actor.changeOwnerAddress(rt, newAddr)
+	t.Run("fails if no change is pending at the epoch", func(t *testing.T) {
+		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
+		actor.constructAndVerify(rt)
+
+		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch, actor.controlAddrs)
+
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			actor.cancelWorkerChange(rt, effectiveEpoch+1)
 		})
+		rt.Reset()
+		actor.checkState(rt)
 	})
 
-	t.Run("only owner can propose", func(t *testing.T) {
+	t.Run("fails if the change has already taken effect", func(t *testing.T) {
 		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
 		actor.constructAndVerify(rt)
 
-		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
-		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
-			actor.changeOwnerAddress(rt, newAddr)
-		})
-		rt.SetCaller(otherAddr, builtin.MultisigActorCodeID)
-		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
-			actor.changeOwnerAddress(rt, newAddr)
+		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch, actor.controlAddrs)
+
+		rt.SetEpoch(effectiveEpoch)
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			actor.cancelWorkerChange(rt, effectiveEpoch)
 		})
+		rt.Reset()
+		actor.checkState(rt)
 	})
 
-	t.Run("only owner can change proposal", func(t *testing.T) {
+	t.Run("fails if caller is not the owner", func(t *testing.T) {
 		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
 		actor.constructAndVerify(rt)
 
-		// Make a proposal
-		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, newAddr)
+		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch, actor.controlAddrs)
 
+		rt.ExpectValidateCallerAddr(actor.owner)
 		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
 		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
-			actor.changeOwnerAddress(rt, otherAddr)
-		})
-		rt.SetCaller(otherAddr, builtin.MultisigActorCodeID)
-		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
-			actor.changeOwnerAddress(rt, otherAddr)
+			rt.Call(actor.a.CancelWorkerChange, &miner.CancelWorkerChangeParams{EffectiveAt: effectiveEpoch})
 		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
 
-		// Owner can change it
-		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, otherAddr)
-		info := actor.getInfo(rt)
-		assert.Equal(t, actor.owner, info.Owner)
-		assert.Equal(t, otherAddr, *info.PendingOwnerAddress)
+func TestControlChangeHistory(t *testing.T) {
+	actor := newHarness(t, 0)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	newWorker1 := tutil.NewIDAddr(t, 999)
+	newWorker2 := tutil.NewIDAddr(t, 1023)
+	newOwner := tutil.NewIDAddr(t, 1001)
+	otherOwner := tutil.NewIDAddr(t, 1002)
+	currentEpoch := abi.ChainEpoch(5)
 
+	t.Run("empty before any change", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		assert.Empty(t, actor.getControlChangeHistory(rt))
+		actor.checkState(rt)
 	})
 
-	t.Run("only nominee can confirm", func(t *testing.T) {
+	t.Run("records owner proposal, revocation and confirmation", func(t *testing.T) {
 		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
 		actor.constructAndVerify(rt)
 
-		// Make a proposal
 		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, newAddr)
+		// Proposing the current owner with nothing pending is not recorded.
+		actor.changeOwnerAddress(rt, actor.owner)
+		actor.changeOwnerAddress(rt, otherOwner)
+		rt.SetEpoch(currentEpoch + 1)
+		actor.changeOwnerAddress(rt, actor.owner)
+		rt.SetEpoch(currentEpoch + 2)
+		actor.changeOwnerAddress(rt, newOwner)
+		rt.SetEpoch(currentEpoch + 3)
+		rt.SetCaller(newOwner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newOwner)
+
+		assert.Equal(t, []miner.ControlChangeEvent{
+			{Kind: miner.OwnerChangeProposed, Address: otherOwner, Epoch: currentEpoch, EffectiveAt: currentEpoch},
+			{Kind: miner.OwnerChangeRevoked, Address: otherOwner, Epoch: currentEpoch + 1, EffectiveAt: currentEpoch + 1},
+			{Kind: miner.OwnerChangeProposed, Address: newOwner, Epoch: currentEpoch + 2, EffectiveAt: currentEpoch + 2},
+			{Kind: miner.OwnerChangeConfirmed, Address: newOwner, Epoch: currentEpoch + 3, EffectiveAt: currentEpoch + 3},
+		}, actor.getControlChangeHistory(rt))
+		actor.checkState(rt)
+	})
 
-		// Owner re-proposing same address doesn't confirm it.
-		actor.changeOwnerAddress(rt, newAddr)
-		info := actor.getInfo(rt)
-		assert.Equal(t, actor.owner, info.Owner)
-		assert.Equal(t, newAddr, *info.PendingOwnerAddress) // Still staged
+	t.Run("records worker proposal, cancellation and confirmation", func(t *testing.T) {
+		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
+		actor.constructAndVerify(rt)
+
+		effectiveEpoch1 := currentEpoch + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch1, actor.controlAddrs)
+		rt.SetEpoch(currentEpoch + 1)
+		effectiveEpoch2 := rt.Epoch() + miner.WorkerKeyChangeDelay
+		actor.changeWorkerAddress(rt, newWorker2, effectiveEpoch2, actor.controlAddrs)
+		rt.SetEpoch(currentEpoch + 2)
+		actor.cancelWorkerChange(rt, effectiveEpoch1)
+
+		rt.SetEpoch(effectiveEpoch2 + 1)
+		actor.confirmUpdateWorkerKey(rt)
+
+		assert.Equal(t, []miner.ControlChangeEvent{
+			{Kind: miner.WorkerChangeProposed, Address: newWorker1, Epoch: currentEpoch, EffectiveAt: effectiveEpoch1},
+			{Kind: miner.WorkerChangeProposed, Address: newWorker2, Epoch: currentEpoch + 1, EffectiveAt: effectiveEpoch2},
+			{Kind: miner.WorkerChangeCancelled, Address: newWorker1, Epoch: currentEpoch + 2, EffectiveAt: effectiveEpoch1},
+			{Kind: miner.WorkerChangeConfirmed, Address: newWorker2, Epoch: effectiveEpoch2 + 1, EffectiveAt: effectiveEpoch2},
+		}, actor.getControlChangeHistory(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("change of worker to the current worker is not recorded", func(t *testing.T) {
+		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
+		actor.constructAndVerify(rt)
+
+		actor.changeWorkerAddress(rt, actor.worker, currentEpoch+miner.WorkerKeyChangeDelay, actor.controlAddrs)
+		assert.Empty(t, actor.getControlChangeHistory(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("keeps only the most recent events", func(t *testing.T) {
+		rt := builder.Build(t)
+		rt.SetEpoch(currentEpoch)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		for i := 0; i < miner.MaxControlChangeHistory; i++ {
+			rt.SetEpoch(currentEpoch + abi.ChainEpoch(i))
+			if i%2 == 0 {
+				actor.changeOwnerAddress(rt, newOwner)
+			} else {
+				actor.changeOwnerAddress(rt, actor.owner)
+			}
+		}
+		history := actor.getControlChangeHistory(rt)
+		require.Len(t, history, miner.MaxControlChangeHistory)
+		assert.Equal(t, currentEpoch, history[0].Epoch)
+
+		rt.SetEpoch(currentEpoch + miner.MaxControlChangeHistory)
+		actor.changeOwnerAddress(rt, otherOwner)
+		history = actor.getControlChangeHistory(rt)
+		require.Len(t, history, miner.MaxControlChangeHistory)
+		assert.Equal(t, currentEpoch+1, history[0].Epoch)
+		assert.Equal(t, miner.ControlChangeEvent{
+			Kind:        miner.OwnerChangeProposed,
+			Address:     otherOwner,
+			Epoch:       currentEpoch + miner.MaxControlChangeHistory,
+			EffectiveAt: currentEpoch + miner.MaxControlChangeHistory,
+		}, history[len(history)-1])
+		actor.checkState(rt)
+	})
+}
+
+func TestChangeOwnerAddress(t *testing.T) {
+	actor := newHarness(t, 0)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+	newAddr := tutil.NewIDAddr(t, 1001)
+	otherAddr := tutil.NewIDAddr(t, 1002)
+
+	t.Run("successful change", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newAddr)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Owner)
+		assert.Equal(t, newAddr, *info.PendingOwnerAddress)
+
+		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newAddr)
+
+		info = actor.getInfo(rt)
+		assert.Equal(t, newAddr, info.Owner)
+		assert.Nil(t, info.PendingOwnerAddress)
+	})
+
+	t.Run("proposed must be valid", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		nominees := []addr.Address{
+			addr.Undef,
+			tutil.NewSECP256K1Addr(t, "asd"),
+			tutil.NewBLSAddr(t, 1234),
+			tutil.NewActorAddr(t, "asd"),
+		}
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		for _, a := range nominees {
+			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+				actor.changeOwnerAddress(rt, a)
+			})
+		}
+	})
+
+	t.Run("withdraw proposal", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newAddr)
+
+		// Revert it
+		actor.changeOwnerAddress(rt, actor.owner)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Owner)
+		assert.Nil(t, info.PendingOwnerAddress)
+
+		// New address cannot confirm.
+		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.changeOwnerAddress(rt, newAddr)
+		})
+	})
+
+	t.Run("only owner can propose", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.changeOwnerAddress(rt, newAddr)
+		})
+		rt.SetCaller(otherAddr, builtin.MultisigActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.changeOwnerAddress(rt, newAddr)
+		})
+	})
+
+	t.Run("only owner can change proposal", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		// Make a proposal
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newAddr)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.changeOwnerAddress(rt, otherAddr)
+		})
+		rt.SetCaller(otherAddr, builtin.MultisigActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.changeOwnerAddress(rt, otherAddr)
+		})
+
+		// Owner can change it
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, otherAddr)
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Owner)
+		assert.Equal(t, otherAddr, *info.PendingOwnerAddress)
+
+	})
+
+	t.Run("only nominee can confirm", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		// Make a proposal
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newAddr)
+
+		// Owner re-proposing same address doesn't confirm it.
+		actor.changeOwnerAddress(rt, newAddr)
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Owner)
+		assert.Equal(t, newAddr, *info.PendingOwnerAddress) // Still staged
 
 		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
 		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
@@ -3079,61 +4874,731 @@ func TestChangeOwnerAddress(t *testing.T) {
 			actor.changeOwnerAddress(rt, otherAddr)
 		})
 
-		// New addr can confirm itself
-		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, newAddr)
-		info = actor.getInfo(rt)
-		assert.Equal(t, newAddr, info.Owner)
-		assert.Nil(t, info.PendingOwnerAddress)
+		// New addr can confirm itself
+		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newAddr)
+		info = actor.getInfo(rt)
+		assert.Equal(t, newAddr, info.Owner)
+		assert.Nil(t, info.PendingOwnerAddress)
+	})
+
+	t.Run("nominee must confirm self explicitly", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		// Make a proposal
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, newAddr)
+
+		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.changeOwnerAddress(rt, actor.owner) // Not own address
+		})
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.changeOwnerAddress(rt, otherAddr) // Not own address
+		})
+	})
+}
+
+func TestChangeBeneficiary(t *testing.T) {
+	actor := newHarness(t, 0)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+	beneficiary := tutil.NewIDAddr(t, 1001)
+	otherAddr := tutil.NewIDAddr(t, 1002)
+	quota := abi.NewTokenAmount(1000)
+	expiration := abi.ChainEpoch(1000)
+
+	t.Run("owner proposes and nominee confirms", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		ret := actor.getBeneficiary(rt)
+		assert.Equal(t, actor.owner, ret.Active.Beneficiary)
+		assert.Nil(t, ret.Proposed)
+
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration}
+		actor.changeBeneficiary(rt, actor.owner, params)
+
+		// The owner has no term to protect, so only the nominee's approval is outstanding.
+		ret = actor.getBeneficiary(rt)
+		assert.Equal(t, actor.owner, ret.Active.Beneficiary)
+		require.NotNil(t, ret.Proposed)
+		assert.Equal(t, miner.PendingBeneficiaryChange{
+			NewBeneficiary:        beneficiary,
+			NewQuota:              quota,
+			NewExpiration:         expiration,
+			ApprovedByBeneficiary: true,
+			ApprovedByNominee:     false,
+		}, *ret.Proposed)
+
+		actor.changeBeneficiary(rt, beneficiary, params)
+		ret = actor.getBeneficiary(rt)
+		assert.Equal(t, beneficiary, ret.Active.Beneficiary)
+		assert.Equal(t, miner.BeneficiaryTerm{Quota: quota, UsedQuota: big.Zero(), Expiration: expiration}, ret.Active.Term)
+		assert.Nil(t, ret.Proposed)
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary with remaining quota must approve a change", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.setBeneficiary(rt, beneficiary, quota, expiration)
+
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: otherAddr, NewQuota: quota, NewExpiration: expiration}
+		actor.changeBeneficiary(rt, actor.owner, params)
+		actor.changeBeneficiary(rt, otherAddr, params)
+		ret := actor.getBeneficiary(rt)
+		assert.Equal(t, beneficiary, ret.Active.Beneficiary)
+		require.NotNil(t, ret.Proposed)
+		assert.False(t, ret.Proposed.ApprovedByBeneficiary)
+		assert.True(t, ret.Proposed.ApprovedByNominee)
+
+		actor.changeBeneficiary(rt, beneficiary, params)
+		ret = actor.getBeneficiary(rt)
+		assert.Equal(t, otherAddr, ret.Active.Beneficiary)
+		assert.Nil(t, ret.Proposed)
+		actor.checkState(rt)
+	})
+
+	t.Run("return to owner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.setBeneficiary(rt, beneficiary, quota, expiration)
+
+		// While the term has quota remaining, the beneficiary must approve.
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: actor.owner, NewQuota: big.Zero(), NewExpiration: 0}
+		actor.changeBeneficiary(rt, actor.owner, params)
+		assert.Equal(t, beneficiary, actor.getBeneficiary(rt).Active.Beneficiary)
+
+		actor.changeBeneficiary(rt, beneficiary, params)
+		ret := actor.getBeneficiary(rt)
+		assert.Equal(t, actor.owner, ret.Active.Beneficiary)
+		assert.Nil(t, ret.Proposed)
+
+		// Once the term expires, the owner may return the role to itself alone.
+		actor.setBeneficiary(rt, beneficiary, quota, expiration)
+		rt.SetEpoch(expiration)
+		actor.changeBeneficiary(rt, actor.owner, params)
+		ret = actor.getBeneficiary(rt)
+		assert.Equal(t, actor.owner, ret.Active.Beneficiary)
+		assert.Nil(t, ret.Proposed)
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary follows change of owner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, otherAddr)
+		rt.SetCaller(otherAddr, builtin.MultisigActorCodeID)
+		actor.changeOwnerAddress(rt, otherAddr)
+
+		assert.Equal(t, otherAddr, actor.getBeneficiary(rt).Active.Beneficiary)
+		actor.checkState(rt)
+	})
+
+	t.Run("invalid proposals are rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		for _, params := range []*miner.ChangeBeneficiaryParams{
+			{NewBeneficiary: beneficiary, NewQuota: big.Zero(), NewExpiration: expiration},
+			{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: rt.Epoch()},
+			{NewBeneficiary: actor.owner, NewQuota: quota, NewExpiration: 0},
+			{NewBeneficiary: actor.owner, NewQuota: big.Zero(), NewExpiration: expiration},
+		} {
+			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+				actor.changeBeneficiary(rt, actor.owner, params)
+			})
+		}
+
+		// Nothing to approve without a proposal.
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration}
+		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+			actor.changeBeneficiary(rt, beneficiary, params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("only the beneficiary and nominee approve the proposal as made", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration}
+		actor.changeBeneficiary(rt, actor.owner, params)
+
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.changeBeneficiary(rt, otherAddr, params)
+		})
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.changeBeneficiary(rt, beneficiary, &miner.ChangeBeneficiaryParams{
+				NewBeneficiary: beneficiary, NewQuota: big.Add(quota, big.NewInt(1)), NewExpiration: expiration,
+			})
+		})
+		assert.Equal(t, actor.owner, actor.getBeneficiary(rt).Active.Beneficiary)
+		actor.checkState(rt)
+	})
+}
+
+func TestChangeOwnerSettings(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	setup := func(t *testing.T, settings miner.OwnerSettings) *mock.Runtime {
+		rt := builder.Build(t)
+		rt.SetEpoch(periodOffset + 1)
+		actor.constructAndVerify(rt)
+		actor.changeOwnerSettings(rt, &settings)
+		return rt
+	}
+
+	makePreCommits := func(rt *mock.Runtime, count int) []miner0.SectorPreCommitInfo {
+		expiration := actor.deadline(rt).PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		var sectors []miner0.SectorPreCommitInfo
+		for i := 0; i < count; i++ {
+			sectors = append(sectors, *actor.makePreCommit(abi.SectorNumber(100+i), rt.Epoch()-1, expiration, nil))
+		}
+		return sectors
+	}
+
+	t.Run("owner changes settings", func(t *testing.T) {
+		settings := miner.OwnerSettings{MinPreCommitBatchSize: 4, MaxSectorsPerMessage: 10, MaxBatchNetworkFee: big.NewInt(1000)}
+		rt := setup(t, settings)
+		assert.Equal(t, settings, getState(rt).GetOwnerSettings())
+		actor.checkState(rt)
+	})
+
+	t.Run("only owner may change settings", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.ChangeOwnerSettings, &miner.OwnerSettings{MinPreCommitBatchSize: 4, MaxBatchNetworkFee: big.Zero()})
+		})
+		rt.Reset()
+		assert.Nil(t, getState(rt).OwnerSettings)
+	})
+
+	t.Run("invalid settings are rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+
+		for _, settings := range []miner.OwnerSettings{
+			{MinPreCommitBatchSize: miner.PreCommitSectorBatchMaxSize + 1, MaxBatchNetworkFee: big.Zero()},
+			{MinPreCommitBatchSize: 5, MaxSectorsPerMessage: 4, MaxBatchNetworkFee: big.Zero()},
+			{MaxBatchNetworkFee: big.NewInt(-1)},
+		} {
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid owner settings", func() {
+				rt.Call(actor.a.ChangeOwnerSettings, &settings)
+			})
+			rt.Reset()
+		}
+	})
+
+	t.Run("pre-commit batch below minimum is refused", func(t *testing.T) {
+		rt := setup(t, miner.OwnerSettings{MinPreCommitBatchSize: 3, MaxBatchNetworkFee: big.Zero()})
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "below owner minimum of 3", func() {
+			actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: makePreCommits(rt, 2)}, preCommitBatchConf{firstForMiner: true}, big.Zero())
+		})
+		rt.Reset()
+
+		// A batch meeting the minimum, and a single pre-commitment, are accepted.
+		actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: makePreCommits(rt, 3)}, preCommitBatchConf{firstForMiner: true}, big.Zero())
+		expiration := actor.deadline(rt).PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+		actor.preCommitSector(rt, actor.makePreCommit(200, rt.Epoch()-1, expiration, nil), preCommitConf{}, false)
+		actor.checkState(rt)
+	})
+
+	t.Run("pre-commit batch above maximum sectors or fee is refused", func(t *testing.T) {
+		fee := miner.AggregatePreCommitNetworkFee(3, big.Zero())
+		rt := setup(t, miner.OwnerSettings{MaxSectorsPerMessage: 3, MaxBatchNetworkFee: fee})
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "exceeds owner maximum of 3 per message", func() {
+			actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: makePreCommits(rt, 4)}, preCommitBatchConf{firstForMiner: true}, big.Zero())
+		})
+		rt.Reset()
+
+		// The fee for the same number of sectors rises with the base fee.
+		baseFee := big.Mul(big.NewInt(2), miner.BatchBalancer)
+		rt.SetBaseFee(baseFee)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "network fee", func() {
+			actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: makePreCommits(rt, 3)}, preCommitBatchConf{firstForMiner: true}, baseFee)
+		})
+		rt.Reset()
+
+		rt.SetBaseFee(big.Zero())
+		actor.preCommitSectorBatch(rt, &miner.PreCommitSectorBatchParams{Sectors: makePreCommits(rt, 3)}, preCommitBatchConf{firstForMiner: true}, big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("aggregate above maximum sectors is refused", func(t *testing.T) {
+		rt := setup(t, miner.OwnerSettings{MaxSectorsPerMessage: miner.MinAggregatedSectors, MaxBatchNetworkFee: big.Zero()})
+
+		sectorNos := bitfield.NewFromSet([]uint64{100, 101, 102, 103, 104})
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "exceeds owner maximum", func() {
+			rt.Call(actor.a.ProveCommitAggregate, makeProveCommitAggregate(sectorNos))
+		})
+		rt.Reset()
+	})
+}
+
+func TestSectorManifests(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero()).
+		WithEpoch(periodOffset + 1)
+
+	expiration := func(rt *mock.Runtime) abi.ChainEpoch {
+		return actor.deadline(rt).PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod
+	}
+
+	t.Run("manifest recorded at pre-commit", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		manifest := tutil.MakeCID("manifest", nil)
+		params := &miner.PreCommitSectorBatchParams{
+			Sectors: []miner0.SectorPreCommitInfo{
+				*actor.makePreCommit(100, rt.Epoch()-1, expiration(rt), nil),
+				*actor.makePreCommit(101, rt.Epoch()-1, expiration(rt), nil),
+			},
+		}
+		actor.preCommitSectorBatch(rt, params, preCommitBatchConf{
+			firstForMiner: true,
+			skipInvalid:   true,
+			manifests:     []miner.SectorManifest{{SectorNumber: 100, Manifest: manifest}},
+		}, big.Zero())
+
+		ret := actor.sectorManifest(rt, 100)
+		require.NotNil(t, ret.Manifest)
+		assert.Equal(t, manifest, *ret.Manifest)
+		assert.Nil(t, actor.sectorManifest(rt, 101).Manifest)
+		actor.checkState(rt)
+	})
+
+	t.Run("invalid manifests are rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		manifest := tutil.MakeCID("manifest", nil)
+		for _, tc := range []struct {
+			manifests []miner.SectorManifest
+			msg       string
+		}{
+			{[]miner.SectorManifest{{SectorNumber: 102, Manifest: manifest}}, "not in batch"},
+			{[]miner.SectorManifest{{SectorNumber: 100, Manifest: manifest}, {SectorNumber: 100, Manifest: manifest}}, "duplicate manifest"},
+			{[]miner.SectorManifest{{SectorNumber: 100}}, "manifest CID undefined"},
+		} {
+			params := &miner.PreCommitSectorBatch2Params{
+				Sectors:   []miner0.SectorPreCommitInfo{*actor.makePreCommit(100, rt.Epoch()-1, expiration(rt), nil)},
+				Manifests: tc.manifests,
+			}
+			rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, tc.msg, func() {
+				rt.Call(actor.a.PreCommitSectorBatch2, params)
+			})
+			rt.Reset()
+		}
+		actor.checkState(rt)
+	})
+
+	t.Run("no manifest for unknown sector", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "sector 100 not pre-committed or active", func() {
+			rt.Call(actor.a.SectorManifest, &miner.SectorManifestParams{SectorNumber: 100})
+		})
+		rt.Reset()
+	})
+}
+
+func TestPartitionExpirations(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("reports on-time and early expirations", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+
+		expirations := actor.partitionExpirations(rt, dlIdx, pIdx)
+		require.Len(t, expirations, 1)
+		assert.Equal(t, uint64(2), expirations[0].OnTimeSectors)
+		assert.Equal(t, uint64(0), expirations[0].EarlySectors)
+
+		// A faulty sector is rescheduled to expire early.
+		actor.declareFaults(rt, sectors[0])
+		expirations = actor.partitionExpirations(rt, dlIdx, pIdx)
+		require.Len(t, expirations, 2)
+		assert.True(t, expirations[0].Epoch < expirations[1].Epoch)
+		assert.Equal(t, uint64(0), expirations[0].OnTimeSectors)
+		assert.Equal(t, uint64(1), expirations[0].EarlySectors)
+		assert.Equal(t, uint64(1), expirations[1].OnTimeSectors)
+
+		// The report matches the partition's queue.
+		_, partition := actor.findSector(rt, sectors[0].SectorNumber)
+		queue := actor.collectPartitionExpirations(rt, partition)
+		for _, expiration := range expirations {
+			es, ok := queue[expiration.Epoch]
+			require.True(t, ok)
+			assert.Equal(t, es.ActivePower, expiration.ActivePower)
+			assert.Equal(t, es.FaultyPower, expiration.FaultyPower)
+		}
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for an invalid deadline or missing partition", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			rt.Call(actor.a.PartitionExpirations, &miner.PartitionExpirationsParams{Deadline: miner.WPoStPeriodDeadlines})
+		})
+		rt.Reset()
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			rt.Call(actor.a.PartitionExpirations, &miner.PartitionExpirationsParams{Deadline: 0, Partition: 0})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestExpirationReminders(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	receiver := tutil.NewIDAddr(t, 1000)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	// Commits and proves sectors, then advances to the first opening of their deadline from which they expire
+	// within the maximum reminder. Returns the sectors, the deadline, their expiration, and the number of proving
+	// periods from the deadline's end to their expiration.
+	setup := func(t *testing.T) (*mock.Runtime, []*miner.SectorOnChainInfo, *dline.Info, abi.ChainEpoch, uint64) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+
+		st := getState(rt)
+		dlIdx, _, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+		dlinfo := advanceToDeadline(rt, actor, dlIdx)
+		expiration := miner.QuantSpecForDeadline(dlinfo).QuantizeUp(sectors[0].Expiration)
+		for expiration-dlinfo.Last() > miner.MaxExpirationReminderPeriods*miner.WPoStProvingPeriod {
+			advanceAndSubmitPoSts(rt, actor, sectors...)
+			dlinfo = advanceToDeadline(rt, actor, dlIdx)
+		}
+		return rt, sectors, dlinfo, expiration, uint64((expiration - dlinfo.Last()) / miner.WPoStProvingPeriod)
+	}
+	// Proves the sectors and runs their deadline's cron.
+	proveAndCron := func(rt *mock.Runtime, sectors []*miner.SectorOnChainInfo, dlinfo *dline.Info, config *cronConfig) {
+		_, pIdx, err := getState(rt).FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+		actor.submitWindowPoSt(rt, dlinfo, []miner.PoStPartition{{Index: pIdx, Skipped: bitfield.New()}}, sectors, nil)
+		advanceDeadline(rt, actor, config)
+	}
+
+	t.Run("receiver is reminded of sectors expiring in the set number of periods", func(t *testing.T) {
+		rt, sectors, dlinfo, expiration, periods := setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+		actor.setExpirationReminder(rt, periods)
+		assert.Equal(t, periods, actor.getInfo(rt).ExpirationReminderPeriods)
+
+		proveAndCron(rt, sectors, dlinfo, &cronConfig{expirationReminder: &miner.SectorsExpiringParams{
+			Sectors:    bitfield.NewFromSet([]uint64{uint64(sectors[0].SectorNumber), uint64(sectors[1].SectorNumber)}),
+			Expiration: expiration,
+		}})
+		actor.checkState(rt)
+	})
+
+	t.Run("receiver failure does not abort cron", func(t *testing.T) {
+		rt, sectors, dlinfo, expiration, periods := setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+		actor.setExpirationReminder(rt, periods)
+
+		proveAndCron(rt, sectors, dlinfo, &cronConfig{
+			expirationReminder: &miner.SectorsExpiringParams{
+				Sectors:    bitfield.NewFromSet([]uint64{uint64(sectors[0].SectorNumber), uint64(sectors[1].SectorNumber)}),
+				Expiration: expiration,
+			},
+			notificationExit: exitcode.ErrForbidden,
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("no reminder for sectors expiring at other than the set number of periods", func(t *testing.T) {
+		rt, sectors, dlinfo, _, periods := setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+		actor.setExpirationReminder(rt, periods-1)
+
+		proveAndCron(rt, sectors, dlinfo, &cronConfig{})
+		actor.checkState(rt)
+	})
+
+	t.Run("no reminder without a receiver or once disabled", func(t *testing.T) {
+		rt, sectors, dlinfo, _, periods := setup(t)
+		actor.setExpirationReminder(rt, periods)
+		proveAndCron(rt, sectors, dlinfo, &cronConfig{})
+
+		rt, sectors, dlinfo, _, periods = setup(t)
+		actor.setNotificationReceiver(rt, &receiver)
+		actor.setExpirationReminder(rt, periods)
+		actor.setExpirationReminder(rt, 0)
+		proveAndCron(rt, sectors, dlinfo, &cronConfig{})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to set a reminder beyond the maximum", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exceeds maximum", func() {
+			rt.Call(actor.a.SetExpirationReminder, &miner.SetExpirationReminderParams{Periods: miner.MaxExpirationReminderPeriods + 1})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
+	t.Run("only the owner may set the reminder", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.SetExpirationReminder, &miner.SetExpirationReminderParams{Periods: 1})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestEstimateDeadlinePenalty(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("estimates fee charged for current faults", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		actor.applyRewards(rt, bigRewards, big.Zero())
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+
+		st := getState(rt)
+		dlIdx, _, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+
+		estimate := actor.estimateDeadlinePenalty(rt, dlIdx)
+		assert.True(t, estimate.FaultyPower.IsZero())
+		assert.Equal(t, big.Zero(), estimate.Penalty)
+
+		actor.declareFaults(rt, sectors[0])
+		estimate = actor.estimateDeadlinePenalty(rt, dlIdx)
+		assert.Equal(t, actor.powerPairForSectors(sectors), estimate.FaultyPower)
+		assert.Equal(t, actor.continuedFaultPenalty(sectors), estimate.Penalty)
+
+		// Other deadlines have no faults.
+		other := actor.estimateDeadlinePenalty(rt, (dlIdx+1)%miner.WPoStPeriodDeadlines)
+		assert.Equal(t, big.Zero(), other.Penalty)
+
+		// The estimated fee is charged when the deadline closes.
+		dlinfo := actor.deadline(rt)
+		for dlinfo.Index != dlIdx {
+			dlinfo = advanceDeadline(rt, actor, &cronConfig{})
+		}
+		assert.Equal(t, dlinfo.Last(), estimate.Epoch)
+		advanceDeadline(rt, actor, &cronConfig{continuedFaultsPenalty: estimate.Penalty})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for an invalid deadline", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			rt.Call(actor.a.EstimateDeadlinePenalty, &miner.EstimateDeadlinePenaltyParams{Deadline: miner.WPoStPeriodDeadlines})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestEstimateAggregateFees(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("evaluates fees at the current base fee", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		baseFee := big.Mul(big.NewInt(7), builtin.OneNanoFIL)
+		rt.SetBaseFee(baseFee)
+
+		ret := actor.estimateAggregateFees(rt, 1, 4, miner.MaxAggregatedSectors)
+		assert.Equal(t, baseFee, ret.BaseFee)
+		require.Len(t, ret.Estimates, 3)
+		assert.Equal(t, miner.AggregateFeeEstimate{
+			BatchSize:               1,
+			PreCommitBatchFee:       big.Zero(),
+			ProveCommitAggregateFee: miner.AggregateProveCommitNetworkFee(1, baseFee),
+		}, ret.Estimates[0])
+		assert.Equal(t, miner.AggregateFeeEstimate{
+			BatchSize:               4,
+			PreCommitBatchFee:       miner.AggregatePreCommitNetworkFee(4, baseFee),
+			ProveCommitAggregateFee: miner.AggregateProveCommitNetworkFee(4, baseFee),
+		}, ret.Estimates[1])
+		assert.Equal(t, uint64(miner.MaxAggregatedSectors), ret.Estimates[2].BatchSize)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for a batch size above the maximum", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exceeds max", func() {
+			rt.Call(actor.a.EstimateAggregateFees, &miner.EstimateAggregateFeesParams{BatchSizes: []uint64{miner.MaxAggregatedSectors + 1}})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestReportConsensusFault(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("invalid report burns reporter bond", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+
+		actor.reportConsensusFault(rt, addr.TestAddress, nil)
+
+		// The miner is not penalized.
+		info := actor.getInfo(rt)
+		assert.Equal(t, abi.ChainEpoch(-1), info.ConsensusFaultElapsed)
+		assert.True(t, getState(rt).FeeDebt.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("report without sufficient bond rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+
+		bond := big.Sub(miner.ConsensusFaultReporterBond, big.NewInt(1))
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "reporter bond", func() {
+			actor.reportConsensusFaultWithBond(rt, addr.TestAddress, &runtime.ConsensusFault{
+				Target: actor.receiver,
+				Epoch:  rt.Epoch() - 1,
+				Type:   runtime.ConsensusFaultDoubleForkMining,
+			}, bond)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("reporter bond is not used to pay the penalty", func(t *testing.T) {
+		rt := builderForHarness(actor).WithBalance(big.Zero(), big.Zero()).Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+
+		bond := miner.ConsensusFaultReporterBond
+		rt.SetBalance(bond)
+		rt.SetReceived(bond)
+		rt.SetCaller(addr.TestAddress, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		params := &miner.ReportConsensusFaultParams{}
+		rt.ExpectVerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  rt.Epoch() - 1,
+			Type:   runtime.ConsensusFaultDoubleForkMining,
+		}, nil)
+		currentReward := reward.ThisEpochRewardReturn{
+			ThisEpochBaselinePower:  actor.baselinePower,
+			ThisEpochRewardSmoothed: actor.epochRewardSmooth,
+		}
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.ThisEpochReward, nil, big.Zero(), &currentReward, exitcode.Ok)
+		// The whole penalty becomes fee debt, so no reward is paid but the bond is returned.
+		rt.ExpectSend(addr.TestAddress, builtin.MethodSend, nil, bond, nil, exitcode.Ok)
+		rt.Call(actor.a.ReportConsensusFault, params)
+		rt.Verify()
+		rt.SetReceived(big.Zero())
+
+		expectedDebt, err := miner.ConsensusFaultPenalty(smoothing.Estimate(&actor.epochRewardSmooth), rt.NetworkVersion())
+		require.NoError(t, err)
+		assert.True(t, expectedDebt.Equals(getState(rt).FeeDebt))
+		assert.True(t, rt.Balance().Equals(big.Zero()))
+		actor.checkState(rt)
 	})
 
-	t.Run("nominee must confirm self explicitly", func(t *testing.T) {
+	t.Run("invalid report refunds bond in excess of that required", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
 
-		// Make a proposal
-		rt.SetCaller(actor.owner, builtin.MultisigActorCodeID)
-		actor.changeOwnerAddress(rt, newAddr)
-
-		rt.SetCaller(newAddr, builtin.MultisigActorCodeID)
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			actor.changeOwnerAddress(rt, actor.owner) // Not own address
-		})
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			actor.changeOwnerAddress(rt, otherAddr) // Not own address
-		})
+		balance := rt.Balance()
+		actor.reportConsensusFaultWithBond(rt, addr.TestAddress, nil, big.Mul(miner.ConsensusFaultReporterBond, big.NewInt(3)))
+		assert.Equal(t, balance, rt.Balance())
+		actor.checkState(rt)
 	})
-}
-
-func TestReportConsensusFault(t *testing.T) {
-	periodOffset := abi.ChainEpoch(100)
-	actor := newHarness(t, periodOffset)
-	builder := builderForHarness(actor).
-		WithBalance(bigBalance, big.Zero())
 
-	t.Run("invalid report rejected", func(t *testing.T) {
+	t.Run("mis-targeted report burns reporter bond", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
 		rt.SetEpoch(abi.ChainEpoch(1))
 
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			actor.reportConsensusFault(rt, addr.TestAddress, nil)
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: tutil.NewIDAddr(t, 1234), // Not receiver
+			Epoch:  rt.Epoch() - 1,
+			Type:   runtime.ConsensusFaultDoubleForkMining,
 		})
+		assert.Equal(t, abi.ChainEpoch(-1), actor.getInfo(rt).ConsensusFaultElapsed)
 		actor.checkState(rt)
 	})
 
-	t.Run("mis-targeted report rejected", func(t *testing.T) {
+	t.Run("report of a fault not yet in the past burns reporter bond", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
 		rt.SetEpoch(abi.ChainEpoch(1))
 
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
-				Target: tutil.NewIDAddr(t, 1234), // Not receiver
-				Epoch:  rt.Epoch() - 1,
-				Type:   runtime.ConsensusFaultDoubleForkMining,
-			})
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  rt.Epoch(),
+			Type:   runtime.ConsensusFaultDoubleForkMining,
 		})
+		assert.Equal(t, abi.ChainEpoch(-1), actor.getInfo(rt).ConsensusFaultElapsed)
 		actor.checkState(rt)
 	})
 
@@ -3173,7 +5638,7 @@ func TestReportConsensusFault(t *testing.T) {
 		actor.checkState(rt)
 	})
 
-	t.Run("Double report of consensus fault fails", func(t *testing.T) {
+	t.Run("Double report of consensus fault refunds reporter bond", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
 		precommitEpoch := abi.ChainEpoch(1)
@@ -3194,27 +5659,27 @@ func TestReportConsensusFault(t *testing.T) {
 		endInfo := actor.getInfo(rt)
 		assert.Equal(t, reportEpoch+miner.ConsensusFaultIneligibilityDuration, endInfo.ConsensusFaultElapsed)
 
-		// same fault can't be reported twice
-		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too old", func() {
-			actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
-				Target: actor.receiver,
-				Epoch:  fault1,
-				Type:   runtime.ConsensusFaultDoubleForkMining,
-			})
+		// same fault can't be reported twice, and the replayed report's bond is refunded
+		balance := rt.Balance()
+		feeDebt := getState(rt).FeeDebt
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  fault1,
+			Type:   runtime.ConsensusFaultDoubleForkMining,
 		})
-		rt.Reset()
+		assert.Equal(t, balance, rt.Balance())
+		assert.Equal(t, feeDebt, getState(rt).FeeDebt)
+		assert.Equal(t, endInfo.ConsensusFaultElapsed, actor.getInfo(rt).ConsensusFaultElapsed)
 
 		// new consensus faults are forbidden until original has elapsed
 		rt.SetEpoch(endInfo.ConsensusFaultElapsed)
 		fault2 := endInfo.ConsensusFaultElapsed - 1
-		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too old", func() {
-			actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
-				Target: actor.receiver,
-				Epoch:  fault2,
-				Type:   runtime.ConsensusFaultDoubleForkMining,
-			})
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  fault2,
+			Type:   runtime.ConsensusFaultDoubleForkMining,
 		})
-		rt.Reset()
+		assert.Equal(t, endInfo.ConsensusFaultElapsed, actor.getInfo(rt).ConsensusFaultElapsed)
 
 		// a new consensus fault can be reported for blocks once original has expired
 		rt.SetEpoch(endInfo.ConsensusFaultElapsed + 1)
@@ -3229,13 +5694,12 @@ func TestReportConsensusFault(t *testing.T) {
 
 		// old fault still cannot be reported after fault interval has elapsed
 		fault4 := fault1 + 1
-		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too old", func() {
-			actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
-				Target: actor.receiver,
-				Epoch:  fault4,
-				Type:   runtime.ConsensusFaultDoubleForkMining,
-			})
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  fault4,
+			Type:   runtime.ConsensusFaultDoubleForkMining,
 		})
+		assert.Equal(t, endInfo.ConsensusFaultElapsed, actor.getInfo(rt).ConsensusFaultElapsed)
 		actor.checkState(rt)
 	})
 }
@@ -3297,7 +5761,7 @@ func TestApplyRewards(t *testing.T) {
 		}
 
 		st = getState(rt)
-		lockedAmt, _ := miner.LockedRewardFromReward(amt)
+		lockedAmt, _ := miner.LockedRewardFromReward(amt, builtin.VestingSpecBlockReward)
 		assert.Equal(t, lockedAmt, st.LockedFunds)
 		// technically applying rewards without first activating cron is an impossible state but convenient for testing
 		_, msgs := miner.CheckStateInvariants(st, rt.AdtStore(), rt.Balance())
@@ -3305,6 +5769,59 @@ func TestApplyRewards(t *testing.T) {
 		assert.Contains(t, msgs.Messages()[0], "DeadlineCronActive == false")
 	})
 
+	t.Run("vesting schedule is queryable", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		assert.Empty(t, actor.getVestingFunds(rt).Vesting)
+
+		actor.applyRewards(rt, abi.NewTokenAmount(600_000), big.Zero())
+		vestingFunds, err := getState(rt).LoadVestingFunds(adt.AsStore(rt))
+		require.NoError(t, err)
+
+		vesting := actor.getVestingFunds(rt).Vesting
+		require.Len(t, vesting, 180)
+		assert.Equal(t, vestingFunds.Funds, vesting)
+	})
+
+	t.Run("funds vest on the schedule of their vesting spec", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		prev := miner.VestingSpecs[builtin.VestingSpecPledgeContribution]
+		defer func() { miner.VestingSpecs[builtin.VestingSpecPledgeContribution] = prev }()
+		miner.VestingSpecs[builtin.VestingSpecPledgeContribution] = &miner.VestSpec{
+			InitialDelay: 0,
+			VestPeriod:   30 * builtin.EpochsInDay,
+			StepDuration: 1 * builtin.EpochsInDay,
+			Quantization: 12 * builtin.EpochsInHour,
+		}
+
+		actor.applyRewardsWithSpec(rt, abi.NewTokenAmount(600_000), big.Zero(), builtin.VestingSpecPledgeContribution)
+		assert.Len(t, actor.getVestingFunds(rt).Vesting, 30)
+
+		actor.applyRewards(rt, abi.NewTokenAmount(600_000), big.Zero())
+		assert.Len(t, actor.getVestingFunds(rt).Vesting, 180)
+	})
+
+	t.Run("fails with unknown vesting spec", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		reward := abi.NewTokenAmount(600_000)
+		rt.SetBalance(big.Add(rt.Balance(), reward))
+		rt.SetCaller(builtin.RewardActorAddr, builtin.RewardActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "unknown vesting spec", func() {
+			rt.Call(actor.a.ApplyRewards, &builtin.ApplyRewardParams{
+				Reward:        reward,
+				Penalty:       big.Zero(),
+				VestingSpecID: builtin.VestingSpecID(99),
+			})
+		})
+		rt.Reset()
+		assert.Empty(t, actor.getVestingFunds(rt).Vesting)
+	})
+
 	t.Run("penalty is burnt", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
@@ -3314,7 +5831,7 @@ func TestApplyRewards(t *testing.T) {
 		rt.SetBalance(big.Add(rt.Balance(), rwd))
 		actor.applyRewards(rt, rwd, penalty)
 
-		expectedLockAmt, _ := miner.LockedRewardFromReward(rwd)
+		expectedLockAmt, _ := miner.LockedRewardFromReward(rwd, builtin.VestingSpecBlockReward)
 		expectedLockAmt = big.Sub(expectedLockAmt, penalty)
 		assert.Equal(t, expectedLockAmt, actor.getLockedFunds(rt))
 
@@ -3389,20 +5906,12 @@ func TestApplyRewards(t *testing.T) {
 
 		// pledge change is new reward - reward taken for fee debt
 		// 3*LockedRewardFactor*amt - 2*amt = remainingLocked
-		lockedReward, _ := miner.LockedRewardFromReward(reward)
+		lockedReward, _ := miner.LockedRewardFromReward(reward, builtin.VestingSpecBlockReward)
 		remainingLocked := big.Sub(lockedReward, st.FeeDebt) // note that this would be clamped at 0 if difference above is < 0
-		pledgeDelta := remainingLocked
 		rt.SetCaller(builtin.RewardActorAddr, builtin.RewardActorCodeID)
 		rt.ExpectValidateCallerAddr(builtin.RewardActorAddr)
 		// expect pledge update
-		rt.ExpectSend(
-			builtin.StoragePowerActorAddr,
-			builtin.MethodsPower.UpdatePledgeTotal,
-			&pledgeDelta,
-			abi.NewTokenAmount(0),
-			nil,
-			exitcode.Ok,
-		)
+		expectUpdatePledgeTotal(rt, big.Zero(), big.Zero(), remainingLocked)
 
 		expectBurnt := st.FeeDebt
 		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectBurnt, nil, exitcode.Ok)
@@ -3454,6 +5963,7 @@ func TestCompactSectorNumbers(t *testing.T) {
 			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
 				actor.preCommitSector(rt, precommit, preCommitConf{}, false)
 			})
+			rt.Reset()
 		}
 
 		{
@@ -3805,6 +6315,18 @@ func (h *actorHarness) changeWorkerAddress(rt *mock.Runtime, newWorker addr.Addr
 	param.NewWorker = newWorker
 	rt.ExpectSend(newWorker, builtin.MethodsAccount.PubkeyAddress, nil, big.Zero(), &h.key, exitcode.Ok)
 
+	// A change is scheduled, with a cron callback to effect it, unless the worker would already be the new one.
+	lastWorker := h.getInfo(rt).Worker
+	for _, change := range h.getPendingWorkerKeys(rt) {
+		if change.EffectiveAt < rt.Epoch()+miner.WorkerKeyChangeDelay {
+			lastWorker = change.NewWorker
+		}
+	}
+	if newWorker != lastWorker {
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent,
+			makeWorkerKeyChangeCronEventParams(h.t, rt.Epoch()+miner.WorkerKeyChangeDelay), big.Zero(), nil, exitcode.Ok)
+	}
+
 	rt.ExpectValidateCallerAddr(h.owner)
 	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
 	rt.Call(h.a.ChangeWorkerAddress, param)
@@ -3831,6 +6353,76 @@ func (h *actorHarness) confirmUpdateWorkerKey(rt *mock.Runtime) {
 	rt.Verify()
 }
 
+func (h *actorHarness) onWorkerKeyChangeCron(rt *mock.Runtime) {
+	payload := &miner.CronEventPayload{EventType: miner.CronEventWorkerKeyChange, Version: miner.CronEventPayloadVersion}
+	h.onDeferredCronEvent(rt, payload)
+}
+
+func (h *actorHarness) onDeferredCronEvent(rt *mock.Runtime, payload cbor.Marshaler) {
+	eventPayloadBuf := bytes.Buffer{}
+	require.NoError(h.t, payload.MarshalCBOR(&eventPayloadBuf), "failed to marshal event payload")
+	h.onDeferredCronEventRaw(rt, eventPayloadBuf.Bytes())
+}
+
+func (h *actorHarness) onDeferredCronEventRaw(rt *mock.Runtime, payload []byte) {
+	rt.ExpectValidateCallerAddr(builtin.StoragePowerActorAddr)
+	rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
+	rt.Call(h.a.OnDeferredCronEvent, &builtin.DeferredCronEventParams{
+		EventPayload:            payload,
+		RewardSmoothed:          h.epochRewardSmooth,
+		QualityAdjPowerSmoothed: h.epochQAPowerSmooth,
+	})
+	rt.Verify()
+}
+
+func (h *actorHarness) droppedCronEvents(rt *mock.Runtime) uint64 {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.DroppedCronEvents, nil).(*miner.DroppedCronEventsReturn)
+	rt.Verify()
+	return ret.Count
+}
+
+func (h *actorHarness) cancelWorkerChange(rt *mock.Runtime, effectiveAt abi.ChainEpoch) {
+	rt.ExpectValidateCallerAddr(h.owner)
+	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
+	rt.Call(h.a.CancelWorkerChange, &miner.CancelWorkerChangeParams{EffectiveAt: effectiveAt})
+	rt.Verify()
+}
+
+// Code CID of the notification receivers in tests, which are not built-in actors.
+var notificationReceiverCode = tutil.MakeCID("notificationreceiver", nil)
+
+// Sets the notification receiver, which is taken to be an actor that is not built in if given by ID address.
+func (h *actorHarness) setNotificationReceiver(rt *mock.Runtime, receiver *addr.Address) {
+	rt.ExpectValidateCallerAddr(h.owner)
+	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
+	if receiver != nil && receiver.Protocol() == addr.ID {
+		rt.SetAddressActorType(*receiver, notificationReceiverCode)
+	}
+	rt.Call(h.a.SetNotificationReceiver, &miner.SetNotificationReceiverParams{NewReceiver: receiver})
+	rt.Verify()
+}
+
+func (h *actorHarness) setExpirationReminder(rt *mock.Runtime, periods uint64) {
+	rt.ExpectValidateCallerAddr(h.owner)
+	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
+	rt.Call(h.a.SetExpirationReminder, &miner.SetExpirationReminderParams{Periods: periods})
+	rt.Verify()
+}
+
+func (h *actorHarness) getPendingWorkerKeys(rt *mock.Runtime) []miner.WorkerKeyChange {
+	pending, err := h.getInfo(rt).LoadPendingWorkerKeys(adt.AsStore(rt))
+	require.NoError(h.t, err)
+	return pending
+}
+
+func (h *actorHarness) getControlChangeHistory(rt *mock.Runtime) []miner.ControlChangeEvent {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.GetControlChangeHistory, nil).(*miner.GetControlChangeHistoryReturn)
+	rt.Verify()
+	return ret.Events
+}
+
 func (h *actorHarness) changeOwnerAddress(rt *mock.Runtime, newAddr addr.Address) {
 	if rt.Caller() == h.owner {
 		rt.ExpectValidateCallerAddr(h.owner)
@@ -3846,6 +6438,37 @@ func (h *actorHarness) changeOwnerAddress(rt *mock.Runtime, newAddr addr.Address
 	rt.Verify()
 }
 
+func (h *actorHarness) changeBeneficiary(rt *mock.Runtime, caller addr.Address, params *miner.ChangeBeneficiaryParams) {
+	info := h.getInfo(rt)
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	if caller == info.Owner {
+		rt.ExpectValidateCallerAddr(info.Owner)
+	} else if info.PendingBeneficiaryTerm != nil {
+		rt.ExpectValidateCallerAddr(info.Beneficiary, info.PendingBeneficiaryTerm.NewBeneficiary)
+	}
+	rt.Call(h.a.ChangeBeneficiary, params)
+	rt.Verify()
+}
+
+// Makes an address the beneficiary, with the approval of any current beneficiary.
+func (h *actorHarness) setBeneficiary(rt *mock.Runtime, beneficiary addr.Address, quota abi.TokenAmount, expiration abi.ChainEpoch) {
+	current := h.getInfo(rt).Beneficiary
+	params := &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration}
+	h.changeBeneficiary(rt, h.owner, params)
+	if current != h.owner {
+		h.changeBeneficiary(rt, current, params)
+	}
+	h.changeBeneficiary(rt, beneficiary, params)
+	require.Equal(h.t, beneficiary, h.getInfo(rt).Beneficiary)
+}
+
+func (h *actorHarness) getBeneficiary(rt *mock.Runtime) *miner.GetBeneficiaryReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.GetBeneficiary, nil).(*miner.GetBeneficiaryReturn)
+	rt.Verify()
+	return ret
+}
+
 func (h *actorHarness) checkSectorProven(rt *mock.Runtime, sectorNum abi.SectorNumber) {
 	param := &miner.CheckSectorProvenParams{SectorNumber: sectorNum}
 
@@ -3855,6 +6478,13 @@ func (h *actorHarness) checkSectorProven(rt *mock.Runtime, sectorNum abi.SectorN
 	rt.Verify()
 }
 
+func (h *actorHarness) callFindSector(rt *mock.Runtime, sectorNum abi.SectorNumber) *miner.FindSectorReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.FindSector, &miner.FindSectorParams{SectorNumber: sectorNum}).(*miner.FindSectorReturn)
+	rt.Verify()
+	return ret
+}
+
 func (h *actorHarness) changeMultiAddrs(rt *mock.Runtime, newAddrs []abi.Multiaddrs) {
 	param := &miner.ChangeMultiaddrsParams{NewMultiaddrs: newAddrs}
 	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
@@ -3883,6 +6513,25 @@ func (h *actorHarness) changePeerID(rt *mock.Runtime, newPID abi.PeerID) {
 	require.EqualValues(h.t, newPID, info.PeerId)
 }
 
+func (h *actorHarness) changeWindowPoStProofType(rt *mock.Runtime, proof abi.RegisteredPoStProof) {
+	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(h.owner)
+	rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedProofType,
+		&power.UpdateClaimedProofTypeParams{NewProofType: proof}, big.Zero(), nil, exitcode.Ok)
+
+	rt.Call(h.a.ChangeWindowPoStProofType, &miner.ChangeWindowPoStProofTypeParams{NewProofType: proof})
+	rt.Verify()
+}
+
+func (h *actorHarness) changeOwnerSettings(rt *mock.Runtime, settings *miner.OwnerSettings) {
+	rt.ExpectValidateCallerAddr(h.owner)
+	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
+
+	rt.Call(h.a.ChangeOwnerSettings, settings)
+	rt.Verify()
+	require.Equal(h.t, *settings, getState(rt).GetOwnerSettings())
+}
+
 func (h *actorHarness) controlAddresses(rt *mock.Runtime) (owner, worker addr.Address, control []addr.Address) {
 	rt.ExpectValidateCallerAny()
 	ret := rt.Call(h.a.ControlAddresses, nil).(*miner.GetControlAddressesReturn)
@@ -3891,6 +6540,53 @@ func (h *actorHarness) controlAddresses(rt *mock.Runtime) (owner, worker addr.Ad
 	return ret.Owner, ret.Worker, ret.ControlAddrs
 }
 
+func (h *actorHarness) terminatedSectorCounts(rt *mock.Runtime) *miner.TerminatedSectorCountsReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.TerminatedSectorCounts, nil).(*miner.TerminatedSectorCountsReturn)
+	require.NotNil(h.t, ret)
+	rt.Verify()
+	return ret
+}
+
+func (h *actorHarness) sectorManifest(rt *mock.Runtime, sectorNo abi.SectorNumber) *miner.SectorManifestReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.SectorManifest, &miner.SectorManifestParams{SectorNumber: sectorNo}).(*miner.SectorManifestReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *actorHarness) getVestingFunds(rt *mock.Runtime) *miner.GetVestingFundsReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.GetVestingFunds, nil).(*miner.GetVestingFundsReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *actorHarness) estimateDeadlinePenalty(rt *mock.Runtime, dlIdx uint64) *miner.EstimateDeadlinePenaltyReturn {
+	rt.ExpectValidateCallerAny()
+	expectQueryNetworkInfo(rt, h)
+	ret := rt.Call(h.a.EstimateDeadlinePenalty, &miner.EstimateDeadlinePenaltyParams{Deadline: dlIdx}).(*miner.EstimateDeadlinePenaltyReturn)
+	require.NotNil(h.t, ret)
+	rt.Verify()
+	return ret
+}
+
+func (h *actorHarness) estimateAggregateFees(rt *mock.Runtime, sizes ...uint64) *miner.EstimateAggregateFeesReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.EstimateAggregateFees, &miner.EstimateAggregateFeesParams{BatchSizes: sizes}).(*miner.EstimateAggregateFeesReturn)
+	require.NotNil(h.t, ret)
+	rt.Verify()
+	return ret
+}
+
+func (h *actorHarness) partitionExpirations(rt *mock.Runtime, dlIdx, pIdx uint64) []miner.PartitionExpiration {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.PartitionExpirations, &miner.PartitionExpirationsParams{Deadline: dlIdx, Partition: pIdx}).(*miner.PartitionExpirationsReturn)
+	require.NotNil(h.t, ret)
+	rt.Verify()
+	return ret.Expirations
+}
+
 // Options for preCommitSector behaviour.
 // Default zero values should let everything be ok.
 type preCommitConf struct {
@@ -3938,6 +6634,7 @@ func (h *actorHarness) preCommitSector(rt *mock.Runtime, params *miner.PreCommit
 	if st.FeeDebt.GreaterThan(big.Zero()) {
 		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, st.FeeDebt, nil, exitcode.Ok)
 	}
+	expectUpdatePledgeTotal(rt, big.Zero(), h.expectedPreCommitDeposit(rt, params.Expiration, conf.dealWeight, conf.verifiedDealWeight), big.Zero())
 
 	if first {
 		dlInfo := miner.NewDeadlineInfoFromOffsetAndEpoch(st.ProvingPeriodStart, rt.Epoch())
@@ -3950,12 +6647,34 @@ func (h *actorHarness) preCommitSector(rt *mock.Runtime, params *miner.PreCommit
 	return h.getPreCommit(rt, params.SectorNumber)
 }
 
+// Computes the deposit required to pre-commit a sector with the given expiration and weights at the current epoch.
+func (h *actorHarness) expectedPreCommitDeposit(rt *mock.Runtime, expiration abi.ChainEpoch, dealWeight, verifiedDealWeight abi.DealWeight) abi.TokenAmount {
+	if dealWeight.Nil() {
+		dealWeight = big.Zero()
+	}
+	if verifiedDealWeight.Nil() {
+		verifiedDealWeight = big.Zero()
+	}
+	duration := expiration - rt.Epoch()
+	if duration <= 0 {
+		return big.Zero() // The pre-commit will be rejected before any deposit is computed.
+	}
+	pwr := miner.QAPowerForWeight(h.sectorSize, duration, dealWeight, verifiedDealWeight)
+	return miner.PreCommitDepositForPower(h.epochRewardSmooth, h.epochQAPowerSmooth, pwr)
+}
+
 type preCommitBatchConf struct {
 	// Weights to be returned from the market actor for sectors 0..len(sectorWeights).
 	// Any remaining sectors are taken to have zero deal weight.
 	sectorWeights []market.SectorWeights
 	// Set if this is the first commitment by this miner, hence should expect scheduling end-of-deadline cron.
 	firstForMiner bool
+	// Set to pre-commit with PreCommitSectorBatch2, which drops invalid sectors from the batch.
+	skipInvalid bool
+	// Indices of sectors expected to fail validation and be dropped from the batch, if skipInvalid is set.
+	failedSectors []uint64
+	// Manifests to record with the batch, if skipInvalid is set.
+	manifests []miner.SectorManifest
 }
 
 func (h *actorHarness) preCommitSectorBatch(rt *mock.Runtime, params *miner.PreCommitSectorBatchParams, conf preCommitBatchConf, baseFee abi.TokenAmount) []*miner.SectorPreCommitOnChainInfo {
@@ -3964,14 +6683,22 @@ func (h *actorHarness) preCommitSectorBatch(rt *mock.Runtime, params *miner.PreC
 	{
 		expectQueryNetworkInfo(rt, h)
 	}
-	sectorDeals := make([]market.SectorDeals, len(params.Sectors))
+	failed := map[int]bool{}
+	for _, i := range conf.failedSectors {
+		failed[int(i)] = true
+	}
+	var sectorDeals []market.SectorDeals
 	sectorWeights := make([]market.SectorWeights, len(params.Sectors))
+	var validWeights []market.SectorWeights
 	anyDeals := false
 	for i, sector := range params.Sectors {
-		sectorDeals[i] = market.SectorDeals{
+		if failed[i] {
+			continue
+		}
+		sectorDeals = append(sectorDeals, market.SectorDeals{
 			SectorExpiry: sector.Expiration,
 			DealIDs:      sector.DealIDs,
-		}
+		})
 
 		if len(conf.sectorWeights) > i {
 			sectorWeights[i] = conf.sectorWeights[i]
@@ -3988,23 +6715,33 @@ func (h *actorHarness) preCommitSectorBatch(rt *mock.Runtime, params *miner.PreC
 		require.True(h.t, sectorHasDeals == !dealTotalWeight.Equals(big.Zero()), "sector deals inconsistent with configured weight")
 		require.True(h.t, sectorHasDeals == (sectorWeights[i].DealSpace != 0), "sector deals inconsistent with configured space")
 		anyDeals = anyDeals || sectorHasDeals
+		validWeights = append(validWeights, sectorWeights[i])
 	}
 	if anyDeals {
 		vdParams := market.VerifyDealsForActivationParams{
 			Sectors: sectorDeals,
 		}
 		vdReturn := market.VerifyDealsForActivationReturn{
-			Sectors: sectorWeights,
+			Sectors: validWeights,
 		}
 		rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.VerifyDealsForActivation, &vdParams, big.Zero(), &vdReturn, exitcode.Ok)
 	}
 	st := getState(rt)
 	// burn networkFee
-	if st.FeeDebt.GreaterThan(big.Zero()) || len(params.Sectors) > 1 {
-		expectedNetworkFee := miner.AggregatePreCommitNetworkFee(len(params.Sectors), baseFee)
+	validCount := len(params.Sectors) - len(failed)
+	if st.FeeDebt.GreaterThan(big.Zero()) || validCount > 1 {
+		expectedNetworkFee := miner.AggregatePreCommitNetworkFee(validCount, baseFee)
 		expectedBurn := big.Add(expectedNetworkFee, st.FeeDebt)
 		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedBurn, nil, exitcode.Ok)
 	}
+	expectedDeposit := big.Zero()
+	for i, sector := range params.Sectors {
+		if failed[i] {
+			continue
+		}
+		expectedDeposit = big.Add(expectedDeposit, h.expectedPreCommitDeposit(rt, sector.Expiration, sectorWeights[i].DealWeight, sectorWeights[i].VerifiedDealWeight))
+	}
+	expectUpdatePledgeTotal(rt, big.Zero(), expectedDeposit, big.Zero())
 
 	if conf.firstForMiner {
 		dlInfo := miner.NewDeadlineInfoFromOffsetAndEpoch(st.ProvingPeriodStart, rt.Epoch())
@@ -4012,12 +6749,38 @@ func (h *actorHarness) preCommitSectorBatch(rt *mock.Runtime, params *miner.PreC
 		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent, cronParams, big.Zero(), nil, exitcode.Ok)
 	}
 
-	rt.Call(h.a.PreCommitSectorBatch, params)
-	rt.Verify()
+	// Sectors dropped from the batch have no pre-commit returned.
 	precommits := make([]*miner.SectorPreCommitOnChainInfo, len(params.Sectors))
+	if !conf.skipInvalid {
+		ret := rt.Call(h.a.PreCommitSectorBatch, params)
+		rt.Verify()
+		assert.Nil(h.t, ret)
+		for i, sector := range params.Sectors {
+			precommits[i] = h.getPreCommit(rt, sector.SectorNumber)
+		}
+		return precommits
+	}
+
+	params2 := &miner.PreCommitSectorBatch2Params{Sectors: params.Sectors, Manifests: conf.manifests}
+	ret := rt.Call(h.a.PreCommitSectorBatch2, params2).(*miner.PreCommitSectorBatchReturn)
+	rt.Verify()
+	failedSectors, err := ret.FailedSectors.All(uint64(len(params.Sectors)))
+	require.NoError(h.t, err)
+	require.Equal(h.t, len(conf.failedSectors), len(failedSectors))
+	if len(failedSectors) > 0 {
+		require.Equal(h.t, conf.failedSectors, failedSectors)
+	}
+
+	var acceptedNumbers []uint64
 	for i, sector := range params.Sectors {
-		precommits[i] = h.getPreCommit(rt, sector.SectorNumber)
+		if !failed[i] {
+			precommits[i] = h.getPreCommit(rt, sector.SectorNumber)
+			acceptedNumbers = append(acceptedNumbers, uint64(sector.SectorNumber))
+		}
 	}
+	accepted, err := ret.AcceptedSectors.All(miner.AddressedSectorsMax)
+	require.NoError(h.t, err)
+	require.Equal(h.t, acceptedNumbers, accepted)
 	return precommits
 }
 
@@ -4028,6 +6791,27 @@ type proveCommitConf struct {
 }
 
 func (h *actorHarness) proveCommitSector(rt *mock.Runtime, precommit *miner.SectorPreCommitOnChainInfo, params *miner.ProveCommitSectorParams) {
+	h.proveCommitSectorBy(rt, h.worker, precommit, params)
+}
+
+func (h *actorHarness) proveCommitSectorBy(rt *mock.Runtime, caller addr.Address, precommit *miner.SectorPreCommitOnChainInfo, params *miner.ProveCommitSectorParams) {
+	h.expectProveCommitSector(rt, precommit, params.Proof, big.Zero())
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	rt.Call(h.a.ProveCommitSector, params)
+	rt.Verify()
+}
+
+func (h *actorHarness) proveCommitSector2By(rt *mock.Runtime, caller addr.Address, precommit *miner.SectorPreCommitOnChainInfo, params *miner.ProveCommitSector2Params) {
+	h.expectProveCommitSector(rt, precommit, params.Proof, params.PriorityFee)
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	rt.Call(h.a.ProveCommitSector2, params)
+	rt.Verify()
+}
+
+// Expects the calls made in proving a pre-committed sector, submitting the proof to the power actor with a priority fee.
+func (h *actorHarness) expectProveCommitSector(rt *mock.Runtime, precommit *miner.SectorPreCommitOnChainInfo, sealProof []byte, priorityFee abi.TokenAmount) {
 	commd := cbg.CborCid(tutil.MakeCID("commd", &market.PieceCIDPrefix))
 	sealRand := abi.SealRandomness([]byte{1, 2, 3, 4})
 	sealIntRand := abi.InteractiveSealRandomness([]byte{5, 6, 7, 8})
@@ -4065,18 +6849,18 @@ func (h *actorHarness) proveCommitSector(rt *mock.Runtime, precommit *miner.Sect
 			},
 			SealedCID:             precommit.Info.SealedCID,
 			SealProof:             precommit.Info.SealProof,
-			Proof:                 params.Proof,
+			Proof:                 sealProof,
 			DealIDs:               precommit.Info.DealIDs,
 			Randomness:            sealRand,
 			InteractiveRandomness: sealIntRand,
 			UnsealedCID:           cid.Cid(commd),
 		}
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.SubmitPoRepForBulkVerify, &seal, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		submitParams := power.SubmitPoRepForBulkVerifyParams{
+			SealInfo:       seal,
+			ProveCommitDue: precommit.PreCommitEpoch + miner.MaxProveCommitDuration[precommit.Info.SealProof],
+		}
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.SubmitPoRepForBulkVerify, &submitParams, priorityFee, nil, exitcode.Ok)
 	}
-	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
-	rt.ExpectValidateCallerAny()
-	rt.Call(h.a.ProveCommitSector, params)
-	rt.Verify()
 }
 
 func (h *actorHarness) proveCommitAggregateSector(rt *mock.Runtime, conf proveCommitConf, precommits []*miner.SectorPreCommitOnChainInfo, params *miner.ProveCommitAggregateParams, baseFee big.Int) {
@@ -4142,46 +6926,136 @@ func (h *actorHarness) proveCommitAggregateSector(rt *mock.Runtime, conf proveCo
 		}, nil)
 	}
 
-	// confirmSectorProofsValid
-	{
-		h.confirmSectorProofsValidInternal(rt, conf, precommits...)
+	// confirmSectorProofsValid, skipping sectors proven too late
+	var onTime []*miner.SectorPreCommitOnChainInfo
+	lateDeposit := big.Zero()
+	{
+		for _, precommit := range precommits {
+			if rt.Epoch() > precommit.PreCommitEpoch+miner.MaxProveCommitDuration[precommit.Info.SealProof] {
+				lateDeposit = big.Add(lateDeposit, precommit.PreCommitDeposit)
+			} else {
+				onTime = append(onTime, precommit)
+			}
+		}
+		h.confirmSectorProofsValidInternal(rt, conf, onTime...)
+	}
+
+	// release deposits of late sectors
+	latePenalty := big.Zero()
+	if !lateDeposit.IsZero() && miner.LatePreCommitDepositRefund.Numerator.GreaterThan(big.Zero()) {
+		latePenalty = big.Sub(lateDeposit, miner.LatePreCommitDepositRefundAmount(lateDeposit))
+		expectUpdatePledgeTotal(rt, big.Zero(), lateDeposit.Neg(), big.Zero())
+	}
+
+	// burn networkFee
+	{
+		expectedFee := miner.AggregateProveCommitNetworkFee(len(onTime), baseFee)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, big.Add(expectedFee, latePenalty), nil, exitcode.Ok)
+	}
+
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.Call(h.a.ProveCommitAggregate, params)
+	rt.Verify()
+}
+
+func (h *actorHarness) proveCommitSectorsNI(rt *mock.Runtime, params *miner.ProveCommitSectorsNIParams, baseFee big.Int, firstForMiner bool) []*miner.SectorOnChainInfo {
+	commD := cbg.CborCid(tutil.MakeCID("commd-cc", &market.PieceCIDPrefix))
+	cdcParams := market.ComputeDataCommitmentParams{Inputs: []*market.SectorDataSpec{{SectorType: params.SealProof}}}
+	cdcRet := market.ComputeDataCommitmentReturn{CommDs: []cbg.CborCid{commD}}
+	rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.ComputeDataCommitment, &cdcParams, big.Zero(), &cdcRet, exitcode.Ok)
+
+	var buf bytes.Buffer
+	receiver := rt.Receiver()
+	require.NoError(h.t, receiver.MarshalCBOR(&buf))
+	svis := make([]proof.AggregateSealVerifyInfo, len(params.Sectors))
+	for i, sector := range params.Sectors {
+		sealRand := abi.SealRandomness([]byte{1, 2, 3, 4})
+		sealIntRand := abi.InteractiveSealRandomness([]byte{5, 6, 7, 8})
+		// The challenge seed must not be drawn at the seal randomness epoch, or the miner could grind the replica
+		// against known challenges.
+		seedEpoch := sector.SealRandEpoch + miner.PreCommitChallengeDelay
+		require.NotEqual(h.t, sector.SealRandEpoch, seedEpoch)
+		seedEntropy := append(append([]byte{}, buf.Bytes()...), sector.SealedCID.Bytes()...)
+		rt.ExpectGetRandomnessTickets(crypto.DomainSeparationTag_SealRandomness, sector.SealRandEpoch, buf.Bytes(), abi.Randomness(sealRand))
+		rt.ExpectGetRandomnessBeacon(builtin.DomainSeparationTag_NonInteractiveSealChallengeSeed, seedEpoch, seedEntropy, abi.Randomness(sealIntRand))
+		svis[i] = proof.AggregateSealVerifyInfo{
+			Number:                sector.SectorNumber,
+			InteractiveRandomness: sealIntRand,
+			Randomness:            sealRand,
+			SealedCID:             sector.SealedCID,
+			UnsealedCID:           cid.Cid(commD),
+		}
 	}
+	actorId, err := addr.IDFromAddress(h.receiver)
+	require.NoError(h.t, err)
+	rt.ExpectAggregateVerifySeals(proof.AggregateSealVerifyProofAndInfos{
+		Infos:          svis,
+		Proof:          params.AggregateProof,
+		Miner:          abi.ActorID(actorId),
+		SealProof:      params.SealProof,
+		AggregateProof: abi.RegisteredAggregationProof_SnarkPackV1,
+	}, nil)
+	expectQueryNetworkInfo(rt, h)
 
-	// burn networkFee
-	{
-		expectedFee := miner.AggregateProveCommitNetworkFee(len(precommits), baseFee)
-		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedFee, nil, exitcode.Ok)
+	expectedPledge := big.Zero()
+	for _, sector := range params.Sectors {
+		qaPower := miner.QAPowerForWeight(h.sectorSize, sector.Expiration-rt.Epoch(), big.Zero(), big.Zero())
+		expectedPledge = big.Add(expectedPledge, miner.InitialPledgeForPower(qaPower, h.baselinePower, h.epochRewardSmooth,
+			h.epochQAPowerSmooth, rt.TotalFilCircSupply()))
+	}
+	st := getState(rt)
+	expectedFee := miner.AggregateProveCommitNetworkFee(len(params.Sectors), baseFee)
+	rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, big.Add(expectedFee, st.FeeDebt), nil, exitcode.Ok)
+	expectUpdatePledgeTotal(rt, expectedPledge, big.Zero(), big.Zero())
+	if firstForMiner {
+		dlInfo := miner.NewDeadlineInfoFromOffsetAndEpoch(st.ProvingPeriodStart, rt.Epoch())
+		cronParams := makeDeadlineCronEventParams(h.t, dlInfo.Last())
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent, cronParams, big.Zero(), nil, exitcode.Ok)
 	}
 
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
-	rt.Call(h.a.ProveCommitAggregate, params)
+	rt.Call(h.a.ProveCommitSectorsNI, params)
 	rt.Verify()
+
+	sectors := make([]*miner.SectorOnChainInfo, len(params.Sectors))
+	for i, sector := range params.Sectors {
+		sectors[i] = h.getSector(rt, sector.SectorNumber)
+	}
+	return sectors
 }
 
 func (h *actorHarness) confirmSectorProofsValidInternal(rt *mock.Runtime, conf proveCommitConf, precommits ...*miner.SectorPreCommitOnChainInfo) {
 	// Prepare for and receive call to ConfirmSectorProofsValid.
+	// The deals of all sectors are activated in a single batch, in which those configured to fail are not activated.
 	var validPrecommits []*miner.SectorPreCommitOnChainInfo
+	var sectorDeals []market.SectorDealActivation
+	activated := bitfield.New()
 	for _, precommit := range precommits {
-		validPrecommits = append(validPrecommits, precommit)
 		if len(precommit.Info.DealIDs) > 0 {
-			vdParams := market.ActivateDealsParams{
-				DealIDs:      precommit.Info.DealIDs,
+			sectorDeals = append(sectorDeals, market.SectorDealActivation{
+				SectorNumber: precommit.Info.SectorNumber,
 				SectorExpiry: precommit.Info.Expiration,
+				DealIDs:      precommit.Info.DealIDs,
+			})
+			if _, failed := conf.verifyDealsExit[precommit.Info.SectorNumber]; failed {
+				continue
 			}
-			exit, found := conf.verifyDealsExit[precommit.Info.SectorNumber]
-			if found {
-				validPrecommits = validPrecommits[:len(validPrecommits)-1] // pop
-			} else {
-				exit = exitcode.Ok
-			}
-			rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.ActivateDeals, &vdParams, big.Zero(), nil, exit)
+			activated.Set(uint64(len(sectorDeals) - 1))
 		}
+		validPrecommits = append(validPrecommits, precommit)
+	}
+	if len(sectorDeals) > 0 {
+		rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.BatchActivateDeals,
+			&market.BatchActivateDealsParams{Sectors: sectorDeals}, big.Zero(),
+			&market.BatchActivateDealsReturn{Activated: activated}, exitcode.Ok)
 	}
 
 	// expected pledge is the sum of initial pledges
 	if len(validPrecommits) > 0 {
 		expectPledge := big.Zero()
+		expectDepositReleased := big.Zero()
 
 		expectQAPower := big.Zero()
 		expectRawPower := big.Zero()
@@ -4203,12 +7077,11 @@ func (h *actorHarness) confirmSectorProofsValidInternal(rt *mock.Runtime, conf p
 				}
 
 				expectPledge = big.Add(expectPledge, pledge)
+				expectDepositReleased = big.Add(expectDepositReleased, precommitOnChain.PreCommitDeposit)
 			}
 		}
 
-		if !expectPledge.IsZero() {
-			rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &expectPledge, big.Zero(), nil, exitcode.Ok)
-		}
+		expectUpdatePledgeTotal(rt, expectPledge, expectDepositReleased.Neg(), big.Zero())
 	}
 }
 
@@ -4230,6 +7103,20 @@ func (h *actorHarness) confirmSectorProofsValid(rt *mock.Runtime, conf proveComm
 	rt.Verify()
 }
 
+// Records a pre-committed sector as proven at the current epoch, without verifying a proof.
+func (h *actorHarness) recordPreCommitProven(rt *mock.Runtime, sectorNo abi.SectorNumber) {
+	st := getState(rt)
+	require.NoError(h.t, st.RecordPreCommitsProven(rt.AdtStore(), rt.Epoch(), sectorNo))
+	rt.ReplaceState(st)
+}
+
+func (h *actorHarness) activationStage(rt *mock.Runtime, sectorNo abi.SectorNumber) miner.SectorActivationStage {
+	st := getState(rt)
+	stage, err := st.SectorActivationStage(rt.AdtStore(), sectorNo, rt.Epoch())
+	require.NoError(h.t, err)
+	return stage
+}
+
 func (h *actorHarness) proveCommitSectorAndConfirm(rt *mock.Runtime, precommit *miner.SectorPreCommitOnChainInfo,
 	params *miner.ProveCommitSectorParams, conf proveCommitConf) *miner.SectorOnChainInfo {
 	h.proveCommitSector(rt, precommit, params)
@@ -4322,10 +7209,12 @@ func (h *actorHarness) advancePastDeadlineEndWithCron(rt *mock.Runtime) {
 }
 
 type poStDisputeResult struct {
-	expectedPowerDelta  miner.PowerPair
-	expectedPledgeDelta abi.TokenAmount
-	expectedPenalty     abi.TokenAmount
-	expectedReward      abi.TokenAmount
+	expectedPowerDelta miner.PowerPair
+	// Expected change in faulty power, if not the negation of expectedPowerDelta.
+	expectedFaultyPowerDelta *miner.PowerPair
+	expectedPledgeDelta      abi.TokenAmount
+	expectedPenalty          abi.TokenAmount
+	expectedReward           abi.TokenAmount
 }
 
 func (h *actorHarness) disputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info, proofIndex uint64, infos []*miner.SectorOnChainInfo, expectSuccess *poStDisputeResult) {
@@ -4405,14 +7294,11 @@ func (h *actorHarness) disputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info,
 
 	if expectSuccess != nil {
 		// expect power update
-		if !expectSuccess.expectedPowerDelta.IsZero() {
-			claim := &power.UpdateClaimedPowerParams{
-				RawByteDelta:         expectSuccess.expectedPowerDelta.Raw,
-				QualityAdjustedDelta: expectSuccess.expectedPowerDelta.QA,
-			}
-			rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedPower, claim, abi.NewTokenAmount(0),
-				nil, exitcode.Ok)
+		faultyPowerDelta := expectSuccess.expectedPowerDelta.Neg()
+		if expectSuccess.expectedFaultyPowerDelta != nil {
+			faultyPowerDelta = *expectSuccess.expectedFaultyPowerDelta
 		}
+		expectUpdateClaimedPower(rt, expectSuccess.expectedPowerDelta, faultyPowerDelta)
 		// expect reward
 		if !expectSuccess.expectedReward.IsZero() {
 			rt.ExpectSend(h.worker, builtin.MethodSend, nil, expectSuccess.expectedReward, nil, exitcode.Ok)
@@ -4422,10 +7308,7 @@ func (h *actorHarness) disputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info,
 			rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectSuccess.expectedPenalty, nil, exitcode.Ok)
 		}
 		// expect pledge update
-		if !expectSuccess.expectedPledgeDelta.IsZero() {
-			rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal,
-				&expectSuccess.expectedPledgeDelta, abi.NewTokenAmount(0), nil, exitcode.Ok)
-		}
+		expectUpdatePledgeTotal(rt, big.Zero(), big.Zero(), expectSuccess.expectedPledgeDelta)
 	}
 
 	params := miner.DisputeWindowedPoStParams{
@@ -4442,13 +7325,37 @@ func (h *actorHarness) disputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info,
 	rt.Verify()
 }
 
+// Returns the bytes a worker signs to have a Window PoSt relayed.
+func relayedPoStIntent(t *testing.T, rt *mock.Runtime, params *miner.SubmitWindowedPoStRelayedParams) []byte {
+	var proofs bytes.Buffer
+	for i := range params.PoSt.Proofs {
+		require.NoError(t, params.PoSt.Proofs[i].MarshalCBOR(&proofs))
+	}
+	proofsHash := rt.HashBlake2b(proofs.Bytes())
+	intent := miner.PoStIntent{
+		Miner:            rt.Receiver(),
+		Deadline:         params.PoSt.Deadline,
+		Partitions:       params.PoSt.Partitions,
+		ProofsHash:       proofsHash[:],
+		ChainCommitEpoch: params.PoSt.ChainCommitEpoch,
+		Nonce:            params.Nonce,
+	}
+	signed, err := market.SigningBytes(miner.SigningDomainPoStIntent, &intent)
+	require.NoError(t, err)
+	return signed
+}
+
 type poStConfig struct {
 	chainRandomness    abi.Randomness
 	expectedPowerDelta miner.PowerPair
-	verificationError  error
+	// Expected change in faulty power, from skipped and recovered sectors.
+	expectedFaultyPowerDelta miner.PowerPair
+	verificationError        error
+	// The submitted partitions have all been proven, so the proof isn't processed.
+	alreadyProven bool
 }
 
-func (h *actorHarness) submitWindowPoSt(rt *mock.Runtime, deadline *dline.Info, partitions []miner.PoStPartition, infos []*miner.SectorOnChainInfo, poStCfg *poStConfig) {
+func (h *actorHarness) submitWindowPoSt(rt *mock.Runtime, deadline *dline.Info, partitions []miner.PoStPartition, infos []*miner.SectorOnChainInfo, poStCfg *poStConfig) *miner.SubmitWindowedPoStReturn {
 	params := miner.SubmitWindowedPoStParams{
 		Deadline:         deadline.Index,
 		Partitions:       partitions,
@@ -4456,11 +7363,11 @@ func (h *actorHarness) submitWindowPoSt(rt *mock.Runtime, deadline *dline.Info,
 		ChainCommitEpoch: deadline.Challenge,
 		ChainCommitRand:  abi.Randomness("chaincommitment"),
 	}
-	h.submitWindowPoStRaw(rt, deadline, infos, &params, poStCfg)
+	return h.submitWindowPoStRaw(rt, deadline, infos, &params, poStCfg)
 }
 
 func (h *actorHarness) submitWindowPoStRaw(rt *mock.Runtime, deadline *dline.Info,
-	infos []*miner.SectorOnChainInfo, params *miner.SubmitWindowedPoStParams, poStCfg *poStConfig) {
+	infos []*miner.SectorOnChainInfo, params *miner.SubmitWindowedPoStParams, poStCfg *poStConfig) *miner.SubmitWindowedPoStReturn {
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
 	chainCommitRand := params.ChainCommitRand
 	if poStCfg != nil && len(poStCfg.chainRandomness) > 0 {
@@ -4545,18 +7452,66 @@ func (h *actorHarness) submitWindowPoStRaw(rt *mock.Runtime, deadline *dline.Inf
 
 	if poStCfg != nil {
 		// expect power update
-		if !poStCfg.expectedPowerDelta.Raw.NilOrZero() || !poStCfg.expectedPowerDelta.QA.NilOrZero() {
-			claim := &power.UpdateClaimedPowerParams{
-				RawByteDelta:         poStCfg.expectedPowerDelta.Raw,
-				QualityAdjustedDelta: poStCfg.expectedPowerDelta.QA,
+		expectUpdateClaimedPower(rt, poStCfg.expectedPowerDelta, poStCfg.expectedFaultyPowerDelta)
+	}
+
+	ret := rt.Call(h.a.SubmitWindowedPoSt, params).(*miner.SubmitWindowedPoStReturn)
+	rt.Verify()
+
+	if poStCfg != nil {
+		expectedDelta := miner.NewPowerPairZero()
+		if !poStCfg.expectedPowerDelta.Raw.Nil() {
+			expectedDelta = poStCfg.expectedPowerDelta
+		}
+		assert.True(h.t, expectedDelta.Equals(ret.PowerDelta), "expected power delta %v, got %v", expectedDelta, ret.PowerDelta)
+	}
+	return ret
+}
+
+// Submits an aggregate Window PoSt for partitions with no faults or recoveries, proving the sectors in infos.
+func (h *actorHarness) submitWindowPoStAggregate(rt *mock.Runtime, deadline *dline.Info, partitions []miner.PoStPartition, infos []*miner.SectorOnChainInfo, poStCfg *poStConfig) *miner.SubmitWindowedPoStReturn {
+	params := miner.SubmitWindowedPoStAggregateParams{
+		Deadline:         deadline.Index,
+		Partitions:       partitions,
+		AggregateProof:   makePoStProofs(h.windowPostProofType)[0],
+		ChainCommitEpoch: deadline.Challenge,
+		ChainCommitRand:  abi.Randomness("chaincommitment"),
+	}
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.ExpectGetRandomnessTickets(crypto.DomainSeparationTag_PoStChainCommit, params.ChainCommitEpoch, nil, params.ChainCommitRand)
+
+	if !poStCfg.alreadyProven {
+		var buf bytes.Buffer
+		receiver := rt.Receiver()
+		require.NoError(h.t, receiver.MarshalCBOR(&buf))
+		challengeRand := abi.Randomness([]byte{10, 11, 12, 13})
+		rt.ExpectGetRandomnessBeacon(crypto.DomainSeparationTag_WindowedPoStChallengeSeed, deadline.Challenge, buf.Bytes(), challengeRand)
+
+		actorId, err := addr.IDFromAddress(h.receiver)
+		require.NoError(h.t, err)
+		proofInfos := make([]proof.SectorInfo, len(infos))
+		for i, ci := range infos {
+			proofInfos[i] = proof.SectorInfo{
+				SealProof:    ci.SealProof,
+				SectorNumber: ci.SectorNumber,
+				SealedCID:    ci.SealedCID,
 			}
-			rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedPower, claim, abi.NewTokenAmount(0),
-				nil, exitcode.Ok)
 		}
+		rt.ExpectVerifyAggregatePoSt(proof.AggregateWindowPoStVerifyInfo{
+			Randomness:        abi.PoStRandomness(challengeRand),
+			AggregateProof:    params.AggregateProof,
+			Partitions:        uint64(len(partitions)),
+			ChallengedSectors: proofInfos,
+			Prover:            abi.ActorID(actorId),
+		}, poStCfg.verificationError)
+
+		expectUpdateClaimedPower(rt, poStCfg.expectedPowerDelta, poStCfg.expectedFaultyPowerDelta)
 	}
 
-	rt.Call(h.a.SubmitWindowedPoSt, params)
+	ret := rt.Call(h.a.SubmitWindowedPoStAggregate, &params).(*miner.SubmitWindowedPoStReturn)
 	rt.Verify()
+	return ret
 }
 
 func (h *actorHarness) declareFaults(rt *mock.Runtime, faultSectorInfos ...*miner.SectorOnChainInfo) miner.PowerPair {
@@ -4570,18 +7525,8 @@ func (h *actorHarness) declareFaults(rt *mock.Runtime, faultSectorInfos ...*mine
 	expectedQADelta = expectedQADelta.Neg()
 
 	// expect power update
-	claim := &power.UpdateClaimedPowerParams{
-		RawByteDelta:         expectedRawDelta,
-		QualityAdjustedDelta: expectedQADelta,
-	}
-	rt.ExpectSend(
-		builtin.StoragePowerActorAddr,
-		builtin.MethodsPower.UpdateClaimedPower,
-		claim,
-		abi.NewTokenAmount(0),
-		nil,
-		exitcode.Ok,
-	)
+	powerDelta := miner.NewPowerPair(expectedRawDelta, expectedQADelta)
+	expectUpdateClaimedPower(rt, powerDelta, powerDelta.Neg())
 
 	// Calculate params from faulted sector infos
 	st := getState(rt)
@@ -4589,7 +7534,7 @@ func (h *actorHarness) declareFaults(rt *mock.Runtime, faultSectorInfos ...*mine
 	rt.Call(h.a.DeclareFaults, params)
 	rt.Verify()
 
-	return miner.NewPowerPair(claim.RawByteDelta, claim.QualityAdjustedDelta)
+	return powerDelta
 }
 
 func (h *actorHarness) declareRecoveries(rt *mock.Runtime, deadlineIdx uint64, partitionIdx uint64, recoverySectors bitfield.BitField, expectedDebtRepaid abi.TokenAmount) {
@@ -4611,13 +7556,48 @@ func (h *actorHarness) declareRecoveries(rt *mock.Runtime, deadlineIdx uint64, p
 	rt.Verify()
 }
 
+func (h *actorHarness) declareFaultsAndRecoveries(rt *mock.Runtime, params *miner.DeclareFaultsAndRecoveriesParams, expectedPowerDelta miner.PowerPair) {
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+
+	// Recoveries change neither power nor faulty power until proven.
+	expectUpdateClaimedPower(rt, expectedPowerDelta, expectedPowerDelta.Neg())
+
+	rt.Call(h.a.DeclareFaultsAndRecoveries, params)
+	rt.Verify()
+}
+
+// Declares recoveries with queueing enabled, which neither repays nor burns any fee debt.
+func (h *actorHarness) queueRecoveries(rt *mock.Runtime, deadlineIdx uint64, partitionIdx uint64, recoverySectors bitfield.BitField) {
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+
+	params := &miner.DeclareFaultsRecovered2Params{
+		Recoveries: []miner.RecoveryDeclaration{{
+			Deadline:  deadlineIdx,
+			Partition: partitionIdx,
+			Sectors:   recoverySectors,
+		}},
+		QueueIfInDebt: true,
+	}
+
+	rt.Call(h.a.DeclareFaultsRecovered2, params)
+	rt.Verify()
+}
+
 func (h *actorHarness) extendSectors(rt *mock.Runtime, params *miner.ExtendSectorExpirationParams) {
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
 
+	// A sector declared more than once is extended once.
 	qaDelta := big.Zero()
+	extended := map[uint64]bool{}
 	for _, extension := range params.Extensions {
 		err := extension.Sectors.ForEach(func(sno uint64) error {
+			if extended[sno] {
+				return nil
+			}
+			extended[sno] = true
 			sector := h.getSector(rt, abi.SectorNumber(sno))
 			newSector := *sector
 			newSector.Expiration = extension.NewExpiration
@@ -4645,15 +7625,92 @@ func (h *actorHarness) extendSectors(rt *mock.Runtime, params *miner.ExtendSecto
 	rt.Verify()
 }
 
+// Extends sectors, with the verified registry reporting the given verified space for each sector's claims.
+func (h *actorHarness) extendSectors2(rt *mock.Runtime, params *miner.ExtendSectorExpiration2Params, claims map[abi.SectorNumber]abi.StoragePower) {
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+
+	var sectorClaims []verifreg.SectorClaims
+	var verifiedSpace []verifreg.DataCap
+	for _, extension := range params.Extensions {
+		for _, claim := range extension.Claims {
+			sectorClaims = append(sectorClaims, verifreg.SectorClaims{SectorNumber: claim.SectorNumber, ClaimIDs: claim.ClaimIDs})
+			verifiedSpace = append(verifiedSpace, claims[claim.SectorNumber])
+		}
+	}
+	if len(sectorClaims) > 0 {
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.VerifySectorClaims,
+			&verifreg.VerifySectorClaimsParams{Sectors: sectorClaims}, big.Zero(),
+			&verifreg.VerifySectorClaimsReturn{VerifiedSpace: verifiedSpace}, exitcode.Ok)
+	}
+	expectQueryNetworkInfo(rt, h)
+
+	qaDelta := big.Zero()
+	pledgeDelta := big.Zero()
+	for _, extension := range params.Extensions {
+		err := extension.Sectors.ForEach(func(sno uint64) error {
+			sector := h.getSector(rt, abi.SectorNumber(sno))
+			duration := extension.NewExpiration - rt.Epoch()
+			dealWeight := big.Div(big.Mul(sector.DealWeight, big.NewInt(int64(sector.Expiration-rt.Epoch()))),
+				big.NewInt(int64(sector.Expiration-sector.Activation)))
+			verifiedDealWeight := big.Zero()
+			if claimed, ok := claims[sector.SectorNumber]; ok {
+				verifiedDealWeight = big.Mul(claimed, big.NewInt(int64(duration)))
+			}
+			newPower := miner.QAPowerForWeight(h.sectorSize, duration, dealWeight, verifiedDealWeight)
+			qaDelta = big.Sum(qaDelta, newPower, miner.QAPowerForSector(h.sectorSize, sector).Neg())
+
+			pledge := miner.InitialPledgeForPower(newPower, h.baselinePower, h.epochRewardSmooth,
+				h.epochQAPowerSmooth, rt.TotalFilCircSupply())
+			if pledge.GreaterThan(sector.InitialPledge) {
+				pledgeDelta = big.Sum(pledgeDelta, pledge, sector.InitialPledge.Neg())
+			}
+			return nil
+		})
+		require.NoError(h.t, err)
+	}
+	if !qaDelta.IsZero() {
+		rt.ExpectSend(builtin.StoragePowerActorAddr,
+			builtin.MethodsPower.UpdateClaimedPower,
+			&power.UpdateClaimedPowerParams{
+				RawByteDelta:         big.Zero(),
+				QualityAdjustedDelta: qaDelta,
+			},
+			abi.NewTokenAmount(0),
+			nil,
+			exitcode.Ok,
+		)
+	}
+	expectUpdatePledgeTotal(rt, pledgeDelta, big.Zero(), big.Zero())
+
+	rt.Call(h.a.ExtendSectorExpiration2, params)
+	rt.Verify()
+}
+
 func (h *actorHarness) terminateSectors(rt *mock.Runtime, sectors bitfield.BitField, expectedFee abi.TokenAmount) (miner.PowerPair, abi.TokenAmount) {
+	return h.terminateSectorsWithPenaltyFunds(rt, sectors, expectedFee, big.Zero(), exitcode.Ok)
+}
+
+// Terminates sectors with funds sent with the message earmarked for the penalty, if any.
+// The expected fee is paid from the earmarked funds before vesting funds, and the remainder returned to the worker,
+// which responds to the refund with refundCode.
+func (h *actorHarness) terminateSectorsWithPenaltyFunds(rt *mock.Runtime, sectors bitfield.BitField, expectedFee, earmarked abi.TokenAmount,
+	refundCode exitcode.ExitCode) (miner.PowerPair, abi.TokenAmount) {
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.SetBalance(big.Add(rt.Balance(), earmarked))
+	rt.SetReceived(earmarked)
+	defer rt.SetReceived(big.Zero())
 	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
 
 	dealIDs := []abi.DealID{}
 	sectorInfos := []*miner.SectorOnChainInfo{}
+	var verifiedSectors []uint64
 	err := sectors.ForEach(func(secNum uint64) error {
 		sector := h.getSector(rt, abi.SectorNumber(secNum))
 		dealIDs = append(dealIDs, sector.DealIDs...)
+		if !sector.VerifiedDealWeight.IsZero() {
+			verifiedSectors = append(verifiedSectors, secNum)
+		}
 
 		sectorInfos = append(sectorInfos, sector)
 		return nil
@@ -4662,21 +7719,21 @@ func (h *actorHarness) terminateSectors(rt *mock.Runtime, sectors bitfield.BitFi
 
 	expectQueryNetworkInfo(rt, h)
 
-	pledgeDelta := big.Zero()
+	initialPledgeDelta := big.Zero()
+	lockedRewardsDelta := big.Zero()
 	var sectorPower miner.PowerPair
+	fromEarmarked := big.Min(earmarked, expectedFee)
 	if big.Zero().LessThan(expectedFee) {
 		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedFee, nil, exitcode.Ok)
-		pledgeDelta = big.Sum(pledgeDelta, expectedFee.Neg())
+		lockedRewardsDelta = big.Sub(fromEarmarked, expectedFee)
 	}
 	// notify change to initial pledge
 	if len(sectorInfos) > 0 {
 		for _, sector := range sectorInfos {
-			pledgeDelta = big.Add(pledgeDelta, sector.InitialPledge.Neg())
+			initialPledgeDelta = big.Add(initialPledgeDelta, sector.InitialPledge.Neg())
 		}
 	}
-	if !pledgeDelta.Equals(big.Zero()) {
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &pledgeDelta, big.Zero(), nil, exitcode.Ok)
-	}
+	expectUpdatePledgeTotal(rt, initialPledgeDelta, big.Zero(), lockedRewardsDelta)
 	if len(dealIDs) > 0 {
 		size := len(dealIDs)
 		if size > cbg.MaxLength {
@@ -4688,6 +7745,13 @@ func (h *actorHarness) terminateSectors(rt *mock.Runtime, sectors bitfield.BitFi
 		}, abi.NewTokenAmount(0), nil, exitcode.Ok)
 		dealIDs = dealIDs[size:]
 	}
+	if len(verifiedSectors) > 0 {
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RemoveSectorClaims,
+			&verifreg.RemoveSectorClaimsParams{Sectors: bitfield.NewFromSet(verifiedSectors)}, big.Zero(), nil, exitcode.Ok)
+	}
+	if refund := big.Sub(earmarked, fromEarmarked); refund.GreaterThan(big.Zero()) {
+		rt.ExpectSend(h.worker, builtin.MethodSend, nil, refund, nil, refundCode)
+	}
 	{
 		sectorPower = miner.PowerForSectors(h.sectorSize, sectorInfos)
 		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedPower, &power.UpdateClaimedPowerParams{
@@ -4715,14 +7779,50 @@ func (h *actorHarness) terminateSectors(rt *mock.Runtime, sectors bitfield.BitFi
 	})
 	require.NoError(h.t, err)
 
-	params := &miner.TerminateSectorsParams{Terminations: declarations}
-	rt.Call(h.a.TerminateSectors, params)
+	if earmarked.GreaterThan(big.Zero()) {
+		rt.Call(h.a.TerminateSectors2, &miner.TerminateSectors2Params{Terminations: declarations, PenaltyFromValue: true})
+	} else {
+		rt.Call(h.a.TerminateSectors, &miner.TerminateSectorsParams{Terminations: declarations})
+	}
 	rt.Verify()
 
-	return sectorPower.Neg(), pledgeDelta
+	return sectorPower.Neg(), big.Add(initialPledgeDelta, lockedRewardsDelta)
+}
+
+// Terminates a sector as requested by the market actor, expecting the fee and market notification of a single
+// faulty sector, which has no power to remove.
+func (h *actorHarness) terminateBreachedSector(rt *mock.Runtime, sector *miner.SectorOnChainInfo, dealID abi.DealID, expectedFee abi.TokenAmount) {
+	rt.SetCaller(builtin.StorageMarketActorAddr, builtin.StorageMarketActorCodeID)
+	rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+
+	expectQueryNetworkInfo(rt, h)
+	if expectedFee.GreaterThan(big.Zero()) {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedFee, nil, exitcode.Ok)
+	}
+	expectUpdatePledgeTotal(rt, sector.InitialPledge.Neg(), big.Zero(), expectedFee.Neg())
+	rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.OnMinerSectorsTerminate, &market.OnMinerSectorsTerminateParams{
+		Epoch:   rt.Epoch(),
+		DealIDs: sector.DealIDs,
+	}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+	// The sector is faulty, so has no power to remove.
+	sectorPower := miner.PowerForSectors(h.sectorSize, []*miner.SectorOnChainInfo{sector})
+	expectUpdateClaimedPower(rt, miner.NewPowerPairZero(), sectorPower.Neg())
+
+	rt.Call(h.a.TerminateBreachedSector, &builtin.TerminateBreachedSectorParams{
+		SectorNumber: sector.SectorNumber,
+		DealID:       dealID,
+	})
+	rt.Verify()
 }
 
 func (h *actorHarness) reportConsensusFault(rt *mock.Runtime, from addr.Address, fault *runtime.ConsensusFault) {
+	h.reportConsensusFaultWithBond(rt, from, fault, miner.ConsensusFaultReporterBond)
+}
+
+// Reports a consensus fault with the given bond.
+// A nil fault indicates a report that fails verification. An invalid report forfeits the required bond,
+// refunding any excess, while a report of a fault already penalized aborts.
+func (h *actorHarness) reportConsensusFaultWithBond(rt *mock.Runtime, from addr.Address, fault *runtime.ConsensusFault, bond abi.TokenAmount) {
 	rt.SetCaller(from, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
 	params := &miner.ReportConsensusFaultParams{
@@ -4730,11 +7830,38 @@ func (h *actorHarness) reportConsensusFault(rt *mock.Runtime, from addr.Address,
 		BlockHeader2:     nil,
 		BlockHeaderExtra: nil,
 	}
+	rt.SetBalance(big.Add(rt.Balance(), bond))
+	rt.SetReceived(bond)
+	defer rt.SetReceived(big.Zero())
 
-	if fault != nil {
-		rt.ExpectVerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra, fault, nil)
-	} else {
+	if bond.LessThan(miner.ConsensusFaultReporterBond) {
+		rt.Call(h.a.ReportConsensusFault, params)
+		rt.Verify()
+		return
+	}
+
+	if fault == nil {
 		rt.ExpectVerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra, nil, fmt.Errorf("no fault"))
+	} else {
+		rt.ExpectVerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra, fault, nil)
+	}
+	if fault != nil && fault.Target == h.receiver && fault.Epoch < rt.Epoch() && fault.Epoch < h.getInfo(rt).ConsensusFaultElapsed {
+		// A report of a fault already penalized aborts, refunding the bond.
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too old", func() {
+			rt.Call(h.a.ReportConsensusFault, params)
+		})
+		rt.SetBalance(big.Sub(rt.Balance(), bond))
+		rt.Verify()
+		return
+	}
+	if fault == nil || fault.Target != h.receiver || fault.Epoch >= rt.Epoch() {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, miner.ConsensusFaultReporterBond, nil, exitcode.Ok)
+		if excess := big.Sub(bond, miner.ConsensusFaultReporterBond); excess.GreaterThan(big.Zero()) {
+			rt.ExpectSend(from, builtin.MethodSend, nil, excess, nil, exitcode.Ok)
+		}
+		rt.Call(h.a.ReportConsensusFault, params)
+		rt.Verify()
+		return
 	}
 
 	currentReward := reward.ThisEpochRewardReturn{
@@ -4744,9 +7871,10 @@ func (h *actorHarness) reportConsensusFault(rt *mock.Runtime, from addr.Address,
 	rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.ThisEpochReward, nil, big.Zero(), &currentReward, exitcode.Ok)
 
 	thisEpochReward := smoothing.Estimate(&h.epochRewardSmooth)
-	penaltyTotal := miner.ConsensusFaultPenalty(thisEpochReward)
+	penaltyTotal, err := miner.ConsensusFaultPenalty(thisEpochReward, rt.NetworkVersion())
+	require.NoError(h.t, err)
 	rewardTotal := miner.RewardForConsensusSlashReport(thisEpochReward)
-	rt.ExpectSend(from, builtin.MethodSend, nil, rewardTotal, nil, exitcode.Ok)
+	rt.ExpectSend(from, builtin.MethodSend, nil, big.Add(rewardTotal, bond), nil, exitcode.Ok)
 
 	// pay fault fee
 	toBurn := big.Sub(penaltyTotal, rewardTotal)
@@ -4757,6 +7885,10 @@ func (h *actorHarness) reportConsensusFault(rt *mock.Runtime, from addr.Address,
 }
 
 func (h *actorHarness) applyRewards(rt *mock.Runtime, amt, penalty abi.TokenAmount) {
+	h.applyRewardsWithSpec(rt, amt, penalty, builtin.VestingSpecBlockReward)
+}
+
+func (h *actorHarness) applyRewardsWithSpec(rt *mock.Runtime, amt, penalty abi.TokenAmount, specID builtin.VestingSpecID) {
 	// This harness function does not handle the state where apply rewards is
 	// on a miner with existing fee debt.  This state is not protocol reachable
 	// because currently fee debt prevents election participation.
@@ -4764,39 +7896,38 @@ func (h *actorHarness) applyRewards(rt *mock.Runtime, amt, penalty abi.TokenAmou
 	// We further assume the miner can pay the penalty.  If the miner
 	// goes into debt we can't rely on the harness call
 	// TODO unify those cases
-	lockAmt, _ := miner.LockedRewardFromReward(amt)
-	pledgeDelta := big.Sub(lockAmt, penalty)
+	lockAmt, _ := miner.LockedRewardFromReward(amt, specID)
 
 	rt.SetCaller(builtin.RewardActorAddr, builtin.RewardActorCodeID)
 	rt.ExpectValidateCallerAddr(builtin.RewardActorAddr)
 	// expect pledge update
-	rt.ExpectSend(
-		builtin.StoragePowerActorAddr,
-		builtin.MethodsPower.UpdatePledgeTotal,
-		&pledgeDelta,
-		abi.NewTokenAmount(0),
-		nil,
-		exitcode.Ok,
-	)
+	expectUpdatePledgeTotal(rt, big.Zero(), big.Zero(), big.Sub(lockAmt, penalty))
 
 	if penalty.GreaterThan(big.Zero()) {
 		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, penalty, nil, exitcode.Ok)
 	}
 
-	rt.Call(h.a.ApplyRewards, &builtin.ApplyRewardParams{Reward: amt, Penalty: penalty})
+	rt.Call(h.a.ApplyRewards, &builtin.ApplyRewardParams{Reward: amt, Penalty: penalty, VestingSpecID: specID})
 	rt.Verify()
 }
 
 type cronConfig struct {
-	noEnrollment              bool // true if expect not to continue enrollment false otherwise
-	expectedEnrollment        abi.ChainEpoch
-	detectedFaultsPowerDelta  *miner.PowerPair
-	expiredSectorsPowerDelta  *miner.PowerPair
+	noEnrollment             bool // true if expect not to continue enrollment false otherwise
+	expectedEnrollment       abi.ChainEpoch
+	detectedFaultsPowerDelta *miner.PowerPair
+	expiredSectorsPowerDelta *miner.PowerPair
+	// Expected change in faulty power, if not the negation of detectedFaultsPowerDelta.
+	faultyPowerDelta          *miner.PowerPair
 	expiredSectorsPledgeDelta abi.TokenAmount
 	continuedFaultsPenalty    abi.TokenAmount // Expected amount burnt to pay continued fault penalties.
 	expiredPrecommitPenalty   abi.TokenAmount // Expected amount burnt to pay for expired precommits
 	repaidFeeDebt             abi.TokenAmount // Expected amount burnt to repay fee debt.
 	penaltyFromUnlocked       abi.TokenAmount // Expected reduction in unlocked balance from penalties exceeding vesting funds.
+	// Expected notification of expired pre-commits to the miner's notification receiver, and its exit code.
+	expiredPrecommitNotification *miner.PreCommitsExpiredParams
+	notificationExit             exitcode.ExitCode
+	// Expected reminder of expiring sectors to the miner's notification receiver, which exits with notificationExit.
+	expirationReminder *miner.SectorsExpiringParams
 }
 
 func (h *actorHarness) onDeadlineCron(rt *mock.Runtime, config *cronConfig) {
@@ -4813,16 +7944,16 @@ func (h *actorHarness) onDeadlineCron(rt *mock.Runtime, config *cronConfig) {
 		powerDelta = powerDelta.Add(*config.expiredSectorsPowerDelta)
 	}
 
-	if !powerDelta.IsZero() {
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedPower, &power.UpdateClaimedPowerParams{
-			RawByteDelta:         powerDelta.Raw,
-			QualityAdjustedDelta: powerDelta.QA,
-		},
-			abi.NewTokenAmount(0), nil, exitcode.Ok)
+	faultyPowerDelta := miner.NewPowerPairZero()
+	if config.faultyPowerDelta != nil {
+		faultyPowerDelta = *config.faultyPowerDelta
+	} else if config.detectedFaultsPowerDelta != nil {
+		faultyPowerDelta = config.detectedFaultsPowerDelta.Neg()
 	}
+	expectUpdateClaimedPower(rt, powerDelta, faultyPowerDelta)
 
 	penaltyTotal := big.Zero()
-	pledgeDelta := big.Zero()
+	lockedRewardsDelta := big.Zero()
 	if !config.continuedFaultsPenalty.NilOrZero() {
 		penaltyTotal = big.Add(penaltyTotal, config.continuedFaultsPenalty)
 	}
@@ -4847,17 +7978,45 @@ func (h *actorHarness) onDeadlineCron(rt *mock.Runtime, config *cronConfig) {
 		if !config.penaltyFromUnlocked.NilOrZero() {
 			penaltyFromVesting = big.Sub(penaltyFromVesting, config.penaltyFromUnlocked)
 		}
-		pledgeDelta = big.Sub(pledgeDelta, penaltyFromVesting)
+		lockedRewardsDelta = big.Sub(lockedRewardsDelta, penaltyFromVesting)
 	}
 
+	initialPledgeDelta := big.Zero()
 	if !config.expiredSectorsPledgeDelta.NilOrZero() {
-		pledgeDelta = big.Add(pledgeDelta, config.expiredSectorsPledgeDelta)
+		initialPledgeDelta = config.expiredSectorsPledgeDelta
 	}
+	preCommitDepositDelta := big.Zero()
+	if !config.expiredPrecommitPenalty.NilOrZero() {
+		preCommitDepositDelta = config.expiredPrecommitPenalty.Neg()
+	}
+
+	lockedRewardsDelta = big.Sub(lockedRewardsDelta, immediatelyVestingFunds(rt, &st))
+
+	expectUpdatePledgeTotal(rt, initialPledgeDelta, preCommitDepositDelta, lockedRewardsDelta)
 
-	pledgeDelta = big.Sub(pledgeDelta, immediatelyVestingFunds(rt, &st))
+	// Report of a proving period ending with this deadline, in which any sector that was or is
+	// detected faulty was not proven.
+	if dlInfo := st.DeadlineInfo(rt.Epoch()); dlInfo.PeriodStarted() && dlInfo.Index == miner.WPoStPeriodDeadlines-1 {
+		missedPoSt, err := st.HasFaultyPower(rt.AdtStore())
+		require.NoError(h.t, err)
+		missedPoSt = missedPoSt || (config.detectedFaultsPowerDelta != nil && !config.detectedFaultsPowerDelta.IsZero())
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.RecordProvingPeriod,
+			&power.RecordProvingPeriodParams{MissedPoSt: missedPoSt}, big.Zero(), nil, exitcode.Ok)
+	}
 
-	if !pledgeDelta.IsZero() {
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &pledgeDelta, big.Zero(), nil, exitcode.Ok)
+	if config.expiredPrecommitNotification != nil {
+		info, err := st.GetInfo(rt.AdtStore())
+		require.NoError(h.t, err)
+		require.NotNil(h.t, info.NotificationReceiver)
+		rt.ExpectSend(*info.NotificationReceiver, miner.MethodNotifyPreCommitsExpired, config.expiredPrecommitNotification,
+			big.Zero(), nil, config.notificationExit)
+	}
+	if config.expirationReminder != nil {
+		info, err := st.GetInfo(rt.AdtStore())
+		require.NoError(h.t, err)
+		require.NotNil(h.t, info.NotificationReceiver)
+		rt.ExpectSend(*info.NotificationReceiver, miner.MethodNotifySectorsExpiring, config.expirationReminder,
+			big.Zero(), nil, config.notificationExit)
 	}
 
 	// Re-enrollment for next period.
@@ -4867,7 +8026,7 @@ func (h *actorHarness) onDeadlineCron(rt *mock.Runtime, config *cronConfig) {
 	}
 
 	eventPayloadBuf := bytes.Buffer{}
-	payload := &miner0.CronEventPayload{EventType: miner.CronEventProvingDeadline}
+	payload := &miner.CronEventPayload{EventType: miner.CronEventProvingDeadline, Version: miner.CronEventPayloadVersion}
 	require.NoError(h.t, payload.MarshalCBOR(&eventPayloadBuf), "failed to marshal event payload")
 
 	rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
@@ -4880,10 +8039,21 @@ func (h *actorHarness) onDeadlineCron(rt *mock.Runtime, config *cronConfig) {
 }
 
 func (h *actorHarness) withdrawFunds(rt *mock.Runtime, amountRequested, expectedWithdrawn, expectedDebtRepaid abi.TokenAmount) {
-	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
-	rt.ExpectValidateCallerAddr(h.owner)
+	h.withdrawFundsFrom(rt, h.owner, amountRequested, expectedWithdrawn, expectedDebtRepaid)
+}
 
-	rt.ExpectSend(h.owner, builtin.MethodSend, nil, expectedWithdrawn, nil, exitcode.Ok)
+func (h *actorHarness) withdrawFundsFrom(rt *mock.Runtime, caller addr.Address, amountRequested, expectedWithdrawn, expectedDebtRepaid abi.TokenAmount) {
+	info := h.getInfo(rt)
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	if info.Beneficiary == info.Owner {
+		rt.ExpectValidateCallerAddr(info.Owner)
+	} else {
+		rt.ExpectValidateCallerAddr(info.Owner, info.Beneficiary)
+	}
+
+	if expectedWithdrawn.GreaterThan(big.Zero()) {
+		rt.ExpectSend(info.Beneficiary, builtin.MethodSend, nil, expectedWithdrawn, nil, exitcode.Ok)
+	}
 	if expectedDebtRepaid.GreaterThan(big.Zero()) {
 		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedDebtRepaid, nil, exitcode.Ok)
 	}
@@ -4896,7 +8066,26 @@ func (h *actorHarness) withdrawFunds(rt *mock.Runtime, amountRequested, expected
 
 	rt.Verify()
 
-	assert.Equal(h.t, expectedWithdrawn, *withdrawn, "return value indicates %s withdrawn but expected %s", *withdrawn, expectedWithdrawn)
+	assert.True(h.t, expectedWithdrawn.Equals(*withdrawn), "return value indicates %s withdrawn but expected %s", *withdrawn, expectedWithdrawn)
+}
+
+// Expects the burnt deposit to be paid from the miner's balance, so assumes it has no vesting funds.
+// Cleans up expired pre-commits, expecting the deposit burnt and, if not nil, the notification to the miner's receiver.
+func (h *actorHarness) cleanUpExpiredPreCommits(rt *mock.Runtime, expectedBurn abi.TokenAmount, notification *miner.PreCommitsExpiredParams) {
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(h.owner, h.worker)
+	if expectedBurn.GreaterThan(big.Zero()) {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedBurn, nil, exitcode.Ok)
+	}
+	expectUpdatePledgeTotal(rt, big.Zero(), expectedBurn.Neg(), big.Zero())
+	if notification != nil {
+		receiver := h.getInfo(rt).NotificationReceiver
+		require.NotNil(h.t, receiver)
+		rt.ExpectSend(*receiver, miner.MethodNotifyPreCommitsExpired, notification, big.Zero(), nil, exitcode.Ok)
+	}
+
+	rt.Call(h.a.CleanUpExpiredPreCommits, nil)
+	rt.Verify()
 }
 
 func (h *actorHarness) repayDebt(rt *mock.Runtime, value, expectedRepayedFromVest, expectedRepaidFromBalance abi.TokenAmount) {
@@ -4906,8 +8095,7 @@ func (h *actorHarness) repayDebt(rt *mock.Runtime, value, expectedRepayedFromVes
 	rt.SetBalance(big.Sum(rt.Balance(), value))
 	rt.SetReceived(value)
 	if expectedRepayedFromVest.GreaterThan(big.Zero()) {
-		pledgeDelta := expectedRepayedFromVest.Neg()
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &pledgeDelta, big.Zero(), nil, exitcode.Ok)
+		expectUpdatePledgeTotal(rt, big.Zero(), big.Zero(), expectedRepayedFromVest.Neg())
 	}
 
 	totalRepaid := big.Sum(expectedRepayedFromVest, expectedRepaidFromBalance)
@@ -4929,6 +8117,62 @@ func (h *actorHarness) compactPartitions(rt *mock.Runtime, deadline uint64, part
 	rt.Verify()
 }
 
+func (h *actorHarness) compactPartitions2(rt *mock.Runtime, param *miner.CompactPartitions2Params) {
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+
+	rt.Call(h.a.CompactPartitions2, param)
+	rt.Verify()
+}
+
+func (h *actorHarness) movePartitions(rt *mock.Runtime, origDeadline, destDeadline uint64, partitions bitfield.BitField) {
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+
+	rt.Call(h.a.MovePartitions, &miner.MovePartitionsParams{
+		OrigDeadline: origDeadline,
+		DestDeadline: destDeadline,
+		Partitions:   partitions,
+	})
+	rt.Verify()
+}
+
+func (h *actorHarness) rebalanceSectors(rt *mock.Runtime, origDeadline, destDeadline uint64, partitions bitfield.BitField, maxSectors uint64) {
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+
+	rt.Call(h.a.RebalanceSectors, &miner.RebalanceSectorsParams{
+		OrigDeadline: origDeadline,
+		DestDeadline: destDeadline,
+		Partitions:   partitions,
+		MaxSectors:   maxSectors,
+	})
+	rt.Verify()
+}
+
+func (h *actorHarness) deadlineSectorCounts(rt *mock.Runtime) []uint64 {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.a.DeadlineSectorCounts, nil).(*miner.DeadlineSectorCountsReturn)
+	rt.Verify()
+	return ret.LiveSectors
+}
+
+func (h *actorHarness) declareMaintenanceWindow(rt *mock.Runtime, start, duration abi.ChainEpoch) {
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+
+	rt.Call(h.a.DeclareMaintenanceWindow, &miner.DeclareMaintenanceWindowParams{
+		Start:    start,
+		Duration: duration,
+	})
+	rt.Verify()
+
+	w := getState(rt).MaintenanceWindow
+	require.NotNil(h.t, w)
+	assert.Equal(h.t, start, w.Start)
+	assert.Equal(h.t, start+duration, w.End)
+}
+
 func (h *actorHarness) continuedFaultPenalty(sectors []*miner.SectorOnChainInfo) abi.TokenAmount {
 	_, qa := powerForSectors(h.sectorSize, sectors)
 	return miner.PledgePenaltyForContinuedFault(h.epochRewardSmooth, h.epochQAPowerSmooth, qa)
@@ -4950,6 +8194,23 @@ func (h *actorHarness) makePreCommit(sectorNo abi.SectorNumber, challenge, expir
 	}
 }
 
+func (h *actorHarness) makeProveCommitSectorsNI(sectorNos []abi.SectorNumber, challenge, expiration abi.ChainEpoch) *miner.ProveCommitSectorsNIParams {
+	sectors := make([]miner.SectorNIActivationInfo, len(sectorNos))
+	for i, sectorNo := range sectorNos {
+		sectors[i] = miner.SectorNIActivationInfo{
+			SectorNumber:  sectorNo,
+			SealedCID:     tutil.MakeCID(fmt.Sprintf("commr-%d", sectorNo), &miner.SealedCIDPrefix),
+			SealRandEpoch: challenge,
+			Expiration:    expiration,
+		}
+	}
+	return &miner.ProveCommitSectorsNIParams{
+		Sectors:        sectors,
+		SealProof:      h.sealProofType,
+		AggregateProof: make([]byte, 1024),
+	}
+}
+
 func (h *actorHarness) setPeerID(rt *mock.Runtime, newID abi.PeerID) {
 	params := miner.ChangePeerIDParams{NewID: newID}
 
@@ -5157,8 +8418,18 @@ func getState(rt *mock.Runtime) *miner.State {
 	return &st
 }
 
+func makeWorkerKeyChangeCronEventParams(t testing.TB, epoch abi.ChainEpoch) *power.EnrollCronEventParams {
+	eventPayload := miner.CronEventPayload{EventType: miner.CronEventWorkerKeyChange, Version: miner.CronEventPayloadVersion}
+	buf := bytes.Buffer{}
+	require.NoError(t, eventPayload.MarshalCBOR(&buf))
+	return &power.EnrollCronEventParams{
+		EventEpoch: epoch,
+		Payload:    buf.Bytes(),
+	}
+}
+
 func makeDeadlineCronEventParams(t testing.TB, epoch abi.ChainEpoch) *power.EnrollCronEventParams {
-	eventPayload := miner.CronEventPayload{EventType: miner.CronEventProvingDeadline}
+	eventPayload := miner.CronEventPayload{EventType: miner.CronEventProvingDeadline, Version: miner.CronEventPayloadVersion}
 	buf := bytes.Buffer{}
 	err := eventPayload.MarshalCBOR(&buf)
 	require.NoError(t, err)
@@ -5262,6 +8533,31 @@ func fixedHasher(target uint64) func([]byte) [32]byte {
 	}
 }
 
+// Expects a pledge update to the power actor, if any of the deltas are non-zero.
+func expectUpdatePledgeTotal(rt *mock.Runtime, initialPledgeDelta, preCommitDepositDelta, lockedRewardsDelta abi.TokenAmount) {
+	if initialPledgeDelta.IsZero() && preCommitDepositDelta.IsZero() && lockedRewardsDelta.IsZero() {
+		return
+	}
+	params := power.UpdatePledgeTotalParams{
+		InitialPledgeDelta:    initialPledgeDelta,
+		PreCommitDepositDelta: preCommitDepositDelta,
+		LockedRewardsDelta:    lockedRewardsDelta,
+	}
+	rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &params, big.Zero(), nil, exitcode.Ok)
+}
+
+func expectUpdateClaimedPower(rt *mock.Runtime, delta, faultyDelta miner.PowerPair) {
+	if delta.Raw.NilOrZero() && delta.QA.NilOrZero() && faultyDelta.QA.NilOrZero() {
+		return
+	}
+	params := power.UpdateClaimedPowerParams{
+		RawByteDelta:               delta.Raw,
+		QualityAdjustedDelta:       delta.QA,
+		FaultyQualityAdjustedDelta: faultyDelta.QA,
+	}
+	rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdateClaimedPower, &params, big.Zero(), nil, exitcode.Ok)
+}
+
 func expectQueryNetworkInfo(rt *mock.Runtime, h *actorHarness) {
 	currentPower := power.CurrentTotalPowerReturn{
 		RawBytePower:            h.networkRawPower,
@@ -4,7 +4,9 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/filecoin-project/go-state-types/network"
 	rtt "github.com/filecoin-project/go-state-types/rt"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/math"
@@ -56,8 +58,11 @@ var TerminationRewardFactor = builtin.BigFrac{ // PARAM_SPEC
 // Maximum number of lifetime days penalized when a sector is terminated.
 const TerminationLifetimeCap = 140 // PARAM_SPEC
 
-// Multiplier of whole per-winner rewards for a consensus fault penalty.
-const ConsensusFaultFactor = 5
+// Multiplier of whole per-winner rewards for a consensus fault penalty, by the network version from which it applies.
+// A change to the multiplier takes effect by appending the new value for the version that introduces it.
+var ConsensusFaultFactors = []builtin.VersionedInt64{
+	{From: network.Version16, Value: 5},
+}
 
 // Fraction of total reward (block reward + gas reward) to be locked up as of V6
 var LockedRewardFactorNum = big.NewInt(75)
@@ -68,6 +73,11 @@ var BaseRewardForDisputedWindowPoSt = big.Mul(big.NewInt(4), builtin.TokenPrecis
 // Base penalty for a successful disputed window post proof.
 var BasePenaltyForDisputedWindowPoSt = big.Mul(big.NewInt(20), builtin.TokenPrecision) // PARAM_SPEC
 
+// Minimum bond a reporter must send with a consensus fault report.
+// The bond is returned with the reward if the fault is verified, and burnt if verification fails,
+// so that each call to the (expensive) fault verification is paid for by the reporter.
+var ConsensusFaultReporterBond = big.Div(builtin.TokenPrecision, big.NewInt(10)) // PARAM_SPEC
+
 // The projected block reward a sector would earn over some period.
 // Also known as "BR(t)".
 // BR(t) = ProjectedRewardFraction(t) * SectorQualityAdjustedPower
@@ -103,6 +113,29 @@ func PledgePenaltyForContinuedFault(rewardEstimate, networkQAPowerEstimate smoot
 	return ExpectedRewardForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower, ContinuedFaultProjectionPeriod)
 }
 
+// Fraction of the continued fault fee paid by sectors declared faulty during a maintenance window, while the window lasts.
+var MaintenanceFaultFeeFactor = builtin.BigFrac{
+	Numerator:   big.NewInt(1), // PARAM_SPEC
+	Denominator: big.NewInt(4),
+}
+
+// The reduced continued fault fee paid by sectors declared faulty during a maintenance window.
+func PledgePenaltyForContinuedMaintenanceFault(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
+	fee := PledgePenaltyForContinuedFault(rewardEstimate, networkQAPowerEstimate, qaSectorPower)
+	return big.Div(big.Mul(fee, MaintenanceFaultFeeFactor.Numerator), MaintenanceFaultFeeFactor.Denominator)
+}
+
+// The continued fault fee paid at the end of a deadline by its faulty power, of which maintenanceFaultyQA
+// was declared faulty during an active maintenance window and pays the reduced fee.
+func continuedFaultPenalty(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, faultyQA, maintenanceFaultyQA abi.StoragePower) abi.TokenAmount {
+	maintenanceFaultyQA = big.Min(maintenanceFaultyQA, faultyQA)
+	penalty := PledgePenaltyForContinuedFault(rewardEstimate, networkQAPowerEstimate, big.Sub(faultyQA, maintenanceFaultyQA))
+	if maintenanceFaultyQA.GreaterThan(big.Zero()) {
+		penalty = big.Add(penalty, PledgePenaltyForContinuedMaintenanceFault(rewardEstimate, networkQAPowerEstimate, maintenanceFaultyQA))
+	}
+	return penalty
+}
+
 // Lower bound on the penalty for a terminating sector.
 // It is a projection of the expected reward earned by the sector.
 // Also known as "SP(t)"
@@ -196,18 +229,24 @@ func RepayDebtsOrAbort(rt Runtime, st *State) abi.TokenAmount {
 	return toBurn
 }
 
-func ConsensusFaultPenalty(thisEpochReward abi.TokenAmount) abi.TokenAmount {
+// The penalty for a consensus fault, at the network version under which it is reported.
+func ConsensusFaultPenalty(thisEpochReward abi.TokenAmount, nv network.Version) (abi.TokenAmount, error) {
+	factor, ok := builtin.ValueAtNetworkVersion(ConsensusFaultFactors, nv)
+	if !ok {
+		return big.Zero(), xerrors.Errorf("no consensus fault factor for network version %d", nv)
+	}
 	return big.Div(
-		big.Mul(thisEpochReward, big.NewInt(ConsensusFaultFactor)),
+		big.Mul(thisEpochReward, big.NewInt(factor)),
 		big.NewInt(builtin.ExpectedLeadersPerEpoch),
-	)
+	), nil
 }
 
 // Returns the amount of a reward to vest, and the vesting schedule, for a reward amount.
-func LockedRewardFromReward(reward abi.TokenAmount) (abi.TokenAmount, *VestSpec) {
+// The schedule is nil if the vesting spec is unknown.
+func LockedRewardFromReward(reward abi.TokenAmount, specID builtin.VestingSpecID) (abi.TokenAmount, *VestSpec) {
 	// Locked amount is 75% of award.
 	lockAmount := big.Div(big.Mul(reward, LockedRewardFactorNum), LockedRewardFactorDenom)
-	return lockAmount, &RewardVestingSpec
+	return lockAmount, VestingSpecs[specID]
 }
 
 var EstimatedSingleProveCommitGasUsage = big.NewInt(49299973) // PARAM_SPEC
@@ -226,6 +265,20 @@ func AggregatePreCommitNetworkFee(aggregateSize int, baseFee abi.TokenAmount) ab
 	return aggregateNetworkFee(aggregateSize, EstimatedSinglePreCommitGasUsage, baseFee)
 }
 
+// Returns the network fee that PreCommitSectorBatch burns for a batch of sectors at a base fee.
+// A batch of a single sector pays no fee.
+func EstimatePreCommitBatchFee(batchSize int, baseFee abi.TokenAmount) abi.TokenAmount {
+	if batchSize <= 1 {
+		return big.Zero()
+	}
+	return AggregatePreCommitNetworkFee(batchSize, baseFee)
+}
+
+// Returns the network fee that ProveCommitAggregate burns for an aggregate proof of sectors at a base fee.
+func EstimateProveCommitAggregateFee(aggregateSize int, baseFee abi.TokenAmount) abi.TokenAmount {
+	return AggregateProveCommitNetworkFee(aggregateSize, baseFee)
+}
+
 func aggregateNetworkFee(aggregateSize int, gasUsage big.Int, baseFee abi.TokenAmount) abi.TokenAmount {
 	effectiveGasFee := big.Max(baseFee, BatchBalancer)
 	networkFeeNum := big.Product(effectiveGasFee, gasUsage, big.NewInt(int64(aggregateSize)), BatchDiscount.Numerator)
@@ -5,7 +5,9 @@ import (
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/miner"
@@ -169,6 +171,31 @@ func TestContinuedFault(t *testing.T) {
 	})
 }
 
+func TestConsensusFaultPenalty(t *testing.T) {
+	thisEpochReward := abi.NewTokenAmount(1 << 50)
+
+	t.Run("penalty uses the factor for the network version", func(t *testing.T) {
+		defer func(factors []builtin.VersionedInt64) { miner.ConsensusFaultFactors = factors }(miner.ConsensusFaultFactors)
+		miner.ConsensusFaultFactors = []builtin.VersionedInt64{
+			{From: network.Version16, Value: 5},
+			{From: network.Version17, Value: 10},
+		}
+
+		penalty, err := miner.ConsensusFaultPenalty(thisEpochReward, network.Version16)
+		require.NoError(t, err)
+		assert.Equal(t, big.Div(big.Mul(thisEpochReward, big.NewInt(5)), big.NewInt(builtin.ExpectedLeadersPerEpoch)), penalty)
+
+		penalty, err = miner.ConsensusFaultPenalty(thisEpochReward, network.Version17)
+		require.NoError(t, err)
+		assert.Equal(t, big.Div(big.Mul(thisEpochReward, big.NewInt(10)), big.NewInt(builtin.ExpectedLeadersPerEpoch)), penalty)
+	})
+
+	t.Run("no penalty before the first network version with a factor", func(t *testing.T) {
+		_, err := miner.ConsensusFaultPenalty(thisEpochReward, network.Version15)
+		require.Error(t, err)
+	})
+}
+
 func TestExpectedRewardForPowerClamptedAtAttoFIL(t *testing.T) {
 	t.Run("expected zero valued BR clamped at 1 attofil", func(t *testing.T) {
 		epochTargetReward := abi.NewTokenAmount(1 << 50)
@@ -282,3 +309,33 @@ func TestAggregateNetworkFee(t *testing.T) {
 		assert.Equal(t, atTwentyBaseFeeProve, big.Mul(big.NewInt(3), atTwentyBaseFeePre))
 	})
 }
+
+func TestEstimateAggregateFeeFunctions(t *testing.T) {
+	threeNano := big.Mul(big.NewInt(3), builtin.OneNanoFIL)
+	sixNano := big.Mul(big.NewInt(6), builtin.OneNanoFIL)
+
+	for _, tc := range []struct {
+		size    int
+		baseFee abi.TokenAmount
+	}{
+		{1, big.Zero()},
+		{2, big.Zero()},
+		{10, threeNano},
+		{100, miner.BatchBalancer},
+		{100, sixNano},
+		{miner.MaxAggregatedSectors, sixNano},
+	} {
+		preCommitFee := miner.EstimatePreCommitBatchFee(tc.size, tc.baseFee)
+		if tc.size == 1 {
+			assert.Equal(t, big.Zero(), preCommitFee)
+		} else {
+			assert.Equal(t, miner.AggregatePreCommitNetworkFee(tc.size, tc.baseFee), preCommitFee)
+		}
+		assert.Equal(t, miner.AggregateProveCommitNetworkFee(tc.size, tc.baseFee), miner.EstimateProveCommitAggregateFee(tc.size, tc.baseFee))
+	}
+
+	// Below the batch balancer, the fee is charged at the balancer.
+	assert.Equal(t, miner.EstimatePreCommitBatchFee(10, miner.BatchBalancer), miner.EstimatePreCommitBatchFee(10, threeNano))
+	assert.Equal(t, big.Mul(big.NewInt(2), miner.EstimateProveCommitAggregateFee(10, threeNano)),
+		miner.EstimateProveCommitAggregateFee(10, big.Mul(big.NewInt(2), miner.BatchBalancer)))
+}
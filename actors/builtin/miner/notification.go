@@ -0,0 +1,35 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+)
+
+// Method invoked on a miner's notification receiver when expired pre-commitments are cleaned up, burning their
+// deposits, whether by deadline cron or by CleanUpExpiredPreCommits. The message carries no value.
+// Any actor that is not built in and implements this method, with PreCommitsExpiredParams, may be a notification
+// receiver. The receiver's exit code is ignored.
+const MethodNotifyPreCommitsExpired = builtin.MethodsExternalStart + 2
+
+// Method invoked on a miner's notification receiver when deadline cron finds sectors due to expire in the number
+// of proving periods set by the owner with SetExpirationReminder, so that the receiver may have them extended.
+// Like MethodNotifyPreCommitsExpired, it lies in the range of methods that no built-in actor implements.
+// The message carries no value, and the receiver's exit code is ignored.
+// Each sector is reminded of once, by the cron of its deadline the set number of proving periods before it expires.
+const MethodNotifySectorsExpiring = builtin.MethodsExternalStart + 3
+
+type PreCommitsExpiredParams struct {
+	// Numbers of the sectors whose pre-commitments were cleaned up.
+	Sectors bitfield.BitField
+	// Total pre-commit deposit burnt for the sectors.
+	DepositBurnt abi.TokenAmount
+}
+
+type SectorsExpiringParams struct {
+	// Numbers of the sectors due to expire on time, in the deadline whose cron sent the reminder.
+	Sectors bitfield.BitField
+	// The epoch at which the sectors expire, at the end of their deadline, unless extended.
+	Expiration abi.ChainEpoch
+}
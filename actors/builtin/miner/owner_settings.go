@@ -0,0 +1,72 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"golang.org/x/xerrors"
+)
+
+// Thresholds set by a miner's owner on the messages that commit sectors, letting an operator encode its
+// cost policy on-chain so that no automation submitting on its behalf can exceed it.
+// The thresholds are checked on entry to each method, before any state is changed.
+type OwnerSettings struct {
+	// Minimum number of sectors in a PreCommitSectorBatch, or zero for no minimum.
+	// Single sectors pre-committed by PreCommitSector are not subject to this minimum.
+	MinPreCommitBatchSize uint64
+	// Maximum number of sectors addressed by a single pre-commit batch, aggregate prove-commit or replica
+	// update message, or zero for no maximum beyond the protocol's limits.
+	MaxSectorsPerMessage uint64
+	// Maximum network fee payable for a single pre-commit batch or aggregate prove-commit, or zero for
+	// no maximum.
+	MaxBatchNetworkFee abi.TokenAmount
+}
+
+// Returns the owner's settings, which have no thresholds if never set.
+func (st *State) GetOwnerSettings() OwnerSettings {
+	if st.OwnerSettings == nil {
+		return OwnerSettings{MaxBatchNetworkFee: big.Zero()}
+	}
+	return *st.OwnerSettings
+}
+
+func (s *OwnerSettings) validate() error {
+	if s.MinPreCommitBatchSize > PreCommitSectorBatchMaxSize {
+		return xerrors.Errorf("minimum pre-commit batch size %d exceeds maximum batch size %d",
+			s.MinPreCommitBatchSize, PreCommitSectorBatchMaxSize)
+	}
+	if s.MaxSectorsPerMessage != 0 && s.MinPreCommitBatchSize > s.MaxSectorsPerMessage {
+		return xerrors.Errorf("minimum pre-commit batch size %d exceeds maximum sectors per message %d",
+			s.MinPreCommitBatchSize, s.MaxSectorsPerMessage)
+	}
+	if s.MaxBatchNetworkFee.Nil() || s.MaxBatchNetworkFee.LessThan(big.Zero()) {
+		return xerrors.Errorf("maximum batch network fee %v must be non-negative", s.MaxBatchNetworkFee)
+	}
+	return nil
+}
+
+// Checks the number of sectors addressed by a message against the owner's maximum.
+func (s *OwnerSettings) checkSectorCount(count uint64) error {
+	if s.MaxSectorsPerMessage != 0 && count > s.MaxSectorsPerMessage {
+		return xerrors.Errorf("%d sectors exceeds owner maximum of %d per message", count, s.MaxSectorsPerMessage)
+	}
+	return nil
+}
+
+// Checks the network fee payable for a batch or aggregate against the owner's maximum.
+func (s *OwnerSettings) checkBatchNetworkFee(fee abi.TokenAmount) error {
+	if !s.MaxBatchNetworkFee.IsZero() && fee.GreaterThan(s.MaxBatchNetworkFee) {
+		return xerrors.Errorf("network fee %v exceeds owner maximum of %v", fee, s.MaxBatchNetworkFee)
+	}
+	return nil
+}
+
+// Checks a pre-commit batch against the owner's minimum batch size, maximum sectors and maximum fee.
+func (s *OwnerSettings) checkPreCommitBatch(count uint64, baseFee abi.TokenAmount) error {
+	if count < s.MinPreCommitBatchSize {
+		return xerrors.Errorf("batch of %d is below owner minimum of %d", count, s.MinPreCommitBatchSize)
+	}
+	if err := s.checkSectorCount(count); err != nil {
+		return err
+	}
+	return s.checkBatchNetworkFee(EstimatePreCommitBatchFee(int(count), baseFee))
+}
@@ -479,6 +479,22 @@ func (p *Partition) recordEarlyTermination(store adt.Store, epoch abi.ChainEpoch
 	return nil
 }
 
+// Returns the terminated sectors still awaiting processing of their early termination fees.
+func (p *Partition) PendingEarlyTerminations(store adt.Store) (bitfield.BitField, error) {
+	etQueue, err := LoadBitfieldQueue(store, p.EarlyTerminated, builtin.NoQuantization, PartitionEarlyTerminationArrayAmtBitwidth)
+	if err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to load early termination queue: %w", err)
+	}
+	var pending []bitfield.BitField
+	if err = etQueue.ForEach(func(_ abi.ChainEpoch, sectors bitfield.BitField) error {
+		pending = append(pending, sectors)
+		return nil
+	}); err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to iterate early termination queue: %w", err)
+	}
+	return bitfield.MultiMerge(pending...)
+}
+
 // Marks a collection of sectors as terminated.
 // The sectors are removed from Faults and Recoveries.
 // The epoch of termination is recorded for future termination fee calculation.
@@ -560,6 +576,63 @@ func (p *Partition) TerminateSectors(
 	return removed, nil
 }
 
+// An entry in a partition's expiration queue: the sectors and power scheduled to expire at an epoch.
+type PartitionExpiration struct {
+	Epoch abi.ChainEpoch
+	// Number of sectors expiring at the end of their committed life.
+	OnTimeSectors uint64
+	// Number of sectors expiring early due to being faulty for too long.
+	EarlySectors uint64
+	ActivePower  PowerPair
+	FaultyPower  PowerPair
+}
+
+// Returns the entries of the partition's expiration queue, in epoch order.
+// Entry epochs are as scheduled, i.e. quantized to the end of the partition's deadline.
+func (p *Partition) ExpirationSchedule(store adt.Store) ([]PartitionExpiration, error) {
+	queue, err := LoadExpirationQueue(store, p.ExpirationsEpochs, builtin.NoQuantization, PartitionExpirationAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load partition queue: %w", err)
+	}
+	var schedule []PartitionExpiration
+	var es ExpirationSet
+	err = queue.ForEach(&es, func(epoch int64) error {
+		onTime, err := es.OnTimeSectors.Count()
+		if err != nil {
+			return xerrors.Errorf("failed to count on-time sectors expiring at %d: %w", epoch, err)
+		}
+		early, err := es.EarlySectors.Count()
+		if err != nil {
+			return xerrors.Errorf("failed to count early sectors expiring at %d: %w", epoch, err)
+		}
+		schedule = append(schedule, PartitionExpiration{
+			Epoch:         abi.ChainEpoch(epoch),
+			OnTimeSectors: onTime,
+			EarlySectors:  early,
+			ActivePower:   es.ActivePower,
+			FaultyPower:   es.FaultyPower,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to iterate partition queue: %w", err)
+	}
+	return schedule, nil
+}
+
+// Returns the sectors scheduled to expire on time at an epoch, which is quantized as the partition's queue is.
+func (p *Partition) OnTimeExpirations(store adt.Store, epoch abi.ChainEpoch) (bitfield.BitField, error) {
+	queue, err := LoadExpirationQueue(store, p.ExpirationsEpochs, builtin.NoQuantization, PartitionExpirationAmtBitwidth)
+	if err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to load partition queue: %w", err)
+	}
+	es, err := queue.mayGet(epoch)
+	if err != nil {
+		return bitfield.BitField{}, err
+	}
+	return es.OnTimeSectors, nil
+}
+
 // PopExpiredSectors traverses the expiration queue up to and including some epoch, and marks all expiring
 // sectors as terminated.
 //
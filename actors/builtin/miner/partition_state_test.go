@@ -634,6 +634,33 @@ func TestPartitions(t *testing.T) {
 		ExpectBQ().Equals(t, queue)
 	})
 
+	t.Run("early terminations are pending until popped", func(t *testing.T) {
+		store, partition := setup(t)
+		sectorArr := sectorsArr(t, store, sectors)
+
+		pending, err := partition.PendingEarlyTerminations(store)
+		require.NoError(t, err)
+		assertBitfieldEmpty(t, pending)
+
+		_, err = partition.TerminateSectors(store, sectorArr, abi.ChainEpoch(3), bf(1, 3), sectorSize, quantSpec)
+		require.NoError(t, err)
+		_, err = partition.TerminateSectors(store, sectorArr, abi.ChainEpoch(5), bf(5), sectorSize, quantSpec)
+		require.NoError(t, err)
+
+		pending, err = partition.PendingEarlyTerminations(store)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, pending, 1, 3, 5)
+
+		// popping settles the earliest terminations first
+		_, hasMore, err := partition.PopEarlyTerminations(store, 2)
+		require.NoError(t, err)
+		assert.True(t, hasMore)
+		pending, err = partition.PendingEarlyTerminations(store)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, pending, 5)
+		assertBitfieldEquals(t, partition.Terminated, 1, 3, 5)
+	})
+
 	t.Run("test max sectors", func(t *testing.T) {
 		store := ipld.NewADTStore(context.Background())
 		partition := emptyPartition(t, store)
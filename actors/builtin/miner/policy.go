@@ -56,6 +56,11 @@ func init() {
 		panic("the challenge lookback cannot exceed one challenge window")
 	}
 
+	// A chain commitment must not be drawn before the deadline's challenge window could be known.
+	if WPoStChainCommitLookback > WPoStChallengeLookback {
+		panic("the chain commit lookback cannot exceed the challenge lookback")
+	}
+
 	// Deadlines are immutable when the challenge window is open, and during
 	// the previous challenge window.
 	immutableWindow := 2 * WPoStChallengeWindow
@@ -76,13 +81,42 @@ func init() {
 // We set this to same as MaxPartitionsPerDeadline so we can process that many partitions every deadline.
 const AddressedPartitionsMax = MaxPartitionsPerDeadline
 
-// Maximum number of unique "declarations" in batch operations.
-const DeclarationsMax = AddressedPartitionsMax
+// Maximum number of "declarations" in batch operations.
+// Declarations addressing the same partition are merged before the partitions addressed are limited,
+// so a batch may hold several declarations for each partition.
+const DeclarationsMax = 4 * AddressedPartitionsMax
 
 // The maximum number of sector infos that can be loaded in a single invocation.
 // This limits the amount of state to be read in a single message execution.
 const AddressedSectorsMax = 25_000 // PARAM_SPEC
 
+// Limits on the partitions and sectors that may be addressed by a single declaration.
+type AddressingLimits struct {
+	Partitions uint64
+	Sectors    uint64
+}
+
+// Addressing limits by Window PoSt proof type. Processing a declaration costs about the same per sector
+// whatever the sector size, while larger sectors carry more power each, so a miner with larger sectors
+// addresses as much power in fewer sectors.
+// The limits never exceed AddressedPartitionsMax and AddressedSectorsMax.
+var addressingLimitsByProof = map[abi.RegisteredPoStProof]AddressingLimits{
+	abi.RegisteredPoStProof_StackedDrgWindow2KiBV1:   {Partitions: AddressedPartitionsMax, Sectors: AddressedSectorsMax},
+	abi.RegisteredPoStProof_StackedDrgWindow8MiBV1:   {Partitions: AddressedPartitionsMax, Sectors: AddressedSectorsMax},
+	abi.RegisteredPoStProof_StackedDrgWindow512MiBV1: {Partitions: AddressedPartitionsMax, Sectors: AddressedSectorsMax},
+	abi.RegisteredPoStProof_StackedDrgWindow32GiBV1:  {Partitions: AddressedPartitionsMax, Sectors: AddressedSectorsMax},
+	abi.RegisteredPoStProof_StackedDrgWindow64GiBV1:  {Partitions: AddressedPartitionsMax, Sectors: AddressedSectorsMax / 2},
+}
+
+// Returns the addressing limits for a miner's Window PoSt proof type, or the global maximums for a type
+// without limits of its own.
+func AddressingLimitsForProof(proof abi.RegisteredPoStProof) AddressingLimits {
+	if limits, ok := addressingLimitsByProof[proof]; ok {
+		return limits
+	}
+	return AddressingLimits{Partitions: AddressedPartitionsMax, Sectors: AddressedSectorsMax}
+}
+
 // Libp2p peer info limits.
 const (
 	// MaxPeerIDLength is the maximum length allowed for any on-chain peer ID.
@@ -102,6 +136,18 @@ func loadPartitionsSectorsMax(partitionSectorCount uint64) uint64 {
 	return min64(AddressedSectorsMax/partitionSectorCount, AddressedPartitionsMax)
 }
 
+// Scale of a partition's proof cost index, which is the cost index of a full partition.
+const PartitionProofCostIndexMax = uint64(100)
+
+// Estimates the relative cost of proving a partition with some live sectors, for a proof type with the given
+// partition size. The index grows with the live sectors, rounding up so that only an empty partition costs nothing.
+func PartitionProofCostIndex(liveSectors, partitionSectorCount uint64) uint64 {
+	if liveSectors >= partitionSectorCount {
+		return PartitionProofCostIndexMax
+	}
+	return (liveSectors*PartitionProofCostIndexMax + partitionSectorCount - 1) / partitionSectorCount
+}
+
 // Epochs after which chain state is final with overwhelming probability (hence the likelihood of two fork of this size is negligible)
 // This is a conservative value that is chosen via simulations of all known attacks.
 const ChainFinality = abi.ChainEpoch(900) // PARAM_SPEC
@@ -141,7 +187,7 @@ func CanPreCommitSealProof(s abi.RegisteredSealProof) bool {
 	return ok
 }
 
-// Checks whether a seal proof type is supported for new miners and sectors.
+// Checks whether the expiration of a sector with a seal proof type may be extended.
 // As of network version 11, all permitted seal proof types may be extended.
 func CanExtendSealProofType(_ abi.RegisteredSealProof) bool {
 	return true
@@ -181,12 +227,25 @@ var MaxPreCommitRandomnessLookback = builtin.EpochsInDay + ChainFinality // PARA
 // (2) prevents a miner attempting a long fork in the past to insert a pre-commitment after seeing the challenge.
 var PreCommitChallengeDelay = abi.ChainEpoch(150) // PARAM_SPEC
 
+// Maximum delay between the seal randomness of a sector proven by ProveCommitSectorsNI and the proof.
+// Without a pre-commitment, the miner knows the seal randomness and the challenge seed before submitting, and the
+// proof is verified with its seal proof type's interactive challenge count. The lookback is bounded to little more
+// than the time to seal, so that a miner has few seal randomness epochs to choose among for favourable challenges.
+var MaxProveCommitNIRandomnessLookback = 12*builtin.EpochsInHour + PreCommitChallengeDelay // PARAM_SPEC
+
 // Lookback from the deadline's challenge window opening from which to sample chain randomness for the WindowPoSt challenge seed.
 // This means that deadline windows can be non-overlapping (which make the programming simpler) without requiring a
 // miner to wait for chain stability during the challenge window.
 // This value cannot be too large lest it compromise the rationality of honest storage (from Window PoSt cost assumptions).
 const WPoStChallengeLookback = abi.ChainEpoch(20) // PARAM_SPEC
 
+// Maximum number of epochs before a deadline's challenge epoch from which the chain commit randomness
+// of a Window PoSt may be drawn. This widens the window of earlier versions, which required the chain
+// commitment to be drawn no earlier than the challenge epoch, so that a miner may commit to a chain
+// that forked shortly before the challenge. The epoch drawn is recorded with an optimistically accepted
+// proof, so that a dispute re-derives the same randomness.
+const WPoStChainCommitLookback = abi.ChainEpoch(10)
+
 // Minimum period between fault declaration and the next deadline opening.
 // If the number of epochs between fault declaration and deadline's challenge window opening is lower than FaultDeclarationCutoff,
 // the fault declaration is considered invalid for that deadline.
@@ -201,6 +260,16 @@ var FaultMaxAge = WPoStProvingPeriod * 42 // PARAM_SPEC
 // This delay prevents a miner choosing a more favorable worker key that wins leader elections.
 const WorkerKeyChangeDelay = ChainFinality // PARAM_SPEC
 
+// Maximum number of worker key changes that may be scheduled at once.
+const MaxPendingWorkerKeyChanges = 4 // PARAM_SPEC
+
+// Maximum number of owner and worker change events retained in a miner's history.
+const MaxControlChangeHistory = 32 // PARAM_SPEC
+
+// Maximum number of proving periods ahead of sectors' expiration at which a miner's notification receiver may be
+// reminded of it. A sector's lifetime is at least this many proving periods.
+const MaxExpirationReminderPeriods = 180
+
 // Minimum number of epochs past the current epoch a sector may be set to expire.
 const MinSectorExpiration = 180 * builtin.EpochsInDay // PARAM_SPEC
 
@@ -209,6 +278,47 @@ const MinSectorExpiration = 180 * builtin.EpochsInDay // PARAM_SPEC
 // the associated seal proof's maximum lifetime.
 const MaxSectorExpirationExtension = 540 * builtin.EpochsInDay // PARAM_SPEC
 
+// A step in a seal proof's expiration extension schedule: sectors at least MinAge epochs past activation
+// may be extended to at most MaxExtension epochs past the current epoch.
+type ExpirationExtensionStep struct {
+	MinAge       abi.ChainEpoch
+	MaxExtension abi.ChainEpoch
+}
+
+// Expiration extension schedule for each seal proof type, with steps in increasing order of MinAge.
+// Every proof currently permits MaxSectorExpirationExtension regardless of sector age; proof types whose
+// security holds for longer may be given larger extensions here.
+var MaxExpirationExtensionSchedule = map[abi.RegisteredSealProof][]ExpirationExtensionStep{
+	abi.RegisteredSealProof_StackedDrg32GiBV1:  {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg2KiBV1:   {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg8MiBV1:   {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg512MiBV1: {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg64GiBV1:  {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+
+	abi.RegisteredSealProof_StackedDrg32GiBV1_1:  {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg2KiBV1_1:   {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg8MiBV1_1:   {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg512MiBV1_1: {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+	abi.RegisteredSealProof_StackedDrg64GiBV1_1:  {{MinAge: 0, MaxExtension: MaxSectorExpirationExtension}},
+}
+
+// The maximum number of epochs past the current epoch that a sector with the given seal proof,
+// activated age epochs ago, may be set to expire.
+func MaxSectorExpirationExtensionFor(proof abi.RegisteredSealProof, age abi.ChainEpoch) (abi.ChainEpoch, error) {
+	schedule, ok := MaxExpirationExtensionSchedule[proof]
+	if !ok || len(schedule) == 0 {
+		return 0, fmt.Errorf("no expiration extension schedule for seal proof type %d", proof)
+	}
+	maxExtension := abi.ChainEpoch(0)
+	for _, step := range schedule {
+		if age < step.MinAge {
+			break
+		}
+		maxExtension = step.MaxExtension
+	}
+	return maxExtension, nil
+}
+
 // Ratio of sector size to maximum number of deals per sector.
 // The maximum number of deals is the sector size divided by this number (2^27)
 // which limits 32GiB sectors to 256 deals and 64GiB sectors to 512
@@ -286,6 +396,21 @@ var RewardVestingSpec = VestSpec{ // PARAM_SPEC
 	Quantization: 12 * builtin.EpochsInHour,
 }
 
+// The vesting schedule for pledge contributions deposited to a miner by other parties.
+// This matches the reward schedule but is set independently of it.
+var PledgeContributionVestingSpec = VestSpec{ // PARAM_SPEC
+	InitialDelay: abi.ChainEpoch(0),
+	VestPeriod:   abi.ChainEpoch(180 * builtin.EpochsInDay),
+	StepDuration: abi.ChainEpoch(1 * builtin.EpochsInDay),
+	Quantization: 12 * builtin.EpochsInHour,
+}
+
+// The vesting schedule applied to funds locked by ApplyRewards, by the kind of funds.
+var VestingSpecs = map[builtin.VestingSpecID]*VestSpec{
+	builtin.VestingSpecBlockReward:        &RewardVestingSpec,
+	builtin.VestingSpecPledgeContribution: &PledgeContributionVestingSpec,
+}
+
 // When an actor reports a consensus fault, they earn a share of the penalty paid by the miner.
 func RewardForConsensusSlashReport(epochReward abi.TokenAmount) abi.TokenAmount {
 	return big.Div(epochReward,
@@ -304,7 +429,41 @@ const MaxAggregatedSectors = 819
 const MinAggregatedSectors = 4
 const MaxAggregateProofSize = 81960
 
+// Maximum number of partitions whose Window PoSts may be aggregated into a single proof.
+const MaxAggregatedWindowPoStPartitions = 100 // PARAM_SPEC
+
+// Maximum size of an aggregated Window PoSt proof.
+const MaxAggregateWindowPoStProofSize = 81960 // PARAM_SPEC
+
+// Fraction of the pre-commit deposit refunded for a sector included in a valid aggregate prove-commitment after
+// its prove-commit deadline, with the remainder burnt at once. If zero, the deposits of such sectors are left to be
+// burnt when their pre-commitments are cleaned up.
+var LatePreCommitDepositRefund = builtin.BigFrac{ // PARAM_SPEC
+	Numerator:   big.NewInt(1),
+	Denominator: big.NewInt(2),
+}
+
+// The amount refunded from the pre-commit deposit of a sector proven too late to be activated.
+func LatePreCommitDepositRefundAmount(deposit abi.TokenAmount) abi.TokenAmount {
+	return big.Div(big.Mul(deposit, LatePreCommitDepositRefund.Numerator), LatePreCommitDepositRefund.Denominator)
+}
+
 // The delay between pre commit expiration and clean up from state. This enforces that expired pre-commits
 // stay in state for a period of time creating a grace period during which a late-running aggregated prove-commit
 // can still prove its non-expired precommits without resubmitting a message
 const ExpiredPreCommitCleanUpDelay = 8 * builtin.EpochsInHour
+
+// Length of the periods, aligned to epoch zero, over which the maintenance windows a miner may declare are limited.
+const MaintenanceQuotaPeriod = abi.ChainEpoch(90 * builtin.EpochsInDay) // PARAM_SPEC
+
+// Maximum total length of the maintenance windows a miner may declare starting in a single quota period.
+const MaxMaintenanceEpochsPerQuotaPeriod = abi.ChainEpoch(builtin.EpochsInDay) // PARAM_SPEC
+
+// Minimum notice a miner must give of the start of a maintenance window, so that windows are planned
+// rather than declared in response to an outage.
+const MaintenanceWindowNotice = abi.ChainEpoch(builtin.EpochsInDay) // PARAM_SPEC
+
+// Number of whole proving periods a sector must have been continuously faulty before a client with a deal in it
+// may have it terminated through the market actor.
+// This is well short of FaultMaxAge, so a client need not wait for the sector to be terminated automatically.
+const ClientBreachFaultPeriods = 7 // PARAM_SPEC
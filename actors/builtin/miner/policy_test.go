@@ -134,6 +134,40 @@ func TestPower(t *testing.T) {
 	})
 }
 
+func TestMaxSectorExpirationExtension(t *testing.T) {
+	t.Run("every supported proof has a schedule", func(t *testing.T) {
+		for proof := range miner.MaxProveCommitDuration {
+			maxExtension, err := miner.MaxSectorExpirationExtensionFor(proof, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, abi.ChainEpoch(miner.MaxSectorExpirationExtension), maxExtension)
+		}
+	})
+
+	t.Run("unknown proof is rejected", func(t *testing.T) {
+		_, err := miner.MaxSectorExpirationExtensionFor(abi.RegisteredSealProof(-1), 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("extension follows age steps", func(t *testing.T) {
+		proof := abi.RegisteredSealProof_StackedDrg32GiBV1_1
+		prev := miner.MaxExpirationExtensionSchedule[proof]
+		miner.MaxExpirationExtensionSchedule[proof] = []miner.ExpirationExtensionStep{
+			{MinAge: 0, MaxExtension: 100},
+			{MinAge: 1000, MaxExtension: 200},
+			{MinAge: 5000, MaxExtension: 300},
+		}
+		defer func() { miner.MaxExpirationExtensionSchedule[proof] = prev }()
+
+		for _, tc := range []struct{ age, expected abi.ChainEpoch }{
+			{0, 100}, {999, 100}, {1000, 200}, {4999, 200}, {5000, 300}, {100000, 300},
+		} {
+			maxExtension, err := miner.MaxSectorExpirationExtensionFor(proof, tc.age)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, maxExtension, "age %d", tc.age)
+		}
+	})
+}
+
 func weight(size abi.SectorSize, duration abi.ChainEpoch) big.Int {
 	return big.Mul(big.NewIntUnsigned(uint64(size)), big.NewInt(int64(duration)))
 }
@@ -144,3 +178,46 @@ func assertEqual(t *testing.T, a, b big.Int) {
 		assert.Equal(t, a, b)
 	}
 }
+
+func TestPartitionProofCostIndex(t *testing.T) {
+	for _, tc := range []struct{ live, partitionSectors, expected uint64 }{
+		{0, 2349, 0},
+		{1, 2349, 1},
+		{1174, 2349, 50},
+		{1175, 2349, 51},
+		{2349, 2349, 100},
+		{1, 2, 50},
+		{3, 2, 100},
+	} {
+		assert.Equal(t, tc.expected, miner.PartitionProofCostIndex(tc.live, tc.partitionSectors), "live %d of %d", tc.live, tc.partitionSectors)
+	}
+}
+
+func TestAddressingLimits(t *testing.T) {
+	t.Run("limits never exceed the global maximums", func(t *testing.T) {
+		for _, proof := range []abi.RegisteredPoStProof{
+			abi.RegisteredPoStProof_StackedDrgWindow2KiBV1,
+			abi.RegisteredPoStProof_StackedDrgWindow8MiBV1,
+			abi.RegisteredPoStProof_StackedDrgWindow512MiBV1,
+			abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+			abi.RegisteredPoStProof_StackedDrgWindow64GiBV1,
+		} {
+			limits := miner.AddressingLimitsForProof(proof)
+			assert.LessOrEqual(t, limits.Partitions, uint64(miner.AddressedPartitionsMax), "proof %d", proof)
+			assert.LessOrEqual(t, limits.Sectors, uint64(miner.AddressedSectorsMax), "proof %d", proof)
+		}
+	})
+
+	t.Run("larger sectors address fewer sectors", func(t *testing.T) {
+		limits32 := miner.AddressingLimitsForProof(abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+		limits64 := miner.AddressingLimitsForProof(abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		assert.Equal(t, uint64(miner.AddressedSectorsMax), limits32.Sectors)
+		assert.Less(t, limits64.Sectors, limits32.Sectors)
+	})
+
+	t.Run("unknown proof types use the global maximums", func(t *testing.T) {
+		limits := miner.AddressingLimitsForProof(abi.RegisteredPoStProof(-1))
+		assert.Equal(t, uint64(miner.AddressedPartitionsMax), limits.Partitions)
+		assert.Equal(t, uint64(miner.AddressedSectorsMax), limits.Sectors)
+	})
+}
@@ -0,0 +1,123 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// The info of a committed-capacity sector as it was before a replica update, retained so the update may be
+// rolled back until a Window PoSt could first cover the new replica.
+type ReplicaUpdateRollback struct {
+	Sector SectorOnChainInfo
+	// The update may be rolled back only before this epoch, the opening of the sector's deadline
+	// following the update.
+	Expiry abi.ChainEpoch
+}
+
+// Returns the rollback recorded for a sector's replica update, if any.
+// A rollback may remain recorded after its expiry until pruned at the end of a proving period.
+func (st *State) GetReplicaUpdateRollback(store adt.Store, sectorNo abi.SectorNumber) (*ReplicaUpdateRollback, bool, error) {
+	if st.ReplicaUpdateRollbacks == nil {
+		return nil, false, nil
+	}
+	rollbacks, err := adt.AsMap(store, *st.ReplicaUpdateRollbacks, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load replica update rollbacks: %w", err)
+	}
+	var out ReplicaUpdateRollback
+	found, err := rollbacks.Get(abi.UIntKey(uint64(sectorNo)), &out)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to get replica update rollback for sector %d: %w", sectorNo, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &out, true, nil
+}
+
+// Records rollbacks for replica updates, replacing any previously recorded for the same sectors.
+func (st *State) PutReplicaUpdateRollbacks(store adt.Store, rollbacks ...*ReplicaUpdateRollback) error {
+	if len(rollbacks) == 0 {
+		return nil
+	}
+	return st.updateReplicaUpdateRollbacks(store, func(m *adt.Map) error {
+		for _, rollback := range rollbacks {
+			if err := m.Put(abi.UIntKey(uint64(rollback.Sector.SectorNumber)), rollback); err != nil {
+				return xerrors.Errorf("failed to put replica update rollback for sector %d: %w", rollback.Sector.SectorNumber, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Removes any rollbacks recorded for sectors.
+func (st *State) DeleteReplicaUpdateRollbacks(store adt.Store, sectorNos bitfield.BitField) error {
+	if st.ReplicaUpdateRollbacks == nil {
+		return nil
+	}
+	return st.updateReplicaUpdateRollbacks(store, func(m *adt.Map) error {
+		return sectorNos.ForEach(func(sectorNo uint64) error {
+			if _, err := m.TryDelete(abi.UIntKey(sectorNo)); err != nil {
+				return xerrors.Errorf("failed to delete replica update rollback for sector %d: %w", sectorNo, err)
+			}
+			return nil
+		})
+	})
+}
+
+// Removes the rollbacks that have expired by an epoch.
+func (st *State) PruneReplicaUpdateRollbacks(store adt.Store, currEpoch abi.ChainEpoch) error {
+	if st.ReplicaUpdateRollbacks == nil {
+		return nil
+	}
+	return st.updateReplicaUpdateRollbacks(store, func(m *adt.Map) error {
+		var expired []abi.Keyer
+		var rollback ReplicaUpdateRollback
+		err := m.ForEach(&rollback, func(k string) error {
+			if rollback.Expiry <= currEpoch {
+				key, err := abi.ParseUIntKey(k)
+				if err != nil {
+					return xerrors.Errorf("failed to parse sector number key %s: %w", k, err)
+				}
+				expired = append(expired, abi.UIntKey(key))
+			}
+			return nil
+		})
+		if err != nil {
+			return xerrors.Errorf("failed to iterate replica update rollbacks: %w", err)
+		}
+		for _, key := range expired {
+			if err := m.Delete(key); err != nil {
+				return xerrors.Errorf("failed to delete replica update rollback %s: %w", key.Key(), err)
+			}
+		}
+		return nil
+	})
+}
+
+// Applies a change to the replica update rollbacks, creating the map on first use.
+func (st *State) updateReplicaUpdateRollbacks(store adt.Store, update func(m *adt.Map) error) error {
+	var rollbacks *adt.Map
+	var err error
+	if st.ReplicaUpdateRollbacks == nil {
+		rollbacks, err = adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	} else {
+		rollbacks, err = adt.AsMap(store, *st.ReplicaUpdateRollbacks, builtin.DefaultHamtBitwidth)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to load replica update rollbacks: %w", err)
+	}
+	if err := update(rollbacks); err != nil {
+		return err
+	}
+	root, err := rollbacks.Root()
+	if err != nil {
+		return xerrors.Errorf("failed to flush replica update rollbacks: %w", err)
+	}
+	st.ReplicaUpdateRollbacks = &root
+	return nil
+}
@@ -0,0 +1,225 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// The stage of a sector on its way from pre-commitment to activation.
+//
+// A sector is pre-committed by PreCommitSector(Batch), then either proven by ProveCommitSector, with the proof
+// verified and confirmed by the power actor at the end of the same epoch (or a later one, should the power actor
+// defer its verification), or proven and activated at once by
+// ProveCommitAggregate. A pre-commitment that is not activated before it expires is cleaned up.
+// A committed-capacity sector may instead be proven and activated by ProveCommitSectorsNI without pre-commitment.
+type SectorActivationStage uint64
+
+const (
+	// Not pre-committed, nor active. The sector may have expired, been cleaned up or been terminated.
+	SectorActivationNone SectorActivationStage = iota
+	// Pre-committed and awaiting a proof.
+	SectorActivationPreCommitted
+	// Pre-committed with a proof accepted in, or deferred to, the current epoch, awaiting confirmation of its validity.
+	SectorActivationProven
+	// Activated, with sector info recorded in the Sectors AMT.
+	SectorActivationActive
+)
+
+func (s SectorActivationStage) String() string {
+	switch s {
+	case SectorActivationNone:
+		return "none"
+	case SectorActivationPreCommitted:
+		return "pre-committed"
+	case SectorActivationProven:
+		return "proven"
+	case SectorActivationActive:
+		return "active"
+	default:
+		return "unknown"
+	}
+}
+
+// Permitted transitions between activation stages.
+var sectorActivationTransitions = map[SectorActivationStage][]SectorActivationStage{
+	SectorActivationNone: {
+		SectorActivationPreCommitted, // PreCommitSector(Batch)
+		SectorActivationActive,       // ProveCommitSectorsNI
+	},
+	SectorActivationPreCommitted: {
+		SectorActivationProven, // ProveCommitSector
+		SectorActivationActive, // ProveCommitAggregate
+		SectorActivationNone,   // clean up of an expired pre-commitment, or release of one proven too late
+	},
+	SectorActivationProven: {
+		SectorActivationProven, // a repeated ProveCommitSector in the same epoch, or deferral of the proof's verification
+		SectorActivationActive, // ConfirmSectorProofsValid, or ProveCommitAggregate
+		SectorActivationNone,   // discard of a pre-commitment whose proof failed verification
+	},
+}
+
+func validateActivationTransition(sectorNo abi.SectorNumber, from, to SectorActivationStage) error {
+	for _, permitted := range sectorActivationTransitions[from] {
+		if permitted == to {
+			return nil
+		}
+	}
+	return xerrors.Errorf("invalid activation transition for sector %d from %s to %s", sectorNo, from, to)
+}
+
+// Returns the activation stage of a sector at the current epoch.
+// A proof recorded in an earlier epoch was not confirmed, so leaves the sector pre-committed.
+func (st *State) SectorActivationStage(store adt.Store, sectorNo abi.SectorNumber, currEpoch abi.ChainEpoch) (SectorActivationStage, error) {
+	active, err := st.HasSectorNo(store, sectorNo)
+	if err != nil {
+		return SectorActivationNone, err
+	}
+	if active {
+		return SectorActivationActive, nil
+	}
+
+	_, precommitted, err := st.GetPrecommittedSector(store, sectorNo)
+	if err != nil {
+		return SectorActivationNone, err
+	}
+	if !precommitted {
+		return SectorActivationNone, nil
+	}
+
+	if st.ProvenPreCommitsEpoch == currEpoch {
+		proven, err := st.ProvenPreCommits.IsSet(uint64(sectorNo))
+		if err != nil {
+			return SectorActivationNone, xerrors.Errorf("failed to check proven pre-commits for %d: %w", sectorNo, err)
+		}
+		if proven {
+			return SectorActivationProven, nil
+		}
+	}
+	return SectorActivationPreCommitted, nil
+}
+
+// Records pre-committed sectors as proven in the current epoch, to await confirmation of their proofs.
+// Proofs recorded in an earlier epoch are discarded.
+func (st *State) RecordPreCommitsProven(store adt.Store, currEpoch abi.ChainEpoch, sectorNos ...abi.SectorNumber) error {
+	if err := st.transitionSectors(store, currEpoch, SectorActivationProven, sectorNos); err != nil {
+		return err
+	}
+
+	if st.ProvenPreCommitsEpoch != currEpoch {
+		st.ProvenPreCommits = bitfield.New()
+		st.ProvenPreCommitsEpoch = currEpoch
+	}
+	proven, err := bitfield.MergeBitFields(st.ProvenPreCommits, sectorNumbersBitfield(sectorNos))
+	if err != nil {
+		return xerrors.Errorf("failed to record proven pre-commits: %w", err)
+	}
+	st.ProvenPreCommits = proven
+	return nil
+}
+
+// Carries sectors whose proofs the power actor deferred for verification in the next epoch over to await
+// confirmation in that epoch. Sectors not awaiting confirmation are skipped.
+// Any other proofs recorded in the current epoch are discarded, having already been confirmed or failed.
+func (st *State) DeferProvenPreCommits(store adt.Store, currEpoch abi.ChainEpoch, sectorNos ...abi.SectorNumber) error {
+	precommits, err := st.FindProvenPreCommits(store, currEpoch, sectorNos...)
+	if err != nil {
+		return err
+	}
+	deferred := make([]abi.SectorNumber, len(precommits))
+	for i, precommit := range precommits {
+		deferred[i] = precommit.Info.SectorNumber
+	}
+	if err := st.transitionSectors(store, currEpoch, SectorActivationProven, deferred); err != nil {
+		return err
+	}
+	st.ProvenPreCommits = sectorNumbersBitfield(deferred)
+	st.ProvenPreCommitsEpoch = currEpoch + 1
+	return nil
+}
+
+// Returns those of the given sectors which are proven and awaiting confirmation at the current epoch.
+func (st *State) FindProvenPreCommits(store adt.Store, currEpoch abi.ChainEpoch, sectorNos ...abi.SectorNumber) ([]*SectorPreCommitOnChainInfo, error) {
+	result := make([]*SectorPreCommitOnChainInfo, 0, len(sectorNos))
+	if st.ProvenPreCommitsEpoch != currEpoch {
+		return result, nil
+	}
+	for _, sectorNo := range sectorNos {
+		proven, err := st.ProvenPreCommits.IsSet(uint64(sectorNo))
+		if err != nil {
+			return nil, xerrors.Errorf("failed to check proven pre-commits for %d: %w", sectorNo, err)
+		}
+		if !proven {
+			continue
+		}
+		precommit, found, err := st.GetPrecommittedSector(store, sectorNo)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		result = append(result, precommit)
+	}
+	return result, nil
+}
+
+// Removes the pre-commitments of sectors being activated.
+// The caller is responsible for recording the new sectors' info.
+func (st *State) ActivatePreCommittedSectors(store adt.Store, currEpoch abi.ChainEpoch, sectorNos ...abi.SectorNumber) error {
+	if err := st.transitionSectors(store, currEpoch, SectorActivationActive, sectorNos); err != nil {
+		return err
+	}
+	return st.DeletePrecommittedSectors(store, sectorNos...)
+}
+
+// Checks that sectors proven without pre-commitment may be activated.
+// The caller is responsible for recording the new sectors' info.
+func (st *State) ActivateSectorsWithoutPreCommit(store adt.Store, currEpoch abi.ChainEpoch, sectorNos ...abi.SectorNumber) error {
+	return st.transitionSectors(store, currEpoch, SectorActivationActive, sectorNos)
+}
+
+// Removes the pre-commitments of sectors that will not be activated, returning their total deposit.
+// The caller is responsible for accounting for the released deposit.
+func (st *State) DiscardPreCommittedSectors(store adt.Store, currEpoch abi.ChainEpoch, sectorNos ...abi.SectorNumber) (abi.TokenAmount, error) {
+	if err := st.transitionSectors(store, currEpoch, SectorActivationNone, sectorNos); err != nil {
+		return big.Zero(), err
+	}
+	deposit := big.Zero()
+	for _, sectorNo := range sectorNos {
+		precommit, _, err := st.GetPrecommittedSector(store, sectorNo)
+		if err != nil {
+			return big.Zero(), err
+		}
+		deposit = big.Add(deposit, precommit.PreCommitDeposit)
+	}
+	if err := st.DeleteSectorManifests(store, sectorNumbersBitfield(sectorNos)); err != nil {
+		return big.Zero(), err
+	}
+	return deposit, st.DeletePrecommittedSectors(store, sectorNos...)
+}
+
+// Checks that each sector may move to a new stage.
+func (st *State) transitionSectors(store adt.Store, currEpoch abi.ChainEpoch, to SectorActivationStage, sectorNos []abi.SectorNumber) error {
+	for _, sectorNo := range sectorNos {
+		from, err := st.SectorActivationStage(store, sectorNo, currEpoch)
+		if err != nil {
+			return xerrors.Errorf("failed to load activation stage of sector %d: %w", sectorNo, err)
+		}
+		if err := validateActivationTransition(sectorNo, from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sectorNumbersBitfield(sectorNos []abi.SectorNumber) bitfield.BitField {
+	nos := make([]uint64, len(sectorNos))
+	for i, sectorNo := range sectorNos {
+		nos[i] = uint64(sectorNo)
+	}
+	return bitfield.NewFromSet(nos)
+}
@@ -0,0 +1,119 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// A commitment to the layout of the pieces within a sector, anchoring where data lives in the sector
+// for retrieval systems. The manifest itself is held off-chain.
+type SectorManifest struct {
+	SectorNumber abi.SectorNumber
+	// CID of a manifest of the sector's piece CIDs and their offsets, in order.
+	Manifest cid.Cid `checked:"true"`
+}
+
+// Checks that each manifest names a distinct sector among those given, with a defined CID.
+func validateSectorManifests(manifests []SectorManifest, sectorNos bitfield.BitField) error {
+	seen := bitfield.New()
+	for _, m := range manifests {
+		if !m.Manifest.Defined() {
+			return xerrors.Errorf("manifest CID undefined for sector %d", m.SectorNumber)
+		}
+		included, err := sectorNos.IsSet(uint64(m.SectorNumber))
+		if err != nil {
+			return xerrors.Errorf("failed to check sector %d: %w", m.SectorNumber, err)
+		}
+		if !included {
+			return xerrors.Errorf("manifest for sector %d not in batch", m.SectorNumber)
+		}
+		duplicate, err := seen.IsSet(uint64(m.SectorNumber))
+		if err != nil {
+			return xerrors.Errorf("failed to check sector %d: %w", m.SectorNumber, err)
+		}
+		if duplicate {
+			return xerrors.Errorf("duplicate manifest for sector %d", m.SectorNumber)
+		}
+		seen.Set(uint64(m.SectorNumber))
+	}
+	return nil
+}
+
+// Returns the manifest committed for a sector, if any.
+// A manifest may remain recorded for a sector that is no longer pre-committed or active, until the sector's
+// info is removed.
+func (st *State) GetSectorManifest(store adt.Store, sectorNo abi.SectorNumber) (cid.Cid, bool, error) {
+	if st.SectorManifests == nil {
+		return cid.Undef, false, nil
+	}
+	manifests, err := adt.AsMap(store, *st.SectorManifests, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, false, xerrors.Errorf("failed to load sector manifests: %w", err)
+	}
+	var out cbg.CborCid
+	found, err := manifests.Get(abi.UIntKey(uint64(sectorNo)), &out)
+	if err != nil {
+		return cid.Undef, false, xerrors.Errorf("failed to get manifest for sector %d: %w", sectorNo, err)
+	}
+	return cid.Cid(out), found, nil
+}
+
+// Records manifests for sectors, replacing any previously recorded.
+func (st *State) PutSectorManifests(store adt.Store, manifests ...SectorManifest) error {
+	if len(manifests) == 0 {
+		return nil
+	}
+	return st.updateSectorManifests(store, func(m *adt.Map) error {
+		for _, manifest := range manifests {
+			value := cbg.CborCid(manifest.Manifest)
+			if err := m.Put(abi.UIntKey(uint64(manifest.SectorNumber)), &value); err != nil {
+				return xerrors.Errorf("failed to put manifest for sector %d: %w", manifest.SectorNumber, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Removes any manifests recorded for sectors.
+func (st *State) DeleteSectorManifests(store adt.Store, sectorNos bitfield.BitField) error {
+	if st.SectorManifests == nil {
+		return nil
+	}
+	return st.updateSectorManifests(store, func(m *adt.Map) error {
+		return sectorNos.ForEach(func(sectorNo uint64) error {
+			if _, err := m.TryDelete(abi.UIntKey(sectorNo)); err != nil {
+				return xerrors.Errorf("failed to delete manifest for sector %d: %w", sectorNo, err)
+			}
+			return nil
+		})
+	})
+}
+
+// Applies a change to the sector manifests, creating the map on first use.
+func (st *State) updateSectorManifests(store adt.Store, update func(m *adt.Map) error) error {
+	var manifests *adt.Map
+	var err error
+	if st.SectorManifests == nil {
+		manifests, err = adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	} else {
+		manifests, err = adt.AsMap(store, *st.SectorManifests, builtin.DefaultHamtBitwidth)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to load sector manifests: %w", err)
+	}
+	if err := update(manifests); err != nil {
+		return err
+	}
+	root, err := manifests.Root()
+	if err != nil {
+		return xerrors.Errorf("failed to flush sector manifests: %w", err)
+	}
+	st.SectorManifests = &root
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 )
 
 type DealSummary struct {
+	SectorNumber     abi.SectorNumber
 	SectorStart      abi.ChainEpoch
 	SectorExpiration abi.ChainEpoch
 }
@@ -23,6 +24,13 @@ type StateSummary struct {
 	Deals               map[abi.DealID]DealSummary
 	WindowPoStProofType abi.RegisteredPoStProof
 	DeadlineCronActive  bool
+	InitialPledge       abi.TokenAmount
+	PreCommitDeposits   abi.TokenAmount
+	LockedFunds         abi.TokenAmount
+	// Terminated sectors still awaiting processing of their early termination fees.
+	PendingTerminatedSectors uint64
+	// Terminated sectors with no outstanding termination fee, not yet removed by compaction.
+	SettledTerminatedSectors uint64
 }
 
 // Checks internal invariants of init state.
@@ -35,6 +43,9 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount) (
 		FaultyPower:         NewPowerPairZero(),
 		WindowPoStProofType: 0,
 		DeadlineCronActive:  st.DeadlineCronActive,
+		InitialPledge:       st.InitialPledge,
+		PreCommitDeposits:   st.PreCommitDeposits,
+		LockedFunds:         st.LockedFunds,
 	}
 
 	// Load data from linked structures.
@@ -46,10 +57,19 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount) (
 		minerSummary.WindowPoStProofType = info.WindowPoStProofType
 		sectorSize = info.SectorSize
 		CheckMinerInfo(info, acc)
+		CheckPendingWorkerKeyChanges(info, store, acc)
 	}
 
 	CheckMinerBalances(st, store, balance, acc)
 
+	if w := st.MaintenanceWindow; w != nil {
+		acc.Require(w.Start < w.End, "maintenance window start %d not before end %d", w.Start, w.End)
+		acc.Require(maintenanceQuotaPeriod(w.Start) == maintenanceQuotaPeriod(w.End-1),
+			"maintenance window [%d, %d) spans quota periods", w.Start, w.End)
+		acc.Require(w.QuotaUsed >= w.End-w.Start && w.QuotaUsed <= MaxMaintenanceEpochsPerQuotaPeriod,
+			"maintenance quota used %d out of bounds for window [%d, %d)", w.QuotaUsed, w.Start, w.End)
+	}
+
 	var allocatedSectors bitfield.BitField
 	var allocatedSectorsMap map[uint64]bool
 	if err := store.Get(store.Context(), st.AllocatedSectors, &allocatedSectors); err != nil {
@@ -79,6 +99,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount) (
 
 			for _, dealID := range sector.DealIDs {
 				minerSummary.Deals[dealID] = DealSummary{
+					SectorNumber:     sector.SectorNumber,
 					SectorStart:      sector.Activation,
 					SectorExpiration: sector.Expiration,
 				}
@@ -105,14 +126,79 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount) (
 			quant := st.QuantSpecForDeadline(dlIdx)
 			dlSummary := CheckDeadlineStateInvariants(dl, store, quant, sectorSize, allSectors, acc)
 
+			if empty, err := st.EmptyDeadlines.IsSet(dlIdx); err != nil {
+				acc.Addf("error checking for empty deadline: %v", err)
+			} else if live, err := dl.IsLive(); err != nil {
+				acc.Addf("error checking for live deadline: %v", err)
+			} else {
+				acc.Require(!(empty && live), "deadline recorded as empty is live")
+			}
+
 			minerSummary.LivePower = minerSummary.LivePower.Add(dlSummary.LivePower)
 			minerSummary.ActivePower = minerSummary.ActivePower.Add(dlSummary.ActivePower)
 			minerSummary.FaultyPower = minerSummary.FaultyPower.Add(dlSummary.FaultyPower)
+			if terminated, err := dlSummary.TerminatedSectors.Count(); err != nil {
+				acc.Addf("error counting terminated sectors: %v", err)
+			} else {
+				minerSummary.PendingTerminatedSectors += dlSummary.EarlyTerminationCount
+				minerSummary.SettledTerminatedSectors += terminated - dlSummary.EarlyTerminationCount
+			}
 			return nil
 		})
 		acc.RequireNoError(err, "error iterating deadlines")
 	}
 
+	// Check queued recoveries
+	if queued, err := st.LoadQueuedRecoveries(store); err != nil {
+		acc.Addf("error loading queued recoveries: %v", err)
+	} else {
+		acc.Require(st.QueuedRecoveries == nil || len(queued) > 0, "queued recoveries are empty but not nil")
+		for _, dlIdx := range queued.Deadlines() {
+			acc.Require(dlIdx < WPoStPeriodDeadlines, "queued recovery at invalid deadline %d", dlIdx)
+		}
+		err = queued.Check(AddressedPartitionsMax, AddressedSectorsMax)
+		acc.RequireNoError(err, "queued recoveries exceed limits")
+	}
+
+	// Check replica update rollbacks
+	if st.ReplicaUpdateRollbacks != nil {
+		if rollbacks, err := adt.AsMap(store, *st.ReplicaUpdateRollbacks, builtin.DefaultHamtBitwidth); err != nil {
+			acc.Addf("error loading replica update rollbacks: %v", err)
+		} else {
+			var rollback ReplicaUpdateRollback
+			err = rollbacks.ForEach(&rollback, func(key string) error {
+				sno, err := abi.ParseUIntKey(key)
+				if err != nil {
+					return err
+				}
+				acc.Require(abi.SectorNumber(sno) == rollback.Sector.SectorNumber, "replica update rollback keyed by %d is for sector %d", sno, rollback.Sector.SectorNumber)
+				acc.Require(len(rollback.Sector.DealIDs) == 0, "replica update rollback for sector %d restores deals", sno)
+				if allSectors != nil {
+					sector, found := allSectors[abi.SectorNumber(sno)]
+					acc.Require(found, "replica update rollback for missing sector %d", sno)
+					if found {
+						acc.Require(sector.SectorKeyCID != nil, "replica update rollback for sector %d with no sector key", sno)
+					}
+				}
+				return nil
+			})
+			acc.RequireNoError(err, "error iterating replica update rollbacks")
+		}
+	}
+
+	// Check control change history
+	if events, err := st.LoadControlChangeHistory(store); err != nil {
+		acc.Addf("error loading control change history: %v", err)
+	} else {
+		acc.Require(st.ControlChanges == nil || len(events) > 0, "control change history is empty but not nil")
+		acc.Require(len(events) <= MaxControlChangeHistory, "control change history of %d events exceeds limit %d",
+			len(events), MaxControlChangeHistory)
+		for i := 1; i < len(events); i++ {
+			acc.Require(events[i-1].Epoch <= events[i].Epoch, "control change event %d at %d precedes previous at %d",
+				i, events[i].Epoch, events[i-1].Epoch)
+		}
+	}
+
 	return minerSummary, acc
 }
 
@@ -126,6 +212,8 @@ type DeadlineStateSummary struct {
 	LivePower         PowerPair
 	ActivePower       PowerPair
 	FaultyPower       PowerPair
+	// Number of terminated sectors in the partitions' early termination queues.
+	EarlyTerminationCount uint64
 }
 
 func CheckDeadlineStateInvariants(deadline *Deadline, store adt.Store, quant builtin.QuantSpec, ssize abi.SectorSize,
@@ -158,6 +246,7 @@ func CheckDeadlineStateInvariants(deadline *Deadline, store adt.Store, quant bui
 	allLivePower := NewPowerPairZero()
 	allActivePower := NewPowerPairZero()
 	allFaultyPower := NewPowerPairZero()
+	allEarlyTerminationCount := uint64(0)
 
 	// Check partitions.
 	partitionsWithExpirations := map[abi.ChainEpoch][]uint64{}
@@ -184,6 +273,7 @@ func CheckDeadlineStateInvariants(deadline *Deadline, store adt.Store, quant bui
 		}
 		if summary.EarlyTerminationCount > 0 {
 			partitionsWithEarlyTerminations = append(partitionsWithEarlyTerminations, pIdx)
+			allEarlyTerminationCount += uint64(summary.EarlyTerminationCount)
 		}
 
 		allSectors, err = bitfield.MergeBitFields(allSectors, summary.AllSectors)
@@ -245,6 +335,8 @@ func CheckDeadlineStateInvariants(deadline *Deadline, store adt.Store, quant bui
 	acc.RequireNoError(err, "error loading proofs snapshot")
 	var proof WindowedPoSt
 	err = proofsSnapshot.ForEach(&proof, func(_ int64) error {
+		acc.Require(proof.ChainCommitEpoch == NoChainCommitEpoch || proof.ChainCommitEpoch >= 0,
+			"recorded proof has invalid chain commit epoch %d", proof.ChainCommitEpoch)
 		err = proof.Partitions.ForEach(func(i uint64) error {
 			found, err := partitionsSnapshot.Get(i, &partition)
 			acc.RequireNoError(err, "error loading partition snapshot")
@@ -338,6 +430,8 @@ func CheckDeadlineStateInvariants(deadline *Deadline, store adt.Store, quant bui
 		LivePower:         allLivePower,
 		ActivePower:       allActivePower,
 		FaultyPower:       allFaultyPower,
+
+		EarlyTerminationCount: allEarlyTerminationCount,
 	}
 }
 
@@ -689,13 +783,6 @@ func CheckMinerInfo(info *MinerInfo, acc *builtin.MessageAccumulator) {
 		acc.Require(a.Protocol() == addr.ID, "control address %v is not an ID address", a)
 	}
 
-	if info.PendingWorkerKey != nil {
-		acc.Require(info.PendingWorkerKey.NewWorker.Protocol() == addr.ID,
-			"pending worker address %v is not an ID address", info.PendingWorkerKey.NewWorker)
-		acc.Require(info.PendingWorkerKey.NewWorker != info.Worker,
-			"pending worker key %v is same as existing worker %v", info.PendingWorkerKey.NewWorker, info.Worker)
-	}
-
 	if info.PendingOwnerAddress != nil {
 		acc.Require(info.PendingOwnerAddress.Protocol() == addr.ID,
 			"pending owner address %v is not an ID address", info.PendingOwnerAddress)
@@ -703,6 +790,21 @@ func CheckMinerInfo(info *MinerInfo, acc *builtin.MessageAccumulator) {
 			"pending owner address %v is same as existing owner %v", info.PendingOwnerAddress, info.Owner)
 	}
 
+	if info.NotificationReceiver != nil {
+		acc.Require(info.NotificationReceiver.Protocol() == addr.ID,
+			"notification receiver address %v is not an ID address", info.NotificationReceiver)
+	}
+	acc.Require(info.ExpirationReminderPeriods <= MaxExpirationReminderPeriods,
+		"expiration reminder %d proving periods ahead exceeds maximum %d", info.ExpirationReminderPeriods, MaxExpirationReminderPeriods)
+
+	acc.Require(info.Beneficiary.Protocol() == addr.ID, "beneficiary address %v is not an ID address", info.Beneficiary)
+	acc.Require(info.BeneficiaryTerm.UsedQuota.LessThanEqual(info.BeneficiaryTerm.Quota),
+		"beneficiary used quota %v exceeds quota %v", info.BeneficiaryTerm.UsedQuota, info.BeneficiaryTerm.Quota)
+	if info.PendingBeneficiaryTerm != nil {
+		acc.Require(info.PendingBeneficiaryTerm.NewBeneficiary.Protocol() == addr.ID,
+			"pending beneficiary address %v is not an ID address", info.PendingBeneficiaryTerm.NewBeneficiary)
+	}
+
 	windowPoStProofInfo, found := abi.PoStProofInfos[info.WindowPoStProofType]
 	acc.Require(found, "miner has unrecognized Window PoSt proof type %d", info.WindowPoStProofType)
 	if found {
@@ -719,6 +821,34 @@ func CheckMinerInfo(info *MinerInfo, acc *builtin.MessageAccumulator) {
 	}
 }
 
+func CheckPendingWorkerKeyChanges(info *MinerInfo, store adt.Store, acc *builtin.MessageAccumulator) {
+	if info.PendingWorkerKeys == nil {
+		return
+	}
+	changes, err := adt.AsArray(store, *info.PendingWorkerKeys, PendingWorkerKeysAmtBitwidth)
+	if err != nil {
+		acc.Addf("error loading pending worker keys: %v", err)
+		return
+	}
+	acc.Require(changes.Length() > 0, "pending worker keys recorded but empty")
+	acc.Require(changes.Length() <= MaxPendingWorkerKeyChanges, "%d pending worker keys exceeds limit %d",
+		changes.Length(), MaxPendingWorkerKeyChanges)
+
+	prevWorker := info.Worker
+	var change WorkerKeyChange
+	err = changes.ForEach(&change, func(epoch int64) error {
+		acc.Require(change.EffectiveAt == abi.ChainEpoch(epoch),
+			"pending worker key change at %d has effective epoch %d", epoch, change.EffectiveAt)
+		acc.Require(change.NewWorker.Protocol() == addr.ID,
+			"pending worker address %v is not an ID address", change.NewWorker)
+		acc.Require(change.NewWorker != prevWorker,
+			"pending worker key %v at %d is same as preceding worker %v", change.NewWorker, epoch, prevWorker)
+		prevWorker = change.NewWorker
+		return nil
+	})
+	acc.RequireNoError(err, "error iterating pending worker keys")
+}
+
 func CheckMinerBalances(st *State, store adt.Store, balance abi.TokenAmount, acc *builtin.MessageAccumulator) {
 	acc.Require(balance.GreaterThanEqual(big.Zero()), "miner actor balance is less than zero: %v", balance)
 	acc.Require(st.LockedFunds.GreaterThanEqual(big.Zero()), "miner locked funds is less than zero: %v", st.LockedFunds)
@@ -726,9 +856,14 @@ func CheckMinerBalances(st *State, store adt.Store, balance abi.TokenAmount, acc
 	acc.Require(st.InitialPledge.GreaterThanEqual(big.Zero()), "miner initial pledge is less than zero: %v", st.InitialPledge)
 	acc.Require(st.FeeDebt.GreaterThanEqual(big.Zero()), "miner fee debt is less than zero: %v", st.FeeDebt)
 
-	acc.Require(big.Subtract(balance, st.LockedFunds, st.PreCommitDeposits, st.InitialPledge).GreaterThanEqual(big.Zero()),
-		"miner balance (%v) is less than sum of locked funds (%v), precommit deposit (%v), and initial pledge (%v)",
-		balance, st.LockedFunds, st.PreCommitDeposits, st.InitialPledge)
+	if big.Subtract(balance, st.LockedFunds, st.PreCommitDeposits, st.InitialPledge).LessThan(big.Zero()) {
+		// Sectors pending termination have been removed from power, but their fees are yet to be paid.
+		pending, settled, err := st.CountTerminatedSectors(store)
+		acc.RequireNoError(err, "error counting terminated sectors")
+		acc.Addf("miner balance (%v) is less than sum of locked funds (%v), precommit deposit (%v), and initial pledge (%v), "+
+			"with %d terminated sectors pending termination fees and %d settled",
+			balance, st.LockedFunds, st.PreCommitDeposits, st.InitialPledge, pending, settled)
+	}
 
 	// locked funds must be sum of vesting table and vesting table payments must be quantized
 	vestingSum := big.Zero()
@@ -777,6 +912,7 @@ func CheckPreCommits(st *State, store adt.Store, allocatedSectors map[uint64]boo
 	}
 
 	precommitTotal := big.Zero()
+	precommittedNos := make(map[uint64]bool)
 	if precommitted, err := adt.AsMap(store, st.PreCommittedSectors, builtin.DefaultHamtBitwidth); err != nil {
 		acc.Addf("error loading precommitted sectors: %v", err)
 	} else {
@@ -789,6 +925,7 @@ func CheckPreCommits(st *State, store adt.Store, allocatedSectors map[uint64]boo
 			}
 
 			acc.Require(allocatedSectors[secNum], "pre-committed sector number has not been allocated %d", secNum)
+			precommittedNos[secNum] = true
 
 			_, found := cleanUpEpochs[secNum]
 			acc.Require(found, "no clean up epoch for pre-commit at %d", precommit.PreCommitEpoch)
@@ -801,6 +938,12 @@ func CheckPreCommits(st *State, store adt.Store, allocatedSectors map[uint64]boo
 
 	acc.Require(st.PreCommitDeposits.Equals(precommitTotal),
 		"sum of precommit deposits %v does not equal recorded precommit deposit %v", precommitTotal, st.PreCommitDeposits)
+
+	err := st.ProvenPreCommits.ForEach(func(secNum uint64) error {
+		acc.Require(precommittedNos[secNum], "proven sector %d is not pre-committed", secNum)
+		return nil
+	})
+	acc.RequireNoError(err, "error iterating proven pre-commits")
 }
 
 // Selects a subset of sectors from a map by sector number.
@@ -0,0 +1,129 @@
+package miner
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// Bitwidth of the AMT of pending worker key changes, which the actor bounds to MaxPendingWorkerKeyChanges entries.
+const PendingWorkerKeysAmtBitwidth = 2
+
+// Returns the scheduled worker key changes, in order of the epoch at which they take effect.
+func (info *MinerInfo) LoadPendingWorkerKeys(store adt.Store) ([]WorkerKeyChange, error) {
+	if info.PendingWorkerKeys == nil {
+		return nil, nil
+	}
+	changes, err := adt.AsArray(store, *info.PendingWorkerKeys, PendingWorkerKeysAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load pending worker keys: %w", err)
+	}
+	var out []WorkerKeyChange
+	var change WorkerKeyChange
+	if err = changes.ForEach(&change, func(_ int64) error {
+		out = append(out, change)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate pending worker keys: %w", err)
+	}
+	return out, nil
+}
+
+// Schedules a change of worker key at an epoch, after any already scheduled.
+// A change scheduled for the same epoch as the last is replaced.
+// Returns whether a change was scheduled, which it is not if the new worker is the worker that
+// would be in effect after all scheduled changes.
+func (info *MinerInfo) scheduleWorkerKeyChange(store adt.Store, newWorker addr.Address, effectiveAt abi.ChainEpoch) (bool, error) {
+	pending, err := info.LoadPendingWorkerKeys(store)
+	if err != nil {
+		return false, err
+	}
+	lastWorker := info.Worker
+	if len(pending) > 0 {
+		last := pending[len(pending)-1]
+		if last.EffectiveAt > effectiveAt {
+			return false, xerrors.Errorf("worker key change at %d precedes last scheduled at %d", effectiveAt, last.EffectiveAt)
+		}
+		if last.EffectiveAt == effectiveAt {
+			pending = pending[:len(pending)-1]
+		}
+		if len(pending) > 0 {
+			lastWorker = pending[len(pending)-1].NewWorker
+		}
+	}
+	if newWorker == lastWorker {
+		return false, nil
+	}
+	return true, info.savePendingWorkerKeys(store, append(pending, WorkerKeyChange{NewWorker: newWorker, EffectiveAt: effectiveAt}))
+}
+
+// Removes the worker key change scheduled at an epoch. Returns the change removed, or nil if there was none.
+// A later change that would no longer alter the worker is removed too.
+func (info *MinerInfo) cancelWorkerKeyChange(store adt.Store, effectiveAt abi.ChainEpoch) (*WorkerKeyChange, error) {
+	pending, err := info.LoadPendingWorkerKeys(store)
+	if err != nil {
+		return nil, err
+	}
+	var cancelled *WorkerKeyChange
+	prevWorker := info.Worker
+	var remaining []WorkerKeyChange
+	for i, change := range pending {
+		if change.EffectiveAt == effectiveAt {
+			cancelled = &pending[i]
+			continue
+		}
+		if change.NewWorker == prevWorker {
+			continue
+		}
+		remaining = append(remaining, change)
+		prevWorker = change.NewWorker
+	}
+	if cancelled == nil {
+		return nil, nil
+	}
+	return cancelled, info.savePendingWorkerKeys(store, remaining)
+}
+
+// Makes effective any worker key changes scheduled at or before the current epoch, in order.
+// Returns the changes made effective.
+func (info *MinerInfo) applyWorkerKeyChanges(store adt.Store, currEpoch abi.ChainEpoch) ([]WorkerKeyChange, error) {
+	pending, err := info.LoadPendingWorkerKeys(store)
+	if err != nil {
+		return nil, err
+	}
+	effective := 0
+	for effective < len(pending) && pending[effective].EffectiveAt <= currEpoch {
+		info.Worker = pending[effective].NewWorker
+		effective++
+	}
+	if effective == 0 {
+		return nil, nil
+	}
+	return pending[:effective], info.savePendingWorkerKeys(store, pending[effective:])
+}
+
+// Stores pending worker key changes, keyed by the epoch at which they take effect.
+// The pending changes are recorded as nil when empty.
+func (info *MinerInfo) savePendingWorkerKeys(store adt.Store, pending []WorkerKeyChange) error {
+	if len(pending) == 0 {
+		info.PendingWorkerKeys = nil
+		return nil
+	}
+	changes, err := adt.MakeEmptyArray(store, PendingWorkerKeysAmtBitwidth)
+	if err != nil {
+		return xerrors.Errorf("failed to create pending worker keys: %w", err)
+	}
+	for i := range pending {
+		if err := changes.Set(uint64(pending[i].EffectiveAt), &pending[i]); err != nil {
+			return xerrors.Errorf("failed to set worker key change at %d: %w", pending[i].EffectiveAt, err)
+		}
+	}
+	root, err := changes.Root()
+	if err != nil {
+		return xerrors.Errorf("failed to flush pending worker keys: %w", err)
+	}
+	info.PendingWorkerKeys = &root
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
 )
 
 // PARAM_SPEC
@@ -61,3 +62,20 @@ const SectorQualityPrecision = 20
 
 // 1 NanoFIL
 var OneNanoFIL = big.NewInt(1_000_000_000)
+
+// A policy value that applies from a network version until superseded by the value for a later version.
+type VersionedInt64 struct {
+	From  network.Version
+	Value int64
+}
+
+// Returns the value in force at a network version, given values in ascending order of version.
+// Returns false if the version precedes them all.
+func ValueAtNetworkVersion(values []VersionedInt64, nv network.Version) (int64, bool) {
+	for i := len(values) - 1; i >= 0; i-- {
+		if nv >= values[i].From {
+			return values[i].Value, true
+		}
+	}
+	return 0, false
+}
@@ -13,7 +13,7 @@ import (
 
 var _ = xerrors.Errorf
 
-var lengthBufState = []byte{143}
+var lengthBufState = []byte{152, 26}
 
 func (t *State) MarshalCBOR(w io.Writer) error {
 	if t == nil {
@@ -51,6 +51,26 @@ func (t *State) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.TotalInitialPledge (big.Int) (struct)
+	if err := t.TotalInitialPledge.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.TotalPreCommitDeposits (big.Int) (struct)
+	if err := t.TotalPreCommitDeposits.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.TotalLockedRewards (big.Int) (struct)
+	if err := t.TotalLockedRewards.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.TotalFaultyQAPower (big.Int) (struct)
+	if err := t.TotalFaultyQAPower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
 	// t.ThisEpochRawBytePower (big.Int) (struct)
 	if err := t.ThisEpochRawBytePower.MarshalCBOR(w); err != nil {
 		return err
@@ -71,6 +91,16 @@ func (t *State) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.ThisEpochRewardSmoothed (smoothing.FilterEstimate) (struct)
+	if err := t.ThisEpochRewardSmoothed.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.FaultRateSmoothed (smoothing.FilterEstimate) (struct)
+	if err := t.FaultRateSmoothed.MarshalCBOR(w); err != nil {
+		return err
+	}
+
 	// t.MinerCount (int64) (int64)
 	if t.MinerCount >= 0 {
 		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.MinerCount)); err != nil {
@@ -116,6 +146,41 @@ func (t *State) MarshalCBOR(w io.Writer) error {
 		return xerrors.Errorf("failed to write cid field t.Claims: %w", err)
 	}
 
+	// t.ClaimsSnapshot (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.ClaimsSnapshot); err != nil {
+		return xerrors.Errorf("failed to write cid field t.ClaimsSnapshot: %w", err)
+	}
+
+	// t.ClaimsSnapshotEpoch (abi.ChainEpoch) (int64)
+	if t.ClaimsSnapshotEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ClaimsSnapshotEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.ClaimsSnapshotEpoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.FaultStreaks (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.FaultStreaks); err != nil {
+		return xerrors.Errorf("failed to write cid field t.FaultStreaks: %w", err)
+	}
+
+	// t.CronFailures (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.CronFailures); err != nil {
+		return xerrors.Errorf("failed to write cid field t.CronFailures: %w", err)
+	}
+
+	// t.PowerCheckpoints (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.PowerCheckpoints); err != nil {
+		return xerrors.Errorf("failed to write cid field t.PowerCheckpoints: %w", err)
+	}
+
 	// t.ProofValidationBatch (cid.Cid) (struct)
 
 	if t.ProofValidationBatch == nil {
@@ -145,7 +210,7 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 15 {
+	if extra != 26 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -193,6 +258,42 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 			return xerrors.Errorf("unmarshaling t.TotalPledgeCollateral: %w", err)
 		}
 
+	}
+	// t.TotalInitialPledge (big.Int) (struct)
+
+	{
+
+		if err := t.TotalInitialPledge.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.TotalInitialPledge: %w", err)
+		}
+
+	}
+	// t.TotalPreCommitDeposits (big.Int) (struct)
+
+	{
+
+		if err := t.TotalPreCommitDeposits.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.TotalPreCommitDeposits: %w", err)
+		}
+
+	}
+	// t.TotalLockedRewards (big.Int) (struct)
+
+	{
+
+		if err := t.TotalLockedRewards.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.TotalLockedRewards: %w", err)
+		}
+
+	}
+	// t.TotalFaultyQAPower (big.Int) (struct)
+
+	{
+
+		if err := t.TotalFaultyQAPower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.TotalFaultyQAPower: %w", err)
+		}
+
 	}
 	// t.ThisEpochRawBytePower (big.Int) (struct)
 
@@ -229,6 +330,24 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 			return xerrors.Errorf("unmarshaling t.ThisEpochQAPowerSmoothed: %w", err)
 		}
 
+	}
+	// t.ThisEpochRewardSmoothed (smoothing.FilterEstimate) (struct)
+
+	{
+
+		if err := t.ThisEpochRewardSmoothed.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.ThisEpochRewardSmoothed: %w", err)
+		}
+
+	}
+	// t.FaultRateSmoothed (smoothing.FilterEstimate) (struct)
+
+	{
+
+		if err := t.FaultRateSmoothed.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.FaultRateSmoothed: %w", err)
+		}
+
 	}
 	// t.MinerCount (int64) (int64)
 	{
@@ -328,6 +447,79 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 
 		t.Claims = c
 
+	}
+	// t.ClaimsSnapshot (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.ClaimsSnapshot: %w", err)
+		}
+
+		t.ClaimsSnapshot = c
+
+	}
+	// t.ClaimsSnapshotEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.ClaimsSnapshotEpoch = abi.ChainEpoch(extraI)
+	}
+	// t.FaultStreaks (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.FaultStreaks: %w", err)
+		}
+
+		t.FaultStreaks = c
+
+	}
+	// t.CronFailures (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.CronFailures: %w", err)
+		}
+
+		t.CronFailures = c
+
+	}
+	// t.PowerCheckpoints (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.PowerCheckpoints: %w", err)
+		}
+
+		t.PowerCheckpoints = c
+
 	}
 	// t.ProofValidationBatch (cid.Cid) (struct)
 
@@ -537,3 +729,1776 @@ func (t *CronEvent) UnmarshalCBOR(r io.Reader) error {
 	}
 	return nil
 }
+
+var lengthBufFaultStreak = []byte{130}
+
+func (t *FaultStreak) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufFaultStreak); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.MissedPeriods (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.MissedPeriods)); err != nil {
+		return err
+	}
+
+	// t.StartEpoch (abi.ChainEpoch) (int64)
+	if t.StartEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.StartEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.StartEpoch-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *FaultStreak) UnmarshalCBOR(r io.Reader) error {
+	*t = FaultStreak{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.MissedPeriods (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.MissedPeriods = uint64(extra)
+
+	}
+	// t.StartEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.StartEpoch = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufCronFailureRecord = []byte{134}
+
+func (t *CronFailureRecord) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufCronFailureRecord); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.ConsecutiveFailures (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ConsecutiveFailures)); err != nil {
+		return err
+	}
+
+	// t.LastFailureEpoch (abi.ChainEpoch) (int64)
+	if t.LastFailureEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.LastFailureEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.LastFailureEpoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.FailingEvents ([]power.CronEvent) (slice)
+	if len(t.FailingEvents) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.FailingEvents was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.FailingEvents))); err != nil {
+		return err
+	}
+	for _, v := range t.FailingEvents {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+
+	// t.QuarantinedEpoch (abi.ChainEpoch) (int64)
+	if t.QuarantinedEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.QuarantinedEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.QuarantinedEpoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.Claim (power.Claim) (struct)
+	if err := t.Claim.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.HeldEvents ([]power.CronEvent) (slice)
+	if len(t.HeldEvents) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.HeldEvents was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.HeldEvents))); err != nil {
+		return err
+	}
+	for _, v := range t.HeldEvents {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *CronFailureRecord) UnmarshalCBOR(r io.Reader) error {
+	*t = CronFailureRecord{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 6 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.ConsecutiveFailures (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.ConsecutiveFailures = uint64(extra)
+
+	}
+	// t.LastFailureEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.LastFailureEpoch = abi.ChainEpoch(extraI)
+	}
+	// t.FailingEvents ([]power.CronEvent) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.FailingEvents: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.FailingEvents = make([]CronEvent, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v CronEvent
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.FailingEvents[i] = v
+	}
+
+	// t.QuarantinedEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.QuarantinedEpoch = abi.ChainEpoch(extraI)
+	}
+	// t.Claim (power.Claim) (struct)
+
+	{
+
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != cbg.CborNull[0] {
+			if err := br.UnreadByte(); err != nil {
+				return err
+			}
+			t.Claim = new(Claim)
+			if err := t.Claim.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Claim pointer: %w", err)
+			}
+		}
+
+	}
+	// t.HeldEvents ([]power.CronEvent) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.HeldEvents: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.HeldEvents = make([]CronEvent, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v CronEvent
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.HeldEvents[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufPowerCheckpoint = []byte{133}
+
+func (t *PowerCheckpoint) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufPowerCheckpoint); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	if t.Epoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Epoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Epoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.RawBytePower (big.Int) (struct)
+	if err := t.RawBytePower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.QualityAdjPower (big.Int) (struct)
+	if err := t.QualityAdjPower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.MinerCount (int64) (int64)
+	if t.MinerCount >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.MinerCount)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.MinerCount-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.MinerAboveMinPowerCount (int64) (int64)
+	if t.MinerAboveMinPowerCount >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.MinerAboveMinPowerCount)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.MinerAboveMinPowerCount-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *PowerCheckpoint) UnmarshalCBOR(r io.Reader) error {
+	*t = PowerCheckpoint{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 5 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Epoch = abi.ChainEpoch(extraI)
+	}
+	// t.RawBytePower (big.Int) (struct)
+
+	{
+
+		if err := t.RawBytePower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RawBytePower: %w", err)
+		}
+
+	}
+	// t.QualityAdjPower (big.Int) (struct)
+
+	{
+
+		if err := t.QualityAdjPower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.QualityAdjPower: %w", err)
+		}
+
+	}
+	// t.MinerCount (int64) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.MinerCount = int64(extraI)
+	}
+	// t.MinerAboveMinPowerCount (int64) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.MinerAboveMinPowerCount = int64(extraI)
+	}
+	return nil
+}
+
+var lengthBufProofValidationEntry = []byte{131}
+
+func (t *ProofValidationEntry) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufProofValidationEntry); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.SealInfo (proof.SealVerifyInfo) (struct)
+	if err := t.SealInfo.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PriorityFee (big.Int) (struct)
+	if err := t.PriorityFee.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.ProveCommitDue (abi.ChainEpoch) (int64)
+	if t.ProveCommitDue >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ProveCommitDue)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.ProveCommitDue-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *ProofValidationEntry) UnmarshalCBOR(r io.Reader) error {
+	*t = ProofValidationEntry{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.SealInfo (proof.SealVerifyInfo) (struct)
+
+	{
+
+		if err := t.SealInfo.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.SealInfo: %w", err)
+		}
+
+	}
+	// t.PriorityFee (big.Int) (struct)
+
+	{
+
+		if err := t.PriorityFee.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PriorityFee: %w", err)
+		}
+
+	}
+	// t.ProveCommitDue (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.ProveCommitDue = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufUpdateClaimedPowerParams = []byte{131}
+
+func (t *UpdateClaimedPowerParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufUpdateClaimedPowerParams); err != nil {
+		return err
+	}
+
+	// t.RawByteDelta (big.Int) (struct)
+	if err := t.RawByteDelta.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.QualityAdjustedDelta (big.Int) (struct)
+	if err := t.QualityAdjustedDelta.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.FaultyQualityAdjustedDelta (big.Int) (struct)
+	if err := t.FaultyQualityAdjustedDelta.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *UpdateClaimedPowerParams) UnmarshalCBOR(r io.Reader) error {
+	*t = UpdateClaimedPowerParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.RawByteDelta (big.Int) (struct)
+
+	{
+
+		if err := t.RawByteDelta.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RawByteDelta: %w", err)
+		}
+
+	}
+	// t.QualityAdjustedDelta (big.Int) (struct)
+
+	{
+
+		if err := t.QualityAdjustedDelta.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.QualityAdjustedDelta: %w", err)
+		}
+
+	}
+	// t.FaultyQualityAdjustedDelta (big.Int) (struct)
+
+	{
+
+		if err := t.FaultyQualityAdjustedDelta.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.FaultyQualityAdjustedDelta: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufUpdatePledgeTotalParams = []byte{131}
+
+func (t *UpdatePledgeTotalParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufUpdatePledgeTotalParams); err != nil {
+		return err
+	}
+
+	// t.InitialPledgeDelta (big.Int) (struct)
+	if err := t.InitialPledgeDelta.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PreCommitDepositDelta (big.Int) (struct)
+	if err := t.PreCommitDepositDelta.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.LockedRewardsDelta (big.Int) (struct)
+	if err := t.LockedRewardsDelta.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *UpdatePledgeTotalParams) UnmarshalCBOR(r io.Reader) error {
+	*t = UpdatePledgeTotalParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.InitialPledgeDelta (big.Int) (struct)
+
+	{
+
+		if err := t.InitialPledgeDelta.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.InitialPledgeDelta: %w", err)
+		}
+
+	}
+	// t.PreCommitDepositDelta (big.Int) (struct)
+
+	{
+
+		if err := t.PreCommitDepositDelta.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PreCommitDepositDelta: %w", err)
+		}
+
+	}
+	// t.LockedRewardsDelta (big.Int) (struct)
+
+	{
+
+		if err := t.LockedRewardsDelta.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.LockedRewardsDelta: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufCurrentTotalPowerReturn = []byte{135}
+
+func (t *CurrentTotalPowerReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufCurrentTotalPowerReturn); err != nil {
+		return err
+	}
+
+	// t.RawBytePower (big.Int) (struct)
+	if err := t.RawBytePower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.QualityAdjPower (big.Int) (struct)
+	if err := t.QualityAdjPower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PledgeCollateral (big.Int) (struct)
+	if err := t.PledgeCollateral.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.QualityAdjPowerSmoothed (smoothing.FilterEstimate) (struct)
+	if err := t.QualityAdjPowerSmoothed.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.InitialPledge (big.Int) (struct)
+	if err := t.InitialPledge.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PreCommitDeposits (big.Int) (struct)
+	if err := t.PreCommitDeposits.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.LockedRewards (big.Int) (struct)
+	if err := t.LockedRewards.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *CurrentTotalPowerReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = CurrentTotalPowerReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 7 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.RawBytePower (big.Int) (struct)
+
+	{
+
+		if err := t.RawBytePower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RawBytePower: %w", err)
+		}
+
+	}
+	// t.QualityAdjPower (big.Int) (struct)
+
+	{
+
+		if err := t.QualityAdjPower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.QualityAdjPower: %w", err)
+		}
+
+	}
+	// t.PledgeCollateral (big.Int) (struct)
+
+	{
+
+		if err := t.PledgeCollateral.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PledgeCollateral: %w", err)
+		}
+
+	}
+	// t.QualityAdjPowerSmoothed (smoothing.FilterEstimate) (struct)
+
+	{
+
+		if err := t.QualityAdjPowerSmoothed.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.QualityAdjPowerSmoothed: %w", err)
+		}
+
+	}
+	// t.InitialPledge (big.Int) (struct)
+
+	{
+
+		if err := t.InitialPledge.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.InitialPledge: %w", err)
+		}
+
+	}
+	// t.PreCommitDeposits (big.Int) (struct)
+
+	{
+
+		if err := t.PreCommitDeposits.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PreCommitDeposits: %w", err)
+		}
+
+	}
+	// t.LockedRewards (big.Int) (struct)
+
+	{
+
+		if err := t.LockedRewards.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.LockedRewards: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufSubmitPoRepForBulkVerifyParams = []byte{130}
+
+func (t *SubmitPoRepForBulkVerifyParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufSubmitPoRepForBulkVerifyParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.SealInfo (proof.SealVerifyInfo) (struct)
+	if err := t.SealInfo.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.ProveCommitDue (abi.ChainEpoch) (int64)
+	if t.ProveCommitDue >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ProveCommitDue)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.ProveCommitDue-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *SubmitPoRepForBulkVerifyParams) UnmarshalCBOR(r io.Reader) error {
+	*t = SubmitPoRepForBulkVerifyParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.SealInfo (proof.SealVerifyInfo) (struct)
+
+	{
+
+		if err := t.SealInfo.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.SealInfo: %w", err)
+		}
+
+	}
+	// t.ProveCommitDue (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.ProveCommitDue = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufRecordProvingPeriodParams = []byte{129}
+
+func (t *RecordProvingPeriodParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRecordProvingPeriodParams); err != nil {
+		return err
+	}
+
+	// t.MissedPoSt (bool) (bool)
+	if err := cbg.WriteBool(w, t.MissedPoSt); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RecordProvingPeriodParams) UnmarshalCBOR(r io.Reader) error {
+	*t = RecordProvingPeriodParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.MissedPoSt (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.MissedPoSt = false
+	case 21:
+		t.MissedPoSt = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	return nil
+}
+
+var lengthBufMinerFaultStatusReturn = []byte{130}
+
+func (t *MinerFaultStatusReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufMinerFaultStatusReturn); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.MissedPeriods (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.MissedPeriods)); err != nil {
+		return err
+	}
+
+	// t.DetectedInactive (bool) (bool)
+	if err := cbg.WriteBool(w, t.DetectedInactive); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *MinerFaultStatusReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = MinerFaultStatusReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.MissedPeriods (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.MissedPeriods = uint64(extra)
+
+	}
+	// t.DetectedInactive (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.DetectedInactive = false
+	case 21:
+		t.DetectedInactive = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	return nil
+}
+
+var lengthBufNetworkVersionReturn = []byte{129}
+
+func (t *NetworkVersionReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufNetworkVersionReturn); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.NetworkVersion (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.NetworkVersion)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *NetworkVersionReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = NetworkVersionReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.NetworkVersion (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.NetworkVersion = uint64(extra)
+
+	}
+	return nil
+}
+
+var lengthBufQuarantinedMiner = []byte{131}
+
+func (t *QuarantinedMiner) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufQuarantinedMiner); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Miner (address.Address) (struct)
+	if err := t.Miner.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.QuarantinedEpoch (abi.ChainEpoch) (int64)
+	if t.QuarantinedEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.QuarantinedEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.QuarantinedEpoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.HeldEvents (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.HeldEvents)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *QuarantinedMiner) UnmarshalCBOR(r io.Reader) error {
+	*t = QuarantinedMiner{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Miner (address.Address) (struct)
+
+	{
+
+		if err := t.Miner.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Miner: %w", err)
+		}
+
+	}
+	// t.QuarantinedEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.QuarantinedEpoch = abi.ChainEpoch(extraI)
+	}
+	// t.HeldEvents (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.HeldEvents = uint64(extra)
+
+	}
+	return nil
+}
+
+var lengthBufCronQuarantinedMinersReturn = []byte{129}
+
+func (t *CronQuarantinedMinersReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufCronQuarantinedMinersReturn); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Miners ([]power.QuarantinedMiner) (slice)
+	if len(t.Miners) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Miners was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Miners))); err != nil {
+		return err
+	}
+	for _, v := range t.Miners {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *CronQuarantinedMinersReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = CronQuarantinedMinersReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Miners ([]power.QuarantinedMiner) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Miners: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Miners = make([]QuarantinedMiner, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v QuarantinedMiner
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.Miners[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufUpdateClaimedProofTypeParams = []byte{129}
+
+func (t *UpdateClaimedProofTypeParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufUpdateClaimedProofTypeParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.NewProofType (abi.RegisteredPoStProof) (int64)
+	if t.NewProofType >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.NewProofType)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.NewProofType-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *UpdateClaimedProofTypeParams) UnmarshalCBOR(r io.Reader) error {
+	*t = UpdateClaimedProofTypeParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.NewProofType (abi.RegisteredPoStProof) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.NewProofType = abi.RegisteredPoStProof(extraI)
+	}
+	return nil
+}
+
+var lengthBufPowerCheckpointParams = []byte{129}
+
+func (t *PowerCheckpointParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufPowerCheckpointParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	if t.Epoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Epoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Epoch-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *PowerCheckpointParams) UnmarshalCBOR(r io.Reader) error {
+	*t = PowerCheckpointParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Epoch = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufCurrentTotalPowerBriefReturn = []byte{130}
+
+func (t *CurrentTotalPowerBriefReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufCurrentTotalPowerBriefReturn); err != nil {
+		return err
+	}
+
+	// t.QualityAdjPowerSmoothed (smoothing.FilterEstimate) (struct)
+	if err := t.QualityAdjPowerSmoothed.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.RewardSmoothed (smoothing.FilterEstimate) (struct)
+	if err := t.RewardSmoothed.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *CurrentTotalPowerBriefReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = CurrentTotalPowerBriefReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.QualityAdjPowerSmoothed (smoothing.FilterEstimate) (struct)
+
+	{
+
+		if err := t.QualityAdjPowerSmoothed.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.QualityAdjPowerSmoothed: %w", err)
+		}
+
+	}
+	// t.RewardSmoothed (smoothing.FilterEstimate) (struct)
+
+	{
+
+		if err := t.RewardSmoothed.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RewardSmoothed: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufNetworkFaultRateReturn = []byte{130}
+
+func (t *NetworkFaultRateReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufNetworkFaultRateReturn); err != nil {
+		return err
+	}
+
+	// t.FaultyQAPower (big.Int) (struct)
+	if err := t.FaultyQAPower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.FaultRateSmoothed (smoothing.FilterEstimate) (struct)
+	if err := t.FaultRateSmoothed.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *NetworkFaultRateReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = NetworkFaultRateReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.FaultyQAPower (big.Int) (struct)
+
+	{
+
+		if err := t.FaultyQAPower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.FaultyQAPower: %w", err)
+		}
+
+	}
+	// t.FaultRateSmoothed (smoothing.FilterEstimate) (struct)
+
+	{
+
+		if err := t.FaultRateSmoothed.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.FaultRateSmoothed: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufMinerRawPowerReturn = []byte{131}
+
+func (t *MinerRawPowerReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufMinerRawPowerReturn); err != nil {
+		return err
+	}
+
+	// t.RawBytePower (big.Int) (struct)
+	if err := t.RawBytePower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.QualityAdjPower (big.Int) (struct)
+	if err := t.QualityAdjPower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.MeetsConsensusMinimum (bool) (bool)
+	if err := cbg.WriteBool(w, t.MeetsConsensusMinimum); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *MinerRawPowerReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = MinerRawPowerReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.RawBytePower (big.Int) (struct)
+
+	{
+
+		if err := t.RawBytePower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RawBytePower: %w", err)
+		}
+
+	}
+	// t.QualityAdjPower (big.Int) (struct)
+
+	{
+
+		if err := t.QualityAdjPower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.QualityAdjPower: %w", err)
+		}
+
+	}
+	// t.MeetsConsensusMinimum (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.MeetsConsensusMinimum = false
+	case 21:
+		t.MeetsConsensusMinimum = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	return nil
+}
+
+var lengthBufMinerCountReturn = []byte{129}
+
+func (t *MinerCountReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufMinerCountReturn); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.MinerCount (int64) (int64)
+	if t.MinerCount >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.MinerCount)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.MinerCount-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *MinerCountReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = MinerCountReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.MinerCount (int64) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.MinerCount = int64(extraI)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package power
+
+import (
+	"bytes"
+	"context"
+
+	addr "github.com/filecoin-project/go-address"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// A proof of a miner's claim, or of its absence, in a claims HAMT.
+// The proof holds the serialized HAMT nodes on the path from the root to the miner's key,
+// so it may be checked against a trusted claims root (such as State.ClaimsSnapshot) without
+// access to the rest of the state.
+type ClaimProof struct {
+	Blocks [][]byte
+}
+
+// Generates a proof of a miner's claim in the claims HAMT with the given root.
+// The miner must be identified by ID address, as claims are keyed.
+func GenerateClaimProof(store adt.Store, claimsRoot cid.Cid, miner addr.Address) (*ClaimProof, error) {
+	recorder := &recordingStore{Store: store}
+	claims, err := adt.AsMap(recorder, claimsRoot, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load claims: %w", err)
+	}
+	if _, _, err := getClaim(claims, miner); err != nil {
+		return nil, err
+	}
+	return &ClaimProof{Blocks: recorder.blocks}, nil
+}
+
+// Verifies a proof against a claims root, returning the miner's claim, or nil if the proof
+// shows the miner has no claim. An error is returned if the proof is incomplete or does not
+// match the root.
+func VerifyClaimProof(ctx context.Context, claimsRoot cid.Cid, miner addr.Address, proof *ClaimProof) (*Claim, error) {
+	store := &proofStore{ctx: ctx, blocks: proof.Blocks}
+	claims, err := adt.AsMap(store, claimsRoot, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load claims: %w", err)
+	}
+	claim, found, err := getClaim(claims, miner)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return claim, nil
+}
+
+// Records the serialized form of each block read through it.
+type recordingStore struct {
+	adt.Store
+	blocks [][]byte
+}
+
+func (s *recordingStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	var raw cbg.Deferred
+	if err := s.Store.Get(ctx, c, &raw); err != nil {
+		return err
+	}
+	s.blocks = append(s.blocks, raw.Raw)
+	return unmarshalBlock(c, raw.Raw, out)
+}
+
+// Serves reads from the blocks of a proof, each of which is checked against the requested CID.
+type proofStore struct {
+	ctx    context.Context
+	blocks [][]byte
+}
+
+func (s *proofStore) Context() context.Context {
+	return s.ctx
+}
+
+func (s *proofStore) Get(_ context.Context, c cid.Cid, out interface{}) error {
+	for _, data := range s.blocks {
+		sum, err := c.Prefix().Sum(data)
+		if err != nil {
+			return xerrors.Errorf("failed to hash proof block: %w", err)
+		}
+		if sum.Equals(c) {
+			return unmarshalBlock(c, data, out)
+		}
+	}
+	return xerrors.Errorf("proof is missing block %s", c)
+}
+
+func (s *proofStore) Put(_ context.Context, _ interface{}) (cid.Cid, error) {
+	return cid.Undef, xerrors.Errorf("cannot write to a proof")
+}
+
+func unmarshalBlock(c cid.Cid, data []byte, out interface{}) error {
+	u, ok := out.(cbg.CBORUnmarshaler)
+	if !ok {
+		return xerrors.Errorf("cannot unmarshal block %s into %T", c, out)
+	}
+	if err := u.UnmarshalCBOR(bytes.NewReader(data)); err != nil {
+		return xerrors.Errorf("failed to unmarshal block %s: %w", c, err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package power
+
+import (
+	"bytes"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// Epoch recorded for a miner whose cron events are not quarantined.
+const NoCronQuarantine = abi.ChainEpoch(-1)
+
+// A record of consecutive failures of a miner's deferred cron events.
+// A failed event is retried in the next epoch until the miner's events have failed in MaxConsecutiveCronFailures
+// epochs, when the miner is quarantined: its claim is withdrawn and its cron events are held, rather than
+// dispatched, until it is released. The run of failures ends once each event that failed has succeeded.
+type CronFailureRecord struct {
+	// Number of epochs in the run in which a delivery of the miner's cron events failed.
+	ConsecutiveFailures uint64
+	// The last epoch in which a delivery of the miner's cron events failed.
+	LastFailureEpoch abi.ChainEpoch
+	// Events that failed and have not since succeeded.
+	FailingEvents []CronEvent
+	// Epoch at which the miner was quarantined, or NoCronQuarantine.
+	QuarantinedEpoch abi.ChainEpoch
+	// The miner's claim at the time it was quarantined, to be restored on release.
+	Claim *Claim
+	// Cron events held while quarantined, to be rescheduled on release.
+	HeldEvents []CronEvent
+}
+
+func (r *CronFailureRecord) Quarantined() bool {
+	return r.QuarantinedEpoch != NoCronQuarantine
+}
+
+// Returns a miner's record of cron failures, if it has any.
+func (st *State) GetCronFailures(s adt.Store, miner addr.Address) (*CronFailureRecord, bool, error) {
+	failures, err := adt.AsMap(s, st.CronFailures, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load cron failures: %w", err)
+	}
+	return getCronFailures(failures, miner)
+}
+
+func getCronFailures(failures *adt.Map, miner addr.Address) (*CronFailureRecord, bool, error) {
+	var out CronFailureRecord
+	found, err := failures.Get(abi.AddrKey(miner), &out)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to get cron failures for %v: %w", miner, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &out, true, nil
+}
+
+// Holds a cron event if its miner is quarantined, returning whether it was held.
+func holdQuarantinedEvent(failures *adt.Map, event *CronEvent) (bool, error) {
+	record, found, err := getCronFailures(failures, event.MinerAddr)
+	if err != nil {
+		return false, err
+	}
+	if !found || !record.Quarantined() {
+		return false, nil
+	}
+	record.HeldEvents = append(record.HeldEvents, *event)
+	if err := failures.Put(abi.AddrKey(event.MinerAddr), record); err != nil {
+		return false, xerrors.Errorf("failed to put cron failures for %v: %w", event.MinerAddr, err)
+	}
+	return true, nil
+}
+
+// Records a successful delivery of a cron event. If the event had failed, it no longer counts toward
+// its miner's run of failures, which ends once none of its failed events remain.
+func (st *State) recordCronSuccess(failures *adt.Map, event *CronEvent) error {
+	record, found, err := getCronFailures(failures, event.MinerAddr)
+	if err != nil {
+		return err
+	}
+	if !found || record.Quarantined() || !record.removeFailingEvent(event) {
+		return nil
+	}
+	if len(record.FailingEvents) > 0 {
+		if err := failures.Put(abi.AddrKey(event.MinerAddr), record); err != nil {
+			return xerrors.Errorf("failed to put cron failures for %v: %w", event.MinerAddr, err)
+		}
+		return nil
+	}
+	if err := failures.Delete(abi.AddrKey(event.MinerAddr)); err != nil {
+		return xerrors.Errorf("failed to delete cron failures for %v: %w", event.MinerAddr, err)
+	}
+	return nil
+}
+
+// Removes one failing event equal to the given one, returning whether it was found.
+func (r *CronFailureRecord) removeFailingEvent(event *CronEvent) bool {
+	for i := range r.FailingEvents {
+		failing := &r.FailingEvents[i]
+		if failing.MinerAddr == event.MinerAddr && bytes.Equal(failing.CallbackPayload, event.CallbackPayload) {
+			r.FailingEvents = append(r.FailingEvents[:i], r.FailingEvents[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Records a failed delivery of a cron event. The event is rescheduled for the next epoch, unless
+// the failure quarantines the miner, in which case it is held along with the miner's claim.
+// Returns whether the miner was quarantined.
+func (st *State) recordCronFailure(failures, claims *adt.Map, events *adt.Multimap, event *CronEvent, epoch abi.ChainEpoch) (bool, error) {
+	record, found, err := getCronFailures(failures, event.MinerAddr)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		record = &CronFailureRecord{LastFailureEpoch: epoch, QuarantinedEpoch: NoCronQuarantine}
+		record.ConsecutiveFailures++
+	} else if record.LastFailureEpoch != epoch {
+		// Failures of any number of the miner's events in the same epoch count once.
+		record.LastFailureEpoch = epoch
+		record.ConsecutiveFailures++
+	}
+	// A failed retry of an event remains a single failing event.
+	record.removeFailingEvent(event)
+	record.FailingEvents = append(record.FailingEvents, *event)
+
+	quarantined := false
+	if record.Quarantined() {
+		record.HeldEvents = append(record.HeldEvents, *event)
+	} else if record.ConsecutiveFailures >= MaxConsecutiveCronFailures {
+		claim, found, err := getClaim(claims, event.MinerAddr)
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			return false, xerrors.Errorf("no claim for quarantined miner %v", event.MinerAddr)
+		}
+		if _, err := st.deleteClaim(claims, event.MinerAddr); err != nil {
+			return false, xerrors.Errorf("failed to delete claim of quarantined miner %v: %w", event.MinerAddr, err)
+		}
+		st.MinerCount--
+		record.QuarantinedEpoch = epoch
+		record.Claim = claim
+		record.HeldEvents = append(record.HeldEvents, *event)
+		quarantined = true
+	} else if err := st.appendCronEvent(events, epoch+1, event); err != nil {
+		return false, xerrors.Errorf("failed to reschedule cron event for %v: %w", event.MinerAddr, err)
+	}
+
+	if err := failures.Put(abi.AddrKey(event.MinerAddr), record); err != nil {
+		return false, xerrors.Errorf("failed to put cron failures for %v: %w", event.MinerAddr, err)
+	}
+	return quarantined, nil
+}
+
+// Releases a quarantined miner, restoring its claim and rescheduling its held cron events
+// for the given epoch.
+func (st *State) releaseCronQuarantine(failures, claims *adt.Map, events *adt.Multimap, miner addr.Address, epoch abi.ChainEpoch) error {
+	record, found, err := getCronFailures(failures, miner)
+	if err != nil {
+		return err
+	}
+	if !found || !record.Quarantined() {
+		return xerrors.Errorf("miner %v is not quarantined", miner)
+	}
+	if record.Claim == nil {
+		return xerrors.Errorf("no claim held for quarantined miner %v", miner)
+	}
+
+	if err := setClaim(claims, miner, &Claim{
+		WindowPoStProofType: record.Claim.WindowPoStProofType,
+		RawBytePower:        abi.NewStoragePower(0),
+		QualityAdjPower:     abi.NewStoragePower(0),
+	}); err != nil {
+		return err
+	}
+	if err := st.addToClaim(claims, miner, record.Claim.RawBytePower, record.Claim.QualityAdjPower); err != nil {
+		return xerrors.Errorf("failed to restore claim of %v: %w", miner, err)
+	}
+	st.MinerCount++
+
+	for i := range record.HeldEvents {
+		if err := st.appendCronEvent(events, epoch, &record.HeldEvents[i]); err != nil {
+			return xerrors.Errorf("failed to reschedule held cron event for %v: %w", miner, err)
+		}
+	}
+	if err := failures.Delete(abi.AddrKey(miner)); err != nil {
+		return xerrors.Errorf("failed to delete cron failures for %v: %w", miner, err)
+	}
+	return nil
+}
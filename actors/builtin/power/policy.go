@@ -1,12 +1,36 @@
 package power
 
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+)
+
 // The number of miners that must meet the consensus minimum miner power before that minimum power is enforced
 // as a condition of leader election.
 // This ensures a network still functions before any miners reach that threshold.
 const ConsensusMinerMinMiners = 4 // PARAM_SPEC
 
-// Maximum number of prove-commits each miner can submit in one epoch.
+// Maximum number of prove-commits of each miner verified in one epoch.
+// Those submitted beyond this are admitted in order of the priority fee paid with them, the rest being
+// deferred to the next epoch.
 //
 // This limits the number of proof partitions we may need to load in the cron call path.
 // Onboarding 1EiB/year requires at least 32 prove-commits per epoch.
 const MaxMinerProveCommitsPerEpoch = 200 // PARAM_SPEC
+
+// Maximum number of prove-commits each miner may have awaiting verification, including those deferred
+// from earlier epochs.
+const MaxMinerProveCommitsPending = 2 * MaxMinerProveCommitsPerEpoch // PARAM_SPEC
+
+// Number of consecutive proving periods in which a miner must miss a Window PoSt to be detected as inactive.
+// Other actors may consult this flag to avoid relying on a miner that has stopped proving its storage.
+const InactiveMinerFaultStreak = 3
+
+// Number of consecutive epochs in which deliveries of a miner's deferred cron events fail after which the miner
+// is quarantined. Until then a failed event is retried in the following epoch.
+const MaxConsecutiveCronFailures = 3 // PARAM_SPEC
+
+// Length of the intervals in which a checkpoint of the network's power is recorded, so that the growth of
+// power over time may be computed on chain.
+const PowerCheckpointInterval = abi.ChainEpoch(builtin.EpochsInDay) // PARAM_SPEC
@@ -2,6 +2,7 @@ package power
 
 import (
 	"bytes"
+	"sort"
 
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -13,7 +14,6 @@ import (
 
 	power0 "github.com/filecoin-project/specs-actors/actors/builtin/power"
 	power3 "github.com/filecoin-project/specs-actors/v3/actors/builtin/power"
-	power6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/power"
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
@@ -22,6 +22,7 @@ import (
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime/proof"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/smoothing"
 )
 
 type Runtime = runtime.Runtime
@@ -45,6 +46,17 @@ func (a Actor) Exports() []interface{} {
 		7:                         nil, // deprecated
 		8:                         a.SubmitPoRepForBulkVerify,
 		9:                         a.CurrentTotalPower,
+		10:                        a.RecordProvingPeriod,
+		11:                        a.MinerFaultStatus,
+		12:                        a.NetworkVersion,
+		13:                        a.ReleaseCronQuarantine,
+		14:                        a.CronQuarantinedMiners,
+		15:                        a.UpdateClaimedProofType,
+		16:                        a.PowerCheckpoint,
+		17:                        a.CurrentTotalPowerBrief,
+		18:                        a.NetworkFaultRate,
+		19:                        a.MinerRawPower,
+		20:                        a.MinerCount,
 	}
 }
 
@@ -156,13 +168,18 @@ func (a Actor) CreateMiner(rt Runtime, params *CreateMinerParams) *CreateMinerRe
 	}
 }
 
-//type UpdateClaimedPowerParams struct {
-//	RawByteDelta         abi.StoragePower
-//	QualityAdjustedDelta abi.StoragePower
-//}
-type UpdateClaimedPowerParams = power0.UpdateClaimedPowerParams
+// Changed in v8:
+// - Added the change in faulty power
+type UpdateClaimedPowerParams struct {
+	RawByteDelta         abi.StoragePower
+	QualityAdjustedDelta abi.StoragePower
+	// Change in the quality-adjusted power of the miner's faulty sectors.
+	// Faults add to this, while recoveries and the expiration or termination of faulty sectors subtract.
+	// This is independent of QualityAdjustedDelta, from which faulty power is already excluded.
+	FaultyQualityAdjustedDelta abi.StoragePower
+}
 
-// Adds or removes claimed power for the calling actor.
+// Adds or removes claimed power for the calling actor, and records the change in its faulty power.
 // May only be invoked by a miner actor.
 func (a Actor) UpdateClaimedPower(rt Runtime, params *UpdateClaimedPowerParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
@@ -175,6 +192,34 @@ func (a Actor) UpdateClaimedPower(rt Runtime, params *UpdateClaimedPowerParams)
 		err = st.addToClaim(claims, minerAddr, params.RawByteDelta, params.QualityAdjustedDelta)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update power raw %s, qa %s", params.RawByteDelta, params.QualityAdjustedDelta)
 
+		st.TotalFaultyQAPower = big.Add(st.TotalFaultyQAPower, params.FaultyQualityAdjustedDelta)
+		builtin.RequireState(rt, st.TotalFaultyQAPower.GreaterThanEqual(big.Zero()),
+			"negative total faulty power %v after change of %v", st.TotalFaultyQAPower, params.FaultyQualityAdjustedDelta)
+
+		st.Claims, err = claims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
+	})
+	return nil
+}
+
+type UpdateClaimedProofTypeParams struct {
+	NewProofType abi.RegisteredPoStProof
+}
+
+// Changes the Window PoSt proof type recorded in the calling miner's claim.
+// The claim must hold no power, so the change does not move the miner across the consensus minimum.
+// May only be invoked by a miner actor.
+func (a Actor) UpdateClaimedProofType(rt Runtime, params *UpdateClaimedProofTypeParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+	var st State
+	rt.StateTransaction(&st, func() {
+		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
+
+		err = st.setClaimedProofType(claims, minerAddr, params.NewProofType)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update proof type of claim for %v", minerAddr)
+
 		st.Claims, err = claims.Root()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
 	})
@@ -238,6 +283,14 @@ func (a Actor) CronTick(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 		st.ThisEpochRawBytePower = rawBytePower
 		// we can now assume delta is one since cron is invoked on every epoch.
 		st.updateSmoothedEstimate(abi.ChainEpoch(1))
+		st.ThisEpochRewardSmoothed = rewret.ThisEpochRewardSmoothed
+
+		// The snapshot epoch is that of the previous cron tick until it's updated.
+		err := st.recordPowerCheckpoint(adt.AsStore(rt), st.ClaimsSnapshotEpoch, rt.CurrEpoch())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record power checkpoint")
+
+		st.ClaimsSnapshot = st.Claims
+		st.ClaimsSnapshotEpoch = rt.CurrEpoch()
 	})
 
 	// update network KPI in RewardActor
@@ -253,13 +306,28 @@ func (a Actor) CronTick(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 	return nil
 }
 
-func (a Actor) UpdatePledgeTotal(rt Runtime, pledgeDelta *abi.TokenAmount) *abi.EmptyValue {
+// Changes in a miner's pledge, by purpose.
+// Changed in v8:
+// - Replaces a single total pledge delta
+type UpdatePledgeTotalParams struct {
+	// Change in initial pledge for committed sectors.
+	InitialPledgeDelta abi.TokenAmount
+	// Change in deposits held for pre-committed sectors.
+	PreCommitDepositDelta abi.TokenAmount
+	// Change in block rewards locked in the vesting table.
+	LockedRewardsDelta abi.TokenAmount
+}
+
+func (a Actor) UpdatePledgeTotal(rt Runtime, params *UpdatePledgeTotalParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
 	var st State
 	rt.StateTransaction(&st, func() {
 		validateMinerHasClaim(rt, st, rt.Caller())
-		st.addPledgeTotal(*pledgeDelta)
+		st.addPledgeTotal(params)
 		builtin.RequireState(rt, st.TotalPledgeCollateral.GreaterThanEqual(big.Zero()), "negative total pledge collateral %v", st.TotalPledgeCollateral)
+		builtin.RequireState(rt, st.TotalInitialPledge.GreaterThanEqual(big.Zero()), "negative total initial pledge %v", st.TotalInitialPledge)
+		builtin.RequireState(rt, st.TotalPreCommitDeposits.GreaterThanEqual(big.Zero()), "negative total pre-commit deposits %v", st.TotalPreCommitDeposits)
+		builtin.RequireState(rt, st.TotalLockedRewards.GreaterThanEqual(big.Zero()), "negative total locked rewards %v", st.TotalLockedRewards)
 	})
 	return nil
 }
@@ -268,7 +336,20 @@ func (a Actor) UpdatePledgeTotal(rt Runtime, pledgeDelta *abi.TokenAmount) *abi.
 // This number is empirically determined
 const GasOnSubmitVerifySeal = 34721049
 
-func (a Actor) SubmitPoRepForBulkVerify(rt Runtime, sealInfo *proof.SealVerifyInfo) *abi.EmptyValue {
+// Changed in v8:
+// - Replaces the bare seal verify info, adding the epoch by which the proof must be verified
+type SubmitPoRepForBulkVerifyParams struct {
+	SealInfo proof.SealVerifyInfo
+	// The last epoch at which the proof may be verified, after which the sector's pre-commitment expires.
+	ProveCommitDue abi.ChainEpoch
+}
+
+// Queues a seal proof for batch verification at the end of the epoch.
+// Any value sent is a priority fee, which orders the proof's admission to verification should the miner submit
+// more than MaxMinerProveCommitsPerEpoch in the epoch. Should any of the miner's proofs be deferred to the next
+// epoch, the fees of those admitted are burnt and the rest refunded. Otherwise, all the fees are refunded.
+// A deferred proof that would be verified after its pre-commitment expires is reported to the miner as failed.
+func (a Actor) SubmitPoRepForBulkVerify(rt Runtime, params *SubmitPoRepForBulkVerifyParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
 
 	minerAddr := rt.Caller()
@@ -291,11 +372,15 @@ func (a Actor) SubmitPoRepForBulkVerify(rt Runtime, sealInfo *proof.SealVerifyIn
 
 		arr, found, err := mmap.Get(abi.AddrKey(minerAddr))
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get get seal verify infos at addr %s", minerAddr)
-		if found && arr.Length() >= MaxMinerProveCommitsPerEpoch {
-			rt.Abortf(ErrTooManyProveCommits, "miner %s attempting to prove commit over %d sectors in epoch", minerAddr, MaxMinerProveCommitsPerEpoch)
+		if found && arr.Length() >= MaxMinerProveCommitsPending {
+			rt.Abortf(ErrTooManyProveCommits, "miner %s attempting to have over %d prove commits pending", minerAddr, MaxMinerProveCommitsPending)
 		}
 
-		err = mmap.Add(abi.AddrKey(minerAddr), sealInfo)
+		err = mmap.Add(abi.AddrKey(minerAddr), &ProofValidationEntry{
+			SealInfo:       params.SealInfo,
+			PriorityFee:    rt.ValueReceived(),
+			ProveCommitDue: params.ProveCommitDue,
+		})
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to insert proof into batch")
 
 		mmrc, err := mmap.Root()
@@ -310,18 +395,22 @@ func (a Actor) SubmitPoRepForBulkVerify(rt Runtime, sealInfo *proof.SealVerifyIn
 
 // Changed since v0:
 // - QualityAdjPowerSmoothed is not a pointer
-//type CurrentTotalPowerReturn struct {
-//	RawBytePower            abi.StoragePower
-//	QualityAdjPower         abi.StoragePower
-//	PledgeCollateral        abi.TokenAmount
-//	QualityAdjPowerSmoothed smoothing.FilterEstimate
-//}
-type CurrentTotalPowerReturn = power6.CurrentTotalPowerReturn
+// Changed in v8:
+// - Added the breakdown of pledge by purpose
+type CurrentTotalPowerReturn struct {
+	RawBytePower            abi.StoragePower
+	QualityAdjPower         abi.StoragePower
+	PledgeCollateral        abi.TokenAmount
+	QualityAdjPowerSmoothed smoothing.FilterEstimate
+	InitialPledge           abi.TokenAmount
+	PreCommitDeposits       abi.TokenAmount
+	LockedRewards           abi.TokenAmount
+}
 
 // Returns the total power and pledge recorded by the power actor.
-// The returned values are frozen during the cron tick before this epoch
-// so that this method returns consistent values while processing all messages
-// of an epoch.
+// The returned power and pledge collateral values are frozen during the cron tick
+// before this epoch so that this method returns consistent values while processing
+// all messages of an epoch. The breakdown of pledge by purpose reflects current totals.
 func (a Actor) CurrentTotalPower(rt Runtime, _ *abi.EmptyValue) *CurrentTotalPowerReturn {
 	rt.ValidateImmediateCallerAcceptAny()
 	var st State
@@ -332,9 +421,272 @@ func (a Actor) CurrentTotalPower(rt Runtime, _ *abi.EmptyValue) *CurrentTotalPow
 		QualityAdjPower:         st.ThisEpochQualityAdjPower,
 		PledgeCollateral:        st.ThisEpochPledgeCollateral,
 		QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+		InitialPledge:           st.TotalInitialPledge,
+		PreCommitDeposits:       st.TotalPreCommitDeposits,
+		LockedRewards:           st.TotalLockedRewards,
+	}
+}
+
+type CurrentTotalPowerBriefReturn struct {
+	QualityAdjPowerSmoothed smoothing.FilterEstimate
+	RewardSmoothed          smoothing.FilterEstimate
+}
+
+// Returns only the smoothed network power and reward estimates, as needed to compute
+// a miner's pre-commit deposit and expected rewards.
+// Both values are cached during the cron tick before this epoch, so callers needing
+// nothing else may use this in place of both CurrentTotalPower and the reward actor's
+// ThisEpochReward, accepting a reward estimate one epoch behind the reward actor's.
+func (a Actor) CurrentTotalPowerBrief(rt Runtime, _ *abi.EmptyValue) *CurrentTotalPowerBriefReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	var st State
+	rt.StateReadonly(&st)
+
+	return &CurrentTotalPowerBriefReturn{
+		QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+		RewardSmoothed:          st.ThisEpochRewardSmoothed,
 	}
 }
 
+type NetworkFaultRateReturn struct {
+	// Quality-adjusted power of all miners' faulty sectors.
+	FaultyQAPower abi.StoragePower
+	// Smoothed estimate of the fraction of committed quality-adjusted power that is faulty.
+	// The estimated fraction, as given by smoothing.Estimate, is in Q.128 format.
+	FaultRateSmoothed smoothing.FilterEstimate
+}
+
+// Returns the faulty power of the network, and a smoothed estimate of the fraction of power that is faulty.
+// The estimate is updated during each cron tick, and so is consistent across the messages of an epoch.
+func (a Actor) NetworkFaultRate(rt Runtime, _ *abi.EmptyValue) *NetworkFaultRateReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	var st State
+	rt.StateReadonly(&st)
+
+	return &NetworkFaultRateReturn{
+		FaultyQAPower:     st.TotalFaultyQAPower,
+		FaultRateSmoothed: st.FaultRateSmoothed,
+	}
+}
+
+type RecordProvingPeriodParams struct {
+	// Whether the miner missed a Window PoSt for any of its sectors in the proving period.
+	MissedPoSt bool
+}
+
+// Records the outcome of a proving period that has just ended for the calling miner,
+// extending or ending its run of periods with missed Window PoSts.
+// May only be invoked by a miner actor.
+func (a Actor) RecordProvingPeriod(rt Runtime, params *RecordProvingPeriodParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+	var st State
+	rt.StateTransaction(&st, func() {
+		validateMinerHasClaim(rt, st, minerAddr)
+
+		streaks, err := adt.AsMap(adt.AsStore(rt), st.FaultStreaks, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load fault streaks")
+
+		err = recordProvingPeriod(streaks, minerAddr, params.MissedPoSt, rt.CurrEpoch())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record proving period for %v", minerAddr)
+
+		st.FaultStreaks, err = streaks.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush fault streaks")
+	})
+	return nil
+}
+
+type MinerFaultStatusReturn struct {
+	// Number of consecutive proving periods, up to the most recently ended, in which the miner missed a Window PoSt.
+	MissedPeriods uint64
+	// Whether the miner has missed at least InactiveMinerFaultStreak consecutive periods.
+	DetectedInactive bool
+}
+
+// Returns a miner's run of proving periods with missed Window PoSts, and whether it is long
+// enough for the miner to be considered inactive.
+func (a Actor) MinerFaultStatus(rt Runtime, minerAddr *addr.Address) *MinerFaultStatusReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	miner, ok := rt.ResolveAddress(*minerAddr)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve miner address %v", *minerAddr)
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	streak, err := st.GetFaultStreak(adt.AsStore(rt), miner)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load fault streak for %v", miner)
+
+	return &MinerFaultStatusReturn{
+		MissedPeriods:    streak.MissedPeriods,
+		DetectedInactive: streak.MissedPeriods >= InactiveMinerFaultStreak,
+	}
+}
+
+type MinerRawPowerReturn struct {
+	// The miner's claimed raw byte and quality-adjusted power.
+	RawBytePower    abi.StoragePower
+	QualityAdjPower abi.StoragePower
+	// Whether the miner's power meets the consensus minimum, so that it may win blocks.
+	MeetsConsensusMinimum bool
+}
+
+// Returns a miner's claimed power, and whether it meets the minimum for consensus.
+// Unlike the network totals, the claim reflects all updates up to the current message.
+func (a Actor) MinerRawPower(rt Runtime, minerAddr *addr.Address) *MinerRawPowerReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	miner, ok := rt.ResolveAddress(*minerAddr)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve miner address %v", *minerAddr)
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	store := adt.AsStore(rt)
+	claim, found, err := st.GetClaim(store, miner)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claim for %v", miner)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no claim for miner %v", miner)
+	}
+	meetsMinimum, err := st.MinerNominalPowerMeetsConsensusMinimum(store, miner)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check consensus minimum power of %v", miner)
+
+	return &MinerRawPowerReturn{
+		RawBytePower:          claim.RawBytePower,
+		QualityAdjPower:       claim.QualityAdjPower,
+		MeetsConsensusMinimum: meetsMinimum,
+	}
+}
+
+type MinerCountReturn struct {
+	// Number of miners with a claim.
+	MinerCount int64
+}
+
+// Returns the number of miners with a claim, whether or not they have power.
+func (a Actor) MinerCount(rt Runtime, _ *abi.EmptyValue) *MinerCountReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	var st State
+	rt.StateReadonly(&st)
+	return &MinerCountReturn{MinerCount: st.MinerCount}
+}
+
+type NetworkVersionReturn struct {
+	NetworkVersion uint64
+}
+
+// Returns the network version under which the current epoch is being processed, as supplied by the runtime.
+// Actors with behaviour that depends on the network version should condition it on this value, rather than
+// assuming the version under which they were introduced.
+func (a Actor) NetworkVersion(rt Runtime, _ *abi.EmptyValue) *NetworkVersionReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	return &NetworkVersionReturn{
+		NetworkVersion: uint64(rt.NetworkVersion()),
+	}
+}
+
+// Releases a miner whose cron events were quarantined after repeated failures, restoring its claim
+// and rescheduling its held cron events for the current epoch.
+// May only be invoked by the system actor, e.g. once the miner's state has been repaired by an upgrade.
+func (a Actor) ReleaseCronQuarantine(rt Runtime, minerAddr *addr.Address) *abi.EmptyValue {
+	rt.ValidateImmediateCallerIs(builtin.SystemActorAddr)
+	miner, ok := rt.ResolveAddress(*minerAddr)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve miner address %v", *minerAddr)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		store := adt.AsStore(rt)
+		failures, err := adt.AsMap(store, st.CronFailures, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron failures")
+		record, found, err := getCronFailures(failures, miner)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron failures for %v", miner)
+		if !found || !record.Quarantined() {
+			rt.Abortf(exitcode.ErrForbidden, "miner %v is not quarantined", miner)
+		}
+
+		events, err := adt.AsMultimap(store, st.CronEventQueue, CronQueueHamtBitwidth, CronQueueAmtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron events")
+		claims, err := adt.AsMap(store, st.Claims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
+
+		err = st.releaseCronQuarantine(failures, claims, events, miner, rt.CurrEpoch())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to release miner %v", miner)
+
+		st.CronEventQueue, err = events.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush events")
+		st.Claims, err = claims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
+		st.CronFailures, err = failures.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush cron failures")
+	})
+	return nil
+}
+
+type QuarantinedMiner struct {
+	Miner            addr.Address
+	QuarantinedEpoch abi.ChainEpoch
+	// Number of cron events held for the miner.
+	HeldEvents uint64
+}
+
+type CronQuarantinedMinersReturn struct {
+	Miners []QuarantinedMiner
+}
+
+// Returns the miners whose cron events are quarantined.
+func (a Actor) CronQuarantinedMiners(rt Runtime, _ *abi.EmptyValue) *CronQuarantinedMinersReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateReadonly(&st)
+	failures, err := adt.AsMap(adt.AsStore(rt), st.CronFailures, builtin.DefaultHamtBitwidth)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron failures")
+
+	ret := CronQuarantinedMinersReturn{Miners: []QuarantinedMiner{}}
+	var record CronFailureRecord
+	err = failures.ForEach(&record, func(key string) error {
+		if !record.Quarantined() {
+			return nil
+		}
+		miner, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		ret.Miners = append(ret.Miners, QuarantinedMiner{
+			Miner:            miner,
+			QuarantinedEpoch: record.QuarantinedEpoch,
+			HeldEvents:       uint64(len(record.HeldEvents)),
+		})
+		return nil
+	})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate cron failures")
+	return &ret
+}
+
+type PowerCheckpointParams struct {
+	Epoch abi.ChainEpoch
+}
+
+// Returns the checkpoint of the network's power taken in the checkpoint interval containing an epoch.
+// Comparing checkpoints allows the growth of power over a period to be computed on chain.
+func (a Actor) PowerCheckpoint(rt Runtime, params *PowerCheckpointParams) *PowerCheckpoint {
+	rt.ValidateImmediateCallerAcceptAny()
+	if params.Epoch < 0 || params.Epoch > rt.CurrEpoch() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "checkpoint epoch %d must be in [0, %d]", params.Epoch, rt.CurrEpoch())
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	checkpoint, found, err := st.GetPowerCheckpoint(adt.AsStore(rt), params.Epoch)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load power checkpoint")
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no power checkpoint for epoch %d", params.Epoch)
+	}
+	return checkpoint
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Method utility functions
 ////////////////////////////////////////////////////////////////////////////////
@@ -355,6 +707,12 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 
 	var miners []addr.Address
 	verifies := make(map[addr.Address][]proof.SealVerifyInfo)
+	deferrals := make(map[addr.Address][]abi.SectorNumber)
+	// Deferred proofs dropped for their pre-commitments expiring before they could be verified.
+	expirations := make(map[addr.Address][]builtin.SectorProofFailure)
+	// Priority fees to be refunded, and those of proofs admitted ahead of deferred ones, to be burnt.
+	refunds := make(map[addr.Address]abi.TokenAmount)
+	burn := big.Zero()
 
 	var stErr error
 	rt.StateTransaction(&st, func() {
@@ -368,6 +726,12 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 			stErr = xerrors.Errorf("failed to load proofs validation batch: %w", err)
 			return
 		}
+		deferredBatch, err := adt.MakeEmptyMultimap(store, builtin.DefaultHamtBitwidth, ProofValidationBatchAmtBitwidth)
+		if err != nil {
+			stErr = xerrors.Errorf("failed to create deferred proofs batch: %w", err)
+			return
+		}
+		anyDeferred := false
 
 		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
 		if err != nil {
@@ -381,6 +745,16 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 				return xerrors.Errorf("failed to parse address key: %w", err)
 			}
 
+			var entries []ProofValidationEntry
+			var entry ProofValidationEntry
+			err = arr.ForEach(&entry, func(i int64) error {
+				entries = append(entries, entry)
+				return nil
+			})
+			if err != nil {
+				return xerrors.Errorf("failed to iterate over proof verify array for miner %s: %w", a, err)
+			}
+
 			// refuse to process proofs for miner with no claim
 			found, err := claims.Has(abi.AddrKey(a))
 			if err != nil {
@@ -388,22 +762,53 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 			}
 			if !found {
 				rt.Log(rtt.WARN, "skipping batch verifies for unknown miner %s", a)
+				for _, e := range entries {
+					burn = big.Add(burn, e.PriorityFee)
+				}
 				return nil
 			}
 
 			miners = append(miners, a)
 
+			admitted, deferred := admitProofs(entries)
 			var infos []proof.SealVerifyInfo
-			var svi proof.SealVerifyInfo
-			err = arr.ForEach(&svi, func(i int64) error {
-				infos = append(infos, svi)
-				return nil
-			})
-			if err != nil {
-				return xerrors.Errorf("failed to iterate over proof verify array for miner %s: %w", a, err)
+			admittedFees := big.Zero()
+			for _, e := range admitted {
+				infos = append(infos, e.SealInfo)
+				admittedFees = big.Add(admittedFees, e.PriorityFee)
 			}
-
 			verifies[a] = infos
+
+			// Fees only buy precedence over the miner's deferred proofs, so are refunded if there are none.
+			refund := big.Zero()
+			if len(deferred) > 0 {
+				burn = big.Add(burn, admittedFees)
+			} else {
+				refund = admittedFees
+			}
+			for _, e := range deferred {
+				refund = big.Add(refund, e.PriorityFee)
+				sectorNo := e.SealInfo.SectorID.Number
+				if e.ProveCommitDue <= rt.CurrEpoch() {
+					rt.Log(rtt.INFO, "dropping deferred prove commit of miner %s for sector %d, due at %d",
+						a, sectorNo, e.ProveCommitDue)
+					expirations[a] = append(expirations[a], builtin.SectorProofFailure{
+						SectorNumber: sectorNo,
+						Code:         ErrTooManyProveCommits,
+					})
+					continue
+				}
+				deferrals[a] = append(deferrals[a], sectorNo)
+				e.PriorityFee = big.Zero()
+				if err := deferredBatch.Add(abi.AddrKey(a), &e); err != nil {
+					return xerrors.Errorf("failed to defer proof for miner %s: %w", a, err)
+				}
+				anyDeferred = true
+			}
+			if len(deferrals[a]) > 0 {
+				rt.Log(rtt.INFO, "deferring %d prove commits of miner %s to the next epoch", len(deferrals[a]), a)
+			}
+			refunds[a] = refund
 			return nil
 		})
 		// Do not return immediately, all runs that get this far should wipe the ProofValidationBatchQueue.
@@ -413,7 +818,39 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 			stErr = xerrors.Errorf("failed to iterate proof batch: %w", err)
 		}
 		st.ProofValidationBatch = nil
+		if stErr == nil && anyDeferred {
+			root, err := deferredBatch.Root()
+			if err != nil {
+				stErr = xerrors.Errorf("failed to flush deferred proofs batch: %w", err)
+				return
+			}
+			st.ProofValidationBatch = &root
+		}
 	})
+
+	// Refund priority fees, burning any that can't be.
+	for _, m := range miners {
+		refund, ok := refunds[m]
+		if !ok || refund.IsZero() {
+			continue
+		}
+		code := rt.Send(m, builtin.MethodSend, nil, refund, &builtin.Discard{})
+		if code.IsError() {
+			rt.Log(rtt.ERROR, "failed to refund priority fee %v to %s, error code %d", refund, m, code)
+			burn = big.Add(burn, refund)
+		}
+	}
+	// The batch is discarded if it could not be processed. The power actor holds no funds but the priority fees
+	// of the proofs in the batch, so the fees of proofs not reached are burnt along with any due to be.
+	if stErr != nil {
+		burn = rt.CurrentBalance()
+	}
+	if burn.GreaterThan(big.Zero()) {
+		code := rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, burn, &builtin.Discard{})
+		if code.IsError() {
+			rt.Log(rtt.ERROR, "failed to burn priority fees %v, error code %d", burn, code)
+		}
+	}
 	if stErr != nil {
 		return stErr
 	}
@@ -445,12 +882,34 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 
 				seen[snum] = struct{}{}
 				successful = append(successful, snum)
-			} else {
-				rt.Log(rtt.INFO, "a proof failed from miner %s", m)
 			}
 		}
 
-		if len(successful) > 0 {
+		// A sector is reported as failed only if none of its proofs were valid.
+		var failures []builtin.SectorProofFailure
+		for i, r := range vres {
+			if !r {
+				snum := verifs[i].SectorID.Number
+				rt.Log(rtt.INFO, "a proof failed from miner %s for sector %d", m, snum)
+				if _, exists := seen[snum]; exists {
+					continue
+				}
+
+				seen[snum] = struct{}{}
+				failures = append(failures, builtin.SectorProofFailure{
+					SectorNumber: snum,
+					Code:         exitcode.ErrIllegalArgument,
+				})
+			}
+		}
+		for _, f := range expirations[m] {
+			if _, exists := seen[f.SectorNumber]; !exists {
+				seen[f.SectorNumber] = struct{}{}
+				failures = append(failures, f)
+			}
+		}
+
+		if len(successful) > 0 || len(failures) > 0 || len(deferrals[m]) > 0 {
 			code := rt.Send(
 				m,
 				builtin.MethodsMiner.ConfirmSectorProofsValid,
@@ -458,7 +917,10 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 					Sectors:                 successful,
 					RewardSmoothed:          rewret.ThisEpochRewardSmoothed,
 					RewardBaselinePower:     rewret.ThisEpochBaselinePower,
-					QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed},
+					QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+					Failures:                failures,
+					Deferred:                deferrals[m],
+				},
 				abi.NewTokenAmount(0),
 				&builtin.Discard{},
 			)
@@ -472,10 +934,39 @@ func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewa
 	return nil
 }
 
+// Selects up to MaxMinerProveCommitsPerEpoch of a miner's proofs for verification, by descending priority fee
+// and then order of submission. The rest are deferred. Both are returned in order of submission.
+func admitProofs(entries []ProofValidationEntry) (admitted, deferred []ProofValidationEntry) {
+	if len(entries) <= MaxMinerProveCommitsPerEpoch {
+		return entries, nil
+	}
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return entries[order[i]].PriorityFee.GreaterThan(entries[order[j]].PriorityFee)
+	})
+	isAdmitted := make([]bool, len(entries))
+	for _, i := range order[:MaxMinerProveCommitsPerEpoch] {
+		isAdmitted[i] = true
+	}
+	for i, e := range entries {
+		if isAdmitted[i] {
+			admitted = append(admitted, e)
+		} else {
+			deferred = append(deferred, e)
+		}
+	}
+	return admitted, deferred
+}
+
 func (a Actor) processDeferredCronEvents(rt Runtime, rewret reward.ThisEpochRewardReturn) {
 	rtEpoch := rt.CurrEpoch()
 
 	var cronEvents []CronEvent
+	// Miners with a run of failed cron events, which a successful delivery will end.
+	failingMiners := make(map[addr.Address]struct{})
 	var st State
 	rt.StateTransaction(&st, func() {
 		events, err := adt.AsMultimap(adt.AsStore(rt), st.CronEventQueue, CronQueueHamtBitwidth, CronQueueAmtBitwidth)
@@ -484,11 +975,23 @@ func (a Actor) processDeferredCronEvents(rt Runtime, rewret reward.ThisEpochRewa
 		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
 
+		failures, err := adt.AsMap(adt.AsStore(rt), st.CronFailures, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron failures")
+
 		for epoch := st.FirstCronEpoch; epoch <= rtEpoch; epoch++ {
 			epochEvents, err := loadCronEvents(events, epoch)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron events at %v", epoch)
 
-			for _, evt := range epochEvents {
+			for i := range epochEvents {
+				evt := epochEvents[i]
+				// hold events for quarantined miners until they are released
+				held, err := holdQuarantinedEvent(failures, &evt)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check quarantine of miner %v", evt.MinerAddr)
+				if held {
+					rt.Log(rtt.WARN, "holding cron event for quarantined miner %v", evt.MinerAddr)
+					continue
+				}
+
 				// refuse to process proofs for miner with no claim
 				found, err := claims.Has(abi.AddrKey(evt.MinerAddr))
 				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to look up claim")
@@ -496,6 +999,12 @@ func (a Actor) processDeferredCronEvents(rt Runtime, rewret reward.ThisEpochRewa
 					rt.Log(rtt.WARN, "skipping cron event for unknown miner %v", evt.MinerAddr)
 					continue
 				}
+
+				_, failing, err := getCronFailures(failures, evt.MinerAddr)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron failures")
+				if failing {
+					failingMiners[evt.MinerAddr] = struct{}{}
+				}
 				cronEvents = append(cronEvents, evt)
 			}
 
@@ -511,9 +1020,15 @@ func (a Actor) processDeferredCronEvents(rt Runtime, rewret reward.ThisEpochRewa
 
 		st.CronEventQueue, err = events.Root()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush events")
+		err = adt.FlushIfModified(adt.PermitWrite, failures, &st.CronFailures)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush cron failures")
 	})
-	failedMinerCrons := make([]addr.Address, 0)
 
+	type cronResult struct {
+		event CronEvent
+		ok    bool
+	}
+	var results []cronResult
 	for _, event := range cronEvents {
 
 		params := builtin.DeferredCronEventParams{
@@ -529,37 +1044,49 @@ func (a Actor) processDeferredCronEvents(rt Runtime, rewret reward.ThisEpochRewa
 			abi.NewTokenAmount(0),
 			&builtin.Discard{},
 		)
-		// If a callback fails, this actor continues to invoke other callbacks
-		// and persists state removing the failed event from the event queue. It won't be tried again.
-		// Failures are unexpected here but will result in removal of miner power as a defensive measure.
+		// If a callback fails, this actor continues to invoke other callbacks.
+		// Failures are unexpected here, so the failed event is retried in the next epoch, but a miner
+		// whose events fail repeatedly is quarantined as a defensive measure, removing its power.
 		if code != exitcode.Ok {
 			rt.Log(rtt.ERROR, "OnDeferredCronEvent failed for miner %s: exitcode %d", event.MinerAddr, code)
-			failedMinerCrons = append(failedMinerCrons, event.MinerAddr)
+		}
+		_, failing := failingMiners[event.MinerAddr]
+		if code != exitcode.Ok || failing {
+			results = append(results, cronResult{event: event, ok: code == exitcode.Ok})
 		}
 	}
 
-	if len(failedMinerCrons) > 0 {
+	if len(results) > 0 {
 		rt.StateTransaction(&st, func() {
-			claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
+			store := adt.AsStore(rt)
+			events, err := adt.AsMultimap(store, st.CronEventQueue, CronQueueHamtBitwidth, CronQueueAmtBitwidth)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron events")
+			claims, err := adt.AsMap(store, st.Claims, builtin.DefaultHamtBitwidth)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
-
-			// Remove miner claim and leave miner frozen
-			for _, minerAddr := range failedMinerCrons {
-				found, err := st.deleteClaim(claims, minerAddr)
-				if err != nil {
-					rt.Log(rtt.ERROR, "failed to delete claim for miner %s after failing OnDeferredCronEvent: %s", minerAddr, err)
-					continue
-				} else if !found {
-					rt.Log(rtt.ERROR, "can't find claim for miner %s after failing OnDeferredCronEvent: %s", minerAddr, err)
+			failures, err := adt.AsMap(store, st.CronFailures, builtin.DefaultHamtBitwidth)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron failures")
+
+			for i := range results {
+				result := &results[i]
+				if result.ok {
+					err = st.recordCronSuccess(failures, &result.event)
+					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record cron success for %v", result.event.MinerAddr)
 					continue
 				}
-
-				// Decrement miner count to keep stats consistent.
-				st.MinerCount--
+				quarantined, err := st.recordCronFailure(failures, claims, events, &result.event, rtEpoch)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record cron failure for %v", result.event.MinerAddr)
+				if quarantined {
+					rt.Log(rtt.ERROR, "quarantined cron events of miner %s after failures in %d consecutive epochs",
+						result.event.MinerAddr, MaxConsecutiveCronFailures)
+				}
 			}
 
+			st.CronEventQueue, err = events.Root()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush events")
 			st.Claims, err = claims.Root()
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
+			st.CronFailures, err = failures.Root()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush cron failures")
 		})
 	}
 }
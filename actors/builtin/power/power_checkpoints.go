@@ -0,0 +1,75 @@
+package power
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+)
+
+// Bitwidth of the PowerCheckpoints AMT, which is appended to at a steady rate and rarely read.
+const PowerCheckpointsAmtBitwidth = 5
+
+// A record of the network's power at the first cron tick in a checkpoint interval.
+type PowerCheckpoint struct {
+	// Epoch of the cron tick at which the checkpoint was taken.
+	Epoch abi.ChainEpoch
+	// Network power in effect for the following epoch, as ThisEpochRawBytePower and ThisEpochQualityAdjPower.
+	RawBytePower    abi.StoragePower
+	QualityAdjPower abi.StoragePower
+	MinerCount      int64
+	// Number of miners having proven the minimum consensus power.
+	MinerAboveMinPowerCount int64
+}
+
+// Index of the checkpoint interval containing an epoch, with intervals aligned to epoch zero.
+func powerCheckpointIndex(epoch abi.ChainEpoch) uint64 {
+	return uint64(epoch / PowerCheckpointInterval)
+}
+
+// Records a checkpoint of the network's power if the cron tick at the current epoch is the first in
+// its checkpoint interval, given the epoch of the previous cron tick.
+// A checkpoint is taken at the first tick in an interval even if the interval starts with null rounds.
+func (st *State) recordPowerCheckpoint(store adt.Store, prevTick, currEpoch abi.ChainEpoch) error {
+	if prevTick >= 0 && powerCheckpointIndex(prevTick) == powerCheckpointIndex(currEpoch) {
+		return nil
+	}
+	checkpoints, err := adt.AsArray(store, st.PowerCheckpoints, PowerCheckpointsAmtBitwidth)
+	if err != nil {
+		return xerrors.Errorf("failed to load power checkpoints: %w", err)
+	}
+	if err = checkpoints.Set(powerCheckpointIndex(currEpoch), &PowerCheckpoint{
+		Epoch:                   currEpoch,
+		RawBytePower:            st.ThisEpochRawBytePower,
+		QualityAdjPower:         st.ThisEpochQualityAdjPower,
+		MinerCount:              st.MinerCount,
+		MinerAboveMinPowerCount: st.MinerAboveMinPowerCount,
+	}); err != nil {
+		return xerrors.Errorf("failed to record power checkpoint at %d: %w", currEpoch, err)
+	}
+	if st.PowerCheckpoints, err = checkpoints.Root(); err != nil {
+		return xerrors.Errorf("failed to flush power checkpoints: %w", err)
+	}
+	return nil
+}
+
+// Returns the checkpoint taken in the checkpoint interval containing an epoch, if any.
+// No checkpoint is taken for an interval in which no cron tick executed.
+func (st *State) GetPowerCheckpoint(store adt.Store, epoch abi.ChainEpoch) (*PowerCheckpoint, bool, error) {
+	if epoch < 0 {
+		return nil, false, xerrors.Errorf("invalid checkpoint epoch %d", epoch)
+	}
+	checkpoints, err := adt.AsArray(store, st.PowerCheckpoints, PowerCheckpointsAmtBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load power checkpoints: %w", err)
+	}
+	var checkpoint PowerCheckpoint
+	found, err := checkpoints.Get(powerCheckpointIndex(epoch), &checkpoint)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to get power checkpoint for epoch %d: %w", epoch, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &checkpoint, true, nil
+}
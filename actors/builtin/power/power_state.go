@@ -12,7 +12,10 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/reward"
+	"github.com/filecoin-project/specs-actors/v8/actors/runtime/proof"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/math"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/smoothing"
 )
 
@@ -43,12 +46,30 @@ type State struct {
 	TotalQABytesCommitted abi.StoragePower
 	TotalPledgeCollateral abi.TokenAmount
 
+	// Breakdown of pledge by purpose, as reported by miners.
+	// TotalPledgeCollateral is the sum of TotalInitialPledge and TotalLockedRewards.
+	// Pre-commit deposits are tracked alongside but do not count towards TotalPledgeCollateral.
+	TotalInitialPledge     abi.TokenAmount
+	TotalPreCommitDeposits abi.TokenAmount
+	TotalLockedRewards     abi.TokenAmount
+
+	// Quality-adjusted power of miners' faulty sectors, as reported by miners.
+	// This power is excluded from the totals above.
+	TotalFaultyQAPower abi.StoragePower
+
 	// These fields are set once per epoch in the previous cron tick and used
 	// for consistent values across a single epoch's state transition.
 	ThisEpochRawBytePower     abi.StoragePower
 	ThisEpochQualityAdjPower  abi.StoragePower
 	ThisEpochPledgeCollateral abi.TokenAmount
 	ThisEpochQAPowerSmoothed  smoothing.FilterEstimate
+	// The reward actor's smoothed estimate of the per-epoch reward, as read at the start of the
+	// previous cron tick. It's taken before that tick updates the network KPI, so lags the
+	// reward actor's own estimate by one epoch.
+	ThisEpochRewardSmoothed smoothing.FilterEstimate
+	// Smoothed estimate of the fraction of committed quality-adjusted power that is faulty,
+	// updated with the power at each cron tick. The observed fraction is in Q.128 format.
+	FaultRateSmoothed smoothing.FilterEstimate
 
 	MinerCount int64
 	// Number of miners having proven the minimum consensus power.
@@ -64,7 +85,24 @@ type State struct {
 	// Claimed power for each miner.
 	Claims cid.Cid // Map, HAMT[address]Claim
 
-	ProofValidationBatch *cid.Cid // Multimap, (HAMT[Address]AMT[SealVerifyInfo])
+	// Root of Claims as of the end of the cron tick at ClaimsSnapshotEpoch, which is set every epoch.
+	// Light clients may verify a miner's claim at that epoch against this root with a ClaimProof.
+	ClaimsSnapshot      cid.Cid // Map, HAMT[address]Claim
+	ClaimsSnapshotEpoch abi.ChainEpoch
+
+	// Runs of consecutive proving periods in which miners missed a Window PoSt, up to the most
+	// recently ended period. Miners that proved all their sectors in their last period have no entry.
+	FaultStreaks cid.Cid // Map, HAMT[address]FaultStreak
+
+	// Runs of consecutive failed deliveries of miners' deferred cron events, including those of
+	// quarantined miners. Miners whose last cron event was delivered have no entry.
+	CronFailures cid.Cid // Map, HAMT[address]CronFailureRecord
+
+	// Checkpoints of the network's power, indexed by checkpoint interval since epoch zero.
+	PowerCheckpoints cid.Cid // Array, AMT[uint64]PowerCheckpoint
+
+	// Proofs awaiting batch verification at the next cron tick, including those deferred from earlier ticks.
+	ProofValidationBatch *cid.Cid // Multimap, (HAMT[Address]AMT[ProofValidationEntry])
 }
 
 type Claim struct {
@@ -78,6 +116,24 @@ type Claim struct {
 	QualityAdjPower abi.StoragePower
 }
 
+// A run of consecutive proving periods in which a miner missed a Window PoSt.
+type FaultStreak struct {
+	// Number of consecutive proving periods missed, up to the most recently ended.
+	MissedPeriods uint64
+	// Epoch at which the first missed period of the run ended.
+	StartEpoch abi.ChainEpoch
+}
+
+// A seal proof awaiting batch verification.
+type ProofValidationEntry struct {
+	SealInfo proof.SealVerifyInfo
+	// Fee paid by the miner for the proof to be verified ahead of its others, should it submit more than
+	// can be verified in an epoch. Zero for a proof deferred from an earlier epoch, its fee having been refunded.
+	PriorityFee abi.TokenAmount
+	// The last epoch at which the proof may be verified, after which the sector's pre-commitment expires.
+	ProveCommitDue abi.ChainEpoch
+}
+
 type CronEvent struct {
 	MinerAddr       addr.Address
 	CallbackPayload []byte
@@ -92,6 +148,10 @@ func ConstructState(store adt.Store) (*State, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create empty multimap: %w", err)
 	}
+	emptyCheckpointsArrayCid, err := adt.StoreEmptyArray(store, PowerCheckpointsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty array: %w", err)
+	}
 
 	return &State{
 		TotalRawBytePower:         abi.NewStoragePower(0),
@@ -99,13 +159,24 @@ func ConstructState(store adt.Store) (*State, error) {
 		TotalQualityAdjPower:      abi.NewStoragePower(0),
 		TotalQABytesCommitted:     abi.NewStoragePower(0),
 		TotalPledgeCollateral:     abi.NewTokenAmount(0),
+		TotalInitialPledge:        abi.NewTokenAmount(0),
+		TotalPreCommitDeposits:    abi.NewTokenAmount(0),
+		TotalLockedRewards:        abi.NewTokenAmount(0),
+		TotalFaultyQAPower:        abi.NewStoragePower(0),
 		ThisEpochRawBytePower:     abi.NewStoragePower(0),
 		ThisEpochQualityAdjPower:  abi.NewStoragePower(0),
 		ThisEpochPledgeCollateral: abi.NewTokenAmount(0),
 		ThisEpochQAPowerSmoothed:  smoothing.NewEstimate(InitialQAPowerEstimatePosition, InitialQAPowerEstimateVelocity),
+		ThisEpochRewardSmoothed:   smoothing.NewEstimate(reward.InitialRewardPositionEstimate, reward.InitialRewardVelocityEstimate),
+		FaultRateSmoothed:         smoothing.DefaultInitialEstimate(),
 		FirstCronEpoch:            0,
 		CronEventQueue:            emptyCronQueueMMapCid,
 		Claims:                    emptyClaimsMapCid,
+		ClaimsSnapshot:            emptyClaimsMapCid,
+		ClaimsSnapshotEpoch:       -1,
+		FaultStreaks:              emptyClaimsMapCid,
+		CronFailures:              emptyClaimsMapCid,
+		PowerCheckpoints:          emptyCheckpointsArrayCid,
 		MinerCount:                0,
 		MinerAboveMinPowerCount:   0,
 	}, nil
@@ -231,6 +302,42 @@ func (st *State) addToClaim(claims *adt.Map, miner addr.Address, power abi.Stora
 	return setClaim(claims, miner, &newClaim)
 }
 
+// Changes the proof type of a miner's claim, which must hold no power.
+func (st *State) setClaimedProofType(claims *adt.Map, miner addr.Address, proof abi.RegisteredPoStProof) error {
+	claim, ok, err := getClaim(claims, miner)
+	if err != nil {
+		return fmt.Errorf("failed to get claim: %w", err)
+	}
+	if !ok {
+		return exitcode.ErrNotFound.Wrapf("no claim for actor %v", miner)
+	}
+	if !claim.RawBytePower.IsZero() || !claim.QualityAdjPower.IsZero() {
+		return exitcode.ErrForbidden.Wrapf("cannot change proof type of claim with power raw %v, qa %v",
+			claim.RawBytePower, claim.QualityAdjPower)
+	}
+
+	oldMinPower, err := builtin.ConsensusMinerMinPower(claim.WindowPoStProofType)
+	if err != nil {
+		return fmt.Errorf("could not get consensus miner min power: %w", err)
+	}
+	newMinPower, err := builtin.ConsensusMinerMinPower(proof)
+	if err != nil {
+		return exitcode.ErrIllegalArgument.Wrapf("could not get consensus miner min power: %w", err)
+	}
+
+	// A claim with no power meets the minimum only if the minimum is zero, as counted for new miners.
+	wasAbove := oldMinPower.LessThanEqual(big.Zero())
+	nowAbove := newMinPower.LessThanEqual(big.Zero())
+	if wasAbove && !nowAbove {
+		st.MinerAboveMinPowerCount--
+	} else if !wasAbove && nowAbove {
+		st.MinerAboveMinPowerCount++
+	}
+
+	claim.WindowPoStProofType = proof
+	return setClaim(claims, miner, claim)
+}
+
 func (st *State) updateStatsForNewMiner(windowPoStProof abi.RegisteredPoStProof) error {
 	minPower, err := builtin.ConsensusMinerMinPower(windowPoStProof)
 	if err != nil {
@@ -264,6 +371,61 @@ func (st *State) deleteClaim(claims *adt.Map, miner addr.Address) (bool, error)
 	return true, claims.Delete(abi.AddrKey(miner))
 }
 
+// Returns a miner's current run of proving periods with missed Window PoSts, which is empty
+// if the miner proved all its sectors in its most recent period.
+func (st *State) GetFaultStreak(s adt.Store, miner addr.Address) (*FaultStreak, error) {
+	streaks, err := adt.AsMap(s, st.FaultStreaks, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load fault streaks: %w", err)
+	}
+	return getFaultStreak(streaks, miner)
+}
+
+// Whether a miner has missed a Window PoSt in enough consecutive proving periods to be
+// considered inactive.
+func (st *State) MinerDetectedInactive(s adt.Store, miner addr.Address) (bool, error) {
+	streak, err := st.GetFaultStreak(s, miner)
+	if err != nil {
+		return false, err
+	}
+	return streak.MissedPeriods >= InactiveMinerFaultStreak, nil
+}
+
+// Extends a miner's run of proving periods with missed Window PoSts, or ends it if the miner
+// did not miss any in the period ending at the given epoch.
+func recordProvingPeriod(streaks *adt.Map, miner addr.Address, missed bool, epoch abi.ChainEpoch) error {
+	if !missed {
+		if _, err := streaks.TryDelete(abi.AddrKey(miner)); err != nil {
+			return xerrors.Errorf("failed to delete fault streak for %v: %w", miner, err)
+		}
+		return nil
+	}
+	streak, err := getFaultStreak(streaks, miner)
+	if err != nil {
+		return err
+	}
+	if streak.MissedPeriods == 0 {
+		streak.StartEpoch = epoch
+	}
+	streak.MissedPeriods++
+	if err := streaks.Put(abi.AddrKey(miner), streak); err != nil {
+		return xerrors.Errorf("failed to put fault streak for %v: %w", miner, err)
+	}
+	return nil
+}
+
+func getFaultStreak(streaks *adt.Map, miner addr.Address) (*FaultStreak, error) {
+	var out FaultStreak
+	found, err := streaks.Get(abi.AddrKey(miner), &out)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get fault streak for %v: %w", miner, err)
+	}
+	if !found {
+		return &FaultStreak{MissedPeriods: 0, StartEpoch: -1}, nil
+	}
+	return &out, nil
+}
+
 func getClaim(claims *adt.Map, a addr.Address) (*Claim, bool, error) {
 	var out Claim
 	found, err := claims.Get(abi.AddrKey(a), &out)
@@ -276,8 +438,11 @@ func getClaim(claims *adt.Map, a addr.Address) (*Claim, bool, error) {
 	return &out, true, nil
 }
 
-func (st *State) addPledgeTotal(amount abi.TokenAmount) {
-	st.TotalPledgeCollateral = big.Add(st.TotalPledgeCollateral, amount)
+func (st *State) addPledgeTotal(delta *UpdatePledgeTotalParams) {
+	st.TotalInitialPledge = big.Add(st.TotalInitialPledge, delta.InitialPledgeDelta)
+	st.TotalPreCommitDeposits = big.Add(st.TotalPreCommitDeposits, delta.PreCommitDepositDelta)
+	st.TotalLockedRewards = big.Add(st.TotalLockedRewards, delta.LockedRewardsDelta)
+	st.TotalPledgeCollateral = big.Sum(st.TotalPledgeCollateral, delta.InitialPledgeDelta, delta.LockedRewardsDelta)
 }
 
 func (st *State) appendCronEvent(events *adt.Multimap, epoch abi.ChainEpoch, event *CronEvent) error {
@@ -296,6 +461,19 @@ func (st *State) appendCronEvent(events *adt.Multimap, epoch abi.ChainEpoch, eve
 func (st *State) updateSmoothedEstimate(delta abi.ChainEpoch) {
 	filterQAPower := smoothing.LoadFilter(st.ThisEpochQAPowerSmoothed, smoothing.DefaultAlpha, smoothing.DefaultBeta)
 	st.ThisEpochQAPowerSmoothed = filterQAPower.NextEstimate(st.ThisEpochQualityAdjPower, delta)
+
+	filterFaultRate := smoothing.LoadFilter(st.FaultRateSmoothed, smoothing.DefaultAlpha, smoothing.DefaultBeta)
+	st.FaultRateSmoothed = filterFaultRate.NextEstimate(CurrentFaultRate(st), delta)
+}
+
+// Returns the fraction of quality-adjusted power committed by all miners, including miners below the
+// consensus minimum, that is faulty, in Q.128 format.
+func CurrentFaultRate(st *State) big.Int {
+	total := big.Add(st.TotalQABytesCommitted, st.TotalFaultyQAPower)
+	if total.LessThanEqual(big.Zero()) {
+		return big.Zero()
+	}
+	return big.Div(big.Lsh(st.TotalFaultyQAPower, math.Precision128), total)
 }
 
 func loadCronEvents(mmap *adt.Multimap, epoch abi.ChainEpoch) ([]CronEvent, error) {
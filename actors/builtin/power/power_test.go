@@ -2,6 +2,7 @@ package power_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/filecoin-project/go-state-types/network"
 	cid "github.com/ipfs/go-cid"
 	assert "github.com/stretchr/testify/assert"
 	require "github.com/stretchr/testify/require"
@@ -23,7 +25,9 @@ import (
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/reward"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime/proof"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/math"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/smoothing"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
 	"github.com/filecoin-project/specs-actors/v8/support/mock"
 	tutil "github.com/filecoin-project/specs-actors/v8/support/testing"
 )
@@ -166,6 +170,42 @@ func TestUpdateClaimedPowerFailures(t *testing.T) {
 	})
 }
 
+func TestUpdateClaimedProofType(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	miner := tutil.NewIDAddr(t, 111)
+
+	t.Run("changes proof type of claim without power", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner)
+
+		ac.updateClaimedProofType(rt, miner, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		assert.Equal(t, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1, ac.getClaim(rt, miner).WindowPoStProofType)
+		assert.Equal(t, int64(0), getState(rt).MinerAboveMinPowerCount)
+		ac.checkState(rt)
+	})
+
+	t.Run("fails if claim has power", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner)
+		ac.updateClaimedPower(rt, miner, big.NewInt(100), big.NewInt(100))
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "claim with power", func() {
+			ac.updateClaimedProofType(rt, miner, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+
+	t.Run("fails if claim does not exist for caller", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			ac.updateClaimedProofType(rt, miner, abi.RegisteredPoStProof_StackedDrgWindow64GiBV1)
+		})
+		rt.Reset()
+	})
+}
+
 func TestEnrollCronEpoch(t *testing.T) {
 	owner := tutil.NewBLSAddr(t, 0)
 	miner := tutil.NewIDAddr(t, 101)
@@ -327,6 +367,62 @@ func TestPowerAndPledgeAccounting(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("pledge accounted by purpose", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.createMinerBasic(rt, owner, owner, miner2)
+
+		actor.updatePledgeTotalByPurpose(rt, miner1, &power.UpdatePledgeTotalParams{
+			InitialPledgeDelta:    big.Zero(),
+			PreCommitDepositDelta: abi.NewTokenAmount(300),
+			LockedRewardsDelta:    abi.NewTokenAmount(50),
+		})
+		// Pre-commit deposits are released as initial pledge is taken.
+		actor.updatePledgeTotalByPurpose(rt, miner1, &power.UpdatePledgeTotalParams{
+			InitialPledgeDelta:    abi.NewTokenAmount(1000),
+			PreCommitDepositDelta: abi.NewTokenAmount(-300),
+			LockedRewardsDelta:    big.Zero(),
+		})
+		actor.updatePledgeTotalByPurpose(rt, miner2, &power.UpdatePledgeTotalParams{
+			InitialPledgeDelta:    abi.NewTokenAmount(200),
+			PreCommitDepositDelta: abi.NewTokenAmount(100),
+			LockedRewardsDelta:    abi.NewTokenAmount(20),
+		})
+
+		// Pre-commit deposits are not counted as pledge collateral.
+		actor.expectTotalPledgeEager(rt, abi.NewTokenAmount(1270))
+
+		ret := actor.currentPowerTotal(rt)
+		assert.Equal(t, abi.NewTokenAmount(1200), ret.InitialPledge)
+		assert.Equal(t, abi.NewTokenAmount(100), ret.PreCommitDeposits)
+		assert.Equal(t, abi.NewTokenAmount(70), ret.LockedRewards)
+		actor.checkState(rt)
+	})
+
+	t.Run("pledge by purpose cannot go negative", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.updatePledgeTotal(rt, miner1, abi.NewTokenAmount(1000))
+
+		// The total pledge collateral stays positive, but the locked rewards would not.
+		params := power.UpdatePledgeTotalParams{
+			InitialPledgeDelta:    big.Zero(),
+			PreCommitDepositDelta: big.Zero(),
+			LockedRewardsDelta:    abi.NewTokenAmount(-1),
+		}
+		rt.SetCaller(miner1, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalState, "negative total locked rewards", func() {
+			rt.Call(actor.UpdatePledgeTotal, &params)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+
 	t.Run("new miner updates MinerAboveMinPowerCount", func(t *testing.T) {
 		for _, test := range []struct {
 			proof          abi.RegisteredPoStProof
@@ -538,6 +634,218 @@ func TestUpdatePledgeTotal(t *testing.T) {
 	})
 }
 
+func TestFaultStreaks(t *testing.T) {
+	actor := newHarness(t)
+	owner := tutil.NewIDAddr(t, 101)
+	miner1 := tutil.NewIDAddr(t, 111)
+	miner2 := tutil.NewIDAddr(t, 112)
+	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).
+		WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+
+	t.Run("miner is detected inactive after consecutive missed periods", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.createMinerBasic(rt, owner, owner, miner2)
+
+		rt.SetEpoch(100)
+		actor.recordProvingPeriod(rt, miner1, true)
+		for i := 1; i < power.InactiveMinerFaultStreak; i++ {
+			rt.SetEpoch(rt.Epoch() + mineract.WPoStProvingPeriod)
+			assert.Equal(t, &power.MinerFaultStatusReturn{MissedPeriods: uint64(i), DetectedInactive: false}, actor.minerFaultStatus(rt, miner1))
+			actor.recordProvingPeriod(rt, miner1, true)
+		}
+		assert.Equal(t, &power.MinerFaultStatusReturn{MissedPeriods: power.InactiveMinerFaultStreak, DetectedInactive: true}, actor.minerFaultStatus(rt, miner1))
+
+		streak, err := getState(rt).GetFaultStreak(rt.AdtStore(), miner1)
+		require.NoError(t, err)
+		assert.Equal(t, abi.ChainEpoch(100), streak.StartEpoch)
+
+		// Other miners are unaffected.
+		assert.Equal(t, &power.MinerFaultStatusReturn{MissedPeriods: 0, DetectedInactive: false}, actor.minerFaultStatus(rt, miner2))
+		actor.checkState(rt)
+	})
+
+	t.Run("a proven period ends the streak", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+
+		for i := 0; i < power.InactiveMinerFaultStreak; i++ {
+			actor.recordProvingPeriod(rt, miner1, true)
+		}
+		actor.recordProvingPeriod(rt, miner1, false)
+		assert.Equal(t, &power.MinerFaultStatusReturn{MissedPeriods: 0, DetectedInactive: false}, actor.minerFaultStatus(rt, miner1))
+
+		// A miner with no streak may report a proven period.
+		actor.recordProvingPeriod(rt, miner1, false)
+		actor.recordProvingPeriod(rt, miner1, true)
+		assert.Equal(t, &power.MinerFaultStatusReturn{MissedPeriods: 1, DetectedInactive: false}, actor.minerFaultStatus(rt, miner1))
+		actor.checkState(rt)
+	})
+
+	t.Run("record proving period aborts if miner has no claim", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.deleteClaim(rt, miner1)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "unknown miner", func() {
+			actor.recordProvingPeriod(rt, miner1, true)
+		})
+	})
+}
+
+func TestNetworkFaultRate(t *testing.T) {
+	actor := newHarness(t)
+	owner := tutil.NewIDAddr(t, 101)
+	miner1 := tutil.NewIDAddr(t, 111)
+	miner2 := tutil.NewIDAddr(t, 112)
+	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).
+		WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+
+	qaPower := abi.NewStoragePower(10 << 30)
+	faultyPower := abi.NewStoragePower(4 << 30)
+
+	t.Run("faults and recoveries are tracked across miners", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.createMinerBasic(rt, owner, owner, miner2)
+
+		actor.updateClaimedPower(rt, miner1, big.Zero(), qaPower)
+		actor.updateClaimedPower(rt, miner2, big.Zero(), qaPower)
+		ret := actor.networkFaultRate(rt)
+		assert.True(t, ret.FaultyQAPower.IsZero())
+		assert.Equal(t, int64(0), power.CurrentFaultRate(getState(rt)).Int64())
+
+		// Miner 1 faults some of its power.
+		actor.updateClaimedPowerWithFaults(rt, miner1, big.Zero(), faultyPower.Neg(), faultyPower)
+		ret = actor.networkFaultRate(rt)
+		assert.Equal(t, faultyPower, ret.FaultyQAPower)
+		expectedRate := big.Div(big.Lsh(faultyPower, math.Precision128), big.Mul(qaPower, big.NewInt(2)))
+		assert.Equal(t, expectedRate, power.CurrentFaultRate(getState(rt)))
+
+		// Miner 2 terminates power, which is not a fault.
+		actor.updateClaimedPower(rt, miner2, big.Zero(), faultyPower.Neg())
+		assert.Equal(t, faultyPower, actor.networkFaultRate(rt).FaultyQAPower)
+
+		// Miner 1 recovers.
+		actor.updateClaimedPowerWithFaults(rt, miner1, big.Zero(), faultyPower, faultyPower.Neg())
+		ret = actor.networkFaultRate(rt)
+		assert.True(t, ret.FaultyQAPower.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("smoothed fault rate follows the current rate at cron", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.updateClaimedPower(rt, miner1, big.Zero(), qaPower)
+		actor.updateClaimedPowerWithFaults(rt, miner1, big.Zero(), faultyPower.Neg(), faultyPower)
+
+		currentRate := power.CurrentFaultRate(getState(rt))
+		actor.onEpochTickEnd(rt, 1, big.Zero(), nil, nil)
+		first := actor.networkFaultRate(rt).FaultRateSmoothed
+		assert.True(t, smoothing.Estimate(&first).GreaterThan(big.Zero()))
+		assert.True(t, smoothing.Estimate(&first).LessThan(currentRate))
+
+		actor.onEpochTickEnd(rt, 2, big.Zero(), nil, nil)
+		second := actor.networkFaultRate(rt).FaultRateSmoothed
+		assert.True(t, smoothing.Estimate(&second).GreaterThan(smoothing.Estimate(&first)))
+		actor.checkState(rt)
+	})
+
+	t.Run("recovering more power than is faulty aborts", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.updateClaimedPower(rt, miner1, big.Zero(), qaPower)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalState, "negative total faulty power", func() {
+			actor.updateClaimedPowerWithFaults(rt, miner1, big.Zero(), faultyPower, faultyPower.Neg())
+		})
+		rt.Reset()
+	})
+}
+
+func TestMinerPowerQueries(t *testing.T) {
+	actor := newHarness(t)
+	owner := tutil.NewIDAddr(t, 101)
+	miners := []addr.Address{
+		tutil.NewIDAddr(t, 111),
+		tutil.NewIDAddr(t, 112),
+		tutil.NewIDAddr(t, 113),
+		tutil.NewIDAddr(t, 114),
+	}
+	small := tutil.NewIDAddr(t, 115)
+	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).
+		WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+
+	powerUnit, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+	require.NoError(t, err)
+	smallPowerUnit := big.NewInt(1_000_000)
+	require.Equal(t, 4, power.ConsensusMinerMinMiners, "power.ConsensusMinerMinMiners has changed requiring update to this test")
+
+	t.Run("reports claims and whether they meet the consensus minimum", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		assert.Equal(t, int64(0), actor.minerCount(rt).MinerCount)
+
+		actor.createMinerBasic(rt, owner, owner, small)
+		assert.Equal(t, &power.MinerRawPowerReturn{
+			RawBytePower:          big.Zero(),
+			QualityAdjPower:       big.Zero(),
+			MeetsConsensusMinimum: false,
+		}, actor.minerRawPower(rt, small))
+
+		// While few miners meet the minimum, any miner with power may win blocks.
+		actor.updateClaimedPower(rt, small, smallPowerUnit, big.Mul(smallPowerUnit, big.NewInt(2)))
+		assert.Equal(t, &power.MinerRawPowerReturn{
+			RawBytePower:          smallPowerUnit,
+			QualityAdjPower:       big.Mul(smallPowerUnit, big.NewInt(2)),
+			MeetsConsensusMinimum: true,
+		}, actor.minerRawPower(rt, small))
+
+		for _, miner := range miners {
+			actor.createMinerBasic(rt, owner, owner, miner)
+			actor.updateClaimedPower(rt, miner, powerUnit, powerUnit)
+		}
+		assert.Equal(t, int64(5), actor.minerCount(rt).MinerCount)
+		assert.True(t, actor.minerRawPower(rt, miners[0]).MeetsConsensusMinimum)
+		assert.False(t, actor.minerRawPower(rt, small).MeetsConsensusMinimum)
+		actor.checkState(rt)
+	})
+
+	t.Run("raw power aborts for a miner with no claim", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no claim", func() {
+			rt.Call(actor.MinerRawPower, &miners[0])
+		})
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "failed to resolve", func() {
+			unresolved := tutil.NewBLSAddr(t, 1)
+			rt.Call(actor.MinerRawPower, &unresolved)
+		})
+	})
+}
+
+func TestNetworkVersion(t *testing.T) {
+	actor := newHarness(t)
+	rt := mock.NewBuilder(builtin.StoragePowerActorAddr).
+		WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID).
+		WithNetworkVersion(network.Version16).
+		Build(t)
+	actor.constructAndVerify(rt)
+
+	assert.Equal(t, uint64(network.Version16), actor.networkVersion(rt))
+	rt.SetNetworkVersion(network.Version17)
+	assert.Equal(t, uint64(network.Version17), actor.networkVersion(rt))
+}
+
 func TestCron(t *testing.T) {
 	actor := newHarness(t)
 	miner1 := tutil.NewIDAddr(t, 101)
@@ -563,6 +871,37 @@ func TestCron(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("caches reward estimate read at cron", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		brief := actor.currentPowerTotalBrief(rt)
+		st := getState(rt)
+		assert.Equal(t, st.ThisEpochQAPowerSmoothed, brief.QualityAdjPowerSmoothed)
+		assert.Equal(t, smoothing.NewEstimate(reward.InitialRewardPositionEstimate, reward.InitialRewardVelocityEstimate), brief.RewardSmoothed)
+
+		// The reward actor's estimate read at the start of cron is cached.
+		epochReward := reward.ThisEpochRewardReturn{
+			ThisEpochRewardSmoothed: smoothing.TestingConstantEstimate(abi.NewTokenAmount(123)),
+			ThisEpochBaselinePower:  actor.thisEpochBaselinePower,
+		}
+		expectedPower := big.NewInt(0)
+		rt.SetEpoch(1)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.ThisEpochReward, nil, big.Zero(), &epochReward, exitcode.Ok)
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &expectedPower, big.Zero(), nil, exitcode.Ok)
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+		rt.ExpectBatchVerifySeals(nil, nil, nil)
+		rt.Call(actor.Actor.CronTick, nil)
+		rt.Verify()
+
+		brief = actor.currentPowerTotalBrief(rt)
+		st = getState(rt)
+		assert.Equal(t, st.ThisEpochQAPowerSmoothed, brief.QualityAdjPowerSmoothed)
+		assert.Equal(t, epochReward.ThisEpochRewardSmoothed, brief.RewardSmoothed)
+		actor.checkState(rt)
+	})
+
 	t.Run("test amount sent to reward actor and state change", func(t *testing.T) {
 		powerUnit, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow2KiBV1)
 		require.NoError(t, err)
@@ -595,6 +934,34 @@ func TestCron(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("snapshots claims at the end of the cron tick", func(t *testing.T) {
+		powerUnit, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow2KiBV1)
+		require.NoError(t, err)
+
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.updateClaimedPower(rt, miner1, powerUnit, powerUnit)
+		actor.onEpochTickEnd(rt, 5, powerUnit, nil, nil)
+
+		st := getState(rt)
+		assert.Equal(t, st.Claims, st.ClaimsSnapshot)
+		assert.Equal(t, abi.ChainEpoch(5), st.ClaimsSnapshotEpoch)
+
+		// Claims updated after the tick do not change the snapshot.
+		actor.updateClaimedPower(rt, miner1, powerUnit, powerUnit)
+		st = getState(rt)
+		assert.NotEqual(t, st.Claims, st.ClaimsSnapshot)
+
+		proof, err := power.GenerateClaimProof(adt.AsStore(rt), st.ClaimsSnapshot, miner1)
+		require.NoError(t, err)
+		claim, err := power.VerifyClaimProof(context.Background(), st.ClaimsSnapshot, miner1, proof)
+		require.NoError(t, err)
+		require.NotNil(t, claim)
+		assert.Equal(t, powerUnit, claim.RawBytePower)
+		actor.checkState(rt)
+	})
+
 	t.Run("event scheduled in null round called next round", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
@@ -771,82 +1138,276 @@ func TestCron(t *testing.T) {
 		actor.expectTotalPowerEager(rt, rawPow, qaPow)
 		actor.expectMinersAboveMinPower(rt, 1)
 
-		expectedPower := big.NewInt(0)
-		rt.SetEpoch(2)
-		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
-
-		// process batch verifies first
-		rt.ExpectBatchVerifySeals(nil, nil, nil)
-
-		expectQueryNetworkInfo(rt, actor)
-
-		st := getState(rt)
-
-		input := builtin.DeferredCronEventParams{
-			EventPayload:            []byte{},
-			RewardSmoothed:          actor.thisEpochRewardSmoothed,
-			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
-		}
-
-		// First send fails
-		rt.ExpectSend(miner1, builtin.MethodsMiner.OnDeferredCronEvent, &input, big.Zero(), nil, exitcode.ErrIllegalState)
-
-		// Subsequent one still invoked
-		rt.ExpectSend(miner2, builtin.MethodsMiner.OnDeferredCronEvent, &input, big.Zero(), nil, exitcode.Ok)
-
-		// Reward actor still invoked
-		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &expectedPower, big.Zero(), nil, exitcode.Ok)
-		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
-		rt.Call(actor.Actor.CronTick, nil)
-		rt.Verify()
+		// First send fails, subsequent one still invoked
+		actor.cronTickWithMinerSends(rt, 2, rawPow, cronSend{miner1, exitcode.ErrIllegalState, nil}, cronSend{miner2, exitcode.Ok, nil})
 
 		// expect cron failure was logged
 		rt.ExpectLogsContain("OnDeferredCronEvent failed for miner")
 
-		// expect power stats to be decremented due to claim deletion
-		actor.expectTotalPowerEager(rt, big.Zero(), big.Zero())
-		actor.expectMinersAboveMinPower(rt, 0)
+		// miner keeps its claim and power until it fails repeatedly
+		actor.expectTotalPowerEager(rt, rawPow, qaPow)
+		actor.expectMinersAboveMinPower(rt, 1)
+		st := getState(rt)
+		assert.Equal(t, int64(2), st.MinerCount)
+		record, found, err := st.GetCronFailures(rt.AdtStore(), miner1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, uint64(1), record.ConsecutiveFailures)
+		assert.False(t, record.Quarantined())
 
-		// miner's claim is removed
+		// Next epoch, the failed event is retried and succeeds, ending the run of failures
+		actor.cronTickWithMinerSends(rt, 3, rawPow, cronSend{miner1, exitcode.Ok, nil})
 		st = getState(rt)
-		_, found, err := st.GetClaim(rt.AdtStore(), miner1)
+		_, found, err = st.GetCronFailures(rt.AdtStore(), miner1)
 		require.NoError(t, err)
 		assert.False(t, found)
+		actor.checkState(rt)
+	})
 
-		// miner count has been reduced to 1
-		assert.Equal(t, int64(1), st.MinerCount)
+	t.Run("counts failures of several events in one epoch once", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
 
-		// Next epoch, only the reward actor is invoked
-		rt.SetEpoch(3)
-		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+		rt.SetEpoch(1)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		for i := byte(0); i < byte(power.MaxConsecutiveCronFailures); i++ {
+			actor.enrollCronEvent(rt, miner1, 2, []byte{i})
+		}
 
-		expectQueryNetworkInfo(rt, actor)
+		rawPow, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+		require.NoError(t, err)
+		actor.updateClaimedPower(rt, miner1, rawPow, rawPow)
 
-		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &expectedPower, big.Zero(), nil, exitcode.Ok)
-		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
-		rt.ExpectBatchVerifySeals(nil, nil, nil)
+		var failed, succeeded []cronSend
+		for i := byte(0); i < byte(power.MaxConsecutiveCronFailures); i++ {
+			failed = append(failed, cronSend{miner1, exitcode.ErrIllegalState, []byte{i}})
+			succeeded = append(succeeded, cronSend{miner1, exitcode.Ok, []byte{i}})
+		}
+		actor.cronTickWithMinerSends(rt, 2, rawPow, failed...)
 
-		rt.Call(actor.Actor.CronTick, nil)
-		rt.Verify()
+		// The miner is not quarantined, as its events have failed in only one epoch.
+		actor.expectTotalPowerEager(rt, rawPow, rawPow)
+		st := getState(rt)
+		record, found, err := st.GetCronFailures(rt.AdtStore(), miner1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, uint64(1), record.ConsecutiveFailures)
+		assert.Len(t, record.FailingEvents, len(failed))
+		assert.False(t, record.Quarantined())
 		actor.checkState(rt)
-	})
-}
 
-func TestSubmitPoRepForBulkVerify(t *testing.T) {
-	actor := newHarness(t)
-	miner := tutil.NewIDAddr(t, 101)
-	owner := tutil.NewIDAddr(t, 101)
-	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+		// The run of failures ends once all the failed events succeed.
+		actor.cronTickWithMinerSends(rt, 3, rawPow, succeeded...)
+		_, found, err = getState(rt).GetCronFailures(rt.AdtStore(), miner1)
+		require.NoError(t, err)
+		assert.False(t, found)
+		actor.checkState(rt)
+	})
 
-	t.Run("registers porep and charges gas", func(t *testing.T) {
+	t.Run("success of another event does not end a run of failures", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
-		actor.createMinerBasic(rt, owner, owner, miner)
-		commR := tutil.MakeCID("commR", &mineract.SealedCIDPrefix)
-		commD := tutil.MakeCID("commD", &market.PieceCIDPrefix)
-		sealInfo := &proof.SealVerifyInfo{
-			SealProof:   actor.sealProof,
-			SealedCID:   commR,
+
+		rt.SetEpoch(1)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		failing := []byte{1}
+		healthy := []byte{2}
+		actor.enrollCronEvent(rt, miner1, 2, failing)
+		for epoch := abi.ChainEpoch(3); epoch < 2+abi.ChainEpoch(power.MaxConsecutiveCronFailures); epoch++ {
+			actor.enrollCronEvent(rt, miner1, epoch, healthy)
+		}
+
+		rawPow, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+		require.NoError(t, err)
+		actor.updateClaimedPower(rt, miner1, rawPow, rawPow)
+
+		actor.cronTickWithMinerSends(rt, 2, rawPow, cronSend{miner1, exitcode.ErrIllegalState, failing})
+
+		// The healthy event succeeds in each epoch, while the retried event keeps failing.
+		epoch := abi.ChainEpoch(3)
+		for i := uint64(2); i < power.MaxConsecutiveCronFailures; i++ {
+			actor.cronTickWithMinerSends(rt, epoch, rawPow,
+				cronSend{miner1, exitcode.Ok, healthy}, cronSend{miner1, exitcode.ErrIllegalState, failing})
+			record, found, err := getState(rt).GetCronFailures(rt.AdtStore(), miner1)
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, i, record.ConsecutiveFailures)
+			assert.Equal(t, []power.CronEvent{{MinerAddr: miner1, CallbackPayload: failing}}, record.FailingEvents)
+			actor.checkState(rt)
+			epoch++
+		}
+		actor.cronTickWithMinerSends(rt, epoch, big.Zero(),
+			cronSend{miner1, exitcode.Ok, healthy}, cronSend{miner1, exitcode.ErrIllegalState, failing})
+		rt.ExpectLogsContain("quarantined cron events of miner")
+		assert.Equal(t, []power.QuarantinedMiner{{Miner: miner1, QuarantinedEpoch: epoch, HeldEvents: 1}}, actor.cronQuarantinedMiners(rt))
+		actor.checkState(rt)
+	})
+
+	t.Run("quarantines miner after repeated failures", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetEpoch(1)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.enrollCronEvent(rt, miner1, 2, []byte{})
+
+		rawPow, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+		require.NoError(t, err)
+		actor.updateClaimedPower(rt, miner1, rawPow, rawPow)
+
+		// The event is retried in each epoch until the miner is quarantined.
+		epoch := abi.ChainEpoch(2)
+		for i := uint64(1); i < power.MaxConsecutiveCronFailures; i++ {
+			actor.cronTickWithMinerSends(rt, epoch, rawPow, cronSend{miner1, exitcode.ErrIllegalState, nil})
+			epoch++
+		}
+		actor.cronTickWithMinerSends(rt, epoch, big.Zero(), cronSend{miner1, exitcode.ErrIllegalState, nil})
+		rt.ExpectLogsContain("quarantined cron events of miner")
+
+		// The miner's claim is withdrawn.
+		actor.expectTotalPowerEager(rt, big.Zero(), big.Zero())
+		actor.expectMinersAboveMinPower(rt, 0)
+		st := getState(rt)
+		assert.Equal(t, int64(0), st.MinerCount)
+		_, found, err := st.GetClaim(rt.AdtStore(), miner1)
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Equal(t, []power.QuarantinedMiner{{Miner: miner1, QuarantinedEpoch: epoch, HeldEvents: 1}}, actor.cronQuarantinedMiners(rt))
+
+		// Further events are held rather than dispatched.
+		actor.enrollCronEvent(rt, miner1, epoch+1, []byte{})
+		actor.cronTickWithMinerSends(rt, epoch+1, big.Zero())
+		assert.Equal(t, []power.QuarantinedMiner{{Miner: miner1, QuarantinedEpoch: epoch, HeldEvents: 2}}, actor.cronQuarantinedMiners(rt))
+		actor.checkState(rt)
+
+		// Once released, the miner's claim is restored and its held events are dispatched.
+		rt.SetEpoch(epoch + 2)
+		actor.releaseCronQuarantine(rt, miner1)
+		actor.expectTotalPowerEager(rt, rawPow, rawPow)
+		actor.expectMinersAboveMinPower(rt, 1)
+		assert.Equal(t, int64(1), getState(rt).MinerCount)
+		assert.Empty(t, actor.cronQuarantinedMiners(rt))
+		actor.checkState(rt)
+
+		actor.cronTickWithMinerSends(rt, epoch+2, rawPow, cronSend{miner1, exitcode.Ok, nil}, cronSend{miner1, exitcode.Ok, nil})
+		actor.checkState(rt)
+	})
+
+	t.Run("only the system actor may release a quarantined miner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+
+		rt.SetCaller(owner, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.Actor.ReleaseCronQuarantine, &miner1)
+		})
+		rt.Reset()
+
+		// A miner that is not quarantined cannot be released.
+		rt.SetCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+			rt.Call(actor.Actor.ReleaseCronQuarantine, &miner1)
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestPowerCheckpoints(t *testing.T) {
+	actor := newHarness(t)
+	miner := tutil.NewIDAddr(t, 101)
+	owner := tutil.NewIDAddr(t, 103)
+	interval := power.PowerCheckpointInterval
+
+	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+
+	t.Run("records a checkpoint at the first cron tick of each interval", func(t *testing.T) {
+		powerUnit, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow2KiBV1)
+		require.NoError(t, err)
+
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner)
+		actor.updateClaimedPower(rt, miner, powerUnit, powerUnit)
+
+		actor.onEpochTickEnd(rt, 1, powerUnit, nil, nil)
+		checkpoint := actor.powerCheckpoint(rt, 1)
+		assert.Equal(t, abi.ChainEpoch(1), checkpoint.Epoch)
+		assert.Equal(t, powerUnit, checkpoint.RawBytePower)
+		assert.Equal(t, powerUnit, checkpoint.QualityAdjPower)
+		assert.Equal(t, int64(1), checkpoint.MinerCount)
+		assert.Equal(t, int64(1), checkpoint.MinerAboveMinPowerCount)
+
+		// A later tick in the same interval does not replace the checkpoint.
+		actor.updateClaimedPower(rt, miner, powerUnit, powerUnit)
+		doublePower := big.Mul(big.NewInt(2), powerUnit)
+		actor.onEpochTickEnd(rt, 2, doublePower, nil, nil)
+		checkpoint = actor.powerCheckpoint(rt, 2)
+		assert.Equal(t, abi.ChainEpoch(1), checkpoint.Epoch)
+		assert.Equal(t, powerUnit, checkpoint.RawBytePower)
+
+		// The first tick of the next interval is checkpointed, even after null rounds at its start.
+		actor.onEpochTickEnd(rt, interval+3, doublePower, nil, nil)
+		checkpoint = actor.powerCheckpoint(rt, interval)
+		assert.Equal(t, interval+3, checkpoint.Epoch)
+		assert.Equal(t, doublePower, checkpoint.RawBytePower)
+		assert.Equal(t, doublePower, checkpoint.QualityAdjPower)
+		actor.checkState(rt)
+	})
+
+	t.Run("no checkpoint for an interval without a cron tick", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.onEpochTickEnd(rt, 1, big.Zero(), nil, nil)
+		actor.onEpochTickEnd(rt, 2*interval, big.Zero(), nil, nil)
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			rt.Call(actor.Actor.PowerCheckpoint, &power.PowerCheckpointParams{Epoch: interval})
+		})
+		rt.Reset()
+		assert.Equal(t, 2*interval, actor.powerCheckpoint(rt, 2*interval).Epoch)
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects epochs out of range", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.onEpochTickEnd(rt, 1, big.Zero(), nil, nil)
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			rt.Call(actor.Actor.PowerCheckpoint, &power.PowerCheckpointParams{Epoch: -1})
+		})
+		rt.Reset()
+
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			rt.Call(actor.Actor.PowerCheckpoint, &power.PowerCheckpointParams{Epoch: 2})
+		})
+		rt.Reset()
+		actor.checkState(rt)
+	})
+}
+
+func TestSubmitPoRepForBulkVerify(t *testing.T) {
+	actor := newHarness(t)
+	miner := tutil.NewIDAddr(t, 101)
+	owner := tutil.NewIDAddr(t, 101)
+	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+
+	t.Run("registers porep and charges gas", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner)
+		commR := tutil.MakeCID("commR", &mineract.SealedCIDPrefix)
+		commD := tutil.MakeCID("commD", &market.PieceCIDPrefix)
+		sealInfo := &proof.SealVerifyInfo{
+			SealProof:   actor.sealProof,
+			SealedCID:   commR,
 			UnsealedCID: commD,
 		}
 		actor.submitPoRepForBulkVerify(rt, miner, sealInfo)
@@ -860,11 +1421,30 @@ func TestSubmitPoRepForBulkVerify(t *testing.T) {
 		require.NoError(t, err)
 		require.True(t, found)
 		assert.Equal(t, uint64(1), arr.Length())
-		var storedSealInfo proof.SealVerifyInfo
-		found, err = arr.Get(0, &storedSealInfo)
+		var stored power.ProofValidationEntry
+		found, err = arr.Get(0, &stored)
 		require.NoError(t, err)
 		require.True(t, found)
-		assert.Equal(t, commR, storedSealInfo.SealedCID)
+		assert.Equal(t, commR, stored.SealInfo.SealedCID)
+		assert.Equal(t, big.Zero(), stored.PriorityFee)
+		actor.checkState(rt)
+	})
+
+	t.Run("records priority fee sent with porep", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner)
+		sealInfo := &proof.SealVerifyInfo{
+			SealProof:   actor.sealProof,
+			SealedCID:   tutil.MakeCID("commR", &mineract.SealedCIDPrefix),
+			UnsealedCID: tutil.MakeCID("commD", &market.PieceCIDPrefix),
+		}
+		fee := abi.NewTokenAmount(1_000)
+		actor.submitPoRepForBulkVerifyWithFee(rt, miner, sealInfo, fee)
+
+		entries := actor.pendingProofs(rt, miner)
+		require.Len(t, entries, 1)
+		assert.Equal(t, fee, entries[0].PriorityFee)
 		actor.checkState(rt)
 	})
 
@@ -880,17 +1460,17 @@ func TestSubmitPoRepForBulkVerify(t *testing.T) {
 			return &sealInfo
 		}
 
-		// Adding MaxMinerProveCommitsPerEpoch works without error
-		for i := 0; i < power.MaxMinerProveCommitsPerEpoch; i++ {
+		// Adding MaxMinerProveCommitsPending works without error
+		for i := 0; i < power.MaxMinerProveCommitsPending; i++ {
 			actor.submitPoRepForBulkVerify(rt, miner, sealInfo(i))
 		}
 
 		rt.ExpectAbort(power.ErrTooManyProveCommits, func() {
-			actor.submitPoRepForBulkVerify(rt, miner, sealInfo(power.MaxMinerProveCommitsPerEpoch))
+			actor.submitPoRepForBulkVerify(rt, miner, sealInfo(power.MaxMinerProveCommitsPending))
 		})
 
 		// Gas only charged for successful submissions
-		rt.ExpectGasCharged(power.GasOnSubmitVerifySeal * power.MaxMinerProveCommitsPerEpoch)
+		rt.ExpectGasCharged(power.GasOnSubmitVerifySeal * power.MaxMinerProveCommitsPending)
 	})
 
 	t.Run("aborts when miner has no claim", func(t *testing.T) {
@@ -913,9 +1493,73 @@ func TestSubmitPoRepForBulkVerify(t *testing.T) {
 	})
 }
 
+func TestClaimProof(t *testing.T) {
+	ctx := context.Background()
+	store := ipld.NewADTStore(ctx)
+
+	// Enough claims that the HAMT has more than one level.
+	claims, err := adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for i := uint64(0); i < 500; i++ {
+		err := claims.Put(abi.AddrKey(tutil.NewIDAddr(t, 1000+i)), &power.Claim{
+			WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+			RawBytePower:        abi.NewStoragePower(int64(i)),
+			QualityAdjPower:     abi.NewStoragePower(int64(2 * i)),
+		})
+		require.NoError(t, err)
+	}
+	root, err := claims.Root()
+	require.NoError(t, err)
+
+	t.Run("proves a claim", func(t *testing.T) {
+		miner := tutil.NewIDAddr(t, 1042)
+		proof, err := power.GenerateClaimProof(store, root, miner)
+		require.NoError(t, err)
+		assert.Greater(t, len(proof.Blocks), 1)
+
+		claim, err := power.VerifyClaimProof(ctx, root, miner, proof)
+		require.NoError(t, err)
+		require.NotNil(t, claim)
+		assert.Equal(t, abi.NewStoragePower(42), claim.RawBytePower)
+		assert.Equal(t, abi.NewStoragePower(84), claim.QualityAdjPower)
+	})
+
+	t.Run("proves absence of a claim", func(t *testing.T) {
+		miner := tutil.NewIDAddr(t, 999)
+		proof, err := power.GenerateClaimProof(store, root, miner)
+		require.NoError(t, err)
+
+		claim, err := power.VerifyClaimProof(ctx, root, miner, proof)
+		require.NoError(t, err)
+		assert.Nil(t, claim)
+	})
+
+	t.Run("rejects incomplete proof", func(t *testing.T) {
+		miner := tutil.NewIDAddr(t, 1042)
+		proof, err := power.GenerateClaimProof(store, root, miner)
+		require.NoError(t, err)
+
+		proof.Blocks = proof.Blocks[:len(proof.Blocks)-1]
+		_, err = power.VerifyClaimProof(ctx, root, miner, proof)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects proof for another root", func(t *testing.T) {
+		miner := tutil.NewIDAddr(t, 1042)
+		proof, err := power.GenerateClaimProof(store, root, miner)
+		require.NoError(t, err)
+
+		otherRoot, err := adt.StoreEmptyMap(store, builtin.DefaultHamtBitwidth)
+		require.NoError(t, err)
+		_, err = power.VerifyClaimProof(ctx, otherRoot, miner, proof)
+		assert.Error(t, err)
+	})
+}
+
 func TestCronBatchProofVerifies(t *testing.T) {
 	sealInfo := func(i int) *proof.SealVerifyInfo {
 		var sealInfo proof.SealVerifyInfo
+		sealInfo.SealProof = abi.RegisteredSealProof_StackedDrg32GiBV1_1
 		sealInfo.SealedCID = tutil.MakeCID(fmt.Sprintf("commR-%d", i), &mineract.SealedCIDPrefix)
 		sealInfo.UnsealedCID = tutil.MakeCID(fmt.Sprintf("commD-%d", i), &market.PieceCIDPrefix)
 		sealInfo.SectorID = abi.SectorID{Number: abi.SectorNumber(i)}
@@ -1061,27 +1705,97 @@ func TestCronBatchProofVerifies(t *testing.T) {
 			miner1: {true, false, true},
 		}
 
-		// send will only be for the first and third sector as the middle sector will fail verification
-		cs := []confirmedSectorSend{{miner1, []abi.SectorNumber{info1.Number, info3.Number}}}
+		// the first and third sectors are confirmed and the middle sector reported as failed
+		expectQueryNetworkInfo(rt, ac)
+
+		st := getState(rt)
+		param := &builtin.ConfirmSectorProofsParams{
+			Sectors:                 []abi.SectorNumber{info1.Number, info3.Number},
+			RewardSmoothed:          ac.thisEpochRewardSmoothed,
+			RewardBaselinePower:     ac.thisEpochBaselinePower,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+			Failures:                []builtin.SectorProofFailure{{SectorNumber: info2.Number, Code: exitcode.ErrIllegalArgument}},
+		}
+		rt.ExpectSend(miner1, builtin.MethodsMiner.ConfirmSectorProofsValid, param, abi.NewTokenAmount(0), nil, 0)
+
+		rt.ExpectBatchVerifySeals(infos, res, nil)
+		power := big.Zero()
+		//expect power sends to reward actor
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &power, abi.NewTokenAmount(0), nil, 0)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+
+		rt.SetEpoch(0)
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+
+		rt.Call(ac.CronTick, nil)
+		rt.Verify()
+		ac.checkState(rt)
+	})
+
+	t.Run("failures are reported when all of a miner's proofs fail", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner1)
+
+		ac.submitPoRepForBulkVerify(rt, miner1, info1)
+		ac.submitPoRepForBulkVerify(rt, miner1, info2)
+
+		infos := map[addr.Address][]proof.SealVerifyInfo{miner1: {*info1, *info2}}
+		res := map[addr.Address][]bool{
+			miner1: {false, false},
+		}
 
 		expectQueryNetworkInfo(rt, ac)
 
 		st := getState(rt)
+		param := &builtin.ConfirmSectorProofsParams{
+			RewardSmoothed:          ac.thisEpochRewardSmoothed,
+			RewardBaselinePower:     ac.thisEpochBaselinePower,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+			Failures: []builtin.SectorProofFailure{
+				{SectorNumber: info1.Number, Code: exitcode.ErrIllegalArgument},
+				{SectorNumber: info2.Number, Code: exitcode.ErrIllegalArgument},
+			},
+		}
+		rt.ExpectSend(miner1, builtin.MethodsMiner.ConfirmSectorProofsValid, param, abi.NewTokenAmount(0), nil, 0)
 
-		// expect sends for confirmed sectors
-		for _, cs := range cs {
-			param := &builtin.ConfirmSectorProofsParams{
-				Sectors:                 cs.sectorNums,
-				RewardSmoothed:          ac.thisEpochRewardSmoothed,
-				RewardBaselinePower:     ac.thisEpochBaselinePower,
-				QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
-			}
-			rt.ExpectSend(cs.miner, builtin.MethodsMiner.ConfirmSectorProofsValid, param, abi.NewTokenAmount(0), nil, 0)
+		rt.ExpectBatchVerifySeals(infos, res, nil)
+		power := big.Zero()
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &power, abi.NewTokenAmount(0), nil, 0)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+
+		rt.SetEpoch(0)
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+
+		rt.Call(ac.CronTick, nil)
+		rt.Verify()
+		ac.checkState(rt)
+	})
+
+	t.Run("a sector with a valid proof is not reported as failed", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner1)
+
+		ac.submitPoRepForBulkVerify(rt, miner1, info1)
+		ac.submitPoRepForBulkVerify(rt, miner1, info1)
+
+		infos := map[addr.Address][]proof.SealVerifyInfo{miner1: {*info1, *info1}}
+		res := map[addr.Address][]bool{
+			miner1: {false, true},
 		}
 
+		expectQueryNetworkInfo(rt, ac)
+
+		st := getState(rt)
+		param := &builtin.ConfirmSectorProofsParams{
+			Sectors:                 []abi.SectorNumber{info1.Number},
+			RewardSmoothed:          ac.thisEpochRewardSmoothed,
+			RewardBaselinePower:     ac.thisEpochBaselinePower,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+		}
+		rt.ExpectSend(miner1, builtin.MethodsMiner.ConfirmSectorProofsValid, param, abi.NewTokenAmount(0), nil, 0)
+
 		rt.ExpectBatchVerifySeals(infos, res, nil)
 		power := big.Zero()
-		//expect power sends to reward actor
 		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &power, abi.NewTokenAmount(0), nil, 0)
 		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
 
@@ -1093,6 +1807,221 @@ func TestCronBatchProofVerifies(t *testing.T) {
 		ac.checkState(rt)
 	})
 
+	t.Run("proofs beyond the epoch limit are admitted by priority fee and the rest deferred", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner1)
+
+		// Each proof pays a higher fee than the last, so the first two are deferred.
+		count := power.MaxMinerProveCommitsPerEpoch + 2
+		var admitted []proof.SealVerifyInfo
+		var admittedNos []abi.SectorNumber
+		burnt := big.Zero()
+		for i := 0; i < count; i++ {
+			fee := abi.NewTokenAmount(int64(i + 1))
+			ac.submitPoRepForBulkVerifyWithFee(rt, miner1, sealInfo(i), fee)
+			if i >= 2 {
+				admitted = append(admitted, *sealInfo(i))
+				admittedNos = append(admittedNos, sealInfo(i).Number)
+				burnt = big.Add(burnt, fee)
+			}
+		}
+		infos := map[addr.Address][]proof.SealVerifyInfo{miner1: admitted}
+
+		expectQueryNetworkInfo(rt, ac)
+		// The fees of the deferred proofs are refunded, and those of the admitted ones burnt.
+		rt.ExpectSend(miner1, builtin.MethodSend, nil, abi.NewTokenAmount(1+2), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, burnt, nil, exitcode.Ok)
+		rt.ExpectBatchVerifySeals(infos, batchVerifyDefaultOutput(infos), nil)
+		st := getState(rt)
+		rt.ExpectSend(miner1, builtin.MethodsMiner.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+			Sectors:                 admittedNos,
+			RewardSmoothed:          ac.thisEpochRewardSmoothed,
+			RewardBaselinePower:     ac.thisEpochBaselinePower,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+			Deferred:                []abi.SectorNumber{sealInfo(0).Number, sealInfo(1).Number},
+		}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rawPower := big.Zero()
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &rawPower, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+
+		rt.SetEpoch(0)
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+		rt.Call(ac.CronTick, nil)
+		rt.Verify()
+
+		// The deferred proofs remain, without their fees, and are verified at the next cron tick.
+		entries := ac.pendingProofs(rt, miner1)
+		require.Len(t, entries, 2)
+		for i, entry := range entries {
+			assert.Equal(t, sealInfo(i).Number, entry.SealInfo.Number)
+			assert.Equal(t, big.Zero(), entry.PriorityFee)
+		}
+		ac.checkState(rt)
+
+		deferred := map[addr.Address][]proof.SealVerifyInfo{miner1: {*sealInfo(0), *sealInfo(1)}}
+		cs := []confirmedSectorSend{{miner1, []abi.SectorNumber{sealInfo(0).Number, sealInfo(1).Number}}}
+		ac.onEpochTickEnd(rt, 1, big.Zero(), cs, deferred)
+		ac.checkState(rt)
+	})
+
+	t.Run("priority fees are refunded when no proof is deferred", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner1)
+
+		ac.submitPoRepForBulkVerifyWithFee(rt, miner1, info1, abi.NewTokenAmount(10))
+		ac.submitPoRepForBulkVerifyWithFee(rt, miner1, info2, abi.NewTokenAmount(20))
+		infos := map[addr.Address][]proof.SealVerifyInfo{miner1: {*info1, *info2}}
+
+		expectQueryNetworkInfo(rt, ac)
+		// Nothing competed for verification, so none of the fees are burnt.
+		rt.ExpectSend(miner1, builtin.MethodSend, nil, abi.NewTokenAmount(30), nil, exitcode.Ok)
+		rt.ExpectBatchVerifySeals(infos, batchVerifyDefaultOutput(infos), nil)
+		st := getState(rt)
+		rt.ExpectSend(miner1, builtin.MethodsMiner.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+			Sectors:                 []abi.SectorNumber{info1.Number, info2.Number},
+			RewardSmoothed:          ac.thisEpochRewardSmoothed,
+			RewardBaselinePower:     ac.thisEpochBaselinePower,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+		}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rawPower := big.Zero()
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &rawPower, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+
+		rt.SetEpoch(0)
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+		rt.Call(ac.CronTick, nil)
+		rt.Verify()
+
+		assert.Nil(t, getState(rt).ProofValidationBatch)
+		ac.checkState(rt)
+	})
+
+	t.Run("priority fees are burnt when the proof batch cannot be processed", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner1)
+
+		ac.submitPoRepForBulkVerifyWithFee(rt, miner1, info1, abi.NewTokenAmount(10))
+		ac.submitPoRepForBulkVerifyWithFee(rt, miner1, info2, abi.NewTokenAmount(20))
+
+		// The proofs are filed under a key that is not an address, so who paid their fees is unknown.
+		entries := ac.pendingProofs(rt, miner1)
+		batch, err := adt.MakeEmptyMultimap(rt.AdtStore(), builtin.DefaultHamtBitwidth, power.ProofValidationBatchAmtBitwidth)
+		require.NoError(t, err)
+		for i := range entries {
+			require.NoError(t, batch.Add(invalidAddrKey{}, &entries[i]))
+		}
+		st := getState(rt)
+		root, err := batch.Root()
+		require.NoError(t, err)
+		st.ProofValidationBatch = &root
+		rt.ReplaceState(st)
+
+		expectQueryNetworkInfo(rt, ac)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, abi.NewTokenAmount(30), nil, exitcode.Ok)
+		rawPower := big.Zero()
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &rawPower, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+		rt.Call(ac.CronTick, nil)
+		rt.Verify()
+		rt.ExpectLogsContain("unexpected error processing batch proof verifies")
+
+		assert.Nil(t, getState(rt).ProofValidationBatch)
+		assert.Equal(t, big.Zero(), rt.Balance())
+		ac.checkState(rt)
+	})
+
+	t.Run("deferred proofs due before the next epoch are reported as failed", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner1)
+		rt.SetEpoch(100)
+
+		// The first proof pays the lowest fee and so is deferred, but its pre-commitment expires this epoch.
+		count := power.MaxMinerProveCommitsPerEpoch + 1
+		var admitted []proof.SealVerifyInfo
+		var admittedNos []abi.SectorNumber
+		burnt := big.Zero()
+		ac.submitPoRepForBulkVerifyDueBy(rt, miner1, sealInfo(0), abi.NewTokenAmount(1), rt.Epoch())
+		for i := 1; i < count; i++ {
+			fee := abi.NewTokenAmount(int64(i + 1))
+			ac.submitPoRepForBulkVerifyWithFee(rt, miner1, sealInfo(i), fee)
+			admitted = append(admitted, *sealInfo(i))
+			admittedNos = append(admittedNos, sealInfo(i).Number)
+			burnt = big.Add(burnt, fee)
+		}
+		infos := map[addr.Address][]proof.SealVerifyInfo{miner1: admitted}
+
+		expectQueryNetworkInfo(rt, ac)
+		// The fee of the dropped proof is refunded, and those of the proofs admitted ahead of it burnt.
+		rt.ExpectSend(miner1, builtin.MethodSend, nil, abi.NewTokenAmount(1), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, burnt, nil, exitcode.Ok)
+		rt.ExpectBatchVerifySeals(infos, batchVerifyDefaultOutput(infos), nil)
+		st := getState(rt)
+		rt.ExpectSend(miner1, builtin.MethodsMiner.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+			Sectors:                 admittedNos,
+			RewardSmoothed:          ac.thisEpochRewardSmoothed,
+			RewardBaselinePower:     ac.thisEpochBaselinePower,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+			Failures: []builtin.SectorProofFailure{{
+				SectorNumber: sealInfo(0).Number,
+				Code:         power.ErrTooManyProveCommits,
+			}},
+		}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rawPower := big.Zero()
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &rawPower, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+		rt.Call(ac.CronTick, nil)
+		rt.Verify()
+
+		// The dropped proof is not retained for the next epoch.
+		assert.Nil(t, getState(rt).ProofValidationBatch)
+		ac.checkState(rt)
+	})
+
+	t.Run("proofs paying equal fees are admitted in order of submission", func(t *testing.T) {
+		rt, ac := basicPowerSetup(t)
+		ac.createMinerBasic(rt, owner, owner, miner1)
+
+		var admitted []proof.SealVerifyInfo
+		var admittedNos []abi.SectorNumber
+		for i := 0; i <= power.MaxMinerProveCommitsPerEpoch; i++ {
+			ac.submitPoRepForBulkVerify(rt, miner1, sealInfo(i))
+			if i < power.MaxMinerProveCommitsPerEpoch {
+				admitted = append(admitted, *sealInfo(i))
+				admittedNos = append(admittedNos, sealInfo(i).Number)
+			}
+		}
+		infos := map[addr.Address][]proof.SealVerifyInfo{miner1: admitted}
+
+		// No fees were paid, so none are refunded or burnt.
+		expectQueryNetworkInfo(rt, ac)
+		rt.ExpectBatchVerifySeals(infos, batchVerifyDefaultOutput(infos), nil)
+		st := getState(rt)
+		rt.ExpectSend(miner1, builtin.MethodsMiner.ConfirmSectorProofsValid, &builtin.ConfirmSectorProofsParams{
+			Sectors:                 admittedNos,
+			RewardSmoothed:          ac.thisEpochRewardSmoothed,
+			RewardBaselinePower:     ac.thisEpochBaselinePower,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+			Deferred:                []abi.SectorNumber{sealInfo(power.MaxMinerProveCommitsPerEpoch).Number},
+		}, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rawPower := big.Zero()
+		rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &rawPower, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+
+		rt.SetEpoch(0)
+		rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+		rt.Call(ac.CronTick, nil)
+		rt.Verify()
+
+		entries := ac.pendingProofs(rt, miner1)
+		require.Len(t, entries, 1)
+		assert.Equal(t, sealInfo(power.MaxMinerProveCommitsPerEpoch).Number, entries[0].SealInfo.Number)
+		ac.checkState(rt)
+	})
+
 	t.Run("cron tick does not fail if batch verify seals fails", func(t *testing.T) {
 		rt, ac := basicPowerSetup(t)
 		ac.createMinerBasic(rt, owner, owner, miner1)
@@ -1223,6 +2152,60 @@ func (h *spActorHarness) onEpochTickEnd(rt *mock.Runtime, currEpoch abi.ChainEpo
 	require.Nil(h.t, st.ProofValidationBatch)
 }
 
+func (h *spActorHarness) powerCheckpoint(rt *mock.Runtime, epoch abi.ChainEpoch) *power.PowerCheckpoint {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.Actor.PowerCheckpoint, &power.PowerCheckpointParams{Epoch: epoch}).(*power.PowerCheckpoint)
+	rt.Verify()
+	return ret
+}
+
+type cronSend struct {
+	miner   addr.Address
+	code    exitcode.ExitCode
+	payload []byte
+}
+
+// Runs a cron tick in which miners' deferred cron events are dispatched, in order, with the given results.
+// An event with no payload given is expected to carry an empty payload.
+func (h *spActorHarness) cronTickWithMinerSends(rt *mock.Runtime, epoch abi.ChainEpoch, expectedRawPower abi.StoragePower, sends ...cronSend) {
+	rt.SetEpoch(epoch)
+	rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+	rt.ExpectBatchVerifySeals(nil, nil, nil)
+	expectQueryNetworkInfo(rt, h)
+
+	st := getState(rt)
+	for _, send := range sends {
+		payload := send.payload
+		if payload == nil {
+			payload = []byte{}
+		}
+		input := builtin.DeferredCronEventParams{
+			EventPayload:            payload,
+			RewardSmoothed:          h.thisEpochRewardSmoothed,
+			QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+		}
+		rt.ExpectSend(send.miner, builtin.MethodsMiner.OnDeferredCronEvent, &input, big.Zero(), nil, send.code)
+	}
+	rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &expectedRawPower, big.Zero(), nil, exitcode.Ok)
+	rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+	rt.Call(h.Actor.CronTick, nil)
+	rt.Verify()
+}
+
+func (h *spActorHarness) releaseCronQuarantine(rt *mock.Runtime, miner addr.Address) {
+	rt.SetCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+	rt.Call(h.Actor.ReleaseCronQuarantine, &miner)
+	rt.Verify()
+}
+
+func (h *spActorHarness) cronQuarantinedMiners(rt *mock.Runtime) []power.QuarantinedMiner {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.Actor.CronQuarantinedMiners, nil).(*power.CronQuarantinedMinersReturn)
+	rt.Verify()
+	return ret.Miners
+}
+
 func (h *spActorHarness) createMiner(rt *mock.Runtime, owner, worker, miner, robust addr.Address, peer abi.PeerID,
 	multiaddrs []abi.Multiaddrs, windowPoStProofType abi.RegisteredPoStProof, value abi.TokenAmount) {
 
@@ -1328,11 +2311,16 @@ func (h *spActorHarness) createMinerBasic(rt *mock.Runtime, owner, worker, miner
 }
 
 func (h *spActorHarness) updateClaimedPower(rt *mock.Runtime, miner addr.Address, rawDelta, qaDelta abi.StoragePower) {
+	h.updateClaimedPowerWithFaults(rt, miner, rawDelta, qaDelta, big.Zero())
+}
+
+func (h *spActorHarness) updateClaimedPowerWithFaults(rt *mock.Runtime, miner addr.Address, rawDelta, qaDelta, faultyDelta abi.StoragePower) {
 	prevCl := h.getClaim(rt, miner)
 
 	params := power.UpdateClaimedPowerParams{
-		RawByteDelta:         rawDelta,
-		QualityAdjustedDelta: qaDelta,
+		RawByteDelta:               rawDelta,
+		QualityAdjustedDelta:       qaDelta,
+		FaultyQualityAdjustedDelta: faultyDelta,
 	}
 	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
 	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
@@ -1355,18 +2343,38 @@ func (h *spActorHarness) updateClaimedPower(rt *mock.Runtime, miner addr.Address
 	}
 }
 
+func (h *spActorHarness) updateClaimedProofType(rt *mock.Runtime, miner addr.Address, proof abi.RegisteredPoStProof) {
+	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	rt.Call(h.UpdateClaimedProofType, &power.UpdateClaimedProofTypeParams{NewProofType: proof})
+	rt.Verify()
+}
+
 func (h *spActorHarness) updatePledgeTotal(rt *mock.Runtime, miner addr.Address, delta abi.TokenAmount) {
-	st := getState(rt)
-	prev := st.TotalPledgeCollateral
+	h.updatePledgeTotalByPurpose(rt, miner, &power.UpdatePledgeTotalParams{
+		InitialPledgeDelta:    delta,
+		PreCommitDepositDelta: big.Zero(),
+		LockedRewardsDelta:    big.Zero(),
+	})
+}
+
+func (h *spActorHarness) updatePledgeTotalByPurpose(rt *mock.Runtime, miner addr.Address, params *power.UpdatePledgeTotalParams) {
+	prev := getState(rt)
 
 	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
 	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
-	rt.Call(h.UpdatePledgeTotal, &delta)
+	rt.Call(h.UpdatePledgeTotal, params)
 	rt.Verify()
 
-	st = getState(rt)
-	new := st.TotalPledgeCollateral
-	require.EqualValues(h.t, big.Add(prev, delta), new)
+	st := getState(rt)
+	expectedPledge := big.Sum(prev.TotalPledgeCollateral, params.InitialPledgeDelta, params.LockedRewardsDelta)
+	require.True(h.t, expectedPledge.Equals(st.TotalPledgeCollateral), "expected total pledge %v, got %v", expectedPledge, st.TotalPledgeCollateral)
+	expectedInitialPledge := big.Add(prev.TotalInitialPledge, params.InitialPledgeDelta)
+	require.True(h.t, expectedInitialPledge.Equals(st.TotalInitialPledge), "expected initial pledge %v, got %v", expectedInitialPledge, st.TotalInitialPledge)
+	expectedDeposits := big.Add(prev.TotalPreCommitDeposits, params.PreCommitDepositDelta)
+	require.True(h.t, expectedDeposits.Equals(st.TotalPreCommitDeposits), "expected pre-commit deposits %v, got %v", expectedDeposits, st.TotalPreCommitDeposits)
+	expectedLocked := big.Add(prev.TotalLockedRewards, params.LockedRewardsDelta)
+	require.True(h.t, expectedLocked.Equals(st.TotalLockedRewards), "expected locked rewards %v, got %v", expectedLocked, st.TotalLockedRewards)
 }
 
 func (h *spActorHarness) currentPowerTotal(rt *mock.Runtime) *power.CurrentTotalPowerReturn {
@@ -1376,6 +2384,55 @@ func (h *spActorHarness) currentPowerTotal(rt *mock.Runtime) *power.CurrentTotal
 	return ret
 }
 
+func (h *spActorHarness) currentPowerTotalBrief(rt *mock.Runtime) *power.CurrentTotalPowerBriefReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.CurrentTotalPowerBrief, nil).(*power.CurrentTotalPowerBriefReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *spActorHarness) recordProvingPeriod(rt *mock.Runtime, miner addr.Address, missedPoSt bool) {
+	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	rt.Call(h.RecordProvingPeriod, &power.RecordProvingPeriodParams{MissedPoSt: missedPoSt})
+	rt.Verify()
+}
+
+func (h *spActorHarness) minerFaultStatus(rt *mock.Runtime, miner addr.Address) *power.MinerFaultStatusReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.MinerFaultStatus, &miner).(*power.MinerFaultStatusReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *spActorHarness) minerRawPower(rt *mock.Runtime, miner addr.Address) *power.MinerRawPowerReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.MinerRawPower, &miner).(*power.MinerRawPowerReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *spActorHarness) minerCount(rt *mock.Runtime) *power.MinerCountReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.MinerCount, nil).(*power.MinerCountReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *spActorHarness) networkFaultRate(rt *mock.Runtime) *power.NetworkFaultRateReturn {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.NetworkFaultRate, nil).(*power.NetworkFaultRateReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *spActorHarness) networkVersion(rt *mock.Runtime) uint64 {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.NetworkVersion, nil).(*power.NetworkVersionReturn)
+	rt.Verify()
+	return ret.NetworkVersion
+}
+
 func (h *spActorHarness) enrollCronEvent(rt *mock.Runtime, miner addr.Address, epoch abi.ChainEpoch, payload []byte) {
 	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
 	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
@@ -1388,11 +2445,48 @@ func (h *spActorHarness) enrollCronEvent(rt *mock.Runtime, miner addr.Address, e
 }
 
 func (h *spActorHarness) submitPoRepForBulkVerify(rt *mock.Runtime, minerAddr addr.Address, sealInfo *proof.SealVerifyInfo) {
+	h.submitPoRepForBulkVerifyWithFee(rt, minerAddr, sealInfo, big.Zero())
+}
+
+// Submits a proof paying a priority fee, which is added to the actor's balance.
+// The proof is due as for a sector pre-committed in the current epoch.
+func (h *spActorHarness) submitPoRepForBulkVerifyWithFee(rt *mock.Runtime, minerAddr addr.Address, sealInfo *proof.SealVerifyInfo, fee abi.TokenAmount) {
+	due := rt.Epoch() + mineract.MaxProveCommitDuration[sealInfo.SealProof]
+	h.submitPoRepForBulkVerifyDueBy(rt, minerAddr, sealInfo, fee, due)
+}
+
+func (h *spActorHarness) submitPoRepForBulkVerifyDueBy(rt *mock.Runtime, minerAddr addr.Address, sealInfo *proof.SealVerifyInfo, fee abi.TokenAmount, due abi.ChainEpoch) {
 	rt.ExpectGasCharged(power.GasOnSubmitVerifySeal)
 	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
 	rt.SetCaller(minerAddr, builtin.StorageMinerActorCodeID)
-	rt.Call(h.Actor.SubmitPoRepForBulkVerify, sealInfo)
+	rt.SetReceived(fee)
+	rt.SetBalance(big.Add(rt.Balance(), fee))
+	rt.Call(h.Actor.SubmitPoRepForBulkVerify, &power.SubmitPoRepForBulkVerifyParams{
+		SealInfo:       *sealInfo,
+		ProveCommitDue: due,
+	})
 	rt.Verify()
+	rt.SetReceived(big.Zero())
+}
+
+// A map key that does not parse as an address.
+type invalidAddrKey struct{}
+
+func (invalidAddrKey) Key() string { return "not an address" }
+
+// Returns a miner's proofs awaiting verification, in order of submission.
+func (h *spActorHarness) pendingProofs(rt *mock.Runtime, minerAddr addr.Address) []power.ProofValidationEntry {
+	st := getState(rt)
+	require.NotNil(h.t, st.ProofValidationBatch)
+	mmap, err := adt.AsMultimap(rt.AdtStore(), *st.ProofValidationBatch, builtin.DefaultHamtBitwidth, power.ProofValidationBatchAmtBitwidth)
+	require.NoError(h.t, err)
+	var entries []power.ProofValidationEntry
+	var entry power.ProofValidationEntry
+	require.NoError(h.t, mmap.ForEach(abi.AddrKey(minerAddr), &entry, func(_ int64) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	return entries
 }
 
 func (h *spActorHarness) expectTotalPowerEager(rt *mock.Runtime, expectedRaw, expectedQA abi.StoragePower) {
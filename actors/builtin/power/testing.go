@@ -23,6 +23,10 @@ type StateSummary struct {
 	Crons  CronEventsByAddress
 	Claims ClaimsByAddress
 	Proofs ProofsByAddress
+
+	TotalInitialPledge     abi.TokenAmount
+	TotalPreCommitDeposits abi.TokenAmount
+	TotalLockedRewards     abi.TokenAmount
 }
 
 // Checks internal invariants of power state.
@@ -34,6 +38,7 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 	acc.Require(st.TotalQualityAdjPower.GreaterThanEqual(big.Zero()), "total qa power is negative %v", st.TotalQualityAdjPower)
 	acc.Require(st.TotalBytesCommitted.GreaterThanEqual(big.Zero()), "total raw power committed is negative %v", st.TotalBytesCommitted)
 	acc.Require(st.TotalQABytesCommitted.GreaterThanEqual(big.Zero()), "total qa power committed is negative %v", st.TotalQABytesCommitted)
+	acc.Require(st.TotalFaultyQAPower.GreaterThanEqual(big.Zero()), "total faulty qa power is negative %v", st.TotalFaultyQAPower)
 
 	acc.Require(st.TotalRawBytePower.LessThanEqual(st.TotalQualityAdjPower),
 		"total raw power %v is greater than total quality adjusted power %v", st.TotalRawBytePower, st.TotalQualityAdjPower)
@@ -44,17 +49,114 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 	acc.Require(st.TotalQualityAdjPower.LessThanEqual(st.TotalQABytesCommitted),
 		"total qa power %v is greater than qa power committed %v", st.TotalQualityAdjPower, st.TotalQABytesCommitted)
 
+	// invariants around the breakdown of pledge
+	acc.Require(st.TotalInitialPledge.GreaterThanEqual(big.Zero()), "total initial pledge is negative %v", st.TotalInitialPledge)
+	acc.Require(st.TotalPreCommitDeposits.GreaterThanEqual(big.Zero()), "total pre-commit deposits is negative %v", st.TotalPreCommitDeposits)
+	acc.Require(st.TotalLockedRewards.GreaterThanEqual(big.Zero()), "total locked rewards is negative %v", st.TotalLockedRewards)
+	acc.Require(st.TotalPledgeCollateral.Equals(big.Add(st.TotalInitialPledge, st.TotalLockedRewards)),
+		"total pledge collateral %v is not the sum of initial pledge %v and locked rewards %v",
+		st.TotalPledgeCollateral, st.TotalInitialPledge, st.TotalLockedRewards)
+
+	if _, err := adt.AsMap(store, st.ClaimsSnapshot, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading claims snapshot: %v", err)
+	}
+
 	crons := CheckCronInvariants(st, store, acc)
 	claims := CheckClaimInvariants(st, store, acc)
+	CheckFaultStreakInvariants(st, store, acc)
+	CheckCronFailureInvariants(st, store, claims, acc)
+	CheckPowerCheckpointInvariants(st, store, acc)
 	proofs := CheckProofValidationInvariants(st, store, claims, acc)
 
 	return &StateSummary{
 		Crons:  crons,
 		Claims: claims,
 		Proofs: proofs,
+
+		TotalInitialPledge:     st.TotalInitialPledge,
+		TotalPreCommitDeposits: st.TotalPreCommitDeposits,
+		TotalLockedRewards:     st.TotalLockedRewards,
 	}, acc
 }
 
+func CheckFaultStreakInvariants(st *State, store adt.Store, acc *builtin.MessageAccumulator) {
+	streaks, err := adt.AsMap(store, st.FaultStreaks, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		acc.Addf("error loading fault streaks: %v", err)
+		return
+	}
+
+	var streak FaultStreak
+	err = streaks.ForEach(&streak, func(key string) error {
+		a, err := address.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		acc.Require(a.Protocol() == address.ID, "fault streak key %v is not an ID address", a)
+		acc.Require(streak.MissedPeriods > 0, "miner %v has an empty fault streak", a)
+		acc.Require(streak.StartEpoch >= 0, "miner %v fault streak has negative start epoch %d", a, streak.StartEpoch)
+		return nil
+	})
+	acc.RequireNoError(err, "error iterating fault streaks")
+}
+
+func CheckCronFailureInvariants(st *State, store adt.Store, claims ClaimsByAddress, acc *builtin.MessageAccumulator) {
+	failures, err := adt.AsMap(store, st.CronFailures, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		acc.Addf("error loading cron failures: %v", err)
+		return
+	}
+
+	var record CronFailureRecord
+	err = failures.ForEach(&record, func(key string) error {
+		a, err := address.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		acc.Require(a.Protocol() == address.ID, "cron failures key %v is not an ID address", a)
+		acc.Require(record.ConsecutiveFailures > 0, "miner %v has an empty run of cron failures", a)
+		acc.Require(len(record.FailingEvents) > 0, "miner %v has a run of cron failures with no failing events", a)
+		for _, event := range record.FailingEvents {
+			acc.Require(event.MinerAddr == a, "miner %v has failing cron event of miner %v", a, event.MinerAddr)
+		}
+		_, hasClaim := claims[a]
+		if record.Quarantined() {
+			acc.Require(record.ConsecutiveFailures >= MaxConsecutiveCronFailures, "quarantined miner %v has only %d cron failures",
+				a, record.ConsecutiveFailures)
+			acc.Require(record.Claim != nil, "quarantined miner %v has no held claim", a)
+			acc.Require(!hasClaim, "quarantined miner %v has a claim", a)
+		} else {
+			acc.Require(record.ConsecutiveFailures < MaxConsecutiveCronFailures, "miner %v with %d cron failures is not quarantined",
+				a, record.ConsecutiveFailures)
+			acc.Require(record.Claim == nil, "miner %v holds a claim but is not quarantined", a)
+			acc.Require(len(record.HeldEvents) == 0, "miner %v holds cron events but is not quarantined", a)
+		}
+		return nil
+	})
+	acc.RequireNoError(err, "error iterating cron failures")
+}
+
+func CheckPowerCheckpointInvariants(st *State, store adt.Store, acc *builtin.MessageAccumulator) {
+	checkpoints, err := adt.AsArray(store, st.PowerCheckpoints, PowerCheckpointsAmtBitwidth)
+	if err != nil {
+		acc.Addf("error loading power checkpoints: %v", err)
+		return
+	}
+
+	var checkpoint PowerCheckpoint
+	err = checkpoints.ForEach(&checkpoint, func(i int64) error {
+		acc.Require(powerCheckpointIndex(checkpoint.Epoch) == uint64(i), "power checkpoint %d has epoch %d in another interval", i, checkpoint.Epoch)
+		acc.Require(checkpoint.Epoch <= st.ClaimsSnapshotEpoch, "power checkpoint %d has epoch %d after last cron tick %d",
+			i, checkpoint.Epoch, st.ClaimsSnapshotEpoch)
+		acc.Require(checkpoint.RawBytePower.LessThanEqual(checkpoint.QualityAdjPower),
+			"power checkpoint %d raw power %v is greater than quality adjusted power %v", i, checkpoint.RawBytePower, checkpoint.QualityAdjPower)
+		acc.Require(checkpoint.MinerAboveMinPowerCount <= checkpoint.MinerCount,
+			"power checkpoint %d has %d miners above minimum power of %d", i, checkpoint.MinerAboveMinPowerCount, checkpoint.MinerCount)
+		return nil
+	})
+	acc.RequireNoError(err, "error iterating power checkpoints")
+}
+
 func CheckCronInvariants(st *State, store adt.Store, acc *builtin.MessageAccumulator) CronEventsByAddress {
 	byAddress := make(CronEventsByAddress)
 	queue, err := adt.AsMultimap(store, st.CronEventQueue, CronQueueHamtBitwidth, CronQueueAmtBitwidth)
@@ -167,8 +269,10 @@ func CheckProofValidationInvariants(st *State, store adt.Store, claims ClaimsByA
 				return nil
 			}
 
-			var info proof.SealVerifyInfo
-			err = arr.ForEach(&info, func(i int64) error {
+			var entry ProofValidationEntry
+			err = arr.ForEach(&entry, func(i int64) error {
+				info := entry.SealInfo
+				acc.Require(entry.PriorityFee.GreaterThanEqual(big.Zero()), "miner %v proof has negative priority fee %v", addr, entry.PriorityFee)
 				sectorWindowPoStProofType, err := info.SealProof.RegisteredWindowPoStProof()
 				acc.RequireNoError(err, "failed to get PoSt proof type for seal proof %d", info.SealProof)
 				acc.Require(claim.WindowPoStProofType == sectorWindowPoStProofType, "miner submitted proof with proof type %d different from claim %d",
@@ -179,7 +283,7 @@ func CheckProofValidationInvariants(st *State, store adt.Store, claims ClaimsByA
 			if err != nil {
 				return err
 			}
-			acc.Require(len(proofs[addr]) <= MaxMinerProveCommitsPerEpoch,
+			acc.Require(len(proofs[addr]) <= MaxMinerProveCommitsPending,
 				"miner %v has submitted too many proofs (%d) for batch verification", addr, len(proofs[addr]))
 			return nil
 		})
@@ -0,0 +1,14 @@
+package builtin
+
+import (
+	"github.com/filecoin-project/go-state-types/crypto"
+)
+
+// Domain separation tags for randomness drawn by built-in actors for purposes not covered by go-state-types.
+// The values are offset well beyond the tags defined there so that tags added upstream cannot collide with them.
+const (
+	// Seeds the challenges of a non-interactive proof of replication.
+	DomainSeparationTag_NonInteractiveSealChallengeSeed crypto.DomainSeparationTag = 100 + iota
+	// Seeds the sampling of deals for retrieval audit.
+	DomainSeparationTag_MarketDealAuditSeed
+)
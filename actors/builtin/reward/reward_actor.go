@@ -25,6 +25,8 @@ func (a Actor) Exports() []interface{} {
 		2:                         a.AwardBlockReward,
 		3:                         a.ThisEpochReward,
 		4:                         a.UpdateNetworkKPI,
+		5:                         a.PauseMinting,
+		6:                         a.ResumeMinting,
 	}
 }
 
@@ -117,8 +119,9 @@ func (a Actor) AwardBlockReward(rt runtime.Runtime, params *AwardBlockRewardPara
 
 	// if this fails, we can assume the miner is responsible and avoid failing here.
 	rewardParams := builtin.ApplyRewardParams{
-		Reward:  totalReward,
-		Penalty: penalty,
+		Reward:        totalReward,
+		Penalty:       penalty,
+		VestingSpecID: builtin.VestingSpecBlockReward,
 	}
 	code := rt.Send(minerAddr, builtin.MethodsMiner.ApplyRewards, &rewardParams, totalReward, &builtin.Discard{})
 	if !code.IsSuccess() {
@@ -174,8 +177,46 @@ func (a Actor) UpdateNetworkKPI(rt runtime.Runtime, currRealizedPower *abi.Stora
 		}
 
 		st.updateToNextEpochWithReward(*currRealizedPower)
-		// only update smoothed estimates after updating reward and epoch
-		st.updateSmoothedEstimates(st.Epoch - prev)
+		// only update smoothed estimates after updating reward and epoch.
+		// The estimate is frozen while minting is paused, rather than decaying with the zero reward,
+		// as it prices pledge and penalties for when minting resumes.
+		if !st.MintingPaused() {
+			st.updateSmoothedEstimates(st.Epoch - prev)
+		}
+	})
+	return nil
+}
+
+// Pauses minting of block rewards, as an emergency measure invoked by the system actor.
+// The current epoch's reward is unaffected. From the next epoch, the reward is zero and
+// neither the baseline, the effective network time nor the smoothed reward estimate advance,
+// until minting is resumed.
+func (a Actor) PauseMinting(rt runtime.Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
+	rt.ValidateImmediateCallerIs(builtin.SystemActorAddr)
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		if st.MintingPaused() {
+			rt.Abortf(exitcode.ErrForbidden, "minting already paused since epoch %d", st.MintingPausedEpoch)
+		}
+		st.MintingPausedEpoch = rt.CurrEpoch()
+	})
+	return nil
+}
+
+// Resumes minting of block rewards paused by PauseMinting.
+// From the next epoch, rewards are computed as if the paused epochs had not occurred.
+func (a Actor) ResumeMinting(rt runtime.Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
+	rt.ValidateImmediateCallerIs(builtin.SystemActorAddr)
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		if !st.MintingPaused() {
+			rt.Abortf(exitcode.ErrForbidden, "minting is not paused")
+		}
+		rt.Log(rtt.INFO, "resuming minting paused since epoch %d, %d epochs paused in total",
+			st.MintingPausedEpoch, st.PausedEpochs)
+		st.MintingPausedEpoch = NoMintingPause
 	})
 	return nil
 }
@@ -20,6 +20,9 @@ var InitialRewardPositionEstimate = big.MustFromString(InitialRewardPositionEsti
 // https://www.wolframalpha.com/input/?i=IntegerPart%5B%28Exp%5B-Log%5B2%5D+%2F+%286+*+%281+year+%2F+30+seconds%29%29%5D+-+1%29+*+10%5E18%5D
 var InitialRewardVelocityEstimate = abi.NewTokenAmount(-109897758509)
 
+// Value of MintingPausedEpoch when minting is not paused.
+const NoMintingPause = abi.ChainEpoch(-1)
+
 // Changed since v0:
 // - ThisEpochRewardSmoothed is not a pointer
 type State struct {
@@ -62,6 +65,14 @@ type State struct {
 	// into a code constant in a subsequent upgrade.
 	SimpleTotal   abi.TokenAmount
 	BaselineTotal abi.TokenAmount
+
+	// The epoch at which minting was paused, or NoMintingPause.
+	// While paused, no reward is minted and neither the baseline nor effective network time advance.
+	MintingPausedEpoch abi.ChainEpoch
+	// The number of epochs for which minting has been paused, in total.
+	// Minting resumes from the schedule position at which it was paused, so
+	// the epoch used to compute simple minting is Epoch - PausedEpochs.
+	PausedEpochs abi.ChainEpoch
 }
 
 func ConstructState(currRealizedPower abi.StoragePower) *State {
@@ -80,6 +91,9 @@ func ConstructState(currRealizedPower abi.StoragePower) *State {
 
 		SimpleTotal:   DefaultSimpleTotal,
 		BaselineTotal: DefaultBaselineTotal,
+
+		MintingPausedEpoch: NoMintingPause,
+		PausedEpochs:       0,
 	}
 
 	st.updateToNextEpochWithReward(currRealizedPower)
@@ -91,6 +105,10 @@ func ConstructState(currRealizedPower abi.StoragePower) *State {
 // Used for update of internal state during null rounds
 func (st *State) updateToNextEpoch(currRealizedPower abi.StoragePower) {
 	st.Epoch++
+	if st.MintingPaused() {
+		st.PausedEpochs++
+		return
+	}
 	st.ThisEpochBaselinePower = BaselinePowerFromPrev(st.ThisEpochBaselinePower)
 	cappedRealizedPower := big.Min(st.ThisEpochBaselinePower, currRealizedPower)
 	st.CumsumRealized = big.Add(st.CumsumRealized, cappedRealizedPower)
@@ -105,14 +123,29 @@ func (st *State) updateToNextEpoch(currRealizedPower abi.StoragePower) {
 // Takes in a current realized power for a reward epoch and computes
 // and updates reward state to track reward for the next epoch
 func (st *State) updateToNextEpochWithReward(currRealizedPower abi.StoragePower) {
+	if st.MintingPaused() {
+		st.updateToNextEpoch(currRealizedPower)
+		st.ThisEpochReward = big.Zero()
+		return
+	}
 	prevRewardTheta := ComputeRTheta(st.EffectiveNetworkTime, st.EffectiveBaselinePower, st.CumsumRealized, st.CumsumBaseline)
 	st.updateToNextEpoch(currRealizedPower)
 	currRewardTheta := ComputeRTheta(st.EffectiveNetworkTime, st.EffectiveBaselinePower, st.CumsumRealized, st.CumsumBaseline)
 
-	st.ThisEpochReward = computeReward(st.Epoch, prevRewardTheta, currRewardTheta, st.SimpleTotal, st.BaselineTotal)
+	st.ThisEpochReward = computeReward(st.MintingEpoch(), prevRewardTheta, currRewardTheta, st.SimpleTotal, st.BaselineTotal)
 }
 
 func (st *State) updateSmoothedEstimates(delta abi.ChainEpoch) {
 	filterReward := smoothing.LoadFilter(st.ThisEpochRewardSmoothed, smoothing.DefaultAlpha, smoothing.DefaultBeta)
 	st.ThisEpochRewardSmoothed = filterReward.NextEstimate(st.ThisEpochReward, delta)
 }
+
+// Whether minting is paused.
+func (st *State) MintingPaused() bool {
+	return st.MintingPausedEpoch != NoMintingPause
+}
+
+// The epoch of the minting schedule reached, which excludes epochs for which minting was paused.
+func (st *State) MintingEpoch() abi.ChainEpoch {
+	return st.Epoch - st.PausedEpochs
+}
@@ -252,6 +252,133 @@ func TestSuccessiveKPIUpdates(t *testing.T) {
 
 }
 
+func TestPauseMinting(t *testing.T) {
+	actor := rewardHarness{reward.Actor{}, t}
+	builder := mock.NewBuilder(builtin.RewardActorAddr).
+		WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID).
+		WithBalance(abi.NewTokenAmount(1e18), abi.NewTokenAmount(0))
+	power := abi.NewStoragePower(1 << 50)
+
+	t.Run("paused epochs are excluded from minting", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, &power)
+		reference := builder.Build(t)
+		actor.constructAndVerify(reference, &power)
+		for epoch := abi.ChainEpoch(1); epoch <= 3; epoch++ {
+			rt.SetEpoch(epoch)
+			actor.updateNetworkKPI(rt, &power)
+			reference.SetEpoch(epoch)
+			actor.updateNetworkKPI(reference, &power)
+		}
+		rt.SetEpoch(4)
+		reference.SetEpoch(4)
+		before := getState(rt)
+
+		// The reward for the epoch of the pause is still paid.
+		actor.pauseMinting(rt)
+		assert.True(t, getState(rt).ThisEpochReward.Equals(before.ThisEpochReward))
+		actor.updateNetworkKPI(rt, &power)
+
+		// Nothing accrues while paused, including over null rounds.
+		paused := getState(rt)
+		assert.Equal(t, abi.ChainEpoch(4), paused.MintingPausedEpoch)
+		assert.Equal(t, abi.ChainEpoch(5), paused.Epoch)
+		assert.Equal(t, abi.ChainEpoch(1), paused.PausedEpochs)
+		assert.True(t, paused.ThisEpochReward.IsZero())
+		rt.SetEpoch(7)
+		actor.updateNetworkKPI(rt, &power)
+		paused = getState(rt)
+		assert.Equal(t, abi.ChainEpoch(8), paused.Epoch)
+		assert.Equal(t, abi.ChainEpoch(4), paused.PausedEpochs)
+		assert.Equal(t, before.MintingEpoch(), paused.MintingEpoch())
+		assert.True(t, paused.ThisEpochReward.IsZero())
+		assert.Equal(t, before.EffectiveNetworkTime, paused.EffectiveNetworkTime)
+		assert.True(t, before.CumsumRealized.Equals(paused.CumsumRealized))
+		assert.True(t, before.CumsumBaseline.Equals(paused.CumsumBaseline))
+		assert.True(t, before.ThisEpochBaselinePower.Equals(paused.ThisEpochBaselinePower))
+
+		// Block producers receive only the gas reward.
+		gasReward := abi.NewTokenAmount(10)
+		rt.SetCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+		actor.awardBlockReward(rt, tutil.NewIDAddr(t, 1000), big.Zero(), gasReward, 1, gasReward)
+
+		// After resuming, minting continues where it was paused.
+		rt.SetEpoch(8)
+		actor.resumeMinting(rt)
+		actor.updateNetworkKPI(rt, &power)
+		actor.updateNetworkKPI(reference, &power)
+		resumed := getState(rt)
+		expected := getState(reference)
+		assert.Equal(t, reward.NoMintingPause, resumed.MintingPausedEpoch)
+		assert.Equal(t, abi.ChainEpoch(9), resumed.Epoch)
+		assert.Equal(t, expected.Epoch, resumed.MintingEpoch())
+		assert.True(t, expected.ThisEpochReward.Equals(resumed.ThisEpochReward))
+		assert.Equal(t, expected.EffectiveNetworkTime, resumed.EffectiveNetworkTime)
+		assert.True(t, expected.CumsumRealized.Equals(resumed.CumsumRealized))
+		assert.True(t, expected.CumsumBaseline.Equals(resumed.CumsumBaseline))
+		assert.True(t, expected.ThisEpochBaselinePower.Equals(resumed.ThisEpochBaselinePower))
+	})
+
+	t.Run("smoothed reward estimate is frozen while paused", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, &power)
+		for epoch := abi.ChainEpoch(1); epoch <= 3; epoch++ {
+			rt.SetEpoch(epoch)
+			actor.updateNetworkKPI(rt, &power)
+		}
+		rt.SetEpoch(4)
+		actor.pauseMinting(rt)
+		before := getState(rt).ThisEpochRewardSmoothed
+
+		actor.updateNetworkKPI(rt, &power)
+		assert.Equal(t, before, getState(rt).ThisEpochRewardSmoothed)
+		rt.SetEpoch(7)
+		actor.updateNetworkKPI(rt, &power)
+		assert.Equal(t, before, getState(rt).ThisEpochRewardSmoothed)
+
+		// The estimate resumes updating with minting.
+		rt.SetEpoch(8)
+		actor.resumeMinting(rt)
+		actor.updateNetworkKPI(rt, &power)
+		assert.NotEqual(t, before, getState(rt).ThisEpochRewardSmoothed)
+	})
+
+	t.Run("rejects pausing twice and resuming when not paused", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, &power)
+
+		rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "not paused", func() {
+			rt.Call(actor.ResumeMinting, nil)
+		})
+		rt.Reset()
+
+		actor.pauseMinting(rt)
+		rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "already paused", func() {
+			rt.Call(actor.PauseMinting, nil)
+		})
+		rt.Reset()
+		actor.resumeMinting(rt)
+	})
+
+	t.Run("only the system actor may pause or resume", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, &power)
+
+		rt.SetCaller(tutil.NewIDAddr(t, 1000), builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.PauseMinting, nil)
+		})
+		rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.ResumeMinting, nil)
+		})
+		assert.False(t, getState(rt).MintingPaused())
+	})
+}
+
 type rewardHarness struct {
 	reward.Actor
 	t testing.TB
@@ -273,6 +400,22 @@ func (h *rewardHarness) updateNetworkKPI(rt *mock.Runtime, currRawPower *abi.Sto
 	rt.Verify()
 }
 
+func (h *rewardHarness) pauseMinting(rt *mock.Runtime) {
+	rt.SetCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+	ret := rt.Call(h.PauseMinting, nil)
+	assert.Nil(h.t, ret)
+	rt.Verify()
+}
+
+func (h *rewardHarness) resumeMinting(rt *mock.Runtime) {
+	rt.SetCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
+	ret := rt.Call(h.ResumeMinting, nil)
+	assert.Nil(h.t, ret)
+	rt.Verify()
+}
+
 func (h *rewardHarness) awardBlockReward(rt *mock.Runtime, miner address.Address, penalty, gasReward abi.TokenAmount, winCount int64, expectedPayment abi.TokenAmount) {
 	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
 	// expect penalty multiplier
@@ -21,6 +21,13 @@ func CheckStateInvariants(st *State, store adt.Store, priorEpoch abi.ChainEpoch,
 	acc.Require(st.Epoch == priorEpoch+1, "reward state epoch %d does not match priorEpoch+1 %d", st.Epoch, priorEpoch+1)
 	acc.Require(st.EffectiveNetworkTime <= st.Epoch, "effective network time greater than state epoch")
 
+	acc.Require(st.PausedEpochs >= 0, "paused epochs %d < 0", st.PausedEpochs)
+	acc.Require(st.EffectiveNetworkTime <= st.MintingEpoch(), "effective network time %d greater than minting epoch %d", st.EffectiveNetworkTime, st.MintingEpoch())
+	if st.MintingPaused() {
+		acc.Require(st.MintingPausedEpoch <= st.Epoch, "minting paused at epoch %d after state epoch %d", st.MintingPausedEpoch, st.Epoch)
+		acc.Require(st.ThisEpochReward.IsZero() || st.MintingPausedEpoch >= st.Epoch, "reward %v minted while paused", st.ThisEpochReward)
+	}
+
 	acc.Require(st.CumsumRealized.LessThanEqual(st.CumsumBaseline), "cumsum realized > cumsum baseline")
 	acc.Require(st.CumsumRealized.GreaterThanEqual(big.Zero()), "cumsum realized < 0")
 	acc.Require(st.EffectiveBaselinePower.LessThanEqual(st.ThisEpochBaselinePower), "effective baseline power > baseline power")
@@ -10,9 +10,9 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	builtin0 "github.com/filecoin-project/specs-actors/actors/builtin"
-	builtin2 "github.com/filecoin-project/specs-actors/v2/actors/builtin"
 	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/smoothing"
 )
 
 ///// Code shared by multiple built-in actors. /////
@@ -104,13 +104,31 @@ type MinerAddrs = builtin0.MinerAddrs
 //}
 type DeferredCronEventParams = builtin6.DeferredCronEventParams
 
-//type ConfirmSectorProofsParams struct {
-//	Sectors                 []abi.SectorNumber
-//	RewardSmoothed          smoothing.FilterEstimate
-//	RewardBaselinePower     abi.StoragePower
-//	QualityAdjPowerSmoothed smoothing.FilterEstimate
-//}
-type ConfirmSectorProofsParams = builtin6.ConfirmSectorProofsParams
+// Reports the outcome of verifying a miner's batched seal proofs.
+// Sectors lists the sectors with valid proofs, Failures those with none, and Deferred those yet to be verified.
+type ConfirmSectorProofsParams struct {
+	Sectors                 []abi.SectorNumber
+	RewardSmoothed          smoothing.FilterEstimate
+	RewardBaselinePower     abi.StoragePower
+	QualityAdjPowerSmoothed smoothing.FilterEstimate
+	Failures                []SectorProofFailure
+	// Sectors whose proofs were deferred for verification in the next epoch, the miner having submitted more
+	// than can be verified in one.
+	Deferred []abi.SectorNumber
+}
+
+// A sector whose proof failed verification, with the reason as an exit code.
+type SectorProofFailure struct {
+	SectorNumber abi.SectorNumber
+	Code         exitcode.ExitCode
+}
+
+// Identifies a sector to be terminated for its provider's breach of a deal it holds,
+// as requested of a miner by the market actor on behalf of the deal's client.
+type TerminateBreachedSectorParams struct {
+	SectorNumber abi.SectorNumber
+	DealID       abi.DealID
+}
 
 // ResolveToIDAddr resolves the given address to it's ID address form.
 // If an ID address for the given address dosen't exist yet, it tries to create one by sending a zero balance to the given address.
@@ -136,13 +154,21 @@ func ResolveToIDAddr(rt runtime.Runtime, address addr.Address) (addr.Address, er
 	return idAddr, nil
 }
 
-// Note: we could move this alias back to the mutually-importing packages that use it, now that they
-// can instead both alias the v2 version.
-// type ApplyRewardParams struct {
-// 	Reward  abi.TokenAmount
-// 	Penalty abi.TokenAmount
-// }
-type ApplyRewardParams = builtin2.ApplyRewardParams
+// Identifies the vesting schedule a miner applies to the funds it locks on ApplyRewards.
+type VestingSpecID uint64
+
+const (
+	VestingSpecBlockReward        VestingSpecID = iota // Block and gas rewards earned by a block producer.
+	VestingSpecPledgeContribution                      // Pledge contributions deposited by other parties.
+)
+
+// Changed since v2:
+// - added VestingSpecID
+type ApplyRewardParams struct {
+	Reward        abi.TokenAmount
+	Penalty       abi.TokenAmount
+	VestingSpecID VestingSpecID
+}
 
 // Discard is a helper
 type Discard struct{}
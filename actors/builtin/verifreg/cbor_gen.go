@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"io"
 
+	abi "github.com/filecoin-project/go-state-types/abi"
+	big "github.com/filecoin-project/go-state-types/big"
+	exitcode "github.com/filecoin-project/go-state-types/exitcode"
+	verifreg1 "github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	xerrors "golang.org/x/xerrors"
 )
 
 var _ = xerrors.Errorf
 
-var lengthBufState = []byte{132}
+var lengthBufState = []byte{139}
 
 func (t *State) MarshalCBOR(w io.Writer) error {
 	if t == nil {
@@ -48,6 +52,48 @@ func (t *State) MarshalCBOR(w io.Writer) error {
 		return xerrors.Errorf("failed to write cid field t.RemoveDataCapProposalIDs: %w", err)
 	}
 
+	// t.ClientUsage (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.ClientUsage); err != nil {
+		return xerrors.Errorf("failed to write cid field t.ClientUsage: %w", err)
+	}
+
+	// t.ProviderUsage (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.ProviderUsage); err != nil {
+		return xerrors.Errorf("failed to write cid field t.ProviderUsage: %w", err)
+	}
+
+	// t.Allocations (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.Allocations); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Allocations: %w", err)
+	}
+
+	// t.Claims (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.Claims); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Claims: %w", err)
+	}
+
+	// t.ClientAllocations (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.ClientAllocations); err != nil {
+		return xerrors.Errorf("failed to write cid field t.ClientAllocations: %w", err)
+	}
+
+	// t.SectorClaims (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.SectorClaims); err != nil {
+		return xerrors.Errorf("failed to write cid field t.SectorClaims: %w", err)
+	}
+
+	// t.NextAllocationID (verifreg.AllocationID) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.NextAllocationID)); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -65,7 +111,7 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 4 {
+	if extra != 11 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -113,6 +159,92 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 
 		t.RemoveDataCapProposalIDs = c
 
+	}
+	// t.ClientUsage (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.ClientUsage: %w", err)
+		}
+
+		t.ClientUsage = c
+
+	}
+	// t.ProviderUsage (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.ProviderUsage: %w", err)
+		}
+
+		t.ProviderUsage = c
+
+	}
+	// t.Allocations (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Allocations: %w", err)
+		}
+
+		t.Allocations = c
+
+	}
+	// t.Claims (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Claims: %w", err)
+		}
+
+		t.Claims = c
+
+	}
+	// t.ClientAllocations (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.ClientAllocations: %w", err)
+		}
+
+		t.ClientAllocations = c
+
+	}
+	// t.SectorClaims (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.SectorClaims: %w", err)
+		}
+
+		t.SectorClaims = c
+
+	}
+	// t.NextAllocationID (verifreg.AllocationID) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.NextAllocationID = AllocationID(extra)
+
 	}
 	return nil
 }
@@ -269,31 +401,37 @@ func (t *RemoveDataCapReturn) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-var lengthBufRemoveDataCapRequest = []byte{130}
+var lengthBufRecordActivatedBytesParams = []byte{129}
 
-func (t *RemoveDataCapRequest) MarshalCBOR(w io.Writer) error {
+func (t *RecordActivatedBytesParams) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write(lengthBufRemoveDataCapRequest); err != nil {
+	if _, err := w.Write(lengthBufRecordActivatedBytesParams); err != nil {
 		return err
 	}
 
-	// t.Verifier (address.Address) (struct)
-	if err := t.Verifier.MarshalCBOR(w); err != nil {
-		return err
+	scratch := make([]byte, 9)
+
+	// t.Activations ([]verifreg.ActivatedBytes) (slice)
+	if len(t.Activations) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Activations was too long")
 	}
 
-	// t.VerifierSignature (crypto.Signature) (struct)
-	if err := t.VerifierSignature.MarshalCBOR(w); err != nil {
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Activations))); err != nil {
 		return err
 	}
+	for _, v := range t.Activations {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (t *RemoveDataCapRequest) UnmarshalCBOR(r io.Reader) error {
-	*t = RemoveDataCapRequest{}
+func (t *RecordActivatedBytesParams) UnmarshalCBOR(r io.Reader) error {
+	*t = RecordActivatedBytesParams{}
 
 	br := cbg.GetPeeker(r)
 	scratch := make([]byte, 8)
@@ -306,61 +444,67 @@ func (t *RemoveDataCapRequest) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 2 {
+	if extra != 1 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.Verifier (address.Address) (struct)
+	// t.Activations ([]verifreg.ActivatedBytes) (slice)
 
-	{
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
 
-		if err := t.Verifier.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.Verifier: %w", err)
-		}
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Activations: array too large (%d)", extra)
+	}
 
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
 	}
-	// t.VerifierSignature (crypto.Signature) (struct)
 
-	{
+	if extra > 0 {
+		t.Activations = make([]ActivatedBytes, extra)
+	}
 
-		if err := t.VerifierSignature.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.VerifierSignature: %w", err)
+	for i := 0; i < int(extra); i++ {
+
+		var v ActivatedBytes
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
 		}
 
+		t.Activations[i] = v
 	}
+
 	return nil
 }
 
-var lengthBufRemoveDataCapProposal = []byte{131}
+var lengthBufDataCapUsageReturn = []byte{130}
 
-func (t *RemoveDataCapProposal) MarshalCBOR(w io.Writer) error {
+func (t *DataCapUsageReturn) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write(lengthBufRemoveDataCapProposal); err != nil {
-		return err
-	}
-
-	// t.VerifiedClient (address.Address) (struct)
-	if err := t.VerifiedClient.MarshalCBOR(w); err != nil {
+	if _, err := w.Write(lengthBufDataCapUsageReturn); err != nil {
 		return err
 	}
 
-	// t.DataCapAmount (big.Int) (struct)
-	if err := t.DataCapAmount.MarshalCBOR(w); err != nil {
+	// t.ClientUsage (big.Int) (struct)
+	if err := t.ClientUsage.MarshalCBOR(w); err != nil {
 		return err
 	}
 
-	// t.RemovalProposalID (verifreg.RmDcProposalID) (struct)
-	if err := t.RemovalProposalID.MarshalCBOR(w); err != nil {
+	// t.ProviderUsage (big.Int) (struct)
+	if err := t.ProviderUsage.MarshalCBOR(w); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (t *RemoveDataCapProposal) UnmarshalCBOR(r io.Reader) error {
-	*t = RemoveDataCapProposal{}
+func (t *DataCapUsageReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = DataCapUsageReturn{}
 
 	br := cbg.GetPeeker(r)
 	scratch := make([]byte, 8)
@@ -373,64 +517,141 @@ func (t *RemoveDataCapProposal) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 3 {
+	if extra != 2 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.VerifiedClient (address.Address) (struct)
+	// t.ClientUsage (big.Int) (struct)
 
 	{
 
-		if err := t.VerifiedClient.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.VerifiedClient: %w", err)
+		if err := t.ClientUsage.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.ClientUsage: %w", err)
 		}
 
 	}
-	// t.DataCapAmount (big.Int) (struct)
+	// t.ProviderUsage (big.Int) (struct)
 
 	{
 
-		if err := t.DataCapAmount.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.DataCapAmount: %w", err)
+		if err := t.ProviderUsage.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.ProviderUsage: %w", err)
 		}
 
 	}
-	// t.RemovalProposalID (verifreg.RmDcProposalID) (struct)
+	return nil
+}
 
-	{
+var lengthBufRestoreBytesBatchParams = []byte{129}
 
-		if err := t.RemovalProposalID.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.RemovalProposalID: %w", err)
+func (t *RestoreBytesBatchParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRestoreBytesBatchParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Restorations ([]verifreg.RestoreBytesParams) (slice)
+	if len(t.Restorations) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Restorations was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Restorations))); err != nil {
+		return err
+	}
+	for _, v := range t.Restorations {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *RestoreBytesBatchParams) UnmarshalCBOR(r io.Reader) error {
+	*t = RestoreBytesBatchParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Restorations ([]verifreg.RestoreBytesParams) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Restorations: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Restorations = make([]verifreg1.RestoreBytesParams, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v verifreg1.RestoreBytesParams
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
 		}
 
+		t.Restorations[i] = v
 	}
+
 	return nil
 }
 
-var lengthBufRmDcProposalID = []byte{129}
+var lengthBufRestoreBytesBatchReturn = []byte{129}
 
-func (t *RmDcProposalID) MarshalCBOR(w io.Writer) error {
+func (t *RestoreBytesBatchReturn) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write(lengthBufRmDcProposalID); err != nil {
+	if _, err := w.Write(lengthBufRestoreBytesBatchReturn); err != nil {
 		return err
 	}
 
 	scratch := make([]byte, 9)
 
-	// t.ProposalID (uint64) (uint64)
+	// t.Failures ([]verifreg.RestoreBytesFailure) (slice)
+	if len(t.Failures) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Failures was too long")
+	}
 
-	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ProposalID)); err != nil {
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Failures))); err != nil {
 		return err
 	}
-
+	for _, v := range t.Failures {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (t *RmDcProposalID) UnmarshalCBOR(r io.Reader) error {
-	*t = RmDcProposalID{}
+func (t *RestoreBytesBatchReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = RestoreBytesBatchReturn{}
 
 	br := cbg.GetPeeker(r)
 	scratch := make([]byte, 8)
@@ -447,19 +668,1421 @@ func (t *RmDcProposalID) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.ProposalID (uint64) (uint64)
+	// t.Failures ([]verifreg.RestoreBytesFailure) (slice)
 
-	{
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
 
-		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
-		if err != nil {
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Failures: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Failures = make([]RestoreBytesFailure, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v RestoreBytesFailure
+		if err := v.UnmarshalCBOR(br); err != nil {
 			return err
 		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.ProposalID = uint64(extra)
 
+		t.Failures[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufVerifySectorClaimsParams = []byte{129}
+
+func (t *VerifySectorClaimsParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufVerifySectorClaimsParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Sectors ([]verifreg.SectorClaims) (slice)
+	if len(t.Sectors) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Sectors was too long")
 	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Sectors))); err != nil {
+		return err
+	}
+	for _, v := range t.Sectors {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *VerifySectorClaimsParams) UnmarshalCBOR(r io.Reader) error {
+	*t = VerifySectorClaimsParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Sectors ([]verifreg.SectorClaims) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Sectors: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Sectors = make([]SectorClaims, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v SectorClaims
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.Sectors[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufVerifySectorClaimsReturn = []byte{129}
+
+func (t *VerifySectorClaimsReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufVerifySectorClaimsReturn); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.VerifiedSpace ([]big.Int) (slice)
+	if len(t.VerifiedSpace) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.VerifiedSpace was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.VerifiedSpace))); err != nil {
+		return err
+	}
+	for _, v := range t.VerifiedSpace {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *VerifySectorClaimsReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = VerifySectorClaimsReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.VerifiedSpace ([]big.Int) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.VerifiedSpace: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.VerifiedSpace = make([]big.Int, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v big.Int
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.VerifiedSpace[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufCreateAllocationParams = []byte{132}
+
+func (t *CreateAllocationParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufCreateAllocationParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Client (address.Address) (struct)
+	if err := t.Client.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Provider (address.Address) (struct)
+	if err := t.Provider.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Size (big.Int) (struct)
+	if err := t.Size.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Expiration (abi.ChainEpoch) (int64)
+	if t.Expiration >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Expiration)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Expiration-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *CreateAllocationParams) UnmarshalCBOR(r io.Reader) error {
+	*t = CreateAllocationParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 4 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Client (address.Address) (struct)
+
+	{
+
+		if err := t.Client.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Client: %w", err)
+		}
+
+	}
+	// t.Provider (address.Address) (struct)
+
+	{
+
+		if err := t.Provider.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Provider: %w", err)
+		}
+
+	}
+	// t.Size (big.Int) (struct)
+
+	{
+
+		if err := t.Size.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Size: %w", err)
+		}
+
+	}
+	// t.Expiration (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Expiration = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufCreateAllocationReturn = []byte{129}
+
+func (t *CreateAllocationReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufCreateAllocationReturn); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.AllocationID (verifreg.AllocationID) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.AllocationID)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *CreateAllocationReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = CreateAllocationReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.AllocationID (verifreg.AllocationID) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.AllocationID = AllocationID(extra)
+
+	}
+	return nil
+}
+
+var lengthBufClaimAllocationsParams = []byte{131}
+
+func (t *ClaimAllocationsParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufClaimAllocationsParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Provider (address.Address) (struct)
+	if err := t.Provider.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Sector (abi.SectorNumber) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Sector)); err != nil {
+		return err
+	}
+
+	// t.AllocationIDs ([]verifreg.AllocationID) (slice)
+	if len(t.AllocationIDs) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.AllocationIDs was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.AllocationIDs))); err != nil {
+		return err
+	}
+	for _, v := range t.AllocationIDs {
+		if err := cbg.CborWriteHeader(w, cbg.MajUnsignedInt, uint64(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *ClaimAllocationsParams) UnmarshalCBOR(r io.Reader) error {
+	*t = ClaimAllocationsParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Provider (address.Address) (struct)
+
+	{
+
+		if err := t.Provider.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Provider: %w", err)
+		}
+
+	}
+	// t.Sector (abi.SectorNumber) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Sector = abi.SectorNumber(extra)
+
+	}
+	// t.AllocationIDs ([]verifreg.AllocationID) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.AllocationIDs: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.AllocationIDs = make([]AllocationID, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		maj, val, err := cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return xerrors.Errorf("failed to read uint64 for t.AllocationIDs slice: %w", err)
+		}
+
+		if maj != cbg.MajUnsignedInt {
+			return xerrors.Errorf("value read for array t.AllocationIDs was not a uint, instead got %d", maj)
+		}
+
+		t.AllocationIDs[i] = AllocationID(val)
+	}
+
+	return nil
+}
+
+var lengthBufRemoveExpiredAllocationsParams = []byte{129}
+
+func (t *RemoveExpiredAllocationsParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveExpiredAllocationsParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.AllocationIDs ([]verifreg.AllocationID) (slice)
+	if len(t.AllocationIDs) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.AllocationIDs was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.AllocationIDs))); err != nil {
+		return err
+	}
+	for _, v := range t.AllocationIDs {
+		if err := cbg.CborWriteHeader(w, cbg.MajUnsignedInt, uint64(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *RemoveExpiredAllocationsParams) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveExpiredAllocationsParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.AllocationIDs ([]verifreg.AllocationID) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.AllocationIDs: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.AllocationIDs = make([]AllocationID, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		maj, val, err := cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return xerrors.Errorf("failed to read uint64 for t.AllocationIDs slice: %w", err)
+		}
+
+		if maj != cbg.MajUnsignedInt {
+			return xerrors.Errorf("value read for array t.AllocationIDs was not a uint, instead got %d", maj)
+		}
+
+		t.AllocationIDs[i] = AllocationID(val)
+	}
+
+	return nil
+}
+
+var lengthBufRemoveExpiredAllocationsReturn = []byte{129}
+
+func (t *RemoveExpiredAllocationsReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveExpiredAllocationsReturn); err != nil {
+		return err
+	}
+
+	// t.DataCapRecovered (big.Int) (struct)
+	if err := t.DataCapRecovered.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveExpiredAllocationsReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveExpiredAllocationsReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.DataCapRecovered (big.Int) (struct)
+
+	{
+
+		if err := t.DataCapRecovered.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DataCapRecovered: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRemoveSectorClaimsParams = []byte{129}
+
+func (t *RemoveSectorClaimsParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveSectorClaimsParams); err != nil {
+		return err
+	}
+
+	// t.Sectors (bitfield.BitField) (struct)
+	if err := t.Sectors.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveSectorClaimsParams) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveSectorClaimsParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Sectors (bitfield.BitField) (struct)
+
+	{
+
+		if err := t.Sectors.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Sectors: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufGetClientAllocationsReturn = []byte{129}
+
+func (t *GetClientAllocationsReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufGetClientAllocationsReturn); err != nil {
+		return err
+	}
+
+	// t.AllocationIDs (bitfield.BitField) (struct)
+	if err := t.AllocationIDs.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *GetClientAllocationsReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = GetClientAllocationsReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.AllocationIDs (bitfield.BitField) (struct)
+
+	{
+
+		if err := t.AllocationIDs.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.AllocationIDs: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRemoveDataCapRequest = []byte{130}
+
+func (t *RemoveDataCapRequest) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveDataCapRequest); err != nil {
+		return err
+	}
+
+	// t.Verifier (address.Address) (struct)
+	if err := t.Verifier.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.VerifierSignature (crypto.Signature) (struct)
+	if err := t.VerifierSignature.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveDataCapRequest) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveDataCapRequest{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Verifier (address.Address) (struct)
+
+	{
+
+		if err := t.Verifier.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Verifier: %w", err)
+		}
+
+	}
+	// t.VerifierSignature (crypto.Signature) (struct)
+
+	{
+
+		if err := t.VerifierSignature.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifierSignature: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRemoveDataCapProposal = []byte{131}
+
+func (t *RemoveDataCapProposal) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveDataCapProposal); err != nil {
+		return err
+	}
+
+	// t.VerifiedClient (address.Address) (struct)
+	if err := t.VerifiedClient.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.DataCapAmount (big.Int) (struct)
+	if err := t.DataCapAmount.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.RemovalProposalID (verifreg.RmDcProposalID) (struct)
+	if err := t.RemovalProposalID.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveDataCapProposal) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveDataCapProposal{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.VerifiedClient (address.Address) (struct)
+
+	{
+
+		if err := t.VerifiedClient.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifiedClient: %w", err)
+		}
+
+	}
+	// t.DataCapAmount (big.Int) (struct)
+
+	{
+
+		if err := t.DataCapAmount.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DataCapAmount: %w", err)
+		}
+
+	}
+	// t.RemovalProposalID (verifreg.RmDcProposalID) (struct)
+
+	{
+
+		if err := t.RemovalProposalID.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RemovalProposalID: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRmDcProposalID = []byte{129}
+
+func (t *RmDcProposalID) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRmDcProposalID); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.ProposalID (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ProposalID)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *RmDcProposalID) UnmarshalCBOR(r io.Reader) error {
+	*t = RmDcProposalID{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.ProposalID (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.ProposalID = uint64(extra)
+
+	}
+	return nil
+}
+
+var lengthBufActivatedBytes = []byte{131}
+
+func (t *ActivatedBytes) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufActivatedBytes); err != nil {
+		return err
+	}
+
+	// t.Client (address.Address) (struct)
+	if err := t.Client.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Provider (address.Address) (struct)
+	if err := t.Provider.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.DealSize (big.Int) (struct)
+	if err := t.DealSize.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *ActivatedBytes) UnmarshalCBOR(r io.Reader) error {
+	*t = ActivatedBytes{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Client (address.Address) (struct)
+
+	{
+
+		if err := t.Client.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Client: %w", err)
+		}
+
+	}
+	// t.Provider (address.Address) (struct)
+
+	{
+
+		if err := t.Provider.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Provider: %w", err)
+		}
+
+	}
+	// t.DealSize (big.Int) (struct)
+
+	{
+
+		if err := t.DealSize.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DealSize: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRestoreBytesFailure = []byte{130}
+
+func (t *RestoreBytesFailure) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRestoreBytesFailure); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Index (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Index)); err != nil {
+		return err
+	}
+
+	// t.Code (exitcode.ExitCode) (int64)
+	if t.Code >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Code)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Code-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *RestoreBytesFailure) UnmarshalCBOR(r io.Reader) error {
+	*t = RestoreBytesFailure{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Index (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Index = uint64(extra)
+
+	}
+	// t.Code (exitcode.ExitCode) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Code = exitcode.ExitCode(extraI)
+	}
+	return nil
+}
+
+var lengthBufAllocation = []byte{132}
+
+func (t *Allocation) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufAllocation); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Client (address.Address) (struct)
+	if err := t.Client.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Provider (address.Address) (struct)
+	if err := t.Provider.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Size (big.Int) (struct)
+	if err := t.Size.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Expiration (abi.ChainEpoch) (int64)
+	if t.Expiration >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Expiration)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Expiration-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Allocation) UnmarshalCBOR(r io.Reader) error {
+	*t = Allocation{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 4 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Client (address.Address) (struct)
+
+	{
+
+		if err := t.Client.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Client: %w", err)
+		}
+
+	}
+	// t.Provider (address.Address) (struct)
+
+	{
+
+		if err := t.Provider.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Provider: %w", err)
+		}
+
+	}
+	// t.Size (big.Int) (struct)
+
+	{
+
+		if err := t.Size.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Size: %w", err)
+		}
+
+	}
+	// t.Expiration (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Expiration = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufClaim = []byte{133}
+
+func (t *Claim) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufClaim); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Client (address.Address) (struct)
+	if err := t.Client.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Provider (address.Address) (struct)
+	if err := t.Provider.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Size (big.Int) (struct)
+	if err := t.Size.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Sector (abi.SectorNumber) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Sector)); err != nil {
+		return err
+	}
+
+	// t.ClaimEpoch (abi.ChainEpoch) (int64)
+	if t.ClaimEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ClaimEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.ClaimEpoch-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Claim) UnmarshalCBOR(r io.Reader) error {
+	*t = Claim{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 5 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Client (address.Address) (struct)
+
+	{
+
+		if err := t.Client.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Client: %w", err)
+		}
+
+	}
+	// t.Provider (address.Address) (struct)
+
+	{
+
+		if err := t.Provider.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Provider: %w", err)
+		}
+
+	}
+	// t.Size (big.Int) (struct)
+
+	{
+
+		if err := t.Size.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Size: %w", err)
+		}
+
+	}
+	// t.Sector (abi.SectorNumber) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Sector = abi.SectorNumber(extra)
+
+	}
+	// t.ClaimEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.ClaimEpoch = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufSectorClaims = []byte{130}
+
+func (t *SectorClaims) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufSectorClaims); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.SectorNumber (abi.SectorNumber) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.SectorNumber)); err != nil {
+		return err
+	}
+
+	// t.ClaimIDs ([]verifreg.AllocationID) (slice)
+	if len(t.ClaimIDs) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.ClaimIDs was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.ClaimIDs))); err != nil {
+		return err
+	}
+	for _, v := range t.ClaimIDs {
+		if err := cbg.CborWriteHeader(w, cbg.MajUnsignedInt, uint64(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *SectorClaims) UnmarshalCBOR(r io.Reader) error {
+	*t = SectorClaims{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.SectorNumber (abi.SectorNumber) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.SectorNumber = abi.SectorNumber(extra)
+
+	}
+	// t.ClaimIDs ([]verifreg.AllocationID) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.ClaimIDs: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.ClaimIDs = make([]AllocationID, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		maj, val, err := cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return xerrors.Errorf("failed to read uint64 for t.ClaimIDs slice: %w", err)
+		}
+
+		if maj != cbg.MajUnsignedInt {
+			return xerrors.Errorf("value read for array t.ClaimIDs was not a uint, instead got %d", maj)
+		}
+
+		t.ClaimIDs[i] = AllocationID(val)
+	}
+
 	return nil
 }
@@ -2,8 +2,10 @@ package verifreg
 
 import (
 	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	cid "github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
@@ -64,8 +66,123 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 	}
 	// No need to iterate all clients; any overlap must have been one of all verifiers.
 
+	// Check usage. Each activated deal adds the same bytes to its client's and its provider's usage.
+	clientUsage := checkUsage(st.ClientUsage, store, "client", acc)
+	providerUsage := checkUsage(st.ProviderUsage, store, "provider", acc)
+	acc.Require(clientUsage.Equals(providerUsage), "total client usage %v does not match total provider usage %v",
+		clientUsage, providerUsage)
+
+	// Check allocations and claims. An allocation is removed when it is claimed, so no ID is in both.
+	allocationClients := map[AllocationID]addr.Address{}
+	if allocations, err := adt.AsMap(store, st.Allocations, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading allocations: %v", err)
+	} else {
+		var allocation Allocation
+		err = allocations.ForEach(&allocation, func(key string) error {
+			id, err := abi.ParseUIntKey(key)
+			if err != nil {
+				return err
+			}
+			acc.Require(AllocationID(id) < st.NextAllocationID, "allocation %d not less than next allocation ID %d", id, st.NextAllocationID)
+			acc.Require(allocation.Client.Protocol() == addr.ID, "allocation %d client %v should have ID protocol", id, allocation.Client)
+			acc.Require(allocation.Provider.Protocol() == addr.ID, "allocation %d provider %v should have ID protocol", id, allocation.Provider)
+			acc.Require(allocation.Size.GreaterThanEqual(MinVerifiedDealSize), "allocation %d size %v below minimum", id, allocation.Size)
+			allocationClients[AllocationID(id)] = allocation.Client
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating allocations")
+	}
+	claimSectors := map[AllocationID]SectorKey{}
+	if claims, err := adt.AsMap(store, st.Claims, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading claims: %v", err)
+	} else {
+		var claim Claim
+		err = claims.ForEach(&claim, func(key string) error {
+			id, err := abi.ParseUIntKey(key)
+			if err != nil {
+				return err
+			}
+			acc.Require(AllocationID(id) < st.NextAllocationID, "claim %d not less than next allocation ID %d", id, st.NextAllocationID)
+			_, allocated := allocationClients[AllocationID(id)]
+			acc.Require(!allocated, "claim %d is also an allocation", id)
+			acc.Require(claim.Client.Protocol() == addr.ID, "claim %d client %v should have ID protocol", id, claim.Client)
+			acc.Require(claim.Provider.Protocol() == addr.ID, "claim %d provider %v should have ID protocol", id, claim.Provider)
+			acc.Require(claim.Size.GreaterThanEqual(MinVerifiedDealSize), "claim %d size %v below minimum", id, claim.Size)
+			claimSectors[AllocationID(id)] = SectorKey{Provider: claim.Provider, Sector: claim.Sector}
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating claims")
+	}
+
+	// Check the allocations indexed by client and the claims indexed by sector match those they index.
+	indexed := 0
+	checkIndex(st.ClientAllocations, store, "client allocations", acc, func(key string, id AllocationID) {
+		client, err := addr.NewFromBytes([]byte(key))
+		acc.RequireNoError(err, "error parsing client allocations key")
+		allocationClient, found := allocationClients[id]
+		acc.Require(found, "client %v indexes missing allocation %d", client, id)
+		acc.Require(!found || allocationClient == client, "client %v indexes allocation %d of client %v", client, id, allocationClient)
+		indexed++
+	})
+	acc.Require(indexed == len(allocationClients), "%d allocations indexed by client, expected %d", indexed, len(allocationClients))
+	indexed = 0
+	checkIndex(st.SectorClaims, store, "sector claims", acc, func(key string, id AllocationID) {
+		sector, err := ParseSectorKey(key)
+		acc.RequireNoError(err, "error parsing sector claims key")
+		claimSector, found := claimSectors[id]
+		acc.Require(found, "sector %v indexes missing claim %d", sector, id)
+		acc.Require(!found || claimSector == sector, "sector %v indexes claim %d of sector %v", sector, id, claimSector)
+		indexed++
+	})
+	acc.Require(indexed == len(claimSectors), "%d claims indexed by sector, expected %d", indexed, len(claimSectors))
+
 	return &StateSummary{
 		Verifiers: allVerifiers,
 		Clients:   allClients,
 	}, acc
 }
+
+// Checks the entries of a usage map, returning their total.
+func checkUsage(root cid.Cid, store adt.Store, role string, acc *builtin.MessageAccumulator) DataCap {
+	total := big.Zero()
+	usage, err := adt.AsMap(store, root, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		acc.Addf("error loading %s usage: %v", role, err)
+		return total
+	}
+	var used DataCap
+	err = usage.ForEach(&used, func(key string) error {
+		a, err := addr.NewFromBytes([]byte(key))
+		if err != nil {
+			return err
+		}
+		acc.Require(a.Protocol() == addr.ID, "%s %v should have ID protocol", role, a)
+		acc.Require(used.GreaterThan(big.Zero()), "%s %v usage %v is not positive", role, a, used)
+		total = big.Add(total, used)
+		return nil
+	})
+	acc.RequireNoError(err, "error iterating %s usage", role)
+	return total
+}
+
+// Checks the entries of an index of allocation IDs, calling visit for each ID indexed.
+func checkIndex(root cid.Cid, store adt.Store, name string, acc *builtin.MessageAccumulator, visit func(key string, id AllocationID)) {
+	index, err := adt.AsMap(store, root, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		acc.Addf("error loading %s: %v", name, err)
+		return
+	}
+	var ids bitfield.BitField
+	err = index.ForEach(&ids, func(key string) error {
+		count, err := ids.Count()
+		if err != nil {
+			return err
+		}
+		acc.Require(count > 0, "%s entry %x is empty", name, key)
+		return ids.ForEach(func(id uint64) error {
+			visit(key, AllocationID(id))
+			return nil
+		})
+	})
+	acc.RequireNoError(err, "error iterating %s", name)
+}
@@ -1,13 +1,18 @@
 package verifreg
 
 import (
+	"sort"
+
 	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/cbor"
 	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
+	rtt "github.com/filecoin-project/go-state-types/rt"
 	verifreg0 "github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
@@ -22,9 +27,18 @@ func (a Actor) Exports() []interface{} {
 		2:                         a.AddVerifier,
 		3:                         a.RemoveVerifier,
 		4:                         a.AddVerifiedClient,
-		5:                         a.UseBytes,
+		5:                         nil, // deprecated
 		6:                         a.RestoreBytes,
 		7:                         a.RemoveVerifiedClientDataCap,
+		8:                         a.RecordActivatedBytes,
+		9:                         a.DataCapUsage,
+		10:                        a.RestoreBytesBatch,
+		11:                        a.VerifySectorClaims,
+		12:                        a.CreateAllocation,
+		13:                        a.ClaimAllocations,
+		14:                        a.RemoveExpiredAllocations,
+		15:                        a.RemoveSectorClaims,
+		16:                        a.GetClientAllocations,
 	}
 }
 
@@ -206,63 +220,6 @@ func (a Actor) AddVerifiedClient(rt runtime.Runtime, params *AddVerifiedClientPa
 	return nil
 }
 
-//type UseBytesParams struct {
-//	Address  addr.Address     // Address of verified client.
-//	DealSize abi.StoragePower // Number of bytes to use.
-//}
-type UseBytesParams = verifreg0.UseBytesParams
-
-// Called by StorageMarketActor during PublishStorageDeals.
-// Do not allow partially verified deals (DealSize must be greater than equal to allowed cap).
-// Delete VerifiedClient if remaining DataCap is smaller than minimum VerifiedDealSize.
-func (a Actor) UseBytes(rt runtime.Runtime, params *UseBytesParams) *abi.EmptyValue {
-	rt.ValidateImmediateCallerIs(builtin.StorageMarketActorAddr)
-
-	client, err := builtin.ResolveToIDAddr(rt, params.Address)
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve verified client address %v", params.Address)
-
-	if params.DealSize.LessThan(MinVerifiedDealSize) {
-		rt.Abortf(exitcode.ErrIllegalArgument, "VerifiedDealSize: %d below minimum in UseBytes", params.DealSize)
-	}
-
-	var st State
-	rt.StateTransaction(&st, func() {
-		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
-
-		var vcCap DataCap
-		found, err := verifiedClients.Get(abi.AddrKey(client), &vcCap)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client %v", client)
-		if !found {
-			rt.Abortf(exitcode.ErrNotFound, "no such verified client %v", client)
-		}
-		builtin.RequireState(rt, vcCap.GreaterThanEqual(big.Zero()), "negative cap for client %v: %v", client, vcCap)
-
-		if params.DealSize.GreaterThan(vcCap) {
-			rt.Abortf(exitcode.ErrIllegalArgument, "DealSize %d exceeds allowable cap: %d for VerifiedClient %v", params.DealSize, vcCap, client)
-		}
-
-		newVcCap := big.Sub(vcCap, params.DealSize)
-		if newVcCap.LessThan(MinVerifiedDealSize) {
-			// Delete entry if remaining DataCap is less than MinVerifiedDealSize.
-			// Will be restored later if the deal did not get activated with a ProvenSector.
-			//
-			// NOTE: Technically, client could lose up to MinVerifiedDealSize worth of DataCap.
-			// See: https://github.com/filecoin-project/specs-actors/issues/727
-			err = verifiedClients.Delete(abi.AddrKey(client))
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete verified client %v", client)
-		} else {
-			err = verifiedClients.Put(abi.AddrKey(client), &newVcCap)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update verified client %v with %v", client, newVcCap)
-		}
-
-		st.VerifiedClients, err = verifiedClients.Root()
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verified clients")
-	})
-
-	return nil
-}
-
 //type RestoreBytesParams struct {
 //	Address  addr.Address
 //	DealSize abi.StoragePower
@@ -404,3 +361,549 @@ func (a Actor) RemoveVerifiedClientDataCap(rt runtime.Runtime, params *RemoveDat
 		DataCapRemoved: removedDataCapAmount,
 	}
 }
+
+// Verified bytes in a deal activated by a provider for a client. Both addresses must be ID addresses.
+type ActivatedBytes struct {
+	Client   addr.Address
+	Provider addr.Address
+	DealSize DataCap
+}
+
+type RecordActivatedBytesParams struct {
+	Activations []ActivatedBytes
+}
+
+// Called by StorageMarketActor when verified deals are activated, to accumulate the verified bytes used
+// by each client and each provider.
+func (a Actor) RecordActivatedBytes(rt runtime.Runtime, params *RecordActivatedBytesParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerIs(builtin.StorageMarketActorAddr)
+
+	for _, activation := range params.Activations {
+		if activation.Client.Protocol() != addr.ID || activation.Provider.Protocol() != addr.ID {
+			rt.Abortf(exitcode.ErrIllegalArgument, "client %v and provider %v must be ID addresses", activation.Client, activation.Provider)
+		}
+		if activation.DealSize.LessThan(MinVerifiedDealSize) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deal size %v below minimum %v", activation.DealSize, MinVerifiedDealSize)
+		}
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		clientUsage, err := adt.AsMap(adt.AsStore(rt), st.ClientUsage, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client usage")
+
+		providerUsage, err := adt.AsMap(adt.AsStore(rt), st.ProviderUsage, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load provider usage")
+
+		for _, activation := range params.Activations {
+			err = addUsage(clientUsage, activation.Client, activation.DealSize)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record usage for client %v", activation.Client)
+
+			err = addUsage(providerUsage, activation.Provider, activation.DealSize)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record usage for provider %v", activation.Provider)
+		}
+
+		st.ClientUsage, err = clientUsage.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client usage")
+
+		st.ProviderUsage, err = providerUsage.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush provider usage")
+	})
+
+	return nil
+}
+
+type DataCapUsageReturn struct {
+	// Verified bytes in deals activated for the address as a client.
+	ClientUsage DataCap
+	// Verified bytes in deals activated by the address as a provider.
+	ProviderUsage DataCap
+}
+
+// Returns the verified bytes in activated deals of a client or provider.
+// An address that cannot be resolved has no usage.
+func (a Actor) DataCapUsage(rt runtime.Runtime, address *addr.Address) *DataCapUsageReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	idAddr, ok := rt.ResolveAddress(*address)
+	if !ok {
+		return &DataCapUsageReturn{ClientUsage: big.Zero(), ProviderUsage: big.Zero()}
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	clientUsage, providerUsage, err := st.GetDataCapUsage(adt.AsStore(rt), idAddr)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get usage for %v", idAddr)
+	return &DataCapUsageReturn{ClientUsage: clientUsage, ProviderUsage: providerUsage}
+}
+
+type RestoreBytesBatchParams struct {
+	Restorations []RestoreBytesParams
+}
+
+type RestoreBytesFailure struct {
+	// Index of the restoration in the batch.
+	Index uint64
+	Code  exitcode.ExitCode
+}
+
+type RestoreBytesBatchReturn struct {
+	// Restorations that were rejected, in order of index. All others were applied.
+	Failures []RestoreBytesFailure
+}
+
+// Called by StorageMarketActor from cron to restore the allowable cap of the clients of verified deals
+// that failed to activate, as RestoreBytes does for a single deal.
+// A restoration that RestoreBytes would reject is skipped and reported, without affecting the others.
+func (a Actor) RestoreBytesBatch(rt runtime.Runtime, params *RestoreBytesBatchParams) *RestoreBytesBatchReturn {
+	rt.ValidateImmediateCallerIs(builtin.StorageMarketActorAddr)
+
+	var failures []RestoreBytesFailure
+	fail := func(i int, code exitcode.ExitCode, format string, args ...interface{}) {
+		rt.Log(rtt.INFO, "rejected restoration %d: "+format, append([]interface{}{i}, args...)...)
+		failures = append(failures, RestoreBytesFailure{Index: uint64(i), Code: code})
+	}
+
+	// Resolve clients before the transaction, since resolution may send to create an account.
+	clients := make([]addr.Address, len(params.Restorations))
+	valid := make([]bool, len(params.Restorations))
+	for i, restoration := range params.Restorations {
+		if restoration.DealSize.LessThan(MinVerifiedDealSize) {
+			fail(i, exitcode.ErrIllegalArgument, "deal size %v below minimum %v", restoration.DealSize, MinVerifiedDealSize)
+			continue
+		}
+		client, err := builtin.ResolveToIDAddr(rt, restoration.Address)
+		if err != nil {
+			fail(i, exitcode.ErrIllegalState, "failed to resolve client address %v: %s", restoration.Address, err)
+			continue
+		}
+		clients[i] = client
+		valid[i] = true
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
+
+		verifiers, err := adt.AsMap(adt.AsStore(rt), st.Verifiers, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verifiers")
+
+		for i, restoration := range params.Restorations {
+			if !valid[i] {
+				continue
+			}
+			client := clients[i]
+			if client == st.RootKey {
+				fail(i, exitcode.ErrIllegalArgument, "cannot restore allowance for root key")
+				continue
+			}
+			isVerifier, err := verifiers.Get(abi.AddrKey(client), nil)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier %v", client)
+			if isVerifier {
+				fail(i, exitcode.ErrIllegalArgument, "cannot restore allowance for verifier %v", client)
+				continue
+			}
+
+			var vcCap DataCap
+			found, err := verifiedClients.Get(abi.AddrKey(client), &vcCap)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client %v", client)
+			if !found {
+				vcCap = big.Zero()
+			}
+
+			newVcCap := big.Add(vcCap, restoration.DealSize)
+			err = verifiedClients.Put(abi.AddrKey(client), &newVcCap)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put verified client %v with %v", client, newVcCap)
+		}
+
+		st.VerifiedClients, err = verifiedClients.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verified clients")
+	})
+
+	// Failures found in the transaction follow those found before it, so restore index order.
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+	return &RestoreBytesBatchReturn{Failures: failures}
+}
+
+type VerifySectorClaimsParams struct {
+	Sectors []SectorClaims
+}
+
+// The claims asserted to be bound to a sector.
+type SectorClaims struct {
+	SectorNumber abi.SectorNumber
+	ClaimIDs     []AllocationID
+}
+
+type VerifySectorClaimsReturn struct {
+	// The verified space of each sector's claims, in the order of the sectors in the parameters.
+	VerifiedSpace []DataCap
+}
+
+// Called by a miner to establish the verified space of its sectors from the claims bound to them.
+// Every claim must have been made by the calling provider for the sector to which it is asserted, and may
+// be asserted only once, so verified space cannot be counted in more than the one sector holding its data.
+func (a Actor) VerifySectorClaims(rt runtime.Runtime, params *VerifySectorClaimsParams) *VerifySectorClaimsReturn {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	provider := rt.Caller()
+
+	var st State
+	rt.StateReadonly(&st)
+	claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
+
+	seen := map[AllocationID]struct{}{}
+	verifiedSpace := make([]DataCap, len(params.Sectors))
+	for i, sector := range params.Sectors {
+		space := big.Zero()
+		for _, id := range sector.ClaimIDs {
+			if _, ok := seen[id]; ok {
+				rt.Abortf(exitcode.ErrIllegalArgument, "claim %d asserted more than once", id)
+			}
+			seen[id] = struct{}{}
+
+			var claim Claim
+			found, err := claims.Get(id, &claim)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get claim %d", id)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "no such claim %d", id)
+			}
+			if claim.Provider != provider {
+				rt.Abortf(exitcode.ErrForbidden, "claim %d is of provider %v, not %v", id, claim.Provider, provider)
+			}
+			if claim.Sector != sector.SectorNumber {
+				rt.Abortf(exitcode.ErrForbidden, "claim %d is bound to sector %d, not %d", id, claim.Sector, sector.SectorNumber)
+			}
+			space = big.Add(space, claim.Size)
+		}
+		verifiedSpace[i] = space
+	}
+	return &VerifySectorClaimsReturn{VerifiedSpace: verifiedSpace}
+}
+
+type CreateAllocationParams struct {
+	Client   addr.Address
+	Provider addr.Address
+	Size     DataCap
+	// The last epoch at which the allocation can be claimed.
+	Expiration abi.ChainEpoch
+}
+
+type CreateAllocationReturn struct {
+	AllocationID AllocationID
+}
+
+// Called by StorageMarketActor during PublishStorageDeals to allocate a verified client's DataCap to the data of a
+// deal, deducting it from the client's cap. The provider must claim the allocation before it expires, or the
+// client may remove it to recover the DataCap.
+func (a Actor) CreateAllocation(rt runtime.Runtime, params *CreateAllocationParams) *CreateAllocationReturn {
+	rt.ValidateImmediateCallerIs(builtin.StorageMarketActorAddr)
+
+	client, err := builtin.ResolveToIDAddr(rt, params.Client)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve verified client address %v", params.Client)
+	provider, ok := rt.ResolveAddress(params.Provider)
+	if !ok {
+		rt.Abortf(exitcode.ErrIllegalArgument, "failed to resolve provider address %v", params.Provider)
+	}
+
+	if params.Size.LessThan(MinVerifiedDealSize) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "allocation size %v below minimum %v", params.Size, MinVerifiedDealSize)
+	}
+	if params.Expiration < rt.CurrEpoch() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "allocation expiration %d must not be before current epoch %d", params.Expiration, rt.CurrEpoch())
+	}
+
+	var id AllocationID
+	var st State
+	rt.StateTransaction(&st, func() {
+		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
+
+		allocations, err := adt.AsMap(adt.AsStore(rt), st.Allocations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load allocations")
+
+		clientAllocations, err := adt.AsMap(adt.AsStore(rt), st.ClientAllocations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client allocations")
+
+		useDataCap(rt, verifiedClients, client, params.Size)
+
+		id = st.NextAllocationID
+		st.NextAllocationID++
+		err = allocations.Put(id, &Allocation{
+			Client:     client,
+			Provider:   provider,
+			Size:       params.Size,
+			Expiration: params.Expiration,
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put allocation %d", id)
+		err = addIndexedID(clientAllocations, abi.AddrKey(client), id)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to index allocation %d", id)
+
+		st.VerifiedClients, err = verifiedClients.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verified clients")
+
+		st.Allocations, err = allocations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush allocations")
+
+		st.ClientAllocations, err = clientAllocations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client allocations")
+	})
+
+	return &CreateAllocationReturn{AllocationID: id}
+}
+
+type ClaimAllocationsParams struct {
+	// The provider whose sector holds the allocated data. Must be an ID address.
+	Provider      addr.Address
+	Sector        abi.SectorNumber
+	AllocationIDs []AllocationID
+}
+
+// Called by StorageMarketActor when verified deals are activated, to claim their allocations for the sector in
+// which their data was activated. Every allocation must be for the provider and not have expired.
+func (a Actor) ClaimAllocations(rt runtime.Runtime, params *ClaimAllocationsParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerIs(builtin.StorageMarketActorAddr)
+	currEpoch := rt.CurrEpoch()
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		allocations, err := adt.AsMap(adt.AsStore(rt), st.Allocations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load allocations")
+
+		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
+
+		clientAllocations, err := adt.AsMap(adt.AsStore(rt), st.ClientAllocations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client allocations")
+
+		sectorClaims, err := adt.AsMap(adt.AsStore(rt), st.SectorClaims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector claims")
+
+		for _, id := range params.AllocationIDs {
+			var allocation Allocation
+			found, err := allocations.Get(id, &allocation)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get allocation %d", id)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "no such allocation %d", id)
+			}
+			if allocation.Provider != params.Provider {
+				rt.Abortf(exitcode.ErrForbidden, "allocation %d is for provider %v, not %v", id, allocation.Provider, params.Provider)
+			}
+			if currEpoch > allocation.Expiration {
+				rt.Abortf(exitcode.ErrIllegalArgument, "allocation %d expired at %d", id, allocation.Expiration)
+			}
+
+			err = allocations.Delete(id)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete allocation %d", id)
+
+			err = claims.Put(id, &Claim{
+				Client:     allocation.Client,
+				Provider:   allocation.Provider,
+				Size:       allocation.Size,
+				Sector:     params.Sector,
+				ClaimEpoch: currEpoch,
+			})
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put claim %d", id)
+
+			err = removeIndexedID(clientAllocations, abi.AddrKey(allocation.Client), id)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unindex allocation %d", id)
+			err = addIndexedID(sectorClaims, SectorKey{Provider: params.Provider, Sector: params.Sector}, id)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to index claim %d", id)
+		}
+
+		st.Allocations, err = allocations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush allocations")
+
+		st.Claims, err = claims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
+
+		st.ClientAllocations, err = clientAllocations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client allocations")
+
+		st.SectorClaims, err = sectorClaims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush sector claims")
+	})
+
+	return nil
+}
+
+type RemoveExpiredAllocationsParams struct {
+	AllocationIDs []AllocationID
+}
+
+type RemoveExpiredAllocationsReturn struct {
+	// DataCap restored to the client from the removed allocations.
+	DataCapRecovered DataCap
+}
+
+// Removes a client's expired, unclaimed allocations, restoring their DataCap to the client.
+// Only an allocation's client may remove it, so the caller, which may be an actor of any type, is taken to be
+// the client. A client finds the IDs of its allocations with GetClientAllocations.
+func (a Actor) RemoveExpiredAllocations(rt runtime.Runtime, params *RemoveExpiredAllocationsParams) *RemoveExpiredAllocationsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	client := rt.Caller()
+	currEpoch := rt.CurrEpoch()
+
+	recovered := big.Zero()
+	var st State
+	rt.StateTransaction(&st, func() {
+		if client == st.RootKey {
+			rt.Abortf(exitcode.ErrIllegalArgument, "cannot restore allowance for root key")
+		}
+		if isVerifier(rt, st, client) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "cannot restore allowance for verifier %v", client)
+		}
+
+		allocations, err := adt.AsMap(adt.AsStore(rt), st.Allocations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load allocations")
+
+		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
+
+		clientAllocations, err := adt.AsMap(adt.AsStore(rt), st.ClientAllocations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client allocations")
+
+		for _, id := range params.AllocationIDs {
+			var allocation Allocation
+			found, err := allocations.Get(id, &allocation)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get allocation %d", id)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "no such allocation %d", id)
+			}
+			if allocation.Client != client {
+				rt.Abortf(exitcode.ErrForbidden, "allocation %d is of client %v, not %v", id, allocation.Client, client)
+			}
+			if currEpoch <= allocation.Expiration {
+				rt.Abortf(exitcode.ErrForbidden, "allocation %d does not expire until after %d", id, allocation.Expiration)
+			}
+
+			err = allocations.Delete(id)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete allocation %d", id)
+			err = removeIndexedID(clientAllocations, abi.AddrKey(client), id)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unindex allocation %d", id)
+			recovered = big.Add(recovered, allocation.Size)
+		}
+
+		if recovered.GreaterThan(big.Zero()) {
+			var vcCap DataCap
+			found, err := verifiedClients.Get(abi.AddrKey(client), &vcCap)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client %v", client)
+			if !found {
+				vcCap = big.Zero()
+			}
+			newVcCap := big.Add(vcCap, recovered)
+			err = verifiedClients.Put(abi.AddrKey(client), &newVcCap)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put verified client %v with %v", client, newVcCap)
+		}
+
+		st.Allocations, err = allocations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush allocations")
+
+		st.VerifiedClients, err = verifiedClients.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verified clients")
+
+		st.ClientAllocations, err = clientAllocations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client allocations")
+	})
+
+	return &RemoveExpiredAllocationsReturn{DataCapRecovered: recovered}
+}
+
+type RemoveSectorClaimsParams struct {
+	Sectors bitfield.BitField
+}
+
+// Called by a miner to remove the claims bound to its sectors once the sectors no longer hold the claimed data:
+// when they are terminated or expire, or their replica updates are rolled back. Sectors without claims are
+// ignored. The DataCap of a removed claim is not restored.
+func (a Actor) RemoveSectorClaims(rt runtime.Runtime, params *RemoveSectorClaimsParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	provider := rt.Caller()
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
+
+		sectorClaims, err := adt.AsMap(adt.AsStore(rt), st.SectorClaims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector claims")
+
+		err = params.Sectors.ForEach(func(sector uint64) error {
+			key := SectorKey{Provider: provider, Sector: abi.SectorNumber(sector)}
+			var ids bitfield.BitField
+			found, err := sectorClaims.Pop(key, &ids)
+			if err != nil {
+				return xerrors.Errorf("failed to remove claims of sector %d: %w", sector, err)
+			}
+			if !found {
+				return nil
+			}
+			return ids.ForEach(func(id uint64) error {
+				if err := claims.Delete(AllocationID(id)); err != nil {
+					return xerrors.Errorf("failed to delete claim %d of sector %d: %w", id, sector, err)
+				}
+				return nil
+			})
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove sector claims")
+
+		st.Claims, err = claims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
+
+		st.SectorClaims, err = sectorClaims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush sector claims")
+	})
+	return nil
+}
+
+type GetClientAllocationsReturn struct {
+	// IDs of the client's allocations that are yet to be claimed or removed.
+	AllocationIDs bitfield.BitField
+}
+
+// Returns the IDs of a client's allocations, including those made for deals that have since timed out in the
+// market actor, which the client may remove once they expire to recover their DataCap.
+func (a Actor) GetClientAllocations(rt runtime.Runtime, client *addr.Address) *GetClientAllocationsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	clientID, ok := rt.ResolveAddress(*client)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "failed to resolve client address %v", *client)
+	}
+
+	var st State
+	rt.StateReadonly(&st)
+	ids, err := st.GetClientAllocationIDs(adt.AsStore(rt), clientID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get allocations of client %v", clientID)
+	return &GetClientAllocationsReturn{AllocationIDs: ids}
+}
+
+// Deducts bytes from a verified client's cap, aborting if the client's cap does not cover them.
+// Deletes the client if its remaining DataCap is smaller than the minimum verified deal size.
+func useDataCap(rt runtime.Runtime, verifiedClients *adt.Map, client addr.Address, size DataCap) {
+	var vcCap DataCap
+	found, err := verifiedClients.Get(abi.AddrKey(client), &vcCap)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client %v", client)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such verified client %v", client)
+	}
+	builtin.RequireState(rt, vcCap.GreaterThanEqual(big.Zero()), "negative cap for client %v: %v", client, vcCap)
+
+	if size.GreaterThan(vcCap) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "DealSize %d exceeds allowable cap: %d for VerifiedClient %v", size, vcCap, client)
+	}
+
+	newVcCap := big.Sub(vcCap, size)
+	if newVcCap.LessThan(MinVerifiedDealSize) {
+		// Delete entry if remaining DataCap is less than MinVerifiedDealSize.
+		// Will be restored later if the deal did not get activated with a ProvenSector.
+		//
+		// NOTE: Technically, client could lose up to MinVerifiedDealSize worth of DataCap.
+		// See: https://github.com/filecoin-project/specs-actors/issues/727
+		err = verifiedClients.Delete(abi.AddrKey(client))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete verified client %v", client)
+	} else {
+		err = verifiedClients.Put(abi.AddrKey(client), &newVcCap)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update verified client %v with %v", client, newVcCap)
+	}
+}
@@ -2,10 +2,13 @@ package verifreg
 
 import (
 	"bytes"
+	"encoding/binary"
 
 	"github.com/filecoin-project/go-address"
 	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
@@ -42,6 +45,91 @@ type State struct {
 	//specific client. Unique proposal ids ensure that removal proposals cannot be replayed.√
 	// AddrPairKey is constructed as <verifier address, client address>, both using ID addresses.
 	RemoveDataCapProposalIDs cid.Cid // HAMT[AddrPairKey]RmDcProposalID
+
+	// Verified bytes in deals activated for each client, and in deals activated by each provider.
+	// Usage accumulates as deals are activated, and is not reduced when deals end.
+	ClientUsage   cid.Cid // HAMT[addr.Address]DataCap
+	ProviderUsage cid.Cid // HAMT[addr.Address]DataCap
+
+	// Allocations of clients' DataCap to data to be stored by a provider, made when verified deals are published.
+	// An allocation is claimed when its data is activated in a sector. An allocation that expires unclaimed
+	// may be removed by its client, recovering the DataCap.
+	Allocations cid.Cid // HAMT[AllocationID]Allocation
+
+	// Claimed allocations, keyed by the ID of the allocation.
+	Claims cid.Cid // HAMT[AllocationID]Claim
+
+	// IDs of each client's allocations, by which a client finds the allocations it may remove once they expire.
+	// An ID is removed when its allocation is claimed or removed.
+	ClientAllocations cid.Cid // HAMT[addr.Address]bitfield.BitField
+
+	// IDs of the claims bound to each provider's sectors, by which the claims are removed when a sector is
+	// terminated or expires, or a replica update of it is rolled back.
+	SectorClaims cid.Cid // HAMT[SectorKey]bitfield.BitField
+
+	// The ID of the next allocation to be made.
+	NextAllocationID AllocationID
+}
+
+// Identifies an allocation of DataCap, and the claim made of it.
+type AllocationID uint64
+
+func (id AllocationID) Key() string {
+	return abi.UIntKey(uint64(id)).Key()
+}
+
+// DataCap allocated by a client to data to be stored by a provider. Both addresses are ID addresses.
+type Allocation struct {
+	Client   addr.Address
+	Provider addr.Address
+	Size     DataCap
+	// The last epoch at which the allocation can be claimed. After it, the allocation may be removed by the client.
+	Expiration abi.ChainEpoch
+}
+
+// An allocation claimed by a provider for the sector in which the allocated data was activated.
+type Claim struct {
+	Client   addr.Address
+	Provider addr.Address
+	Size     DataCap
+	Sector   abi.SectorNumber
+	// The epoch at which the allocation was claimed.
+	ClaimEpoch abi.ChainEpoch
+}
+
+// Key of the claims bound to a provider's sector.
+type SectorKey struct {
+	Provider addr.Address // Must be an ID address
+	Sector   abi.SectorNumber
+}
+
+// The key is the provider's actor ID followed by the sector number, each as a uvarint.
+func (k SectorKey) Key() string {
+	id, err := addr.IDFromAddress(k.Provider)
+	if err != nil {
+		panic(err) // Providers are resolved to ID addresses before claiming
+	}
+	buf := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, id)
+	n += binary.PutUvarint(buf[n:], uint64(k.Sector))
+	return string(buf[:n])
+}
+
+func ParseSectorKey(key string) (SectorKey, error) {
+	buf := []byte(key)
+	id, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return SectorKey{}, xerrors.Errorf("failed to parse provider of sector key %x", buf)
+	}
+	sector, m := binary.Uvarint(buf[n:])
+	if m <= 0 || n+m != len(buf) {
+		return SectorKey{}, xerrors.Errorf("failed to parse sector of sector key %x", buf)
+	}
+	provider, err := addr.NewIDAddress(id)
+	if err != nil {
+		return SectorKey{}, err
+	}
+	return SectorKey{Provider: provider, Sector: abi.SectorNumber(sector)}, nil
 }
 
 var MinVerifiedDealSize = abi.NewStoragePower(1 << 20)
@@ -58,9 +146,162 @@ func ConstructState(store adt.Store, rootKeyAddress addr.Address) (*State, error
 		Verifiers:                emptyMapCid,
 		VerifiedClients:          emptyMapCid,
 		RemoveDataCapProposalIDs: emptyMapCid,
+		ClientUsage:              emptyMapCid,
+		ProviderUsage:            emptyMapCid,
+		Allocations:              emptyMapCid,
+		Claims:                   emptyMapCid,
+		ClientAllocations:        emptyMapCid,
+		SectorClaims:             emptyMapCid,
+		NextAllocationID:         0,
 	}, nil
 }
 
+// Returns the verified bytes in deals activated for an address as a client, and as a provider.
+func (st *State) GetDataCapUsage(store adt.Store, a addr.Address) (DataCap, DataCap, error) {
+	clientUsage, err := getUsage(store, st.ClientUsage, a)
+	if err != nil {
+		return DataCap{}, DataCap{}, xerrors.Errorf("failed to get client usage: %w", err)
+	}
+	providerUsage, err := getUsage(store, st.ProviderUsage, a)
+	if err != nil {
+		return DataCap{}, DataCap{}, xerrors.Errorf("failed to get provider usage: %w", err)
+	}
+	return clientUsage, providerUsage, nil
+}
+
+func getUsage(store adt.Store, root cid.Cid, a addr.Address) (DataCap, error) {
+	usage, err := adt.AsMap(store, root, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return DataCap{}, err
+	}
+	var used DataCap
+	found, err := usage.Get(abi.AddrKey(a), &used)
+	if err != nil {
+		return DataCap{}, xerrors.Errorf("failed to get usage for %v: %w", a, err)
+	}
+	if !found {
+		return big.Zero(), nil
+	}
+	return used, nil
+}
+
+// Returns an allocation, and whether it exists. A claimed allocation no longer exists.
+func (st *State) GetAllocation(store adt.Store, id AllocationID) (*Allocation, bool, error) {
+	allocations, err := adt.AsMap(store, st.Allocations, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load allocations: %w", err)
+	}
+	var allocation Allocation
+	found, err := allocations.Get(id, &allocation)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to get allocation %d: %w", id, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &allocation, true, nil
+}
+
+// Returns the claim of an allocation, and whether it exists.
+func (st *State) GetClaim(store adt.Store, id AllocationID) (*Claim, bool, error) {
+	claims, err := adt.AsMap(store, st.Claims, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load claims: %w", err)
+	}
+	var claim Claim
+	found, err := claims.Get(id, &claim)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to get claim %d: %w", id, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &claim, true, nil
+}
+
+// Returns the IDs of a client's allocations, which must be identified by ID address.
+func (st *State) GetClientAllocationIDs(store adt.Store, client addr.Address) (bitfield.BitField, error) {
+	index, err := adt.AsMap(store, st.ClientAllocations, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to load client allocations: %w", err)
+	}
+	return getIndexedIDs(index, abi.AddrKey(client))
+}
+
+// Returns the IDs of the claims bound to a provider's sector.
+func (st *State) GetSectorClaimIDs(store adt.Store, provider addr.Address, sector abi.SectorNumber) (bitfield.BitField, error) {
+	index, err := adt.AsMap(store, st.SectorClaims, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to load sector claims: %w", err)
+	}
+	return getIndexedIDs(index, SectorKey{Provider: provider, Sector: sector})
+}
+
+// Returns the IDs held under a key in an index of allocation or claim IDs, which are empty if there is no entry.
+func getIndexedIDs(index *adt.Map, key abi.Keyer) (bitfield.BitField, error) {
+	var ids bitfield.BitField
+	found, err := index.Get(key, &ids)
+	if err != nil {
+		return bitfield.BitField{}, xerrors.Errorf("failed to get IDs for %v: %w", key, err)
+	}
+	if !found {
+		return bitfield.New(), nil
+	}
+	return ids, nil
+}
+
+// Adds an ID under a key in an index of allocation or claim IDs.
+func addIndexedID(index *adt.Map, key abi.Keyer, id AllocationID) error {
+	ids, err := getIndexedIDs(index, key)
+	if err != nil {
+		return err
+	}
+	ids.Set(uint64(id))
+	if err = index.Put(key, &ids); err != nil {
+		return xerrors.Errorf("failed to put IDs for %v: %w", key, err)
+	}
+	return nil
+}
+
+// Removes an ID from under a key in an index of allocation or claim IDs, deleting the entry once it is empty.
+func removeIndexedID(index *adt.Map, key abi.Keyer, id AllocationID) error {
+	ids, err := getIndexedIDs(index, key)
+	if err != nil {
+		return err
+	}
+	ids.Unset(uint64(id))
+	empty, err := ids.IsEmpty()
+	if err != nil {
+		return xerrors.Errorf("failed to check IDs for %v: %w", key, err)
+	}
+	if empty {
+		err = index.Delete(key)
+	} else {
+		err = index.Put(key, &ids)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to update IDs for %v: %w", key, err)
+	}
+	return nil
+}
+
+// Adds verified bytes to an address's accumulated usage.
+func addUsage(usage *adt.Map, a addr.Address, size DataCap) error {
+	var used DataCap
+	found, err := usage.Get(abi.AddrKey(a), &used)
+	if err != nil {
+		return xerrors.Errorf("failed to get usage for %v: %w", a, err)
+	}
+	if !found {
+		used = big.Zero()
+	}
+	newUsed := big.Add(used, size)
+	if err := usage.Put(abi.AddrKey(a), &newUsed); err != nil {
+		return xerrors.Errorf("failed to put usage for %v: %w", a, err)
+	}
+	return nil
+}
+
 // A verifier who wants to send/agree to a RemoveDataCapRequest should sign a RemoveDataCapProposal and send the signed proposal to the root key holder.
 type RemoveDataCapProposal struct {
 	// VerifiedClient is the client address to remove the DataCap from
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
@@ -424,7 +425,7 @@ func TestAddVerifiedClient(t *testing.T) {
 	})
 }
 
-func TestUseBytes(t *testing.T) {
+func TestAllocateBytes(t *testing.T) {
 	root := tutil.NewIDAddr(t, 101)
 	clientAddr := tutil.NewIDAddr(t, 201)
 	clientAddr2 := tutil.NewIDAddr(t, 202)
@@ -433,7 +434,7 @@ func TestUseBytes(t *testing.T) {
 	verifierAddr := tutil.NewIDAddr(t, 301)
 	vallow := big.Add(verifreg.MinVerifiedDealSize, big.NewInt(100))
 
-	t.Run("successfully consume deal bytes for deals from different verified clients", func(t *testing.T) {
+	t.Run("successfully allocate deal bytes for deals from different verified clients", func(t *testing.T) {
 		rt, ac := basicVerifRegSetup(t, root)
 
 		ca1 := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(3))
@@ -449,11 +450,11 @@ func TestUseBytes(t *testing.T) {
 		bal1 := big.Sub(ca1, dSize)
 		bal2 := big.Sub(ca2, dSize)
 		// client 1 uses bytes
-		ac.useBytes(rt, clientAddr, dSize, &capExpectation{expectedCap: bal1})
+		ac.allocateBytes(rt, clientAddr, dSize, &capExpectation{expectedCap: bal1})
 		// client 2 uses bytes
-		ac.useBytes(rt, clientAddr2, dSize, &capExpectation{expectedCap: bal2})
+		ac.allocateBytes(rt, clientAddr2, dSize, &capExpectation{expectedCap: bal2})
 		// client 3 uses bytes
-		ac.useBytes(rt, clientAddr3, dSize, &capExpectation{removed: true})
+		ac.allocateBytes(rt, clientAddr3, dSize, &capExpectation{removed: true})
 
 		// verify
 		assert.EqualValues(t, bal1, ac.getClientCap(rt, clientAddr))
@@ -462,9 +463,9 @@ func TestUseBytes(t *testing.T) {
 
 		// client 1 adds a deal and it works
 		bal1 = big.Sub(bal1, dSize)
-		ac.useBytes(rt, clientAddr, dSize, &capExpectation{expectedCap: bal1})
+		ac.allocateBytes(rt, clientAddr, dSize, &capExpectation{expectedCap: bal1})
 		// client 2 adds a deal and it works
-		ac.useBytes(rt, clientAddr2, dSize, &capExpectation{removed: true})
+		ac.allocateBytes(rt, clientAddr2, dSize, &capExpectation{removed: true})
 
 		// verify
 		assert.EqualValues(t, bal1, ac.getClientCap(rt, clientAddr))
@@ -472,7 +473,7 @@ func TestUseBytes(t *testing.T) {
 		ac.checkState(rt)
 	})
 
-	t.Run("successfully consume deal bytes for verified client and then fail on next attempt because it does NOT have enough allowance", func(t *testing.T) {
+	t.Run("successfully allocate deal bytes for verified client and then fail on next attempt because it does NOT have enough allowance", func(t *testing.T) {
 		rt, ac := basicVerifRegSetup(t, root)
 		clientAllowance := big.Sum(verifreg.MinVerifiedDealSize, verifreg.MinVerifiedDealSize, big.NewInt(1))
 
@@ -481,19 +482,17 @@ func TestUseBytes(t *testing.T) {
 
 		// use bytes
 		dSize1 := verifreg.MinVerifiedDealSize
-		ac.useBytes(rt, clientAddr, dSize1, &capExpectation{expectedCap: big.Sub(clientAllowance, dSize1)})
+		ac.allocateBytes(rt, clientAddr, dSize1, &capExpectation{expectedCap: big.Sub(clientAllowance, dSize1)})
 
 		// fails now because client does NOT have enough capacity for second deal
 		dSize2 := big.Add(verifreg.MinVerifiedDealSize, big.NewInt(2))
-		param := &verifreg.UseBytesParams{Address: clientAddr, DealSize: dSize2}
-
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			ac.useBytes(rt, param.Address, param.DealSize, nil)
+				rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			ac.allocateBytes(rt, clientAddr, dSize2, nil)
 		})
 		ac.checkState(rt)
 	})
 
-	t.Run("successfully consume deal bytes after resolving verified client address", func(t *testing.T) {
+	t.Run("successfully allocate deal bytes after resolving verified client address", func(t *testing.T) {
 		rt, ac := basicVerifRegSetup(t, root)
 		clientAllowance := big.Sum(verifreg.MinVerifiedDealSize, verifreg.MinVerifiedDealSize, big.NewInt(1))
 
@@ -506,11 +505,11 @@ func TestUseBytes(t *testing.T) {
 
 		// use bytes
 		dSize1 := verifreg.MinVerifiedDealSize
-		ac.useBytes(rt, clientNonIdAddr, dSize1, &capExpectation{expectedCap: big.Sub(clientAllowance, dSize1)})
+		ac.allocateBytes(rt, clientNonIdAddr, dSize1, &capExpectation{expectedCap: big.Sub(clientAllowance, dSize1)})
 		ac.checkState(rt)
 	})
 
-	t.Run("successfully consume deal for verified client and then fail on next attempt because it has been removed", func(t *testing.T) {
+	t.Run("successfully allocate deal bytes for verified client and then fail on next attempt because it has been removed", func(t *testing.T) {
 		rt, ac := basicVerifRegSetup(t, root)
 		clientAllowance := big.Sum(verifreg.MinVerifiedDealSize, big.NewInt(1))
 
@@ -519,27 +518,13 @@ func TestUseBytes(t *testing.T) {
 
 		// use bytes
 		dSize1 := verifreg.MinVerifiedDealSize
-		ac.useBytes(rt, clientAddr, dSize1, &capExpectation{removed: true})
+		ac.allocateBytes(rt, clientAddr, dSize1, &capExpectation{removed: true})
 
 		// fails now because client has been removed
 		dSize2 := verifreg.MinVerifiedDealSize
-		param := &verifreg.UseBytesParams{Address: clientAddr, DealSize: dSize2}
-
-		rt.ExpectAbort(exitcode.ErrNotFound, func() {
-			ac.useBytes(rt, param.Address, param.DealSize, nil)
-
-		})
-		ac.checkState(rt)
-	})
+				rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			ac.allocateBytes(rt, clientAddr, dSize2, nil)
 
-	t.Run("fail if caller is not storage market actor", func(t *testing.T) {
-		rt, ac := basicVerifRegSetup(t, root)
-		rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
-		rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
-		param := &verifreg.UseBytesParams{Address: clientAddr, DealSize: verifreg.MinVerifiedDealSize}
-
-		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
-			rt.Call(ac.UseBytes, param)
 		})
 		ac.checkState(rt)
 	})
@@ -547,10 +532,8 @@ func TestUseBytes(t *testing.T) {
 	t.Run("fail if deal size is less than min verified deal size", func(t *testing.T) {
 		rt, ac := basicVerifRegSetup(t, root)
 		dSize2 := big.Sub(verifreg.MinVerifiedDealSize, big.NewInt(1))
-		param := &verifreg.UseBytesParams{Address: clientAddr, DealSize: dSize2}
-
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			ac.useBytes(rt, param.Address, param.DealSize, nil)
+				rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			ac.allocateBytes(rt, clientAddr, dSize2, nil)
 		})
 		ac.checkState(rt)
 	})
@@ -558,10 +541,8 @@ func TestUseBytes(t *testing.T) {
 	t.Run("fail if verified client does not exist", func(t *testing.T) {
 		rt, ac := basicVerifRegSetup(t, root)
 		dSize2 := verifreg.MinVerifiedDealSize
-		param := &verifreg.UseBytesParams{Address: clientAddr, DealSize: dSize2}
-
-		rt.ExpectAbort(exitcode.ErrNotFound, func() {
-			ac.useBytes(rt, param.Address, param.DealSize, nil)
+				rt.ExpectAbort(exitcode.ErrNotFound, func() {
+			ac.allocateBytes(rt, clientAddr, dSize2, nil)
 
 		})
 		ac.checkState(rt)
@@ -576,10 +557,8 @@ func TestUseBytes(t *testing.T) {
 
 		// use bytes
 		dSize := big.Add(clientAllowance, big.NewInt(1))
-		param := &verifreg.UseBytesParams{Address: clientAddr, DealSize: dSize}
-
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			ac.useBytes(rt, param.Address, param.DealSize, nil)
+				rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			ac.allocateBytes(rt, clientAddr, dSize, nil)
 		})
 		ac.checkState(rt)
 	})
@@ -625,8 +604,8 @@ func TestRestoreBytes(t *testing.T) {
 		bal1 = big.Sub(bal1, dSize)
 		bal2 = big.Sub(bal2, dSize)
 		// client1 and client2 use bytes
-		ac.useBytes(rt, clientAddr, dSize, &capExpectation{expectedCap: bal1})
-		ac.useBytes(rt, clientAddr2, dSize, &capExpectation{expectedCap: bal2})
+		ac.allocateBytes(rt, clientAddr, dSize, &capExpectation{expectedCap: bal1})
+		ac.allocateBytes(rt, clientAddr2, dSize, &capExpectation{expectedCap: bal2})
 
 		assert.EqualValues(t, bal1, ac.getClientCap(rt, clientAddr))
 		assert.EqualValues(t, bal2, ac.getClientCap(rt, clientAddr2))
@@ -653,7 +632,7 @@ func TestRestoreBytes(t *testing.T) {
 		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientAllowance)
 		dSize1 := verifreg.MinVerifiedDealSize
 		bal := big.Sub(clientAllowance, dSize1)
-		ac.useBytes(rt, clientAddr, dSize1, &capExpectation{expectedCap: bal})
+		ac.allocateBytes(rt, clientAddr, dSize1, &capExpectation{expectedCap: bal})
 
 		sz := verifreg.MinVerifiedDealSize
 		ac.restoreBytes(rt, clientAddr, sz, &capExpectation{expectedCap: big.Add(bal, sz)})
@@ -673,7 +652,7 @@ func TestRestoreBytes(t *testing.T) {
 		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientIdAddr, vallow, clientAllowance)
 		dSize1 := verifreg.MinVerifiedDealSize
 		bal := big.Sub(clientAllowance, dSize1)
-		ac.useBytes(rt, clientIdAddr, dSize1, &capExpectation{expectedCap: bal})
+		ac.allocateBytes(rt, clientIdAddr, dSize1, &capExpectation{expectedCap: bal})
 
 		sz := verifreg.MinVerifiedDealSize
 		ac.restoreBytes(rt, clientNonIdAddr, sz, &capExpectation{expectedCap: big.Add(bal, sz)})
@@ -689,7 +668,7 @@ func TestRestoreBytes(t *testing.T) {
 		// add verified client -> use bytes -> client is removed
 		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientAllowance)
 		dSize1 := verifreg.MinVerifiedDealSize
-		ac.useBytes(rt, clientAddr, dSize1, &capExpectation{removed: true})
+		ac.allocateBytes(rt, clientAddr, dSize1, &capExpectation{removed: true})
 
 		sz := verifreg.MinVerifiedDealSize
 		ac.restoreBytes(rt, clientAddr, sz, &capExpectation{expectedCap: sz})
@@ -749,6 +728,374 @@ func TestRestoreBytes(t *testing.T) {
 	})
 }
 
+func TestRestoreBytesBatch(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	clientAddr2 := tutil.NewIDAddr(t, 202)
+	verifierAddr := tutil.NewIDAddr(t, 301)
+	vallow := big.Add(verifreg.MinVerifiedDealSize, big.NewInt(100))
+	dSize := verifreg.MinVerifiedDealSize
+
+	t.Run("restores bytes for each client in the batch", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, dSize)
+		ac.allocateBytes(rt, clientAddr, dSize, &capExpectation{removed: true})
+
+		ret := ac.restoreBytesBatch(rt,
+			verifreg.RestoreBytesParams{Address: clientAddr, DealSize: dSize},
+			verifreg.RestoreBytesParams{Address: clientAddr2, DealSize: dSize},
+			verifreg.RestoreBytesParams{Address: clientAddr, DealSize: dSize},
+		)
+		assert.Empty(t, ret.Failures)
+
+		assert.EqualValues(t, big.Mul(dSize, big.NewInt(2)), ac.getClientCap(rt, clientAddr))
+		assert.EqualValues(t, dSize, ac.getClientCap(rt, clientAddr2))
+		ac.checkState(rt)
+	})
+
+	t.Run("rejected restorations are reported without affecting others", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.addNewVerifier(rt, verifierAddr, vallow)
+
+		ret := ac.restoreBytesBatch(rt,
+			verifreg.RestoreBytesParams{Address: root, DealSize: dSize},
+			verifreg.RestoreBytesParams{Address: clientAddr, DealSize: dSize},
+			verifreg.RestoreBytesParams{Address: clientAddr2, DealSize: big.Sub(dSize, big.NewInt(1))},
+			verifreg.RestoreBytesParams{Address: verifierAddr, DealSize: dSize},
+		)
+		assert.Equal(t, []verifreg.RestoreBytesFailure{
+			{Index: 0, Code: exitcode.ErrIllegalArgument},
+			{Index: 2, Code: exitcode.ErrIllegalArgument},
+			{Index: 3, Code: exitcode.ErrIllegalArgument},
+		}, ret.Failures)
+
+		assert.EqualValues(t, dSize, ac.getClientCap(rt, clientAddr))
+		ac.assertClientRemoved(rt, clientAddr2)
+		ac.assertClientRemoved(rt, verifierAddr)
+		ac.checkState(rt)
+	})
+
+	t.Run("fail if caller is not storage market actor", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+		rt.SetCaller(builtin.StoragePowerActorAddr, builtin.StoragePowerActorCodeID)
+		param := &verifreg.RestoreBytesBatchParams{Restorations: []verifreg.RestoreBytesParams{{Address: clientAddr, DealSize: dSize}}}
+
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(ac.RestoreBytesBatch, param)
+		})
+		ac.checkState(rt)
+	})
+}
+
+func TestDataCapUsage(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	clientAddr2 := tutil.NewIDAddr(t, 202)
+	providerAddr := tutil.NewIDAddr(t, 301)
+	providerAddr2 := tutil.NewIDAddr(t, 302)
+	dSize := verifreg.MinVerifiedDealSize
+
+	t.Run("usage accumulates per client and per provider", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.assertUsage(rt, clientAddr, big.Zero(), big.Zero())
+
+		ac.recordActivatedBytes(rt,
+			verifreg.ActivatedBytes{Client: clientAddr, Provider: providerAddr, DealSize: dSize},
+			verifreg.ActivatedBytes{Client: clientAddr, Provider: providerAddr2, DealSize: dSize},
+		)
+		ac.recordActivatedBytes(rt,
+			verifreg.ActivatedBytes{Client: clientAddr2, Provider: providerAddr, DealSize: big.Mul(dSize, big.NewInt(2))},
+		)
+
+		ac.assertUsage(rt, clientAddr, big.Mul(dSize, big.NewInt(2)), big.Zero())
+		ac.assertUsage(rt, clientAddr2, big.Mul(dSize, big.NewInt(2)), big.Zero())
+		ac.assertUsage(rt, providerAddr, big.Zero(), big.Mul(dSize, big.NewInt(3)))
+		ac.assertUsage(rt, providerAddr2, big.Zero(), dSize)
+		ac.checkState(rt)
+	})
+
+	t.Run("unresolvable address has no usage", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.assertUsage(rt, tutil.NewSECP256K1Addr(t, "unknown"), big.Zero(), big.Zero())
+	})
+
+	t.Run("only the market may record usage", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		rt.SetCaller(providerAddr, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(ac.RecordActivatedBytes, &verifreg.RecordActivatedBytesParams{
+				Activations: []verifreg.ActivatedBytes{{Client: clientAddr, Provider: providerAddr, DealSize: dSize}},
+			})
+		})
+		rt.Reset()
+	})
+
+	t.Run("usage below minimum deal size is rejected", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		rt.SetCaller(builtin.StorageMarketActorAddr, builtin.StorageMarketActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			rt.Call(ac.RecordActivatedBytes, &verifreg.RecordActivatedBytesParams{
+				Activations: []verifreg.ActivatedBytes{{Client: clientAddr, Provider: providerAddr, DealSize: big.Sub(dSize, big.NewInt(1))}},
+			})
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+}
+
+func TestAllocations(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	clientAddr2 := tutil.NewIDAddr(t, 202)
+	providerAddr := tutil.NewIDAddr(t, 301)
+	providerAddr2 := tutil.NewIDAddr(t, 302)
+	verifierAddr := tutil.NewIDAddr(t, 401)
+	vallow := verifreg.MinVerifiedDealSize
+	dSize := verifreg.MinVerifiedDealSize
+	clientCap := big.Mul(dSize, big.NewInt(3))
+	expiration := abi.ChainEpoch(100)
+
+	t.Run("allocation uses client datacap and is claimed for a sector", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+
+		id0 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		id1 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		assert.Equal(t, verifreg.AllocationID(0), id0)
+		assert.Equal(t, verifreg.AllocationID(1), id1)
+		assert.EqualValues(t, dSize, ac.getClientCap(rt, clientAddr))
+
+		rt.SetEpoch(expiration)
+		ac.claimAllocations(rt, providerAddr, 7, id1)
+
+		allocation, found, err := ac.state(rt).GetAllocation(rt.AdtStore(), id0)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, verifreg.Allocation{Client: clientAddr, Provider: providerAddr, Size: dSize, Expiration: expiration}, *allocation)
+
+		_, found, err = ac.state(rt).GetAllocation(rt.AdtStore(), id1)
+		require.NoError(t, err)
+		assert.False(t, found)
+		claim, found, err := ac.state(rt).GetClaim(rt.AdtStore(), id1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, verifreg.Claim{Client: clientAddr, Provider: providerAddr, Size: dSize, Sector: 7, ClaimEpoch: expiration}, *claim)
+		ac.checkState(rt)
+	})
+
+	t.Run("allocation fails without sufficient datacap", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, dSize)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exceeds allowable cap", func() {
+			ac.createAllocation(rt, clientAddr, providerAddr, big.Add(dSize, big.NewInt(1)), expiration)
+		})
+		rt.Reset()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such verified client", func() {
+			ac.createAllocation(rt, clientAddr2, providerAddr, dSize, expiration)
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+
+	t.Run("allocation must not expire before the current epoch", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+
+		rt.SetEpoch(expiration + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must not be before current epoch", func() {
+			ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+
+	t.Run("only the market may create and claim allocations", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		rt.SetCaller(providerAddr, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(ac.CreateAllocation, &verifreg.CreateAllocationParams{Client: clientAddr, Provider: providerAddr, Size: dSize, Expiration: expiration})
+		})
+		rt.Reset()
+
+		rt.SetCaller(providerAddr, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(ac.ClaimAllocations, &verifreg.ClaimAllocationsParams{Provider: providerAddr, AllocationIDs: []verifreg.AllocationID{0}})
+		})
+		rt.Reset()
+	})
+
+	t.Run("claim fails for an expired allocation, another provider's allocation, or a missing allocation", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+		id := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is for provider", func() {
+			ac.claimAllocations(rt, providerAddr2, 0, id)
+		})
+		rt.Reset()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such allocation", func() {
+			ac.claimAllocations(rt, providerAddr, 0, id+1)
+		})
+		rt.Reset()
+		rt.SetEpoch(expiration + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "expired", func() {
+			ac.claimAllocations(rt, providerAddr, 0, id)
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+
+	t.Run("client removes expired allocations to recover datacap", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+		id0 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		id1 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		id2 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		ac.assertClientRemoved(rt, clientAddr)
+
+		rt.SetEpoch(expiration + 1)
+		recovered := ac.removeExpiredAllocations(rt, clientAddr, id0, id2)
+		assert.Equal(t, big.Mul(dSize, big.NewInt(2)), recovered)
+		assert.EqualValues(t, recovered, ac.getClientCap(rt, clientAddr))
+
+		_, found, err := ac.state(rt).GetAllocation(rt.AdtStore(), id1)
+		require.NoError(t, err)
+		assert.True(t, found)
+		ac.checkState(rt)
+	})
+
+	t.Run("allocation cannot be removed before it expires", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+		id := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+
+		rt.SetEpoch(expiration)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "does not expire until", func() {
+			ac.removeExpiredAllocations(rt, clientAddr, id)
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+
+	t.Run("only the client may remove an allocation", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+		id := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+
+		rt.SetEpoch(expiration + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is of client", func() {
+			ac.removeExpiredAllocations(rt, clientAddr2, id)
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+
+	t.Run("client of any actor type removes its expired allocations", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+		id := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+
+		rt.SetEpoch(expiration + 1)
+		rt.SetCaller(clientAddr, builtin.MultisigActorCodeID)
+		rt.ExpectValidateCallerAny()
+		ret := rt.Call(ac.RemoveExpiredAllocations, &verifreg.RemoveExpiredAllocationsParams{
+			AllocationIDs: []verifreg.AllocationID{id},
+		}).(*verifreg.RemoveExpiredAllocationsReturn)
+		rt.Verify()
+		assert.Equal(t, dSize, ret.DataCapRecovered)
+		ac.checkState(rt)
+	})
+
+	t.Run("client finds its allocations until they are claimed or removed", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+		id0 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		id1 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		id2 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		assert.Equal(t, []verifreg.AllocationID{id0, id1, id2}, ac.getClientAllocations(rt, clientAddr))
+		assert.Empty(t, ac.getClientAllocations(rt, clientAddr2))
+
+		ac.claimAllocations(rt, providerAddr, 0, id1)
+		assert.Equal(t, []verifreg.AllocationID{id0, id2}, ac.getClientAllocations(rt, clientAddr))
+
+		rt.SetEpoch(expiration + 1)
+		ac.removeExpiredAllocations(rt, clientAddr, id0, id2)
+		assert.Empty(t, ac.getClientAllocations(rt, clientAddr))
+		ac.checkState(rt)
+	})
+
+	t.Run("claimed allocation cannot be removed", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, vallow, clientCap)
+		id := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		ac.claimAllocations(rt, providerAddr, 0, id)
+
+		rt.SetEpoch(expiration + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such allocation", func() {
+			ac.removeExpiredAllocations(rt, clientAddr, id)
+		})
+		rt.Reset()
+		ac.checkState(rt)
+	})
+}
+
+func TestRemoveSectorClaims(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	providerAddr := tutil.NewIDAddr(t, 301)
+	providerAddr2 := tutil.NewIDAddr(t, 302)
+	verifierAddr := tutil.NewIDAddr(t, 401)
+	dSize := verifreg.MinVerifiedDealSize
+	clientCap := big.Mul(dSize, big.NewInt(3))
+	expiration := abi.ChainEpoch(100)
+
+	t.Run("removes the claims of the provider's sectors", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, dSize, clientCap)
+		id0 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		id1 := ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration)
+		id2 := ac.createAllocation(rt, clientAddr, providerAddr2, dSize, expiration)
+		ac.claimAllocations(rt, providerAddr, 7, id0)
+		ac.claimAllocations(rt, providerAddr, 8, id1)
+		ac.claimAllocations(rt, providerAddr2, 7, id2)
+
+		// Sector 9 has no claims.
+		ac.removeSectorClaims(rt, providerAddr, 7, 9)
+
+		st := ac.state(rt)
+		_, found, err := st.GetClaim(rt.AdtStore(), id0)
+		require.NoError(t, err)
+		assert.False(t, found)
+		for _, id := range []verifreg.AllocationID{id1, id2} {
+			_, found, err = st.GetClaim(rt.AdtStore(), id)
+			require.NoError(t, err)
+			assert.True(t, found)
+		}
+		ids, err := st.GetSectorClaimIDs(rt.AdtStore(), providerAddr, 7)
+		require.NoError(t, err)
+		empty, err := ids.IsEmpty()
+		require.NoError(t, err)
+		assert.True(t, empty)
+		ac.checkState(rt)
+	})
+
+	t.Run("only a miner may remove its sector claims", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		rt.SetCaller(providerAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(ac.RemoveSectorClaims, &verifreg.RemoveSectorClaimsParams{Sectors: bitfield.NewFromSet([]uint64{7})})
+		})
+		rt.Reset()
+	})
+}
+
 type verifRegActorTestHarness struct {
 	rootkey address.Address
 	verifreg.Actor
@@ -768,6 +1115,86 @@ func basicVerifRegSetup(t *testing.T, root address.Address) (*mock.Runtime, *ver
 	return rt, &actor
 }
 
+func TestVerifySectorClaims(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	providerAddr := tutil.NewIDAddr(t, 301)
+	providerAddr2 := tutil.NewIDAddr(t, 302)
+	verifierAddr := tutil.NewIDAddr(t, 401)
+	dSize := verifreg.MinVerifiedDealSize
+	clientCap := big.Mul(dSize, big.NewInt(3))
+	expiration := abi.ChainEpoch(100)
+
+	setup := func(t *testing.T) (*mock.Runtime, *verifRegActorTestHarness, []verifreg.AllocationID) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.generateAndAddVerifierAndVerifiedClient(rt, verifierAddr, clientAddr, dSize, clientCap)
+		ids := []verifreg.AllocationID{
+			ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration),
+			ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration),
+			ac.createAllocation(rt, clientAddr, providerAddr, dSize, expiration),
+		}
+		ac.claimAllocations(rt, providerAddr, 7, ids[0], ids[1])
+		ac.claimAllocations(rt, providerAddr, 8, ids[2])
+		return rt, ac, ids
+	}
+
+	t.Run("returns the space of the claims bound to each sector", func(t *testing.T) {
+		rt, ac, ids := setup(t)
+
+		space := ac.verifySectorClaims(rt, providerAddr,
+			verifreg.SectorClaims{SectorNumber: 8, ClaimIDs: ids[2:]},
+			verifreg.SectorClaims{SectorNumber: 7, ClaimIDs: ids[:2]},
+			verifreg.SectorClaims{SectorNumber: 9},
+		)
+		assert.Equal(t, []verifreg.DataCap{dSize, big.Mul(dSize, big.NewInt(2)), big.Zero()}, space)
+		ac.checkState(rt)
+	})
+
+	t.Run("rejects a claim bound to another sector", func(t *testing.T) {
+		rt, ac, ids := setup(t)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is bound to sector 8, not 7", func() {
+			ac.verifySectorClaims(rt, providerAddr, verifreg.SectorClaims{SectorNumber: 7, ClaimIDs: ids[2:]})
+		})
+		rt.Reset()
+	})
+
+	t.Run("rejects a claim asserted for more than one sector", func(t *testing.T) {
+		rt, ac, ids := setup(t)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "asserted more than once", func() {
+			ac.verifySectorClaims(rt, providerAddr,
+				verifreg.SectorClaims{SectorNumber: 7, ClaimIDs: ids[:1]},
+				verifreg.SectorClaims{SectorNumber: 7, ClaimIDs: ids[:1]},
+			)
+		})
+		rt.Reset()
+	})
+
+	t.Run("rejects another provider's claim or a missing claim", func(t *testing.T) {
+		rt, ac, ids := setup(t)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is of provider", func() {
+			ac.verifySectorClaims(rt, providerAddr2, verifreg.SectorClaims{SectorNumber: 7, ClaimIDs: ids[:1]})
+		})
+		rt.Reset()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such claim", func() {
+			ac.verifySectorClaims(rt, providerAddr, verifreg.SectorClaims{SectorNumber: 7, ClaimIDs: []verifreg.AllocationID{ids[2] + 1}})
+		})
+		rt.Reset()
+	})
+
+	t.Run("only a miner may verify its claims", func(t *testing.T) {
+		rt, ac := basicVerifRegSetup(t, root)
+		rt.SetCaller(providerAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(ac.VerifySectorClaims, &verifreg.VerifySectorClaimsParams{})
+		})
+		rt.Reset()
+	})
+}
+
 func (h *verifRegActorTestHarness) constructAndVerify(rt *mock.Runtime) {
 	rt.ExpectValidateCallerAddr(builtin.SystemActorAddr)
 	ret := rt.Call(h.Constructor, &h.rootkey)
@@ -851,15 +1278,10 @@ type capExpectation struct {
 	removed     bool
 }
 
-func (h *verifRegActorTestHarness) useBytes(rt *mock.Runtime, a address.Address, dealSize verifreg.DataCap, expectedCap *capExpectation) {
-	rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
-	rt.SetCaller(builtin.StorageMarketActorAddr, builtin.StorageMinerActorCodeID)
-
-	param := &verifreg.UseBytesParams{Address: a, DealSize: dealSize}
-
-	ret := rt.Call(h.UseBytes, param)
-	rt.Verify()
-	assert.Nil(h.t, ret)
+// Allocates dealSize bytes of a client's DataCap, as the market does for a verified deal, and checks the client's
+// remaining cap.
+func (h *verifRegActorTestHarness) allocateBytes(rt *mock.Runtime, a address.Address, dealSize verifreg.DataCap, expectedCap *capExpectation) {
+	h.createAllocation(rt, a, tutil.NewIDAddr(h.t, 1000), dealSize, rt.Epoch()+builtin.EpochsInDay)
 
 	clientIdAddr, found := rt.GetIdAddr(a)
 	require.True(h.t, found)
@@ -889,6 +1311,95 @@ func (h *verifRegActorTestHarness) restoreBytes(rt *mock.Runtime, a address.Addr
 	assert.EqualValues(h.t, expectedCap.expectedCap, h.getClientCap(rt, clientIdAddr))
 }
 
+func (h *verifRegActorTestHarness) restoreBytesBatch(rt *mock.Runtime, restorations ...verifreg.RestoreBytesParams) *verifreg.RestoreBytesBatchReturn {
+	rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+	rt.SetCaller(builtin.StorageMarketActorAddr, builtin.StorageMarketActorCodeID)
+
+	ret := rt.Call(h.RestoreBytesBatch, &verifreg.RestoreBytesBatchParams{Restorations: restorations}).(*verifreg.RestoreBytesBatchReturn)
+	rt.Verify()
+	return ret
+}
+
+func (h *verifRegActorTestHarness) recordActivatedBytes(rt *mock.Runtime, activations ...verifreg.ActivatedBytes) {
+	rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+	rt.SetCaller(builtin.StorageMarketActorAddr, builtin.StorageMarketActorCodeID)
+
+	ret := rt.Call(h.RecordActivatedBytes, &verifreg.RecordActivatedBytesParams{Activations: activations})
+	rt.Verify()
+	assert.Nil(h.t, ret)
+}
+
+func (h *verifRegActorTestHarness) createAllocation(rt *mock.Runtime, client, provider address.Address, size verifreg.DataCap,
+	expiration abi.ChainEpoch) verifreg.AllocationID {
+	rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+	rt.SetCaller(builtin.StorageMarketActorAddr, builtin.StorageMarketActorCodeID)
+
+	ret := rt.Call(h.CreateAllocation, &verifreg.CreateAllocationParams{
+		Client:     client,
+		Provider:   provider,
+		Size:       size,
+		Expiration: expiration,
+	}).(*verifreg.CreateAllocationReturn)
+	rt.Verify()
+	return ret.AllocationID
+}
+
+func (h *verifRegActorTestHarness) claimAllocations(rt *mock.Runtime, provider address.Address, sector abi.SectorNumber, ids ...verifreg.AllocationID) {
+	rt.ExpectValidateCallerAddr(builtin.StorageMarketActorAddr)
+	rt.SetCaller(builtin.StorageMarketActorAddr, builtin.StorageMarketActorCodeID)
+
+	ret := rt.Call(h.ClaimAllocations, &verifreg.ClaimAllocationsParams{Provider: provider, Sector: sector, AllocationIDs: ids})
+	rt.Verify()
+	assert.Nil(h.t, ret)
+}
+
+func (h *verifRegActorTestHarness) removeExpiredAllocations(rt *mock.Runtime, client address.Address, ids ...verifreg.AllocationID) verifreg.DataCap {
+	rt.ExpectValidateCallerAny()
+	rt.SetCaller(client, builtin.AccountActorCodeID)
+
+	ret := rt.Call(h.RemoveExpiredAllocations, &verifreg.RemoveExpiredAllocationsParams{AllocationIDs: ids}).(*verifreg.RemoveExpiredAllocationsReturn)
+	rt.Verify()
+	return ret.DataCapRecovered
+}
+
+func (h *verifRegActorTestHarness) removeSectorClaims(rt *mock.Runtime, provider address.Address, sectors ...uint64) {
+	rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	ret := rt.Call(h.RemoveSectorClaims, &verifreg.RemoveSectorClaimsParams{Sectors: bitfield.NewFromSet(sectors)})
+	rt.Verify()
+	assert.Nil(h.t, ret)
+}
+
+func (h *verifRegActorTestHarness) getClientAllocations(rt *mock.Runtime, client address.Address) []verifreg.AllocationID {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.GetClientAllocations, &client).(*verifreg.GetClientAllocationsReturn)
+	rt.Verify()
+
+	var ids []verifreg.AllocationID
+	err := ret.AllocationIDs.ForEach(func(id uint64) error {
+		ids = append(ids, verifreg.AllocationID(id))
+		return nil
+	})
+	require.NoError(h.t, err)
+	return ids
+}
+
+func (h *verifRegActorTestHarness) verifySectorClaims(rt *mock.Runtime, provider address.Address, sectors ...verifreg.SectorClaims) []verifreg.DataCap {
+	rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	ret := rt.Call(h.VerifySectorClaims, &verifreg.VerifySectorClaimsParams{Sectors: sectors}).(*verifreg.VerifySectorClaimsReturn)
+	rt.Verify()
+	return ret.VerifiedSpace
+}
+
+func (h *verifRegActorTestHarness) assertUsage(rt *mock.Runtime, a address.Address, clientUsage, providerUsage verifreg.DataCap) {
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.DataCapUsage, &a).(*verifreg.DataCapUsageReturn)
+	rt.Verify()
+	assert.Equal(h.t, clientUsage, ret.ClientUsage)
+	assert.Equal(h.t, providerUsage, ret.ProviderUsage)
+}
+
 func (h *verifRegActorTestHarness) getVerifierCap(rt *mock.Runtime, a address.Address) verifreg.DataCap {
 	var st verifreg.State
 	rt.GetState(&st)
@@ -0,0 +1,345 @@
+package nv16
+
+import (
+	"context"
+	"unicode/utf8"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	market7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	market8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
+	adt8 "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+type marketMigrator struct{}
+
+func (m marketMigrator) migrateState(ctx context.Context, store cbor.IpldStore, in actorMigrationInput) (*actorMigrationResult, error) {
+	var inState market7.State
+	if err := store.Get(ctx, in.head, &inState); err != nil {
+		return nil, err
+	}
+
+	proposals, pendingProposals, err := migrateDealProposals(ctx, store, inState.Proposals, inState.PendingProposals)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to migrate deal proposals: %w", err)
+	}
+	states, err := migrateDealStates(ctx, store, inState.States)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to migrate deal states: %w", err)
+	}
+	activeDeals, activeDealIndexes, maxPieceSize, err := indexActiveDeals(ctx, store, inState.States, inState.Proposals)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to index active deals: %w", err)
+	}
+	dealOps, err := migrateDealOps(ctx, store, inState.DealOpsByEpoch, inState.Proposals)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to migrate deal ops: %w", err)
+	}
+
+	emptyProviderAsks, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty provider asks map: %w", err)
+	}
+	emptyRevokedProposals, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty revoked proposals map: %w", err)
+	}
+	emptyClientProposals, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty client proposals map: %w", err)
+	}
+	emptyLabelIndex, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty label index map: %w", err)
+	}
+	emptyEscrowFunders, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty escrow funders map: %w", err)
+	}
+	emptyDealAllocations, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty deal allocations map: %w", err)
+	}
+	emptyRetrievalViolations, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty retrieval violations map: %w", err)
+	}
+	emptySupersededProposals, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty superseded proposals map: %w", err)
+	}
+	emptyBreachedDeals, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty breached deals set: %w", err)
+	}
+
+	outState := market8.State{
+		Proposals:                     proposals,
+		States:                        states,
+		PendingProposals:              pendingProposals,
+		EscrowTable:                   inState.EscrowTable,
+		LockedTable:                   inState.LockedTable,
+		NextID:                        inState.NextID,
+		DealOpsByEpoch:                dealOps,
+		LastCron:                      inState.LastCron,
+		TotalClientLockedCollateral:   inState.TotalClientLockedCollateral,
+		TotalProviderLockedCollateral: inState.TotalProviderLockedCollateral,
+		TotalClientStorageFee:         inState.TotalClientStorageFee,
+		ProviderAsks:                  emptyProviderAsks,
+		RevokedProposals:              emptyRevokedProposals,
+		ClientProposals:               emptyClientProposals,
+		LabelIndex:                    emptyLabelIndex,
+		EscrowFunders:                 emptyEscrowFunders,
+		BreachedDeals:                 emptyBreachedDeals,
+		DealAllocations:               emptyDealAllocations,
+		RetrievalViolations:           emptyRetrievalViolations,
+		SupersededProposals:           emptySupersededProposals,
+		ActiveDeals:                   activeDeals,
+		ActiveDealIndexes:             activeDealIndexes,
+		ActiveDealsMaxPieceSize:       maxPieceSize,
+	}
+
+	newHead, err := store.Put(ctx, &outState)
+	return &actorMigrationResult{
+		newCodeCID: m.migratedCodeCID(),
+		newHead:    newHead,
+	}, err
+}
+
+// Rewrites the proposals whose labels are not valid UTF-8 with bytes labels, since v8 only reads valid UTF-8 as
+// a string label. Other proposals serialize identically in v8 and are left as they are.
+// A rewritten proposal's CID changes, so any entry for it in the pending proposals set is replaced.
+func migrateDealProposals(ctx context.Context, store cbor.IpldStore, proposalsRoot, pendingRoot cid.Cid) (cid.Cid, cid.Cid, error) {
+	adtStore := adt8.WrapStore(ctx, store)
+	inProposals, err := market7.AsDealProposalArray(adtStore, proposalsRoot)
+	if err != nil {
+		return cid.Undef, cid.Undef, xerrors.Errorf("failed to load proposals: %w", err)
+	}
+	outProposals, err := market8.AsDealProposalArray(adtStore, proposalsRoot)
+	if err != nil {
+		return cid.Undef, cid.Undef, xerrors.Errorf("failed to load proposals: %w", err)
+	}
+	pending, err := adt8.AsSet(adtStore, pendingRoot, builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, cid.Undef, xerrors.Errorf("failed to load pending proposals: %w", err)
+	}
+
+	var inProposal market7.DealProposal
+	err = inProposals.ForEach(&inProposal, func(key int64) error {
+		if utf8.ValidString(inProposal.Label) {
+			return nil
+		}
+		outProposal := market8.DealProposal{
+			PieceCID:             inProposal.PieceCID,
+			PieceSize:            inProposal.PieceSize,
+			VerifiedDeal:         inProposal.VerifiedDeal,
+			Client:               inProposal.Client,
+			Provider:             inProposal.Provider,
+			Label:                market8.NewLabelFromBytes([]byte(inProposal.Label)),
+			StartEpoch:           inProposal.StartEpoch,
+			EndEpoch:             inProposal.EndEpoch,
+			StoragePricePerEpoch: inProposal.StoragePricePerEpoch,
+			ProviderCollateral:   inProposal.ProviderCollateral,
+			ClientCollateral:     inProposal.ClientCollateral,
+		}
+		if err := outProposals.Set(abi.DealID(key), &outProposal); err != nil {
+			return xerrors.Errorf("failed to set proposal %d: %w", key, err)
+		}
+
+		inCid, err := inProposal.Cid()
+		if err != nil {
+			return xerrors.Errorf("failed to compute CID of proposal %d: %w", key, err)
+		}
+		wasPending, err := pending.Has(abi.CidKey(inCid))
+		if err != nil {
+			return xerrors.Errorf("failed to check pending proposal %d: %w", key, err)
+		}
+		if !wasPending {
+			return nil
+		}
+		outCid, err := outProposal.Cid()
+		if err != nil {
+			return xerrors.Errorf("failed to compute CID of migrated proposal %d: %w", key, err)
+		}
+		if err := pending.Delete(abi.CidKey(inCid)); err != nil {
+			return xerrors.Errorf("failed to delete pending proposal %d: %w", key, err)
+		}
+		return pending.Put(abi.CidKey(outCid))
+	})
+	if err != nil {
+		return cid.Undef, cid.Undef, err
+	}
+
+	outProposalsRoot, err := outProposals.Root()
+	if err != nil {
+		return cid.Undef, cid.Undef, xerrors.Errorf("failed to flush proposals: %w", err)
+	}
+	outPendingRoot, err := pending.Root()
+	if err != nil {
+		return cid.Undef, cid.Undef, xerrors.Errorf("failed to flush pending proposals: %w", err)
+	}
+	return outProposalsRoot, outPendingRoot, nil
+}
+
+// Rewrites the deal states with the sector number that v8 records on activation.
+// The sectors of deals activated before v8 are not known to the market, so are recorded as unknown.
+func migrateDealStates(ctx context.Context, store cbor.IpldStore, statesRoot cid.Cid) (cid.Cid, error) {
+	adtStore := adt8.WrapStore(ctx, store)
+	inStates, err := market7.AsDealStateArray(adtStore, statesRoot)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+	outArray, err := adt8.MakeEmptyArray(adtStore, market8.StatesAmtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to construct deal states: %w", err)
+	}
+	outStates := market8.DealMetaArray{Array: outArray}
+
+	var inState market7.DealState
+	err = inStates.ForEach(&inState, func(key int64) error {
+		return outStates.Set(abi.DealID(key), &market8.DealState{
+			SectorStartEpoch: inState.SectorStartEpoch,
+			LastUpdatedEpoch: inState.LastUpdatedEpoch,
+			SlashEpoch:       inState.SlashEpoch,
+			SectorNumber:     market8.DealSectorNumberUnknown,
+		})
+	})
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to migrate deal states: %w", err)
+	}
+	return outStates.Root()
+}
+
+// Indexes every deal with a state as active, in deal ID order, as v8 indexes deals on activation for audit sampling.
+// The piece sizes are read from the v7 proposals, whose piece sizes the migration leaves unchanged.
+func indexActiveDeals(ctx context.Context, store cbor.IpldStore, statesRoot, proposalsRoot cid.Cid) (cid.Cid, cid.Cid, abi.PaddedPieceSize, error) {
+	adtStore := adt8.WrapStore(ctx, store)
+	states, err := market7.AsDealStateArray(adtStore, statesRoot)
+	if err != nil {
+		return cid.Undef, cid.Undef, 0, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+	proposals, err := market7.AsDealProposalArray(adtStore, proposalsRoot)
+	if err != nil {
+		return cid.Undef, cid.Undef, 0, xerrors.Errorf("failed to load proposals: %w", err)
+	}
+	activeDeals, err := adt8.MakeEmptyArray(adtStore, market8.ActiveDealsAmtBitwidth)
+	if err != nil {
+		return cid.Undef, cid.Undef, 0, xerrors.Errorf("failed to construct active deals: %w", err)
+	}
+	indexes, err := adt8.MakeEmptyMap(adtStore, builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, cid.Undef, 0, xerrors.Errorf("failed to construct active deal indexes: %w", err)
+	}
+
+	maxPieceSize := abi.PaddedPieceSize(0)
+	var state market7.DealState
+	err = states.ForEach(&state, func(key int64) error {
+		proposal, found, err := proposals.Get(abi.DealID(key))
+		if err != nil {
+			return xerrors.Errorf("failed to load proposal %d: %w", key, err)
+		}
+		if !found {
+			return xerrors.Errorf("deal state %d for missing proposal", key)
+		}
+		index := cbg.CborInt(activeDeals.Length())
+		dealID := cbg.CborInt(key)
+		if err := activeDeals.Set(uint64(index), &dealID); err != nil {
+			return xerrors.Errorf("failed to index active deal %d: %w", key, err)
+		}
+		if err := indexes.Put(abi.UIntKey(uint64(key)), &index); err != nil {
+			return xerrors.Errorf("failed to record index of active deal %d: %w", key, err)
+		}
+		if proposal.PieceSize > maxPieceSize {
+			maxPieceSize = proposal.PieceSize
+		}
+		return nil
+	})
+	if err != nil {
+		return cid.Undef, cid.Undef, 0, err
+	}
+
+	activeRoot, err := activeDeals.Root()
+	if err != nil {
+		return cid.Undef, cid.Undef, 0, xerrors.Errorf("failed to flush active deals: %w", err)
+	}
+	indexesRoot, err := indexes.Root()
+	if err != nil {
+		return cid.Undef, cid.Undef, 0, xerrors.Errorf("failed to flush active deal indexes: %w", err)
+	}
+	return activeRoot, indexesRoot, maxPieceSize, nil
+}
+
+// Regroups the deal ops of each epoch by the provider of their deals, as v8 schedules them.
+// The providers are read from the v7 proposals, whose providers the migration leaves unchanged.
+func migrateDealOps(ctx context.Context, store cbor.IpldStore, opsRoot, proposalsRoot cid.Cid) (cid.Cid, error) {
+	adtStore := adt8.WrapStore(ctx, store)
+	inOps, err := adt8.AsMap(adtStore, opsRoot, builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load deal ops: %w", err)
+	}
+	proposals, err := market7.AsDealProposalArray(adtStore, proposalsRoot)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load proposals: %w", err)
+	}
+	outOps, err := market8.MakeEmptyProviderSetMultimap(adtStore, builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to construct deal ops: %w", err)
+	}
+
+	var setRoot cbg.CborCid
+	err = inOps.ForEach(&setRoot, func(k string) error {
+		epoch, err := abi.ParseUIntKey(k)
+		if err != nil {
+			return xerrors.Errorf("deal ops has key that is not an int: %x: %w", k, err)
+		}
+		set, err := adt8.AsSet(adtStore, cid.Cid(setRoot), builtin8.DefaultHamtBitwidth)
+		if err != nil {
+			return xerrors.Errorf("failed to load deal ops for epoch %d: %w", epoch, err)
+		}
+
+		// Group the epoch's deals by provider, keeping providers in the order first seen.
+		var providers []addr.Address
+		deals := make(map[addr.Address][]abi.DealID)
+		err = set.ForEach(func(dk string) error {
+			id, err := abi.ParseUIntKey(dk)
+			if err != nil {
+				return xerrors.Errorf("deal ops has deal key that is not an int: %x: %w", dk, err)
+			}
+			proposal, found, err := proposals.Get(abi.DealID(id))
+			if err != nil {
+				return xerrors.Errorf("failed to load proposal %d: %w", id, err)
+			}
+			if !found {
+				return xerrors.Errorf("deal op at epoch %d for missing proposal %d", epoch, id)
+			}
+			if _, ok := deals[proposal.Provider]; !ok {
+				providers = append(providers, proposal.Provider)
+			}
+			deals[proposal.Provider] = append(deals[proposal.Provider], abi.DealID(id))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, provider := range providers {
+			if err := outOps.PutMany(abi.ChainEpoch(epoch), provider, deals[provider]); err != nil {
+				return xerrors.Errorf("failed to set deal ops for epoch %d provider %v: %w", epoch, provider, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+	return outOps.Root()
+}
+
+func (m marketMigrator) migratedCodeCID() cid.Cid {
+	return builtin8.StorageMarketActorCodeID
+}
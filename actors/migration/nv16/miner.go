@@ -0,0 +1,198 @@
+package nv16
+
+import (
+	"context"
+
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	miner8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/miner"
+	adt8 "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/big"
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+)
+
+// The miner state gains an empty queue of recoveries, an empty set of proven pre-commitments
+// (confirmation of a proof never spans a migration), no owner settings and no deadlines recorded as
+// empty (deadline cron records them as their challenge windows end, so none are skipped before then),
+// the owner becomes the miner's beneficiary, optimistically accepted Window PoSts are re-recorded
+// without a chain commit epoch, and each miner's pledge and faulty power are accumulated for the
+// power actor migration.
+type minerMigrator struct {
+	totals *minerTotals
+}
+
+func (m minerMigrator) migrateState(ctx context.Context, store cbor.IpldStore, in actorMigrationInput) (*actorMigrationResult, error) {
+	var inState miner7.State
+	if err := store.Get(ctx, in.head, &inState); err != nil {
+		return nil, err
+	}
+	info, err := migrateInfo(ctx, store, inState.Info)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to migrate miner info: %w", err)
+	}
+
+	deadlines, faultyPower, err := migrateDeadlines(ctx, store, inState.Deadlines)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to migrate deadlines: %w", err)
+	}
+	m.totals.add(&inState, faultyPower.QA)
+
+	outState := miner8.State{
+		Info:                       info,
+		PreCommitDeposits:          inState.PreCommitDeposits,
+		LockedFunds:                inState.LockedFunds,
+		VestingFunds:               inState.VestingFunds,
+		FeeDebt:                    inState.FeeDebt,
+		InitialPledge:              inState.InitialPledge,
+		PreCommittedSectors:        inState.PreCommittedSectors,
+		PreCommittedSectorsCleanUp: inState.PreCommittedSectorsCleanUp,
+		AllocatedSectors:           inState.AllocatedSectors,
+		Sectors:                    inState.Sectors,
+		ProvingPeriodStart:         inState.ProvingPeriodStart,
+		CurrentDeadline:            inState.CurrentDeadline,
+		Deadlines:                  deadlines,
+		EarlyTerminations:          inState.EarlyTerminations,
+		DeadlineCronActive:         inState.DeadlineCronActive,
+		QueuedRecoveries:           nil,
+		ProvenPreCommits:           bitfield.New(),
+		ProvenPreCommitsEpoch:      -1,
+		OwnerSettings:              nil,
+		PoStRelayNonce:             0,
+		SectorManifests:            nil,
+		EmptyDeadlines:             bitfield.New(), // Recorded by deadline cron as each challenge window ends.
+		MaintenanceWindow:          nil,
+		DroppedCronEvents:          0,
+		FaultStreakSectors:         bitfield.New(),
+		FaultStreakStarts:          nil,
+		ReplicaUpdateRollbacks:     nil,
+		ControlChanges:             nil,
+	}
+
+	newHead, err := store.Put(ctx, &outState)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to put new state: %w", err)
+	}
+
+	return &actorMigrationResult{
+		newCodeCID: m.migratedCodeCID(),
+		newHead:    newHead,
+	}, nil
+}
+
+func (m minerMigrator) migratedCodeCID() cid.Cid {
+	return builtin8.StorageMinerActorCodeID
+}
+
+// Rewrites the miner info with the owner as beneficiary, under an empty term,
+// and any pending worker key change as the only entry of the pending worker keys.
+func migrateInfo(ctx context.Context, store cbor.IpldStore, root cid.Cid) (cid.Cid, error) {
+	var inInfo miner7.MinerInfo
+	if err := store.Get(ctx, root, &inInfo); err != nil {
+		return cid.Undef, err
+	}
+	var pendingWorkerKeys *cid.Cid
+	if inInfo.PendingWorkerKey != nil {
+		changes, err := adt8.MakeEmptyArray(adt8.WrapStore(ctx, store), miner8.PendingWorkerKeysAmtBitwidth)
+		if err != nil {
+			return cid.Undef, err
+		}
+		change := miner8.WorkerKeyChange(*inInfo.PendingWorkerKey)
+		if err := changes.Set(uint64(change.EffectiveAt), &change); err != nil {
+			return cid.Undef, xerrors.Errorf("failed to set pending worker key: %w", err)
+		}
+		changesRoot, err := changes.Root()
+		if err != nil {
+			return cid.Undef, err
+		}
+		pendingWorkerKeys = &changesRoot
+	}
+	return store.Put(ctx, &miner8.MinerInfo{
+		Owner:                      inInfo.Owner,
+		Worker:                     inInfo.Worker,
+		ControlAddresses:           inInfo.ControlAddresses,
+		PendingWorkerKeys:          pendingWorkerKeys,
+		PeerId:                     inInfo.PeerId,
+		Multiaddrs:                 inInfo.Multiaddrs,
+		WindowPoStProofType:        inInfo.WindowPoStProofType,
+		SectorSize:                 inInfo.SectorSize,
+		WindowPoStPartitionSectors: inInfo.WindowPoStPartitionSectors,
+		ConsensusFaultElapsed:      inInfo.ConsensusFaultElapsed,
+		PendingOwnerAddress:        inInfo.PendingOwnerAddress,
+		Beneficiary:                inInfo.Owner,
+		BeneficiaryTerm:            miner8.BeneficiaryTerm{Quota: big.Zero(), UsedQuota: big.Zero(), Expiration: 0},
+		PendingBeneficiaryTerm:     nil,
+	})
+}
+
+// Rewrites each deadline's optimistically accepted Window PoSts, and their snapshots, in the v8 form.
+// The deadline structures are otherwise unchanged.
+// Returns the new deadlines root and the sum of the deadlines' faulty power.
+func migrateDeadlines(ctx context.Context, store cbor.IpldStore, root cid.Cid) (cid.Cid, miner7.PowerPair, error) {
+	faultyPower := miner7.NewPowerPairZero()
+	var deadlines miner7.Deadlines
+	if err := store.Get(ctx, root, &deadlines); err != nil {
+		return cid.Undef, faultyPower, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+
+	for dlIdx, dlCid := range deadlines.Due {
+		var deadline miner7.Deadline
+		if err := store.Get(ctx, dlCid, &deadline); err != nil {
+			return cid.Undef, faultyPower, xerrors.Errorf("failed to load deadline %d: %w", dlIdx, err)
+		}
+		faultyPower = faultyPower.Add(deadline.FaultyPower)
+
+		posts, err := migratePoStSubmissions(ctx, store, deadline.OptimisticPoStSubmissions)
+		if err != nil {
+			return cid.Undef, faultyPower, xerrors.Errorf("failed to migrate proofs of deadline %d: %w", dlIdx, err)
+		}
+		postsSnapshot, err := migratePoStSubmissions(ctx, store, deadline.OptimisticPoStSubmissionsSnapshot)
+		if err != nil {
+			return cid.Undef, faultyPower, xerrors.Errorf("failed to migrate proofs snapshot of deadline %d: %w", dlIdx, err)
+		}
+		if posts == deadline.OptimisticPoStSubmissions && postsSnapshot == deadline.OptimisticPoStSubmissionsSnapshot {
+			continue
+		}
+
+		deadline.OptimisticPoStSubmissions = posts
+		deadline.OptimisticPoStSubmissionsSnapshot = postsSnapshot
+		if deadlines.Due[dlIdx], err = store.Put(ctx, &deadline); err != nil {
+			return cid.Undef, faultyPower, xerrors.Errorf("failed to put deadline %d: %w", dlIdx, err)
+		}
+	}
+	newRoot, err := store.Put(ctx, &deadlines)
+	return newRoot, faultyPower, err
+}
+
+// Re-records Window PoSts without their chain commit epochs, which were not tracked by v7,
+// preserving their indexes. An empty array is returned unchanged.
+func migratePoStSubmissions(ctx context.Context, store cbor.IpldStore, root cid.Cid) (cid.Cid, error) {
+	adtStore := adt8.WrapStore(ctx, store)
+	inArray, err := adt8.AsArray(adtStore, root, miner7.DeadlineOptimisticPoStSubmissionsAmtBitwidth)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if inArray.Length() == 0 {
+		return root, nil
+	}
+
+	outArray, err := adt8.MakeEmptyArray(adtStore, miner8.DeadlineOptimisticPoStSubmissionsAmtBitwidth)
+	if err != nil {
+		return cid.Undef, err
+	}
+	var inPost miner7.WindowedPoSt
+	err = inArray.ForEach(&inPost, func(idx int64) error {
+		return outArray.Set(uint64(idx), &miner8.WindowedPoSt{
+			Partitions:       inPost.Partitions,
+			Proofs:           inPost.Proofs,
+			ChainCommitEpoch: miner8.NoChainCommitEpoch,
+		})
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+	return outArray.Root()
+}
@@ -0,0 +1,147 @@
+package nv16
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	power7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	power8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/power"
+	adt8 "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	smoothing8 "github.com/filecoin-project/specs-actors/v8/actors/util/smoothing"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+)
+
+// Sums of pledge and faulty power held by all miners, accumulated concurrently by miner migrations.
+type minerTotals struct {
+	lk                sync.Mutex
+	initialPledge     abi.TokenAmount
+	preCommitDeposits abi.TokenAmount
+	lockedFunds       abi.TokenAmount
+	faultyQAPower     abi.StoragePower
+}
+
+func newMinerTotals() *minerTotals {
+	return &minerTotals{
+		initialPledge:     big.Zero(),
+		preCommitDeposits: big.Zero(),
+		lockedFunds:       big.Zero(),
+		faultyQAPower:     big.Zero(),
+	}
+}
+
+func (p *minerTotals) add(st *miner7.State, faultyQAPower abi.StoragePower) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.initialPledge = big.Add(p.initialPledge, st.InitialPledge)
+	p.preCommitDeposits = big.Add(p.preCommitDeposits, st.PreCommitDeposits)
+	p.lockedFunds = big.Add(p.lockedFunds, st.LockedFunds)
+	p.faultyQAPower = big.Add(p.faultyQAPower, faultyQAPower)
+}
+
+// The power actor migration is deferred until all miners have been migrated,
+// so that the pledge breakdown and faulty power can be initialized from the accumulated totals.
+// The smoothed fault rate starts at the network's current fault rate.
+// No fault streaks or cron failures are known at migration, so all miners start with none.
+// Power checkpoints start empty, with the first taken at the next cron tick in a new interval.
+// The cached reward estimate is taken from the reward actor's state prior to migration.
+// The upgrade must fall between epochs, after cron has verified the epoch's proofs, so no proofs
+// may be awaiting verification, and the batch is left unset.
+type powerMigrator struct {
+	totals         *minerTotals
+	rewardSmoothed smoothing8.FilterEstimate
+}
+
+func (m powerMigrator) migrateState(ctx context.Context, store cbor.IpldStore, in actorMigrationInput) (*actorMigrationResult, error) {
+	var inState power7.State
+	if err := store.Get(ctx, in.head, &inState); err != nil {
+		return nil, err
+	}
+
+	emptyMap, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty map: %w", err)
+	}
+	emptyCheckpoints, err := adt8.StoreEmptyArray(adt8.WrapStore(ctx, store), power8.PowerCheckpointsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty power checkpoints: %w", err)
+	}
+
+	if inState.ProofValidationBatch != nil {
+		if err := checkProofValidationBatchEmpty(ctx, store, *inState.ProofValidationBatch); err != nil {
+			return nil, err
+		}
+	}
+
+	m.totals.lk.Lock()
+	defer m.totals.lk.Unlock()
+
+	outState := power8.State{
+		TotalRawBytePower:         inState.TotalRawBytePower,
+		TotalBytesCommitted:       inState.TotalBytesCommitted,
+		TotalQualityAdjPower:      inState.TotalQualityAdjPower,
+		TotalQABytesCommitted:     inState.TotalQABytesCommitted,
+		TotalPledgeCollateral:     inState.TotalPledgeCollateral,
+		TotalInitialPledge:        m.totals.initialPledge,
+		TotalPreCommitDeposits:    m.totals.preCommitDeposits,
+		TotalLockedRewards:        m.totals.lockedFunds,
+		TotalFaultyQAPower:        m.totals.faultyQAPower,
+		ThisEpochRawBytePower:     inState.ThisEpochRawBytePower,
+		ThisEpochQualityAdjPower:  inState.ThisEpochQualityAdjPower,
+		ThisEpochPledgeCollateral: inState.ThisEpochPledgeCollateral,
+		ThisEpochQAPowerSmoothed:  smoothing8.FilterEstimate(inState.ThisEpochQAPowerSmoothed),
+		ThisEpochRewardSmoothed:   m.rewardSmoothed,
+		MinerCount:                inState.MinerCount,
+		MinerAboveMinPowerCount:   inState.MinerAboveMinPowerCount,
+		CronEventQueue:            inState.CronEventQueue,
+		FirstCronEpoch:            inState.FirstCronEpoch,
+		Claims:                    inState.Claims,
+		ClaimsSnapshot:            inState.Claims,
+		ClaimsSnapshotEpoch:       in.priorEpoch,
+		FaultStreaks:              emptyMap,
+		CronFailures:              emptyMap,
+		PowerCheckpoints:          emptyCheckpoints,
+	}
+	outState.FaultRateSmoothed = smoothing8.NewEstimate(power8.CurrentFaultRate(&outState), big.Zero())
+
+	newHead, err := store.Put(ctx, &outState)
+	if err != nil {
+		return nil, err
+	}
+
+	return &actorMigrationResult{
+		newCodeCID: m.migratedCodeCID(),
+		newHead:    newHead,
+	}, nil
+}
+
+// Checks that no proofs are awaiting verification. The v7 batch holds seal verify infos, which can't
+// be read as the v8 entries that also carry a priority fee.
+func checkProofValidationBatchEmpty(ctx context.Context, store cbor.IpldStore, batchRoot cid.Cid) error {
+	batch, err := adt8.AsMultimap(adt8.WrapStore(ctx, store), batchRoot, builtin8.DefaultHamtBitwidth, power7.ProofValidationBatchAmtBitwidth)
+	if err != nil {
+		return xerrors.Errorf("failed to load proof validation batch: %w", err)
+	}
+	var pending uint64
+	err = batch.ForAll(func(k string, arr *adt8.Array) error {
+		pending += arr.Length()
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to count proof validation batch: %w", err)
+	}
+	if pending > 0 {
+		return xerrors.Errorf("%d proofs awaiting verification at upgrade, expected none", pending)
+	}
+	return nil
+}
+
+func (m powerMigrator) migratedCodeCID() cid.Cid {
+	return builtin8.StoragePowerActorCodeID
+}
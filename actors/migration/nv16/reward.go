@@ -0,0 +1,48 @@
+package nv16
+
+import (
+	"context"
+
+	reward7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	reward8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/reward"
+	smoothing8 "github.com/filecoin-project/specs-actors/v8/actors/util/smoothing"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+)
+
+type rewardMigrator struct{}
+
+func (m rewardMigrator) migrateState(ctx context.Context, store cbor.IpldStore, in actorMigrationInput) (*actorMigrationResult, error) {
+	var inState reward7.State
+	if err := store.Get(ctx, in.head, &inState); err != nil {
+		return nil, err
+	}
+
+	outState := reward8.State{
+		CumsumBaseline:          inState.CumsumBaseline,
+		CumsumRealized:          inState.CumsumRealized,
+		EffectiveNetworkTime:    inState.EffectiveNetworkTime,
+		EffectiveBaselinePower:  inState.EffectiveBaselinePower,
+		ThisEpochReward:         inState.ThisEpochReward,
+		ThisEpochRewardSmoothed: smoothing8.FilterEstimate(inState.ThisEpochRewardSmoothed),
+		ThisEpochBaselinePower:  inState.ThisEpochBaselinePower,
+		Epoch:                   inState.Epoch,
+		TotalStoragePowerReward: inState.TotalStoragePowerReward,
+		SimpleTotal:             inState.SimpleTotal,
+		BaselineTotal:           inState.BaselineTotal,
+		MintingPausedEpoch:      reward8.NoMintingPause,
+		PausedEpochs:            0,
+	}
+
+	newHead, err := store.Put(ctx, &outState)
+	return &actorMigrationResult{
+		newCodeCID: m.migratedCodeCID(),
+		newHead:    newHead,
+	}, err
+}
+
+func (m rewardMigrator) migratedCodeCID() cid.Cid {
+	return builtin8.RewardActorCodeID
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	market7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	vm7 "github.com/filecoin-project/specs-actors/v7/support/vm"
+
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	market8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
+	adt8 "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+	"github.com/filecoin-project/specs-actors/v8/support/vm7Util"
+)
+
+// Publishes deals with labels that are and are not valid UTF-8 against v7 actors, and checks that
+// the migration keeps the valid labels as strings and turns the others into bytes.
+func TestNv16MigrationDealLabels(t *testing.T) {
+	ctx := context.Background()
+	bs := ipld.NewBlockStoreInMemory()
+	v := vm7.NewVMWithSingletons(ctx, t, bs)
+	v = vm7Util.AdvanceToEpochWithCron(t, v, 200)
+
+	minerInfos := createMiners(t, ctx, v, 1)
+	worker, minerAddr := minerInfos[0].WorkerAddress, minerInfos[0].MinerAddress
+	vm7.ApplyOk(t, v, worker, builtin7.StorageMarketActorAddr, big.Mul(big.NewInt(6), vm7.FIL), builtin7.MethodsMarket.AddBalance, &worker)
+	vm7.ApplyOk(t, v, worker, builtin7.StorageMarketActorAddr, big.Mul(big.NewInt(64), vm7.FIL), builtin7.MethodsMarket.AddBalance, &minerAddr)
+
+	dealStart := v.GetEpoch() + miner7.PreCommitChallengeDelay + 10*miner7.WPoStChallengeWindow
+	stringLabel := "utf8-label"
+	bytesLabel := string([]byte{0xff, 0xfe, 0xfd})
+	stringDeal := vm7Util.PublishDeal(t, v, worker, worker, minerAddr, stringLabel, 32<<30, false, dealStart, 180*builtin7.EpochsInDay).IDs[0]
+	bytesDeal := vm7Util.PublishDeal(t, v, worker, worker, minerAddr, bytesLabel, 32<<30, false, dealStart, 180*builtin7.EpochsInDay).IDs[0]
+	bytesCidV7 := dealProposalCidV7(t, v, bytesDeal)
+
+	v8 := vm7Util.MigrateToV8(t, v)
+
+	var st market8.State
+	require.NoError(t, v8.GetState(builtin8.StorageMarketActorAddr, &st))
+	proposals, err := market8.AsDealProposalArray(v8.Store(), st.Proposals)
+	require.NoError(t, err)
+	pending, err := adt8.AsSet(v8.Store(), st.PendingProposals, builtin8.DefaultHamtBitwidth)
+	require.NoError(t, err)
+
+	proposal, found, err := proposals.Get(stringDeal)
+	require.NoError(t, err)
+	require.True(t, found)
+	label, err := proposal.Label.ToString()
+	require.NoError(t, err)
+	require.Equal(t, stringLabel, label)
+	requirePending(t, pending, proposal)
+
+	proposal, found, err = proposals.Get(bytesDeal)
+	require.NoError(t, err)
+	require.True(t, found)
+	raw, err := proposal.Label.ToBytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte(bytesLabel), raw)
+	requirePending(t, pending, proposal)
+
+	// The pending entry under the proposal's v7 CID is replaced.
+	has, err := pending.Has(abi.CidKey(bytesCidV7))
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+// Publishes deals for several providers against v7 actors, and checks that the migration schedules
+// the same deal ops at the same epochs, grouped by the provider of each deal.
+func TestNv16MigrationDealOps(t *testing.T) {
+	ctx := context.Background()
+	bs := ipld.NewBlockStoreInMemory()
+	v := vm7.NewVMWithSingletons(ctx, t, bs)
+	v = vm7Util.AdvanceToEpochWithCron(t, v, 200)
+
+	minerInfos := createMiners(t, ctx, v, 2)
+	dealStart := v.GetEpoch() + miner7.PreCommitChallengeDelay + 10*miner7.WPoStChallengeWindow
+	providers := map[abi.DealID]address.Address{}
+	for i, info := range minerInfos {
+		worker, minerAddr := info.WorkerAddress, info.MinerAddress
+		vm7.ApplyOk(t, v, worker, builtin7.StorageMarketActorAddr, big.Mul(big.NewInt(6), vm7.FIL), builtin7.MethodsMarket.AddBalance, &worker)
+		vm7.ApplyOk(t, v, worker, builtin7.StorageMarketActorAddr, big.Mul(big.NewInt(64), vm7.FIL), builtin7.MethodsMarket.AddBalance, &minerAddr)
+		for j := 0; j < 3; j++ {
+			label := fmt.Sprintf("deal-%d-%d", i, j)
+			id := vm7Util.PublishDeal(t, v, worker, worker, minerAddr, label, 1<<30, false, dealStart, 180*builtin7.EpochsInDay).IDs[0]
+			providers[id] = minerAddr
+		}
+	}
+
+	// Deals are first scheduled within an update interval of their start.
+	from, to := dealStart, dealStart+market7.DealUpdatesInterval
+	var stV7 market7.State
+	require.NoError(t, v.GetState(builtin7.StorageMarketActorAddr, &stV7))
+	opsV7, err := market7.AsSetMultimap(v.Store(), stV7.DealOpsByEpoch, builtin7.DefaultHamtBitwidth, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	expected := map[abi.DealID]abi.ChainEpoch{}
+	for epoch := from; epoch < to; epoch++ {
+		require.NoError(t, opsV7.ForEach(epoch, func(id abi.DealID) error {
+			expected[id] = epoch
+			return nil
+		}))
+	}
+	require.Len(t, expected, len(providers))
+
+	v8 := vm7Util.MigrateToV8(t, v)
+
+	var st market8.State
+	require.NoError(t, v8.GetState(builtin8.StorageMarketActorAddr, &st))
+	ops, err := market8.AsProviderSetMultimap(v8.Store(), st.DealOpsByEpoch, builtin8.DefaultHamtBitwidth, builtin8.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	actual := map[abi.DealID]abi.ChainEpoch{}
+	require.NoError(t, ops.ForEachInRange(from, to, func(epoch abi.ChainEpoch, provider address.Address, id abi.DealID) error {
+		require.Equal(t, providers[id], provider)
+		actual[id] = epoch
+		return nil
+	}))
+	require.Equal(t, expected, actual)
+}
+
+func dealProposalCidV7(t *testing.T, v *vm7.VM, dealID abi.DealID) cid.Cid {
+	var st market7.State
+	require.NoError(t, v.GetState(builtin7.StorageMarketActorAddr, &st))
+	proposals, err := market7.AsDealProposalArray(v.Store(), st.Proposals)
+	require.NoError(t, err)
+	proposal, found, err := proposals.Get(dealID)
+	require.NoError(t, err)
+	require.True(t, found)
+	c, err := proposal.Cid()
+	require.NoError(t, err)
+	return c
+}
+
+func requirePending(t *testing.T, pending *adt8.Set, proposal *market8.DealProposal) {
+	c, err := proposal.Cid()
+	require.NoError(t, err)
+	has, err := pending.Has(abi.CidKey(c))
+	require.NoError(t, err)
+	require.True(t, has)
+}
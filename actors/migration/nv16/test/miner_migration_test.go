@@ -212,6 +212,9 @@ func TestNv16Migration(t *testing.T) {
 		require.NoError(t, err)
 		newDeadlines, err := newMinerState.LoadDeadlines(ctxStore)
 		require.NoError(t, err)
+		emptyDeadlines, err := newMinerState.EmptyDeadlines.Count()
+		require.NoError(t, err)
+		require.Zero(t, emptyDeadlines)
 
 		for i := 0; uint64(i) < miner7.WPoStPeriodDeadlines; i++ {
 			oldDeadline, err := oldDeadlines.LoadDeadline(v.Store(), uint64(i))
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	vm7 "github.com/filecoin-project/specs-actors/v7/support/vm"
+
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	power8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v8/actors/migration/nv16"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+	"github.com/filecoin-project/specs-actors/v8/support/vm7Util"
+)
+
+// Prove-commits a sector against v7 actors, and checks that the migration refuses a state with the proof
+// still awaiting verification, then succeeds once cron has verified it.
+func TestNv16MigrationProofValidationBatch(t *testing.T) {
+	ctx := context.Background()
+	bs := ipld.NewBlockStoreInMemory()
+	v := vm7.NewVMWithSingletons(ctx, t, bs)
+	v = vm7Util.AdvanceToEpochWithCron(t, v, 200)
+
+	minerInfos := createMiners(t, ctx, v, 1)
+	worker, minerAddr := minerInfos[0].WorkerAddress, minerInfos[0].MinerAddress
+	precommit := vm7Util.PreCommitSectors(t, v, 1, 1, worker, minerAddr, sealProof, 100, true, v.GetEpoch()+miner7.MaxSectorExpirationExtension, nil)[0]
+	v = vm7Util.AdvanceToEpochWithCron(t, v, precommit.PreCommitEpoch+miner7.PreCommitChallengeDelay+1)
+
+	vm7.ApplyOk(t, v, worker, minerAddr, big.Zero(), builtin7.MethodsMiner.ProveCommitSector, &miner7.ProveCommitSectorParams{
+		SectorNumber: precommit.Info.SectorNumber,
+	})
+
+	log := nv16.TestLogger{TB: t}
+	_, err := nv16.MigrateStateTree(ctx, v.Store(), v.StateRoot(), v.GetEpoch(), nv16.Config{MaxWorkers: 1}, log, nv16.NewMemMigrationCache())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "proofs awaiting verification")
+
+	v = vm7Util.AdvanceOneEpochWithCron(t, v)
+	v8 := vm7Util.MigrateToV8(t, v)
+
+	var st power8.State
+	require.NoError(t, v8.GetState(builtin8.StoragePowerActorAddr, &st))
+	require.Nil(t, st.ProofValidationBatch)
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	market7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	vm7 "github.com/filecoin-project/specs-actors/v7/support/vm"
+
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	market8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v8/actors/states"
+	adt8 "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+	vm8 "github.com/filecoin-project/specs-actors/v8/support/vm"
+	"github.com/filecoin-project/specs-actors/v8/support/vm7Util"
+)
+
+// Runs a deal and its sectors against v7 actors, migrates, and checks that the
+// deal keeps paying and the sectors keep being proven against v8 actors.
+func TestNv16MigrationScenarioContinuity(t *testing.T) {
+	ctx := context.Background()
+	bs := ipld.NewBlockStoreInMemory()
+	v := vm7.NewVMWithSingletons(ctx, t, bs)
+	ctxStore := adt7.WrapBlockStore(ctx, bs)
+	v = vm7Util.AdvanceToEpochWithCron(t, v, 200)
+
+	minerInfos := createMiners(t, ctx, v, 1)
+	worker, minerAddr := minerInfos[0].WorkerAddress, minerInfos[0].MinerAddress
+
+	// Publish a deal starting shortly after its sector can be proven.
+	vm7.ApplyOk(t, v, worker, builtin7.StorageMarketActorAddr, big.Mul(big.NewInt(3), vm7.FIL), builtin7.MethodsMarket.AddBalance, &worker)
+	vm7.ApplyOk(t, v, worker, builtin7.StorageMarketActorAddr, big.Mul(big.NewInt(64), vm7.FIL), builtin7.MethodsMarket.AddBalance, &minerAddr)
+	dealStart := v.GetEpoch() + miner7.PreCommitChallengeDelay + 10*miner7.WPoStChallengeWindow
+	deals := vm7Util.PublishDeal(t, v, worker, worker, minerAddr, "continuity", 32<<30, false, dealStart, 180*builtin7.EpochsInDay)
+	dealID := deals.IDs[0]
+
+	// Pre-commit and prove enough sectors to aggregate, the first of which holds the deal.
+	precommits := vm7Util.PreCommitSectors(t, v, miner7.MinAggregatedSectors, miner7.PreCommitSectorBatchMaxSize, worker, minerAddr,
+		sealProof, 100, true, v.GetEpoch()+miner7.MaxSectorExpirationExtension, deals.IDs)
+	v = vm7Util.AdvanceToEpochWithCron(t, v, precommits[0].PreCommitEpoch+miner7.PreCommitChallengeDelay+1)
+	vm7Util.ProveCommitSectors(t, v, worker, minerAddr, precommits, true)
+
+	// Prove until the deal has made its first payment.
+	for {
+		dealState, found := vm7.GetDealState(t, v, dealID)
+		require.True(t, found)
+		if dealState.LastUpdatedEpoch != -1 {
+			break
+		}
+		v = vm7Util.ProveThenAdvanceOneDeadlineWithCron(t, v, ctxStore, minerInfos)
+	}
+	dealStateBefore, _ := vm7.GetDealState(t, v, dealID)
+	escrowBefore := marketEscrowV7(t, v, minerAddr)
+	powerBefore := vm7Util.MinerPower(t, v, ctxStore, minerAddr)
+	require.False(t, powerBefore.Raw.IsZero())
+
+	v8 := vm7Util.MigrateToV8(t, v)
+
+	// Continue the scenario for a full proving period, which is also the deal update interval.
+	v8 = vm7Util.AdvanceOneDayWhileProvingV8(t, v8, minerInfos)
+
+	dealStateAfter, found := vm8.GetDealState(t, v8, dealID)
+	require.True(t, found)
+	require.Equal(t, abi.ChainEpoch(-1), dealStateAfter.SlashEpoch)
+	require.Greater(t, dealStateAfter.LastUpdatedEpoch, dealStateBefore.LastUpdatedEpoch)
+	escrowAfter := marketEscrowV8(t, v8, minerAddr)
+	require.True(t, escrowAfter.GreaterThan(escrowBefore), "provider escrow %v did not grow from %v", escrowAfter, escrowBefore)
+
+	powerAfter := vm8.MinerPower(t, v8, minerAddr)
+	require.True(t, powerBefore.Raw.Equals(powerAfter.Raw))
+	require.True(t, powerBefore.QA.Equals(powerAfter.QA))
+	for _, precommit := range precommits {
+		dlIdx, pIdx := vm8.SectorDeadline(t, v8, minerAddr, precommit.Info.SectorNumber)
+		require.True(t, vm8.CheckSectorActive(t, v8, minerAddr, dlIdx, pIdx, precommit.Info.SectorNumber))
+		require.False(t, vm8.CheckSectorFaulty(t, v8, minerAddr, dlIdx, pIdx, precommit.Info.SectorNumber))
+	}
+
+	stateTree, err := v8.GetStateTree()
+	require.NoError(t, err)
+	totalBalance, err := v8.GetTotalActorBalance()
+	require.NoError(t, err)
+	acc, err := states.CheckStateInvariants(stateTree, totalBalance, v8.GetEpoch()-1)
+	require.NoError(t, err)
+	require.True(t, acc.IsEmpty(), strings.Join(acc.Messages(), "\n"))
+}
+
+func marketEscrowV7(t *testing.T, v *vm7.VM, a address.Address) abi.TokenAmount {
+	var st market7.State
+	require.NoError(t, v.GetState(builtin7.StorageMarketActorAddr, &st))
+	escrow, err := adt7.AsBalanceTable(v.Store(), st.EscrowTable)
+	require.NoError(t, err)
+	balance, err := escrow.Get(a)
+	require.NoError(t, err)
+	return balance
+}
+
+func marketEscrowV8(t *testing.T, v *vm8.VM, a address.Address) abi.TokenAmount {
+	var st market8.State
+	require.NoError(t, v.GetState(builtin8.StorageMarketActorAddr, &st))
+	escrow, err := adt8.AsBalanceTable(v.Store(), st.EscrowTable)
+	require.NoError(t, err)
+	balance, err := escrow.Get(a)
+	require.NoError(t, err)
+	return balance
+}
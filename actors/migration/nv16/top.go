@@ -13,10 +13,12 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/rt"
 	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	reward7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
 	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
 	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
 	states8 "github.com/filecoin-project/specs-actors/v8/actors/states"
 	adt8 "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	smoothing8 "github.com/filecoin-project/specs-actors/v8/actors/util/smoothing"
 
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -47,7 +49,21 @@ type Logger interface {
 
 // Migrates from v15 to v16
 //
-// This migration only updates the actor code CIDs in the state tree.
+// This migration updates the actor code CIDs in the state tree, adds empty
+// provider ask, revoked proposal, label index and escrow funder tables to the
+// market actor state, adds an empty recovery queue, an empty set of proven
+// pre-commitments, no owner settings, a zero PoSt relay nonce and no sector
+// manifests to each miner's state, makes each miner's owner its beneficiary,
+// marks its optimistically accepted Window PoSts as having no recorded chain
+// commit epoch, marks reward minting as not paused, adds empty datacap usage
+// tables to the verified registry, and initializes the power actor's breakdown
+// of pledge from the sum of all miners' pledge, its total faulty power from
+// the sum of all miners' faulty power, its claims snapshot from the current
+// claims and empty tables of fault streaks and cron failures.
+//
+// The market's deal ID counter is carried over unchanged. Deal IDs derived
+// from proposal CIDs are disjoint from counter IDs, so existing deals keep
+// their IDs if the market later switches to derived IDs.
 // MigrationCache stores and loads cached data. Its implementation must be threadsafe
 type MigrationCache interface {
 	Write(key string, newCid cid.Cid) error
@@ -71,6 +87,9 @@ func MigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn ci
 		return cid.Undef, xerrors.Errorf("invalid migration config with %d workers", cfg.MaxWorkers)
 	}
 
+	// Accumulates miners' pledge and faulty power for the deferred power actor migration.
+	totals := newMinerTotals()
+
 	// Maps prior version code CIDs to migration functions.
 	var migrations = map[cid.Cid]actorMigration{
 		builtin7.AccountActorCodeID:          nilMigrator{builtin8.AccountActorCodeID},
@@ -78,17 +97,16 @@ func MigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn ci
 		builtin7.InitActorCodeID:             nilMigrator{builtin8.InitActorCodeID},
 		builtin7.MultisigActorCodeID:         nilMigrator{builtin8.MultisigActorCodeID},
 		builtin7.PaymentChannelActorCodeID:   nilMigrator{builtin8.PaymentChannelActorCodeID},
-		builtin7.RewardActorCodeID:           nilMigrator{builtin8.RewardActorCodeID},
-		builtin7.StorageMarketActorCodeID:    nilMigrator{builtin8.StorageMarketActorCodeID},
-		builtin7.StorageMinerActorCodeID:     nilMigrator{builtin8.StorageMinerActorCodeID},
-		builtin7.StoragePowerActorCodeID:     nilMigrator{builtin8.StoragePowerActorCodeID},
+		builtin7.RewardActorCodeID:           rewardMigrator{},
+		builtin7.StorageMarketActorCodeID:    marketMigrator{},
+		builtin7.StorageMinerActorCodeID:     minerMigrator{totals},
 		builtin7.SystemActorCodeID:           nilMigrator{builtin8.SystemActorCodeID},
-		builtin7.VerifiedRegistryActorCodeID: nilMigrator{builtin8.VerifiedRegistryActorCodeID},
+		builtin7.VerifiedRegistryActorCodeID: verifregMigrator{},
 	}
 
 	// Set of prior version code CIDs for actors to defer during iteration, for explicit migration afterwards.
 	var deferredCodeIDs = map[cid.Cid]struct{}{
-		// None
+		builtin7.StoragePowerActorCodeID: {},
 	}
 
 	if len(migrations)+len(deferredCodeIDs) != 11 {
@@ -227,6 +245,39 @@ func MigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn ci
 		return cid.Undef, err
 	}
 
+	// Perform any deferred migrations explicitly here.
+	// The power actor depends on pledge and faulty power accumulated through migration of miner actors.
+	powerActorIn, found, err := actorsIn.GetActor(builtin7.StoragePowerActorAddr)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !found {
+		return cid.Undef, xerrors.Errorf("could not find power actor in state")
+	}
+	rewardActorIn, found, err := actorsIn.GetActor(builtin7.RewardActorAddr)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !found {
+		return cid.Undef, xerrors.Errorf("could not find reward actor in state")
+	}
+	var rewardStateIn reward7.State
+	if err := store.Get(ctx, rewardActorIn.Head, &rewardStateIn); err != nil {
+		return cid.Undef, err
+	}
+	powerResult, err := (&migrationJob{
+		Address:        builtin7.StoragePowerActorAddr,
+		Actor:          *powerActorIn,
+		cache:          cache,
+		actorMigration: powerMigrator{totals, smoothing8.FilterEstimate(rewardStateIn.ThisEpochRewardSmoothed)},
+	}).run(ctx, store, priorEpoch)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := actorsOut.SetActor(powerResult.Address, &powerResult.Actor); err != nil {
+		return cid.Undef, err
+	}
+
 	elapsed := time.Since(startTime)
 	rate := float64(doneCount) / elapsed.Seconds()
 	log.Log(rt.INFO, "All %d done after %v (%.0f/s). Flushing state tree root.", doneCount, elapsed, rate)
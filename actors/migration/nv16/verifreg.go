@@ -0,0 +1,55 @@
+package nv16
+
+import (
+	"context"
+
+	verifreg7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	verifreg8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/verifreg"
+	adt8 "github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+)
+
+// The verified registry gains empty datacap usage tables, and empty allocation and claim tables.
+// Usage by deals activated before the migration is not reconstructed. Verified deals published before
+// the migration used their client's datacap directly, so have no allocations.
+type verifregMigrator struct{}
+
+func (m verifregMigrator) migrateState(ctx context.Context, store cbor.IpldStore, in actorMigrationInput) (*actorMigrationResult, error) {
+	var inState verifreg7.State
+	if err := store.Get(ctx, in.head, &inState); err != nil {
+		return nil, err
+	}
+
+	emptyMap, err := adt8.StoreEmptyMap(adt8.WrapStore(ctx, store), builtin8.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to construct empty map: %w", err)
+	}
+
+	outState := verifreg8.State{
+		RootKey:                  inState.RootKey,
+		Verifiers:                inState.Verifiers,
+		VerifiedClients:          inState.VerifiedClients,
+		RemoveDataCapProposalIDs: inState.RemoveDataCapProposalIDs,
+		ClientUsage:              emptyMap,
+		ProviderUsage:            emptyMap,
+		Allocations:              emptyMap,
+		Claims:                   emptyMap,
+		ClientAllocations:        emptyMap,
+		SectorClaims:             emptyMap,
+		NextAllocationID:         0,
+	}
+
+	newHead, err := store.Put(ctx, &outState)
+	return &actorMigrationResult{
+		newCodeCID: m.migratedCodeCID(),
+		newHead:    newHead,
+	}, err
+}
+
+func (m verifregMigrator) migratedCodeCID() cid.Cid {
+	return builtin8.VerifiedRegistryActorCodeID
+}
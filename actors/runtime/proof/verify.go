@@ -1,6 +1,8 @@
 package proof
 
 import (
+	"github.com/filecoin-project/go-state-types/abi"
+
 	proof0 "github.com/filecoin-project/specs-actors/actors/runtime/proof"
 	proof5 "github.com/filecoin-project/specs-actors/v5/actors/runtime/proof"
 	proof7 "github.com/filecoin-project/specs-actors/v7/actors/runtime/proof"
@@ -75,3 +77,16 @@ type WinningPoStVerifyInfo = proof0.WinningPoStVerifyInfo
 // Prover            abi.ActorID // used to derive 32-byte prover ID
 //}
 type WindowPoStVerifyInfo = proof0.WindowPoStVerifyInfo
+
+// Information needed to verify an aggregate of the Window PoSts for a number of partitions at a deadline,
+// submitted directly to a miner actor.
+type AggregateWindowPoStVerifyInfo struct {
+	Randomness abi.PoStRandomness
+	// Aggregate of one proof per partition, all of the same PoSt proof type.
+	AggregateProof PoStProof
+	// Number of partition proofs aggregated.
+	Partitions uint64
+	// Sectors challenged in the aggregated partitions, in order of partition.
+	ChallengedSectors []SectorInfo
+	Prover            abi.ActorID // used to derive 32-byte prover ID
+}
@@ -177,6 +177,10 @@ type Syscalls interface {
 	// If it's an ID-address, the actor is looked up in state. It must be an account actor, and the
 	// public key is obtained from its state.
 	VerifySignature(signature crypto.Signature, signer addr.Address, plaintext []byte) error
+	// Verifies that an aggregate BLS signature is valid for the signatures of each signer over the
+	// plaintext at the same index. Signer addresses are resolved as for VerifySignature, and each
+	// must have a BLS public key.
+	VerifyAggregateSignature(signature crypto.Signature, signers []addr.Address, plaintexts [][]byte) error
 	// Hashes input data using blake2b with 256 bit output.
 	HashBlake2b(data []byte) [32]byte
 	// Computes an unsealed sector CID (CommD) from its constituent piece CIDs (CommPs) and sizes.
@@ -192,6 +196,8 @@ type Syscalls interface {
 
 	// Verifies a proof of spacetime.
 	VerifyPoSt(vi proof5.WindowPoStVerifyInfo) error
+	// Verifies an aggregate of proofs of spacetime for a number of partitions.
+	VerifyAggregatePoSt(vi proof.AggregateWindowPoStVerifyInfo) error
 	// Verifies that two block headers provide proof of a consensus fault:
 	// - both headers mined by the same actor
 	// - headers are different
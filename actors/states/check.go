@@ -1,8 +1,6 @@
 package states
 
 import (
-	"bytes"
-
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
@@ -158,7 +156,12 @@ func CheckStateInvariants(tree *Tree, expectedBalanceTotal abi.TokenAmount, prio
 }
 
 func CheckMinersAgainstPower(acc *builtin.MessageAccumulator, minerSummaries map[addr.Address]*miner.StateSummary, powerSummary *power.StateSummary) {
+	initialPledge, preCommitDeposits, lockedFunds := big.Zero(), big.Zero(), big.Zero()
 	for addr, minerSummary := range minerSummaries { // nolint:nomaprange
+		initialPledge = big.Add(initialPledge, minerSummary.InitialPledge)
+		preCommitDeposits = big.Add(preCommitDeposits, minerSummary.PreCommitDeposits)
+		lockedFunds = big.Add(lockedFunds, minerSummary.LockedFunds)
+
 		// check claim
 		claim, ok := powerSummary.Claims[addr]
 		acc.Require(ok, "miner %v has no power claim", addr)
@@ -176,12 +179,15 @@ func CheckMinersAgainstPower(acc *builtin.MessageAccumulator, minerSummaries map
 			continue
 		}
 
-		var payload miner.CronEventPayload
 		var provingPeriodCron *power.MinerCronEvent
 		for _, event := range crons {
-			err := payload.UnmarshalCBOR(bytes.NewReader(event.Payload))
-			acc.Require(err == nil, "miner %v registered cron at epoch %d with wrong or corrupt payload",
-				addr, event.Epoch)
+			payload, err := miner.DecodeCronEventPayload(event.Payload)
+			if err != nil {
+				acc.Addf("miner %v registered cron at epoch %d with wrong or corrupt payload", addr, event.Epoch)
+				continue
+			}
+			acc.Require(payload.Version <= miner.CronEventPayloadVersion, "miner %v registered cron at epoch %d with unknown payload version %d",
+				addr, event.Epoch, payload.Version)
 			acc.Require(payload.EventType == miner.CronEventProcessEarlyTerminations || payload.EventType == miner.CronEventProvingDeadline,
 				"miner %v has unexpected cron event type %v", addr, payload.EventType)
 
@@ -199,6 +205,14 @@ func CheckMinersAgainstPower(acc *builtin.MessageAccumulator, minerSummaries map
 
 		acc.Require(provingPeriodCron != nil, "miner %v has no proving period cron", addr)
 	}
+
+	// check pledge totals
+	acc.Require(initialPledge.Equals(powerSummary.TotalInitialPledge),
+		"sum of miner initial pledge %v does not match power total %v", initialPledge, powerSummary.TotalInitialPledge)
+	acc.Require(preCommitDeposits.Equals(powerSummary.TotalPreCommitDeposits),
+		"sum of miner pre-commit deposits %v does not match power total %v", preCommitDeposits, powerSummary.TotalPreCommitDeposits)
+	acc.Require(lockedFunds.Equals(powerSummary.TotalLockedRewards),
+		"sum of miner locked funds %v does not match power total %v", lockedFunds, powerSummary.TotalLockedRewards)
 }
 
 func CheckDealStatesAgainstSectors(acc *builtin.MessageAccumulator, minerSummaries map[addr.Address]*miner.StateSummary, marketSummary *market.StateSummary) {
@@ -223,6 +237,10 @@ func CheckDealStatesAgainstSectors(acc *builtin.MessageAccumulator, minerSummari
 			continue
 		}
 
+		acc.Require(deal.SectorNumber == market.DealSectorNumberUnknown || deal.SectorNumber == sectorDeal.SectorNumber,
+			"deal state sector %d does not match sector %d for miner %v",
+			deal.SectorNumber, sectorDeal.SectorNumber, deal.Provider)
+
 		acc.Require(deal.SectorStartEpoch == sectorDeal.SectorStart,
 			"deal state start %d does not match sector start %d for miner %v",
 			deal.SectorStartEpoch, sectorDeal.SectorStart, deal.Provider)
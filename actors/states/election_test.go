@@ -143,13 +143,15 @@ func constructMinerState(ctx context.Context, t *testing.T, store adt.Store, own
 		Owner:                      owner,
 		Worker:                     owner,
 		ControlAddresses:           []address.Address{},
-		PendingWorkerKey:           nil,
+		PendingWorkerKeys:          nil,
 		PeerId:                     nil,
 		Multiaddrs:                 [][]byte{},
 		WindowPoStProofType:        proofType,
 		SectorSize:                 ssize,
 		WindowPoStPartitionSectors: psize,
 		ConsensusFaultElapsed:      0,
+		Beneficiary:                owner,
+		BeneficiaryTerm:            miner.BeneficiaryTerm{Quota: big.Zero(), UsedQuota: big.Zero()},
 	}
 	infoCid, err := store.Put(ctx, &info)
 	require.NoError(t, err)
@@ -77,6 +77,7 @@ func TestCommitPoStFlow(t *testing.T) {
 					{To: minerAddrs.IDAddress, Method: builtin.MethodsMiner.OnDeferredCronEvent, SubInvocations: []vm.ExpectInvocation{
 						// The call to burnt funds indicates the overdue precommit has been penalized
 						{To: builtin.BurntFundsActorAddr, Method: builtin.MethodSend, Value: vm.ExpectAttoFil(precommits[0].PreCommitDeposit)},
+						{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal},
 						// No re-enrollment of cron because burning of PCD discontinues miner cron scheduling
 					}},
 					{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.UpdateNetworkKPI},
@@ -98,6 +99,45 @@ func TestCommitPoStFlow(t *testing.T) {
 		assert.Equal(t, big.Zero(), networkStats.TotalQualityAdjPower)
 	})
 
+	t.Run("prove commit rejected by registered seal verifier", func(t *testing.T) {
+		tv, err := v.WithEpoch(proveTime)
+		require.NoError(t, err)
+
+		verifiers := vm.NewProofVerifiers()
+		verifiers.RegisterSealVerifier(sealProof, func(proof.SealVerifyInfo) error {
+			return fmt.Errorf("seal proof rejected")
+		})
+		tv.SetProofVerifiers(verifiers)
+
+		proveCommitParams := miner.ProveCommitSectorParams{SectorNumber: sectorNumber}
+		vm.ApplyOk(t, tv, addrs[0], minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveCommitSector, &proveCommitParams)
+		vm.ApplyOk(t, tv, builtin.SystemActorAddr, builtin.CronActorAddr, big.Zero(), builtin.MethodsCron.EpochTick, nil)
+
+		// the failed proof is reported to the miner, which discards the pre-commitment
+		vm.ExpectInvocation{
+			To:     builtin.StoragePowerActorAddr,
+			Method: builtin.MethodsPower.CronTick,
+			SubInvocations: []vm.ExpectInvocation{
+				{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
+				{To: minerAddrs.IDAddress, Method: builtin.MethodsMiner.ConfirmSectorProofsValid, SubInvocations: []vm.ExpectInvocation{
+					{To: builtin.BurntFundsActorAddr, Method: builtin.MethodSend, Value: vm.ExpectAttoFil(precommits[0].PreCommitDeposit)},
+					{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal},
+				}},
+				{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.UpdateNetworkKPI},
+			},
+		}.Matches(t, tv.LastInvocation().SubInvocations[0])
+
+		var st miner.State
+		require.NoError(t, tv.GetState(minerAddrs.IDAddress, &st))
+		_, found, err := st.GetSector(tv.Store(), sectorNumber)
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		balances := vm.GetMinerBalances(t, tv, minerAddrs.IDAddress)
+		assert.Equal(t, big.Zero(), balances.PreCommitDeposit)
+		assert.Equal(t, big.Zero(), balances.InitialPledge)
+	})
+
 	//
 	// prove and verify
 	//
@@ -249,6 +289,8 @@ func TestCommitPoStFlow(t *testing.T) {
 				{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CronTick, SubInvocations: []vm.ExpectInvocation{
 					{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
 					{To: minerAddrs.IDAddress, Method: builtin.MethodsMiner.OnDeferredCronEvent, SubInvocations: []vm.ExpectInvocation{
+						// The unproven sector's power is reported as faulty, though no active power is lost.
+						{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdateClaimedPower},
 						{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.EnrollCronEvent},
 					}},
 					{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.UpdateNetworkKPI},
@@ -594,7 +636,7 @@ func TestAggregateOnePreCommitExpires(t *testing.T) {
 	proveCommitAggregateParams := miner.ProveCommitAggregateParams{
 		SectorNumbers: sectorNosBf,
 	}
-	// Aggregate passes, proving the 2 unexpired commitments
+	// Aggregate passes, proving the 3 unexpired commitments and releasing the expired one
 	vm.ApplyOk(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveCommitAggregate, &proveCommitAggregateParams)
 	vm.ExpectInvocation{
 		To:     minerAddrs.IDAddress,
@@ -605,13 +647,15 @@ func TestAggregateOnePreCommitExpires(t *testing.T) {
 			{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
 			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CurrentTotalPower},
 			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal},
+			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal},
 			{To: builtin.BurntFundsActorAddr, Method: builtin.MethodSend},
 		},
 	}.Matches(t, v.LastInvocation())
 
+	// The expired pre-commitment's deposit is released, part refunded and the rest burnt.
 	balances := vm.GetMinerBalances(t, v, minerAddrs.IDAddress)
 	assert.True(t, balances.InitialPledge.GreaterThan(big.Zero()))
-	assert.True(t, balances.PreCommitDeposit.GreaterThan(big.Zero()))
+	assert.True(t, balances.PreCommitDeposit.IsZero())
 
 }
 
@@ -65,6 +65,7 @@ func preCommitSectors(t *testing.T, v *vm.VM, count, batchSize int, worker, mAdd
 			aggFee := miner.AggregatePreCommitNetworkFee(len(params.Sectors), big.Zero())
 			invocs = append(invocs, vm.ExpectInvocation{To: builtin.BurntFundsActorAddr, Method: builtin.MethodSend, Value: &aggFee})
 		}
+		invocs = append(invocs, vm.ExpectInvocation{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal})
 		if expectCronEnrollment && msgSectorIndexStart == 0 {
 			invocs = append(invocs, invocFirst)
 		}
@@ -108,13 +109,15 @@ func createMiner(t *testing.T, v *vm.VM, owner, worker addr.Address, wPoStProof
 func publishDeal(t *testing.T, v *vm.VM, provider, dealClient, minerID addr.Address, dealLabel string,
 	pieceSize abi.PaddedPieceSize, verifiedDeal bool, dealStart abi.ChainEpoch, dealLifetime abi.ChainEpoch,
 ) *market.PublishStorageDealsReturn {
+	label, err := market.NewLabelFromString(dealLabel)
+	require.NoError(t, err)
 	deal := market.DealProposal{
 		PieceCID:             tutil.MakeCID(dealLabel, &market.PieceCIDPrefix),
 		PieceSize:            pieceSize,
 		VerifiedDeal:         verifiedDeal,
 		Client:               dealClient,
 		Provider:             minerID,
-		Label:                dealLabel,
+		Label:                label,
 		StartEpoch:           dealStart,
 		EndEpoch:             dealStart + dealLifetime,
 		StoragePricePerEpoch: abi.NewTokenAmount(1 << 20),
@@ -123,7 +126,7 @@ func publishDeal(t *testing.T, v *vm.VM, provider, dealClient, minerID addr.Addr
 	}
 
 	paramBuf := new(bytes.Buffer)
-	err := deal.MarshalCBOR(paramBuf)
+	err = deal.MarshalCBOR(paramBuf)
 	require.NoError(t, err)
 
 	publishDealParams := market.PublishStorageDealsParams{
@@ -147,7 +150,7 @@ func publishDeal(t *testing.T, v *vm.VM, provider, dealClient, minerID addr.Addr
 	if verifiedDeal {
 		expectedPublishSubinvocations = append(expectedPublishSubinvocations, vm.ExpectInvocation{
 			To:             builtin.VerifiedRegistryActorAddr,
-			Method:         builtin.MethodsVerifiedRegistry.UseBytes,
+			Method:         builtin.MethodsVerifiedRegistry.CreateAllocation,
 			SubInvocations: []vm.ExpectInvocation{},
 		})
 	}
@@ -175,13 +178,15 @@ func newDealBatcher(v *vm.VM) *dealBatcher {
 
 func (db *dealBatcher) stage(t *testing.T, dealClient, dealProvider addr.Address, dealLabel string, pieceSize abi.PaddedPieceSize, verifiedDeal bool, dealStart,
 	dealLifetime abi.ChainEpoch, pricePerEpoch, providerCollateral, clientCollateral abi.TokenAmount) {
+	label, err := market.NewLabelFromString(dealLabel)
+	require.NoError(t, err)
 	deal := market.DealProposal{
 		PieceCID:             tutil.MakeCID(dealLabel, &market.PieceCIDPrefix),
 		PieceSize:            pieceSize,
 		VerifiedDeal:         verifiedDeal,
 		Client:               dealClient,
 		Provider:             dealProvider,
-		Label:                dealLabel,
+		Label:                label,
 		StartEpoch:           dealStart,
 		EndEpoch:             dealStart + dealLifetime,
 		StoragePricePerEpoch: pricePerEpoch,
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/v8/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+	"github.com/filecoin-project/specs-actors/v8/support/vm"
+)
+
+func TestInvocationStateRoots(t *testing.T) {
+	ctx := context.Background()
+
+	// Creates a multisig with the approval threshold given, in a VM recording state roots up to limit.
+	run := func(t *testing.T, limit int, threshold uint64) *vm.VM {
+		v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+		v.SetStateRootRecording(limit)
+		addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), big.NewInt(1e18)), 93837778)
+
+		paramBuf := new(bytes.Buffer)
+		require.NoError(t, (&multisig.ConstructorParams{Signers: addrs, NumApprovalsThreshold: threshold}).MarshalCBOR(paramBuf))
+		vm.ApplyOk(t, v, addrs[0], builtin.InitActorAddr, big.Zero(), builtin.MethodsInit.Exec, &init_.ExecParams{
+			CodeCID:           builtin.MultisigActorCodeID,
+			ConstructorParams: paramBuf.Bytes(),
+		})
+		return v
+	}
+
+	t.Run("records the state root on completion of each invocation", func(t *testing.T) {
+		v := run(t, 1000, 1)
+		last := v.LastInvocation()
+		assert.Equal(t, v.StateRoot(), last.StateRoot)
+		require.Len(t, last.SubInvocations, 1)
+		assert.True(t, last.SubInvocations[0].StateRoot.Defined())
+
+		_, diverged := vm.FindDivergentInvocation(v.Invocations(), run(t, 1000, 1).Invocations())
+		assert.False(t, diverged)
+	})
+
+	t.Run("records no more roots than the limit", func(t *testing.T) {
+		v := run(t, 1, 1)
+		var count func(invocations []*vm.Invocation) int
+		count = func(invocations []*vm.Invocation) int {
+			recorded := 0
+			for _, invocation := range invocations {
+				if invocation.StateRoot.Defined() {
+					recorded++
+				}
+				recorded += count(invocation.SubInvocations)
+			}
+			return recorded
+		}
+		assert.Equal(t, 1, count(v.Invocations()))
+		// Nested invocations complete before those invoking them.
+		last := v.LastInvocation()
+		assert.True(t, last.SubInvocations[0].StateRoot.Defined())
+		assert.False(t, last.StateRoot.Defined())
+	})
+
+	t.Run("finds the nested invocation at which runs diverge", func(t *testing.T) {
+		a := run(t, 1000, 1)
+		b := run(t, 1000, 2)
+		idxs, diverged := vm.FindDivergentInvocation(a.Invocations(), b.Invocations())
+		require.True(t, diverged)
+		assert.Equal(t, []int{len(a.Invocations()) - 1, 0}, idxs)
+		var params multisig.ConstructorParams
+		require.NoError(t, params.UnmarshalCBOR(bytes.NewReader(vm.ParamsForInvocation(t, a, idxs...).(builtin.CBORBytes))))
+		assert.Equal(t, uint64(1), params.NumApprovalsThreshold)
+	})
+}
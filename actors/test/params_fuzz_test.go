@@ -0,0 +1,189 @@
+//go:build go1.18
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	cbor "github.com/filecoin-project/go-state-types/cbor"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/exported"
+	init_ "github.com/filecoin-project/specs-actors/v8/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+	"github.com/filecoin-project/specs-actors/v8/support/vm"
+)
+
+// These targets feed arbitrary bytes to every exported method of an actor as its CBOR params.
+// The VM decodes the bytes into the method's param type and runs the method, turning aborts into exit codes.
+// Any other panic escapes the VM and fails the target.
+// Run one with e.g. `go test ./actors/test -run '^$' -fuzz FuzzMinerParams`.
+
+func FuzzAccountParams(f *testing.F)  { fuzzActorParams(f, builtin.AccountActorCodeID) }
+func FuzzCronParams(f *testing.F)     { fuzzActorParams(f, builtin.CronActorCodeID) }
+func FuzzInitParams(f *testing.F)     { fuzzActorParams(f, builtin.InitActorCodeID) }
+func FuzzMarketParams(f *testing.F)   { fuzzActorParams(f, builtin.StorageMarketActorCodeID) }
+func FuzzMinerParams(f *testing.F)    { fuzzActorParams(f, builtin.StorageMinerActorCodeID) }
+func FuzzMultisigParams(f *testing.F) { fuzzActorParams(f, builtin.MultisigActorCodeID) }
+func FuzzPaychParams(f *testing.F)    { fuzzActorParams(f, builtin.PaymentChannelActorCodeID) }
+func FuzzPowerParams(f *testing.F)    { fuzzActorParams(f, builtin.StoragePowerActorCodeID) }
+func FuzzRewardParams(f *testing.F)   { fuzzActorParams(f, builtin.RewardActorCodeID) }
+func FuzzSystemParams(f *testing.F)   { fuzzActorParams(f, builtin.SystemActorCodeID) }
+func FuzzVerifregParams(f *testing.F) { fuzzActorParams(f, builtin.VerifiedRegistryActorCodeID) }
+
+// Malformed params seeded for every method, in addition to the encoding of the method's zero-valued params.
+var fuzzParamSeeds = [][]byte{
+	{},
+	{0xf6},       // null
+	{0x80},       // empty array
+	{0x81, 0x00}, // array of a single zero
+	{0x9f, 0xff}, // empty indefinite-length array
+	{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // byte string of absurd length
+}
+
+type paramsFuzzHarness struct {
+	v *vm.VM
+	// Actor to receive messages for each actor code.
+	receivers map[cid.Cid]address.Address
+	// Possible message senders. The first is the owner, worker, signer or payer of the constructed actors.
+	senders []address.Address
+}
+
+func fuzzActorParams(f *testing.F, code cid.Cid) {
+	h := newParamsFuzzHarness(f)
+	to, ok := h.receivers[code]
+	require.True(f, ok, "no receiver for actor code %s", code)
+
+	var methods []abi.MethodNum
+	var paramTypes []reflect.Type
+	for _, a := range exported.BuiltinActors() {
+		if !a.Code().Equals(code) {
+			continue
+		}
+		for i, m := range a.Exports() {
+			if m == nil {
+				continue
+			}
+			methods = append(methods, abi.MethodNum(i))
+			paramTypes = append(paramTypes, reflect.TypeOf(m).In(1))
+		}
+	}
+	require.NotEmpty(f, methods)
+
+	for i := range methods {
+		for _, seed := range fuzzParamSeeds {
+			f.Add(uint64(i), uint8(0), seed)
+		}
+		if seed, ok := zeroParams(paramTypes[i]); ok {
+			f.Add(uint64(i), uint8(0), seed)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, method uint64, sender uint8, params []byte) {
+		// Each input runs against a fresh view of the seeded state.
+		v, err := h.v.WithEpoch(h.v.GetEpoch())
+		require.NoError(t, err)
+
+		from := h.senders[int(sender)%len(h.senders)]
+		m := methods[method%uint64(len(methods))]
+		_, err = v.ApplyMessage(from, to, big.Zero(), m, builtin.CBORBytes(params), "")
+		require.NoError(t, err)
+	})
+}
+
+func newParamsFuzzHarness(f *testing.F) *paramsFuzzHarness {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, f, ipld.NewBlockStoreInMemory())
+	addrs := vm.CreateAccounts(ctx, f, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	owner, payee := addrs[0], addrs[1]
+
+	minerRet := applyFuzzSetup(f, v, owner, builtin.StoragePowerActorAddr, big.Mul(big.NewInt(100), vm.FIL), builtin.MethodsPower.CreateMiner, &power.CreateMinerParams{
+		Owner:               owner,
+		Worker:              owner,
+		WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		Peer:                abi.PeerID("not really a peer id"),
+	}).(*power.CreateMinerReturn)
+
+	multisigRet := execFuzzSetup(f, v, owner, builtin.MultisigActorCodeID, &multisig.ConstructorParams{
+		Signers:               addrs,
+		NumApprovalsThreshold: 1,
+	})
+	paychRet := execFuzzSetup(f, v, owner, builtin.PaymentChannelActorCodeID, &paych.ConstructorParams{
+		From: owner,
+		To:   payee,
+	})
+
+	return &paramsFuzzHarness{
+		v: v,
+		receivers: map[cid.Cid]address.Address{
+			builtin.AccountActorCodeID:          owner,
+			builtin.CronActorCodeID:             builtin.CronActorAddr,
+			builtin.InitActorCodeID:             builtin.InitActorAddr,
+			builtin.StorageMarketActorCodeID:    builtin.StorageMarketActorAddr,
+			builtin.StorageMinerActorCodeID:     minerRet.IDAddress,
+			builtin.MultisigActorCodeID:         multisigRet.IDAddress,
+			builtin.PaymentChannelActorCodeID:   paychRet.IDAddress,
+			builtin.StoragePowerActorCodeID:     builtin.StoragePowerActorAddr,
+			builtin.RewardActorCodeID:           builtin.RewardActorAddr,
+			builtin.SystemActorCodeID:           builtin.SystemActorAddr,
+			builtin.VerifiedRegistryActorCodeID: builtin.VerifiedRegistryActorAddr,
+		},
+		senders: []address.Address{
+			owner,
+			payee,
+			vm.VerifregRoot,
+			builtin.SystemActorAddr,
+			builtin.InitActorAddr,
+			builtin.CronActorAddr,
+			builtin.RewardActorAddr,
+			builtin.StoragePowerActorAddr,
+			builtin.StorageMarketActorAddr,
+			minerRet.IDAddress,
+			multisigRet.IDAddress,
+		},
+	}
+}
+
+func execFuzzSetup(f *testing.F, v *vm.VM, from address.Address, code cid.Cid, params cbor.Marshaler) *init_.ExecReturn {
+	var buf bytes.Buffer
+	require.NoError(f, params.MarshalCBOR(&buf))
+	return applyFuzzSetup(f, v, from, builtin.InitActorAddr, big.Zero(), builtin.MethodsInit.Exec, &init_.ExecParams{
+		CodeCID:           code,
+		ConstructorParams: buf.Bytes(),
+	}).(*init_.ExecReturn)
+}
+
+func applyFuzzSetup(f *testing.F, v *vm.VM, from, to address.Address, value abi.TokenAmount, method abi.MethodNum, params interface{}) cbor.Marshaler {
+	result, err := v.ApplyMessage(from, to, value, method, params, "")
+	require.NoError(f, err)
+	require.Equal(f, exitcode.Ok, result.Code, "setup message to %s method %d failed", to, method)
+	return result.Ret
+}
+
+// Returns the encoding of a zero-valued instance of a method param type, if it can be encoded.
+func zeroParams(typ reflect.Type) ([]byte, bool) {
+	if typ.Kind() != reflect.Ptr {
+		return nil, false
+	}
+	m, ok := reflect.New(typ.Elem()).Interface().(cbor.Marshaler)
+	if !ok {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	if err := m.MarshalCBOR(&buf); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
@@ -93,8 +93,8 @@ func TestCronTick(t *testing.T) {
 	}
 	vm.ApplyOk(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.PreCommitSector, &preCommitParams)
 
-	// find epoch of miner's next cron task (precommit:1, enrollCron:2)
-	cronParams := vm.ParamsForInvocation(t, v, 1, 2)
+	// find epoch of miner's next cron task (precommit:1, updatePledge:2, enrollCron:3)
+	cronParams := vm.ParamsForInvocation(t, v, 1, 3)
 	cronConfig, ok := cronParams.(*power.EnrollCronEventParams)
 	require.True(t, ok)
 
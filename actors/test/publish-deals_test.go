@@ -7,7 +7,9 @@ import (
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/power"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/verifreg"
@@ -393,3 +395,65 @@ func TestPublishStorageDealsFailure(t *testing.T) {
 	})
 
 }
+
+func TestPublishStorageDealsWithClientActor(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	worker, daoOwner := addrs[0], addrs[1]
+	sealProof := abi.RegisteredSealProof_StackedDrg32GiBV1_1
+
+	params := power.CreateMinerParams{
+		Owner:               worker,
+		Worker:              worker,
+		WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		Peer:                abi.PeerID("not really a peer id"),
+	}
+	ret := vm.ApplyOk(t, v, worker, builtin.StoragePowerActorAddr, big.Mul(big.NewInt(100), vm.FIL), builtin.MethodsPower.CreateMiner, &params)
+	minerAddrs, ok := ret.(*power.CreateMinerReturn)
+	require.True(t, ok)
+
+	// The client actor's escrow is funded by its owner.
+	dao := vm.CreateDealClient(ctx, t, v, daoOwner, "data-dao")
+	vm.ApplyOk(t, v, daoOwner, builtin.StorageMarketActorAddr, big.Mul(big.NewInt(100), vm.FIL), builtin.MethodsMarket.AddBalance, &dao)
+	vm.ApplyOk(t, v, worker, builtin.StorageMarketActorAddr, big.Mul(big.NewInt(100), vm.FIL), builtin.MethodsMarket.AddBalance, &minerAddrs.IDAddress)
+
+	dealStart := v.GetEpoch() + miner.MaxProveCommitDuration[sealProof]
+	batcher := newDealBatcher(v)
+	batcher.stage(t, dao, minerAddrs.IDAddress, "dao-deal0", 1<<30, false, dealStart, dealLifeTime,
+		defaultPricePerEpoch, defaultProviderCollateral, defaultClientCollateral)
+	batcher.stage(t, dao, minerAddrs.IDAddress, "dao-deal1", 1<<30, false, dealStart, dealLifeTime,
+		defaultPricePerEpoch, defaultProviderCollateral, defaultClientCollateral)
+
+	// Only the proposal approved by the client actor's owner is published.
+	approved, err := batcher.deals[0].Cid()
+	require.NoError(t, err)
+	vm.ApplyOk(t, v, daoOwner, dao, big.Zero(), vm.DealClientMethodApproveDeal, &market.AuthorizeDealParams{ProposalCid: approved})
+
+	dealRet := batcher.publishOK(t, worker)
+	goodInputs, err := dealRet.ValidDeals.All(math.MaxUint64)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0}, goodInputs)
+	require.Len(t, dealRet.IDs, 1)
+
+	vm.ExpectInvocation{
+		To:     builtin.StorageMarketActorAddr,
+		Method: builtin.MethodsMarket.PublishStorageDeals,
+		SubInvocations: []vm.ExpectInvocation{
+			{To: minerAddrs.IDAddress, Method: builtin.MethodsMiner.ControlAddresses},
+			{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
+			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CurrentTotalPower},
+			{To: dao, Method: market.MethodAuthorizeDeal, Params: vm.ExpectObject(&market.AuthorizeDealParams{ProposalCid: approved})},
+			{To: dao, Method: market.MethodAuthorizeDeal, Exitcode: exitcode.ErrForbidden},
+		},
+	}.Matches(t, v.LastInvocation())
+
+	var st market.State
+	require.NoError(t, v.GetState(builtin.StorageMarketActorAddr, &st))
+	proposals, err := market.AsDealProposalArray(v.Store(), st.Proposals)
+	require.NoError(t, err)
+	published, found, err := proposals.Get(dealRet.IDs[0])
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, dao, published.Client)
+}
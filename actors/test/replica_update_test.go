@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
@@ -12,7 +13,6 @@ import (
 	"github.com/filecoin-project/go-address"
 
 	"github.com/filecoin-project/go-bitfield"
-	tutil "github.com/filecoin-project/specs-actors/v8/support/testing"
 
 	"github.com/filecoin-project/go-state-types/exitcode"
 
@@ -22,8 +22,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v8/actors/states"
 	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v8/support/testing"
 	"github.com/filecoin-project/specs-actors/v8/support/vm"
 )
 
@@ -70,6 +73,234 @@ func TestFullPathSuccess(t *testing.T) {
 	require.Equal(t, ss, minerPower.Raw.Uint64())
 }
 
+// Tests that a replica update records a manifest of the sector's new piece layout
+func TestUpgradeWithManifest(t *testing.T) {
+	ctx := context.Background()
+	blkStore := ipld.NewBlockStoreInMemory()
+	v := vm.NewVMWithSingletons(ctx, t, blkStore)
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(100_000), big.NewInt(1e18)), 93837778)
+
+	sealProof := abi.RegisteredSealProof_StackedDrg32GiBV1_1
+	wPoStProof, err := sealProof.RegisteredWindowPoStProof()
+	require.NoError(t, err)
+	owner, worker := addrs[0], addrs[0]
+	minerAddrs := createMiner(t, v, owner, worker, wPoStProof, big.Mul(big.NewInt(10_000), vm.FIL))
+
+	v, err = v.WithEpoch(abi.ChainEpoch(200))
+	require.NoError(t, err)
+	v, deadlineIndex, partitionIndex, sectorNumber := createSector(t, v, worker, minerAddrs.IDAddress, 100, sealProof)
+	dealIDs := createDeals(t, 1, v, worker, worker, minerAddrs.IDAddress, sealProof)
+
+	// A CC sector has no manifest.
+	ret := vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.SectorManifest,
+		&miner.SectorManifestParams{SectorNumber: sectorNumber})
+	assert.Nil(t, ret.(*miner.SectorManifestReturn).Manifest)
+
+	manifest := tutil.MakeCID("manifest", nil)
+	update := vm.NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex, "replica", dealIDs)
+	vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveReplicaUpdates2, &miner.ProveReplicaUpdates2Params{
+		Updates: []miner.ReplicaUpdate2{{
+			SectorID:             update.SectorID,
+			Deadline:             update.Deadline,
+			Partition:            update.Partition,
+			NewSealedSectorCID:   update.NewSealedSectorCID,
+			NewUnsealedSectorCID: tutil.MakeCID("presealedSectorCID", &vm.UnsealedCIDPrefix),
+			Deals:                update.Deals,
+			UpdateProofType:      update.UpdateProofType,
+		}},
+		Manifests: []miner.SectorManifest{{SectorNumber: sectorNumber, Manifest: manifest}},
+	})
+
+	ret = vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.SectorManifest,
+		&miner.SectorManifestParams{SectorNumber: sectorNumber})
+	require.NotNil(t, ret.(*miner.SectorManifestReturn).Manifest)
+	assert.Equal(t, manifest, *ret.(*miner.SectorManifestReturn).Manifest)
+}
+
+// Tests that a replica update with an explicit unsealed CID reports the outcome of each update
+func TestProveReplicaUpdates2(t *testing.T) {
+	ctx := context.Background()
+	blkStore := ipld.NewBlockStoreInMemory()
+	v := vm.NewVMWithSingletons(ctx, t, blkStore)
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(100_000), big.NewInt(1e18)), 93837778)
+
+	sealProof := abi.RegisteredSealProof_StackedDrg32GiBV1_1
+	wPoStProof, err := sealProof.RegisteredWindowPoStProof()
+	require.NoError(t, err)
+	owner, worker := addrs[0], addrs[0]
+	minerAddrs := createMiner(t, v, owner, worker, wPoStProof, big.Mul(big.NewInt(10_000), vm.FIL))
+
+	v, err = v.WithEpoch(abi.ChainEpoch(200))
+	require.NoError(t, err)
+	v, deadlineIndex, partitionIndex, sectorNumber := createSector(t, v, worker, minerAddrs.IDAddress, 100, sealProof)
+	dealIDs := createDeals(t, 1, v, worker, worker, minerAddrs.IDAddress, sealProof)
+
+	update := vm.NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex, "replica", dealIDs)
+	update2 := miner.ReplicaUpdate2{
+		SectorID:             update.SectorID,
+		Deadline:             update.Deadline,
+		Partition:            update.Partition,
+		NewSealedSectorCID:   update.NewSealedSectorCID,
+		NewUnsealedSectorCID: tutil.MakeCID("presealedSectorCID", &vm.UnsealedCIDPrefix),
+		Deals:                update.Deals,
+		UpdateProofType:      update.UpdateProofType,
+	}
+
+	// An unsealed CID not matching the deals fails the message.
+	mismatched := update2
+	mismatched.NewUnsealedSectorCID = tutil.MakeCID("other data", &vm.UnsealedCIDPrefix)
+	vm.ApplyCode(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveReplicaUpdates2,
+		&miner.ProveReplicaUpdates2Params{Updates: []miner.ReplicaUpdate2{mismatched}}, exitcode.ErrIllegalArgument)
+
+	// A duplicate update of the sector is skipped, and the skip reported.
+	ret := vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveReplicaUpdates2,
+		&miner.ProveReplicaUpdates2Params{Updates: []miner.ReplicaUpdate2{update2, update2}})
+	assert.Equal(t, []miner.ReplicaUpdateResult{
+		{SectorNumber: sectorNumber, Code: exitcode.Ok},
+		{SectorNumber: sectorNumber, Code: exitcode.ErrIllegalArgument},
+	}, ret.(*miner.ProveReplicaUpdates2Return).Results)
+
+	newSectorInfo := vm.SectorInfo(t, v, minerAddrs.RobustAddress, sectorNumber)
+	assert.Equal(t, update.NewSealedSectorCID, newSectorInfo.SealedCID)
+	assert.Equal(t, dealIDs, newSectorInfo.DealIDs)
+}
+
+// Tests that a sector holding deals may be updated with new deals, settling the deals it held without penalty
+func TestUpgradeReplacingDeals(t *testing.T) {
+	v, sectorInfo, worker, minerAddrs, deadlineIndex, partitionIndex, _ := createMinerAndUpgradeASector(t)
+	sectorNumber := sectorInfo.SectorNumber
+	oldDealIDs := sectorInfo.DealIDs
+	require.NotEmpty(t, oldDealIDs)
+
+	sealProof := sectorInfo.SealProof
+	vm.ApplyOk(t, v, worker, builtin.StorageMarketActorAddr, big.Mul(big.NewInt(3), vm.FIL), builtin.MethodsMarket.AddBalance, &worker)
+	vm.ApplyOk(t, v, worker, builtin.StorageMarketActorAddr, big.Mul(big.NewInt(64), vm.FIL), builtin.MethodsMarket.AddBalance, &minerAddrs.IDAddress)
+	dealStart := v.GetEpoch() + miner.MaxProveCommitDuration[sealProof]
+	newDealIDs := publishDeal(t, v, worker, worker, minerAddrs.IDAddress, "replacementDeal", 32<<30, false, dealStart, 180*builtin.EpochsInDay).IDs
+
+	clientBefore := getMarketBalance(t, v, worker, worker)
+	providerBefore := getMarketBalance(t, v, worker, minerAddrs.IDAddress)
+	minerBefore := vm.GetMinerBalances(t, v, minerAddrs.IDAddress)
+
+	update := vm.NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex, "replica2", newDealIDs)
+	update2 := miner.ReplicaUpdate2{
+		SectorID:             update.SectorID,
+		Deadline:             update.Deadline,
+		Partition:            update.Partition,
+		NewSealedSectorCID:   update.NewSealedSectorCID,
+		NewUnsealedSectorCID: tutil.MakeCID("presealedSectorCID", &vm.UnsealedCIDPrefix),
+		Deals:                update.Deals,
+		UpdateProofType:      update.UpdateProofType,
+	}
+
+	// The update is refused unless the sector's deals may be replaced.
+	vm.ApplyCode(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveReplicaUpdates2,
+		&miner.ProveReplicaUpdates2Params{Updates: []miner.ReplicaUpdate2{update2}}, exitcode.ErrIllegalArgument)
+
+	ret := vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveReplicaUpdates2,
+		&miner.ProveReplicaUpdates2Params{Updates: []miner.ReplicaUpdate2{update2}, ReplaceDeals: true})
+	assert.Equal(t, []miner.ReplicaUpdateResult{{SectorNumber: sectorNumber, Code: exitcode.Ok}},
+		ret.(*miner.ProveReplicaUpdates2Return).Results)
+
+	vm.ExpectInvocation{
+		To:     minerAddrs.IDAddress,
+		Method: builtin.MethodsMiner.ProveReplicaUpdates2,
+		SubInvocations: []vm.ExpectInvocation{
+			{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.ActivateDeals},
+			{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.OnMinerDealsReplaced},
+			{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.VerifyDealsForActivation},
+			{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.ComputeDataCommitment},
+			{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
+			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CurrentTotalPower},
+		},
+	}.Matches(t, v.LastInvocation())
+
+	newSectorInfo := vm.SectorInfo(t, v, minerAddrs.RobustAddress, sectorNumber)
+	assert.Equal(t, newDealIDs, newSectorInfo.DealIDs)
+	assert.Equal(t, update.NewSealedSectorCID, newSectorInfo.SealedCID)
+	assert.Equal(t, sectorInfo.SectorKeyCID, newSectorInfo.SectorKeyCID)
+
+	// The replaced deals are terminated as of the update, while the new deals are active.
+	for _, dealID := range oldDealIDs {
+		state, found := vm.GetDealState(t, v, dealID)
+		require.True(t, found)
+		assert.Equal(t, v.GetEpoch(), state.SlashEpoch)
+	}
+	for _, dealID := range newDealIDs {
+		state, found := vm.GetDealState(t, v, dealID)
+		require.True(t, found)
+		assert.Equal(t, v.GetEpoch(), state.SectorStartEpoch)
+	}
+
+	// The replaced deals' funds stay locked until cron settles them, slashing the provider's collateral.
+	clientAfter := getMarketBalance(t, v, worker, worker)
+	providerAfter := getMarketBalance(t, v, worker, minerAddrs.IDAddress)
+	assert.Equal(t, clientBefore, clientAfter)
+	assert.Equal(t, providerBefore, providerAfter)
+
+	// The miner's pledge covers the updated sector, and is never reduced by an update.
+	minerAfter := vm.GetMinerBalances(t, v, minerAddrs.IDAddress)
+	assert.Equal(t, newSectorInfo.InitialPledge, minerAfter.InitialPledge)
+	assert.True(t, minerAfter.InitialPledge.GreaterThanEqual(minerBefore.InitialPledge))
+	assert.Equal(t, big.Sub(minerAfter.InitialPledge, minerBefore.InitialPledge), big.Sub(newSectorInfo.InitialPledge, sectorInfo.InitialPledge))
+
+	stateTree, err := v.GetStateTree()
+	require.NoError(t, err)
+	totalBalance, err := v.GetTotalActorBalance()
+	require.NoError(t, err)
+	acc, err := states.CheckStateInvariants(stateTree, totalBalance, v.GetEpoch())
+	require.NoError(t, err)
+	assert.True(t, acc.IsEmpty(), strings.Join(acc.Messages(), "\n"))
+}
+
+// Tests that the replica update of a CC sector can be rolled back until a PoSt could cover the new replica
+func TestRollbackReplicaUpdate(t *testing.T) {
+	t.Run("restores the sector and terminates its new deals", func(t *testing.T) {
+		v, sectorInfo, worker, minerAddrs, _, _, ss := createMinerAndUpgradeASector(t)
+		sectorNumber := sectorInfo.SectorNumber
+		require.NotEmpty(t, sectorInfo.DealIDs)
+		params := &miner.RollbackReplicaUpdatesParams{Sectors: bitfield.NewFromSet([]uint64{uint64(sectorNumber)})}
+
+		vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.RollbackReplicaUpdates, params)
+
+		restored := vm.SectorInfo(t, v, minerAddrs.RobustAddress, sectorNumber)
+		assert.Equal(t, *sectorInfo.SectorKeyCID, restored.SealedCID)
+		assert.Nil(t, restored.SectorKeyCID)
+		assert.Empty(t, restored.DealIDs)
+		assert.Equal(t, ss, vm.MinerPower(t, v, minerAddrs.IDAddress).Raw.Uint64())
+
+		for _, dealID := range sectorInfo.DealIDs {
+			state, found := vm.GetDealState(t, v, dealID)
+			require.True(t, found)
+			assert.Equal(t, v.GetEpoch(), state.SlashEpoch)
+		}
+
+		// The rollback can't be repeated.
+		vm.ApplyCode(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.RollbackReplicaUpdates, params, exitcode.ErrForbidden)
+
+		stateTree, err := v.GetStateTree()
+		require.NoError(t, err)
+		totalBalance, err := v.GetTotalActorBalance()
+		require.NoError(t, err)
+		acc, err := states.CheckStateInvariants(stateTree, totalBalance, v.GetEpoch())
+		require.NoError(t, err)
+		assert.True(t, acc.IsEmpty(), strings.Join(acc.Messages(), "\n"))
+	})
+
+	t.Run("forbidden once the sector's deadline opens", func(t *testing.T) {
+		v, sectorInfo, worker, minerAddrs, deadlineIndex, _, _ := createMinerAndUpgradeASector(t)
+		params := &miner.RollbackReplicaUpdatesParams{Sectors: bitfield.NewFromSet([]uint64{uint64(sectorInfo.SectorNumber)})}
+
+		v, dlInfo := vm.AdvanceByDeadlineTillIndex(t, v, minerAddrs.IDAddress, deadlineIndex)
+		v, err := v.WithEpoch(dlInfo.Open)
+		require.NoError(t, err)
+		vm.ApplyCode(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.RollbackReplicaUpdates, params, exitcode.ErrForbidden)
+
+		updated := vm.SectorInfo(t, v, minerAddrs.RobustAddress, sectorInfo.SectorNumber)
+		assert.Equal(t, sectorInfo.SealedCID, updated.SealedCID)
+	})
+}
+
 func TestUpgradeAndMissPoSt(t *testing.T) {
 	ctx := context.Background()
 	blkStore := ipld.NewBlockStoreInMemory()
@@ -96,18 +327,7 @@ func TestUpgradeAndMissPoSt(t *testing.T) {
 
 	// replicaUpdate the sector
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           deadlineIndex,
-		Partition:          partitionIndex,
-		NewSealedSectorCID: tutil.MakeCID("replica", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
-
-	vm.ApplyOk(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
-		builtin.MethodsMiner.ProveReplicaUpdates,
-		&miner.ProveReplicaUpdatesParams{Updates: []miner.ReplicaUpdate{replicaUpdate}})
+	vm.UpgradeSector(t, v, minerAddrs.IDAddress, worker, deadlineIndex, partitionIndex, sectorNumber, dealIDs)
 
 	powerAfterUpdate := vm.MinerPower(t, v, minerAddrs.IDAddress)
 	require.False(t, powerAfterUpdate.Raw.IsZero())
@@ -182,14 +402,7 @@ func TestImmutableDeadlineFailure(t *testing.T) {
 
 	// replicaUpdate the sector
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           deadlineIndex,
-		Partition:          partitionIndex,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
+	replicaUpdate := vm.NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex, "replica1", dealIDs)
 
 	vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
 		builtin.MethodsMiner.ProveReplicaUpdates,
@@ -218,14 +431,7 @@ func TestUnhealthySectorFailure(t *testing.T) {
 	v, _ = vm.AdvanceByDeadlineTillEpoch(t, v, minerAddrs.IDAddress, v.GetEpoch()+miner.WPoStProvingPeriod)
 	require.False(t, vm.CheckSectorActive(t, v, minerAddrs.IDAddress, deadlineIndex, partitionIndex, sectorNumber))
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           deadlineIndex,
-		Partition:          partitionIndex,
-		NewSealedSectorCID: tutil.MakeCID("replica", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
+	replicaUpdate := vm.NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex, "replica", dealIDs)
 
 	vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
 		builtin.MethodsMiner.ProveReplicaUpdates,
@@ -260,14 +466,7 @@ func TestTerminatedSectorFailure(t *testing.T) {
 	})
 	require.False(t, vm.CheckSectorActive(t, v, minerAddrs.RobustAddress, dlIdx, pIdx, sectorNumber))
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           dlIdx,
-		Partition:          pIdx,
-		NewSealedSectorCID: tutil.MakeCID("replica", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
+	replicaUpdate := vm.NewReplicaUpdate(sectorNumber, dlIdx, pIdx, "replica", dealIDs)
 
 	vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
 		builtin.MethodsMiner.ProveReplicaUpdates,
@@ -296,13 +495,7 @@ func TestBadBatchSizeFailure(t *testing.T) {
 
 	updates := make([]miner.ReplicaUpdate, miner.ProveReplicaUpdatesMaxSize+1)
 	for i := range updates {
-		updates[i] = miner.ReplicaUpdate{
-			SectorID:           sectorNumber,
-			Deadline:           deadlineIndex,
-			Partition:          partitionIndex,
-			NewSealedSectorCID: tutil.MakeCID("replica", &miner.SealedCIDPrefix),
-			Deals:              dealIDs,
-		}
+		updates[i] = vm.NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex, "replica", dealIDs)
 	}
 
 	vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
@@ -383,28 +576,7 @@ func TestBadPostUpgradeDispute(t *testing.T) {
 
 	// replicaUpdate the sector
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           dlIdx,
-		Partition:          pIdx,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
-
-	ret := vm.ApplyOk(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
-		builtin.MethodsMiner.ProveReplicaUpdates,
-		&miner.ProveReplicaUpdatesParams{Updates: []miner.ReplicaUpdate{replicaUpdate}})
-
-	updatedSectors, ok := ret.(*bitfield.BitField)
-	require.True(t, ok)
-	count, err := updatedSectors.Count()
-	require.NoError(t, err)
-	require.Equal(t, uint64(1), count)
-
-	isSet, err := updatedSectors.IsSet(uint64(sectorNumber))
-	require.NoError(t, err)
-	require.True(t, isSet)
+	vm.UpgradeSector(t, v, minerAddrs.IDAddress, worker, dlIdx, pIdx, sectorNumber, dealIDs)
 
 	disputeParams := &miner.DisputeWindowedPoStParams{
 		Deadline:  dlIdx,
@@ -508,14 +680,7 @@ func TestWrongDeadlineIndexFailure(t *testing.T) {
 
 	// replicaUpdate the sector
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           deadlineIndex + 1,
-		Partition:          partitionIndex,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
+	replicaUpdate := vm.NewReplicaUpdate(sectorNumber, deadlineIndex+1, partitionIndex, "replica1", dealIDs)
 
 	ret := vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
 		builtin.MethodsMiner.ProveReplicaUpdates,
@@ -556,14 +721,7 @@ func TestWrongPartitionIndexFailure(t *testing.T) {
 
 	// replicaUpdate the sector
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           deadlineIndex,
-		Partition:          partitionIndex + 1,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
+	replicaUpdate := vm.NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex+1, "replica1", dealIDs)
 
 	ret := vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
 		builtin.MethodsMiner.ProveReplicaUpdates,
@@ -643,23 +801,8 @@ func TestProveReplicaUpdateMultiDline(t *testing.T) {
 
 	/* Replica Update across two deadlines */
 	dealIDs := createDeals(t, 2, v, worker, worker, minerAddrs.IDAddress, sealProof)
-	replicaUpdate1 := miner.ReplicaUpdate{
-		SectorID:           firstSectorNumberP1,
-		Deadline:           0,
-		Partition:          0,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs[0:1],
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
-
-	replicaUpdate2 := miner.ReplicaUpdate{
-		SectorID:           firstSectorNumberP2,
-		Deadline:           1,
-		Partition:          0,
-		NewSealedSectorCID: tutil.MakeCID("replica2", &miner.SealedCIDPrefix),
-		Deals:              dealIDs[1:],
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
+	replicaUpdate1 := vm.NewReplicaUpdate(firstSectorNumberP1, 0, 0, "replica1", dealIDs[0:1])
+	replicaUpdate2 := vm.NewReplicaUpdate(firstSectorNumberP2, 1, 0, "replica2", dealIDs[1:])
 
 	// When this bug is fixed this should become vm.ApplyOk
 	vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
@@ -743,31 +886,10 @@ func TestDealIncludedInMultipleSectors(t *testing.T) {
 
 	// replicaUpdate the sector
 
-	replicaUpdate1 := miner.ReplicaUpdate{
-		SectorID:           sectorNumber1,
-		Deadline:           dlIdx,
-		Partition:          pIdx,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
-
-	replicaUpdate2 := miner.ReplicaUpdate{
-		SectorID:           sectorNumber2,
-		Deadline:           dlIdx,
-		Partition:          pIdx,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
-
-	ret := vm.ApplyCode(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
-		builtin.MethodsMiner.ProveReplicaUpdates,
-		&miner.ProveReplicaUpdatesParams{Updates: []miner.ReplicaUpdate{replicaUpdate1, replicaUpdate2}},
-		0)
+	replicaUpdate1 := vm.NewReplicaUpdate(sectorNumber1, dlIdx, pIdx, "replica1", dealIDs)
+	replicaUpdate2 := vm.NewReplicaUpdate(sectorNumber2, dlIdx, pIdx, "replica1", dealIDs)
 
-	updatedSectors, ok := ret.(*bitfield.BitField)
-	require.True(t, ok)
+	updatedSectors := vm.ProveReplicaUpdates(t, v, minerAddrs.RobustAddress, worker, replicaUpdate1, replicaUpdate2)
 	count, err := updatedSectors.Count()
 	require.NoError(t, err)
 	require.Equal(t, uint64(1), count)
@@ -789,6 +911,22 @@ func TestDealIncludedInMultipleSectors(t *testing.T) {
 	require.NotEqual(t, replicaUpdate2.NewSealedSectorCID, newSectorInfo2.SealedCID)
 }
 
+func getDealProposal(t *testing.T, v *vm.VM, dealID abi.DealID) *market.DealProposal {
+	var st market.State
+	require.NoError(t, v.GetState(builtin.StorageMarketActorAddr, &st))
+	proposals, err := market.AsDealProposalArray(v.Store(), st.Proposals)
+	require.NoError(t, err)
+	proposal, found, err := proposals.Get(dealID)
+	require.NoError(t, err)
+	require.True(t, found)
+	return proposal
+}
+
+func getMarketBalance(t *testing.T, v *vm.VM, caller, a address.Address) *market.GetBalanceReturn {
+	ret := vm.ApplyOk(t, v, caller, builtin.StorageMarketActorAddr, big.Zero(), builtin.MethodsMarket.GetBalance, &a)
+	return ret.(*market.GetBalanceReturn)
+}
+
 func createDeals(t *testing.T, numberOfDeals int, v *vm.VM, clientAddress address.Address, workerAddress address.Address, minerAddress address.Address, sealProof abi.RegisteredSealProof) []abi.DealID {
 	// add market collateral for client and miner
 	collateral := big.Mul(big.NewInt(int64(3*numberOfDeals)), vm.FIL)
@@ -895,34 +1033,7 @@ func createMinerAndUpgradeASector(t *testing.T) (*vm.VM, *miner.SectorOnChainInf
 
 	// replicaUpdate the sector
 
-	replicaUpdate := miner.ReplicaUpdate{
-		SectorID:           sectorNumber,
-		Deadline:           deadlineIndex,
-		Partition:          partitionIndex,
-		NewSealedSectorCID: tutil.MakeCID("replica1", &miner.SealedCIDPrefix),
-		Deals:              dealIDs,
-		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
-	}
-
-	ret := vm.ApplyOk(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(),
-		builtin.MethodsMiner.ProveReplicaUpdates,
-		&miner.ProveReplicaUpdatesParams{Updates: []miner.ReplicaUpdate{replicaUpdate}})
-
-	updatedSectors, ok := ret.(*bitfield.BitField)
-	require.True(t, ok)
-	count, err := updatedSectors.Count()
-	require.NoError(t, err)
-	require.Equal(t, uint64(1), count)
-
-	isSet, err := updatedSectors.IsSet(uint64(sectorNumber))
-	require.NoError(t, err)
-	require.True(t, isSet)
-
-	newSectorInfo := vm.SectorInfo(t, v, minerAddrs.RobustAddress, sectorNumber)
-	require.Equal(t, 1, len(newSectorInfo.DealIDs))
-	require.Equal(t, dealIDs[0], newSectorInfo.DealIDs[0])
-	require.Equal(t, oldSectorInfo.SealedCID, *newSectorInfo.SectorKeyCID)
-	require.Equal(t, replicaUpdate.NewSealedSectorCID, newSectorInfo.SealedCID)
+	newSectorInfo := vm.UpgradeSector(t, v, minerAddrs.IDAddress, worker, deadlineIndex, partitionIndex, sectorNumber, dealIDs)
 
 	minerPower = vm.MinerPower(t, v, minerAddrs.IDAddress)
 	require.Equal(t, uint64(ss), minerPower.Raw.Uint64())
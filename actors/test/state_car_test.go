@@ -0,0 +1,46 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+	"github.com/filecoin-project/specs-actors/v8/support/vm"
+)
+
+// Tests that a VM exported to a CAR file loads with the same state and conditions, and applies messages as the original
+func TestStateCARRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	v, err := v.WithEpoch(200)
+	require.NoError(t, err)
+	v, err = v.WithNetworkVersion(network.Version15)
+	require.NoError(t, err)
+	v.SetCirculatingSupply(big.Mul(big.NewInt(1e6), vm.FIL))
+
+	path := filepath.Join(t.TempDir(), "state.car")
+	require.NoError(t, v.ExportStateCAR(path))
+	loaded, err := vm.NewVMFromCAR(ctx, path)
+	require.NoError(t, err)
+
+	assert.Equal(t, v.StateRoot(), loaded.StateRoot())
+	assert.Equal(t, v.GetEpoch(), loaded.GetEpoch())
+	assert.Equal(t, v.GetNetworkVersion(), loaded.GetNetworkVersion())
+	assert.Equal(t, v.GetCirculatingSupply(), loaded.GetCirculatingSupply())
+
+	// The same miner is created in both, reaching the same state.
+	createMiner(t, v, addrs[0], addrs[1], abi.RegisteredPoStProof_StackedDrgWindow32GiBV1, big.Mul(big.NewInt(100), vm.FIL))
+	createMiner(t, loaded, addrs[0], addrs[1], abi.RegisteredPoStProof_StackedDrgWindow32GiBV1, big.Mul(big.NewInt(100), vm.FIL))
+	vm.ApplyOk(t, v, builtin.SystemActorAddr, builtin.CronActorAddr, big.Zero(), builtin.MethodsCron.EpochTick, nil)
+	vm.ApplyOk(t, loaded, builtin.SystemActorAddr, builtin.CronActorAddr, big.Zero(), builtin.MethodsCron.EpochTick, nil)
+	assert.Equal(t, v.StateRoot(), loaded.StateRoot())
+}
@@ -142,6 +142,15 @@ func TestTerminateSectors(t *testing.T) {
 		assert.Equal(t, abi.ChainEpoch(-1), state.SlashEpoch)
 	}
 
+	// the sector holds the claims of its verified deals
+	var verifregState verifreg.State
+	require.NoError(t, v.GetState(builtin.VerifiedRegistryActorAddr, &verifregState))
+	claimIDs, err := verifregState.GetSectorClaimIDs(v.Store(), minerAddrs.IDAddress, sectorNumber)
+	require.NoError(t, err)
+	claimCount, err := claimIDs.Count()
+	require.NoError(t, err)
+	assert.Greater(t, claimCount, uint64(0))
+
 	//
 	// Terminate Sector
 	//
@@ -167,10 +176,19 @@ func TestTerminateSectors(t *testing.T) {
 			{To: builtin.BurntFundsActorAddr, Method: builtin.MethodSend, SubInvocations: noSubinvocations},
 			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal, SubInvocations: noSubinvocations},
 			{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.OnMinerSectorsTerminate, SubInvocations: noSubinvocations},
+			{To: builtin.VerifiedRegistryActorAddr, Method: builtin.MethodsVerifiedRegistry.RemoveSectorClaims, SubInvocations: noSubinvocations},
 			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdateClaimedPower, SubInvocations: noSubinvocations},
 		},
 	}.Matches(t, v.LastInvocation())
 
+	// the terminated sector's claims are removed
+	require.NoError(t, v.GetState(builtin.VerifiedRegistryActorAddr, &verifregState))
+	claimIDs, err = verifregState.GetSectorClaimIDs(v.Store(), minerAddrs.IDAddress, sectorNumber)
+	require.NoError(t, err)
+	claimCount, err = claimIDs.Count()
+	require.NoError(t, err)
+	assert.Zero(t, claimCount)
+
 	// expect power, market and miner to be in base state
 	minerBalances := vm.GetMinerBalances(t, v, minerAddrs.IDAddress)
 	assert.Equal(t, big.Zero(), minerBalances.InitialPledge)
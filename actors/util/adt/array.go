@@ -17,6 +17,8 @@ var DefaultAmtOptions = []amt.Option{}
 type Array struct {
 	root  *amt.Root
 	store Store
+	// Whether the array has been modified since it was loaded or last flushed.
+	modified bool
 }
 
 // AsArray interprets a store as an AMT-based array with root `r`.
@@ -57,7 +59,17 @@ func StoreEmptyArray(s Store, bitwidth int) (cid.Cid, error) {
 
 // Returns the root CID of the underlying AMT.
 func (a *Array) Root() (cid.Cid, error) {
-	return a.root.Flush(a.store.Context())
+	c, err := a.root.Flush(a.store.Context())
+	if err != nil {
+		return cid.Undef, err
+	}
+	a.modified = false
+	return c, nil
+}
+
+// Returns whether the array has been modified since it was loaded or its root last computed.
+func (a *Array) Modified() bool {
+	return a.modified
 }
 
 // Appends a value to the end of the array. Assumes continuous array.
@@ -66,6 +78,7 @@ func (a *Array) AppendContinuous(value cbor.Marshaler) error {
 	if err := a.root.Set(a.store.Context(), a.root.Len(), value); err != nil {
 		return xerrors.Errorf("append failed to set index %v value %v in root %v: %w", a.root.Len(), value, a.root, err)
 	}
+	a.modified = true
 	return nil
 }
 
@@ -73,6 +86,7 @@ func (a *Array) Set(i uint64, value cbor.Marshaler) error {
 	if err := a.root.Set(a.store.Context(), i, value); err != nil {
 		return xerrors.Errorf("failed to set index %v value %v in root %v: %w", i, value, a.root, err)
 	}
+	a.modified = true
 	return nil
 }
 
@@ -82,6 +96,7 @@ func (a *Array) TryDelete(i uint64) (bool, error) {
 	if found, err := a.root.Delete(a.store.Context(), i); err != nil {
 		return false, xerrors.Errorf("array delete failed to delete index %v in root %v: %w", i, a.root, err)
 	} else {
+		a.modified = a.modified || found
 		return found, nil
 	}
 }
@@ -93,12 +108,15 @@ func (a *Array) Delete(i uint64) error {
 	} else if !found {
 		return xerrors.Errorf("no such index %v in root %v to delete: %w", i, a.root, err)
 	}
+	a.modified = true
 	return nil
 }
 
 func (a *Array) BatchDelete(ix []uint64, strict bool) error {
-	if _, err := a.root.BatchDelete(a.store.Context(), ix, strict); err != nil {
+	if modified, err := a.root.BatchDelete(a.store.Context(), ix, strict); err != nil {
 		return xerrors.Errorf("failed to batch delete keys %v: %w", ix, err)
+	} else {
+		a.modified = a.modified || modified
 	}
 	return nil
 }
@@ -107,7 +125,19 @@ func (a *Array) BatchDelete(ix []uint64, strict bool) error {
 // Iteration halts if the function returns an error.
 // If the output parameter is nil, deserialization is skipped.
 func (a *Array) ForEach(out cbor.Unmarshaler, fn func(i int64) error) error {
-	return a.root.ForEach(a.store.Context(), func(k uint64, val *cbg.Deferred) error {
+	return a.root.ForEach(a.store.Context(), decodeEach(out, fn))
+}
+
+// Iterates the entries in the array with index at least `start`, in ascending index order,
+// deserializing each value in turn into `out` and then calling a function.
+// Iteration halts if the function returns an error.
+// If the output parameter is nil, deserialization is skipped.
+func (a *Array) ForEachFrom(start uint64, out cbor.Unmarshaler, fn func(i int64) error) error {
+	return a.root.ForEachAt(a.store.Context(), start, decodeEach(out, fn))
+}
+
+func decodeEach(out cbor.Unmarshaler, fn func(i int64) error) func(k uint64, val *cbg.Deferred) error {
+	return func(k uint64, val *cbg.Deferred) error {
 		if out != nil {
 			if deferred, ok := out.(*cbg.Deferred); ok {
 				// fast-path deferred -> deferred to avoid re-decoding.
@@ -117,7 +147,7 @@ func (a *Array) ForEach(out cbor.Unmarshaler, fn func(i int64) error) error {
 			}
 		}
 		return fn(int64(k))
-	})
+	}
 }
 
 func (a *Array) Length() uint64 {
@@ -148,5 +178,6 @@ func (a *Array) Pop(k uint64, out cbor.Unmarshaler) (bool, error) {
 	} else if !found {
 		return false, xerrors.Errorf("can't find index %v to delete in root %v", k, a.root)
 	}
+	a.modified = true
 	return true, nil
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
 
 	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
 	"github.com/filecoin-project/specs-actors/v8/support/mock"
@@ -20,3 +21,31 @@ func TestArrayNotFound(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, found)
 }
+
+func TestArrayForEachFrom(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+	arr, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	for _, i := range []uint64{70, 2, 9, 1000, 8} {
+		val := cbg.CborInt(i)
+		require.NoError(t, arr.Set(i, &val))
+	}
+
+	var val cbg.CborInt
+	var keys []int64
+	err = arr.ForEachFrom(9, &val, func(i int64) error {
+		require.Equal(t, i, int64(val))
+		keys = append(keys, i)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int64{9, 70, 1000}, keys)
+
+	keys = nil
+	require.NoError(t, arr.ForEachFrom(1001, nil, func(i int64) error {
+		keys = append(keys, i)
+		return nil
+	}))
+	require.Empty(t, keys)
+}
@@ -34,6 +34,11 @@ func (t *BalanceTable) Root() (cid.Cid, error) {
 	return (*Map)(t).Root()
 }
 
+// Returns whether any balance has been modified since the table was loaded or its root last computed.
+func (t *BalanceTable) Modified() bool {
+	return (*Map)(t).Modified()
+}
+
 // Gets the balance for a key, which is zero if they key has never been added to.
 func (t *BalanceTable) Get(key addr.Address) (abi.TokenAmount, error) {
 	var value abi.TokenAmount
@@ -26,6 +26,8 @@ type Map struct {
 	lastCid cid.Cid
 	root    *hamt.Node
 	store   Store
+	// Whether the map has been modified since it was loaded or last flushed.
+	modified bool
 }
 
 // AsMap interprets a store as a HAMT-based map with root `r`.
@@ -79,15 +81,22 @@ func (m *Map) Root() (cid.Cid, error) {
 		return cid.Undef, xerrors.Errorf("writing map root object: %w", err)
 	}
 	m.lastCid = c
+	m.modified = false
 
 	return c, nil
 }
 
+// Returns whether the map has been modified since it was loaded or its root last computed.
+func (m *Map) Modified() bool {
+	return m.modified
+}
+
 // Put adds value `v` with key `k` to the hamt store.
 func (m *Map) Put(k abi.Keyer, v cbor.Marshaler) error {
 	if err := m.root.Set(m.store.Context(), k.Key(), v); err != nil {
 		return xerrors.Errorf("failed to set key %v value %v in node %v: %w", k.Key(), v, m.lastCid, err)
 	}
+	m.modified = true
 	return nil
 }
 
@@ -115,6 +124,7 @@ func (m *Map) PutIfAbsent(k abi.Keyer, v cbor.Marshaler) (bool, error) {
 	if modified, err := m.root.SetIfAbsent(m.store.Context(), k.Key(), v); err != nil {
 		return false, xerrors.Errorf("failed to set key %v value %v in node %v: %w", k.Key(), v, m.lastCid, err)
 	} else {
+		m.modified = m.modified || modified
 		return modified, nil
 	}
 }
@@ -125,6 +135,7 @@ func (m *Map) TryDelete(k abi.Keyer) (bool, error) {
 	if found, err := m.root.Delete(m.store.Context(), k.Key()); err != nil {
 		return false, xerrors.Errorf("failed to delete key %v in node %v: %v", k.Key(), m.root, err)
 	} else {
+		m.modified = m.modified || found
 		return found, nil
 	}
 }
@@ -136,6 +147,7 @@ func (m *Map) Delete(k abi.Keyer) error {
 	} else if !found {
 		return xerrors.Errorf("no such key %v to delete in node %v", k.Key(), m.root)
 	}
+	m.modified = true
 	return nil
 }
 
@@ -178,5 +190,6 @@ func (m *Map) Pop(k abi.Keyer, out cbor.Unmarshaler) (bool, error) {
 	} else if !found {
 		return false, xerrors.Errorf("failed to find key %v to delete", k.Key())
 	}
+	m.modified = true
 	return true, nil
 }
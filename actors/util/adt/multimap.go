@@ -50,6 +50,11 @@ func (mm *Multimap) Root() (cid.Cid, error) {
 	return mm.mp.Root()
 }
 
+// Returns whether any key has been modified since the multimap was loaded or its root last computed.
+func (mm *Multimap) Modified() bool {
+	return mm.mp.Modified()
+}
+
 // Adds a value for a key.
 func (mm *Multimap) Add(key abi.Keyer, value cbor.Marshaler) error {
 	// Load the array under key, or initialize a new empty one if not found.
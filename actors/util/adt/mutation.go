@@ -0,0 +1,38 @@
+package adt
+
+import (
+	cid "github.com/ipfs/go-cid"
+)
+
+// The access to a sub-structure of actor state requested of a state mutation, which loads
+// sub-structures for use within a state transaction and writes the modified ones back to state.
+type MutationPermission int
+
+const (
+	// Not loaded by the mutation.
+	PermitNone MutationPermission = iota
+	// Loaded for reading, and never written back to state.
+	PermitReadOnly
+	// Loaded for reading and writing, and written back to state if modified.
+	PermitWrite
+)
+
+// A sub-structure of actor state that tracks whether it has been modified since it was loaded.
+type Flushable interface {
+	Root() (cid.Cid, error)
+	Modified() bool
+}
+
+// Writes a sub-structure loaded with write permission back to the state field holding its root.
+// A sub-structure that has not been modified is not re-serialized, and the root is left unchanged.
+func FlushIfModified(permit MutationPermission, f Flushable, root *cid.Cid) error {
+	if permit != PermitWrite || !f.Modified() {
+		return nil
+	}
+	c, err := f.Root()
+	if err != nil {
+		return err
+	}
+	*root = c
+	return nil
+}
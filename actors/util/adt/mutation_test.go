@@ -0,0 +1,74 @@
+package adt_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v8/support/mock"
+)
+
+func TestFlushIfModified(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	t.Run("unmodified map is not flushed", func(t *testing.T) {
+		m, err := adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+		require.NoError(t, err)
+		root := cid.Undef
+		require.NoError(t, adt.FlushIfModified(adt.PermitWrite, m, &root))
+		assert.Equal(t, cid.Undef, root)
+
+		// A read does not modify the map, nor a delete of an absent key.
+		_, err = m.Has(abi.UIntKey(1))
+		require.NoError(t, err)
+		_, err = m.TryDelete(abi.UIntKey(1))
+		require.NoError(t, err)
+		assert.False(t, m.Modified())
+	})
+
+	t.Run("modified map is flushed with write permission only", func(t *testing.T) {
+		m, err := adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+		require.NoError(t, err)
+		value := cbg.CborInt(1)
+		require.NoError(t, m.Put(abi.UIntKey(1), &value))
+		assert.True(t, m.Modified())
+
+		root := cid.Undef
+		require.NoError(t, adt.FlushIfModified(adt.PermitReadOnly, m, &root))
+		assert.Equal(t, cid.Undef, root)
+
+		require.NoError(t, adt.FlushIfModified(adt.PermitWrite, m, &root))
+		expected, err := m.Root()
+		require.NoError(t, err)
+		assert.Equal(t, expected, root)
+		assert.False(t, m.Modified())
+	})
+
+	t.Run("array tracks modification until flushed", func(t *testing.T) {
+		arr, err := adt.MakeEmptyArray(store, 3)
+		require.NoError(t, err)
+		_, err = arr.TryDelete(7)
+		require.NoError(t, err)
+		assert.False(t, arr.Modified())
+
+		value := cbg.CborInt(1)
+		require.NoError(t, arr.Set(7, &value))
+		assert.True(t, arr.Modified())
+
+		root := cid.Undef
+		require.NoError(t, adt.FlushIfModified(adt.PermitWrite, arr, &root))
+		assert.True(t, root.Defined())
+		assert.False(t, arr.Modified())
+
+		require.NoError(t, arr.Delete(7))
+		assert.True(t, arr.Modified())
+	})
+}
@@ -38,6 +38,11 @@ func (h *Set) Root() (cid.Cid, error) {
 	return h.m.Root()
 }
 
+// Returns whether the set has been modified since it was loaded or its root last computed.
+func (h *Set) Modified() bool {
+	return h.m.Modified()
+}
+
 // Put adds `k` to the set.
 func (h *Set) Put(k abi.Keyer) error {
 	return h.m.Put(k, nil)
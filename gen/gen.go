@@ -3,6 +3,7 @@ package main
 import (
 	gen "github.com/whyrusleeping/cbor-gen"
 
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/account"
 	"github.com/filecoin-project/specs-actors/v8/actors/builtin/cron"
 	init_ "github.com/filecoin-project/specs-actors/v8/actors/builtin/init"
@@ -29,14 +30,16 @@ func main() {
 	//	panic(err)
 	//}
 
-	//if err := gen.WriteTupleEncodersToFile("./actors/builtin/cbor_gen.go", "builtin",
-	//	//builtin.MinerAddrs{}, // Aliased from v0
-	//	//builtin.ConfirmSectorProofsParams{}, // Aliased from v6
-	//	//builtin.DeferredCronEventParams{}, // Aliased from v6
-	//	//builtin.ApplyRewardParams{}, // Aliased from v2
-	//); err != nil {
-	//	panic(err)
-	//}
+	if err := gen.WriteTupleEncodersToFile("./actors/builtin/cbor_gen.go", "builtin",
+		//builtin.MinerAddrs{}, // Aliased from v0
+		builtin.ConfirmSectorProofsParams{},
+		builtin.SectorProofFailure{},
+		//builtin.DeferredCronEventParams{}, // Aliased from v6
+		builtin.ApplyRewardParams{},
+		builtin.TerminateBreachedSectorParams{},
+	); err != nil {
+		panic(err)
+	}
 
 	// if err := gen.WriteTupleEncodersToFile("./actors/states/cbor_gen.go", "states",
 	// 	states.Actor{}, // Aliased from v0
@@ -130,12 +133,29 @@ func main() {
 		power.State{},
 		power.Claim{},
 		power.CronEvent{},
+		power.FaultStreak{},
+		power.CronFailureRecord{},
+		power.PowerCheckpoint{},
+		power.ProofValidationEntry{},
 		// method params and returns
 		//power.CreateMinerParams{}, // Aliased from v3
 		//power.CreateMinerReturn{}, // Aliased from v0
 		//power.EnrollCronEventParams{}, // Aliased from v0
-		//power.UpdateClaimedPowerParams{}, // Aliased from v0
-		//power.CurrentTotalPowerReturn{}, // Aliased from v6
+		power.UpdateClaimedPowerParams{}, // Changed in v8
+		power.UpdatePledgeTotalParams{},
+		power.CurrentTotalPowerReturn{}, // Changed in v8
+		power.SubmitPoRepForBulkVerifyParams{}, // Changed in v8
+		power.RecordProvingPeriodParams{},
+		power.MinerFaultStatusReturn{},
+		power.NetworkVersionReturn{},
+		power.QuarantinedMiner{},
+		power.CronQuarantinedMinersReturn{},
+		power.UpdateClaimedProofTypeParams{},
+		power.PowerCheckpointParams{},
+		power.CurrentTotalPowerBriefReturn{},
+		power.NetworkFaultRateReturn{},
+		power.MinerRawPowerReturn{},
+		power.MinerCountReturn{},
 		// other types
 		//power.MinerConstructorParams{}, // Aliased from v3
 	); err != nil {
@@ -146,19 +166,71 @@ func main() {
 		// actor state
 		market.State{},
 		market.DealState{},
+		market.ProviderAsk{},
 		// method params and returns
 		//market.WithdrawBalanceParams{}, // Aliased from v0
-		// market.PublishStorageDealsParams{}, // Aliased from v0
+		market.PublishStorageDealsParams{},
 		//market.PublishStorageDealsReturn{}, // Aliased from v6
-		//market.ActivateDealsParams{}, // Aliased from v0
+		market.ActivateDealsParams{},
 		//market.VerifyDealsForActivationParams{}, // Aliased from v3
 		//market.VerifyDealsForActivationReturn{}, // Aliased from v3
 		//market.ComputeDataCommitmentParams{}, // Aliased from v5
 		//market.ComputeDataCommitmentReturn{}, // Aliased from v5
 		//market.OnMinerSectorsTerminateParams{}, // Aliased from v0
+		market.OnMinerSectorsTerminateReturn{},
+		market.OnMinerDealsReplacedParams{},
+		market.PostProviderAskParams{},
+		market.WithdrawProviderAskParams{},
+		market.RevokeDealProposalParams{},
+		market.IndexDealLabelsParams{},
+		market.LookupDealsByLabelParams{},
+		market.LookupDealsByLabelReturn{},
+		market.VerifyPieceInclusionParams{},
+		market.AuthorizeEscrowFunderParams{},
+		market.RequestEscrowFundsParams{},
+		market.AuthorizeDealParams{},
+		market.RepairLockedTotalsReturn{},
+		market.SettleDealPaymentsParams{},
+		market.SampleDealsForAuditParams{},
+		market.SampleDealsForAuditReturn{},
+		market.TerminateBreachedDealParams{},
+		market.GetBalanceReturn{},
+		market.GetDealStatusParams{},
+		market.GetDealStatusReturn{},
+		market.PublishStorageDealsFromClientParams{},
+		market.PublishStorageDealsFromClientReturn{},
+		market.AcceptDealProposalsParams{},
+		market.WithdrawDealProposalsParams{},
+		market.DealProposalRef{},
+		market.DealAmendment{},
+		market.AmendDealProposalParams{},
+		market.AmendDealProposalReturn{},
+		market.GetDealActivationParams{},
+		market.GetDealActivationReturn{},
+		market.DealClientTransfer{},
+		market.TransferDealClientParams{},
+		market.TransferDealClientReturn{},
+		market.AddProviderCollateralParams{},
+		market.AddProviderCollateralReturn{},
+		market.RetrievalViolation{},
+		market.ReportRetrievalViolationParams{},
+		market.ReportRetrievalViolationReturn{},
+		market.RetrievalReceipt{},
+		market.DisputeRetrievalViolationParams{},
+		market.SectorDealActivation{},
+		market.BatchActivateDealsParams{},
+		market.BatchActivateDealsReturn{},
+		market.GetDealUpdateEpochParams{},
+		market.GetDealUpdateEpochReturn{},
+		market.PublishStorageDealsAggregatedParams{},
+		market.ContestDealSlashParams{},
 		// other types
-		//market.DealProposal{}, // Aliased from v0
-		//market.ClientDealProposal{},
+		market.PieceInclusionProof{},
+		market.EscrowFunder{},
+		market.LockedTotals{},
+		market.RetrievalSLA{},
+		market.RetrievalViolations{},
+		market.ClientDealProposal{},
 		//market.SectorDeals{}, // Aliased from v3
 		//market.SectorWeights{}, // Aliased from v3
 		//market.SectorDataSpec{}, // Aliased from v5
@@ -182,9 +254,18 @@ func main() {
 		miner.VestingFunds{},
 		miner.VestingFund{},
 		miner.WindowedPoSt{},
+		miner.QueuedRecoveries{},
+		miner.OwnerSettings{},
+		miner.BeneficiaryTerm{},
+		miner.PendingBeneficiaryChange{},
+		miner.MaintenanceWindow{},
+		miner.ReplicaUpdateRollback{},
+		miner.ControlChangeEvent{},
+		miner.ControlChangeHistory{},
 		// method params and returns
 		// miner.ConstructorParams{}, // in power actor
 		//miner.SubmitWindowedPoStParams{}, // Aliased from v0
+		miner.SubmitWindowedPoStReturn{},
 		//miner.TerminateSectorsParams{}, // Aliased from v0
 		//miner.TerminateSectorsReturn{}, // Aliased from v0
 		//miner.ChangePeerIDParams{}, // Aliased from v0
@@ -201,11 +282,61 @@ func main() {
 		//miner.WithdrawBalanceParams{}, // Aliased from v0
 		//miner.CompactPartitionsParams{}, // Aliased from v0
 		//miner.CompactSectorNumbersParams{}, // Aliased from v0
-		//miner.CronEventPayload{}, // Aliased from v0
+		miner.CronEventPayload{},
 		// miner.DisputeWindowedPoStParams{}, // Aliased from v3
 		//miner.PreCommitSectorBatchParams{}, // Aliased from v5
 		//miner.ProveReplicaUpdatesParams{}, // Aliased from v7
+		miner.TerminatedSectorCountsReturn{},
+		miner.PartitionExpirationsParams{},
+		miner.PartitionExpirationsReturn{},
+		miner.SubmitWindowedPoStRelayedParams{},
+		miner.PoStIntent{},
+		miner.DeclareFaultsAndRecoveriesParams{},
+		miner.SectorManifest{},
+		miner.SectorManifestParams{},
+		miner.SectorManifestReturn{},
+		miner.GetVestingFundsReturn{},
+		miner.ChangeBeneficiaryParams{},
+		miner.ActiveBeneficiary{},
+		miner.GetBeneficiaryReturn{},
+		miner.ExtendSectorExpiration2Params{},
+		miner.ExpirationExtension2{},
+		miner.SectorVerifiedClaim{},
+		miner.ProveCommitSectorsNIParams{},
+		miner.SectorNIActivationInfo{},
+		miner.ChangeWindowPoStProofTypeParams{},
+		miner.MovePartitionsParams{},
+		miner.DeclareMaintenanceWindowParams{},
+		miner.ReplicaUpdate2{},
+		miner.ProveReplicaUpdates2Params{},
+		miner.ReplicaUpdateResult{},
+		miner.ProveReplicaUpdates2Return{},
+		miner.EstimateDeadlinePenaltyParams{},
+		miner.EstimateDeadlinePenaltyReturn{},
+		miner.SubmitWindowedPoStAggregateParams{},
+		miner.CancelWorkerChangeParams{},
+		miner.PreCommitSectorBatchReturn{},
+		miner.SetNotificationReceiverParams{},
+		miner.PreCommitsExpiredParams{},
+		miner.SetExpirationReminderParams{},
+		miner.SectorsExpiringParams{},
+		miner.DeadlineSectorCountsReturn{},
+		miner.RebalanceSectorsParams{},
+		miner.FindSectorParams{},
+		miner.FindSectorReturn{},
+		miner.DroppedCronEventsReturn{},
+		miner.RollbackReplicaUpdatesParams{},
+		miner.EstimateAggregateFeesParams{},
+		miner.AggregateFeeEstimate{},
+		miner.EstimateAggregateFeesReturn{},
+		miner.GetControlChangeHistoryReturn{},
+		miner.ProveCommitSector2Params{},
+		miner.PreCommitSectorBatch2Params{},
+		miner.TerminateSectors2Params{},
+		miner.DeclareFaultsRecovered2Params{},
+		miner.CompactPartitions2Params{},
 		// other types
+		miner.PartitionExpiration{},
 		//miner.FaultDeclaration{}, // Aliased from v0
 		//miner.RecoveryDeclaration{}, // Aliased from v0
 		//miner.ExpirationExtension{}, // Aliased from v0
@@ -227,10 +358,28 @@ func main() {
 		//verifreg.RestoreBytesParams{}, // Aliased from v0
 		verifreg.RemoveDataCapParams{}, // New in v7
 		verifreg.RemoveDataCapReturn{}, // New in v7
+		verifreg.RecordActivatedBytesParams{},
+		verifreg.DataCapUsageReturn{},
+		verifreg.RestoreBytesBatchParams{},
+		verifreg.RestoreBytesBatchReturn{},
+		verifreg.VerifySectorClaimsParams{},
+		verifreg.VerifySectorClaimsReturn{},
+		verifreg.CreateAllocationParams{},
+		verifreg.CreateAllocationReturn{},
+		verifreg.ClaimAllocationsParams{},
+		verifreg.RemoveExpiredAllocationsParams{},
+		verifreg.RemoveExpiredAllocationsReturn{},
+		verifreg.RemoveSectorClaimsParams{},
+		verifreg.GetClientAllocationsReturn{},
 		// other types
 		verifreg.RemoveDataCapRequest{},  // New in v7
 		verifreg.RemoveDataCapProposal{}, // New in v7
 		verifreg.RmDcProposalID{},        // New in v7
+		verifreg.ActivatedBytes{},
+		verifreg.RestoreBytesFailure{},
+		verifreg.Allocation{},
+		verifreg.Claim{},
+		verifreg.SectorClaims{},
 	); err != nil {
 		panic(err)
 	}
@@ -246,6 +395,8 @@ func main() {
 		vm.ChainMessage{},
 		vm.StateInfo0{},
 		vm.StateRoot{},
+		vm.DealClientState{},
+		vm.SnapshotConditions{},
 	); err != nil {
 		panic(err)
 	}
@@ -71,10 +71,12 @@ type Runtime struct {
 	expectRandomnessTickets        []*expectRandomness
 	expectSends                    []*expectedMessage
 	expectVerifySigs               []*expectVerifySig
+	expectVerifyAggregateSig       *expectVerifyAggregateSig
 	expectCreateActor              *expectCreateActor
 	expectVerifySeal               *expectVerifySeal
 	expectComputeUnsealedSectorCID []*expectComputeUnsealedSectorCID
 	expectVerifyPoSt               *expectVerifyPoSt
+	expectVerifyAggregatePoSt      *expectVerifyAggregatePoSt
 	expectVerifyConsensusFault     *expectVerifyConsensusFault
 	expectDeleteActor              *addr.Address
 	expectBatchVerifySeals         *expectBatchVerifySeals
@@ -122,6 +124,9 @@ type expectedMessage struct {
 	// returns from applying expectedMessage
 	sendReturn cbor.Er
 	exitCode   exitcode.ExitCode
+
+	// simulates effects of the callee on the calling actor, such as a re-entrant call
+	effect func()
 }
 
 type expectVerifySig struct {
@@ -133,6 +138,15 @@ type expectVerifySig struct {
 	result error
 }
 
+type expectVerifyAggregateSig struct {
+	// Expected arguments
+	sig        crypto.Signature
+	signers    []addr.Address
+	plaintexts [][]byte
+	// Result
+	result error
+}
+
 type expectVerifySeal struct {
 	seal   proof.SealVerifyInfo
 	result error
@@ -150,6 +164,11 @@ type expectVerifyPoSt struct {
 	result error
 }
 
+type expectVerifyAggregatePoSt struct {
+	post   proof.AggregateWindowPoStVerifyInfo
+	result error
+}
+
 func (m *expectedMessage) Equal(to addr.Address, method abi.MethodNum, params cbor.Marshaler, value abi.TokenAmount) bool {
 	// avoid nil vs. zero/empty discrepancies that would disappear in serialization
 	paramBuf1 := new(bytes.Buffer)
@@ -377,6 +396,10 @@ func (rt *Runtime) Send(toAddr addr.Address, methodNum abi.MethodNum, params cbo
 		rt.balance = big.Sub(rt.balance, value)
 	}()
 
+	if exp.effect != nil {
+		exp.effect()
+	}
+
 	// populate the output argument
 	var buf bytes.Buffer
 	err := exp.sendReturn.MarshalCBOR(&buf)
@@ -591,6 +614,23 @@ func (rt *Runtime) VerifySignature(sig crypto.Signature, signer addr.Address, pl
 	return nil
 }
 
+func (rt *Runtime) VerifyAggregateSignature(sig crypto.Signature, signers []addr.Address, plaintexts [][]byte) error {
+	exp := rt.expectVerifyAggregateSig
+	if exp == nil {
+		rt.failTestNow("unexpected syscall to verify aggregate signature %v, signers %v, plaintexts %v", sig, signers, plaintexts)
+	}
+	if !exp.sig.Equals(&sig) || !reflect.DeepEqual(exp.signers, signers) || !reflect.DeepEqual(exp.plaintexts, plaintexts) {
+		rt.failTest("unexpected aggregate signature verification\n"+
+			"         sig: %v, signers: %v, plaintexts: %v\n"+
+			"expected sig: %v, signers: %v, plaintexts: %v",
+			sig, signers, plaintexts, exp.sig, exp.signers, exp.plaintexts)
+	}
+	defer func() {
+		rt.expectVerifyAggregateSig = nil
+	}()
+	return exp.result
+}
+
 func (rt *Runtime) HashBlake2b(data []byte) [32]byte {
 	return rt.hashfunc(data)
 }
@@ -743,6 +783,24 @@ func (rt *Runtime) VerifyPoSt(vi proof.WindowPoStVerifyInfo) error {
 	return nil
 }
 
+func (rt *Runtime) VerifyAggregatePoSt(vi proof.AggregateWindowPoStVerifyInfo) error {
+	exp := rt.expectVerifyAggregatePoSt
+	if exp != nil {
+		if !reflect.DeepEqual(exp.post, vi) {
+			rt.failTest("unexpected aggregate PoSt verification\n"+
+				"        : %v\n"+
+				"expected: %v",
+				vi, exp.post)
+		}
+		defer func() {
+			rt.expectVerifyAggregatePoSt = nil
+		}()
+		return exp.result
+	}
+	rt.failTestNow("unexpected syscall to verify aggregate PoSt %v", vi)
+	return nil
+}
+
 func (rt *Runtime) VerifyConsensusFault(h1, h2, extra []byte) (*runtime.ConsensusFault, error) {
 	if rt.expectVerifyConsensusFault == nil {
 		rt.failTestNow("Unexpected syscall VerifyConsensusFault")
@@ -925,6 +983,13 @@ func (rt *Runtime) ExpectSend(toAddr addr.Address, methodNum abi.MethodNum, para
 	})
 }
 
+// Expects a send as ExpectSend, and runs effect when the send is made. The effect may modify the
+// runtime's state or balance to simulate the callee calling back into the actor under test.
+func (rt *Runtime) ExpectSendWithEffect(toAddr addr.Address, methodNum abi.MethodNum, params cbor.Marshaler, value abi.TokenAmount, ret cbor.Er, exitCode exitcode.ExitCode, effect func()) {
+	rt.ExpectSend(toAddr, methodNum, params, value, ret, exitCode)
+	rt.expectSends[len(rt.expectSends)-1].effect = effect
+}
+
 func (rt *Runtime) ExpectVerifySignature(sig crypto.Signature, signer addr.Address, plaintext []byte, result error) {
 	rt.expectVerifySigs = append(rt.expectVerifySigs, &expectVerifySig{
 		sig:       sig,
@@ -934,6 +999,15 @@ func (rt *Runtime) ExpectVerifySignature(sig crypto.Signature, signer addr.Addre
 	})
 }
 
+func (rt *Runtime) ExpectVerifyAggregateSignature(sig crypto.Signature, signers []addr.Address, plaintexts [][]byte, result error) {
+	rt.expectVerifyAggregateSig = &expectVerifyAggregateSig{
+		sig:        sig,
+		signers:    signers,
+		plaintexts: plaintexts,
+		result:     result,
+	}
+}
+
 func (rt *Runtime) ExpectCreateActor(codeId cid.Cid, address addr.Address) {
 	rt.expectCreateActor = &expectCreateActor{
 		codeId:  codeId,
@@ -983,6 +1057,13 @@ func (rt *Runtime) ExpectVerifyPoSt(post proof.WindowPoStVerifyInfo, result erro
 	}
 }
 
+func (rt *Runtime) ExpectVerifyAggregatePoSt(post proof.AggregateWindowPoStVerifyInfo, result error) {
+	rt.expectVerifyAggregatePoSt = &expectVerifyAggregatePoSt{
+		post:   post,
+		result: result,
+	}
+}
+
 func (rt *Runtime) ExpectVerifyConsensusFault(h1, h2, extra []byte, result *runtime.ConsensusFault, resultErr error) {
 	rt.expectVerifyConsensusFault = &expectVerifyConsensusFault{
 		requireCorrectInput: true,
@@ -1018,6 +1099,9 @@ func (rt *Runtime) Verify() {
 	if len(rt.expectVerifySigs) > 0 {
 		rt.failTest("missing expected verify signature %v", rt.expectVerifySigs)
 	}
+	if rt.expectVerifyAggregateSig != nil {
+		rt.failTest("missing expected verify aggregate signature %v", rt.expectVerifyAggregateSig)
+	}
 	if len(rt.expectComputeUnsealedSectorCID) > 0 {
 		rt.failTest("missing expected ComputeUnsealedSectorCID with %v", rt.expectComputeUnsealedSectorCID)
 	}
@@ -1046,6 +1130,10 @@ func (rt *Runtime) Verify() {
 		rt.failTest("missing expected PoSt verification with %v", rt.expectVerifyPoSt)
 	}
 
+	if rt.expectVerifyAggregatePoSt != nil {
+		rt.failTest("missing expected aggregate PoSt verification with %v", rt.expectVerifyAggregatePoSt)
+	}
+
 	if rt.expectVerifyConsensusFault != nil {
 		rt.failTest("missing expected verify consensus fault")
 	}
@@ -1066,6 +1154,7 @@ func (rt *Runtime) Reset() {
 	rt.expectSends = nil
 	rt.expectCreateActor = nil
 	rt.expectVerifySigs = nil
+	rt.expectVerifyAggregateSig = nil
 	rt.expectVerifySeal = nil
 	rt.expectBatchVerifySeals = nil
 	rt.expectComputeUnsealedSectorCID = nil
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Import path of the package declaring the singleton actor addresses and method numbers.
+const builtinImportPath = "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+
+// Actor name used for a send whose target can't be determined statically.
+const anyTarget = "*"
+
+// Singleton actor addresses declared by the builtin package, by the actor to which they belong.
+var singletonActors = map[string]string{
+	"SystemActorAddr":           "system",
+	"InitActorAddr":             "init",
+	"RewardActorAddr":           "reward",
+	"CronActorAddr":             "cron",
+	"StoragePowerActorAddr":     "power",
+	"StorageMarketActorAddr":    "market",
+	"VerifiedRegistryActorAddr": "verifreg",
+	"BurntFundsActorAddr":       "burntfunds",
+}
+
+// Method number tables declared by the builtin package, by the actor whose methods they number.
+var methodTableActors = map[string]string{
+	"MethodsAccount":          "account",
+	"MethodsInit":             "init",
+	"MethodsCron":             "cron",
+	"MethodsReward":           "reward",
+	"MethodsMultisig":         "multisig",
+	"MethodsPaych":            "paych",
+	"MethodsMarket":           "market",
+	"MethodsPower":            "power",
+	"MethodsMiner":            "miner",
+	"MethodsVerifiedRegistry": "verifreg",
+}
+
+// A message sent by an actor method, directly or through the functions it calls.
+// Both ends are named "actor.Method". The target is "*" where it depends on state or parameters.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// The calls between built-in actors, ordered by caller then target.
+type Graph struct {
+	Edges []Edge `json:"edges"`
+}
+
+// Returns the distinct dependencies of actors on the methods of other actors, as "actor -> actor.Method".
+func (g *Graph) Dependencies() []string {
+	seen := map[string]struct{}{}
+	var deps []string
+	for _, e := range g.Edges {
+		dep := actorOf(e.From) + " -> " + e.To
+		if _, ok := seen[dep]; ok {
+			continue
+		}
+		seen[dep] = struct{}{}
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+func actorOf(method string) string {
+	return method[:strings.Index(method, ".")]
+}
+
+// The non-test source of a package.
+type sourcePackage struct {
+	// Functions by name, and methods by "Type.Name".
+	funcs map[string]*ast.FuncDecl
+	// Keys of methods in funcs by method name, regardless of receiver type.
+	methods map[string][]string
+	// Names by which each file imports the builtin package.
+	builtinNames map[*ast.File]string
+	files        []*ast.File
+	// Whether this is the builtin package itself.
+	isBuiltin bool
+}
+
+// Analyzes the actor packages under a directory holding the builtin package, such as actors/builtin,
+// returning the messages sent by each of their exported methods.
+//
+// The analysis is syntactic. Calls are followed within an actor's package and into the builtin package.
+// A method call is followed to every method of that name in the package, since receiver types aren't resolved,
+// so the graph may overstate the sends reachable from a method, but not omit any.
+func Analyze(dir string) (*Graph, error) {
+	fset := token.NewFileSet()
+	builtin, err := parseSourcePackage(fset, dir)
+	if err != nil {
+		return nil, err
+	}
+	builtin.isBuiltin = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	graph := &Graph{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		pkg, err := parseSourcePackage(fset, filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		exports, ok := pkg.funcs["Actor.Exports"]
+		if !ok {
+			continue
+		}
+		a := &analyzer{builtin: builtin}
+		for _, method := range exportedMethods(exports) {
+			targets := map[string]struct{}{}
+			a.collect(pkg, "Actor."+method, map[*ast.FuncDecl]bool{}, targets)
+			for to := range targets { //nolint:nomaprange
+				graph.Edges = append(graph.Edges, Edge{From: entry.Name() + "." + method, To: to})
+			}
+		}
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph, nil
+}
+
+func parseSourcePackage(fset *token.FileSet, dir string) (*sourcePackage, error) {
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	sp := &sourcePackage{
+		funcs:        map[string]*ast.FuncDecl{},
+		methods:      map[string][]string{},
+		builtinNames: map[*ast.File]string{},
+	}
+	for _, pkg := range pkgs { //nolint:nomaprange
+		for _, file := range pkg.Files { //nolint:nomaprange
+			sp.files = append(sp.files, file)
+			for _, imp := range file.Imports {
+				if path, _ := strconv.Unquote(imp.Path.Value); path == builtinImportPath {
+					sp.builtinNames[file] = "builtin"
+					if imp.Name != nil {
+						sp.builtinNames[file] = imp.Name.Name
+					}
+				}
+			}
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil {
+					continue
+				}
+				key := fn.Name.Name
+				if fn.Recv != nil && len(fn.Recv.List) == 1 {
+					key = receiverType(fn.Recv.List[0].Type) + "." + fn.Name.Name
+					sp.methods[fn.Name.Name] = append(sp.methods[fn.Name.Name], key)
+				}
+				sp.funcs[key] = fn
+			}
+		}
+	}
+	for name := range sp.methods { //nolint:nomaprange
+		sort.Strings(sp.methods[name])
+	}
+	return sp, nil
+}
+
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	default:
+		return ""
+	}
+}
+
+// Returns the names of the Actor methods listed by an Exports function.
+func exportedMethods(exports *ast.FuncDecl) []string {
+	var methods []string
+	ast.Inspect(exports.Body, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := kv.Value.(*ast.SelectorExpr); ok {
+			methods = append(methods, sel.Sel.Name)
+		}
+		return false
+	})
+	return methods
+}
+
+type analyzer struct {
+	builtin *sourcePackage
+}
+
+// Collects the targets of sends made by a function and the functions it calls.
+func (a *analyzer) collect(pkg *sourcePackage, key string, visited map[*ast.FuncDecl]bool, targets map[string]struct{}) {
+	fn, ok := pkg.funcs[key]
+	if !ok || visited[fn] {
+		return
+	}
+	visited[fn] = true
+	builtinName := pkg.builtinNameFor(fn)
+	ident := func(expr ast.Expr) string {
+		return builtinIdent(expr, builtinName, pkg.isBuiltin)
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if isSend(call) {
+			targets[sendTarget(call, ident)] = struct{}{}
+		}
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			a.collect(pkg, fun.Name, visited, targets)
+		case *ast.SelectorExpr:
+			if x, ok := fun.X.(*ast.Ident); ok && builtinName != "" && x.Name == builtinName {
+				a.collect(a.builtin, fun.Sel.Name, visited, targets)
+				break
+			}
+			for _, method := range pkg.methods[fun.Sel.Name] {
+				a.collect(pkg, method, visited, targets)
+			}
+		}
+		return true
+	})
+}
+
+// Returns the name by which the file declaring a function imports the builtin package, if it does.
+func (sp *sourcePackage) builtinNameFor(fn *ast.FuncDecl) string {
+	for _, file := range sp.files {
+		if file.Pos() <= fn.Pos() && fn.End() <= file.End() {
+			return sp.builtinNames[file]
+		}
+	}
+	return ""
+}
+
+// Whether a call is of a runtime's Send method, which takes a receiver, method, params, value and return value.
+func isSend(call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Send" && len(call.Args) == 5
+}
+
+// Returns the target of a send as "actor.Method", determined by the receiver address if it is a singleton,
+// or else by the table of method numbers from which the method is taken.
+// The ident function names the builtin package identifier to which an expression refers, if any.
+func sendTarget(call *ast.CallExpr, ident func(ast.Expr) string) string {
+	actor := singletonActors[ident(call.Args[0])]
+
+	method := anyTarget
+	if m, ok := call.Args[1].(*ast.SelectorExpr); ok && methodTableActors[ident(m.X)] != "" {
+		// A method number from a table, such as builtin.MethodsPower.UpdateClaimedPower.
+		if actor == "" {
+			actor = methodTableActors[ident(m.X)]
+		}
+		method = m.Sel.Name
+	} else if name := ident(call.Args[1]); strings.HasPrefix(name, "Method") {
+		// A universal method number, such as builtin.MethodSend.
+		method = strings.TrimPrefix(name, "Method")
+	}
+
+	if actor == "" {
+		actor = anyTarget
+	}
+	return actor + "." + method
+}
+
+// Returns the name of a package-level identifier of the builtin package: a qualified identifier
+// in an actor package, or an unqualified one within the builtin package itself.
+func builtinIdent(expr ast.Expr, builtinName string, inBuiltin bool) string {
+	switch e := expr.(type) {
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && builtinName != "" && x.Name == builtinName {
+			return e.Sel.Name
+		}
+	case *ast.Ident:
+		// Identifiers declared in another file of the package are left unresolved by the parser.
+		if inBuiltin && e.Obj == nil {
+			return e.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDependenciesAllowed(t *testing.T) {
+	graph, err := Analyze("../../../actors/builtin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile("allowlist.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var allowlist []string
+	if err := json.Unmarshal(raw, &allowlist); err != nil {
+		t.Fatal(err)
+	}
+	allowed := map[string]bool{}
+	for _, dep := range allowlist {
+		allowed[dep] = true
+	}
+
+	found := map[string]bool{}
+	var unexpected []string
+	for _, dep := range graph.Dependencies() {
+		found[dep] = true
+		if !allowed[dep] {
+			unexpected = append(unexpected, dep)
+		}
+	}
+	if len(unexpected) > 0 {
+		t.Errorf("actors send messages not in allowlist.json:\n\t%s\nadd them if the new dependencies are intended",
+			strings.Join(unexpected, "\n\t"))
+	}
+
+	var stale []string
+	for _, dep := range allowlist {
+		if !found[dep] {
+			stale = append(stale, dep)
+		}
+	}
+	if len(stale) > 0 {
+		t.Errorf("allowlist.json lists dependencies no longer found, which should be removed:\n\t%s",
+			strings.Join(stale, "\n\t"))
+	}
+}
+
+func TestSendTargets(t *testing.T) {
+	graph, err := Analyze("../../../actors/builtin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := map[Edge]bool{}
+	for _, e := range graph.Edges {
+		edges[e] = true
+	}
+
+	for _, e := range []Edge{
+		// A singleton receiver and method table.
+		{From: "miner.SubmitWindowedPoSt", To: "power.UpdateClaimedPower"},
+		// A method table with a receiver from parameters.
+		{From: "reward.AwardBlockReward", To: "miner.ApplyRewards"},
+		// A send made by a function in the builtin package.
+		{From: "market.PublishStorageDeals", To: "miner.ControlAddresses"},
+		// A receiver and method from parameters.
+		{From: "multisig.Approve", To: "*.*"},
+	} {
+		if !edges[e] {
+			t.Errorf("expected edge %s -> %s", e.From, e.To)
+		}
+	}
+	if edges[Edge{From: "account.PubkeyAddress", To: "*.*"}] {
+		t.Errorf("unexpected send from account actor")
+	}
+}
@@ -0,0 +1,70 @@
+// Command callgraph generates the graph of messages sent between built-in actors, from the methods each
+// actor exports to the actor methods they call, as JSON or in the DOT language.
+//
+// Usage, from the support/tools directory:
+//
+//	go run ./callgraph [-format json|dot] [-deps] [path/to/actors/builtin]
+//
+// The graph of the tree's actors is checked against allowlist.json by this package's tests,
+// which must be updated to accept a new dependency of one actor on another.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	format := flag.String("format", "json", "output format: json or dot")
+	deps := flag.Bool("deps", false, "output only the distinct dependencies of each actor, as checked against the allow-list")
+	flag.Parse()
+
+	dir := "../../actors/builtin"
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	graph, err := Analyze(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to analyze %s: %s\n", dir, err)
+		os.Exit(1)
+	}
+
+	switch {
+	case *deps:
+		err = writeJSON(os.Stdout, graph.Dependencies())
+	case *format == "json":
+		err = writeJSON(os.Stdout, graph)
+	case *format == "dot":
+		err = writeDOT(os.Stdout, graph)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+func writeDOT(w io.Writer, graph *Graph) error {
+	if _, err := fmt.Fprintln(w, "digraph actors {"); err != nil {
+		return err
+	}
+	for _, e := range graph.Edges {
+		if _, err := fmt.Fprintf(w, "\t%q -> %q;\n", e.From, e.To); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/cbor"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	initactor "github.com/filecoin-project/specs-actors/v8/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
+	actor_testing "github.com/filecoin-project/specs-actors/v8/support/testing"
+)
+
+// Code CID of the DealClientActor, which is not a built-in actor.
+var DealClientActorCodeID cid.Cid
+
+func init() {
+	var err error
+	DealClientActorCodeID, err = cid.V1Builder{Codec: cid.Raw, MhType: mh.IDENTITY}.Sum([]byte("fil/test/dealclient"))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Method numbers of the DealClientActor.
+const (
+	DealClientMethodAuthorizeDeal = market.MethodAuthorizeDeal
+	DealClientMethodApproveDeal   = abi.MethodNum(4)
+)
+
+// A test actor that is the client of storage deals approved by its owner, in the manner of a DataDAO.
+// It has no key with which to sign deal proposals, so authorizes each approved proposal once
+// when the market publishes it.
+type DealClientActor struct{}
+
+type DealClientState struct {
+	Owner address.Address
+	// CIDs of approved proposals not yet authorized for publication.
+	Approved []cid.Cid
+}
+
+func (a DealClientActor) Exports() []interface{} {
+	return []interface{}{
+		DealClientMethodAuthorizeDeal: a.AuthorizeDeal,
+		DealClientMethodApproveDeal:   a.ApproveDeal,
+	}
+}
+
+func (a DealClientActor) Code() cid.Cid {
+	return DealClientActorCodeID
+}
+
+func (a DealClientActor) IsSingleton() bool {
+	return false
+}
+
+func (a DealClientActor) State() cbor.Er {
+	return new(DealClientState)
+}
+
+var _ runtime.VMActor = DealClientActor{}
+
+// Approves a deal proposal for publication, identified by the CID of the proposal with ID addresses.
+func (a DealClientActor) ApproveDeal(rt runtime.Runtime, params *market.AuthorizeDealParams) *abi.EmptyValue {
+	var st DealClientState
+	rt.StateTransaction(&st, func() {
+		rt.ValidateImmediateCallerIs(st.Owner)
+		st.Approved = append(st.Approved, params.ProposalCid)
+	})
+	return nil
+}
+
+// Authorizes the publication of an approved deal proposal, consuming the approval.
+func (a DealClientActor) AuthorizeDeal(rt runtime.Runtime, params *market.AuthorizeDealParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerIs(builtin.StorageMarketActorAddr)
+	var st DealClientState
+	rt.StateTransaction(&st, func() {
+		for i, approved := range st.Approved {
+			if approved.Equals(params.ProposalCid) {
+				st.Approved = append(st.Approved[:i], st.Approved[i+1:]...)
+				return
+			}
+		}
+		rt.Abortf(exitcode.ErrForbidden, "deal proposal %s not approved", params.ProposalCid)
+	})
+	return nil
+}
+
+// Creates a DealClientActor owned by the given address, registering its implementation with the VM.
+// Returns the new actor's ID address.
+func CreateDealClient(ctx context.Context, t testing.TB, vm *VM, owner address.Address, seed string) address.Address {
+	vm.ActorImpls[DealClientActorCodeID] = DealClientActor{}
+
+	var initState initactor.State
+	err := vm.GetState(builtin.InitActorAddr, &initState)
+	require.NoError(t, err)
+	idAddr, err := initState.MapAddressToNewID(vm.store, actor_testing.NewActorAddr(t, seed))
+	require.NoError(t, err)
+	err = vm.SetActorState(ctx, builtin.InitActorAddr, &initState)
+	require.NoError(t, err)
+
+	ownerID, found := vm.NormalizeAddress(owner)
+	require.True(t, found)
+	initializeActor(ctx, t, vm, &DealClientState{Owner: ownerID, Approved: []cid.Cid{}}, DealClientActorCodeID, idAddr, big.Zero())
+	return idAddr
+}
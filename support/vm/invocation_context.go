@@ -232,6 +232,11 @@ func (ic *invocationContext) VerifySignature(signature crypto.Signature, signer
 	return ic.Syscalls().VerifySignature(signature, signer, plaintext)
 }
 
+func (ic *invocationContext) VerifyAggregateSignature(signature crypto.Signature, signers []address.Address, plaintexts [][]byte) error {
+	ic.topLevel.fakeSyscallsAccessed = true
+	return ic.Syscalls().VerifyAggregateSignature(signature, signers, plaintexts)
+}
+
 func (ic *invocationContext) HashBlake2b(data []byte) [32]byte {
 	ic.topLevel.chargeGas(ic.topLevel.gasPrices.OnHashing(len(data)))
 	ic.topLevel.fakeSyscallsAccessed = true
@@ -272,6 +277,11 @@ func (ic *invocationContext) VerifyPoSt(vi proof.WindowPoStVerifyInfo) error {
 	return ic.Syscalls().VerifyPoSt(vi)
 }
 
+func (ic *invocationContext) VerifyAggregatePoSt(vi proof.AggregateWindowPoStVerifyInfo) error {
+	ic.topLevel.fakeSyscallsAccessed = true
+	return ic.Syscalls().VerifyAggregatePoSt(vi)
+}
+
 func (ic *invocationContext) VerifyConsensusFault(h1, h2, extra []byte) (*runtime.ConsensusFault, error) {
 	ic.topLevel.fakeSyscallsAccessed = true
 	ic.topLevel.chargeGas(ic.topLevel.gasPrices.OnVerifyConsensusFault())
@@ -504,7 +514,7 @@ func (ic *invocationContext) StartSpan(_ string) func() {
 
 // Provides the system call interface.
 func (ic *invocationContext) Syscalls() runtime.Syscalls {
-	return fakeSyscalls{receiver: ic.msg.to, epoch: ic.rt.currentEpoch}
+	return fakeSyscalls{receiver: ic.msg.to, epoch: ic.rt.currentEpoch, verifiers: ic.rt.proofVerifiers}
 }
 
 // Note events that may make debugging easier
@@ -532,8 +542,9 @@ func (r returnWrapper) Into(o cbor.Unmarshaler) error {
 /////////////////////////////////////////////
 
 type fakeSyscalls struct {
-	receiver address.Address
-	epoch    abi.ChainEpoch
+	receiver  address.Address
+	epoch     abi.ChainEpoch
+	verifiers *ProofVerifiers
 }
 
 func (s fakeSyscalls) VerifySignature(sig crypto.Signature, _ address.Address, msg []byte) error {
@@ -544,6 +555,20 @@ func (s fakeSyscalls) VerifySignature(sig crypto.Signature, _ address.Address, m
 	return nil
 }
 
+// A fake aggregate signature is valid if it equals the concatenation of the plaintexts.
+func (s fakeSyscalls) VerifyAggregateSignature(sig crypto.Signature, signers []address.Address, msgs [][]byte) error {
+	if sig.Type != crypto.SigTypeBLS {
+		return xerrors.Errorf("invalid aggregate sig type %d", sig.Type)
+	}
+	if len(signers) != len(msgs) {
+		return xerrors.Errorf("%d signers for %d messages", len(signers), len(msgs))
+	}
+	if !bytes.Equal(sig.Data, bytes.Join(msgs, nil)) {
+		return xerrors.New("invalid aggregate sig: should be equal to concatenated messages")
+	}
+	return nil
+}
+
 func (s fakeSyscalls) HashBlake2b(b []byte) [32]byte {
 	return blake2b.Sum256(b)
 }
@@ -560,7 +585,10 @@ func (s fakeSyscalls) ComputeUnsealedSectorCID(_ abi.RegisteredSealProof, _ []ab
 	return testing.MakeCID("presealedSectorCID", &UnsealedCIDPrefix), nil
 }
 
-func (s fakeSyscalls) VerifySeal(_ proof.SealVerifyInfo) error {
+func (s fakeSyscalls) VerifySeal(info proof.SealVerifyInfo) error {
+	if v, ok := s.verifiers.sealVerifier(info.SealProof); ok {
+		return v(info)
+	}
 	return nil
 }
 
@@ -568,9 +596,9 @@ func (s fakeSyscalls) BatchVerifySeals(vi map[address.Address][]proof.SealVerify
 	res := map[address.Address][]bool{}
 	for addr, infos := range vi { //nolint:nomaprange
 		verified := make([]bool, len(infos))
-		for i := range infos {
-			// everyone wins
-			verified[i] = true
+		for i, info := range infos {
+			// everyone wins, unless a registered verifier says otherwise
+			verified[i] = s.VerifySeal(info) == nil
 		}
 		res[addr] = verified
 	}
@@ -586,6 +614,12 @@ func (s fakeSyscalls) VerifyReplicaUpdate(replicaInfo proof.ReplicaUpdateInfo) e
 }
 
 func (s fakeSyscalls) VerifyPoSt(info proof.WindowPoStVerifyInfo) error {
+	// A window PoSt's proofs share a single proof type.
+	if len(info.Proofs) > 0 {
+		if v, ok := s.verifiers.postVerifier(info.Proofs[0].PoStProof); ok {
+			return v(info)
+		}
+	}
 	for _, postProof := range info.Proofs {
 		if bytes.Equal(postProof.ProofBytes, []byte(InvalidProof)) {
 			return xerrors.New("invalid post")
@@ -594,6 +628,13 @@ func (s fakeSyscalls) VerifyPoSt(info proof.WindowPoStVerifyInfo) error {
 	return nil
 }
 
+func (s fakeSyscalls) VerifyAggregatePoSt(info proof.AggregateWindowPoStVerifyInfo) error {
+	if bytes.Equal(info.AggregateProof.ProofBytes, []byte(InvalidProof)) {
+		return xerrors.New("invalid aggregate post")
+	}
+	return nil
+}
+
 func (s fakeSyscalls) VerifyConsensusFault(_, _, _ []byte) (*runtime.ConsensusFault, error) {
 	return &runtime.ConsensusFault{
 		Target: s.receiver,
@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/runtime/proof"
+)
+
+// Verifies a single seal proof in place of the VM's fake seal verification.
+type SealVerifier func(info proof.SealVerifyInfo) error
+
+// Verifies a window PoSt in place of the VM's fake PoSt verification.
+type PoStVerifier func(info proof.WindowPoStVerifyInfo) error
+
+// ProofVerifiers holds alternative verifier implementations keyed by proof type.
+// This allows proof types still under development to be exercised against the actors
+// before their parameters are settled. Proof types without a registered verifier
+// fall back to the fake syscalls.
+type ProofVerifiers struct {
+	seal map[abi.RegisteredSealProof]SealVerifier
+	post map[abi.RegisteredPoStProof]PoStVerifier
+}
+
+func NewProofVerifiers() *ProofVerifiers {
+	return &ProofVerifiers{
+		seal: make(map[abi.RegisteredSealProof]SealVerifier),
+		post: make(map[abi.RegisteredPoStProof]PoStVerifier),
+	}
+}
+
+// Registers a verifier for seal proofs of a type, replacing any previously registered.
+func (p *ProofVerifiers) RegisterSealVerifier(sealProof abi.RegisteredSealProof, v SealVerifier) {
+	p.seal[sealProof] = v
+}
+
+// Registers a verifier for window PoSt proofs of a type, replacing any previously registered.
+func (p *ProofVerifiers) RegisterPoStVerifier(postProof abi.RegisteredPoStProof, v PoStVerifier) {
+	p.post[postProof] = v
+}
+
+func (p *ProofVerifiers) sealVerifier(sealProof abi.RegisteredSealProof) (SealVerifier, bool) {
+	if p == nil {
+		return nil, false
+	}
+	v, ok := p.seal[sealProof]
+	return v, ok
+}
+
+func (p *ProofVerifiers) postVerifier(postProof abi.RegisteredPoStProof) (PoStVerifier, bool) {
+	if p == nil {
+		return nil, false
+	}
+	v, ok := p.post[postProof]
+	return v, ok
+}
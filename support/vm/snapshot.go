@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"bufio"
+	"context"
+	"os"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/exported"
+	"github.com/filecoin-project/specs-actors/v8/actors/runtime"
+	"github.com/filecoin-project/specs-actors/v8/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v8/support/ipld"
+)
+
+// The conditions under which a VM applies messages, exported alongside its state so that a scenario
+// replays as it ran.
+type SnapshotConditions struct {
+	Epoch          abi.ChainEpoch
+	NetworkVersion uint64 // network.Version
+	CircSupply     abi.TokenAmount
+}
+
+// Writes the VM's committed and pending state to a CAR file, for loading with NewVMFromCAR.
+// The CAR's first root is the versioned state root, as for test vectors, and its second the VM's conditions.
+// Sector commitments and actor code CIDs are not included.
+func (vm *VM) ExportStateCAR(path string) (err error) {
+	rawRoot, err := vm.checkpoint()
+	if err != nil {
+		return xerrors.Errorf("failed to flush state: %w", err)
+	}
+	root, err := flushTreeTopLevel(vm.ctx, vm.store, rawRoot)
+	if err != nil {
+		return xerrors.Errorf("failed to write state root: %w", err)
+	}
+	conditions, err := vm.store.Put(vm.ctx, &SnapshotConditions{
+		Epoch:          vm.currentEpoch,
+		NetworkVersion: uint64(vm.networkVersion),
+		CircSupply:     vm.circSupply,
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to write conditions: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return xerrors.Errorf("failed to create %s: %w", path, err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = xerrors.Errorf("failed to close %s: %w", path, cerr)
+		}
+	}()
+	w := bufio.NewWriter(f)
+	if err := car.WriteCarWithWalker(vm.ctx, nodeGetterFromStore(vm.store), []cid.Cid{root, conditions}, w, carWalkFn); err != nil {
+		return xerrors.Errorf("failed to write CAR: %w", err)
+	}
+	return w.Flush()
+}
+
+// Creates a VM from the state in a CAR file, with the builtin actors and the test deal client actor.
+// The CAR's first root must be a versioned state root. The VM takes its conditions from the second root when
+// written by ExportStateCAR; a CAR with only a state root, such as one pruned from a chain snapshot, starts at
+// epoch zero under the latest network version, and may be advanced with WithEpoch.
+func NewVMFromCAR(ctx context.Context, path string) (*VM, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	bs := ipld.NewBlockStoreInMemory()
+	header, err := car.LoadCar(bs, bufio.NewReader(f))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load CAR %s: %w", path, err)
+	}
+	if len(header.Roots) == 0 {
+		return nil, xerrors.Errorf("CAR %s has no roots", path)
+	}
+	store := adt.WrapBlockStore(ctx, bs)
+
+	var stateRoot StateRoot
+	if err := store.Get(ctx, header.Roots[0], &stateRoot); err != nil {
+		return nil, xerrors.Errorf("failed to load state root %s: %w", header.Roots[0], err)
+	}
+	if stateRoot.Version != CurrentStateTreeVersion {
+		return nil, xerrors.Errorf("unsupported state tree version %d", stateRoot.Version)
+	}
+	conditions := SnapshotConditions{
+		NetworkVersion: uint64(network.VersionMax),
+		CircSupply:     big.Mul(big.NewInt(1e9), big.NewInt(1e18)),
+	}
+	if len(header.Roots) > 1 {
+		if err := store.Get(ctx, header.Roots[1], &conditions); err != nil {
+			return nil, xerrors.Errorf("failed to load conditions %s: %w", header.Roots[1], err)
+		}
+	}
+
+	lookup := map[cid.Cid]runtime.VMActor{DealClientActorCodeID: DealClientActor{}}
+	for _, ba := range exported.BuiltinActors() {
+		lookup[ba.Code()] = ba
+	}
+	v, err := NewVMAtEpoch(ctx, lookup, store, stateRoot.Actors, conditions.Epoch)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load actors: %w", err)
+	}
+	v.networkVersion = network.Version(conditions.NetworkVersion)
+	v.circSupply = conditions.CircSupply
+	return v, nil
+}
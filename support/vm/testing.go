@@ -275,6 +275,31 @@ func ValueForInvocation(t *testing.T, vm *VM, idxs ...int) abi.TokenAmount {
 	return invocation.Msg.value
 }
 
+// Finds the first invocation at which two runs' invocations diverge, for runs recording state roots.
+// Runs diverge at an invocation with a different receiver, method or exit code, or with a different state root where
+// none of its sub-invocations diverge first. An invocation whose root was not recorded in either run is taken
+// not to diverge, except in its sub-invocations.
+// Returns the indices locating the invocation in the first run, as taken by ParamsForInvocation, and whether the
+// runs diverge. Runs that diverge in the number of invocations at some level do so at the first missing one.
+func FindDivergentInvocation(a, b []*Invocation) ([]int, bool) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		if i >= len(a) || i >= len(b) {
+			return []int{i}, true
+		}
+		x, y := a[i], b[i]
+		if x.Msg.to != y.Msg.to || x.Msg.method != y.Msg.method || x.Exitcode != y.Exitcode {
+			return []int{i}, true
+		}
+		if sub, ok := FindDivergentInvocation(x.SubInvocations, y.SubInvocations); ok {
+			return append([]int{i}, sub...), true
+		}
+		if x.StateRoot.Defined() && y.StateRoot.Defined() && !x.StateRoot.Equals(y.StateRoot) {
+			return []int{i}, true
+		}
+	}
+	return nil, false
+}
+
 //
 // Advancing Time while updating state
 //
@@ -412,6 +437,106 @@ func SubmitInvalidPoSt(t *testing.T, v *VM, minerAddress, workerAddress address.
 	ApplyOk(t, v, workerAddress, minerAddress, big.Zero(), builtin.MethodsMiner.SubmitWindowedPoSt, &submitParams)
 }
 
+// Submits a PoSt covering every partition of the miner's current deadline, if the deadline has any sectors.
+func SubmitPoStForDeadline(t *testing.T, v *VM, minerAddress, workerAddress address.Address) {
+	dlInfo := MinerDLInfo(t, v, minerAddress)
+	deadline := DeadlineState(t, v, minerAddress, dlInfo.Index)
+	if deadline.TotalSectors == 0 {
+		return
+	}
+	partitionArray, err := deadline.PartitionsArray(v.store)
+	require.NoError(t, err)
+
+	var partitions []miner.PoStPartition
+	for i := uint64(0); i < partitionArray.Length(); i++ {
+		partitions = append(partitions, miner.PoStPartition{
+			Index:   i,
+			Skipped: bitfield.New(),
+		})
+	}
+
+	submitParams := miner.SubmitWindowedPoStParams{
+		Deadline:   dlInfo.Index,
+		Partitions: partitions,
+		Proofs: []proof.PoStProof{{
+			PoStProof: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		}},
+		ChainCommitEpoch: dlInfo.Challenge,
+		ChainCommitRand:  []byte(RandString),
+	}
+
+	ApplyOk(t, v, workerAddress, minerAddress, big.Zero(), builtin.MethodsMiner.SubmitWindowedPoSt, &submitParams)
+}
+
+// Returns a replica update of a sector in the given deadline and partition, activating the given deals.
+// The new sealed CID is derived from the seed.
+func NewReplicaUpdate(sectorNumber abi.SectorNumber, deadlineIndex, partitionIndex uint64, seed string, dealIDs []abi.DealID) miner.ReplicaUpdate {
+	return miner.ReplicaUpdate{
+		SectorID:           sectorNumber,
+		Deadline:           deadlineIndex,
+		Partition:          partitionIndex,
+		NewSealedSectorCID: actor_testing.MakeCID(seed, &miner.SealedCIDPrefix),
+		Deals:              dealIDs,
+		UpdateProofType:    abi.RegisteredUpdateProof_StackedDrg32GiBV1,
+	}
+}
+
+// Proves replica updates for a miner's sectors and returns the sectors that were updated.
+// Updates may be skipped by the miner, so callers should check the result.
+func ProveReplicaUpdates(t *testing.T, v *VM, minerAddress, workerAddress address.Address, updates ...miner.ReplicaUpdate) bitfield.BitField {
+	ret := ApplyOk(t, v, workerAddress, minerAddress, big.Zero(), builtin.MethodsMiner.ProveReplicaUpdates,
+		&miner.ProveReplicaUpdatesParams{Updates: updates})
+	updated, ok := ret.(*bitfield.BitField)
+	require.True(t, ok)
+	return *updated
+}
+
+// Upgrades an active CC sector to hold the given staged deals with a replica update.
+// Checks that only this sector was updated, with the expected calls to the market, reward and power actors,
+// and that the sector's original sealed CID is retained as its sector key. Returns the updated sector info.
+func UpgradeSector(t *testing.T, v *VM, minerAddress, workerAddress address.Address, deadlineIndex, partitionIndex uint64,
+	sectorNumber abi.SectorNumber, dealIDs []abi.DealID) *miner.SectorOnChainInfo {
+	oldSector := SectorInfo(t, v, minerAddress, sectorNumber)
+	require.Nil(t, oldSector.SectorKeyCID, "sector %d has already been upgraded", sectorNumber)
+	oldPower := PowerForMinerSector(t, v, minerAddress, sectorNumber)
+	oldPledge := GetMinerBalances(t, v, minerAddress).InitialPledge
+
+	update := NewReplicaUpdate(sectorNumber, deadlineIndex, partitionIndex, fmt.Sprintf("replica-%d", sectorNumber), dealIDs)
+	updated := ProveReplicaUpdates(t, v, minerAddress, workerAddress, update)
+	count, err := updated.Count()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), count)
+	isSet, err := updated.IsSet(uint64(sectorNumber))
+	require.NoError(t, err)
+	require.True(t, isSet)
+
+	subinvocations := []ExpectInvocation{
+		{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.ActivateDeals},
+		{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.VerifyDealsForActivation},
+		{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.ComputeDataCommitment},
+		{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
+		{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CurrentTotalPower},
+	}
+	if !GetMinerBalances(t, v, minerAddress).InitialPledge.Equals(oldPledge) {
+		subinvocations = append(subinvocations, ExpectInvocation{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal})
+	}
+	if newPower := PowerForMinerSector(t, v, minerAddress, sectorNumber); !newPower.Equals(oldPower) {
+		subinvocations = append(subinvocations, ExpectInvocation{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdateClaimedPower})
+	}
+	ExpectInvocation{
+		To:             minerAddress,
+		Method:         builtin.MethodsMiner.ProveReplicaUpdates,
+		SubInvocations: subinvocations,
+	}.Matches(t, v.LastInvocation())
+
+	newSector := SectorInfo(t, v, minerAddress, sectorNumber)
+	require.Equal(t, dealIDs, newSector.DealIDs)
+	require.Equal(t, update.NewSealedSectorCID, newSector.SealedCID)
+	require.NotNil(t, newSector.SectorKeyCID)
+	require.Equal(t, oldSector.SealedCID, *newSector.SectorKeyCID)
+	return newSector
+}
+
 // find the proving deadline and partition index of a miner's sector
 func SectorDeadline(t *testing.T, v *VM, minerIDAddress address.Address, sectorNumber abi.SectorNumber) (uint64, uint64) {
 	var minerState miner.State
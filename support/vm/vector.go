@@ -19,6 +19,7 @@ import (
 	"github.com/ipfs/go-cid"
 	format "github.com/ipfs/go-ipld-format"
 	"github.com/ipld/go-car"
+	mh "github.com/multiformats/go-multihash"
 	cbg "github.com/whyrusleeping/cbor-gen"
 )
 
@@ -279,24 +280,28 @@ func newTestVectorSerial(tv *testVector) (*testVectorSerial, error) {
 	}, nil
 }
 
-// encodeCAR taken from https://github.com/filecoin-project/test-vectors/blob/master/gen/builders/car.go#L16
-func encodeCAR(dagserv format.NodeGetter, roots ...cid.Cid) ([]byte, error) {
-	carWalkFn := func(nd format.Node) (out []*format.Link, err error) {
-		//fmt.Printf("%s: %x\n", nd.Cid(), nd.RawData())
-		for _, link := range nd.Links() {
-			// skip sector cids
-			if link.Cid.Prefix().Codec == cid.FilCommitmentSealed || link.Cid.Prefix().Codec == cid.FilCommitmentUnsealed {
-				continue
-			}
-			// skip builtin actor cids
-			if builtin.IsBuiltinActor(link.Cid) {
-				continue
-			}
-			out = append(out, link)
+// Returns the links of a state tree node to include in a CAR, omitting those to objects not held in the store.
+func carWalkFn(nd format.Node) (out []*format.Link, err error) {
+	for _, link := range nd.Links() {
+		// skip sector cids
+		if link.Cid.Prefix().Codec == cid.FilCommitmentSealed || link.Cid.Prefix().Codec == cid.FilCommitmentUnsealed {
+			continue
+		}
+		// skip builtin actor cids
+		if builtin.IsBuiltinActor(link.Cid) {
+			continue
 		}
-		return out, nil
+		// skip other inline cids, such as those of test actor code
+		if link.Cid.Prefix().MhType == mh.IDENTITY {
+			continue
+		}
+		out = append(out, link)
 	}
+	return out, nil
+}
 
+// encodeCAR taken from https://github.com/filecoin-project/test-vectors/blob/master/gen/builders/car.go#L16
+func encodeCAR(dagserv format.NodeGetter, roots ...cid.Cid) ([]byte, error) {
 	var (
 		out = new(bytes.Buffer)
 		gw  = gzip.NewWriter(out)
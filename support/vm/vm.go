@@ -45,12 +45,17 @@ type VM struct {
 	invocationStack []*Invocation
 	invocations     []*Invocation
 
+	stateRootLimit     int // The number of invocations for which to record state roots.
+	stateRootsRecorded int
+
 	statsSource   StatsSource
 	statsByMethod StatsByCall
 
 	circSupply abi.TokenAmount
 
 	gasPrices Pricelist
+
+	proofVerifiers *ProofVerifiers
 }
 
 // VM types
@@ -91,7 +96,7 @@ func makeChainMessage(from, to address.Address, nonce uint64, value abi.TokenAmo
 			return nil, err
 		}
 	} else {
-		if err := params.(cbor.Er).MarshalCBOR(&buf); err != nil {
+		if err := params.(cbor.Marshaler).MarshalCBOR(&buf); err != nil {
 			return nil, err
 		}
 	}
@@ -115,6 +120,9 @@ type Invocation struct {
 	Exitcode       exitcode.ExitCode
 	Ret            cbor.Marshaler
 	SubInvocations []*Invocation
+	// The state root on completion of the invocation, if recorded (see SetStateRootRecording), else cid.Undef.
+	// A failed invocation's state root is that from before it, its changes having been rolled back.
+	StateRoot cid.Cid
 }
 
 // NewVM creates a new runtime for executing messages.
@@ -201,6 +209,8 @@ func (vm *VM) WithEpoch(epoch abi.ChainEpoch) (*VM, error) {
 		statsByMethod:  make(StatsByCall),
 		circSupply:     vm.circSupply,
 		gasPrices:      &v13PriceList,
+		proofVerifiers: vm.proofVerifiers,
+		stateRootLimit: vm.stateRootLimit,
 	}, nil
 }
 
@@ -229,6 +239,8 @@ func (vm *VM) WithNetworkVersion(nv network.Version) (*VM, error) {
 		statsByMethod:  make(StatsByCall),
 		circSupply:     vm.circSupply,
 		gasPrices:      &v13PriceList,
+		proofVerifiers: vm.proofVerifiers,
+		stateRootLimit: vm.stateRootLimit,
 	}, nil
 }
 
@@ -518,6 +530,11 @@ func (vm *VM) GetEpoch() abi.ChainEpoch {
 	return vm.currentEpoch
 }
 
+// Get the network version for this vm
+func (vm *VM) GetNetworkVersion() network.Version {
+	return vm.networkVersion
+}
+
 // Get call stats
 func (vm *VM) GetCallStats() map[MethodKey]*CallStats {
 	return vm.statsByMethod
@@ -636,6 +653,20 @@ func (vm *VM) StoreWriteBytes() uint64 {
 	return 0
 }
 
+//
+// proofs
+//
+
+// Sets verifiers to use for registered proof types in place of the fake syscalls.
+// VMs derived from this one inherit the verifiers.
+func (vm *VM) SetProofVerifiers(p *ProofVerifiers) {
+	vm.proofVerifiers = p
+}
+
+func (vm *VM) GetProofVerifiers() *ProofVerifiers {
+	return vm.proofVerifiers
+}
+
 //
 // invocation tracking
 //
@@ -656,10 +687,27 @@ func (vm *VM) endInvocation(code exitcode.ExitCode, ret cbor.Marshaler) {
 	current := vm.invocationStack[curIndex]
 	current.Exitcode = code
 	current.Ret = ret
+	if vm.stateRootsRecorded < vm.stateRootLimit {
+		// Flushing the state tree doesn't commit it, so a later rollback is unaffected.
+		root, err := vm.actors.Root()
+		if err != nil {
+			panic(err)
+		}
+		current.StateRoot = root
+		vm.stateRootsRecorded++
+	}
 
 	vm.invocationStack = vm.invocationStack[:curIndex]
 }
 
+// Records the state root on completion of each invocation, top-level or nested, on the invocation, until roots
+// have been recorded for limit invocations. Recording flushes the state tree at the end of each invocation, so is
+// off by default, and the limit bounds the memory held by the roots' state trees.
+// VMs derived from this one inherit the limit, and each records up to limit roots.
+func (vm *VM) SetStateRootRecording(limit int) {
+	vm.stateRootLimit = limit
+}
+
 func (vm *VM) Invocations() []*Invocation {
 	return vm.invocations
 }
@@ -0,0 +1,74 @@
+package vm7Util
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/rt"
+	vm7 "github.com/filecoin-project/specs-actors/v7/support/vm"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	builtin8 "github.com/filecoin-project/specs-actors/v8/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v8/actors/builtin/exported"
+	miner8 "github.com/filecoin-project/specs-actors/v8/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v8/actors/migration/nv16"
+	"github.com/filecoin-project/specs-actors/v8/actors/states"
+	vm8 "github.com/filecoin-project/specs-actors/v8/support/vm"
+)
+
+// Runs the nv16 migration over the state of a v7 VM and returns a v8 VM at the same epoch,
+// so that a scenario begun against v7 actors can continue against v8 actors.
+// The migrated state must satisfy the v8 state invariants.
+func MigrateToV8(t *testing.T, v *vm7.VM) *vm8.VM {
+	ctx := context.Background()
+	log := nv16.TestLogger{TB: t}
+	root, err := nv16.MigrateStateTree(ctx, v.Store(), v.StateRoot(), v.GetEpoch(), nv16.Config{MaxWorkers: 1}, log, nv16.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	lookup := map[cid.Cid]rt.VMActor{}
+	for _, ba := range exported.BuiltinActors() {
+		lookup[ba.Code()] = ba
+	}
+	v8, err := vm8.NewVMAtEpoch(ctx, lookup, v.Store(), root, v.GetEpoch())
+	require.NoError(t, err)
+	v8.SetCirculatingSupply(v.GetCirculatingSupply())
+
+	stateTree, err := v8.GetStateTree()
+	require.NoError(t, err)
+	totalBalance, err := v8.GetTotalActorBalance()
+	require.NoError(t, err)
+	acc, err := states.CheckStateInvariants(stateTree, totalBalance, v8.GetEpoch()-1)
+	require.NoError(t, err)
+	require.True(t, acc.IsEmpty(), strings.Join(acc.Messages(), "\n"))
+	return v8
+}
+
+// Advances a migrated VM to the next epoch, running cron.
+func AdvanceOneEpochWithCronV8(t *testing.T, v *vm8.VM) *vm8.VM {
+	vm8.ApplyOk(t, v, builtin8.SystemActorAddr, builtin8.CronActorAddr, big.Zero(), builtin8.MethodsCron.EpochTick, nil)
+
+	v, err := v.WithEpoch(v.GetEpoch() + 1)
+	require.NoError(t, err)
+	return v
+}
+
+// Proves the current deadline of each miner on a migrated VM, then advances one deadline running cron every epoch.
+func ProveThenAdvanceOneDeadlineWithCronV8(t *testing.T, v *vm8.VM, minerInfos []MinerInfo) *vm8.VM {
+	for _, minerInfo := range minerInfos {
+		vm8.SubmitPoStForDeadline(t, v, minerInfo.MinerAddress, minerInfo.WorkerAddress)
+	}
+	for i := 0; i < int(miner8.WPoStChallengeWindow); i++ {
+		v = AdvanceOneEpochWithCronV8(t, v)
+	}
+	return v
+}
+
+func AdvanceOneDayWhileProvingV8(t *testing.T, v *vm8.VM, minerInfos []MinerInfo) *vm8.VM {
+	for i := uint64(0); i < miner8.WPoStPeriodDeadlines; i++ {
+		v = ProveThenAdvanceOneDeadlineWithCronV8(t, v, minerInfos)
+	}
+	return v
+}
@@ -287,14 +287,15 @@ func CreateDeals(t *testing.T, numberOfDeals int, v *vm7.VM, clientAddress addre
 	var dealIDs []abi.DealID
 	for i := 0; i < numberOfDeals; i++ {
 		dealStart := v.GetEpoch() + miner.MaxProveCommitDuration[sealProof]
-		deals := publishDeal(t, v, workerAddress, workerAddress, minerAddress, "dealLabel"+strconv.Itoa(i), 32<<30, false, dealStart, 180*builtin.EpochsInDay)
+		deals := PublishDeal(t, v, workerAddress, workerAddress, minerAddress, "dealLabel"+strconv.Itoa(i), 32<<30, false, dealStart, 180*builtin.EpochsInDay)
 		dealIDs = append(dealIDs, deals.IDs...)
 	}
 
 	return dealIDs
 }
 
-func publishDeal(t *testing.T, v *vm7.VM, provider, dealClient, minerID address.Address, dealLabel string,
+// Publishes a single deal from the client to the miner, checking the expected subinvocations.
+func PublishDeal(t *testing.T, v *vm7.VM, provider, dealClient, minerID address.Address, dealLabel string,
 	pieceSize abi.PaddedPieceSize, verifiedDeal bool, dealStart abi.ChainEpoch, dealLifetime abi.ChainEpoch,
 ) *market7.PublishStorageDealsReturn {
 	deal := market7.DealProposal{